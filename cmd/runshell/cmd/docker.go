@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/iamlongalong/runshell/pkg/server"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dockerMemory          string
+	dockerCPUs            float64
+	dockerPidsLimit       int64
+	dockerNetwork         string
+	dockerReadOnly        bool
+	dockerTmpfs           []string
+	dockerCapDrop         []string
+	dockerNoNewPrivileges bool
+	dockerSecurityOpts    []string
+	dockerUlimits         []string
+	dockerHosts           []string
+	dockerLabels          []string
+	dockerDNS             []string
+
+	dockerMaxMemory     string
+	dockerMaxCPUs       float64
+	dockerMaxPids       int64
+	dockerAllowImages   []string
+	dockerAllowNetworks []string
+)
+
+// addDockerFlags 注册 Docker 容器的资源限制、隔离设置以及会话请求的上限
+func addDockerFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&dockerMemory, "docker-memory", "", "Memory limit of the container, e.g. 512m")
+	cmd.Flags().Float64Var(&dockerCPUs, "docker-cpus", 0, "Number of CPUs the container can use, e.g. 1.5")
+	cmd.Flags().Int64Var(&dockerPidsLimit, "docker-pids-limit", 0, "Maximum number of processes in the container")
+	cmd.Flags().StringVar(&dockerNetwork, "docker-network", "", "Network mode of the container, e.g. bridge or none")
+	cmd.Flags().BoolVar(&dockerReadOnly, "docker-read-only", false, "Mount the root filesystem of the container read-only (/tmp is mounted as tmpfs)")
+	cmd.Flags().StringArrayVar(&dockerTmpfs, "docker-tmpfs", nil, "tmpfs mount as path[:options], can be repeated")
+	cmd.Flags().StringSliceVar(&dockerCapDrop, "docker-cap-drop", nil, "Linux capabilities to drop, e.g. ALL")
+	cmd.Flags().BoolVar(&dockerNoNewPrivileges, "docker-no-new-privileges", false, "Prevent processes in the container from gaining new privileges")
+	cmd.Flags().StringArrayVar(&dockerSecurityOpts, "docker-security-opt", nil, "Security option of the container, can be repeated")
+	cmd.Flags().StringArrayVar(&dockerUlimits, "docker-ulimit", nil, "ulimit as name=soft[:hard], can be repeated")
+	cmd.Flags().StringArrayVar(&dockerHosts, "docker-add-host", nil, "Extra hosts entry as host:ip, can be repeated")
+	cmd.Flags().StringArrayVar(&dockerLabels, "docker-label", nil, "Container label as key=value, can be repeated")
+	cmd.Flags().StringSliceVar(&dockerDNS, "docker-dns", nil, "DNS servers of the container")
+
+	cmd.Flags().StringVar(&dockerMaxMemory, "docker-max-memory", "", "Maximum memory a session can request with docker_config")
+	cmd.Flags().Float64Var(&dockerMaxCPUs, "docker-max-cpus", 0, "Maximum CPUs a session can request with docker_config")
+	cmd.Flags().Int64Var(&dockerMaxPids, "docker-max-pids", 0, "Maximum pids limit a session can request with docker_config")
+	cmd.Flags().StringSliceVar(&dockerAllowImages, "docker-allow-image", nil, "Images a session can request with docker_config besides --docker-image")
+	cmd.Flags().StringSliceVar(&dockerAllowNetworks, "docker-allow-network", nil, "Network modes a session can request with docker_config besides --docker-network and none")
+}
+
+// dockerConfig 根据命令行参数生成 Docker 执行器的配置
+func dockerConfig(image, workDir string) (types.DockerConfig, error) {
+	config := types.DockerConfig{
+		Image:                     image,
+		WorkDir:                   workDir,
+		AllowUnregisteredCommands: true,
+		CPUs:                      dockerCPUs,
+		PidsLimit:                 dockerPidsLimit,
+		NetworkMode:               dockerNetwork,
+		ReadOnly:                  dockerReadOnly,
+		CapDrop:                   dockerCapDrop,
+		NoNewPrivileges:           dockerNoNewPrivileges,
+		SecurityOpt:               dockerSecurityOpts,
+		ExtraHosts:                dockerHosts,
+		DNS:                       dockerDNS,
+	}
+	var err error
+	if config.Memory, err = parseMemory(dockerMemory, "--docker-memory"); err != nil {
+		return config, err
+	}
+	if len(dockerTmpfs) > 0 {
+		config.Tmpfs = make(map[string]string)
+		for _, t := range dockerTmpfs {
+			path, opts, _ := strings.Cut(t, ":")
+			config.Tmpfs[path] = opts
+		}
+	}
+	for _, u := range dockerUlimits {
+		ulimit, err := parseUlimit(u)
+		if err != nil {
+			return config, err
+		}
+		config.Ulimits = append(config.Ulimits, ulimit)
+	}
+	if len(dockerLabels) > 0 {
+		config.Labels = make(map[string]string)
+		for _, l := range dockerLabels {
+			k, v, ok := strings.Cut(l, "=")
+			if !ok {
+				return config, fmt.Errorf("invalid --docker-label %q, expected key=value", l)
+			}
+			config.Labels[k] = v
+		}
+	}
+	return config, nil
+}
+
+// dockerLimits 根据命令行参数生成会话请求的 Docker 配置上限
+func dockerLimits() (server.DockerLimits, error) {
+	maxMemory, err := parseMemory(dockerMaxMemory, "--docker-max-memory")
+	if err != nil {
+		return server.DockerLimits{}, err
+	}
+	return server.DockerLimits{
+		MaxMemory:    maxMemory,
+		MaxCPUs:      dockerMaxCPUs,
+		MaxPids:      dockerMaxPids,
+		Images:       dockerAllowImages,
+		NetworkModes: dockerAllowNetworks,
+	}, nil
+}
+
+// parseMemory 解析内存大小，如 512m、2g，为空时返回 0
+func parseMemory(s, flag string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, err := units.RAMInBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", flag, s, err)
+	}
+	return v, nil
+}
+
+// parseUlimit 解析 name=soft[:hard] 格式的 ulimit，省略硬限制时与软限制相同
+func parseUlimit(s string) (types.Ulimit, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return types.Ulimit{}, fmt.Errorf("invalid --docker-ulimit %q, expected name=soft[:hard]", s)
+	}
+	softStr, hardStr, hasHard := strings.Cut(value, ":")
+	soft, err := strconv.ParseInt(softStr, 10, 64)
+	if err != nil {
+		return types.Ulimit{}, fmt.Errorf("invalid --docker-ulimit %q: %w", s, err)
+	}
+	hard := soft
+	if hasHard {
+		if hard, err = strconv.ParseInt(hardStr, 10, 64); err != nil {
+			return types.Ulimit{}, fmt.Errorf("invalid --docker-ulimit %q: %w", s, err)
+		}
+	}
+	return types.Ulimit{Name: name, Soft: soft, Hard: hard}, nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+func TestDockerConfig(t *testing.T) {
+	defer func() {
+		dockerMemory, dockerTmpfs, dockerUlimits, dockerLabels, dockerMaxMemory = "", nil, nil, nil, ""
+	}()
+
+	dockerMemory = "512m"
+	dockerTmpfs = []string{"/run:size=16m", "/cache"}
+	dockerUlimits = []string{"nofile=1024:2048", "nproc=64"}
+	dockerLabels = []string{"team=infra"}
+	config, err := dockerConfig("alpine", "/workspace")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Image != "alpine" || config.WorkDir != "/workspace" || config.Memory != 512<<20 {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+	if !reflect.DeepEqual(config.Tmpfs, map[string]string{"/run": "size=16m", "/cache": ""}) {
+		t.Errorf("Unexpected tmpfs: %v", config.Tmpfs)
+	}
+	want := []types.Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}, {Name: "nproc", Soft: 64, Hard: 64}}
+	if !reflect.DeepEqual(config.Ulimits, want) {
+		t.Errorf("Unexpected ulimits: %v", config.Ulimits)
+	}
+	if config.Labels["team"] != "infra" {
+		t.Errorf("Unexpected labels: %v", config.Labels)
+	}
+
+	for _, bad := range []func(){
+		func() { dockerMemory = "lots" },
+		func() { dockerMemory, dockerUlimits = "", []string{"nofile"} },
+		func() { dockerUlimits = []string{"nofile=a"} },
+		func() { dockerUlimits, dockerLabels = nil, []string{"team"} },
+	} {
+		bad()
+		if _, err := dockerConfig("alpine", ""); err == nil {
+			t.Errorf("Expected error for memory=%q ulimits=%v labels=%v", dockerMemory, dockerUlimits, dockerLabels)
+		}
+	}
+
+	dockerMaxMemory = "1g"
+	limits, err := dockerLimits()
+	if err != nil || limits.MaxMemory != 1<<30 {
+		t.Errorf("Unexpected limits: %+v, %v", limits, err)
+	}
+}
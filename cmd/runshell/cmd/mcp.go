@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Run as an MCP server over stdio",
+	Long: `Run RunShell as a Model Context Protocol server speaking JSON-RPC over stdin/stdout.
+The HTTP server also serves MCP over streamable HTTP at /api/v1/mcp.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// 标准输出用于协议消息，日志改写到标准错误
+		log.SetConsoleWriter(os.Stderr)
+		gin.DefaultWriter = os.Stderr
+
+		srv, err := newServer("")
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		if err := srv.MCP().ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && err != context.Canceled {
+			return fmt.Errorf("mcp server: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+	addServerFlags(mcpCmd)
+}
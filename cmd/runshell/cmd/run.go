@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/executor"
+	"github.com/iamlongalong/runshell/pkg/executor/docker"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/iamlongalong/runshell/pkg/workflow"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runDockerImage string
+	runWorkDir     string
+	runEnvVars     []string
+	runMaxParallel int
+	runStateDir    string
+	runJSON        bool
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run [workflow.yaml]",
+	Short: "Run a workflow",
+	Long: `Run a workflow of dependent steps defined in YAML or JSON.
+Independent steps run concurrently; the output of each step is printed prefixed with its id.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		wf, err := workflow.Load(args[0])
+		if err != nil {
+			return err
+		}
+
+		// 中断时取消运行，正在执行的步骤被终止
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		run, err := runWorkflow(ctx, wf, os.Stdout)
+		if err != nil {
+			return err
+		}
+		if run.Status != workflow.StatusSucceeded {
+			return fmt.Errorf("workflow run %s %s", run.ID, run.Status)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().StringVar(&runDockerImage, "docker-image", "", "Docker image to run the steps in (runs locally when empty)")
+	runCmd.Flags().StringVar(&runWorkDir, "workdir", "", "Working directory for the steps")
+	runCmd.Flags().StringArrayVarP(&runEnvVars, "env", "e", nil, "Environment variables (KEY=VALUE)")
+	runCmd.Flags().IntVar(&runMaxParallel, "max-parallel", 0, "Maximum number of steps running at the same time (0 for no limit)")
+	runCmd.Flags().StringVar(&runStateDir, "state-dir", "", "Directory where the run state is persisted")
+	runCmd.Flags().BoolVar(&runJSON, "json", false, "Print progress as newline-delimited JSON events")
+}
+
+// runWorkflow 根据命令行参数创建执行器并运行工作流，进度写入 out
+func runWorkflow(ctx context.Context, wf *workflow.Workflow, out io.Writer) (*workflow.Run, error) {
+	options := &types.ExecuteOptions{WorkDir: runWorkDir, Env: parseEnvVars(runEnvVars)}
+
+	var builder types.ExecutorBuilder
+	if runDockerImage != "" {
+		builder = docker.NewDockerExecutorBuilder(types.DockerConfig{
+			Image:                     runDockerImage,
+			WorkDir:                   "/workspace",
+			AllowUnregisteredCommands: true,
+		})
+	} else {
+		builder = executor.NewLocalExecutorBuilder(types.LocalConfig{
+			AllowUnregisteredCommands: true,
+			UseBuiltinCommands:        true,
+		})
+	}
+	exec, err := builder.Build(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create executor: %w", err)
+	}
+	defer exec.Close()
+
+	runner := workflow.NewRunner(exec).WithMaxParallel(runMaxParallel).WithOptions(options)
+	if runStateDir != "" {
+		store, err := workflow.NewFileStore(runStateDir)
+		if err != nil {
+			return nil, err
+		}
+		runner.WithStore(store)
+	}
+
+	var handler func(*workflow.Event)
+	if runJSON {
+		enc := json.NewEncoder(out)
+		handler = func(event *workflow.Event) { _ = enc.Encode(event) }
+	} else {
+		handler = newProgressPrinter(out).handle
+	}
+	return runner.Run(ctx, wf, handler)
+}
+
+// progressPrinter 以文本形式输出运行进度，步骤的每行输出以步骤 ID 为前缀
+type progressPrinter struct {
+	out     io.Writer
+	partial map[string]bool // 步骤的最后一行输出还没有换行
+}
+
+func newProgressPrinter(out io.Writer) *progressPrinter {
+	return &progressPrinter{out: out, partial: make(map[string]bool)}
+}
+
+func (p *progressPrinter) handle(event *workflow.Event) {
+	switch event.Type {
+	case workflow.EventStepStarted:
+		fmt.Fprintf(p.out, "==> %s started\n", event.StepID)
+	case workflow.EventStepOutput:
+		p.write(event.StepID, event.Stdout+event.Stderr)
+	case workflow.EventStepFinished:
+		if p.partial[event.StepID] {
+			fmt.Fprintln(p.out)
+			p.partial[event.StepID] = false
+		}
+		step := event.Step
+		switch step.Status {
+		case workflow.StatusSucceeded:
+			fmt.Fprintf(p.out, "==> %s succeeded (%s)\n", step.ID, step.EndTime.Sub(step.StartTime).Round(time.Millisecond))
+		case workflow.StatusFailed:
+			fmt.Fprintf(p.out, "==> %s failed after %d attempt(s): %s\n", step.ID, step.Attempts, step.Error)
+		default:
+			fmt.Fprintf(p.out, "==> %s %s: %s\n", step.ID, step.Status, step.Error)
+		}
+	case workflow.EventRunFinished:
+		fmt.Fprintf(p.out, "Workflow run %s %s\n", event.RunID, event.Run.Status)
+	}
+}
+
+// write 输出步骤的数据块，在每行开头加上步骤 ID
+func (p *progressPrinter) write(step, data string) {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(data, "\n") {
+		if line == "" {
+			continue
+		}
+		if !p.partial[step] {
+			sb.WriteString("[" + step + "] ")
+		}
+		sb.WriteString(line)
+		p.partial[step] = !strings.HasSuffix(line, "\n")
+	}
+	io.WriteString(p.out, sb.String())
+}
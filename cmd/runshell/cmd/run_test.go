@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iamlongalong/runshell/pkg/workflow"
+)
+
+func TestRunWorkflow(t *testing.T) {
+	defer func() { runWorkDir = "" }()
+	runWorkDir = t.TempDir()
+
+	wf, err := workflow.Parse([]byte(`
+steps:
+  - id: greet
+    run: echo hello; printf partial
+    outputs:
+      name: 'hello'
+  - id: echo
+    needs: [greet]
+    command: echo
+    args: ["${{ steps.greet.outputs.name }} again"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	run, err := runWorkflow(context.Background(), wf, &out)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if run.Status != workflow.StatusSucceeded {
+		t.Errorf("Expected run to succeed, got %s", run.Status)
+	}
+	for _, want := range []string{"[greet] hello\n[greet] partial\n==> greet succeeded", "[echo] hello again\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
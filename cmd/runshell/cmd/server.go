@@ -1,17 +1,28 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/iamlongalong/runshell/pkg/audit"
+	"github.com/iamlongalong/runshell/pkg/commands/script"
 	"github.com/iamlongalong/runshell/pkg/executor"
 	"github.com/iamlongalong/runshell/pkg/executor/docker"
+	"github.com/iamlongalong/runshell/pkg/executor/remote"
+	"github.com/iamlongalong/runshell/pkg/executor/ssh"
+	"github.com/iamlongalong/runshell/pkg/schedule"
 	"github.com/iamlongalong/runshell/pkg/server"
+	"github.com/iamlongalong/runshell/pkg/snapshot"
 	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/iamlongalong/runshell/pkg/workflow"
 	"github.com/spf13/cobra"
 )
 
@@ -21,44 +32,53 @@ var (
 	dockerImage  string
 	executorType string
 	workDir      string
+
+	snapshotDir       string
+	snapshotRetention int
+	autoSnapshot      bool
+
+	scriptDir string
+
+	remotes           []string
+	remoteToken       string
+	remoteCAFile      string
+	remoteCertFile    string
+	remoteKeyFile     string
+	remoteInsecureTLS bool
+
+	sshHosts      []string
+	sshKeyFile    string
+	sshKnownHosts string
+	sshInsecure   bool
+
+	retryConfig    string
+	idempotencyTTL time.Duration
+
+	cacheTTL         time.Duration
+	cacheSize        int
+	cacheEnv         []string
+	cacheFingerprint bool
+
+	workflowDir string
+	scheduleDir string
 )
 
+// remoteTokenEnv 未指定 --remote-token 时读取的环境变量
+const remoteTokenEnv = "RUNSHELL_REMOTE_TOKEN"
+
+// sshPasswordEnv SSH 密码认证读取的环境变量
+const sshPasswordEnv = "RUNSHELL_SSH_PASSWORD"
+
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Start the HTTP server",
 	Long:  `Start the HTTP server to handle command execution requests.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// 创建执行器构建器
-		execBuilder, err := createExecutorBuilder(executorType, &types.ExecuteOptions{
-			WorkDir: workDir,
-		})
+		srv, err := newServer(serverAddr)
 		if err != nil {
-			return fmt.Errorf("failed to create executor builder: %w", err)
-		}
-
-		// 如果指定了审计目录，创建审计执行器
-		if auditDir != "" {
-			// 创建审计器
-			logFile := filepath.Join(auditDir, "audit.log")
-			auditor, err := audit.NewFileAuditor(logFile)
-			if err != nil {
-				return fmt.Errorf("failed to create auditor: %w", err)
-			}
-
-			// 创建审计执行器构建器
-			origBuilder := execBuilder
-			execBuilder = types.ExecutorBuilderFunc(func(options *types.ExecuteOptions) (types.Executor, error) {
-				exec, err := origBuilder.Build(options)
-				if err != nil {
-					return nil, err
-				}
-				return executor.NewAuditedExecutor(exec, auditor), nil
-			})
+			return err
 		}
 
-		// 创建服务器
-		srv := server.NewServer(execBuilder, serverAddr)
-
 		// 启动服务器
 		if err := srv.Start(); err != nil {
 			return fmt.Errorf("failed to start server: %w", err)
@@ -83,10 +103,199 @@ var serverCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(serverCmd)
 	serverCmd.Flags().StringVar(&serverAddr, "addr", ":8080", "Server address")
-	serverCmd.Flags().StringVar(&auditDir, "audit-dir", "", "Directory for audit logs")
-	serverCmd.Flags().StringVar(&dockerImage, "docker-image", "", "Docker image to use")
-	serverCmd.Flags().StringVar(&executorType, "executor-type", "local", "Type of executor to use (local or docker)")
-	serverCmd.Flags().StringVar(&workDir, "work-dir", "/workspace", "Work directory")
+	addServerFlags(serverCmd)
+}
+
+// addServerFlags 注册创建服务器所需的参数
+func addServerFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&auditDir, "audit-dir", "", "Directory for audit logs")
+	cmd.Flags().StringVar(&dockerImage, "docker-image", "", "Docker image to use")
+	cmd.Flags().StringVar(&executorType, "executor-type", "local", "Type of executor to use (local, docker, remote or ssh)")
+	cmd.Flags().StringVar(&workDir, "work-dir", "/workspace", "Work directory")
+	cmd.Flags().StringVar(&snapshotDir, "snapshot-dir", "", "Directory for session snapshots, snapshots are disabled when empty")
+	cmd.Flags().IntVar(&snapshotRetention, "snapshot-retention", snapshot.DefaultRetention, "Maximum number of snapshots kept per session")
+	cmd.Flags().StringVar(&scriptDir, "script-dir", "", "Directory of scripts exported as tools (empty to disable)")
+	cmd.Flags().BoolVar(&autoSnapshot, "auto-snapshot", false, "Snapshot the session workdir before destructive commands (requires --snapshot-dir)")
+	cmd.Flags().StringArrayVar(&remotes, "remote", nil, "Remote runshell server as name=url, can be repeated (the first one is used by --executor-type remote)")
+	cmd.Flags().StringVar(&remoteToken, "remote-token", "", "Bearer token for remote servers (defaults to $"+remoteTokenEnv+")")
+	cmd.Flags().StringVar(&remoteCAFile, "remote-ca-file", "", "CA certificate file for verifying remote servers")
+	cmd.Flags().StringVar(&remoteCertFile, "remote-cert-file", "", "Client certificate file for remote servers")
+	cmd.Flags().StringVar(&remoteKeyFile, "remote-key-file", "", "Client key file for remote servers")
+	cmd.Flags().BoolVar(&remoteInsecureTLS, "remote-insecure", false, "Skip verifying remote server certificates")
+	cmd.Flags().StringArrayVar(&sshHosts, "ssh-host", nil, "SSH host as name=user@host:port, can be repeated (the first one is used by --executor-type ssh)")
+	cmd.Flags().StringVar(&sshKeyFile, "ssh-key", "", "Private key file for SSH hosts (ssh-agent is used when $SSH_AUTH_SOCK is set, password from $"+sshPasswordEnv+")")
+	cmd.Flags().StringVar(&sshKnownHosts, "ssh-known-hosts", "", "known_hosts file for verifying SSH hosts (defaults to ~/.ssh/known_hosts)")
+	cmd.Flags().BoolVar(&sshInsecure, "ssh-insecure", false, "Skip verifying SSH host keys")
+	cmd.Flags().StringVar(&retryConfig, "retry-config", "", "JSON file of retry rules for flaky commands")
+	cmd.Flags().DurationVar(&idempotencyTTL, "idempotency-ttl", server.DefaultIdempotencyTTL, "How long responses are kept for Idempotency-Key replays (0 to disable)")
+	cmd.Flags().DurationVar(&cacheTTL, "cache-ttl", 0, "Cache results of read-only commands in sessions for this long (0 to disable)")
+	cmd.Flags().IntVar(&cacheSize, "cache-size", executor.DefaultCacheEntries, "Maximum number of cached results per session")
+	cmd.Flags().StringSliceVar(&cacheEnv, "cache-env", nil, "Environment variables that are part of the result cache key")
+	cmd.Flags().BoolVar(&cacheFingerprint, "cache-fingerprint", false, "Include a fingerprint of the workdir contents in the result cache key")
+	cmd.Flags().StringVar(&workflowDir, "workflow-dir", "", "Directory where workflow run states are persisted (empty to keep them in memory)")
+	cmd.Flags().StringVar(&scheduleDir, "schedule-dir", "", "Directory where schedules and their run history are persisted (empty to keep them in memory)")
+	addDockerFlags(cmd)
+}
+
+// newServer 根据命令行参数创建服务器，server 和 mcp 命令共用
+func newServer(addr string) (*server.Server, error) {
+	// 创建执行器构建器
+	execBuilder, err := createExecutorBuilder(executorType, &types.ExecuteOptions{
+		WorkDir: workDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create executor builder: %w", err)
+	}
+
+	// layers 依次包装创建的执行器，会话请求定制的 Docker 执行器同样需要包装
+	var layers []func(types.Executor) types.Executor
+	wrap := func(builder types.ExecutorBuilder) types.ExecutorBuilder {
+		return types.ExecutorBuilderFunc(func(options *types.ExecuteOptions) (types.Executor, error) {
+			exec, err := builder.Build(options)
+			if err != nil {
+				return nil, err
+			}
+			for _, layer := range layers {
+				exec = layer(exec)
+			}
+			return exec, nil
+		})
+	}
+
+	// 按规则重试失败的命令，位于缓存内层，失败的结果不会被缓存
+	if retryConfig != "" {
+		rules, err := loadRetryRules(retryConfig)
+		if err != nil {
+			return nil, err
+		}
+		retryMiddleware, err := executor.RetryRulesMiddleware(rules...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry config %s: %w", retryConfig, err)
+		}
+		layers = append(layers, func(exec types.Executor) types.Executor {
+			return executor.Chain(exec, retryMiddleware)
+		})
+	}
+
+	// 缓存只读命令的结果，每个执行器（会话）使用独立的缓存
+	if cacheTTL > 0 {
+		cacheConfig := executor.CacheConfig{
+			TTL:         cacheTTL,
+			MaxEntries:  cacheSize,
+			EnvKeys:     cacheEnv,
+			Fingerprint: cacheFingerprint,
+		}
+		layers = append(layers, func(exec types.Executor) types.Executor {
+			return executor.NewCachingExecutor(exec, cacheConfig)
+		})
+	}
+
+	// 统计所有执行器的命令执行情况，位于缓存外层以记录缓存命中
+	metrics := executor.NewMetrics()
+	layers = append(layers, func(exec types.Executor) types.Executor {
+		return executor.Chain(exec, executor.MetricsMiddleware(metrics))
+	})
+
+	// 如果指定了审计目录，审计所有执行器执行的命令。
+	// 审计位于最外层，缓存命中的命令同样会被记录，重试的命令记录最终结果
+	var auditor *audit.FileAuditor
+	if auditDir != "" {
+		// 创建审计器
+		logFile := filepath.Join(auditDir, "audit.log")
+		auditor, err = audit.NewFileAuditor(logFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create auditor: %w", err)
+		}
+
+		layers = append(layers, func(exec types.Executor) types.Executor {
+			return executor.NewAuditedExecutor(exec, auditor)
+		})
+	}
+
+	// 创建服务器
+	execBuilder = wrap(execBuilder)
+	srv := server.NewServer(execBuilder, addr).WithIdempotencyTTL(idempotencyTTL).WithMetrics(metrics)
+
+	// 允许会话请求在上限内定制 Docker 容器
+	if executorType == "docker" {
+		base, err := dockerConfig(dockerImage, workDir)
+		if err != nil {
+			return nil, err
+		}
+		limits, err := dockerLimits()
+		if err != nil {
+			return nil, err
+		}
+		srv.WithDocker(base, limits, func(config types.DockerConfig) types.ExecutorBuilder {
+			return wrap(docker.NewDockerExecutorBuilder(config).WithOptions(&types.ExecuteOptions{WorkDir: workDir}))
+		})
+	}
+	if auditor != nil {
+		srv.WithAuditLog(auditor)
+	}
+
+	// 注册远程执行器，创建会话时可以选择
+	remoteConfigs, err := parseRemotes()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range remoteConfigs {
+		srv.WithRemote(r.name, remote.NewRemoteExecutorBuilder(r.config))
+	}
+
+	// 注册 SSH 主机，创建会话时可以选择
+	sshConfigs, err := parseSSHHosts()
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range sshConfigs {
+		srv.WithSSHHost(h.name, ssh.NewSSHExecutorBuilder(h.config))
+	}
+
+	// 启用会话快照
+	if snapshotDir != "" {
+		manager, err := snapshot.NewManager(snapshotDir, snapshotRetention)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create snapshot manager: %w", err)
+		}
+		srv.WithSnapshots(manager, autoSnapshot)
+	}
+
+	// 持久化工作流的运行状态
+	if workflowDir != "" {
+		store, err := workflow.NewFileStore(workflowDir)
+		if err != nil {
+			return nil, err
+		}
+		srv.WithWorkflowStore(store)
+	}
+
+	// 持久化计划任务，重启后继续调度
+	if scheduleDir != "" {
+		store, err := schedule.NewFileStore(scheduleDir)
+		if err != nil {
+			return nil, err
+		}
+		srv.WithScheduleStore(store)
+	}
+
+	// 导出脚本目录中的脚本
+	if scriptDir != "" {
+		scriptExec, err := execBuilder.Build(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create script executor: %w", err)
+		}
+		scripts, err := script.NewScriptManager(&script.Config{
+			RootDir:  scriptDir,
+			Executor: scriptExec,
+		}, &types.ExecuteOptions{WorkDir: scriptDir})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create script manager: %w", err)
+		}
+		srv.WithScripts(scripts)
+	}
+
+	return srv, nil
 }
 
 // createExecutorBuilder 创建执行器构建器
@@ -96,11 +305,29 @@ func createExecutorBuilder(execType string, options *types.ExecuteOptions) (type
 		if dockerImage == "" {
 			dockerImage = "ubuntu:latest"
 		}
-		return docker.NewDockerExecutorBuilder(types.DockerConfig{
-			Image:                     dockerImage,
-			WorkDir:                   workDir,
-			AllowUnregisteredCommands: true,
-		}).WithOptions(options), nil
+		config, err := dockerConfig(dockerImage, workDir)
+		if err != nil {
+			return nil, err
+		}
+		return docker.NewDockerExecutorBuilder(config).WithOptions(options), nil
+	case "remote":
+		remoteConfigs, err := parseRemotes()
+		if err != nil {
+			return nil, err
+		}
+		if len(remoteConfigs) == 0 {
+			return nil, fmt.Errorf("--remote is required for the remote executor")
+		}
+		return remote.NewRemoteExecutorBuilder(remoteConfigs[0].config).WithOptions(options), nil
+	case "ssh":
+		sshConfigs, err := parseSSHHosts()
+		if err != nil {
+			return nil, err
+		}
+		if len(sshConfigs) == 0 {
+			return nil, fmt.Errorf("--ssh-host is required for the ssh executor")
+		}
+		return ssh.NewSSHExecutorBuilder(sshConfigs[0].config).WithOptions(options), nil
 	case "local":
 		return executor.NewLocalExecutorBuilder(types.LocalConfig{
 			AllowUnregisteredCommands: true,
@@ -111,3 +338,130 @@ func createExecutorBuilder(execType string, options *types.ExecuteOptions) (type
 		return nil, fmt.Errorf("unsupported executor type: %s", execType)
 	}
 }
+
+// namedRemote 表示通过 --remote 指定的远程服务端
+type namedRemote struct {
+	name   string
+	config types.RemoteConfig
+}
+
+// parseRemotes 解析 --remote 参数，格式为 name=url，省略名称时使用 URL 中的主机名
+func parseRemotes() ([]namedRemote, error) {
+	token := remoteToken
+	if token == "" {
+		token = os.Getenv(remoteTokenEnv)
+	}
+
+	var result []namedRemote
+	seen := make(map[string]bool)
+	for _, spec := range remotes {
+		name, rawURL, ok := strings.Cut(spec, "=")
+		if !ok || strings.Contains(name, "://") {
+			rawURL = spec
+			u, err := url.Parse(rawURL)
+			if err != nil || u.Hostname() == "" {
+				return nil, fmt.Errorf("invalid remote %q, expected name=url", spec)
+			}
+			name = u.Hostname()
+		}
+		if name == "" || rawURL == "" {
+			return nil, fmt.Errorf("invalid remote %q, expected name=url", spec)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate remote name: %s", name)
+		}
+		seen[name] = true
+		result = append(result, namedRemote{name: name, config: types.RemoteConfig{
+			URL:                rawURL,
+			Token:              token,
+			CAFile:             remoteCAFile,
+			CertFile:           remoteCertFile,
+			KeyFile:            remoteKeyFile,
+			InsecureSkipVerify: remoteInsecureTLS,
+		}})
+	}
+	return result, nil
+}
+
+// namedSSHHost 表示通过 --ssh-host 指定的 SSH 主机
+type namedSSHHost struct {
+	name   string
+	config types.SSHConfig
+}
+
+// parseSSHHosts 解析 --ssh-host 参数，格式为 name=user@host:port，省略名称时使用主机名，省略用户时使用 $USER。
+// 会话默认在登录用户的主目录中执行命令。
+func parseSSHHosts() ([]namedSSHHost, error) {
+	var result []namedSSHHost
+	seen := make(map[string]bool)
+	for _, spec := range sshHosts {
+		name, target, ok := strings.Cut(spec, "=")
+		if !ok {
+			name, target = "", spec
+		}
+		user, host, ok := strings.Cut(target, "@")
+		if !ok {
+			user, host = os.Getenv("USER"), target
+		}
+		if user == "" || host == "" {
+			return nil, fmt.Errorf("invalid ssh host %q, expected name=user@host:port", spec)
+		}
+		if name == "" {
+			name = host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				name = h
+			}
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate ssh host name: %s", name)
+		}
+		seen[name] = true
+		result = append(result, namedSSHHost{name: name, config: types.SSHConfig{
+			Host:                  host,
+			User:                  user,
+			Password:              os.Getenv(sshPasswordEnv),
+			KeyFile:               sshKeyFile,
+			UseAgent:              os.Getenv("SSH_AUTH_SOCK") != "",
+			KnownHostsFile:        sshKnownHosts,
+			InsecureIgnoreHostKey: sshInsecure,
+			UseBuiltinCommands:    true,
+		}})
+	}
+	return result, nil
+}
+
+// retryRuleConfig 表示重试配置文件中的一条规则，等待时间使用 time.ParseDuration 的格式，例如 "500ms"
+type retryRuleConfig struct {
+	executor.RetryRule
+	InitialBackoff string `json:"initial_backoff,omitempty"`
+	MaxBackoff     string `json:"max_backoff,omitempty"`
+}
+
+// loadRetryRules 读取 --retry-config 指定的重试规则，文件内容为规则的 JSON 数组
+func loadRetryRules(path string) ([]executor.RetryRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retry config: %w", err)
+	}
+	var configs []retryRuleConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse retry config %s: %w", path, err)
+	}
+
+	rules := make([]executor.RetryRule, 0, len(configs))
+	for i, config := range configs {
+		rule := config.RetryRule
+		if config.InitialBackoff != "" {
+			if rule.InitialBackoff, err = time.ParseDuration(config.InitialBackoff); err != nil {
+				return nil, fmt.Errorf("invalid initial_backoff in retry rule %d: %w", i, err)
+			}
+		}
+		if config.MaxBackoff != "" {
+			if rule.MaxBackoff, err = time.ParseDuration(config.MaxBackoff); err != nil {
+				return nil, fmt.Errorf("invalid max_backoff in retry rule %d: %w", i, err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestServerCommand(t *testing.T) {
@@ -27,3 +30,86 @@ func TestServerStartStop(t *testing.T) {
 		t.Errorf("Unexpected error: %v", err)
 	}
 }
+
+func TestParseRemotes(t *testing.T) {
+	defer func() { remotes, remoteToken = nil, "" }()
+
+	remotes = []string{"build-1=https://build-1:8080", "http://build-2:8080/?a=b"}
+	remoteToken = "secret"
+	result, err := parseRemotes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 remotes, got %d", len(result))
+	}
+	if result[0].name != "build-1" || result[0].config.URL != "https://build-1:8080" || result[0].config.Token != "secret" {
+		t.Errorf("Unexpected remote: %+v", result[0])
+	}
+	if result[1].name != "build-2" || result[1].config.URL != "http://build-2:8080/?a=b" {
+		t.Errorf("Unexpected remote: %+v", result[1])
+	}
+
+	remotes = []string{"a=http://x", "a=http://y"}
+	if _, err := parseRemotes(); err == nil {
+		t.Error("Expected error for duplicate remote names")
+	}
+}
+
+func TestParseSSHHosts(t *testing.T) {
+	defer func() { sshHosts, sshKeyFile = nil, "" }()
+
+	sshHosts = []string{"vm-1=deploy@10.0.0.1:2222", "admin@build-2"}
+	sshKeyFile = "/tmp/id_ed25519"
+	result, err := parseSSHHosts()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 hosts, got %d", len(result))
+	}
+	if result[0].name != "vm-1" || result[0].config.User != "deploy" || result[0].config.Host != "10.0.0.1:2222" || result[0].config.KeyFile != "/tmp/id_ed25519" {
+		t.Errorf("Unexpected host: %+v", result[0])
+	}
+	if result[1].name != "build-2" || result[1].config.User != "admin" || result[1].config.Host != "build-2" {
+		t.Errorf("Unexpected host: %+v", result[1])
+	}
+
+	sshHosts = []string{"a=u@x", "a=u@y"}
+	if _, err := parseSSHHosts(); err == nil {
+		t.Error("Expected error for duplicate ssh host names")
+	}
+}
+
+func TestLoadRetryRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.json")
+	config := `[
+		{"command": "pip*", "args": ["install"], "max_attempts": 4, "initial_backoff": "500ms", "max_backoff": "10s", "jitter": 0.2, "stderr_patterns": ["Read timed out"]},
+		{"command": "git", "args": ["clone"], "exit_codes": [128]}
+	]`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := loadRetryRules(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(rules))
+	}
+	if rules[0].Command != "pip*" || rules[0].MaxAttempts != 4 || rules[0].InitialBackoff != 500*time.Millisecond ||
+		rules[0].MaxBackoff != 10*time.Second || rules[0].Jitter != 0.2 || len(rules[0].StderrPatterns) != 1 {
+		t.Errorf("Unexpected rule: %+v", rules[0])
+	}
+	if rules[1].Command != "git" || len(rules[1].ExitCodes) != 1 || rules[1].ExitCodes[0] != 128 {
+		t.Errorf("Unexpected rule: %+v", rules[1])
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"command": "npm", "initial_backoff": "soon"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRetryRules(path); err == nil {
+		t.Error("Expected error for invalid backoff")
+	}
+}
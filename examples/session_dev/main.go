@@ -1,15 +1,13 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"time"
 
+	"github.com/iamlongalong/runshell/pkg/client"
 	"github.com/iamlongalong/runshell/pkg/executor/docker"
 	"github.com/iamlongalong/runshell/pkg/server"
 	"github.com/iamlongalong/runshell/pkg/types"
@@ -20,7 +18,15 @@ const (
 	projectDir = "/tmp/runshell-projects/hello-world"
 )
 
-func createSession() (string, error) {
+// dockerConfig 是开发环境容器的配置
+var dockerConfig = types.DockerConfig{
+	Image:                     "golang:1.20",
+	WorkDir:                   "/workspace",
+	BindMount:                 fmt.Sprintf("%s:/workspace", projectDir),
+	AllowUnregisteredCommands: true,
+}
+
+func createSession(ctx context.Context, c *client.Client) (string, error) {
 	log.Printf("Creating session...")
 
 	// 创建项目目录
@@ -29,21 +35,10 @@ func createSession() (string, error) {
 	}
 
 	// 准备会话配置
-	sessionConfig := struct {
-		ExecutorType string                `json:"executor_type"`
-		DockerConfig *types.DockerConfig   `json:"docker_config,omitempty"`
-		LocalConfig  *types.LocalConfig    `json:"local_config,omitempty"`
-		Options      *types.ExecuteOptions `json:"options,omitempty"`
-		Metadata     map[string]string     `json:"metadata,omitempty"`
-	}{
-		ExecutorType: "docker",
-		DockerConfig: &types.DockerConfig{
-			Image:                     "golang:1.20",
-			WorkDir:                   "/workspace",
-			User:                      "",
-			BindMount:                 fmt.Sprintf("%s:/workspace", projectDir),
-			AllowUnregisteredCommands: true,
-		},
+	config := dockerConfig
+	req := &types.SessionRequest{
+		ExecutorType: types.ExecutorTypeDocker,
+		DockerConfig: &config,
 		Options: &types.ExecuteOptions{
 			WorkDir: "/workspace",
 			Env: map[string]string{
@@ -52,105 +47,27 @@ func createSession() (string, error) {
 		},
 	}
 
-	log.Printf("Creating session with options: %+v", sessionConfig)
-
-	// 序列化配置
-	body, err := json.Marshal(sessionConfig)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal session config: %v", err)
-	}
-
-	// 发送创建会话请求
-	resp, err := http.Post(serverAddr+"/api/v1/sessions", "application/json", bytes.NewBuffer(body))
-	if err != nil {
-		return "", fmt.Errorf("failed to send session creation request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("session creation failed with status: %s", resp.Status)
-	}
-
-	// 解析响应
-	var result struct {
-		Session struct {
-			ID string `json:"id"`
-		} `json:"session"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode response: %v", err)
-	}
-
-	return result.Session.ID, nil
-}
-
-func execCommand(sessionID string, command string, args []string) error {
-	// 准备命令请求
-	execRequest := struct {
-		Command string                `json:"command"`
-		Args    []string              `json:"args"`
-		Options *types.ExecuteOptions `json:"options,omitempty"`
-	}{
-		Command: command,
-		Args:    args,
-		Options: &types.ExecuteOptions{
-			WorkDir: "/workspace",
-		},
-	}
-
-	// 序列化请求
-	body, err := json.Marshal(execRequest)
-	if err != nil {
-		return fmt.Errorf("failed to marshal exec request: %v", err)
-	}
+	log.Printf("Creating session with options: %+v", req)
 
-	// 发送执行命令请求
-	resp, err := http.Post(
-		fmt.Sprintf("%s/api/v1/sessions/%s/exec", serverAddr, sessionID),
-		"application/json",
-		bytes.NewBuffer(body),
-	)
+	session, err := c.CreateSession(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to send exec request: %v", err)
+		return "", fmt.Errorf("failed to create session: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("command execution failed with status: %s", resp.Status)
-	}
-
-	// 读取并打印输出
-	output, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %v", err)
-	}
-
-	fmt.Printf("Command output:\n%s\n", string(output))
-	return nil
+	return session.ID, nil
 }
 
-func deleteSession(sessionID string) error {
-	// 创建删除请求
-	req, err := http.NewRequest(
-		http.MethodDelete,
-		fmt.Sprintf("%s/api/v1/sessions/%s", serverAddr, sessionID),
-		nil,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create delete request: %v", err)
-	}
-
-	// 发送请求
-	resp, err := http.DefaultClient.Do(req)
+func execCommand(ctx context.Context, c *client.Client, sessionID string, command string, args []string) error {
+	resp, err := c.Exec(ctx, &client.ExecRequest{
+		Command:   command,
+		Args:      args,
+		WorkDir:   "/workspace",
+		SessionID: sessionID,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to send delete request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("session deletion failed with status: %s", resp.Status)
+		return fmt.Errorf("failed to execute command: %v", err)
 	}
 
+	fmt.Printf("Command output (exit code %d):\n%s\n", resp.ExitCode, resp.Output)
 	return nil
 }
 
@@ -158,20 +75,18 @@ func main() {
 	log.Printf("Project directory: %s", projectDir)
 
 	// 创建 Docker 执行器构建器
-	execBuilder := docker.NewDockerExecutorBuilder(types.DockerConfig{
-		Image:                     "golang:1.20",
-		WorkDir:                   "/workspace",
-		BindMount:                 fmt.Sprintf("%s:/workspace", projectDir),
-		AllowUnregisteredCommands: true,
-	}).WithOptions(&types.ExecuteOptions{
+	execBuilder := docker.NewDockerExecutorBuilder(dockerConfig).WithOptions(&types.ExecuteOptions{
 		WorkDir: "/workspace",
 		Env: map[string]string{
 			"GOPROXY": "https://goproxy.cn,direct",
 		},
 	})
 
-	// 启动服务器
-	srv := server.NewServer(execBuilder, ":8081")
+	// 启动服务器，允许会话通过 docker_config 创建容器
+	srv := server.NewServer(execBuilder, ":8081").WithDocker(dockerConfig, server.DockerLimits{},
+		func(config types.DockerConfig) types.ExecutorBuilder {
+			return docker.NewDockerExecutorBuilder(config)
+		})
 
 	go func() {
 		if err := srv.Start(); err != nil {
@@ -182,8 +97,14 @@ func main() {
 	// 等待服务器启动
 	time.Sleep(2 * time.Second)
 
+	ctx := context.Background()
+	c, err := client.NewClient(serverAddr)
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+
 	// 创建会话
-	sessionID, err := createSession()
+	sessionID, err := createSession(ctx, c)
 	if err != nil {
 		log.Fatalf("Failed to create session: %v", err)
 	}
@@ -199,13 +120,13 @@ func main() {
 	}
 
 	for _, cmd := range commands {
-		if err := execCommand(sessionID, cmd.cmd, cmd.args); err != nil {
+		if err := execCommand(ctx, c, sessionID, cmd.cmd, cmd.args); err != nil {
 			log.Printf("Failed to execute command %s: %v", cmd.cmd, err)
 		}
 	}
 
 	// 删除会话
-	if err := deleteSession(sessionID); err != nil {
+	if err := c.DeleteSession(ctx, sessionID); err != nil {
 		log.Printf("Failed to delete session: %v", err)
 	}
 }
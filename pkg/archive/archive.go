@@ -0,0 +1,277 @@
+// Package archive 提供工作目录的 tar 打包与解包功能。
+// 打包结果中的条目路径均相对于被打包的目录。
+package archive
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// TarDir 将目录打包为 tar 流。
+// 符号链接不会被跟随，设备文件、套接字等特殊文件会被跳过。
+func TarDir(ctx context.Context, root string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(ctx, root, pw))
+	}()
+	return pr
+}
+
+func writeTar(ctx context.Context, root string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if p == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		var link string
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		case info.IsDir(), info.Mode().IsRegular():
+		default:
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// Untar 将 tar 流解包到目录中。
+// 条目路径不允许逃逸出目标目录。
+func Untar(root string, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		target, err := SecureJoin(root, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			if err := os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ClearDir 删除目录中的所有内容，但保留目录本身
+func ClearDir(root string) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(root, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 恢复目录时使用的临时目录前缀，位于目录内以保证与目录在同一文件系统中
+const (
+	StagePrefix  = ".runshell-restore-"
+	BackupPrefix = ".runshell-backup-"
+)
+
+// RestoreDir 用 tar 流替换目录中的内容，保留目录本身。
+// 先解包到临时目录，归档损坏、被截断或解包失败时原有内容保持不变；
+// 解包成功后再替换原有内容，替换失败时恢复原有内容。
+func RestoreDir(root string, r io.Reader) error {
+	stage, err := os.MkdirTemp(root, StagePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stage)
+	if err := Untar(stage, r); err != nil {
+		return err
+	}
+
+	backup, err := os.MkdirTemp(root, BackupPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	skip := map[string]bool{filepath.Base(stage): true, filepath.Base(backup): true}
+	err = moveEntries(root, backup, skip)
+	if err == nil {
+		if err = moveEntries(stage, root, nil); err == nil {
+			return os.RemoveAll(backup)
+		}
+	}
+
+	// 恢复原有内容，恢复失败时保留备份目录
+	if rerr := clearEntries(root, skip); rerr != nil {
+		return fmt.Errorf("%w (original contents kept in %s: %v)", err, backup, rerr)
+	}
+	if rerr := moveEntries(backup, root, nil); rerr != nil {
+		return fmt.Errorf("%w (original contents kept in %s: %v)", err, backup, rerr)
+	}
+	os.RemoveAll(backup)
+	return err
+}
+
+// moveEntries 将 src 中的条目移动到 dst 中，跳过 skip 中的名称
+func moveEntries(src, dst string, skip map[string]bool) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if skip[entry.Name()] {
+			continue
+		}
+		if err := os.Rename(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearEntries 删除目录中除 skip 外的所有条目
+func clearEntries(root string, skip map[string]bool) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if skip[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(root, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SecureJoin 将相对路径拼接到根目录下，拒绝逃逸出根目录的路径
+func SecureJoin(root, name string) (string, error) {
+	clean := path.Clean("/" + filepath.ToSlash(name))
+	if clean == "/" {
+		return root, nil
+	}
+	target := filepath.Join(root, filepath.FromSlash(clean))
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path escapes root directory: %s", name)
+	}
+	return target, nil
+}
+
+// StripPrefix 返回去掉第一层目录后的 tar 流。
+// Docker 归档 API 返回的条目以被归档目录的名称为前缀，使用该函数可将其转换为相对路径。
+func StripPrefix(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		tr := tar.NewReader(r)
+		tw := tar.NewWriter(pw)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				pw.CloseWithError(tw.Close())
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			idx := strings.Index(hdr.Name, "/")
+			if idx < 0 || idx == len(hdr.Name)-1 {
+				// 顶层目录本身
+				continue
+			}
+			hdr.Name = hdr.Name[idx+1:]
+			if err := tw.WriteHeader(hdr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
@@ -0,0 +1,86 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTarUntarRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "a.txt"), []byte("hello"), 0600))
+	require.NoError(t, os.Symlink("sub/a.txt", filepath.Join(src, "link")))
+
+	dst := t.TempDir()
+	rc := TarDir(context.Background(), src)
+	defer rc.Close()
+	require.NoError(t, Untar(dst, rc))
+
+	data, err := os.ReadFile(filepath.Join(dst, "sub", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	info, err := os.Stat(filepath.Join(dst, "sub", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	link, err := os.Readlink(filepath.Join(dst, "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "sub/a.txt", link)
+}
+
+func TestSecureJoin(t *testing.T) {
+	root := "/workspace"
+
+	p, err := SecureJoin(root, "a/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "/workspace/a/b.txt", p)
+
+	p, err = SecureJoin(root, "../../etc/passwd")
+	assert.NoError(t, err)
+	assert.Equal(t, "/workspace/etc/passwd", p)
+
+	p, err = SecureJoin(root, "/")
+	assert.NoError(t, err)
+	assert.Equal(t, root, p)
+}
+
+func TestRestoreDir(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, ".hidden"), []byte("hidden"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "new.txt"), bytes.Repeat([]byte("new\n"), 4096), 0644))
+	var buf bytes.Buffer
+	rc := TarDir(context.Background(), src)
+	_, err := io.Copy(&buf, rc)
+	require.NoError(t, err)
+	rc.Close()
+
+	dst := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dst, "old"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dst, "old", "a.txt"), []byte("old"), 0644))
+
+	// 截断或损坏的归档不改变原有内容
+	for _, data := range [][]byte{buf.Bytes()[:buf.Len()/2], []byte("not a tar archive at all")} {
+		assert.Error(t, RestoreDir(dst, bytes.NewReader(data)))
+		entries, err := os.ReadDir(dst)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "old", entries[0].Name())
+	}
+
+	require.NoError(t, RestoreDir(dst, bytes.NewReader(buf.Bytes())))
+	entries, err := os.ReadDir(dst)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{".hidden", "new.txt"}, names)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/iamlongalong/runshell/pkg/types"
@@ -37,6 +38,14 @@ func (a *FileAuditor) LogCommandExecution(exec *types.CommandExecution) error {
 		exec.ExitCode,
 	)
 
+	if exec.Attempt > 0 {
+		logEntry += fmt.Sprintf(", Attempt: %d", exec.Attempt)
+	}
+
+	if exec.Script != "" {
+		logEntry += fmt.Sprintf(", Script: %q", exec.Script)
+	}
+
 	if exec.Error != nil {
 		logEntry += fmt.Sprintf(", Error: %v", exec.Error)
 	}
@@ -57,6 +66,26 @@ func (a *FileAuditor) LogCommandExecution(exec *types.CommandExecution) error {
 	return nil
 }
 
+// Entries 返回最近的 limit 条审计记录，limit 小于等于 0 时返回全部记录
+func (a *FileAuditor) Entries(limit int) ([]string, error) {
+	data, err := os.ReadFile(a.logFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read audit log file: %v", err)
+	}
+
+	entries := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(entries) == 1 && entries[0] == "" {
+		return nil, nil
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
 // ConsoleAuditor 实现基于控制台的审计器
 type ConsoleAuditor struct {
 	timeFormat string
@@ -53,3 +53,29 @@ func TestAuditor(t *testing.T) {
 	assert.Contains(t, logStr, "completed")
 	assert.Contains(t, logStr, "ExitCode: 0")
 }
+
+func TestFileAuditorEntries(t *testing.T) {
+	auditor, err := NewFileAuditor(filepath.Join(t.TempDir(), "audit.log"))
+	assert.NoError(t, err)
+
+	entries, err := auditor.Entries(10)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, auditor.LogCommandExecution(&types.CommandExecution{
+			Command: types.Command{Command: name},
+			Status:  "completed",
+		}))
+	}
+
+	entries, err = auditor.Entries(2)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Contains(t, entries[0], "Command: b")
+	assert.Contains(t, entries[1], "Command: c")
+
+	entries, err = auditor.Entries(0)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
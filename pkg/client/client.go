@@ -0,0 +1,249 @@
+// Package client 提供了 RunShell 服务端的 Go 客户端。
+//
+// 客户端封装了 HTTP API 中的命令执行、会话、文件传输、命令和脚本查询，
+// 以及基于 WebSocket 的交互式终端；服务端返回的错误转换为 *APIError。
+// Client 同时实现了 types.Executor，可以在需要执行器的地方使用远程服务端。
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIPrefix HTTP API 的路径前缀
+const APIPrefix = "/api/v1"
+
+// RetryPolicy 定义请求失败时的重试策略。
+// 仅幂等请求（GET、PUT、DELETE）会在网络错误或服务端暂时不可用时重试，
+// 执行命令和创建会话的请求不会重试。
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数（包含第一次），小于等于 1 时不重试
+	MinBackoff  time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	MaxBackoff  time.Duration // 等待时间上限
+}
+
+// DefaultRetryPolicy 默认的重试策略
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  100 * time.Millisecond,
+	MaxBackoff:  2 * time.Second,
+}
+
+// Client 是 RunShell 服务端的客户端
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	retry      RetryPolicy
+	header     http.Header
+	sessionID  string // 作为执行器使用时绑定的会话
+}
+
+// NewClient 创建客户端，baseURL 为服务端地址，例如 http://localhost:8080
+func NewClient(baseURL string) (*Client, error) {
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported url scheme: %s", u.Scheme)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		retry:      DefaultRetryPolicy,
+		header:     make(http.Header),
+	}, nil
+}
+
+// WithHTTPClient 设置使用的 HTTP 客户端
+func (c *Client) WithHTTPClient(httpClient *http.Client) *Client {
+	c.httpClient = httpClient
+	return c
+}
+
+// WithRetry 设置重试策略
+func (c *Client) WithRetry(policy RetryPolicy) *Client {
+	c.retry = policy
+	return c
+}
+
+// WithHeader 设置每个请求附带的请求头，例如认证信息
+func (c *Client) WithHeader(key, value string) *Client {
+	c.header.Set(key, value)
+	return c
+}
+
+// BaseURL 返回服务端地址
+func (c *Client) BaseURL() string {
+	return c.baseURL.String()
+}
+
+// Health 检查服务端是否可用
+func (c *Client) Health(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, "/health", nil, nil, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// endpoint 返回 API 路径对应的 URL
+func (c *Client) endpoint(path string, query url.Values) string {
+	u := *c.baseURL
+	u.Path += APIPrefix + path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// do 发送请求并返回成功的响应，失败的响应转换为 *APIError。
+// 调用方负责关闭响应体。
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	return c.doWithHeader(ctx, method, path, query, body, contentType, nil)
+}
+
+// doWithHeader 与 do 相同，header 中的请求头会附加到请求中。
+// 带有幂等键的请求即使请求方法不是幂等的也会重试。
+func (c *Client) doWithHeader(ctx context.Context, method, path string, query url.Values, body io.Reader, contentType string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint(path, query), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	attempts := 1
+	if (idempotent(method) || req.Header.Get(idempotencyHeader) != "") && c.retry.MaxAttempts > 1 && (req.Body == nil || req.GetBody != nil) {
+		attempts = c.retry.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var retryAfter time.Duration
+		if err == nil {
+			err = decodeError(resp)
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			resp.Body.Close()
+			if !retryableStatus(resp.StatusCode) {
+				return nil, err
+			}
+		} else {
+			err = fmt.Errorf("request %s %s failed: %w", method, path, err)
+		}
+		if attempt >= attempts {
+			return nil, err
+		}
+
+		wait := c.backoff(attempt)
+		if retryAfter > wait {
+			wait = min(retryAfter, c.retry.MaxBackoff)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// doJSON 发送 JSON 请求并解析 JSON 响应，in 或 out 为 nil 时忽略
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, in, out interface{}) (*http.Response, error) {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	resp, err := c.do(ctx, method, path, query, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// backoff 返回第 attempt 次失败后的等待时间，带有随机抖动
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.retry.MinBackoff << (attempt - 1)
+	if wait <= 0 || (c.retry.MaxBackoff > 0 && wait > c.retry.MaxBackoff) {
+		wait = c.retry.MaxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+	// 在 [wait/2, wait) 范围内随机，避免多个客户端同时重试
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// idempotent 判断请求方法是否可以安全重试
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryableStatus 判断状态码是否表示服务端暂时不可用
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter 解析以秒为单位的 Retry-After 响应头
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,295 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/executor"
+	"github.com/iamlongalong/runshell/pkg/server"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testScripts 是用于测试的脚本管理器
+type testScripts struct{}
+
+func (testScripts) ListCommands() []types.CommandInfo {
+	return []types.CommandInfo{{Name: "deploy", Category: "script"}}
+}
+
+func (testScripts) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	return &types.ExecuteResult{Output: "deployed " + strings.Join(ctx.Command.Args, " ")}, nil
+}
+
+// newTestClient 启动使用本地执行器的服务端并返回客户端
+func newTestClient(t *testing.T) (*Client, string) {
+	gin.SetMode(gin.TestMode)
+	workDir := t.TempDir()
+	s := server.NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		UseBuiltinCommands:        true,
+		AllowUnregisteredCommands: true,
+		WorkDir:                   workDir,
+	}), ":0").WithScripts(testScripts{})
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+
+	c, err := NewClient(ts.URL)
+	require.NoError(t, err)
+	return c, workDir
+}
+
+func TestExec(t *testing.T) {
+	c, workDir := newTestClient(t)
+	ctx := context.Background()
+
+	resp, err := c.Exec(ctx, &ExecRequest{Command: "sh", Args: []string{"-c", "echo out; echo err >&2; exit 3"}})
+	require.NoError(t, err)
+	assert.Equal(t, 3, resp.ExitCode)
+	assert.Contains(t, resp.Output, "out\n")
+	assert.Contains(t, resp.Output, "err\n")
+	assert.NotEmpty(t, resp.Error)
+
+	t.Run("stream", func(t *testing.T) {
+		stream, err := c.ExecStream(ctx, &ExecRequest{Command: "sh", Args: []string{"-c", "echo one; echo two"}, WorkDir: workDir})
+		require.NoError(t, err)
+		defer stream.Close()
+		output, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		assert.Equal(t, "one\ntwo\n", string(output))
+		require.NotNil(t, stream.Result())
+		assert.Equal(t, 0, stream.Result().ExitCode)
+	})
+
+	t.Run("argument error", func(t *testing.T) {
+		_, err := c.Exec(ctx, &ExecRequest{Command: "head", Args: []string{"-n", "x", "a.txt"}})
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.True(t, errors.Is(err, ErrBadRequest))
+	})
+}
+
+func TestSessionsAndFiles(t *testing.T) {
+	c, workDir := newTestClient(t)
+	ctx := context.Background()
+
+	session, err := c.CreateSession(ctx, &types.SessionRequest{Options: &types.ExecuteOptions{WorkDir: workDir}})
+	require.NoError(t, err)
+
+	sessions, err := c.ListSessions(ctx)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, session.ID, sessions[0].ID)
+
+	require.NoError(t, c.WriteFile(ctx, session.ID, "dir/a.txt", []byte("hello\n"), 0600))
+	data, err := c.ReadFile(ctx, session.ID, "dir/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(data))
+
+	entries, err := c.ReadDir(ctx, session.ID, "dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "a.txt", entries[0].Name)
+
+	_, err = c.ReadFile(ctx, session.ID, "missing.txt")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	resp, err := c.Exec(ctx, &ExecRequest{Command: "cat", Args: []string{"dir/a.txt"}, SessionID: session.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 0, resp.ExitCode)
+	assert.Equal(t, "hello\n", resp.Output)
+
+	require.NoError(t, c.DeleteSession(ctx, session.ID))
+	err = c.DeleteSession(ctx, session.ID)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestCommandsAndScripts(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+
+	commands, err := c.Commands(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, commands)
+
+	info, err := c.CommandHelp(ctx, "head")
+	require.NoError(t, err)
+	assert.Equal(t, "head", info.Name)
+	assert.NotNil(t, info.Schema)
+
+	_, err = c.CommandHelp(ctx, "missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	scripts, err := c.ListScripts(ctx)
+	require.NoError(t, err)
+	require.Len(t, scripts, 1)
+
+	resp, err := c.RunScript(ctx, &ExecRequest{Command: "deploy", Args: []string{"prod"}})
+	require.NoError(t, err)
+	assert.Equal(t, "deployed prod", resp.Output)
+}
+
+func TestTerminal(t *testing.T) {
+	c, workDir := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	terminal, err := c.Terminal(ctx, &TerminalRequest{Command: "sh", WorkDir: workDir, Rows: 24, Cols: 80})
+	require.NoError(t, err)
+	defer terminal.Close()
+
+	require.NoError(t, terminal.Resize(40, 100))
+	time.Sleep(100 * time.Millisecond)
+	_, err = terminal.Write([]byte("stty size; exit 3\n"))
+	require.NoError(t, err)
+
+	output, err := io.ReadAll(terminal)
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "40 100")
+	require.NotNil(t, terminal.Result())
+	assert.Equal(t, 3, terminal.Result().ExitCode)
+}
+
+func TestExecutor(t *testing.T) {
+	c, workDir := newTestClient(t)
+
+	var executor types.Executor = c
+	assert.Equal(t, ExecutorName, executor.Name())
+	assert.NotEmpty(t, executor.ListCommands())
+
+	var stdout bytes.Buffer
+	result, err := executor.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "sh", Args: []string{"-c", "echo hi"}},
+		Options: &types.ExecuteOptions{WorkDir: workDir, Stdout: &stdout},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Equal(t, "hi\n", stdout.String())
+
+	result, err = executor.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		IsPiped: true,
+		PipeContext: &types.PipelineContext{Commands: []*types.Command{
+			{Command: "echo", Args: []string{"a b"}},
+			{Command: "tr", Args: []string{" ", "-"}},
+		}},
+		Options: &types.ExecuteOptions{WorkDir: workDir},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "a-b\n", result.Output)
+
+	result, err = executor.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "sh", Args: []string{"-c", "exit 4"}},
+	})
+	assert.Error(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, 4, result.ExitCode)
+
+	session, err := c.CreateSession(context.Background(), &types.SessionRequest{Options: &types.ExecuteOptions{WorkDir: workDir}})
+	require.NoError(t, err)
+	bound := c.WithSession(session.ID)
+	assert.Equal(t, session.ID, bound.SessionID())
+	assert.Empty(t, c.SessionID())
+	result, err = bound.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "pwd"},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, result.Output, workDir)
+}
+
+func TestExecWithoutStreaming(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(r.URL.Path, "/api/v1/sessions/") {
+			// 会话执行接口返回 types.ExecuteResult 的字段
+			w.Write([]byte(`{"CommandName":"ls","ExitCode":2,"Output":"session","Error":{},"CacheStatus":"miss"}`))
+			return
+		}
+		w.Write([]byte(`{"exit_code":0,"output":"plain"}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL)
+	require.NoError(t, err)
+
+	resp, err := c.Exec(context.Background(), &ExecRequest{Command: "ls"})
+	require.NoError(t, err)
+	assert.Equal(t, "plain", resp.Output)
+
+	resp, err = c.Exec(context.Background(), &ExecRequest{Command: "ls", SessionID: "s1"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, resp.ExitCode)
+	assert.Equal(t, "session", resp.Output)
+	assert.Equal(t, types.CacheMiss, resp.Cache)
+}
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"busy"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/exec") {
+			w.Write([]byte(`{"exit_code":0,"output":"` + r.Header.Get("Idempotency-Key") + `"}`))
+			return
+		}
+		w.Write([]byte(`[{"name":"ls"}]`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL)
+	require.NoError(t, err)
+	c.WithRetry(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+
+	commands, err := c.Commands(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, commands, 1)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// 执行命令不是幂等请求，不会重试
+	calls.Store(0)
+	_, err = c.Exec(context.Background(), &ExecRequest{Command: "ls"})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "busy", apiErr.Message)
+	assert.True(t, errors.Is(err, ErrServer))
+	assert.Equal(t, int32(1), calls.Load())
+
+	// 带有幂等键的执行请求会重试
+	calls.Store(0)
+	resp, err := c.Exec(context.Background(), &ExecRequest{Command: "ls", IdempotencyKey: "key-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "key-1", resp.Output)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// 重试次数用尽后返回最后一次的错误
+	calls.Store(-10)
+	_, err = c.Commands(context.Background())
+	assert.True(t, errors.Is(err, ErrServer))
+	assert.Equal(t, int32(-7), calls.Load())
+
+	// ctx 取消时停止等待
+	calls.Store(-10)
+	c.WithRetry(RetryPolicy{MaxAttempts: 5, MinBackoff: time.Hour, MaxBackoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.Commands(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// Commands 列出服务端可用的命令，与 ListCommands 不同的是可以传入 ctx 并返回错误
+func (c *Client) Commands(ctx context.Context) ([]types.CommandInfo, error) {
+	var commands []types.CommandInfo
+	if _, err := c.doJSON(ctx, http.MethodGet, "/commands", nil, nil, &commands); err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+// CommandHelp 获取命令的帮助信息和参数结构
+func (c *Client) CommandHelp(ctx context.Context, name string) (*types.CommandInfo, error) {
+	var info types.CommandInfo
+	query := url.Values{"command": {name}, "format": {"json"}}
+	if _, err := c.doJSON(ctx, http.MethodGet, "/help", query, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ListScripts 列出服务端的脚本
+func (c *Client) ListScripts(ctx context.Context) ([]types.CommandInfo, error) {
+	var scripts []types.CommandInfo
+	if _, err := c.doJSON(ctx, http.MethodGet, "/scripts", nil, nil, &scripts); err != nil {
+		return nil, err
+	}
+	return scripts, nil
+}
+
+// RunScript 执行脚本，req.Command 为脚本名称；脚本不支持在会话中执行
+func (c *Client) RunScript(ctx context.Context, req *ExecRequest) (*ExecResponse, error) {
+	if req.SessionID != "" {
+		return nil, fmt.Errorf("scripts cannot be run in a session")
+	}
+	var resp ExecResponse
+	if _, err := c.doJSON(ctx, http.MethodPost, "/scripts/exec", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// 与 errors.Is 配合使用的错误类别，*APIError 按状态码匹配
+var (
+	ErrBadRequest     = errors.New("bad request")
+	ErrForbidden      = errors.New("forbidden")
+	ErrNotFound       = errors.New("not found")
+	ErrNotImplemented = errors.New("not implemented")
+	ErrServer         = errors.New("server error")
+)
+
+// APIError 表示服务端返回的错误响应（server.ErrorResponse）
+type APIError struct {
+	StatusCode int    // HTTP 状态码
+	Message    string // 服务端返回的错误信息
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("runshell: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// Is 实现 errors.Is，按状态码匹配错误类别
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusRequestEntityTooLarge
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrNotImplemented:
+		return e.StatusCode == http.StatusNotImplemented
+	case ErrServer:
+		return e.StatusCode >= 500 && e.StatusCode != http.StatusNotImplemented
+	}
+	return false
+}
+
+// errorResponse 与 server.ErrorResponse 对应
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// decodeError 将失败的响应转换为 *APIError
+func decodeError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	var body errorResponse
+	if err := json.Unmarshal(data, &body); err == nil && body.Error != "" {
+		apiErr.Message = body.Error
+	} else if text := strings.TrimSpace(string(data)); text != "" {
+		apiErr.Message = text
+	} else {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
@@ -0,0 +1,258 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// streamContentType 流式执行响应的内容类型，与 server.StreamContentType 对应
+const streamContentType = "application/x-ndjson"
+
+// snapshotHeader 自动快照 ID 的响应头，与 server.SnapshotHeader 对应
+const snapshotHeader = "X-Runshell-Snapshot"
+
+// idempotencyHeader 幂等键请求头，与 server.IdempotencyHeader 对应
+const idempotencyHeader = "Idempotency-Key"
+
+// ExecRequest 表示执行命令的请求
+type ExecRequest struct {
+	Command string              `json:"command"`
+	Args    []string            `json:"args,omitempty"`
+	WorkDir string              `json:"workdir,omitempty"`
+	Env     map[string]string   `json:"env,omitempty"` // 在会话中执行时使用会话的环境变量
+	Track   *types.TrackOptions `json:"track,omitempty"`
+	Timeout time.Duration       `json:"timeout,omitempty"` // 服务端执行的超时时间
+
+	// SessionID 不为空时在该会话中执行
+	SessionID string `json:"-"`
+
+	// IdempotencyKey 不为空时作为幂等键发送，请求失败后重试不会重复执行命令，
+	// 服务端已经执行过相同键的请求时返回第一次执行的结果
+	IdempotencyKey string `json:"-"`
+}
+
+// ExecResponse 表示命令的执行结果
+type ExecResponse struct {
+	ExitCode int                `json:"exit_code"`
+	Output   string             `json:"output"`
+	Error    string             `json:"error,omitempty"`
+	Changes  []types.FileChange `json:"changes,omitempty"`
+	Data     interface{}        `json:"data,omitempty"`
+
+	// Attempts 命令在服务端被重试时每次尝试的结果
+	Attempts []types.ExecuteAttempt `json:"attempts,omitempty"`
+
+	// Cache 结果缓存状态，hit 或 miss，命令不可缓存时为空
+	Cache string `json:"cache,omitempty"`
+
+	// SnapshotID 破坏性命令执行前自动创建的快照 ID
+	SnapshotID string `json:"-"`
+}
+
+// StreamEvent 表示流式执行中的一个事件，与 server.ExecStreamEvent 对应
+type StreamEvent struct {
+	Stdout     string        `json:"stdout,omitempty"`
+	Stderr     string        `json:"stderr,omitempty"`
+	Result     *ExecResponse `json:"result,omitempty"`
+	SnapshotID string        `json:"snapshot_id,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// execPath 返回执行请求的路径
+func execPath(req *ExecRequest) string {
+	if req.SessionID != "" {
+		return "/sessions/" + url.PathEscape(req.SessionID) + "/exec"
+	}
+	return "/exec"
+}
+
+// Exec 执行命令并等待结束，返回完整的输出。
+// 命令以非零状态退出不视为错误，通过 ExitCode 和 Error 返回。
+func (c *Client) Exec(ctx context.Context, req *ExecRequest) (*ExecResponse, error) {
+	stream, err := c.ExecStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var output bytes.Buffer
+	result, err := stream.CopyTo(&output, &output)
+	if err != nil {
+		return nil, err
+	}
+	if result.Output == "" {
+		result.Output = output.String()
+	}
+	return result, nil
+}
+
+// ExecStream 执行命令并以流的形式返回输出，调用方需要关闭返回的流
+func (c *Client) ExecStream(ctx context.Context, req *ExecRequest) (*ExecStream, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	var header http.Header
+	if req.IdempotencyKey != "" {
+		header = http.Header{idempotencyHeader: {req.IdempotencyKey}}
+	}
+	resp, err := c.doWithHeader(ctx, http.MethodPost, execPath(req), url.Values{"stream": {"true"}}, bytes.NewReader(data), "application/json", header)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &ExecStream{body: resp.Body, snapshotID: resp.Header.Get(snapshotHeader)}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == streamContentType {
+		stream.dec = json.NewDecoder(resp.Body)
+		return stream, nil
+	}
+
+	// 服务端不支持流式返回时，整个响应即为执行结果
+	defer resp.Body.Close()
+	var result ExecResponse
+	if req.SessionID != "" {
+		// 会话执行接口返回 types.ExecuteResult 的字段
+		var session sessionExecResult
+		if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		result = ExecResponse{
+			ExitCode: session.ExitCode,
+			Output:   session.Output,
+			Changes:  session.Changes,
+			Data:     session.Data,
+			Attempts: session.Attempts,
+			Cache:    session.CacheStatus,
+		}
+	} else if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	stream.pending = []*StreamEvent{{Result: &result}}
+	return stream, nil
+}
+
+// sessionExecResult 是会话执行接口非流式返回的结果，字段名与 types.ExecuteResult 一致
+type sessionExecResult struct {
+	ExitCode    int
+	Output      string
+	Changes     []types.FileChange
+	Data        interface{}
+	Attempts    []types.ExecuteAttempt
+	CacheStatus string
+}
+
+// ExecStream 表示一次流式执行的输出。
+// 可以通过 Recv 逐个读取事件，也可以作为 io.Reader 读取合并后的标准输出和标准错误；
+// 读到 io.EOF 后通过 Result 获取执行结果。
+type ExecStream struct {
+	body       io.ReadCloser
+	dec        *json.Decoder
+	pending    []*StreamEvent
+	buf        []byte
+	result     *ExecResponse
+	snapshotID string
+}
+
+// Recv 返回下一个事件，包含结果的事件之后返回 io.EOF
+func (s *ExecStream) Recv() (*StreamEvent, error) {
+	if s.result != nil {
+		return nil, io.EOF
+	}
+
+	var event *StreamEvent
+	if len(s.pending) > 0 {
+		event, s.pending = s.pending[0], s.pending[1:]
+	} else {
+		if s.dec == nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		event = &StreamEvent{}
+		if err := s.dec.Decode(event); err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("failed to decode stream event: %w", err)
+		}
+	}
+
+	if event.SnapshotID != "" {
+		s.snapshotID = event.SnapshotID
+	}
+	if event.Error != "" && event.Result == nil {
+		return nil, &APIError{StatusCode: http.StatusInternalServerError, Message: event.Error}
+	}
+	if event.Result != nil {
+		s.result = event.Result
+		s.result.SnapshotID = s.snapshotID
+	}
+	return event, nil
+}
+
+// Read 实现 io.Reader，读取合并后的标准输出和标准错误
+func (s *ExecStream) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		event, err := s.Recv()
+		if err != nil {
+			return 0, err
+		}
+		s.buf = append(s.buf, event.Stdout...)
+		s.buf = append(s.buf, event.Stderr...)
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// CopyTo 将输出分别写入 stdout 和 stderr 直到命令结束，返回执行结果；writer 为 nil 时丢弃对应输出
+func (s *ExecStream) CopyTo(stdout, stderr io.Writer) (*ExecResponse, error) {
+	for {
+		event, err := s.Recv()
+		if err == io.EOF {
+			return s.result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if event.Stdout != "" && stdout != nil {
+			if _, err := io.WriteString(stdout, event.Stdout); err != nil {
+				return nil, err
+			}
+		}
+		if event.Stderr != "" && stderr != nil {
+			if _, err := io.WriteString(stderr, event.Stderr); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Result 返回执行结果，命令尚未结束时返回 nil
+func (s *ExecStream) Result() *ExecResponse {
+	return s.result
+}
+
+// Close 关闭流，命令未结束时服务端会取消执行
+func (s *ExecStream) Close() error {
+	return s.body.Close()
+}
+
+// exitError 表示命令以非零状态退出
+func (r *ExecResponse) exitError() error {
+	if r.Error != "" {
+		return fmt.Errorf("%s", r.Error)
+	}
+	if r.ExitCode != 0 {
+		return fmt.Errorf("exit status %d", r.ExitCode)
+	}
+	return nil
+}
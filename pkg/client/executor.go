@@ -0,0 +1,232 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"al.essio.dev/pkg/shellescape"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// ExecutorName 作为执行器使用时的名称
+const ExecutorName = "remote"
+
+// listCommandsTimeout ListCommands 请求的超时时间
+const listCommandsTimeout = 10 * time.Second
+
+var _ types.Executor = (*Client)(nil)
+
+// WithSession 返回绑定到会话的客户端副本，作为执行器使用时命令在该会话中执行
+func (c *Client) WithSession(sessionID string) *Client {
+	clone := *c
+	clone.header = c.header.Clone()
+	clone.sessionID = sessionID
+	return &clone
+}
+
+// SessionID 返回绑定的会话 ID
+func (c *Client) SessionID() string {
+	return c.sessionID
+}
+
+// Name 实现 types.Executor 接口
+func (c *Client) Name() string {
+	return ExecutorName
+}
+
+// Execute 实现 types.Executor 接口，在服务端执行命令。
+// 输出写入 Options 中的 Stdout 和 Stderr，命令以非零状态退出时同时返回结果和错误；
+// 非交互式命令没有标准输入。
+func (c *Client) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if ctx.Options == nil {
+		ctx.Options = &types.ExecuteOptions{}
+	}
+	if ctx.Context == nil {
+		ctx.Context = context.Background()
+	}
+	if ctx.Interactive {
+		return c.executeInteractive(ctx)
+	}
+	// HTTP API 不支持向非交互式命令传递标准输入
+	if ctx.Options.Stdin != nil {
+		log.Debug("Remote executor ignores stdin for non-interactive command %s", ctx.Command.Command)
+	}
+
+	cmd := ctx.Command
+	if ctx.IsPiped {
+		if ctx.PipeContext == nil || len(ctx.PipeContext.Commands) == 0 {
+			return nil, fmt.Errorf("no commands in pipeline")
+		}
+		// 管道在服务端通过 bash -c 执行
+		parts := make([]string, 0, len(ctx.PipeContext.Commands))
+		for _, c := range ctx.PipeContext.Commands {
+			parts = append(parts, quoteCommand(c))
+		}
+		cmd = types.Command{Command: "bash", Args: []string{"-c", strings.Join(parts, " | ")}}
+	}
+
+	startTime := types.GetTimeNow()
+	stream, err := c.ExecStream(ctx.Context, &ExecRequest{
+		Command:   cmd.Command,
+		Args:      cmd.Args,
+		WorkDir:   ctx.Options.WorkDir,
+		Env:       ctx.Options.Env,
+		Track:     ctx.Options.Track,
+		Timeout:   time.Duration(ctx.Options.Timeout),
+		SessionID: c.sessionID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var stdoutBuf, stderrBuf strings.Builder
+	stdout, stderr := io.Writer(&stdoutBuf), io.Writer(&stderrBuf)
+	if ctx.Options.Stdout != nil {
+		stdout = io.MultiWriter(&stdoutBuf, ctx.Options.Stdout)
+	}
+	if ctx.Options.Stderr != nil {
+		stderr = io.MultiWriter(&stderrBuf, ctx.Options.Stderr)
+	}
+	resp, err := stream.CopyTo(stdout, stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.ExecuteResult{
+		CommandName: ctx.Command.Command,
+		ExitCode:    resp.ExitCode,
+		StartTime:   startTime,
+		EndTime:     types.GetTimeNow(),
+		Output:      resp.Output,
+		Changes:     resp.Changes,
+		Data:        resp.Data,
+		Attempts:    resp.Attempts,
+		CacheStatus: resp.Cache,
+	}
+	// 与本地执行器一致，合并标准输出和标准错误
+	if result.Output == "" {
+		result.Output = stdoutBuf.String()
+		if stderrBuf.Len() > 0 {
+			if result.Output != "" {
+				result.Output += "\n"
+			}
+			result.Output += stderrBuf.String()
+		}
+	}
+	if err := resp.exitError(); err != nil {
+		result.Error = err
+		return result, err
+	}
+	return result, nil
+}
+
+// ExecuteCommand 实现 types.Executor 接口
+func (c *Client) ExecuteCommand(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	return c.Execute(ctx)
+}
+
+// executeInteractive 通过交互式终端执行命令
+func (c *Client) executeInteractive(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	req := &TerminalRequest{
+		Command: ctx.Command.Command,
+		Args:    ctx.Command.Args,
+		WorkDir: ctx.Options.WorkDir,
+	}
+	opts := ctx.InteractiveOpts
+	if opts != nil {
+		req.Rows, req.Cols = opts.Rows, opts.Cols
+	}
+
+	terminal, err := c.Terminal(ctx.Context, req)
+	if err != nil {
+		return nil, err
+	}
+	defer terminal.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	if ctx.Options.Stdin != nil {
+		go func() {
+			if _, err := io.Copy(terminal, ctx.Options.Stdin); err != nil {
+				log.Debug("Failed to copy stdin to remote terminal: %v", err)
+			}
+		}()
+	}
+	if opts != nil && opts.Resize != nil {
+		go func() {
+			for {
+				select {
+				case size, ok := <-opts.Resize:
+					if !ok {
+						return
+					}
+					if err := terminal.Resize(size.Rows, size.Cols); err != nil {
+						log.Debug("Failed to resize remote terminal: %v", err)
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	startTime := types.GetTimeNow()
+	stdout := ctx.Options.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if _, err := io.Copy(stdout, terminal); err != nil {
+		return nil, err
+	}
+
+	result := &types.ExecuteResult{
+		CommandName: ctx.Command.Command,
+		StartTime:   startTime,
+		EndTime:     types.GetTimeNow(),
+	}
+	resp := terminal.Result()
+	if resp == nil {
+		result.ExitCode = 1
+		result.Error = fmt.Errorf("terminal closed without a result")
+		return result, result.Error
+	}
+	result.ExitCode = resp.ExitCode
+	result.Output = resp.Output
+	if err := resp.exitError(); err != nil {
+		result.Error = err
+		return result, err
+	}
+	return result, nil
+}
+
+// ListCommands 实现 types.Executor 接口，请求失败时返回空列表
+func (c *Client) ListCommands() []types.CommandInfo {
+	ctx, cancel := context.WithTimeout(context.Background(), listCommandsTimeout)
+	defer cancel()
+	commands, err := c.Commands(ctx)
+	if err != nil {
+		log.Error("Failed to list remote commands: %v", err)
+		return nil
+	}
+	return commands
+}
+
+// Close 实现 types.Executor 接口，关闭空闲连接
+func (c *Client) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// quoteCommand 将命令转换为 shell 命令行中的一段
+func quoteCommand(cmd *types.Command) string {
+	parts := []string{shellescape.Quote(cmd.Command)}
+	for _, arg := range cmd.Args {
+		parts = append(parts, shellescape.Quote(arg))
+	}
+	return strings.Join(parts, " ")
+}
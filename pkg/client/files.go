@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// UploadOptions 表示上传文件的选项
+type UploadOptions struct {
+	Mode    os.FileMode // 新建文件的权限，为 0 时使用服务端默认值 0644
+	Parents bool        // 是否创建不存在的上级目录
+}
+
+// filesPath 返回会话文件接口的路径
+func filesPath(sessionID string) string {
+	return "/sessions/" + url.PathEscape(sessionID) + "/files"
+}
+
+// Download 下载会话工作目录中的文件，调用方需要关闭返回的 io.ReadCloser
+func (c *Client) Download(ctx context.Context, sessionID, path string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, filesPath(sessionID), url.Values{"path": {path}}, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	return resp.Body, nil
+}
+
+// ReadFile 读取会话工作目录中的文件
+func (c *Client) ReadFile(ctx context.Context, sessionID, path string) ([]byte, error) {
+	body, err := c.Download(ctx, sessionID, path)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// ReadDir 列出会话工作目录中的目录条目
+func (c *Client) ReadDir(ctx context.Context, sessionID, path string) ([]types.FileInfo, error) {
+	resp, err := c.do(ctx, http.MethodGet, filesPath(sessionID), url.Values{"path": {path}}, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+	var entries []types.FileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return entries, nil
+}
+
+// Upload 将 r 的内容上传为会话工作目录中的文件，已存在的文件会被覆盖。
+// 只有 r 为 *bytes.Reader、*bytes.Buffer 或 *strings.Reader 时请求失败才会重试。
+func (c *Client) Upload(ctx context.Context, sessionID, path string, r io.Reader, opts *UploadOptions) error {
+	query := url.Values{"path": {path}}
+	if opts != nil {
+		if opts.Mode != 0 {
+			query.Set("mode", strconv.FormatUint(uint64(opts.Mode.Perm()), 8))
+		}
+		if opts.Parents {
+			query.Set("parents", "true")
+		}
+	}
+	resp, err := c.do(ctx, http.MethodPut, filesPath(sessionID), query, r, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// WriteFile 写入会话工作目录中的文件，同时创建不存在的上级目录
+func (c *Client) WriteFile(ctx context.Context, sessionID, path string, data []byte, perm os.FileMode) error {
+	return c.Upload(ctx, sessionID, path, bytes.NewReader(data), &UploadOptions{Mode: perm, Parents: true})
+}
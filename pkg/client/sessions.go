@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// CreateSession 创建会话
+func (c *Client) CreateSession(ctx context.Context, req *types.SessionRequest) (*types.Session, error) {
+	if req == nil {
+		req = &types.SessionRequest{}
+	}
+	if req.Options == nil {
+		req.Options = &types.ExecuteOptions{}
+	}
+	var resp types.SessionResponse
+	if _, err := c.doJSON(ctx, http.MethodPost, "/sessions", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Session, nil
+}
+
+// ListSessions 列出所有会话
+func (c *Client) ListSessions(ctx context.Context) ([]*types.Session, error) {
+	var sessions []*types.Session
+	if _, err := c.doJSON(ctx, http.MethodGet, "/sessions", nil, nil, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// DeleteSession 删除会话
+func (c *Client) DeleteSession(ctx context.Context, id string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, "/sessions/"+url.PathEscape(id), nil, nil, nil)
+	return err
+}
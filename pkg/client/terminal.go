@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// TerminalRequest 表示打开交互式终端的请求
+type TerminalRequest struct {
+	Command string   // 要执行的命令，为空时服务端运行 bash
+	Args    []string // 命令参数
+	WorkDir string   // 工作目录
+	Rows    uint16   // 终端行数，为 0 时使用服务端默认值
+	Cols    uint16   // 终端列数，为 0 时使用服务端默认值
+}
+
+// Terminal 表示通过 WebSocket 连接的交互式终端。
+// Read 读取终端输出，命令结束后返回 io.EOF，之后可以通过 Result 获取执行结果；
+// Write 写入终端输入，服务端会为不以换行结尾的输入补充换行。
+type Terminal struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	buf     []byte
+	result  *ExecResponse
+	stop    func() bool
+}
+
+// Terminal 打开交互式终端，ctx 取消时关闭连接
+func (c *Client) Terminal(ctx context.Context, req *TerminalRequest) (*Terminal, error) {
+	if req == nil {
+		req = &TerminalRequest{}
+	}
+	query := url.Values{}
+	if req.Command != "" {
+		query.Set("command", req.Command)
+	}
+	for _, arg := range req.Args {
+		query.Add("args", arg)
+	}
+	if req.WorkDir != "" {
+		query.Set("workdir", req.WorkDir)
+	}
+	if req.Rows > 0 {
+		query.Set("rows", strconv.Itoa(int(req.Rows)))
+	}
+	if req.Cols > 0 {
+		query.Set("cols", strconv.Itoa(int(req.Cols)))
+	}
+
+	u, err := url.Parse(c.endpoint("/exec/interactive", query))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+
+	dialer := *websocket.DefaultDialer
+	if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = transport.TLSClientConfig
+		dialer.Proxy = transport.Proxy
+	}
+	conn, resp, err := dialer.DialContext(ctx, u.String(), c.header.Clone())
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				return nil, decodeError(resp)
+			}
+		}
+		return nil, fmt.Errorf("failed to open terminal: %w", err)
+	}
+
+	t := &Terminal{conn: conn}
+	t.stop = context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	return t, nil
+}
+
+// Read 实现 io.Reader，读取终端输出
+func (t *Terminal) Read(p []byte) (int, error) {
+	for len(t.buf) == 0 {
+		if t.result != nil {
+			return 0, io.EOF
+		}
+		messageType, data, err := t.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		if messageType == websocket.TextMessage {
+			// 文本消息为执行结果或错误
+			if err := t.handleResult(data); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		t.buf = data
+	}
+	n := copy(p, t.buf)
+	t.buf = t.buf[n:]
+	return n, nil
+}
+
+// handleResult 解析服务端发送的执行结果
+func (t *Terminal) handleResult(data []byte) error {
+	var msg struct {
+		ExitCode *int   `json:"exit_code"`
+		Output   string `json:"output"`
+		Error    string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("invalid terminal message: %w", err)
+	}
+	if msg.ExitCode == nil {
+		return &APIError{StatusCode: http.StatusInternalServerError, Message: msg.Error}
+	}
+	t.result = &ExecResponse{ExitCode: *msg.ExitCode, Output: msg.Output, Error: msg.Error}
+	return nil
+}
+
+// Write 实现 io.Writer，写入终端输入
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if err := t.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Resize 调整终端大小
+func (t *Terminal) Resize(rows, cols uint16) error {
+	payload, err := json.Marshal(map[string]uint16{"rows": rows, "cols": cols})
+	if err != nil {
+		return err
+	}
+	msg, err := json.Marshal(map[string]interface{}{"type": "resize", "payload": json.RawMessage(payload)})
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	return t.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// Result 返回执行结果，命令尚未结束时返回 nil
+func (t *Terminal) Result() *ExecResponse {
+	return t.result
+}
+
+// Close 关闭终端连接
+func (t *Terminal) Close() error {
+	t.stop()
+	return t.conn.Close()
+}
@@ -16,6 +16,7 @@ func (c *GitCommand) Info() types.CommandInfo {
 		Description: "Git version control",
 		Usage:       "git [options] <command> [args]",
 		Category:    "vcs",
+		Schema:      systemArgs("args", "Git subcommand and arguments", false),
 	}
 }
 
@@ -40,6 +41,7 @@ func (c *GoCommand) Info() types.CommandInfo {
 		Description: "Go language tools",
 		Usage:       "go <command> [args]",
 		Category:    "language",
+		Schema:      systemArgs("args", "Go subcommand and arguments", false),
 	}
 }
 
@@ -64,6 +66,7 @@ func (c *PythonCommand) Info() types.CommandInfo {
 		Description: "Run Python interpreter",
 		Usage:       "python [options] [script] [args]",
 		Category:    "language",
+		Schema:      systemArgs("args", "Script and arguments", false),
 	}
 }
 
@@ -90,6 +93,7 @@ func (c *PipCommand) Info() types.CommandInfo {
 		Description: "Python package installer",
 		Usage:       "pip [options] <command> [args]",
 		Category:    "package",
+		Schema:      systemArgs("args", "Pip subcommand and arguments", false),
 	}
 }
 
@@ -115,6 +119,7 @@ func (c *DockerCommand) Info() types.CommandInfo {
 		Description: "Docker container operations",
 		Usage:       "docker [options] <command> [args]",
 		Category:    "container",
+		Schema:      systemArgs("args", "Docker subcommand and arguments", false),
 	}
 }
 
@@ -141,6 +146,7 @@ func (c *NodeCommand) Info() types.CommandInfo {
 		Description: "Run Node.js interpreter",
 		Usage:       "node [options] [script] [args]",
 		Category:    "language",
+		Schema:      systemArgs("args", "Script and arguments", false),
 	}
 }
 
@@ -167,6 +173,7 @@ func (c *NPMCommand) Info() types.CommandInfo {
 		Description: "Node.js package manager",
 		Usage:       "npm [options] <command> [args]",
 		Category:    "package",
+		Schema:      systemArgs("args", "Npm subcommand and arguments", false),
 	}
 }
 
@@ -0,0 +1,566 @@
+// Package commands 实现了 RunShell 的内置命令。
+// 本文件实现了结构化的文件修改命令：write、edit 和 patch。
+package commands
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/iamlongalong/runshell/pkg/diff"
+	"github.com/iamlongalong/runshell/pkg/fs"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// DefaultPatchFuzz patch 命令默认允许忽略的上下文行数，与 GNU patch 一致
+const DefaultPatchFuzz = 2
+
+// FileEditResult 表示 write 和 edit 命令的结果
+// swagger:model
+type FileEditResult struct {
+	Path         string       `json:"path"`                   // 文件路径
+	Created      bool         `json:"created"`                // 是否新建了文件
+	Replacements int          `json:"replacements,omitempty"` // edit 命令替换的次数
+	LinesAdded   int          `json:"lines_added"`            // 新增的行数
+	LinesRemoved int          `json:"lines_removed"`          // 删除的行数
+	Hunks        []*diff.Hunk `json:"hunks,omitempty"`        // 变更的行范围
+	Diff         string       `json:"diff,omitempty"`         // unified diff
+}
+
+// PatchFileResult 表示 patch 命令对一个文件的处理结果
+// swagger:model
+type PatchFileResult struct {
+	Path         string            `json:"path"`            // 文件路径
+	Created      bool              `json:"created"`         // 是否新建了文件
+	Deleted      bool              `json:"deleted"`         // 是否删除了文件
+	LinesAdded   int               `json:"lines_added"`     // 新增的行数
+	LinesRemoved int               `json:"lines_removed"`   // 删除的行数
+	Hunks        []diff.HunkResult `json:"hunks"`           // 每个变更块的应用结果
+	Error        string            `json:"error,omitempty"` // 文件级错误
+}
+
+// PatchResult 表示 patch 命令的结果
+// swagger:model
+type PatchResult struct {
+	Files    []PatchFileResult `json:"files"`    // 每个文件的处理结果
+	Rejected int               `json:"rejected"` // 被拒绝的变更块数量
+	DryRun   bool              `json:"dry_run"`  // 是否仅检查而不写入
+}
+
+// parseOptions 解析位于操作数之前的选项，第一个操作数或 "--" 之后的参数都视为操作数，
+// 以便写入内容和替换文本可以以 "-" 开头。valueOpts 中的选项需要参数值。
+func parseOptions(args []string, boolOpts, valueOpts []string) (map[string]string, []string, error) {
+	opts := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return opts, args[i+1:], nil
+		}
+		// 多行参数（如补丁内容）即使以 "-" 开头也视为操作数
+		if len(arg) < 2 || arg[0] != '-' || strings.Contains(arg, "\n") {
+			return opts, args[i:], nil
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		// 兼容 -p1 形式的短选项参数值
+		if !hasValue && len(arg) > 2 && arg[1] != '-' && contains(valueOpts, arg[:2]) {
+			name, value, hasValue = arg[:2], arg[2:], true
+		}
+		switch {
+		case contains(boolOpts, arg):
+			opts[arg] = "true"
+		case contains(valueOpts, name):
+			if !hasValue {
+				if i+1 >= len(args) {
+					return nil, nil, fmt.Errorf("option %s requires a value", name)
+				}
+				i++
+				value = args[i]
+			}
+			opts[name] = value
+		default:
+			return nil, nil, fmt.Errorf("unknown option: %s", arg)
+		}
+	}
+	return opts, nil, nil
+}
+
+// contains 检查切片是否包含指定元素
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// requireFileSystem 获取文件系统，文件修改命令没有对应的系统命令可以退回
+func requireFileSystem(ctx *types.ExecuteContext, name string) (types.FileSystem, error) {
+	fsys, ok, err := fileSystem(ctx)
+	if !ok {
+		return nil, fmt.Errorf("%s: executor %s does not support filesystem access", name, ctx.Executor.Name())
+	}
+	return fsys, err
+}
+
+// readExisting 读取文件内容，文件不存在时返回 false
+func readExisting(ctx *types.ExecuteContext, fsys types.FileSystem, path string) (string, bool, error) {
+	data, err := fsys.ReadFile(ctx.Context, path)
+	if err != nil {
+		if fs.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+// newEditResult 对比修改前后的内容，生成修改结果
+func newEditResult(path, oldText, newText string, created bool) FileEditResult {
+	hunks := diff.Compute(oldText, newText, 0)
+	added, removed := diff.Count(hunks)
+	oldName := "a/" + path
+	if created {
+		oldName = diff.DevNull
+	}
+	return FileEditResult{
+		Path:         path,
+		Created:      created,
+		LinesAdded:   added,
+		LinesRemoved: removed,
+		Hunks:        hunks,
+		Diff:         diff.Unified(oldName, "b/"+path, oldText, newText, diff.DefaultContext),
+	}
+}
+
+// formatEditResult 生成修改结果的文本表示
+func formatEditResult(action string, result FileEditResult) string {
+	out := fmt.Sprintf("%s %s (+%d -%d)\n", action, result.Path, result.LinesAdded, result.LinesRemoved)
+	return out + result.Diff
+}
+
+// WriteCommand 实现了 write 命令。
+// 用于原子地创建或覆盖文件，内容来自参数或标准输入。
+type WriteCommand struct{}
+
+func (c *WriteCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "write",
+		Description: "Atomically create or overwrite a file",
+		Usage:       "write [-p] file [content]",
+		Category:    "file",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "parents", Flag: "-p", Type: types.ArgTypeBool, Description: "Create parent directories as needed"},
+				{Name: "file", Type: types.ArgTypePath, Required: true, Description: "File to write"},
+				{Name: "content", Type: types.ArgTypeString, Description: "File content, read from stdin when omitted"},
+			},
+			OptionsFirst: true,
+		},
+	}
+}
+
+// Execute 执行 write 命令。
+func (c *WriteCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	opts, operands, err := parseOptions(ctx.Command.Args, []string{"-p"}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(operands) < 1 {
+		return nil, fmt.Errorf("no file specified")
+	}
+	if len(operands) > 2 {
+		return nil, fmt.Errorf("too many arguments, quote the content as a single argument")
+	}
+	path := operands[0]
+
+	// 内容优先取自参数，否则读取标准输入
+	var content string
+	if len(operands) == 2 {
+		content = operands[1]
+	} else if ctx.Options != nil && ctx.Options.Stdin != nil {
+		data, err := io.ReadAll(ctx.Options.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %v", err)
+		}
+		content = string(data)
+	}
+
+	fsys, err := requireFileSystem(ctx, "write")
+	if err != nil {
+		return fsError(ctx, "write", err)
+	}
+
+	oldText, exists, err := readExisting(ctx, fsys, path)
+	if err != nil {
+		return fsError(ctx, "write", err)
+	}
+	if !exists && opts["-p"] != "" {
+		if dir := parentDir(path); dir != "" {
+			if err := fsys.Mkdir(ctx.Context, dir, 0755, true); err != nil {
+				return fsError(ctx, "write", err)
+			}
+		}
+	}
+	if err := fs.WriteFileAtomic(ctx.Context, fsys, path, []byte(content), 0644); err != nil {
+		return fsError(ctx, "write", err)
+	}
+
+	result := newEditResult(path, oldText, content, !exists)
+	return fsResult(ctx, "write", formatEditResult("wrote", result), result), nil
+}
+
+// parentDir 返回路径的上级目录，没有上级目录时返回空字符串
+func parentDir(path string) string {
+	idx := strings.LastIndex(path, "/")
+	if idx <= 0 {
+		return ""
+	}
+	return path[:idx]
+}
+
+// EditCommand 实现了 edit 命令。
+// 用于按精确字符串或正则表达式替换文件内容，并校验匹配次数。
+type EditCommand struct{}
+
+func (c *EditCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "edit",
+		Description: "Replace text in a file with an expected match count",
+		Usage:       "edit [--regex] [--all | --count N] file old new",
+		Category:    "file",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "regex", Flag: "--regex", Type: types.ArgTypeBool, Description: "Treat old as a regular expression"},
+				{Name: "all", Flag: "--all", Type: types.ArgTypeBool, Description: "Replace every match"},
+				{Name: "count", Flag: "--count", Type: types.ArgTypeInt, Default: "1", Description: "Expected number of matches"},
+				{Name: "file", Type: types.ArgTypePath, Required: true, Description: "File to edit"},
+				{Name: "old", Type: types.ArgTypeString, Required: true, Description: "Text to replace"},
+				{Name: "new", Type: types.ArgTypeString, Required: true, Description: "Replacement text"},
+			},
+			OptionsFirst: true,
+		},
+	}
+}
+
+// Execute 执行 edit 命令。
+func (c *EditCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	opts, operands, err := parseOptions(ctx.Command.Args, []string{"--regex", "--all"}, []string{"--count"})
+	if err != nil {
+		return nil, err
+	}
+	if len(operands) != 3 {
+		return nil, fmt.Errorf("edit requires file, old and new arguments")
+	}
+	path, old, replacement := operands[0], operands[1], operands[2]
+	if old == "" {
+		return nil, fmt.Errorf("old text must not be empty")
+	}
+
+	// 默认要求恰好匹配一次，避免误改
+	expected := 1
+	if v, ok := opts["--count"]; ok {
+		if opts["--all"] != "" {
+			return nil, fmt.Errorf("--count cannot be combined with --all")
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid count: %s", v)
+		}
+		expected = n
+	}
+	if opts["--all"] != "" {
+		expected = 0
+	}
+
+	var re *regexp.Regexp
+	if opts["--regex"] != "" {
+		re, err = regexp.Compile(old)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %v", err)
+		}
+	}
+
+	fsys, err := requireFileSystem(ctx, "edit")
+	if err != nil {
+		return fsError(ctx, "edit", err)
+	}
+
+	data, err := fsys.ReadFile(ctx.Context, path)
+	if err != nil {
+		return fsError(ctx, "edit", err)
+	}
+	oldText := string(data)
+
+	var matches int
+	var newText string
+	if re != nil {
+		matches = len(re.FindAllStringIndex(oldText, -1))
+		newText = re.ReplaceAllString(oldText, replacement)
+	} else {
+		matches = strings.Count(oldText, old)
+		newText = strings.ReplaceAll(oldText, old, replacement)
+	}
+
+	if matches == 0 {
+		return fsError(ctx, "edit", fmt.Errorf("no match found in %s", path))
+	}
+	if expected > 0 && matches != expected {
+		return fsError(ctx, "edit", fmt.Errorf("expected %d match(es) in %s, found %d", expected, path, matches))
+	}
+
+	if newText != oldText {
+		if err := fs.WriteFileAtomic(ctx.Context, fsys, path, []byte(newText), 0644); err != nil {
+			return fsError(ctx, "edit", err)
+		}
+	}
+
+	result := newEditResult(path, oldText, newText, false)
+	result.Replacements = matches
+	return fsResult(ctx, "edit", formatEditResult(fmt.Sprintf("replaced %d occurrence(s) in", matches), result), result), nil
+}
+
+// PatchCommand 实现了 patch 命令。
+// 用于应用 unified diff 格式的补丁，支持模糊匹配，并逐个报告被拒绝的变更块。
+type PatchCommand struct{}
+
+func (c *PatchCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "patch",
+		Description: "Apply a unified diff",
+		Usage:       "patch [-p NUM] [--fuzz NUM] [--dry-run] [-i patchfile | patch]",
+		Category:    "file",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "strip", Flag: "-p", Type: types.ArgTypeInt, Default: "1", Description: "Strip NUM leading path components"},
+				{Name: "fuzz", Flag: "--fuzz", Type: types.ArgTypeInt, Default: strconv.Itoa(DefaultPatchFuzz), Description: "Context lines that may be ignored"},
+				{Name: "dry_run", Flag: "--dry-run", Type: types.ArgTypeBool, Description: "Check without writing files"},
+				{Name: "input", Flag: "-i", Type: types.ArgTypePath, Description: "Read the patch from a file"},
+				{Name: "patch", Type: types.ArgTypeString, Description: "Patch content, read from stdin when omitted"},
+			},
+			OptionsFirst: true,
+		},
+	}
+}
+
+// Execute 执行 patch 命令。
+// 部分变更块被拒绝时，已成功的变更块仍会写入，结果的退出码为 1 且不返回错误，
+// 以便调用方获取每个变更块的处理结果。
+func (c *PatchCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	opts, operands, err := parseOptions(ctx.Command.Args, []string{"--dry-run"}, []string{"-p", "--fuzz", "-i"})
+	if err != nil {
+		return nil, err
+	}
+	if len(operands) > 1 {
+		return nil, fmt.Errorf("too many arguments")
+	}
+
+	strip, fuzz := 1, DefaultPatchFuzz
+	if v, ok := opts["-p"]; ok {
+		if strip, err = strconv.Atoi(v); err != nil || strip < 0 {
+			return nil, fmt.Errorf("invalid strip count: %s", v)
+		}
+	}
+	if v, ok := opts["--fuzz"]; ok {
+		if fuzz, err = strconv.Atoi(v); err != nil || fuzz < 0 {
+			return nil, fmt.Errorf("invalid fuzz: %s", v)
+		}
+	}
+	dryRun := opts["--dry-run"] != ""
+
+	fsys, err := requireFileSystem(ctx, "patch")
+	if err != nil {
+		return fsError(ctx, "patch", err)
+	}
+
+	// 补丁内容可以来自 -i 指定的文件、参数或标准输入
+	var patch string
+	switch {
+	case opts["-i"] != "":
+		data, err := fsys.ReadFile(ctx.Context, opts["-i"])
+		if err != nil {
+			return fsError(ctx, "patch", err)
+		}
+		patch = string(data)
+	case len(operands) == 1:
+		patch = operands[0]
+	case ctx.Options != nil && ctx.Options.Stdin != nil:
+		data, err := io.ReadAll(ctx.Options.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %v", err)
+		}
+		patch = string(data)
+	default:
+		return nil, fmt.Errorf("no patch specified")
+	}
+
+	files, err := diff.Parse(patch)
+	if err != nil {
+		return fsError(ctx, "patch", err)
+	}
+
+	result := PatchResult{DryRun: dryRun}
+	var out strings.Builder
+	for _, fileDiff := range files {
+		fileResult := applyFileDiff(ctx, fsys, fileDiff, strip, fuzz, dryRun)
+		result.Files = append(result.Files, fileResult)
+		result.Rejected += writePatchReport(&out, fileResult, dryRun)
+	}
+
+	res := fsResult(ctx, "patch", out.String(), result)
+	if result.Rejected > 0 {
+		res.ExitCode = 1
+		res.Error = fmt.Errorf("%d hunk(s) rejected", result.Rejected)
+	}
+	return res, nil
+}
+
+// stripPath 去掉路径开头的 n 个组成部分
+func stripPath(name string, n int) (string, error) {
+	parts := strings.Split(name, "/")
+	if n >= len(parts) {
+		return "", fmt.Errorf("cannot strip %d components from %s", n, name)
+	}
+	return strings.Join(parts[n:], "/"), nil
+}
+
+// applyFileDiff 将补丁应用到单个文件
+func applyFileDiff(ctx *types.ExecuteContext, fsys types.FileSystem, fileDiff *diff.FileDiff, strip, fuzz int, dryRun bool) PatchFileResult {
+	var result PatchFileResult
+
+	// 拒绝所有变更块并记录文件级错误
+	reject := func(err error) PatchFileResult {
+		result.Error = err.Error()
+		result.Hunks = nil
+		for i, h := range fileDiff.Hunks {
+			text := diff.Format("a", "b", []*diff.Hunk{h})
+			result.Hunks = append(result.Hunks, diff.HunkResult{Index: i + 1, Reject: text[strings.Index(text, "@@"):]})
+		}
+		return result
+	}
+
+	var source, target string
+	var err error
+	if !fileDiff.IsNew() {
+		if source, err = stripPath(fileDiff.OldName, strip); err != nil {
+			return reject(err)
+		}
+	}
+	if !fileDiff.IsDelete() {
+		if target, err = stripPath(fileDiff.NewName, strip); err != nil {
+			return reject(err)
+		}
+	}
+	result.Path = target
+	if target == "" {
+		result.Path = source
+	}
+
+	oldText := ""
+	if fileDiff.IsNew() {
+		if _, exists, err := readExisting(ctx, fsys, target); err != nil {
+			return reject(err)
+		} else if exists {
+			return reject(fmt.Errorf("%s already exists", target))
+		}
+		result.Created = true
+	} else {
+		data, err := fsys.ReadFile(ctx.Context, source)
+		if err != nil {
+			return reject(err)
+		}
+		oldText = string(data)
+	}
+
+	newText, hunks := diff.Apply(oldText, fileDiff.Hunks, fuzz)
+	result.Hunks = hunks
+	applied := 0
+	for _, h := range hunks {
+		if h.Applied {
+			applied++
+		}
+	}
+	if applied == 0 {
+		result.Created = false
+		return result
+	}
+
+	result.LinesAdded, result.LinesRemoved = diff.Count(diff.Compute(oldText, newText, 0))
+	if fileDiff.IsDelete() {
+		result.Deleted = applied == len(hunks) && newText == ""
+	}
+	if dryRun {
+		return result
+	}
+
+	switch {
+	case result.Deleted:
+		err = fsys.Remove(ctx.Context, source, false)
+	default:
+		if result.Created {
+			if dir := parentDir(target); dir != "" {
+				err = fsys.Mkdir(ctx.Context, dir, 0755, true)
+			}
+		}
+		if err == nil {
+			err = fs.WriteFileAtomic(ctx.Context, fsys, result.Path, []byte(newText), 0644)
+		}
+		// 重命名文件时删除原文件
+		if err == nil && source != "" && target != "" && source != target {
+			err = fsys.Remove(ctx.Context, source, false)
+		}
+	}
+	if err != nil {
+		return reject(err)
+	}
+	return result
+}
+
+// writePatchReport 以 GNU patch 的格式输出处理结果，返回被拒绝的变更块数量
+func writePatchReport(out *strings.Builder, result PatchFileResult, dryRun bool) int {
+	action := "patching"
+	if dryRun {
+		action = "checking"
+	}
+	fmt.Fprintf(out, "%s file %s\n", action, result.Path)
+	if result.Error != "" {
+		fmt.Fprintf(out, "%s\n", result.Error)
+	}
+
+	rejected := 0
+	for _, h := range result.Hunks {
+		switch {
+		case !h.Applied:
+			rejected++
+			fmt.Fprintf(out, "Hunk #%d FAILED.\n", h.Index)
+		case h.Offset != 0 || h.Fuzz != 0:
+			fmt.Fprintf(out, "Hunk #%d succeeded at %d", h.Index, h.Line)
+			if h.Fuzz != 0 {
+				fmt.Fprintf(out, " with fuzz %d", h.Fuzz)
+			}
+			if h.Offset != 0 {
+				fmt.Fprintf(out, " (offset %d lines)", h.Offset)
+			}
+			out.WriteString(".\n")
+		}
+	}
+	if rejected > 0 {
+		fmt.Fprintf(out, "%d out of %d hunks FAILED\n", rejected, len(result.Hunks))
+	}
+	return rejected
+}
@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iamlongalong/runshell/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestWriteCommand(t *testing.T) {
+	root := t.TempDir()
+
+	result, output, err := runNative(t, root, &WriteCommand{}, "-p", "sub/dir/a.txt", "hello\n")
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Contains(t, output, "sub/dir/a.txt")
+	assert.Equal(t, "hello\n", readTestFile(t, filepath.Join(root, "sub/dir/a.txt")))
+
+	data := result.Data.(FileEditResult)
+	assert.True(t, data.Created)
+	assert.Equal(t, 1, data.LinesAdded)
+
+	// 覆盖已存在的文件并保留权限
+	require.NoError(t, os.Chmod(filepath.Join(root, "sub/dir/a.txt"), 0600))
+	result, _, err = runNative(t, root, &WriteCommand{}, "sub/dir/a.txt", "world\n")
+	require.NoError(t, err)
+	data = result.Data.(FileEditResult)
+	assert.False(t, data.Created)
+	assert.Equal(t, 1, data.LinesRemoved)
+	assert.Contains(t, data.Diff, "+world")
+	info, err := os.Stat(filepath.Join(root, "sub/dir/a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// 上级目录不存在且未指定 -p
+	result, _, err = runNative(t, root, &WriteCommand{}, "missing/b.txt", "x")
+	assert.Error(t, err)
+	assert.Equal(t, 1, result.ExitCode)
+
+	// 不能写出工作目录
+	_, _, err = runNative(t, root, &WriteCommand{}, "../escape.txt", "x")
+	assert.Error(t, err)
+}
+
+func TestEditCommand(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.go")
+	require.NoError(t, os.WriteFile(path, []byte("foo := 1\nbar := foo\n"), 0644))
+
+	t.Run("ambiguous match", func(t *testing.T) {
+		_, _, err := runNative(t, root, &EditCommand{}, "a.go", "foo", "baz")
+		assert.ErrorContains(t, err, "expected 1 match(es)")
+		assert.Equal(t, "foo := 1\nbar := foo\n", readTestFile(t, path))
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		_, _, err := runNative(t, root, &EditCommand{}, "a.go", "qux", "baz")
+		assert.ErrorContains(t, err, "no match")
+	})
+
+	t.Run("count", func(t *testing.T) {
+		result, _, err := runNative(t, root, &EditCommand{}, "--count", "2", "a.go", "foo", "baz")
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Data.(FileEditResult).Replacements)
+		assert.Equal(t, "baz := 1\nbar := baz\n", readTestFile(t, path))
+	})
+
+	t.Run("regex", func(t *testing.T) {
+		result, _, err := runNative(t, root, &EditCommand{}, "--regex", "--all", "a.go", `(\w+) := (\d+)`, "var $1 = $2")
+		require.NoError(t, err)
+		data := result.Data.(FileEditResult)
+		assert.Equal(t, 1, data.Replacements)
+		assert.Equal(t, 1, data.LinesAdded)
+		assert.Equal(t, 1, data.LinesRemoved)
+		assert.Equal(t, "var baz = 1\nbar := baz\n", readTestFile(t, path))
+	})
+
+	t.Run("through symlink", func(t *testing.T) {
+		target := filepath.Join(root, "target.txt")
+		require.NoError(t, os.WriteFile(target, []byte("old\n"), 0600))
+		require.NoError(t, os.Symlink("target.txt", filepath.Join(root, "link.txt")))
+
+		_, _, err := runNative(t, root, &EditCommand{}, "link.txt", "old", "new")
+		require.NoError(t, err)
+
+		// 写入链接指向的文件，链接和目标文件的权限保持不变
+		assert.Equal(t, "new\n", readTestFile(t, target))
+		info, err := os.Lstat(filepath.Join(root, "link.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, os.ModeSymlink, info.Mode()&os.ModeSymlink)
+		info, err = os.Stat(target)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		// 指向工作目录外的链接不能写入
+		outside := filepath.Join(t.TempDir(), "outside.txt")
+		require.NoError(t, os.WriteFile(outside, []byte("old\n"), 0644))
+		require.NoError(t, os.Symlink(outside, filepath.Join(root, "escape.txt")))
+		_, _, err = runNative(t, root, &EditCommand{}, "escape.txt", "old", "new")
+		assert.Error(t, err)
+		assert.Equal(t, "old\n", readTestFile(t, outside))
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, _, err := runNative(t, root, &EditCommand{}, "--all", "--count", "2", "a.go", "a", "b")
+		assert.Error(t, err)
+		_, _, err = runNative(t, root, &EditCommand{}, "a.go", "a")
+		assert.Error(t, err)
+	})
+}
+
+func TestPatchCommand(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.txt")
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	require.NoError(t, os.WriteFile(path, []byte(old), 0644))
+
+	patch := diff.Unified("a/a.txt", "b/a.txt", old, "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n", 3) +
+		diff.Unified(diff.DevNull, "b/new/b.txt", "", "hello\n", 3)
+
+	t.Run("dry run", func(t *testing.T) {
+		result, output, err := runNative(t, root, &PatchCommand{}, "--dry-run", patch)
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Contains(t, output, "checking file a.txt")
+		assert.Equal(t, old, readTestFile(t, path))
+		assert.NoFileExists(t, filepath.Join(root, "new/b.txt"))
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		result, output, err := runNative(t, root, &PatchCommand{}, patch)
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Contains(t, output, "patching file new/b.txt")
+
+		data := result.Data.(PatchResult)
+		require.Len(t, data.Files, 2)
+		assert.Equal(t, 2, data.Files[0].LinesAdded)
+		assert.True(t, data.Files[1].Created)
+		assert.Equal(t, "hello\n", readTestFile(t, filepath.Join(root, "new/b.txt")))
+		assert.Equal(t, "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n", readTestFile(t, path))
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(old), 0644))
+		second := diff.Unified("a/a.txt", "b/a.txt", old, "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\nXII\n", 3)
+		require.NoError(t, os.WriteFile(path, []byte("one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n"), 0644))
+
+		result, output, err := runNative(t, root, &PatchCommand{}, "-p1", "--fuzz", "0", second)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.ExitCode)
+		assert.Error(t, result.Error)
+		assert.Contains(t, output, "Hunk #1 FAILED")
+		assert.Equal(t, 1, result.Data.(PatchResult).Rejected)
+	})
+
+	t.Run("patch file", func(t *testing.T) {
+		current := readTestFile(t, path)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "fix.patch"),
+			[]byte(diff.Unified("a.txt", "a.txt", current, "zero\n"+current, 3)), 0644))
+
+		result, _, err := runNative(t, root, &PatchCommand{}, "-p", "0", "-i", "fix.patch")
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Equal(t, "zero\n"+current, readTestFile(t, path))
+	})
+}
@@ -0,0 +1,182 @@
+// Package commands 实现了 RunShell 的内置命令。
+// 本文件实现了 find 命令。
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/iamlongalong/runshell/pkg/fs"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// FindResult 表示 find 命令的结果
+// swagger:model
+type FindResult struct {
+	Roots   []string         `json:"roots"`   // 搜索的起始路径
+	Entries []types.FileInfo `json:"entries"` // 匹配的条目，Path 为带起始路径前缀的路径
+}
+
+// findOptions find 命令支持的表达式，多个条件之间为“与”关系
+type findOptions struct {
+	name       string // -name 匹配的文件名模式
+	ignoreCase bool   // -iname
+	fileType   string // -type：f、d 或 l
+	maxDepth   int    // -maxdepth，-1 表示不限制
+	minDepth   int    // -mindepth
+}
+
+// match 检查条目是否满足表达式
+func (o *findOptions) match(info *types.FileInfo, depth int) bool {
+	if depth < o.minDepth {
+		return false
+	}
+	if o.name != "" {
+		name, pattern := info.Name, o.name
+		if o.ignoreCase {
+			name, pattern = strings.ToLower(name), strings.ToLower(pattern)
+		}
+		if ok, _ := path.Match(pattern, name); !ok {
+			return false
+		}
+	}
+	switch o.fileType {
+	case "f":
+		return !info.IsDir && info.Link == ""
+	case "d":
+		return info.IsDir
+	case "l":
+		return info.Link != ""
+	}
+	return true
+}
+
+// parseFindArgs 解析 find 参数，返回起始路径和表达式。
+// 不支持的表达式（如 -exec、-o）返回 errUnsupported。
+func parseFindArgs(args []string) ([]string, *findOptions, error) {
+	opts := &findOptions{maxDepth: -1}
+
+	i := 0
+	var roots []string
+	for ; i < len(args) && !strings.HasPrefix(args[i], "-"); i++ {
+		roots = append(roots, args[i])
+	}
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if i+1 >= len(args) {
+			if arg == "-print" {
+				continue
+			}
+			return nil, nil, fmt.Errorf("%w: %s", errUnsupported, arg)
+		}
+
+		value := args[i+1]
+		switch arg {
+		case "-name", "-iname":
+			opts.name, opts.ignoreCase = value, arg == "-iname"
+			if _, err := path.Match(value, ""); err != nil {
+				return nil, nil, fmt.Errorf("invalid pattern: %s", value)
+			}
+		case "-type":
+			if value != "f" && value != "d" && value != "l" {
+				return nil, nil, fmt.Errorf("%w: -type %s", errUnsupported, value)
+			}
+			opts.fileType = value
+		case "-maxdepth", "-mindepth":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, nil, fmt.Errorf("invalid depth: %s", value)
+			}
+			if arg == "-maxdepth" {
+				opts.maxDepth = n
+			} else {
+				opts.minDepth = n
+			}
+		case "-print":
+			continue
+		default:
+			return nil, nil, fmt.Errorf("%w: %s", errUnsupported, arg)
+		}
+		i++
+	}
+	return roots, opts, nil
+}
+
+// FindCommand 实现了 find 命令。
+// 支持按名称、类型和深度查找文件，并返回结构化的匹配条目。
+type FindCommand struct{}
+
+func (c *FindCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "find",
+		Description: "Search for files in a directory hierarchy",
+		Usage:       "find [path...] [-name pattern | -iname pattern] [-type f|d|l] [-maxdepth N] [-mindepth N]",
+		Category:    "file",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "name", Flag: "-name", Type: types.ArgTypeString, Description: "Base name glob pattern"},
+				{Name: "iname", Flag: "-iname", Type: types.ArgTypeString, Description: "Case-insensitive base name glob pattern"},
+				{Name: "type", Flag: "-type", Type: types.ArgTypeString, Enum: []string{"f", "d", "l", "b", "c", "p", "s"}, Description: "Entry type"},
+				{Name: "maxdepth", Flag: "-maxdepth", Type: types.ArgTypeInt, Description: "Descend at most N levels"},
+				{Name: "mindepth", Flag: "-mindepth", Type: types.ArgTypeInt, Description: "Ignore entries above level N"},
+				{Name: "path", Type: types.ArgTypePath, Default: ".", Variadic: true, Description: "Starting points"},
+			},
+			Passthrough: true,
+		},
+	}
+}
+
+// Execute 执行 find 命令。
+func (c *FindCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	roots, opts, err := parseFindArgs(ctx.Command.Args)
+	if err != nil {
+		if errors.Is(err, errUnsupported) {
+			return runSystem(ctx, "find", ctx.Command.Args)
+		}
+		return nil, err
+	}
+
+	fsys, ok, err := fileSystem(ctx)
+	if !ok {
+		return runSystem(ctx, "find", ctx.Command.Args)
+	}
+	if err != nil {
+		return fsError(ctx, "find", err)
+	}
+
+	result := FindResult{Roots: roots, Entries: []types.FileInfo{}}
+	var out strings.Builder
+	for _, root := range roots {
+		err := fs.Walk(ctx.Context, fsys, root, func(name string, info *types.FileInfo, depth int, err error) error {
+			if err != nil {
+				return err
+			}
+			if opts.match(info, depth) {
+				entry := *info
+				entry.Path = name
+				result.Entries = append(result.Entries, entry)
+				out.WriteString(name + "\n")
+			}
+			if info.IsDir && opts.maxDepth >= 0 && depth >= opts.maxDepth {
+				return fs.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			return fsError(ctx, "find", err)
+		}
+	}
+
+	return fsResult(ctx, "find", out.String(), result), nil
+}
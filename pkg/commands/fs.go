@@ -3,10 +3,135 @@ package commands
 
 import (
 	"fmt"
+	"path"
+	"strings"
 
+	"github.com/iamlongalong/runshell/pkg/fs"
 	"github.com/iamlongalong/runshell/pkg/types"
 )
 
+// 文件命令使用执行器提供的 types.FileSystem 实现，在宿主机和容器中行为一致，
+// 且不依赖执行环境中的 coreutils。执行器不支持文件系统访问时退回到同名系统命令。
+
+// ListResult 表示 ls 命令对一个路径的列举结果
+// swagger:model
+type ListResult struct {
+	Path    string           `json:"path"`    // 列举的路径
+	Entries []types.FileInfo `json:"entries"` // 目录条目，路径为文件时只包含文件本身
+}
+
+// RemoveResult 表示 rm 命令的结果
+// swagger:model
+type RemoveResult struct {
+	Removed []string `json:"removed"` // 已删除的路径
+}
+
+// CopyResult 表示 cp 命令的结果
+// swagger:model
+type CopyResult struct {
+	Sources     []string `json:"sources"`     // 源路径
+	Destination string   `json:"destination"` // 目标路径
+	Files       int      `json:"files"`       // 复制的文件数量
+}
+
+// fileSystem 获取执行器提供的文件系统，执行器不支持时返回 false
+func fileSystem(ctx *types.ExecuteContext) (types.FileSystem, bool, error) {
+	provider, ok := types.As[types.FileSystemProvider](ctx.Executor)
+	if !ok {
+		return nil, false, nil
+	}
+	workDir := ""
+	if ctx.Options != nil {
+		workDir = ctx.Options.WorkDir
+	}
+	fsys, err := provider.FileSystem(workDir)
+	return fsys, true, err
+}
+
+// runSystem 使用执行器执行同名系统命令
+func runSystem(ctx *types.ExecuteContext, name string, args []string) (*types.ExecuteResult, error) {
+	// 创建一个新的上下文，避免递归调用
+	newCtx := ctx.Copy()
+	newCtx.Command = types.Command{Command: name, Args: args}
+
+	// 直接使用执行器执行系统命令
+	return newCtx.Executor.ExecuteCommand(newCtx)
+}
+
+// checkContext 检查上下文和执行器
+func checkContext(ctx *types.ExecuteContext) error {
+	if ctx == nil {
+		return fmt.Errorf("context is nil")
+	}
+	if ctx.Executor == nil {
+		return fmt.Errorf("executor is nil")
+	}
+	return nil
+}
+
+// parseFlags 解析短选项，选项可以合并书写（如 -rf），"--" 之后的参数都视为操作数
+func parseFlags(args []string, allowed string) (map[rune]bool, []string, error) {
+	flags := make(map[rune]bool)
+	var operands []string
+	for i, arg := range args {
+		if arg == "--" {
+			operands = append(operands, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			operands = append(operands, arg)
+			continue
+		}
+		for _, r := range arg[1:] {
+			if !strings.ContainsRune(allowed, r) {
+				return nil, nil, fmt.Errorf("invalid option -- '%c'", r)
+			}
+			flags[r] = true
+		}
+	}
+	return flags, operands, nil
+}
+
+// fsResult 构造文件命令的成功结果，并将文本输出写入 Stdout
+func fsResult(ctx *types.ExecuteContext, name, output string, data interface{}) *types.ExecuteResult {
+	if output != "" && ctx.Options != nil && ctx.Options.Stdout != nil {
+		fmt.Fprint(ctx.Options.Stdout, output)
+	}
+	return &types.ExecuteResult{
+		CommandName: name,
+		ExitCode:    0,
+		StartTime:   ctx.StartTime,
+		EndTime:     types.GetTimeNow(),
+		Output:      output,
+		Data:        data,
+	}
+}
+
+// fsError 构造文件命令的失败结果，并将错误信息写入 Stderr
+func fsError(ctx *types.ExecuteContext, name string, err error) (*types.ExecuteResult, error) {
+	err = fmt.Errorf("%s: %w", name, err)
+	if ctx.Options != nil && ctx.Options.Stderr != nil {
+		fmt.Fprintln(ctx.Options.Stderr, err)
+	}
+	return &types.ExecuteResult{
+		CommandName: name,
+		ExitCode:    1,
+		StartTime:   ctx.StartTime,
+		EndTime:     types.GetTimeNow(),
+		Error:       err,
+		Output:      err.Error() + "\n",
+	}, err
+}
+
+// formatLong 以 ls -l 的格式输出条目
+func formatLong(entry types.FileInfo) string {
+	line := fmt.Sprintf("%s %10d %s %s", entry.Mode.String(), entry.Size, entry.ModTime.Format("Jan _2 15:04"), entry.Name)
+	if entry.Link != "" {
+		line += " -> " + entry.Link
+	}
+	return line
+}
+
 // LSCommand 实现了 ls 命令。
 // 用于列出目录内容。
 type LSCommand struct {
@@ -16,26 +141,79 @@ func (c *LSCommand) Info() types.CommandInfo {
 	return types.CommandInfo{
 		Name:        "ls",
 		Description: "List directory contents",
-		Usage:       "ls [path]",
+		Usage:       "ls [-a] [-l] [path...]",
+		Category:    "file",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "all", Flag: "-a", Type: types.ArgTypeBool, Description: "Include entries starting with ."},
+				{Name: "long", Flag: "-l", Type: types.ArgTypeBool, Description: "Use a long listing format"},
+				{Name: "path", Type: types.ArgTypePath, Default: ".", Variadic: true, Description: "Files or directories to list"},
+			},
+		},
 	}
 }
 
 // Execute 执行 ls 命令。
 func (c *LSCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	if ctx == nil {
-		return nil, fmt.Errorf("context is nil")
+	if err := checkContext(ctx); err != nil {
+		return nil, err
 	}
 
-	if ctx.Executor == nil {
-		return nil, fmt.Errorf("executor is nil")
+	fsys, ok, err := fileSystem(ctx)
+	if !ok {
+		return runSystem(ctx, "ls", ctx.Command.Args)
+	}
+	if err != nil {
+		return fsError(ctx, "ls", err)
 	}
 
-	// 创建一个新的上下文，避免递归调用
-	newCtx := ctx.Copy() // 使用 Copy 方法复制上下文
-	newCtx.Command = types.Command{Command: "ls", Args: ctx.Command.Args}
+	flags, paths, err := parseFlags(ctx.Command.Args, "al")
+	if err != nil {
+		return fsError(ctx, "ls", err)
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
 
-	// 直接使用执行器执行系统命令
-	return newCtx.Executor.ExecuteCommand(newCtx)
+	var results []ListResult
+	var out strings.Builder
+	for i, p := range paths {
+		info, err := fsys.Stat(ctx.Context, p)
+		if err != nil {
+			return fsError(ctx, "ls", err)
+		}
+
+		entries := []types.FileInfo{*info}
+		if info.IsDir {
+			all, err := fsys.ReadDir(ctx.Context, p)
+			if err != nil {
+				return fsError(ctx, "ls", err)
+			}
+			entries = make([]types.FileInfo, 0, len(all))
+			for _, entry := range all {
+				if flags['a'] || !strings.HasPrefix(entry.Name, ".") {
+					entries = append(entries, entry)
+				}
+			}
+		}
+		results = append(results, ListResult{Path: p, Entries: entries})
+
+		if len(paths) > 1 {
+			if i > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString(p + ":\n")
+		}
+		for _, entry := range entries {
+			if flags['l'] {
+				out.WriteString(formatLong(entry) + "\n")
+			} else {
+				out.WriteString(entry.Name + "\n")
+			}
+		}
+	}
+
+	return fsResult(ctx, "ls", out.String(), results), nil
 }
 
 // CatCommand 实现了 cat 命令。
@@ -47,29 +225,49 @@ func (c *CatCommand) Info() types.CommandInfo {
 		Name:        "cat",
 		Description: "Concatenate and print files",
 		Usage:       "cat [file...]",
+		Category:    "file",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "file", Type: types.ArgTypePath, Required: true, Variadic: true, Description: "Files to print"},
+			},
+		},
 	}
 }
 
 // Execute 执行 cat 命令。
 func (c *CatCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	if ctx == nil {
-		return nil, fmt.Errorf("context is nil")
-	}
-
-	if ctx.Executor == nil {
-		return nil, fmt.Errorf("executor is nil")
+	if err := checkContext(ctx); err != nil {
+		return nil, err
 	}
 
 	if len(ctx.Command.Args) == 0 {
 		return nil, fmt.Errorf("no file specified")
 	}
 
-	// 创建一个新的上下文，避免递归调用
-	newCtx := ctx.Copy()
-	newCtx.Command = types.Command{Command: "cat", Args: ctx.Command.Args}
+	fsys, ok, err := fileSystem(ctx)
+	if !ok {
+		return runSystem(ctx, "cat", ctx.Command.Args)
+	}
+	if err != nil {
+		return fsError(ctx, "cat", err)
+	}
 
-	// 直接使用执行器执行系统命令
-	return newCtx.Executor.ExecuteCommand(newCtx)
+	var files []types.FileInfo
+	var out strings.Builder
+	for _, p := range ctx.Command.Args {
+		data, err := fsys.ReadFile(ctx.Context, p)
+		if err != nil {
+			return fsError(ctx, "cat", err)
+		}
+		info, err := fsys.Stat(ctx.Context, p)
+		if err != nil {
+			return fsError(ctx, "cat", err)
+		}
+		files = append(files, *info)
+		out.Write(data)
+	}
+
+	return fsResult(ctx, "cat", out.String(), files), nil
 }
 
 // MkdirCommand 实现了 mkdir 命令。
@@ -80,30 +278,56 @@ func (c *MkdirCommand) Info() types.CommandInfo {
 	return types.CommandInfo{
 		Name:        "mkdir",
 		Description: "Create directories",
-		Usage:       "mkdir [directory...]",
+		Usage:       "mkdir [-p] [directory...]",
+		Category:    "file",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "parents", Flag: "-p", Type: types.ArgTypeBool, Description: "Create parent directories as needed"},
+				{Name: "directory", Type: types.ArgTypePath, Required: true, Variadic: true, Description: "Directories to create"},
+			},
+		},
 	}
 }
 
 // Execute 执行 mkdir 命令。
 func (c *MkdirCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	if ctx == nil {
-		return nil, fmt.Errorf("context is nil")
-	}
-
-	if ctx.Executor == nil {
-		return nil, fmt.Errorf("executor is nil")
+	if err := checkContext(ctx); err != nil {
+		return nil, err
 	}
 
 	if len(ctx.Command.Args) == 0 {
 		return nil, fmt.Errorf("no directory specified")
 	}
 
-	// 创建一个新的上下文，避免递归调用
-	newCtx := ctx.Copy()
-	newCtx.Command = types.Command{Command: "mkdir", Args: ctx.Command.Args}
+	fsys, ok, err := fileSystem(ctx)
+	if !ok {
+		return runSystem(ctx, "mkdir", ctx.Command.Args)
+	}
+	if err != nil {
+		return fsError(ctx, "mkdir", err)
+	}
 
-	// 直接使用执行器执行系统命令
-	return newCtx.Executor.ExecuteCommand(newCtx)
+	flags, dirs, err := parseFlags(ctx.Command.Args, "p")
+	if err != nil {
+		return fsError(ctx, "mkdir", err)
+	}
+	if len(dirs) == 0 {
+		return fsError(ctx, "mkdir", fmt.Errorf("no directory specified"))
+	}
+
+	var created []types.FileInfo
+	for _, dir := range dirs {
+		if err := fsys.Mkdir(ctx.Context, dir, 0755, flags['p']); err != nil {
+			return fsError(ctx, "mkdir", err)
+		}
+		info, err := fsys.Stat(ctx.Context, dir)
+		if err != nil {
+			return fsError(ctx, "mkdir", err)
+		}
+		created = append(created, *info)
+	}
+
+	return fsResult(ctx, "mkdir", "", created), nil
 }
 
 // RmCommand 实现了 rm 命令。
@@ -114,30 +338,61 @@ func (c *RmCommand) Info() types.CommandInfo {
 	return types.CommandInfo{
 		Name:        "rm",
 		Description: "Remove files or directories",
-		Usage:       "rm [file...]",
+		Usage:       "rm [-r] [-f] [file...]",
+		Category:    "file",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "recursive", Flag: "-r", Aliases: []string{"-R"}, Type: types.ArgTypeBool, Description: "Remove directories and their contents"},
+				{Name: "force", Flag: "-f", Type: types.ArgTypeBool, Description: "Ignore nonexistent files"},
+				{Name: "file", Type: types.ArgTypePath, Required: true, Variadic: true, Description: "Files to remove"},
+			},
+		},
 	}
 }
 
 // Execute 执行 rm 命令。
 func (c *RmCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	if ctx == nil {
-		return nil, fmt.Errorf("context is nil")
-	}
-
-	if ctx.Executor == nil {
-		return nil, fmt.Errorf("executor is nil")
+	if err := checkContext(ctx); err != nil {
+		return nil, err
 	}
 
 	if len(ctx.Command.Args) == 0 {
 		return nil, fmt.Errorf("no file specified")
 	}
 
-	// 创建一个新的上下文，避免递归调用
-	newCtx := ctx.Copy()
-	newCtx.Command = types.Command{Command: "rm", Args: ctx.Command.Args}
+	fsys, ok, err := fileSystem(ctx)
+	if !ok {
+		return runSystem(ctx, "rm", ctx.Command.Args)
+	}
+	if err != nil {
+		return fsError(ctx, "rm", err)
+	}
 
-	// 直接使用执行器执行系统命令
-	return newCtx.Executor.ExecuteCommand(newCtx)
+	flags, paths, err := parseFlags(ctx.Command.Args, "rRf")
+	if err != nil {
+		return fsError(ctx, "rm", err)
+	}
+	recursive := flags['r'] || flags['R']
+
+	result := RemoveResult{Removed: []string{}}
+	for _, p := range paths {
+		info, err := fsys.Stat(ctx.Context, p)
+		if err != nil {
+			if flags['f'] && fs.IsNotExist(err) {
+				continue
+			}
+			return fsError(ctx, "rm", err)
+		}
+		if info.IsDir && !recursive {
+			return fsError(ctx, "rm", fmt.Errorf("cannot remove '%s': is a directory", p))
+		}
+		if err := fsys.Remove(ctx.Context, p, recursive); err != nil {
+			return fsError(ctx, "rm", err)
+		}
+		result.Removed = append(result.Removed, p)
+	}
+
+	return fsResult(ctx, "rm", "", result), nil
 }
 
 // CpCommand 实现了 cp 命令。
@@ -148,30 +403,143 @@ func (c *CpCommand) Info() types.CommandInfo {
 	return types.CommandInfo{
 		Name:        "cp",
 		Description: "Copy files and directories",
-		Usage:       "cp [source] [dest]",
+		Usage:       "cp [-r] [source...] [dest]",
+		Category:    "file",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "recursive", Flag: "-r", Aliases: []string{"-R"}, Type: types.ArgTypeBool, Description: "Copy directories recursively"},
+				{Name: "source", Type: types.ArgTypePath, Required: true, Description: "Source path"},
+				{Name: "dest", Type: types.ArgTypePath, Required: true, Variadic: true, Description: "Destination, preceded by further sources"},
+			},
+		},
 	}
 }
 
 // Execute 执行 cp 命令。
 func (c *CpCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	if ctx == nil {
-		return nil, fmt.Errorf("context is nil")
+	if err := checkContext(ctx); err != nil {
+		return nil, err
 	}
 
-	if ctx.Executor == nil {
-		return nil, fmt.Errorf("executor is nil")
+	if len(ctx.Command.Args) < 2 {
+		return nil, fmt.Errorf("cp requires source and destination")
+	}
+
+	fsys, ok, err := fileSystem(ctx)
+	if !ok {
+		return runSystem(ctx, "cp", ctx.Command.Args)
+	}
+	if err != nil {
+		return fsError(ctx, "cp", err)
+	}
+
+	flags, paths, err := parseFlags(ctx.Command.Args, "rR")
+	if err != nil {
+		return fsError(ctx, "cp", err)
+	}
+	if len(paths) < 2 {
+		return fsError(ctx, "cp", fmt.Errorf("cp requires source and destination"))
+	}
+
+	sources, dest := paths[:len(paths)-1], paths[len(paths)-1]
+	if len(sources) > 1 {
+		info, err := fsys.Stat(ctx.Context, dest)
+		if err != nil {
+			return fsError(ctx, "cp", err)
+		}
+		if !info.IsDir {
+			return fsError(ctx, "cp", fmt.Errorf("target '%s' is not a directory", dest))
+		}
+	}
+
+	result := CopyResult{Sources: sources, Destination: dest}
+	for _, src := range sources {
+		n, err := fs.Copy(ctx.Context, fsys, src, dest, flags['r'] || flags['R'])
+		result.Files += n
+		if err != nil {
+			return fsError(ctx, "cp", err)
+		}
+	}
+
+	return fsResult(ctx, "cp", "", result), nil
+}
+
+// MoveResult 表示 mv 命令的结果
+// swagger:model
+type MoveResult struct {
+	Sources     []string `json:"sources"`     // 源路径
+	Destination string   `json:"destination"` // 目标路径
+}
+
+// MvCommand 实现了 mv 命令。
+// 用于移动或重命名文件和目录。
+type MvCommand struct{}
+
+func (c *MvCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "mv",
+		Description: "Move or rename files and directories",
+		Usage:       "mv [-f] [source...] [dest]",
+		Category:    "file",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "force", Flag: "-f", Type: types.ArgTypeBool, Description: "Overwrite existing files (default)"},
+				{Name: "source", Type: types.ArgTypePath, Required: true, Description: "Source path"},
+				{Name: "dest", Type: types.ArgTypePath, Required: true, Variadic: true, Description: "Destination, preceded by further sources"},
+			},
+		},
+	}
+}
+
+// Execute 执行 mv 命令。
+func (c *MvCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
 	}
 
 	if len(ctx.Command.Args) < 2 {
-		return nil, fmt.Errorf("cp requires source and destination")
+		return nil, fmt.Errorf("mv requires source and destination arguments")
 	}
 
-	// 创建一个新的上下文，避免递归调用
-	newCtx := ctx.Copy()
-	newCtx.Command = types.Command{Command: "cp", Args: ctx.Command.Args}
+	fsys, ok, err := fileSystem(ctx)
+	if !ok {
+		return runSystem(ctx, "mv", ctx.Command.Args)
+	}
+	if err != nil {
+		return fsError(ctx, "mv", err)
+	}
 
-	// 直接使用执行器执行系统命令
-	return newCtx.Executor.ExecuteCommand(newCtx)
+	// -f 为默认行为，仅为兼容而接受
+	_, paths, err := parseFlags(ctx.Command.Args, "f")
+	if err != nil {
+		return fsError(ctx, "mv", err)
+	}
+	if len(paths) < 2 {
+		return fsError(ctx, "mv", fmt.Errorf("mv requires source and destination arguments"))
+	}
+
+	sources, dest := paths[:len(paths)-1], paths[len(paths)-1]
+	destIsDir := false
+	if info, err := fsys.Stat(ctx.Context, dest); err == nil {
+		destIsDir = info.IsDir
+	} else if !fs.IsNotExist(err) {
+		return fsError(ctx, "mv", err)
+	}
+	if len(sources) > 1 && !destIsDir {
+		return fsError(ctx, "mv", fmt.Errorf("target '%s' is not a directory", dest))
+	}
+
+	for _, src := range sources {
+		target := dest
+		if destIsDir {
+			target = fs.Join(dest, path.Base(src))
+		}
+		if err := fsys.Rename(ctx.Context, src, target); err != nil {
+			return fsError(ctx, "mv", err)
+		}
+	}
+
+	return fsResult(ctx, "mv", "", MoveResult{Sources: sources, Destination: dest}), nil
 }
 
 // PWDCommand 实现了 pwd 命令。
@@ -183,6 +551,8 @@ func (c *PWDCommand) Info() types.CommandInfo {
 		Name:        "pwd",
 		Description: "Print working directory",
 		Usage:       "pwd",
+		Category:    "file",
+		Schema:      &types.ArgSchema{Args: []types.ArgSpec{}},
 	}
 }
 
@@ -203,52 +573,3 @@ func (c *PWDCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, e
 		Output:      workDir + "\n",
 	}, nil
 }
-
-// ReadFileCommand 实现了 readfile 命令。
-// 用于读取文件指定行范围的内容。
-type ReadFileCommand struct{}
-
-func (c *ReadFileCommand) Info() types.CommandInfo {
-	return types.CommandInfo{
-		Name:        "readfile",
-		Description: "Read file contents",
-		Usage:       "readfile [file] [start_line] [end_line]",
-	}
-}
-
-// Execute 执行 readfile 命令。
-func (c *ReadFileCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	if ctx == nil {
-		return nil, fmt.Errorf("context is nil")
-	}
-
-	if ctx.Executor == nil {
-		return nil, fmt.Errorf("executor is nil")
-	}
-
-	if len(ctx.Command.Args) < 1 {
-		return nil, fmt.Errorf("no file specified")
-	}
-
-	// 如果提供了行号参数，验证它们
-	if len(ctx.Command.Args) >= 3 {
-		startLine := ctx.Command.Args[1]
-		endLine := ctx.Command.Args[2]
-		if startLine < "1" {
-			return nil, fmt.Errorf("invalid start line: %s", startLine)
-		}
-		if endLine < "1" {
-			return nil, fmt.Errorf("invalid end line: %s", endLine)
-		}
-		if startLine > endLine {
-			return nil, fmt.Errorf("start line (%s) is greater than end line (%s)", startLine, endLine)
-		}
-	}
-
-	// 创建一个新的上下文，避免递归调用
-	newCtx := ctx.Copy()
-	newCtx.Command = types.Command{Command: "cat", Args: []string{ctx.Command.Args[0]}}
-
-	// 直接使用执行器执行系统命令
-	return newCtx.Executor.ExecuteCommand(newCtx)
-}
@@ -3,10 +3,14 @@ package commands
 import (
 	"bytes"
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/iamlongalong/runshell/pkg/fs"
 	"github.com/iamlongalong/runshell/pkg/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLSCommand(t *testing.T) {
@@ -431,3 +435,138 @@ func TestReadFileCommand(t *testing.T) {
 		})
 	}
 }
+
+// fsExecutor 为 MockExecutor 提供宿主机文件系统，用于测试内置命令的原生实现
+type fsExecutor struct {
+	*types.MockExecutor
+	root string
+}
+
+func (e *fsExecutor) FileSystem(workDir string) (types.FileSystem, error) {
+	return fs.NewLocalFS(e.root)
+}
+
+// runNative 在临时目录中执行内置命令
+func runNative(t *testing.T, root string, cmd types.ICommand, args ...string) (*types.ExecuteResult, string, error) {
+	stdout := &bytes.Buffer{}
+	ctx := &types.ExecuteContext{
+		Context:  context.Background(),
+		Command:  types.Command{Command: cmd.Info().Name, Args: args},
+		Executor: &fsExecutor{MockExecutor: types.NewMockExecutor(), root: root},
+		Options: &types.ExecuteOptions{
+			WorkDir: root,
+			Stdout:  stdout,
+		},
+	}
+	result, err := cmd.Execute(ctx)
+	return result, stdout.String(), err
+}
+
+func TestNativeFileCommands(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("line1\nline2\nline3\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".hidden"), []byte("h"), 0644))
+
+	t.Run("ls", func(t *testing.T) {
+		result, out, err := runNative(t, root, &LSCommand{})
+		require.NoError(t, err)
+		assert.Equal(t, "a.txt\n", out)
+
+		lists := result.Data.([]ListResult)
+		require.Len(t, lists, 1)
+		require.Len(t, lists[0].Entries, 1)
+		assert.Equal(t, int64(18), lists[0].Entries[0].Size)
+
+		_, out, err = runNative(t, root, &LSCommand{}, "-la")
+		require.NoError(t, err)
+		assert.Contains(t, out, ".hidden")
+		assert.Contains(t, out, "-rw-r--r--")
+
+		_, _, err = runNative(t, root, &LSCommand{}, "missing")
+		assert.Error(t, err)
+		_, _, err = runNative(t, root, &LSCommand{}, "-z")
+		assert.Error(t, err)
+	})
+
+	t.Run("cat", func(t *testing.T) {
+		result, out, err := runNative(t, root, &CatCommand{}, "a.txt", ".hidden")
+		require.NoError(t, err)
+		assert.Equal(t, "line1\nline2\nline3\nh", out)
+		assert.Len(t, result.Data.([]types.FileInfo), 2)
+
+		_, _, err = runNative(t, root, &CatCommand{}, "/etc/hostname")
+		assert.Error(t, err)
+	})
+
+	t.Run("mkdir", func(t *testing.T) {
+		_, _, err := runNative(t, root, &MkdirCommand{}, "x/y")
+		assert.Error(t, err)
+
+		result, _, err := runNative(t, root, &MkdirCommand{}, "-p", "x/y")
+		require.NoError(t, err)
+		created := result.Data.([]types.FileInfo)
+		require.Len(t, created, 1)
+		assert.True(t, created[0].IsDir)
+		assert.DirExists(t, filepath.Join(root, "x", "y"))
+	})
+
+	t.Run("cp", func(t *testing.T) {
+		result, _, err := runNative(t, root, &CpCommand{}, "a.txt", "x/y")
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Data.(CopyResult).Files)
+		assert.FileExists(t, filepath.Join(root, "x", "y", "a.txt"))
+
+		_, _, err = runNative(t, root, &CpCommand{}, "x", "z")
+		assert.Error(t, err)
+		_, _, err = runNative(t, root, &CpCommand{}, "-r", "x", "z")
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(root, "z", "y", "a.txt"))
+	})
+
+	t.Run("rm", func(t *testing.T) {
+		_, _, err := runNative(t, root, &RmCommand{}, "z")
+		assert.Error(t, err)
+
+		result, _, err := runNative(t, root, &RmCommand{}, "-rf", "z", "missing")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"z"}, result.Data.(RemoveResult).Removed)
+		assert.NoDirExists(t, filepath.Join(root, "z"))
+	})
+
+	t.Run("readfile", func(t *testing.T) {
+		result, out, err := runNative(t, root, &ReadFileCommand{}, "a.txt", "2", "3")
+		require.NoError(t, err)
+		assert.Equal(t, "line2\nline3\n", out)
+		data := result.Data.(ReadFileResult)
+		assert.Equal(t, 3, data.TotalLines)
+		assert.Equal(t, 2, data.StartLine)
+
+		_, out, err = runNative(t, root, &ReadFileCommand{}, "a.txt", "3", "10")
+		require.NoError(t, err)
+		assert.Equal(t, "line3\n", out)
+	})
+}
+
+func TestMvCommand(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.txt"), []byte("b"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "dir"), 0755))
+
+	result, _, err := runNative(t, root, &MvCommand{}, "a.txt", "c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, MoveResult{Sources: []string{"a.txt"}, Destination: "c.txt"}, result.Data)
+	assert.FileExists(t, filepath.Join(root, "c.txt"))
+	assert.NoFileExists(t, filepath.Join(root, "a.txt"))
+
+	_, _, err = runNative(t, root, &MvCommand{}, "-f", "b.txt", "c.txt", "dir")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(root, "dir/b.txt"))
+	assert.FileExists(t, filepath.Join(root, "dir/c.txt"))
+
+	_, _, err = runNative(t, root, &MvCommand{}, "dir/b.txt", "dir/c.txt", "missing")
+	assert.Error(t, err)
+
+	_, _, err = runNative(t, root, &MvCommand{}, "dir/b.txt", "../escape.txt")
+	assert.Error(t, err)
+}
@@ -0,0 +1,544 @@
+// Package commands 实现了 RunShell 的内置命令。
+// 本文件实现了面向 Agent 的 readfile 命令。
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/iamlongalong/runshell/pkg/diff"
+	"github.com/iamlongalong/runshell/pkg/fs"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// DefaultReadFileMaxBytes readfile 默认返回的最大字节数
+const DefaultReadFileMaxBytes = 256 * 1024
+
+// binarySniffLen 用于检测二进制内容的字节数
+const binarySniffLen = 8000
+
+// 文件编码
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "iso-8859-1"
+)
+
+// ReadFileResult 表示 readfile 命令的结果
+// swagger:model
+type ReadFileResult struct {
+	Path       string `json:"path"`                 // 文件路径
+	Size       int64  `json:"size"`                 // 文件大小（字节）
+	TotalLines int    `json:"total_lines"`          // 文件总行数，读取在文件末尾之前结束时为 0
+	StartLine  int    `json:"start_line,omitempty"` // 返回内容的起始行号（从 1 开始）
+	EndLine    int    `json:"end_line,omitempty"`   // 返回内容的结束行号（包含）
+	Offset     int64  `json:"offset"`               // 返回内容的起始字节偏移
+	Bytes      int    `json:"bytes"`                // 返回内容的字节数
+	Truncated  bool   `json:"truncated"`            // 是否因超出 max-bytes 被截断
+	Binary     bool   `json:"binary"`               // 是否为二进制文件，二进制文件不返回内容
+	Encoding   string `json:"encoding,omitempty"`   // 检测到的文本编码，内容统一转换为 UTF-8 返回
+	Content    string `json:"content"`              // 读取的内容
+}
+
+// readFileOptions readfile 命令的参数
+type readFileOptions struct {
+	path        string
+	startLine   int   // 起始行号，0 表示未指定
+	endLine     int   // 结束行号，0 表示到文件末尾
+	offset      int64 // 字节偏移，-1 表示未指定
+	maxBytes    int   // 最大返回字节数
+	lineNumbers bool  // 是否输出行号
+}
+
+// ReadFileCommand 实现了 readfile 命令。
+// 用于按行或按字节范围读取文件内容。
+type ReadFileCommand struct{}
+
+func (c *ReadFileCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "readfile",
+		Description: "Read file contents by line or byte range",
+		Usage:       "readfile [-n|--line-numbers] [--offset BYTES] [--max-bytes BYTES] file [start_line] [end_line]",
+		Category:    "file",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "line_numbers", Flag: "-n", Aliases: []string{"--line-numbers"}, Type: types.ArgTypeBool, Description: "Prefix lines with line numbers"},
+				{Name: "offset", Flag: "--offset", Type: types.ArgTypeInt, Description: "Start reading at this byte offset"},
+				{Name: "max_bytes", Flag: "--max-bytes", Type: types.ArgTypeInt, Default: strconv.Itoa(DefaultReadFileMaxBytes), Description: "Maximum number of bytes to return"},
+				{Name: "file", Type: types.ArgTypePath, Required: true, Description: "File to read"},
+				{Name: "start_line", Type: types.ArgTypeInt, Description: "First line to return (1-based)"},
+				{Name: "end_line", Type: types.ArgTypeInt, Description: "Last line to return (inclusive)"},
+			},
+		},
+	}
+}
+
+// parseReadFileArgs 解析 readfile 参数，兼容 file start_line end_line 的位置参数形式
+func parseReadFileArgs(args []string) (*readFileOptions, error) {
+	opts := &readFileOptions{offset: -1, maxBytes: DefaultReadFileMaxBytes}
+
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+
+		// 需要参数值的长选项，支持 --opt value 和 --opt=value 两种写法
+		takeValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("option %s requires a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch {
+		case arg == "-n" || arg == "--line-numbers":
+			opts.lineNumbers = true
+		case name == "--offset":
+			v, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid offset: %s", v)
+			}
+			opts.offset = n
+		case name == "--max-bytes":
+			v, err := takeValue()
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid max bytes: %s", v)
+			}
+			opts.maxBytes = n
+		case arg == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		default:
+			// 以 "-" 开头的参数仅在位置参数已给出文件名后才视为行号，便于报告非法行号
+			if strings.HasPrefix(arg, "-") && len(positional) == 0 {
+				return nil, fmt.Errorf("unknown option: %s", arg)
+			}
+			positional = append(positional, arg)
+		}
+	}
+
+	if len(positional) < 1 {
+		return nil, fmt.Errorf("no file specified")
+	}
+	if len(positional) > 3 {
+		return nil, fmt.Errorf("too many arguments")
+	}
+	opts.path = positional[0]
+
+	if len(positional) >= 2 {
+		n, err := strconv.Atoi(positional[1])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid start line: %s", positional[1])
+		}
+		opts.startLine = n
+	}
+	if len(positional) >= 3 {
+		n, err := strconv.Atoi(positional[2])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid end line: %s", positional[2])
+		}
+		opts.endLine = n
+		if opts.startLine > opts.endLine {
+			return nil, fmt.Errorf("start line (%d) is greater than end line (%d)", opts.startLine, opts.endLine)
+		}
+	}
+	if opts.offset >= 0 && opts.startLine > 0 {
+		return nil, fmt.Errorf("--offset cannot be combined with a line range")
+	}
+	return opts, nil
+}
+
+// Execute 执行 readfile 命令。
+func (c *ReadFileCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	opts, err := parseReadFileArgs(ctx.Command.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys, ok, err := fileSystem(ctx)
+	if !ok {
+		return runSystem(ctx, "cat", []string{opts.path})
+	}
+	if err != nil {
+		return fsError(ctx, "readfile", err)
+	}
+
+	// 跟随符号链接获取文件大小，内容通过流读取，读取到所需范围后即停止
+	name, info, err := fs.ResolveLink(ctx.Context, fsys, opts.path)
+	if err != nil {
+		return fsError(ctx, "readfile", err)
+	}
+	if info == nil {
+		return fsError(ctx, "readfile", &os.PathError{Op: "open", Path: opts.path, Err: os.ErrNotExist})
+	}
+	if info.IsDir {
+		return fsError(ctx, "readfile", &os.PathError{Op: "read", Path: opts.path, Err: fmt.Errorf("is a directory")})
+	}
+	rc, err := fsys.Open(ctx.Context, name)
+	if err != nil {
+		return fsError(ctx, "readfile", err)
+	}
+	defer rc.Close()
+
+	result, err := readFile(opts, rc, info.Size)
+	if err != nil {
+		return fsError(ctx, "readfile", err)
+	}
+	output := result.Content
+	if result.Binary {
+		output = fmt.Sprintf("%s: binary file (%d bytes)\n", opts.path, result.Size)
+	} else if opts.lineNumbers {
+		output = numberLines(result.Content, result.StartLine)
+	}
+	return fsResult(ctx, "readfile", output, result), nil
+}
+
+// readFile 根据参数从文件内容流中截取结果，读取到 max-bytes 或结束行后即停止。
+// 没有读取到文件末尾时不统计总行数。
+func readFile(opts *readFileOptions, r io.Reader, size int64) (ReadFileResult, error) {
+	result := ReadFileResult{
+		Path: opts.path,
+		Size: size,
+	}
+
+	br := bufio.NewReaderSize(r, binarySniffLen)
+	sniff, err := br.Peek(binarySniffLen)
+	if err != nil && err != io.EOF {
+		return result, err
+	}
+	text, encoding, ok := decodeText(br, sniff, err == nil)
+	if !ok {
+		result.Binary = true
+		return result, nil
+	}
+	result.Encoding = encoding
+
+	lr := &lineReader{r: bufio.NewReader(text)}
+	if opts.offset >= 0 {
+		return lr.readRange(opts, result)
+	}
+	return lr.readLines(opts, result)
+}
+
+// lineReader 从解码后的文本流中读取内容，记录已读取的字节数和行数
+type lineReader struct {
+	r     *bufio.Reader
+	bytes int64 // 已读取的字节数
+	lines int   // 已读取的行数，读取了一部分的行也计算在内
+	last  byte  // 最后读取的字节
+	eof   bool  // 是否已读取到末尾
+}
+
+// consume 记录读取的内容
+func (l *lineReader) consume(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if l.bytes == 0 || l.last == '\n' {
+		l.lines++
+	}
+	l.lines += bytes.Count(data[:len(data)-1], []byte{'\n'})
+	l.bytes += int64(len(data))
+	l.last = data[len(data)-1]
+}
+
+// totalLines 返回文件总行数，未读取到末尾时返回 0
+func (l *lineReader) totalLines() int {
+	if !l.eof {
+		if _, err := l.r.Peek(1); err != io.EOF {
+			return 0
+		}
+	}
+	return l.lines
+}
+
+// readLine 读取一行，最多保留 limit+1 字节，limit 小于 0 时不保留内容
+func (l *lineReader) readLine(limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		l.consume(chunk)
+		if limit >= 0 && len(line) <= limit {
+			line = append(line, chunk[:min(len(chunk), limit+1-len(line))]...)
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			if limit >= 0 && len(line) > limit {
+				return line, nil
+			}
+		case err == io.EOF:
+			l.eof = true
+			return line, nil
+		default:
+			return line, err
+		}
+	}
+}
+
+// readRange 按字节范围读取
+func (l *lineReader) readRange(opts *readFileOptions, result ReadFileResult) (ReadFileResult, error) {
+	for l.bytes < opts.offset {
+		chunk, err := l.r.Peek(int(min(opts.offset-l.bytes, int64(l.r.Size()))))
+		l.consume(chunk)
+		l.r.Discard(len(chunk))
+		if err == io.EOF {
+			l.eof = true
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	// 偏移落在多字节字符中间时，向后对齐到字符边界
+	for {
+		b, err := l.r.Peek(1)
+		if err != nil || utf8.RuneStart(b[0]) {
+			break
+		}
+		l.consume(b)
+		l.r.Discard(1)
+	}
+	result.Offset = l.bytes
+	result.StartLine = l.lines + 1
+	if l.bytes > 0 && l.last != '\n' {
+		result.StartLine = l.lines
+	}
+
+	data, err := io.ReadAll(io.LimitReader(l.r, int64(opts.maxBytes)+1))
+	if err != nil {
+		return result, err
+	}
+	l.consume(data)
+	content := string(data)
+	if len(content) > opts.maxBytes {
+		content = truncateUTF8(content, opts.maxBytes)
+		result.Truncated = true
+	} else {
+		l.eof = true
+	}
+	result.Content = content
+	result.Bytes = len(content)
+	if content != "" {
+		result.EndLine = result.StartLine + strings.Count(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	result.TotalLines = l.totalLines()
+	return result, nil
+}
+
+// readLines 按行范围读取
+func (l *lineReader) readLines(opts *readFileOptions, result ReadFileResult) (ReadFileResult, error) {
+	start, end := opts.startLine, opts.endLine
+	if start == 0 {
+		start = 1
+	}
+	for i := 1; i < start && !l.eof; i++ {
+		if _, err := l.readLine(-1); err != nil {
+			return result, err
+		}
+	}
+	result.StartLine = start
+	result.Offset = l.bytes
+
+	var sb strings.Builder
+	endLine := start - 1
+	for i := start; end == 0 || i <= end; i++ {
+		line, err := l.readLine(opts.maxBytes - sb.Len())
+		if err != nil {
+			return result, err
+		}
+		if len(line) == 0 {
+			break
+		}
+		if sb.Len()+len(line) > opts.maxBytes {
+			// 单行超过上限时截取该行的一部分，否则在行边界截断
+			if sb.Len() == 0 {
+				sb.WriteString(truncateUTF8(string(line), opts.maxBytes))
+				endLine = i
+			}
+			result.Truncated = true
+			break
+		}
+		sb.Write(line)
+		endLine = i
+		if l.eof {
+			break
+		}
+	}
+	if endLine >= start {
+		result.EndLine = endLine
+	}
+	result.Content = sb.String()
+	result.Bytes = sb.Len()
+	if !result.Truncated {
+		result.TotalLines = l.totalLines()
+	}
+	return result, nil
+}
+
+// decodeText 根据文件开头的内容检测编码，返回转换为 UTF-8 的文本流，二进制内容返回 false。
+// sniff 为文件开头的内容，full 表示文件长度超过 sniff。
+func decodeText(r *bufio.Reader, sniff []byte, full bool) (io.Reader, string, bool) {
+	switch {
+	case bytes.HasPrefix(sniff, []byte{0xEF, 0xBB, 0xBF}):
+		r.Discard(3)
+		return r, EncodingUTF8, true
+	case bytes.HasPrefix(sniff, []byte{0xFF, 0xFE}):
+		r.Discard(2)
+		return &utf16Reader{r: r}, EncodingUTF16LE, true
+	case bytes.HasPrefix(sniff, []byte{0xFE, 0xFF}):
+		r.Discard(2)
+		return &utf16Reader{r: r, bigEndian: true}, EncodingUTF16BE, true
+	}
+
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return nil, "", false
+	}
+	if validUTF8Prefix(sniff, full) {
+		return r, EncodingUTF8, true
+	}
+
+	// 非 UTF-8 文本中控制字符较多时视为二进制
+	control := 0
+	for _, b := range sniff {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' && b != '\b' && b != 0x1b {
+			control++
+		}
+	}
+	if control*10 > len(sniff) {
+		return nil, "", false
+	}
+
+	// 按 ISO-8859-1 解码，每个字节对应一个 Unicode 码点
+	return &latin1Reader{r: r}, EncodingLatin1, true
+}
+
+// validUTF8Prefix 判断内容是否为合法的 UTF-8，full 为 true 时允许末尾存在不完整的字符
+func validUTF8Prefix(data []byte, full bool) bool {
+	if full {
+		for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+			if utf8.RuneStart(data[len(data)-i]) {
+				if !utf8.FullRune(data[len(data)-i:]) {
+					data = data[:len(data)-i]
+				}
+				break
+			}
+		}
+	}
+	return utf8.Valid(data)
+}
+
+// utf16Reader 将 UTF-16 内容流转换为 UTF-8
+type utf16Reader struct {
+	r         *bufio.Reader
+	bigEndian bool
+	buf       []byte
+}
+
+// unit 返回下一个 UTF-16 编码单元，consume 为 false 时不消耗
+func (d *utf16Reader) unit(consume bool) (rune, bool) {
+	b, err := d.r.Peek(2)
+	if err != nil {
+		return 0, false
+	}
+	if consume {
+		d.r.Discard(2)
+	}
+	if d.bigEndian {
+		return rune(b[0])<<8 | rune(b[1]), true
+	}
+	return rune(b[1])<<8 | rune(b[0]), true
+}
+
+func (d *utf16Reader) Read(p []byte) (int, error) {
+	for len(d.buf) < len(p) {
+		r, ok := d.unit(true)
+		if !ok {
+			break
+		}
+		if utf16.IsSurrogate(r) {
+			r2, ok := d.unit(false)
+			if dec := utf16.DecodeRune(r, r2); ok && dec != utf8.RuneError {
+				d.unit(true)
+				r = dec
+			} else {
+				r = utf8.RuneError
+			}
+		}
+		d.buf = utf8.AppendRune(d.buf, r)
+	}
+	if len(d.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// latin1Reader 将 ISO-8859-1 内容流转换为 UTF-8
+type latin1Reader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (d *latin1Reader) Read(p []byte) (int, error) {
+	if len(d.buf) == 0 {
+		chunk := make([]byte, max(len(p)/2, 1))
+		n, err := d.r.Read(chunk)
+		for _, b := range chunk[:n] {
+			d.buf = utf8.AppendRune(d.buf, rune(b))
+		}
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// truncateUTF8 将文本截断到不超过 n 字节，且不截断多字节字符
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// numberLines 为每一行添加行号前缀，格式与 cat -n 一致
+func numberLines(content string, start int) string {
+	if start < 1 {
+		start = 1
+	}
+	var sb strings.Builder
+	for i, line := range diff.SplitLines(content) {
+		fmt.Fprintf(&sb, "%6d\t%s", start+i, line)
+	}
+	return sb.String()
+}
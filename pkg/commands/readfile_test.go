@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReadFileArgs(t *testing.T) {
+	opts, err := parseReadFileArgs([]string{"-n", "--max-bytes=10", "a.txt", "9", "10"})
+	require.NoError(t, err)
+	assert.True(t, opts.lineNumbers)
+	assert.Equal(t, 10, opts.maxBytes)
+	assert.Equal(t, 9, opts.startLine)
+	assert.Equal(t, 10, opts.endLine)
+
+	opts, err = parseReadFileArgs([]string{"--offset", "5", "a.txt"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), opts.offset)
+	assert.Equal(t, DefaultReadFileMaxBytes, opts.maxBytes)
+
+	for _, args := range [][]string{
+		{},
+		{"-x", "a.txt"},
+		{"a.txt", "0"},
+		{"a.txt", "10", "9"},
+		{"--offset", "1", "a.txt", "1"},
+		{"--max-bytes"},
+		{"a.txt", "1", "2", "3"},
+	} {
+		_, err := parseReadFileArgs(args)
+		assert.Error(t, err, args)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	var sb strings.Builder
+	for i := 1; i <= 12; i++ {
+		sb.WriteString("line" + string(rune('a'+i-1)) + "\n")
+	}
+	text := []byte(sb.String())
+
+	tests := []struct {
+		name      string
+		opts      readFileOptions
+		data      []byte
+		content   string
+		startLine int
+		endLine   int
+		offset    int64
+		truncated bool
+		binary    bool
+		encoding  string
+	}{
+		{
+			name:      "line range beyond 9",
+			opts:      readFileOptions{startLine: 9, endLine: 10, offset: -1, maxBytes: 100},
+			data:      text,
+			content:   "linei\nlinej\n",
+			startLine: 9,
+			endLine:   10,
+			offset:    48,
+			encoding:  EncodingUTF8,
+		},
+		{
+			name:      "truncated at line boundary",
+			opts:      readFileOptions{offset: -1, maxBytes: 15},
+			data:      text,
+			content:   "linea\nlineb\n",
+			startLine: 1,
+			endLine:   2,
+			truncated: true,
+			encoding:  EncodingUTF8,
+		},
+		{
+			name:      "byte range",
+			opts:      readFileOptions{offset: 8, maxBytes: 6},
+			data:      text,
+			content:   "neb\nli",
+			startLine: 2,
+			endLine:   3,
+			offset:    8,
+			truncated: true,
+			encoding:  EncodingUTF8,
+		},
+		{
+			name:   "binary",
+			opts:   readFileOptions{offset: -1, maxBytes: 100},
+			data:   []byte{0x7f, 'E', 'L', 'F', 0, 1, 2},
+			binary: true,
+		},
+		{
+			name:      "utf-16le with bom",
+			opts:      readFileOptions{offset: -1, maxBytes: 100},
+			data:      []byte{0xFF, 0xFE, 'h', 0, 'i', 0, '\n', 0},
+			content:   "hi\n",
+			startLine: 1,
+			endLine:   1,
+			encoding:  EncodingUTF16LE,
+		},
+		{
+			name:      "latin-1",
+			opts:      readFileOptions{offset: -1, maxBytes: 100},
+			data:      []byte("caf\xe9\n"),
+			content:   "café\n",
+			startLine: 1,
+			endLine:   1,
+			encoding:  EncodingLatin1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := readFile(&tt.opts, bytes.NewReader(tt.data), int64(len(tt.data)))
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tt.data)), result.Size)
+			assert.Equal(t, tt.binary, result.Binary)
+			assert.Equal(t, tt.content, result.Content)
+			assert.Equal(t, len(tt.content), result.Bytes)
+			assert.Equal(t, tt.startLine, result.StartLine)
+			assert.Equal(t, tt.endLine, result.EndLine)
+			assert.Equal(t, tt.offset, result.Offset)
+			assert.Equal(t, tt.truncated, result.Truncated)
+			assert.Equal(t, tt.encoding, result.Encoding)
+		})
+	}
+}
+
+// countingReader 记录被读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestReadFileStopsEarly(t *testing.T) {
+	const size = 8 << 20
+	line := []byte("0123456789abcdef\n")
+	data := bytes.Repeat(line, size/len(line))
+
+	for _, opts := range []readFileOptions{
+		{startLine: 10, endLine: 20, offset: -1, maxBytes: DefaultReadFileMaxBytes},
+		{offset: -1, maxBytes: 1024},
+		{offset: 4096, maxBytes: 1024},
+	} {
+		r := &countingReader{r: bytes.NewReader(data)}
+		result, err := readFile(&opts, r, int64(len(data)))
+		require.NoError(t, err)
+		assert.NotEmpty(t, result.Content)
+		assert.Zero(t, result.TotalLines)
+		assert.Less(t, r.n, int64(size/4), "read %d bytes for %+v", r.n, opts)
+	}
+
+	// 读取到文件末尾时统计总行数
+	result, err := readFile(&readFileOptions{startLine: 2, offset: -1, maxBytes: 100}, strings.NewReader("a\nb\nc"), 5)
+	require.NoError(t, err)
+	assert.Equal(t, "b\nc", result.Content)
+	assert.Equal(t, 3, result.TotalLines)
+	assert.Equal(t, 3, result.EndLine)
+
+	// 偏移落在多字节字符中间时向后对齐
+	result, err = readFile(&readFileOptions{offset: 1, maxBytes: 100}, strings.NewReader("é\nx\n"), 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Offset)
+	assert.Equal(t, "\nx\n", result.Content)
+	assert.Equal(t, 2, result.TotalLines)
+
+	// UTF-16 代理对
+	result, err = readFile(&readFileOptions{offset: -1, maxBytes: 100}, bytes.NewReader([]byte{0xFF, 0xFE, 0x3D, 0xD8, 0x00, 0xDE, '\n', 0}), 8)
+	require.NoError(t, err)
+	assert.Equal(t, "😀\n", result.Content)
+}
+
+func TestReadFileCommandNative(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("one\ntwo\nthree\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.bin"), []byte{0, 1, 2}, 0644))
+
+	result, out, err := runNative(t, root, &ReadFileCommand{}, "-n", "a.txt", "2", "3")
+	require.NoError(t, err)
+	assert.Equal(t, "     2\ttwo\n     3\tthree\n", out)
+	data := result.Data.(ReadFileResult)
+	assert.Equal(t, 3, data.TotalLines)
+	assert.Equal(t, int64(14), data.Size)
+
+	result, out, err = runNative(t, root, &ReadFileCommand{}, "b.bin")
+	require.NoError(t, err)
+	assert.Equal(t, "b.bin: binary file (3 bytes)\n", out)
+	assert.True(t, result.Data.(ReadFileResult).Binary)
+}
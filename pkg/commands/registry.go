@@ -15,6 +15,9 @@ func GetBuiltinCommands() []types.ICommand {
 		&MkdirCommand{},
 		&RmCommand{},
 		&CpCommand{},
+		&MvCommand{},
+		&TouchCommand{},
+		&FindCommand{},
 		&PWDCommand{},
 		&PSCommand{},
 		&TopCommand{},
@@ -33,6 +36,19 @@ func GetBuiltinCommands() []types.ICommand {
 		&GitCommand{},
 		&GoCommand{},
 		&ReadFileCommand{},
+		&WriteCommand{},
+		&EditCommand{},
+		&PatchCommand{},
+		&GrepCommand{},
+		&HeadCommand{},
+		&TailCommand{},
+		&SortCommand{},
+		&UniqCommand{},
+		&SedCommand{},
+		&XargsCommand{},
+		&CurlCommand{},
+		&NetstatCommand{},
+		&IfconfigCommand{},
 	}
 	return cmds
 }
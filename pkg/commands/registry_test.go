@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltinCommandSchemas(t *testing.T) {
+	infos := make(map[string]types.CommandInfo)
+	for _, cmd := range GetBuiltinCommands() {
+		info := cmd.Info()
+		if !assert.NotNil(t, info.Schema, info.Name) {
+			continue
+		}
+		infos[info.Name] = info
+
+		// 只有最后一个位置参数可以是可变参数
+		var positional []types.ArgSpec
+		for _, arg := range info.Schema.Args {
+			assert.NotEmpty(t, arg.Type, "%s %s", info.Name, arg.Name)
+			if arg.Flag == "" {
+				positional = append(positional, arg)
+			}
+		}
+		for i, arg := range positional {
+			if arg.Variadic {
+				assert.Equal(t, len(positional)-1, i, "%s %s", info.Name, arg.Name)
+			}
+		}
+	}
+
+	valid := [][]string{
+		{"ls", "-la", "src"},
+		{"readfile", "-n", "--max-bytes", "100", "a.txt", "1", "20"},
+		{"edit", "--count", "2", "a.go", "-old", "-new"},
+		{"patch", "-p1", "--dry-run", "--- a\n+++ b\n"},
+		{"grep", "-rn", "-e", "foo", "--include=*.go", "src"},
+		{"find", ".", "-name", "*.go", "-type", "f", "-maxdepth", "2"},
+		{"head", "-5", "a.txt"},
+		{"tail", "-n", "+3", "a.txt"},
+		{"sort", "-k2", "-t,", "a.csv"},
+		{"kill", "-9", "1234"},
+		{"git", "commit", "-m", "msg"},
+	}
+	for _, args := range valid {
+		assert.NoError(t, types.ValidateArgs(infos[args[0]], args[1:]), "%v", args)
+	}
+
+	invalid := [][]string{
+		{"cat"},
+		{"mkdir", "-x", "dir"},
+		{"readfile", "a.txt", "one"},
+		{"edit", "a.go", "old"},
+		{"find", ".", "-maxdepth", "deep"},
+		{"head", "-n", "ten", "a.txt"},
+		{"curl"},
+	}
+	for _, args := range invalid {
+		assert.Error(t, types.ValidateArgs(infos[args[0]], args[1:]), "%v", args)
+	}
+}
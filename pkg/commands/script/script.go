@@ -146,6 +146,8 @@ func (sm *ScriptManager) ListCommands() []types.CommandInfo {
 			Name:        script.Meta.Name,
 			Description: script.Meta.Description,
 			Usage:       sm.generateUsage(script),
+			Category:    "script",
+			Schema:      script.schema(),
 		})
 	}
 	return commands
@@ -258,6 +260,21 @@ func (sm *ScriptManager) generateExamples(script *Script) []string {
 	return examples
 }
 
+// schema 根据参数定义生成参数结构，脚本参数均为带值的选项
+func (s *Script) schema() *types.ArgSchema {
+	schema := &types.ArgSchema{Args: make([]types.ArgSpec, 0, len(s.Meta.Args))}
+	for _, arg := range s.Meta.Args {
+		schema.Args = append(schema.Args, types.ArgSpec{
+			Name:        arg.Name,
+			Flag:        arg.Flag,
+			Type:        types.ArgTypeString,
+			Required:    arg.Required,
+			Description: arg.Description,
+		})
+	}
+	return schema
+}
+
 // ValidateArgs 验证参数
 func (s *Script) ValidateArgs(args []string) error {
 	// 检查必需参数
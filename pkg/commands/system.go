@@ -15,6 +15,7 @@ func (c *PSCommand) Info() types.CommandInfo {
 		Description: "Report process status",
 		Usage:       "ps [options]",
 		Category:    "process",
+		Schema:      systemArgs("args", "Ps options", false),
 	}
 }
 
@@ -35,6 +36,7 @@ func (c *TopCommand) Info() types.CommandInfo {
 		Description: "Display system processes",
 		Usage:       "top [options]",
 		Category:    "process",
+		Schema:      systemArgs("args", "Top options", false),
 	}
 }
 
@@ -55,6 +57,7 @@ func (c *DFCommand) Info() types.CommandInfo {
 		Description: "Report file system disk space usage",
 		Usage:       "df [options] [file...]",
 		Category:    "system",
+		Schema:      systemArgs("file", "Files whose file system is reported", false),
 	}
 }
 
@@ -78,6 +81,7 @@ func (c *UNameCommand) Info() types.CommandInfo {
 		Description: "Print system information",
 		Usage:       "uname [options]",
 		Category:    "system",
+		Schema:      systemArgs("args", "Uname options", false),
 	}
 }
 
@@ -98,6 +102,7 @@ func (c *EnvCommand) Info() types.CommandInfo {
 		Description: "Set or print environment variables",
 		Usage:       "env [name[=value] ...]",
 		Category:    "system",
+		Schema:      systemArgs("args", "Variables to set and command to run", false),
 	}
 }
 
@@ -118,6 +123,7 @@ func (c *KillCommand) Info() types.CommandInfo {
 		Description: "Terminate processes",
 		Usage:       "kill [options] pid...",
 		Category:    "process",
+		Schema:      systemArgs("pid", "Process IDs or job specs", true),
 	}
 }
 
@@ -140,3 +146,13 @@ func (c *KillCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult,
 
 	return ctx.Executor.ExecuteCommand(ctx)
 }
+
+// systemArgs 返回透传给系统命令的参数结构，只声明一个可变位置参数，选项不做校验
+func systemArgs(name, description string, required bool) *types.ArgSchema {
+	return &types.ArgSchema{
+		Args: []types.ArgSpec{
+			{Name: name, Type: types.ArgTypeString, Required: required, Variadic: true, Description: description},
+		},
+		Passthrough: true,
+	}
+}
@@ -0,0 +1,710 @@
+// Package commands 实现了 RunShell 的内置命令。
+// 本文件实现了 grep、head、tail、sort、uniq 等文本处理命令。
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iamlongalong/runshell/pkg/diff"
+	"github.com/iamlongalong/runshell/pkg/fs"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// 文本命令只实现常用选项，遇到不支持的选项时退回到同名系统命令，保证与原有行为兼容。
+
+// errUnsupported 表示参数中包含原生实现不支持的选项
+var errUnsupported = errors.New("unsupported option")
+
+// stdinName 输出中标准输入的名称
+const stdinName = "(standard input)"
+
+// shortOptions 保存解析后的短选项，同一选项出现多次时保留所有值
+type shortOptions map[rune][]string
+
+// Has 选项是否出现
+func (o shortOptions) Has(r rune) bool {
+	return len(o[r]) > 0
+}
+
+// Value 返回选项最后一次出现时的值
+func (o shortOptions) Value(r rune) string {
+	if v := o[r]; len(v) > 0 {
+		return v[len(v)-1]
+	}
+	return ""
+}
+
+// parseShortOptions 解析 POSIX 风格的短选项。布尔选项可以合并书写（如 -in），
+// valueFlags 中的选项需要参数值，可以紧跟（-n5）或作为下一个参数（-n 5）。
+// 选项和操作数可以交错出现，"--" 之后的参数都视为操作数，单独的 "-" 表示标准输入。
+func parseShortOptions(args []string, boolFlags, valueFlags string) (shortOptions, []string, error) {
+	opts := make(shortOptions)
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			operands = append(operands, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			operands = append(operands, arg)
+			continue
+		}
+		if arg[1] == '-' {
+			return nil, nil, fmt.Errorf("%w: %s", errUnsupported, arg)
+		}
+
+		for j, r := range arg[1:] {
+			switch {
+			case strings.ContainsRune(boolFlags, r):
+				opts[r] = append(opts[r], "true")
+			case strings.ContainsRune(valueFlags, r):
+				value := arg[j+2:]
+				if value == "" {
+					if i+1 >= len(args) {
+						return nil, nil, fmt.Errorf("option requires an argument -- '%c'", r)
+					}
+					i++
+					value = args[i]
+				}
+				opts[r] = append(opts[r], value)
+			default:
+				return nil, nil, fmt.Errorf("%w: -%c", errUnsupported, r)
+			}
+			if strings.ContainsRune(valueFlags, r) {
+				break
+			}
+		}
+	}
+	return opts, operands, nil
+}
+
+// expandCountOption 将 head/tail 的 -NUM 简写展开为 -n NUM
+func expandCountOption(args []string) []string {
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		if len(arg) > 1 && arg[0] == '-' {
+			if _, err := strconv.Atoi(arg[1:]); err == nil {
+				expanded = append(expanded, "-n", arg[1:])
+				continue
+			}
+		}
+		expanded = append(expanded, arg)
+	}
+	return expanded
+}
+
+// textInput 表示一个文本命令的输入
+type textInput struct {
+	name string
+	data []byte
+}
+
+// readInputs 读取命令的输入，没有操作数或操作数为 "-" 时读取标准输入。
+// 需要读取文件但执行器不支持文件系统访问时返回 false。
+func readInputs(ctx *types.ExecuteContext, operands []string) ([]textInput, bool, error) {
+	if len(operands) == 0 {
+		operands = []string{"-"}
+	}
+
+	var fsys types.FileSystem
+	inputs := make([]textInput, 0, len(operands))
+	for _, name := range operands {
+		if name == "-" {
+			var data []byte
+			if ctx.Options != nil && ctx.Options.Stdin != nil {
+				var err error
+				if data, err = io.ReadAll(ctx.Options.Stdin); err != nil {
+					return nil, true, fmt.Errorf("failed to read stdin: %v", err)
+				}
+			}
+			inputs = append(inputs, textInput{name: stdinName, data: data})
+			continue
+		}
+
+		if fsys == nil {
+			var ok bool
+			var err error
+			fsys, ok, err = fileSystem(ctx)
+			if !ok {
+				return nil, false, nil
+			}
+			if err != nil {
+				return nil, true, err
+			}
+		}
+		data, err := fsys.ReadFile(ctx.Context, name)
+		if err != nil {
+			return nil, true, err
+		}
+		inputs = append(inputs, textInput{name: name, data: data})
+	}
+	return inputs, true, nil
+}
+
+// isBinary 检测内容是否为二进制
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffLen {
+		data = data[:binarySniffLen]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// textLines 将内容按行拆分，行尾不包含换行符
+func textLines(data []byte) []string {
+	lines := diff.SplitLines(string(data))
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\n")
+	}
+	return lines
+}
+
+// GrepMatch 表示 grep 匹配到的一行
+// swagger:model
+type GrepMatch struct {
+	Path   string `json:"path"`             // 文件路径，标准输入为 "(standard input)"
+	Line   int    `json:"line"`             // 行号（从 1 开始）
+	Column int    `json:"column,omitempty"` // 首个匹配的列号（字节，从 1 开始），-v 时为 0
+	Text   string `json:"text"`             // 行内容，不包含换行符
+}
+
+// GrepResult 表示 grep 命令的结果
+// swagger:model
+type GrepResult struct {
+	Pattern string      `json:"pattern"` // 匹配模式，多个模式以 "|" 连接
+	Matches []GrepMatch `json:"matches"` // 匹配的行
+	Files   []string    `json:"files"`   // 包含匹配的文件
+	Count   int         `json:"count"`   // 匹配的总行数
+}
+
+// GrepCommand 实现了 grep 命令。
+// 使用 Go 正则语法（RE2）在文件或标准输入中搜索，并返回结构化的匹配结果。
+type GrepCommand struct{}
+
+func (c *GrepCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "grep",
+		Description: "Search for patterns in files",
+		Usage:       "grep [-i] [-v] [-n] [-c] [-l] [-r] [-w] [-F] [-H|-h] [-m NUM] [-e pattern] pattern [file...]",
+		Category:    "text",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "ignore_case", Flag: "-i", Type: types.ArgTypeBool, Description: "Ignore case distinctions"},
+				{Name: "invert", Flag: "-v", Type: types.ArgTypeBool, Description: "Select non-matching lines"},
+				{Name: "line_number", Flag: "-n", Type: types.ArgTypeBool, Description: "Prefix lines with line numbers"},
+				{Name: "count", Flag: "-c", Type: types.ArgTypeBool, Description: "Print the number of matching lines"},
+				{Name: "files_with_matches", Flag: "-l", Type: types.ArgTypeBool, Description: "Print only names of files with matches"},
+				{Name: "recursive", Flag: "-r", Aliases: []string{"-R"}, Type: types.ArgTypeBool, Description: "Search directories recursively"},
+				{Name: "word", Flag: "-w", Type: types.ArgTypeBool, Description: "Match whole words only"},
+				{Name: "fixed", Flag: "-F", Type: types.ArgTypeBool, Description: "Treat patterns as fixed strings"},
+				{Name: "extended", Flag: "-E", Type: types.ArgTypeBool, Description: "Extended regular expressions (default)"},
+				{Name: "with_filename", Flag: "-H", Type: types.ArgTypeBool, Description: "Always print file names"},
+				{Name: "no_filename", Flag: "-h", Type: types.ArgTypeBool, Description: "Never print file names"},
+				{Name: "silent", Flag: "-s", Type: types.ArgTypeBool, Description: "Suppress errors about unreadable files"},
+				{Name: "quiet", Flag: "-q", Type: types.ArgTypeBool, Description: "Suppress output"},
+				{Name: "max_count", Flag: "-m", Type: types.ArgTypeInt, Description: "Stop after NUM matching lines per file"},
+				{Name: "regexp", Flag: "-e", Type: types.ArgTypeString, Description: "Pattern, may be repeated"},
+				{Name: "pattern", Type: types.ArgTypeString, Description: "Pattern, required unless -e is given"},
+				{Name: "file", Type: types.ArgTypePath, Variadic: true, Description: "Files to search, stdin when omitted"},
+			},
+			Passthrough: true,
+		},
+	}
+}
+
+// Execute 执行 grep 命令。
+// 与 GNU grep 一致，没有匹配时退出码为 1，但不返回错误。
+func (c *GrepCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	opts, operands, err := parseShortOptions(ctx.Command.Args, "ivnclrRwFEHhsq", "em")
+	if errors.Is(err, errUnsupported) {
+		return runSystem(ctx, "grep", ctx.Command.Args)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := opts['e']
+	if len(patterns) == 0 {
+		if len(operands) == 0 {
+			return nil, fmt.Errorf("no pattern specified")
+		}
+		patterns, operands = operands[:1], operands[1:]
+	}
+	re, err := compileGrepPattern(patterns, opts.Has('F'), opts.Has('i'), opts.Has('w'))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	maxCount := -1
+	if opts.Has('m') {
+		if maxCount, err = strconv.Atoi(opts.Value('m')); err != nil || maxCount < 0 {
+			return nil, fmt.Errorf("invalid max count: %s", opts.Value('m'))
+		}
+	}
+
+	recursive := opts.Has('r') || opts.Has('R')
+	if recursive && len(operands) == 0 {
+		operands = []string{"."}
+	}
+
+	var inputs []textInput
+	var ok bool
+	if recursive {
+		inputs, ok, err = grepWalk(ctx, operands, opts.Has('s'))
+	} else {
+		inputs, ok, err = readInputs(ctx, operands)
+	}
+	if !ok {
+		return runSystem(ctx, "grep", ctx.Command.Args)
+	}
+	if err != nil {
+		return fsError(ctx, "grep", err)
+	}
+
+	withName := (recursive || len(inputs) > 1) && !opts.Has('h') || opts.Has('H')
+	result := GrepResult{Pattern: strings.Join(patterns, "|"), Matches: []GrepMatch{}, Files: []string{}}
+	var out strings.Builder
+	for _, input := range inputs {
+		matches := grepInput(re, input, opts.Has('v'), maxCount)
+		result.Matches = append(result.Matches, matches...)
+		result.Count += len(matches)
+		if len(matches) > 0 {
+			result.Files = append(result.Files, input.name)
+		}
+		writeGrepOutput(&out, opts, input, matches, withName)
+	}
+
+	output := out.String()
+	if opts.Has('q') {
+		output = ""
+	}
+	res := fsResult(ctx, "grep", output, result)
+	if result.Count == 0 {
+		res.ExitCode = 1
+	}
+	return res, nil
+}
+
+// compileGrepPattern 将多个模式合并为一个正则表达式
+func compileGrepPattern(patterns []string, fixed, ignoreCase, word bool) (*regexp.Regexp, error) {
+	parts := make([]string, len(patterns))
+	for i, p := range patterns {
+		if fixed {
+			p = regexp.QuoteMeta(p)
+		}
+		parts[i] = "(?:" + p + ")"
+	}
+	expr := strings.Join(parts, "|")
+	if word {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// grepWalk 递归收集目录下的文件作为输入，跳过符号链接和二进制文件。
+// quiet 为 true 时忽略无法读取的文件。
+func grepWalk(ctx *types.ExecuteContext, roots []string, quiet bool) ([]textInput, bool, error) {
+	fsys, ok, err := fileSystem(ctx)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+
+	var inputs []textInput
+	for _, root := range roots {
+		err := fs.Walk(ctx.Context, fsys, root, func(name string, info *types.FileInfo, depth int, err error) error {
+			if err != nil {
+				if quiet && depth > 0 {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir || (info.Link != "" && depth > 0) {
+				return nil
+			}
+			data, err := fsys.ReadFile(ctx.Context, name)
+			if err != nil {
+				if quiet {
+					return nil
+				}
+				return err
+			}
+			if depth > 0 && isBinary(data) {
+				return nil
+			}
+			inputs = append(inputs, textInput{name: name, data: data})
+			return nil
+		})
+		if err != nil {
+			return nil, true, err
+		}
+	}
+	return inputs, true, nil
+}
+
+// grepInput 在单个输入中查找匹配的行，maxCount 小于 0 表示不限制
+func grepInput(re *regexp.Regexp, input textInput, invert bool, maxCount int) []GrepMatch {
+	var matches []GrepMatch
+	for i, line := range textLines(input.data) {
+		if maxCount >= 0 && len(matches) >= maxCount {
+			break
+		}
+		loc := re.FindStringIndex(line)
+		if (loc != nil) == invert {
+			continue
+		}
+		match := GrepMatch{Path: input.name, Line: i + 1, Text: line}
+		if loc != nil {
+			match.Column = loc[0] + 1
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// writeGrepOutput 以 GNU grep 的格式输出单个输入的匹配结果
+func writeGrepOutput(out *strings.Builder, opts shortOptions, input textInput, matches []GrepMatch, withName bool) {
+	prefix := ""
+	if withName {
+		prefix = input.name + ":"
+	}
+
+	switch {
+	case opts.Has('l'):
+		if len(matches) > 0 {
+			out.WriteString(input.name + "\n")
+		}
+	case opts.Has('c'):
+		fmt.Fprintf(out, "%s%d\n", prefix, len(matches))
+	case len(matches) > 0 && isBinary(input.data):
+		fmt.Fprintf(out, "Binary file %s matches\n", input.name)
+	default:
+		for _, m := range matches {
+			if opts.Has('n') {
+				fmt.Fprintf(out, "%s%d:%s\n", prefix, m.Line, m.Text)
+			} else {
+				fmt.Fprintf(out, "%s%s\n", prefix, m.Text)
+			}
+		}
+	}
+}
+
+// HeadCommand 实现了 head 命令。
+// 用于输出文件开头的若干行或字节。
+type HeadCommand struct{}
+
+func (c *HeadCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "head",
+		Description: "Output the first part of files",
+		Usage:       "head [-n NUM | -c NUM] [-q] [file...]",
+		Category:    "text",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "lines", Flag: "-n", Type: types.ArgTypeInt, Default: "10", Description: "Number of lines (default 10)"},
+				{Name: "bytes", Flag: "-c", Type: types.ArgTypeInt, Description: "Number of bytes"},
+				{Name: "quiet", Flag: "-q", Type: types.ArgTypeBool, Description: "Never print headers"},
+				{Name: "verbose", Flag: "-v", Type: types.ArgTypeBool, Description: "Always print headers"},
+				{Name: "file", Type: types.ArgTypePath, Variadic: true, Description: "Input files, stdin when omitted"},
+			},
+			Passthrough: true,
+		},
+	}
+}
+
+// Execute 执行 head 命令。
+func (c *HeadCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	return executeHeadTail(ctx, "head")
+}
+
+// TailCommand 实现了 tail 命令。
+// 用于输出文件末尾的若干行或字节，-f 等持续跟踪的用法由系统命令完成。
+type TailCommand struct{}
+
+func (c *TailCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "tail",
+		Description: "Output the last part of files",
+		Usage:       "tail [-n [+]NUM | -c NUM] [-q] [file...]",
+		Category:    "text",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "lines", Flag: "-n", Type: types.ArgTypeString, Default: "10", Description: "Number of lines, +NUM starts at line NUM (default 10)"},
+				{Name: "bytes", Flag: "-c", Type: types.ArgTypeString, Description: "Number of bytes"},
+				{Name: "quiet", Flag: "-q", Type: types.ArgTypeBool, Description: "Never print headers"},
+				{Name: "verbose", Flag: "-v", Type: types.ArgTypeBool, Description: "Always print headers"},
+				{Name: "file", Type: types.ArgTypePath, Variadic: true, Description: "Input files, stdin when omitted"},
+			},
+			Passthrough: true,
+		},
+	}
+}
+
+// Execute 执行 tail 命令。
+func (c *TailCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	return executeHeadTail(ctx, "tail")
+}
+
+// executeHeadTail 实现 head 和 tail 的共同逻辑
+func executeHeadTail(ctx *types.ExecuteContext, name string) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	opts, operands, err := parseShortOptions(expandCountOption(ctx.Command.Args), "qv", "nc")
+	if errors.Is(err, errUnsupported) {
+		return runSystem(ctx, name, ctx.Command.Args)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// tail -n +NUM 表示从第 NUM 行开始输出
+	count, bytesMode, fromStart := 10, opts.Has('c'), false
+	value := opts.Value('n')
+	if bytesMode {
+		value = opts.Value('c')
+	}
+	if value != "" {
+		if name == "tail" && strings.HasPrefix(value, "+") {
+			fromStart, value = true, value[1:]
+		}
+		if count, err = strconv.Atoi(value); err != nil || count < 0 {
+			// head -n -NUM 等形式交给系统命令处理
+			return runSystem(ctx, name, ctx.Command.Args)
+		}
+	}
+
+	inputs, ok, err := readInputs(ctx, operands)
+	if !ok {
+		return runSystem(ctx, name, ctx.Command.Args)
+	}
+	if err != nil {
+		return fsError(ctx, name, err)
+	}
+
+	var out strings.Builder
+	for i, input := range inputs {
+		if (len(inputs) > 1 && !opts.Has('q')) || opts.Has('v') {
+			if i > 0 {
+				out.WriteString("\n")
+			}
+			fmt.Fprintf(&out, "==> %s <==\n", input.name)
+		}
+
+		if bytesMode {
+			data := input.data
+			switch {
+			case name == "head":
+				data = data[:min(count, len(data))]
+			case fromStart:
+				data = data[min(max(count-1, 0), len(data)):]
+			default:
+				data = data[len(data)-min(count, len(data)):]
+			}
+			out.Write(data)
+			continue
+		}
+
+		lines := diff.SplitLines(string(input.data))
+		switch {
+		case name == "head":
+			lines = lines[:min(count, len(lines))]
+		case fromStart:
+			lines = lines[min(max(count-1, 0), len(lines)):]
+		default:
+			lines = lines[len(lines)-min(count, len(lines)):]
+		}
+		out.WriteString(strings.Join(lines, ""))
+	}
+
+	return fsResult(ctx, name, out.String(), nil), nil
+}
+
+// SortCommand 实现了 sort 命令。
+// 用于对文本行排序，-k 等字段排序由系统命令完成。
+type SortCommand struct{}
+
+func (c *SortCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "sort",
+		Description: "Sort lines of text",
+		Usage:       "sort [-r] [-n] [-u] [-f] [file...]",
+		Category:    "text",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "reverse", Flag: "-r", Type: types.ArgTypeBool, Description: "Reverse the result"},
+				{Name: "numeric", Flag: "-n", Type: types.ArgTypeBool, Description: "Compare by leading numeric value"},
+				{Name: "unique", Flag: "-u", Type: types.ArgTypeBool, Description: "Output only the first of equal lines"},
+				{Name: "ignore_case", Flag: "-f", Type: types.ArgTypeBool, Description: "Fold lower case to upper case"},
+				{Name: "file", Type: types.ArgTypePath, Variadic: true, Description: "Input files, stdin when omitted"},
+			},
+			Passthrough: true,
+		},
+	}
+}
+
+// Execute 执行 sort 命令。
+func (c *SortCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	opts, operands, err := parseShortOptions(ctx.Command.Args, "rnuf", "")
+	if errors.Is(err, errUnsupported) {
+		return runSystem(ctx, "sort", ctx.Command.Args)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, ok, err := readInputs(ctx, operands)
+	if !ok {
+		return runSystem(ctx, "sort", ctx.Command.Args)
+	}
+	if err != nil {
+		return fsError(ctx, "sort", err)
+	}
+
+	var lines []string
+	for _, input := range inputs {
+		lines = append(lines, textLines(input.data)...)
+	}
+
+	key := func(s string) string {
+		if opts.Has('f') {
+			return strings.ToUpper(s)
+		}
+		return s
+	}
+	compare := func(a, b string) int {
+		if opts.Has('n') {
+			if na, nb := leadingNumber(a), leadingNumber(b); na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+			if opts.Has('u') {
+				return 0
+			}
+		}
+		return strings.Compare(key(a), key(b))
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if opts.Has('r') {
+			return compare(lines[j], lines[i]) < 0
+		}
+		return compare(lines[i], lines[j]) < 0
+	})
+
+	var out strings.Builder
+	for i, line := range lines {
+		if opts.Has('u') && i > 0 && compare(lines[i-1], line) == 0 {
+			continue
+		}
+		out.WriteString(line + "\n")
+	}
+	return fsResult(ctx, "sort", out.String(), nil), nil
+}
+
+// leadingNumber 解析行首的数字，与 sort -n 一致，没有数字时视为 0
+func leadingNumber(s string) float64 {
+	s = strings.TrimLeft(s, " \t")
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.' || (end == 0 && s[end] == '-')) {
+		end++
+	}
+	n, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// UniqCommand 实现了 uniq 命令。
+// 用于合并相邻的重复行。
+type UniqCommand struct{}
+
+func (c *UniqCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "uniq",
+		Description: "Report or omit repeated lines",
+		Usage:       "uniq [-c] [-d] [-u] [-i] [file]",
+		Category:    "text",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "count", Flag: "-c", Type: types.ArgTypeBool, Description: "Prefix lines with the number of occurrences"},
+				{Name: "repeated", Flag: "-d", Type: types.ArgTypeBool, Description: "Only print duplicate lines"},
+				{Name: "unique", Flag: "-u", Type: types.ArgTypeBool, Description: "Only print unique lines"},
+				{Name: "ignore_case", Flag: "-i", Type: types.ArgTypeBool, Description: "Ignore case when comparing"},
+				{Name: "input", Type: types.ArgTypePath, Description: "Input file, stdin when omitted"},
+				{Name: "output", Type: types.ArgTypePath, Description: "Output file"},
+			},
+			Passthrough: true,
+		},
+	}
+}
+
+// Execute 执行 uniq 命令。
+func (c *UniqCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	opts, operands, err := parseShortOptions(ctx.Command.Args, "cdui", "")
+	if errors.Is(err, errUnsupported) || len(operands) > 1 {
+		// 指定输出文件等用法交给系统命令处理
+		return runSystem(ctx, "uniq", ctx.Command.Args)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, ok, err := readInputs(ctx, operands)
+	if !ok {
+		return runSystem(ctx, "uniq", ctx.Command.Args)
+	}
+	if err != nil {
+		return fsError(ctx, "uniq", err)
+	}
+
+	equal := func(a, b string) bool {
+		if opts.Has('i') {
+			return strings.EqualFold(a, b)
+		}
+		return a == b
+	}
+
+	var out strings.Builder
+	lines := textLines(inputs[0].data)
+	for i := 0; i < len(lines); {
+		j := i + 1
+		for j < len(lines) && equal(lines[i], lines[j]) {
+			j++
+		}
+		count := j - i
+		if (!opts.Has('d') || count > 1) && (!opts.Has('u') || count == 1) {
+			if opts.Has('c') {
+				fmt.Fprintf(&out, "%7d %s\n", count, lines[i])
+			} else {
+				out.WriteString(lines[i] + "\n")
+			}
+		}
+		i = j
+	}
+	return fsResult(ctx, "uniq", out.String(), nil), nil
+}
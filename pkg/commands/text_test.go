@@ -0,0 +1,221 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runWithStdin 使用标准输入执行内置命令
+func runWithStdin(t *testing.T, root string, cmd types.ICommand, stdin string, args ...string) (*types.ExecuteResult, string, error) {
+	stdout := &bytes.Buffer{}
+	ctx := &types.ExecuteContext{
+		Context:  context.Background(),
+		Command:  types.Command{Command: cmd.Info().Name, Args: args},
+		Executor: &fsExecutor{MockExecutor: types.NewMockExecutor(), root: root},
+		Options: &types.ExecuteOptions{
+			WorkDir: root,
+			Stdin:   strings.NewReader(stdin),
+			Stdout:  stdout,
+		},
+	}
+	result, err := cmd.Execute(ctx)
+	return result, stdout.String(), err
+}
+
+func TestParseShortOptions(t *testing.T) {
+	opts, operands, err := parseShortOptions([]string{"-in5", "pat", "-e", "x", "file", "--", "-v"}, "iv", "ne")
+	require.NoError(t, err)
+	assert.True(t, opts.Has('i'))
+	assert.Equal(t, "5", opts.Value('n'))
+	assert.Equal(t, "x", opts.Value('e'))
+	assert.False(t, opts.Has('v'))
+	assert.Equal(t, []string{"pat", "file", "-v"}, operands)
+
+	_, _, err = parseShortOptions([]string{"-z"}, "iv", "")
+	assert.ErrorIs(t, err, errUnsupported)
+	_, _, err = parseShortOptions([]string{"--color=auto"}, "iv", "")
+	assert.ErrorIs(t, err, errUnsupported)
+	_, _, err = parseShortOptions([]string{"-n"}, "", "n")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errUnsupported)
+}
+
+func TestGrepCommand(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "src/sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "src/a.go"), []byte("package a\nfunc Foo() {}\nfunc bar() {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "src/sub/b.go"), []byte("package sub\n// foo helper\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "src/sub/bin.dat"), []byte("foo\x00"), 0644))
+
+	t.Run("single file", func(t *testing.T) {
+		result, output, err := runNative(t, root, &GrepCommand{}, "-n", "func", "src/a.go")
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Equal(t, "2:func Foo() {}\n3:func bar() {}\n", output)
+
+		data := result.Data.(GrepResult)
+		assert.Equal(t, 2, data.Count)
+		assert.Equal(t, GrepMatch{Path: "src/a.go", Line: 2, Column: 1, Text: "func Foo() {}"}, data.Matches[0])
+	})
+
+	t.Run("recursive ignore case", func(t *testing.T) {
+		result, output, err := runNative(t, root, &GrepCommand{}, "-ri", "foo", "src")
+		require.NoError(t, err)
+		assert.Equal(t, "src/a.go:func Foo() {}\nsrc/sub/b.go:// foo helper\n", output)
+		assert.Equal(t, []string{"src/a.go", "src/sub/b.go"}, result.Data.(GrepResult).Files)
+	})
+
+	t.Run("files with matches", func(t *testing.T) {
+		_, output, err := runNative(t, root, &GrepCommand{}, "-rl", "-e", "package", "-e", "helper", "src")
+		require.NoError(t, err)
+		assert.Equal(t, "src/a.go\nsrc/sub/b.go\n", output)
+	})
+
+	t.Run("count invert", func(t *testing.T) {
+		_, output, err := runNative(t, root, &GrepCommand{}, "-vc", "func", "src/a.go", "src/sub/b.go")
+		require.NoError(t, err)
+		assert.Equal(t, "src/a.go:1\nsrc/sub/b.go:2\n", output)
+	})
+
+	t.Run("fixed word", func(t *testing.T) {
+		result, _, err := runWithStdin(t, root, &GrepCommand{}, "a.b\naxb\na.bc\n", "-wF", "a.b")
+		require.NoError(t, err)
+		data := result.Data.(GrepResult)
+		require.Equal(t, 1, data.Count)
+		assert.Equal(t, stdinName, data.Matches[0].Path)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		result, output, err := runNative(t, root, &GrepCommand{}, "missing", "src/a.go")
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.ExitCode)
+		assert.Empty(t, output)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		result, _, err := runNative(t, root, &GrepCommand{}, "x", "nope.txt")
+		assert.Error(t, err)
+		assert.Equal(t, 1, result.ExitCode)
+	})
+}
+
+func TestHeadTailCommand(t *testing.T) {
+	root := t.TempDir()
+	var content strings.Builder
+	for i := 1; i <= 20; i++ {
+		content.WriteString(strings.Repeat("x", i) + "\n")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte(content.String()), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.txt"), []byte("one\ntwo"), 0644))
+
+	_, output, err := runNative(t, root, &HeadCommand{}, "-n", "2", "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "x\nxx\n", output)
+
+	_, output, err = runNative(t, root, &HeadCommand{}, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, 10, strings.Count(output, "\n"))
+
+	_, output, err = runNative(t, root, &HeadCommand{}, "-c3", "b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "one", output)
+
+	_, output, err = runNative(t, root, &TailCommand{}, "-1", "a.txt", "b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "==> a.txt <==\n"+strings.Repeat("x", 20)+"\n\n==> b.txt <==\ntwo", output)
+
+	_, output, err = runNative(t, root, &TailCommand{}, "-n", "+19", "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 19)+"\n"+strings.Repeat("x", 20)+"\n", output)
+
+	_, output, err = runWithStdin(t, root, &TailCommand{}, "a\nb\nc\n", "-n2")
+	require.NoError(t, err)
+	assert.Equal(t, "b\nc\n", output)
+}
+
+func TestSortUniqCommand(t *testing.T) {
+	root := t.TempDir()
+	input := "10 ten\n9 nine\nb\nA\nb\n100 hundred\n"
+
+	_, output, err := runWithStdin(t, root, &SortCommand{}, input)
+	require.NoError(t, err)
+	assert.Equal(t, "10 ten\n100 hundred\n9 nine\nA\nb\nb\n", output)
+
+	_, output, err = runWithStdin(t, root, &SortCommand{}, input, "-nr")
+	require.NoError(t, err)
+	assert.Equal(t, "100 hundred\n10 ten\n9 nine\nb\nb\nA\n", output)
+
+	_, output, err = runWithStdin(t, root, &SortCommand{}, input, "-u", "-f")
+	require.NoError(t, err)
+	assert.Equal(t, "10 ten\n100 hundred\n9 nine\nA\nb\n", output)
+
+	_, output, err = runWithStdin(t, root, &UniqCommand{}, "a\na\nb\nA\nc\nc\nc\n", "-c")
+	require.NoError(t, err)
+	assert.Equal(t, "      2 a\n      1 b\n      1 A\n      3 c\n", output)
+
+	_, output, err = runWithStdin(t, root, &UniqCommand{}, "a\nA\nb\nc\nC\n", "-iu")
+	require.NoError(t, err)
+	assert.Equal(t, "b\n", output)
+
+	_, output, err = runWithStdin(t, root, &UniqCommand{}, "a\na\nb\n", "-d")
+	require.NoError(t, err)
+	assert.Equal(t, "a\n", output)
+}
+
+func TestFindCommand(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "src/sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "src/a.go"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "src/sub/b.go"), []byte("b"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "src/sub/c.txt"), []byte("c"), 0644))
+
+	result, output, err := runNative(t, root, &FindCommand{}, "src", "-name", "*.go")
+	require.NoError(t, err)
+	assert.Equal(t, "src/a.go\nsrc/sub/b.go\n", output)
+	data := result.Data.(FindResult)
+	require.Len(t, data.Entries, 2)
+	assert.Equal(t, "src/sub/b.go", data.Entries[1].Path)
+	assert.Equal(t, int64(1), data.Entries[1].Size)
+
+	_, output, err = runNative(t, root, &FindCommand{}, "-type", "d")
+	require.NoError(t, err)
+	assert.Equal(t, ".\n./src\n./src/sub\n", output)
+
+	_, output, err = runNative(t, root, &FindCommand{}, "src", "-maxdepth", "1", "-mindepth", "1", "-type", "f")
+	require.NoError(t, err)
+	assert.Equal(t, "src/a.go\n", output)
+
+	_, output, err = runNative(t, root, &FindCommand{}, ".", "-iname", "C.TXT")
+	require.NoError(t, err)
+	assert.Equal(t, "./src/sub/c.txt\n", output)
+
+	_, _, err = runNative(t, root, &FindCommand{}, "missing")
+	assert.Error(t, err)
+}
+
+func TestUnsupportedOptionFallback(t *testing.T) {
+	var executed types.Command
+	mockExec := types.NewMockExecutor()
+	mockExec.ExecuteFunc = func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+		executed = ctx.Command
+		return &types.ExecuteResult{CommandName: ctx.Command.Command}, nil
+	}
+	ctx := &types.ExecuteContext{
+		Context:  context.Background(),
+		Command:  types.Command{Command: "sort", Args: []string{"-k2", "file"}},
+		Executor: &fsExecutor{MockExecutor: mockExec, root: t.TempDir()},
+		Options:  &types.ExecuteOptions{},
+	}
+	result, err := (&SortCommand{}).Execute(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, result.Data)
+	assert.Equal(t, types.Command{Command: "sort", Args: []string{"-k2", "file"}}, executed)
+}
@@ -16,6 +16,7 @@ func (c *WgetCommand) Info() types.CommandInfo {
 		Description: "Download files from the web",
 		Usage:       "wget [options] url",
 		Category:    "network",
+		Schema:      systemArgs("url", "URLs to download", true),
 	}
 }
 
@@ -41,6 +42,7 @@ func (c *TarCommand) Info() types.CommandInfo {
 		Description: "Create or extract archives",
 		Usage:       "tar [options] [archive] [file...]",
 		Category:    "file",
+		Schema:      systemArgs("args", "Archive and files", true),
 	}
 }
 
@@ -67,6 +69,7 @@ func (c *ZipCommand) Info() types.CommandInfo {
 		Description: "Package and compress files",
 		Usage:       "zip [options] [zipfile] [file...]",
 		Category:    "file",
+		Schema:      systemArgs("args", "Zip file and files to add", true),
 	}
 }
 
@@ -1,10 +1,9 @@
 // Package commands 实现了 RunShell 的内置命令。
-// 本文件实现了一系列实用工具命令，包括文件操作、文本处理等功能。
+// 本文件实现了一系列通过系统命令完成的实用工具命令。
 package commands
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/iamlongalong/runshell/pkg/types"
 )
@@ -13,196 +12,151 @@ import (
 // 用于创建新文件或更新文件的访问和修改时间。
 type TouchCommand struct{}
 
-// Execute 执行 touch 命令。
-func (c *TouchCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	return ctx.Executor.ExecuteCommand(ctx)
-}
-
-// WriteCommand 实现了 'write' 命令。
-// 用于将指定内容写入文件。
-type WriteCommand struct{}
-
-// Execute 执行 write 命令。
-func (c *WriteCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	return ctx.Executor.ExecuteCommand(ctx)
-}
-
-// FindCommand 实现了 'find' 命令。
-type FindCommand struct{}
-
-// Execute 执行 find 命令。
-func (c *FindCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	return ctx.Executor.ExecuteCommand(ctx)
-}
-
-// GrepCommand 实现了 'grep' 命令。
-type GrepCommand struct{}
-
-// Execute 执行 grep 命令。
-func (c *GrepCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	return ctx.Executor.ExecuteCommand(ctx)
+func (c *TouchCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "touch",
+		Description: "Change file timestamps or create empty files",
+		Usage:       "touch [options] file...",
+		Category:    "file",
+		Schema:      systemArgs("file", "Files to create or update", true),
+	}
 }
 
-// TailCommand 实现了 'tail' 命令。
-type TailCommand struct{}
+// Execute 执行 touch 命令。
+func (c *TouchCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	if len(ctx.Command.Args) == 0 {
+		return nil, fmt.Errorf("no file specified")
+	}
 
-// Execute 执行 tail 命令。
-// 参数：
-//   - -n<num>：显示的行数（可选，默认10）
-//   - 文件路径
-func (c *TailCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	return ctx.Executor.ExecuteCommand(ctx)
+	return runSystem(ctx, "touch", ctx.Command.Args)
 }
 
+// XargsCommand 实现了 'xargs' 命令。
+// 用于从标准输入构建并执行命令。
 type XargsCommand struct{}
 
-func (c *XargsCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	return ctx.Executor.ExecuteCommand(ctx)
-}
-
-// MvCommand implements mv command
-type MvCommand struct{}
-
-// Execute 执行 mv 命令。
-func (c *MvCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	if len(ctx.Command.Args) != 2 {
-		return nil, fmt.Errorf("mv requires source and destination arguments")
+func (c *XargsCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "xargs",
+		Description: "Build and execute command lines from standard input",
+		Usage:       "xargs [options] [command [initial-arguments]]",
+		Category:    "text",
+		Schema:      systemArgs("command", "Command and initial arguments", false),
 	}
-
-	return ctx.Executor.ExecuteCommand(ctx)
 }
 
-// HeadCommand implements head command
-type HeadCommand struct{}
-
-func (c *HeadCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	if len(ctx.Command.Args) < 1 {
-		return nil, fmt.Errorf("head requires a file argument")
+// Execute 执行 xargs 命令。
+func (c *XargsCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
 	}
 
-	return ctx.Executor.ExecuteCommand(ctx)
+	return runSystem(ctx, "xargs", ctx.Command.Args)
 }
 
-// SortCommand implements sort command
-type SortCommand struct{}
+// NetstatCommand 实现了 'netstat' 命令。
+// 用于显示网络连接信息。
+type NetstatCommand struct{}
 
-func (c *SortCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	if len(ctx.Command.Args) < 1 {
-		return nil, fmt.Errorf("sort requires a file argument")
+func (c *NetstatCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "netstat",
+		Description: "Print network connections",
+		Usage:       "netstat [options]",
+		Category:    "network",
+		Schema:      systemArgs("args", "Netstat options", false),
 	}
+}
 
-	// 构建sort命令的参数
-	args := []string{"sort"}
-	args = append(args, ctx.Command.Args...)
-
-	// 创建的执行上下文
-	newCtx := &types.ExecuteContext{
-		Context:     ctx.Context,
-		Command:     types.Command{Command: "sort", Args: args},
-		Options:     ctx.Options,
-		StartTime:   ctx.StartTime,
-		IsPiped:     ctx.IsPiped,
-		PipeContext: ctx.PipeContext,
-		Executor:    ctx.Executor,
+// Execute 执行 netstat 命令。
+func (c *NetstatCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
 	}
 
-	// 通过executor执行命令
-	return ctx.Executor.ExecuteCommand(newCtx)
+	return runSystem(ctx, "netstat", ctx.Command.Args)
 }
 
-// UniqCommand implements uniq command
-type UniqCommand struct{}
+// IfconfigCommand 实现了 'ifconfig' 命令。
+// 用于显示网络接口信息。
+type IfconfigCommand struct{}
 
-func (c *UniqCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	if len(ctx.Command.Args) < 1 {
-		return nil, fmt.Errorf("uniq requires a file argument")
+func (c *IfconfigCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "ifconfig",
+		Description: "Display network interface configuration",
+		Usage:       "ifconfig [interface]",
+		Category:    "network",
+		Schema:      systemArgs("interface", "Network interface", false),
 	}
-
-	return ctx.Executor.ExecuteCommand(ctx)
 }
 
-// NetstatCommand implements netstat command
-type NetstatCommand struct{}
+// Execute 执行 ifconfig 命令。
+func (c *IfconfigCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
 
-func (c *NetstatCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	return ctx.Executor.ExecuteCommand(ctx)
+	return runSystem(ctx, "ifconfig", ctx.Command.Args)
 }
 
-// IfconfigCommand implements ifconfig command
-type IfconfigCommand struct{}
+// CurlCommand 实现了 'curl' 命令。
+// 用于发送 HTTP 请求。
+type CurlCommand struct{}
 
-func (c *IfconfigCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	return ctx.Executor.ExecuteCommand(ctx)
+func (c *CurlCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "curl",
+		Description: "Transfer data from or to a server",
+		Usage:       "curl [options] url...",
+		Category:    "network",
+		Schema:      systemArgs("url", "URLs to request", true),
+	}
 }
 
-// CurlCommand implements curl command
-type CurlCommand struct{}
-
 // Execute 执行 curl 命令。
 func (c *CurlCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
 	if len(ctx.Command.Args) < 1 {
 		return nil, fmt.Errorf("curl requires a URL argument")
 	}
 
-	// 构建curl命令的参数
-	args := []string{"curl"}
-	args = append(args, ctx.Command.Args...)
-
-	// 创建新的执行上下文
-	newCtx := &types.ExecuteContext{
-		Context:     ctx.Context,
-		Command:     types.Command{Command: "curl", Args: args},
-		Options:     ctx.Options,
-		StartTime:   ctx.StartTime,
-		IsPiped:     ctx.IsPiped,
-		PipeContext: ctx.PipeContext,
-		Executor:    ctx.Executor,
-	}
-
-	// 通过executor执行命令
-	return ctx.Executor.ExecuteCommand(newCtx)
+	return runSystem(ctx, "curl", ctx.Command.Args)
 }
 
-// SedCommand implements sed command
+// SedCommand 实现了 'sed' 命令。
+// 用于对文本进行流式编辑。
 type SedCommand struct{}
 
-func (c *SedCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
-	result := &types.ExecuteResult{
-		CommandName: "sed",
-		StartTime:   ctx.StartTime,
-	}
-
-	if ctx.Executor == nil {
-		result.Error = fmt.Errorf("executor is required")
-		return result, result.Error
-	}
-
-	if len(ctx.Command.Args) < 2 {
-		result.Error = fmt.Errorf("sed requires pattern and file arguments")
-		return result, result.Error
+func (c *SedCommand) Info() types.CommandInfo {
+	return types.CommandInfo{
+		Name:        "sed",
+		Description: "Stream editor for filtering and transforming text",
+		Usage:       "sed [options] script [file...]",
+		Category:    "text",
+		Schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "script", Type: types.ArgTypeString, Required: true, Description: "Sed script"},
+				{Name: "file", Type: types.ArgTypePath, Variadic: true, Description: "Input files"},
+			},
+			Passthrough: true,
+		},
 	}
+}
 
-	// 准备命令
-	execCtx := &types.ExecuteContext{
-		Context:     ctx.Context,
-		Command:     types.Command{Command: "sed", Args: ctx.Command.Args},
-		Options:     ctx.Options,
-		StartTime:   ctx.StartTime,
-		IsPiped:     ctx.IsPiped,
-		PipeContext: ctx.PipeContext,
-		Executor:    ctx.Executor,
+// Execute 执行 sed 命令。
+func (c *SedCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
 	}
-
-	// 通过executor执行命令
-	execResult, err := ctx.Executor.ExecuteCommand(execCtx)
-	if err != nil {
-		result.Error = err
-		result.ExitCode = 1
-		return result, err
+	if len(ctx.Command.Args) < 1 {
+		return nil, fmt.Errorf("sed requires a script argument")
 	}
 
-	result.ExitCode = execResult.ExitCode
-	result.EndTime = time.Now()
-
-	return result, nil
+	return runSystem(ctx, "sed", ctx.Command.Args)
 }
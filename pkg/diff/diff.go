@@ -0,0 +1,301 @@
+// Package diff 实现了基于行的文本差异计算与 unified diff 格式输出。
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext unified diff 默认的上下文行数
+const DefaultContext = 3
+
+// maxEditDistance 编辑距离上限，超过时退化为整体替换，避免病态输入消耗过多内存
+const maxEditDistance = 4000
+
+// noNewline 文件末尾没有换行符时的标记
+const noNewline = "\\ No newline at end of file"
+
+// Kind 表示编辑操作类型
+type Kind int
+
+const (
+	// Equal 行未变化
+	Equal Kind = iota
+	// Insert 新增行
+	Insert
+	// Delete 删除行
+	Delete
+)
+
+// Edit 表示一行的编辑操作
+type Edit struct {
+	Kind Kind
+	Text string // 行内容，包含行尾换行符
+}
+
+// SplitLines 将文本按行拆分，每行保留行尾换行符
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines 计算两组行之间的编辑脚本
+func Lines(a, b []string) []Edit {
+	// 去掉公共前缀和后缀
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Kind: Equal, Text: line})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Kind: Equal, Text: line})
+	}
+	return edits
+}
+
+// myers 使用 Myers 算法计算最短编辑脚本
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 || n+m > 2*maxEditDistance {
+		return replaceAll(a, b)
+	}
+
+	max := n + m
+	offset := max
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		if d > maxEditDistance {
+			return replaceAll(a, b)
+		}
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// 回溯生成编辑脚本
+	var edits []Edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, Edit{Kind: Equal, Text: a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, Edit{Kind: Insert, Text: b[y]})
+			} else {
+				x--
+				edits = append(edits, Edit{Kind: Delete, Text: a[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// replaceAll 生成整体删除再整体插入的编辑脚本
+func replaceAll(a, b []string) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, Edit{Kind: Delete, Text: line})
+	}
+	for _, line := range b {
+		edits = append(edits, Edit{Kind: Insert, Text: line})
+	}
+	return edits
+}
+
+// Hunk 表示 unified diff 中的一个变更块
+type Hunk struct {
+	OldStart int    `json:"old_start"` // 原文件起始行号
+	OldLines int    `json:"old_lines"` // 原文件行数
+	NewStart int    `json:"new_start"` // 新文件起始行号
+	NewLines int    `json:"new_lines"` // 新文件行数
+	Edits    []Edit `json:"-"`         // 变更块中的行
+}
+
+// Compute 计算两段文本之间的变更块，context 为上下文行数
+func Compute(oldText, newText string, context int) []*Hunk {
+	if oldText == newText {
+		return nil
+	}
+	if context < 0 {
+		context = DefaultContext
+	}
+
+	edits := Lines(SplitLines(oldText), SplitLines(newText))
+
+	// 找出所有变更区间，并按上下文合并为 hunk
+	var hunks []*Hunk
+	i := 0
+	for i < len(edits) {
+		for i < len(edits) && edits[i].Kind == Equal {
+			i++
+		}
+		if i >= len(edits) {
+			break
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(edits) {
+			if edits[end].Kind != Equal {
+				end++
+				continue
+			}
+			// 统计连续未变化的行数
+			run := end
+			for run < len(edits) && edits[run].Kind == Equal {
+				run++
+			}
+			if run >= len(edits) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		hunks = append(hunks, newHunk(edits, start, end))
+		i = end
+	}
+	return hunks
+}
+
+// newHunk 由 edits[start:end] 构造变更块
+func newHunk(edits []Edit, start, end int) *Hunk {
+	h := &Hunk{OldStart: 1, NewStart: 1, Edits: edits[start:end]}
+	for _, e := range edits[:start] {
+		if e.Kind != Insert {
+			h.OldStart++
+		}
+		if e.Kind != Delete {
+			h.NewStart++
+		}
+	}
+	for _, e := range h.Edits {
+		if e.Kind != Insert {
+			h.OldLines++
+		}
+		if e.Kind != Delete {
+			h.NewLines++
+		}
+	}
+	if h.OldLines == 0 {
+		h.OldStart--
+	}
+	if h.NewLines == 0 {
+		h.NewStart--
+	}
+	return h
+}
+
+// Count 统计变更块中新增和删除的行数
+func Count(hunks []*Hunk) (added, removed int) {
+	for _, h := range hunks {
+		for _, e := range h.Edits {
+			switch e.Kind {
+			case Insert:
+				added++
+			case Delete:
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
+
+// Unified 生成 unified diff 格式的差异文本，内容相同时返回空字符串
+func Unified(oldName, newName, oldText, newText string, context int) string {
+	return Format(oldName, newName, Compute(oldText, newText, context))
+}
+
+// Format 将变更块格式化为 unified diff 文本，没有变更块时返回空字符串
+func Format(oldName, newName string, hunks []*Hunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+		for _, e := range h.Edits {
+			prefix := " "
+			switch e.Kind {
+			case Insert:
+				prefix = "+"
+			case Delete:
+				prefix = "-"
+			}
+			sb.WriteString(prefix)
+			sb.WriteString(e.Text)
+			if !strings.HasSuffix(e.Text, "\n") {
+				sb.WriteString("\n" + noNewline + "\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+func hunkRange(start, length int) string {
+	if length == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, length)
+}
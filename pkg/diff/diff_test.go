@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitLines(t *testing.T) {
+	assert.Nil(t, SplitLines(""))
+	assert.Equal(t, []string{"a\n", "b"}, SplitLines("a\nb"))
+	assert.Equal(t, []string{"a\n", "b\n"}, SplitLines("a\nb\n"))
+}
+
+func TestLines(t *testing.T) {
+	a := SplitLines("a\nb\nc\nd\n")
+	b := SplitLines("a\nc\nd\ne\n")
+
+	var kinds []Kind
+	for _, e := range Lines(a, b) {
+		kinds = append(kinds, e.Kind)
+	}
+	assert.Equal(t, []Kind{Equal, Delete, Equal, Equal, Insert}, kinds)
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{
+			name: "identical",
+			old:  "a\n",
+			new:  "a\n",
+			want: "",
+		},
+		{
+			name: "modify middle line",
+			old:  "1\n2\n3\n4\n5\n",
+			new:  "1\n2\nthree\n4\n5\n",
+			want: "--- a/f\n+++ b/f\n@@ -1,5 +1,5 @@\n 1\n 2\n-3\n+three\n 4\n 5\n",
+		},
+		{
+			name: "new file",
+			old:  "",
+			new:  "x\ny\n",
+			want: "--- a/f\n+++ b/f\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name: "missing newline at end",
+			old:  "a\n",
+			new:  "a\nb",
+			want: "--- a/f\n+++ b/f\n@@ -1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Unified("a/f", "b/f", tt.old, tt.new, DefaultContext))
+		})
+	}
+}
+
+func TestUnifiedSeparateHunks(t *testing.T) {
+	var old, new []string
+	for i := 0; i < 20; i++ {
+		old = append(old, "line")
+		new = append(new, "line")
+	}
+	old[1], new[1] = "x\n", "y\n"
+	old[18], new[18] = "p\n", "q\n"
+	for i := range old {
+		if old[i] == "line" {
+			old[i], new[i] = "line\n", "line\n"
+		}
+	}
+
+	out := Unified("a", "b", strings.Join(old, ""), strings.Join(new, ""), DefaultContext)
+	assert.Equal(t, 2, strings.Count(out, "@@ -"))
+	assert.Contains(t, out, "@@ -1,5 +1,5 @@")
+	assert.Contains(t, out, "@@ -16,5 +16,5 @@")
+}
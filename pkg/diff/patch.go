@@ -0,0 +1,261 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DevNull 表示新建或删除文件时 diff 中使用的空文件名
+const DevNull = "/dev/null"
+
+// hunkHeader 匹配 "@@ -l,s +l,s @@" 形式的变更块头
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// FileDiff 表示补丁中一个文件的变更
+type FileDiff struct {
+	OldName string  // 原文件名，新建文件时为 /dev/null
+	NewName string  // 新文件名，删除文件时为 /dev/null
+	Hunks   []*Hunk // 变更块
+}
+
+// IsNew 是否为新建文件
+func (f *FileDiff) IsNew() bool {
+	return f.OldName == DevNull
+}
+
+// IsDelete 是否为删除文件
+func (f *FileDiff) IsDelete() bool {
+	return f.NewName == DevNull
+}
+
+// Parse 解析 unified diff 格式的补丁，支持包含多个文件的补丁。
+// "diff --git"、"index" 等扩展头会被忽略。
+func Parse(patch string) ([]*FileDiff, error) {
+	lines := SplitLines(patch)
+	var files []*FileDiff
+	var current *FileDiff
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			current = &FileDiff{
+				OldName: parseName(line[4:]),
+				NewName: parseName(strings.TrimRight(lines[i+1], "\r\n")[4:]),
+			}
+			files = append(files, current)
+			i++
+
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk without file header", i+1)
+			}
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			current.Hunks = append(current.Hunks, hunk)
+			i = next - 1
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file headers found in patch")
+	}
+	return files, nil
+}
+
+// parseName 解析文件名，去掉时间戳等附加信息
+func parseName(s string) string {
+	if idx := strings.IndexByte(s, '\t'); idx >= 0 {
+		s = s[:idx]
+	}
+	return strings.TrimSpace(s)
+}
+
+// parseHunk 从 lines[start] 开始解析一个变更块，返回变更块以及下一个待解析行的位置
+func parseHunk(lines []string, start int) (*Hunk, int, error) {
+	m := hunkHeader.FindStringSubmatch(lines[start])
+	if m == nil {
+		return nil, 0, fmt.Errorf("line %d: invalid hunk header: %s", start+1, strings.TrimSpace(lines[start]))
+	}
+
+	atoi := func(s string, def int) int {
+		if s == "" {
+			return def
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	h := &Hunk{
+		OldStart: atoi(m[1], 0),
+		OldLines: atoi(m[2], 1),
+		NewStart: atoi(m[3], 0),
+		NewLines: atoi(m[4], 1),
+	}
+
+	oldCount, newCount := 0, 0
+	i := start + 1
+	for ; i < len(lines) && (oldCount < h.OldLines || newCount < h.NewLines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "\\") {
+			// 末尾没有换行符的标记作用于前一行
+			if n := len(h.Edits); n > 0 {
+				h.Edits[n-1].Text = strings.TrimSuffix(h.Edits[n-1].Text, "\n")
+			}
+			continue
+		}
+
+		text := ""
+		kind := Equal
+		switch {
+		case line == "\n" || line == "\r\n":
+			// 部分工具会去掉空白上下文行的前导空格
+			text = "\n"
+		case line[0] == ' ':
+			text = line[1:]
+		case line[0] == '-':
+			kind, text = Delete, line[1:]
+		case line[0] == '+':
+			kind, text = Insert, line[1:]
+		default:
+			return nil, 0, fmt.Errorf("line %d: unexpected line in hunk: %s", i+1, strings.TrimSpace(line))
+		}
+
+		if kind != Insert {
+			oldCount++
+		}
+		if kind != Delete {
+			newCount++
+		}
+		h.Edits = append(h.Edits, Edit{Kind: kind, Text: text})
+	}
+
+	if oldCount != h.OldLines || newCount != h.NewLines {
+		return nil, 0, fmt.Errorf("line %d: hunk is truncated", start+1)
+	}
+
+	// 处理末尾没有换行符的标记
+	if i < len(lines) && strings.HasPrefix(lines[i], "\\") {
+		last := &h.Edits[len(h.Edits)-1]
+		last.Text = strings.TrimSuffix(last.Text, "\n")
+		i++
+	}
+	return h, i, nil
+}
+
+// HunkResult 表示一个变更块的应用结果
+type HunkResult struct {
+	Index   int    `json:"index"`            // 变更块序号（从 1 开始）
+	Applied bool   `json:"applied"`          // 是否成功应用
+	Line    int    `json:"line,omitempty"`   // 实际应用的起始行号
+	Offset  int    `json:"offset,omitempty"` // 与补丁中记录位置的偏差
+	Fuzz    int    `json:"fuzz,omitempty"`   // 忽略的上下文行数
+	Reject  string `json:"reject,omitempty"` // 未能应用时的变更块文本
+}
+
+// Apply 将变更块应用到文本，fuzz 为允许忽略的首尾上下文行数。
+// 无法应用的变更块会被跳过，并在结果中标记为拒绝。
+func Apply(content string, hunks []*Hunk, fuzz int) (string, []HunkResult) {
+	lines := SplitLines(content)
+	results := make([]HunkResult, 0, len(hunks))
+
+	delta := 0  // 已应用变更块造成的行数偏移
+	minPos := 0 // 后续变更块不能早于该位置
+	for i, h := range hunks {
+		result := HunkResult{Index: i + 1}
+
+		applied := false
+		for f := 0; f <= fuzz && !applied; f++ {
+			from, to, lead := trimContext(h.Edits, f)
+			expected := h.OldStart - 1 + delta + lead
+			if h.OldLines == 0 {
+				// 纯新增的变更块，OldStart 表示插入位置之前的行
+				expected = h.OldStart + delta
+			}
+
+			pos, ok := locate(lines, from, expected, minPos)
+			if !ok {
+				continue
+			}
+
+			replaced := make([]string, 0, len(lines)-len(from)+len(to))
+			replaced = append(replaced, lines[:pos]...)
+			replaced = append(replaced, to...)
+			replaced = append(replaced, lines[pos+len(from):]...)
+			lines = replaced
+
+			result.Applied = true
+			result.Line = pos + 1 - lead
+			result.Offset = pos - expected
+			result.Fuzz = f
+			delta += len(to) - len(from)
+			minPos = pos + len(to)
+			applied = true
+		}
+
+		if !applied {
+			result.Reject = Format("a", "b", []*Hunk{h})
+			result.Reject = result.Reject[strings.Index(result.Reject, "@@"):]
+		}
+		results = append(results, result)
+	}
+
+	return strings.Join(lines, ""), results
+}
+
+// trimContext 去掉变更块首尾最多 fuzz 行上下文，返回需要匹配的原始行、替换后的行以及去掉的首部行数
+func trimContext(edits []Edit, fuzz int) (from, to []string, lead int) {
+	start, end := 0, len(edits)
+	for start < end && start < fuzz && edits[start].Kind == Equal {
+		start++
+	}
+	for trail := 0; end > start && trail < fuzz && edits[end-1].Kind == Equal; trail++ {
+		end--
+	}
+
+	for _, e := range edits[start:end] {
+		if e.Kind != Insert {
+			from = append(from, e.Text)
+		}
+		if e.Kind != Delete {
+			to = append(to, e.Text)
+		}
+	}
+	return from, to, start
+}
+
+// locate 从期望位置开始向两侧查找 from 在 lines 中的位置
+func locate(lines, from []string, expected, minPos int) (int, bool) {
+	if expected < minPos {
+		expected = minPos
+	}
+	if expected > len(lines) {
+		expected = len(lines)
+	}
+
+	match := func(pos int) bool {
+		if pos < minPos || pos+len(from) > len(lines) {
+			return false
+		}
+		for i, line := range from {
+			if lines[pos+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+
+	for d := 0; d <= len(lines); d++ {
+		if match(expected + d) {
+			return expected + d, true
+		}
+		if d > 0 && match(expected-d) {
+			return expected - d, true
+		}
+	}
+	return 0, false
+}
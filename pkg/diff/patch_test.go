@@ -0,0 +1,110 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	patch := "diff --git a/a.txt b/a.txt\n" +
+		"index 123..456 100644\n" +
+		"--- a/a.txt\t2024-01-01\n" +
+		"+++ b/a.txt\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		" one\n" +
+		"-two\n" +
+		"+TWO\n" +
+		" three\n" +
+		"--- /dev/null\n" +
+		"+++ b/new.txt\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+hello\n" +
+		"\\ No newline at end of file\n"
+
+	files, err := Parse(patch)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	assert.Equal(t, "a/a.txt", files[0].OldName)
+	assert.Equal(t, "b/a.txt", files[0].NewName)
+	require.Len(t, files[0].Hunks, 1)
+	assert.Equal(t, 1, files[0].Hunks[0].OldStart)
+	assert.Equal(t, 3, files[0].Hunks[0].OldLines)
+	assert.Len(t, files[0].Hunks[0].Edits, 4)
+
+	assert.True(t, files[1].IsNew())
+	assert.False(t, files[1].IsDelete())
+	require.Len(t, files[1].Hunks, 1)
+	assert.Equal(t, "hello", files[1].Hunks[0].Edits[0].Text)
+
+	_, err = Parse("not a patch\n")
+	assert.Error(t, err)
+
+	_, err = Parse("--- a\n+++ b\n@@ -1,3 +1,3 @@\n one\n")
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	patchFor := func(old, new string) []*Hunk {
+		files, err := Parse(Unified("a", "b", old, new, 3))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		return files[0].Hunks
+	}
+
+	t.Run("exact", func(t *testing.T) {
+		old := "a\nb\nc\nd\ne\n"
+		new := "a\nb\nC\nd\ne\n"
+		got, results := Apply(old, patchFor(old, new), 0)
+		assert.Equal(t, new, got)
+		require.Len(t, results, 1)
+		assert.True(t, results[0].Applied)
+		assert.Equal(t, 0, results[0].Offset)
+	})
+
+	t.Run("offset", func(t *testing.T) {
+		old := "a\nb\nc\nd\ne\n"
+		hunks := patchFor(old, "a\nb\nC\nd\ne\n")
+		got, results := Apply("x\ny\n"+old, hunks, 0)
+		assert.Equal(t, "x\ny\na\nb\nC\nd\ne\n", got)
+		assert.True(t, results[0].Applied)
+		assert.Equal(t, 2, results[0].Offset)
+	})
+
+	t.Run("fuzz", func(t *testing.T) {
+		old := "a\nb\nc\nd\ne\n"
+		hunks := patchFor(old, "a\nb\nC\nd\ne\n")
+		content := "A\nb\nc\nd\ne\n"
+
+		_, results := Apply(content, hunks, 0)
+		assert.False(t, results[0].Applied)
+
+		got, results := Apply(content, hunks, 2)
+		assert.Equal(t, "A\nb\nC\nd\ne\n", got)
+		assert.True(t, results[0].Applied)
+		assert.Equal(t, 1, results[0].Fuzz)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+		new := "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n"
+		hunks := patchFor(old, new)
+		require.Len(t, hunks, 2)
+
+		content := strings.Replace(old, "12\n", "XII\n", 1)
+		got, results := Apply(content, hunks, 0)
+		assert.Equal(t, strings.Replace(content, "1\n", "one\n", 1), got)
+		assert.True(t, results[0].Applied)
+		assert.False(t, results[1].Applied)
+		assert.Contains(t, results[1].Reject, "+twelve")
+	})
+
+	t.Run("new file", func(t *testing.T) {
+		got, results := Apply("", patchFor("", "hello\nworld"), 0)
+		assert.Equal(t, "hello\nworld", got)
+		assert.True(t, results[0].Applied)
+	})
+}
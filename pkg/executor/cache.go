@@ -0,0 +1,462 @@
+// Package executor 实现了命令执行器的核心功能。
+// 本文件实现了缓存只读命令结果的执行器装饰器。
+package executor
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+const (
+	CachingExecutorName = "caching"
+
+	// DefaultCacheTTL 缓存结果的默认有效期
+	DefaultCacheTTL = 30 * time.Second
+	// DefaultCacheEntries 默认最多缓存的结果数量
+	DefaultCacheEntries = 256
+	// DefaultCacheEntrySize 默认可缓存的单个结果的输出上限（字节）
+	DefaultCacheEntrySize = 1 << 20
+
+	// maxFingerprintEntries 计算工作目录指纹时最多遍历的条目数，超出时不缓存
+	maxFingerprintEntries = 10000
+)
+
+// CacheRule 表示一条可缓存命令的规则。
+// Command 使用 glob 匹配命令名称（忽略路径）；Args 中的每个 glob 都必须匹配至少一个参数。
+type CacheRule struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Match 判断命令是否匹配规则
+func (r CacheRule) Match(cmd types.Command) bool {
+	return Route{Command: r.Command, Args: r.Args}.Match(cmd, nil)
+}
+
+// DefaultCacheRules 默认可缓存的只读命令
+var DefaultCacheRules = []CacheRule{
+	{Command: "ls"},
+	{Command: "cat"},
+	{Command: "head"},
+	{Command: "tail"},
+	{Command: "wc"},
+	{Command: "stat"},
+	{Command: "file"},
+	{Command: "pwd"},
+	{Command: "tree"},
+	{Command: "go", Args: []string{"list"}},
+	{Command: "go", Args: []string{"env"}},
+	{Command: "go", Args: []string{"version"}},
+}
+
+// CacheConfig 表示结果缓存的配置
+type CacheConfig struct {
+	TTL          time.Duration // 结果的有效期，默认 DefaultCacheTTL
+	MaxEntries   int           // 最多缓存的结果数量，超出时淘汰最久未使用的结果，默认 DefaultCacheEntries
+	MaxEntrySize int           // 单个结果的输出上限（字节），超出时不缓存，默认 DefaultCacheEntrySize
+	Rules        []CacheRule   // 可缓存的命令，为空时使用 DefaultCacheRules
+	EnvKeys      []string      // 参与缓存键的环境变量名称
+	Fingerprint  bool          // 缓存键是否包含工作目录内容的指纹（路径、大小、权限和修改时间）
+}
+
+// cacheEntry 表示一个缓存的结果
+type cacheEntry struct {
+	key     string
+	result  types.ExecuteResult
+	streams bool // 是否记录了输出流
+	stdout  []byte
+	stderr  []byte
+	expires time.Time
+}
+
+// replay 将缓存的输出写入输出流，第一次执行时没有输出流则将合并后的输出写入 stdout
+func (c *cacheEntry) replay(stdout, stderr io.Writer) {
+	if !c.streams {
+		if stdout != nil && c.result.Output != "" {
+			io.WriteString(stdout, c.result.Output)
+		}
+		return
+	}
+	if stdout != nil && len(c.stdout) > 0 {
+		stdout.Write(c.stdout)
+	}
+	if stderr != nil && len(c.stderr) > 0 {
+		stderr.Write(c.stderr)
+	}
+}
+
+// CachingExecutor 是缓存只读命令结果的执行器装饰器。
+// 匹配规则的命令以命令、参数、工作目录、指定的环境变量以及可选的工作目录指纹为键缓存成功的结果，
+// 有效期内相同的命令直接返回缓存的结果；执行不可缓存的命令后清除所有缓存，
+// 因此每个会话应使用独立的 CachingExecutor。
+type CachingExecutor struct {
+	*ChainedExecutor
+	config  CacheConfig
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // 最近使用的结果在前
+}
+
+// NewCachingExecutor 创建缓存执行器
+func NewCachingExecutor(executor types.Executor, config CacheConfig) *CachingExecutor {
+	if config.TTL <= 0 {
+		config.TTL = DefaultCacheTTL
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultCacheEntries
+	}
+	if config.MaxEntrySize <= 0 {
+		config.MaxEntrySize = DefaultCacheEntrySize
+	}
+	if len(config.Rules) == 0 {
+		config.Rules = DefaultCacheRules
+	}
+	e := &CachingExecutor{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	e.ChainedExecutor = Chain(executor, e.middleware)
+	return e
+}
+
+// Name 返回执行器名称
+func (e *CachingExecutor) Name() string {
+	return CachingExecutorName
+}
+
+// InvalidateCache 清除所有缓存的结果
+func (e *CachingExecutor) InvalidateCache() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.entries) > 0 {
+		log.Debug("Invalidating %d cached results", len(e.entries))
+	}
+	e.entries = make(map[string]*list.Element)
+	e.lru.Init()
+}
+
+// Len 返回缓存的结果数量
+func (e *CachingExecutor) Len() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.entries)
+}
+
+// middleware 缓存命令的结果
+func (e *CachingExecutor) middleware(next ExecuteFunc) ExecuteFunc {
+	return func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+		cmds := pipelineCommands(ctx)
+		if !e.cacheable(ctx, cmds) {
+			result, err := next(ctx)
+			// 不可缓存的命令可能修改了工作目录
+			e.InvalidateCache()
+			return result, err
+		}
+
+		key, err := e.key(ctx, cmds)
+		if err != nil {
+			log.Debug("Skipping result cache for %s: %v", commandName(ctx), err)
+			return next(ctx)
+		}
+
+		if entry, ok := e.get(key); ok {
+			log.Debug("Result cache hit for %s", commandName(ctx))
+			if ctx.Options != nil {
+				entry.replay(ctx.Options.Stdout, ctx.Options.Stderr)
+			}
+			// 返回副本，调用方修改结果不会影响缓存
+			result := cloneResult(entry.result)
+			result.StartTime = time.Now()
+			result.EndTime = result.StartTime
+			result.CacheStatus = types.CacheHit
+			return &result, nil
+		}
+
+		// 记录输出流，命中时重放
+		c := *ctx
+		options := &types.ExecuteOptions{}
+		if ctx.Options != nil {
+			*options = *ctx.Options
+		}
+		stdout := &captureWriter{w: options.Stdout, limit: e.config.MaxEntrySize}
+		stderr := &captureWriter{w: options.Stderr, limit: e.config.MaxEntrySize}
+		if options.Stdout != nil {
+			options.Stdout = stdout
+		}
+		if options.Stderr != nil {
+			options.Stderr = stderr
+		}
+		c.Options = options
+
+		result, err := next(&c)
+		if result == nil {
+			return result, err
+		}
+		result.CacheStatus = types.CacheMiss
+		if err == nil && !stdout.overflow && !stderr.overflow && len(result.Output) <= e.config.MaxEntrySize {
+			e.put(&cacheEntry{
+				key:     key,
+				result:  cloneResult(*result),
+				streams: options.Stdout != nil || options.Stderr != nil,
+				stdout:  stdout.buf,
+				stderr:  stderr.buf,
+				expires: time.Now().Add(e.config.TTL),
+			})
+		}
+		return result, err
+	}
+}
+
+// cacheable 判断命令是否可以缓存，交互式命令、带有输入或开启文件变更跟踪的命令不缓存
+func (e *CachingExecutor) cacheable(ctx *types.ExecuteContext, cmds []types.Command) bool {
+	if ctx.Input != nil {
+		return false
+	}
+	if ctx.Options != nil && (ctx.Options.TTY || ctx.Options.Stdin != nil || ctx.Options.Track != nil) {
+		return false
+	}
+	for _, cmd := range cmds {
+		matched := false
+		for _, rule := range e.config.Rules {
+			if rule.Match(cmd) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return len(cmds) > 0
+}
+
+// key 计算命令的缓存键
+func (e *CachingExecutor) key(ctx *types.ExecuteContext, cmds []types.Command) (string, error) {
+	var workDir string
+	var env map[string]string
+	if ctx.Options != nil {
+		workDir = ctx.Options.WorkDir
+		env = ctx.Options.Env
+	}
+
+	type keyCommand struct {
+		Command string   `json:"c"`
+		Args    []string `json:"a"`
+	}
+	k := struct {
+		Commands    []keyCommand `json:"cmds"`
+		WorkDir     string       `json:"workdir"`
+		Env         []string     `json:"env"`
+		Fingerprint string       `json:"fingerprint,omitempty"`
+	}{WorkDir: workDir}
+	for _, cmd := range cmds {
+		k.Commands = append(k.Commands, keyCommand{Command: cmd.Command, Args: cmd.Args})
+	}
+	for _, name := range e.config.EnvKeys {
+		if value, ok := env[name]; ok {
+			k.Env = append(k.Env, name+"="+value)
+		}
+	}
+	sort.Strings(k.Env)
+	if e.config.Fingerprint {
+		fingerprint, err := e.fingerprint(ctx.Context, workDir)
+		if err != nil {
+			return "", err
+		}
+		k.Fingerprint = fingerprint
+	}
+
+	data, err := json.Marshal(k)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// fingerprint 通过执行器的文件系统计算工作目录内容的指纹
+func (e *CachingExecutor) fingerprint(ctx context.Context, workDir string) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	provider, ok := types.As[types.FileSystemProvider](e.Unwrap())
+	if !ok {
+		return "", fmt.Errorf("executor %s does not support file access", e.Unwrap().Name())
+	}
+	fsys, err := provider.FileSystem(workDir)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	count := 0
+	dirs := []string{"."}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		entries, err := fsys.ReadDir(ctx, dir)
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range entries {
+			count++
+			if count > maxFingerprintEntries {
+				return "", fmt.Errorf("workdir has more than %d entries", maxFingerprintEntries)
+			}
+			name := path.Join(dir, entry.Name)
+			fmt.Fprintf(hash, "%s\x00%d\x00%o\x00%d\x00%s\n", name, entry.Size, entry.Mode, entry.ModTime.UnixNano(), entry.Link)
+			if entry.IsDir {
+				dirs = append(dirs, name)
+			}
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// get 返回未过期的缓存结果
+func (e *CachingExecutor) get(key string) (*cacheEntry, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	elem, ok := e.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		e.lru.Remove(elem)
+		delete(e.entries, key)
+		return nil, false
+	}
+	e.lru.MoveToFront(elem)
+	return entry, true
+}
+
+// put 缓存结果，超出数量上限时淘汰最久未使用的结果
+func (e *CachingExecutor) put(entry *cacheEntry) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if elem, ok := e.entries[entry.key]; ok {
+		elem.Value = entry
+		e.lru.MoveToFront(elem)
+		return
+	}
+	e.entries[entry.key] = e.lru.PushFront(entry)
+	for e.lru.Len() > e.config.MaxEntries {
+		oldest := e.lru.Back()
+		e.lru.Remove(oldest)
+		delete(e.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cloneResult 深拷贝结果中的切片和 Data，使缓存的结果与返回给调用方的结果互不影响
+func cloneResult(r types.ExecuteResult) types.ExecuteResult {
+	r.Changes = slices.Clone(r.Changes)
+	r.Attempts = slices.Clone(r.Attempts)
+	if r.Stages != nil {
+		stages := make([]types.StageResult, len(r.Stages))
+		for i, stage := range r.Stages {
+			stage.Args = slices.Clone(stage.Args)
+			stages[i] = stage
+		}
+		r.Stages = stages
+	}
+	if r.Data != nil {
+		r.Data = cloneValue(reflect.ValueOf(r.Data)).Interface()
+	}
+	return r
+}
+
+// cloneValue 递归复制指针、切片、映射和结构体的导出字段，保持原有类型
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(cloneValue(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(cloneValue(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+		}
+		return c
+	case reflect.Struct:
+		// 未导出的字段无法通过反射设置，保持浅拷贝
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(cloneValue(v.Field(i)))
+			}
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// captureWriter 在写入 w 的同时记录最多 limit 字节，超出时标记 overflow
+type captureWriter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	buf      []byte
+	limit    int
+	overflow bool
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if !w.overflow {
+		if len(w.buf)+len(p) > w.limit {
+			w.overflow = true
+			w.buf = nil
+		} else {
+			w.buf = append(w.buf, p...)
+		}
+	}
+	w.mu.Unlock()
+	return w.w.Write(p)
+}
@@ -0,0 +1,200 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/commands"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingExecutor(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one"), 0644))
+
+	metrics := NewMetrics()
+	caching := NewCachingExecutor(NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil), CacheConfig{
+		EnvKeys: []string{"LANG"},
+	})
+	exec := Chain(caching, MetricsMiddleware(metrics))
+	assert.Equal(t, CachingExecutorName, caching.Name())
+
+	run := func(cmd string, args []string, options *types.ExecuteOptions) *types.ExecuteResult {
+		if options == nil {
+			options = &types.ExecuteOptions{}
+		}
+		options.WorkDir = dir
+		result, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: cmd, Args: args},
+			Options: options,
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	result := run("cat", []string{"a.txt"}, nil)
+	assert.Equal(t, "one", result.Output)
+	assert.Equal(t, types.CacheMiss, result.CacheStatus)
+
+	// 命中时重放输出流
+	var stdout bytes.Buffer
+	result = run("cat", []string{"a.txt"}, &types.ExecuteOptions{Stdout: &stdout})
+	assert.Equal(t, "one", result.Output)
+	assert.Equal(t, types.CacheHit, result.CacheStatus)
+	assert.Equal(t, "one", stdout.String())
+
+	// 未开启指纹时，执行器以外的修改不会使缓存失效
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("two"), 0644))
+	assert.Equal(t, "one", run("cat", []string{"a.txt"}, nil).Output)
+
+	// 参与缓存键的环境变量
+	assert.Equal(t, types.CacheMiss, run("cat", []string{"a.txt"}, &types.ExecuteOptions{Env: map[string]string{"LANG": "C"}}).CacheStatus)
+	assert.Equal(t, types.CacheHit, run("cat", []string{"a.txt"}, &types.ExecuteOptions{Env: map[string]string{"LANG": "C", "TERM": "xterm"}}).CacheStatus)
+
+	// 执行不可缓存的命令后缓存失效
+	assert.Equal(t, 2, caching.Len())
+	result = run("touch", []string{"b.txt"}, nil)
+	assert.Empty(t, result.CacheStatus)
+	assert.Equal(t, 0, caching.Len())
+	result = run("cat", []string{"a.txt"}, nil)
+	assert.Equal(t, "two", result.Output)
+	assert.Equal(t, types.CacheMiss, result.CacheStatus)
+
+	// 失败的命令不缓存
+	_, err := exec.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "cat", Args: []string{"missing.txt"}},
+		Options: &types.ExecuteOptions{WorkDir: dir},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, caching.Len())
+
+	// 通过 types.As 清除缓存
+	invalidator, ok := types.As[types.CacheInvalidator](exec)
+	require.True(t, ok)
+	invalidator.InvalidateCache()
+	assert.Equal(t, 0, caching.Len())
+
+	snapshot := metrics.Snapshot()
+	assert.Equal(t, int64(3), snapshot["cat"].CacheHits)
+	assert.Equal(t, int64(4), snapshot["cat"].CacheMisses)
+	assert.Equal(t, int64(0), snapshot["touch"].CacheHits+snapshot["touch"].CacheMisses)
+}
+
+func TestCachingExecutorCopiesResults(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one"), 0644))
+	local, err := NewLocalExecutorBuilder(types.LocalConfig{UseBuiltinCommands: true}).Build(nil)
+	require.NoError(t, err)
+	exec := NewCachingExecutor(local, CacheConfig{})
+
+	run := func() *types.ExecuteResult {
+		result, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "ls"},
+			Options: &types.ExecuteOptions{WorkDir: dir},
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	// 修改第一次执行和命中返回的结果都不会影响缓存
+	result := run()
+	assert.Equal(t, types.CacheMiss, result.CacheStatus)
+	result.Data.([]commands.ListResult)[0].Entries[0].Name = "changed"
+	result = run()
+	assert.Equal(t, types.CacheHit, result.CacheStatus)
+	assert.Equal(t, "a.txt", result.Data.([]commands.ListResult)[0].Entries[0].Name)
+	result.Data.([]commands.ListResult)[0].Entries[0].Name = "changed"
+	assert.Equal(t, "a.txt", run().Data.([]commands.ListResult)[0].Entries[0].Name)
+}
+
+func TestCloneResult(t *testing.T) {
+	type payload struct {
+		Names []string
+		Sizes map[string]int
+		Next  *payload
+	}
+	original := types.ExecuteResult{
+		Changes:  []types.FileChange{{Path: "a.txt", Type: types.FileModified}},
+		Attempts: []types.ExecuteAttempt{{Attempt: 1}},
+		Stages:   []types.StageResult{{Command: "grep", Args: []string{"x"}}},
+		Data:     &payload{Names: []string{"a"}, Sizes: map[string]int{"a": 1}, Next: &payload{Names: []string{"b"}}},
+	}
+	c := cloneResult(original)
+	c.Changes[0].Path = "b.txt"
+	c.Attempts[0].Attempt = 2
+	c.Stages[0].Args[0] = "y"
+	data := c.Data.(*payload)
+	data.Names[0] = "z"
+	data.Sizes["a"] = 2
+	data.Next.Names[0] = "z"
+
+	assert.Equal(t, "a.txt", original.Changes[0].Path)
+	assert.Equal(t, 1, original.Attempts[0].Attempt)
+	assert.Equal(t, "x", original.Stages[0].Args[0])
+	want := &payload{Names: []string{"a"}, Sizes: map[string]int{"a": 1}, Next: &payload{Names: []string{"b"}}}
+	assert.Equal(t, want, original.Data)
+}
+
+func TestCachingExecutorBounds(t *testing.T) {
+	dir := t.TempDir()
+	local := NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil)
+
+	run := func(exec types.Executor, cmd string, args ...string) *types.ExecuteResult {
+		result, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: cmd, Args: args},
+			Options: &types.ExecuteOptions{WorkDir: dir},
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("ttl", func(t *testing.T) {
+		exec := NewCachingExecutor(local, CacheConfig{TTL: 50 * time.Millisecond})
+		assert.Equal(t, types.CacheMiss, run(exec, "pwd").CacheStatus)
+		assert.Equal(t, types.CacheHit, run(exec, "pwd").CacheStatus)
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, types.CacheMiss, run(exec, "pwd").CacheStatus)
+	})
+
+	t.Run("lru", func(t *testing.T) {
+		exec := NewCachingExecutor(local, CacheConfig{MaxEntries: 2})
+		run(exec, "ls", "-a")
+		run(exec, "ls", "-l")
+		run(exec, "ls", "-a")
+		run(exec, "ls", "-1")
+		assert.Equal(t, 2, exec.Len())
+		assert.Equal(t, types.CacheHit, run(exec, "ls", "-a").CacheStatus)
+		assert.Equal(t, types.CacheMiss, run(exec, "ls", "-l").CacheStatus)
+	})
+
+	t.Run("allow list", func(t *testing.T) {
+		exec := NewCachingExecutor(local, CacheConfig{Rules: []CacheRule{{Command: "echo", Args: []string{"cached"}}}})
+		run(exec, "echo", "cached")
+		assert.Equal(t, types.CacheHit, run(exec, "echo", "cached").CacheStatus)
+		assert.Empty(t, run(exec, "echo", "other").CacheStatus)
+		assert.Equal(t, types.CacheMiss, run(exec, "echo", "cached").CacheStatus)
+	})
+
+	t.Run("fingerprint", func(t *testing.T) {
+		exec := NewCachingExecutor(local, CacheConfig{Fingerprint: true})
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "c.txt"), []byte("1"), 0644))
+		run(exec, "cat", "c.txt")
+		assert.Equal(t, types.CacheHit, run(exec, "cat", "c.txt").CacheStatus)
+
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "d.txt"), []byte("22"), 0644))
+		result := run(exec, "cat", "c.txt")
+		assert.Equal(t, types.CacheMiss, result.CacheStatus)
+		assert.Equal(t, types.CacheHit, run(exec, "cat", "c.txt").CacheStatus)
+	})
+}
@@ -6,17 +6,51 @@
 //   - 在本地系统中执行命令
 //   - 支持工作目录和环境变量设置
 //   - 提供命令注册和管理功能
+//   - 管道命令在进程内运行 (RunPipeline)，内置命令与外部进程通过管道相连，
+//     记录每个命令的退出码和耗时，支持 pipefail
 //
 // 2. Docker 命令执行器 (DockerExecutor)：
 //   - 在 Docker 容器中执行命令
 //   - 支持镜像选择和容器配置
 //   - 提供容器生命周期管理
+//   - 命令名称和参数都加上引号，管道中的每个命令单独执行并通过 RunPipeline 相连
 //
 // 3. 审计执行器 (AuditedExecutor)：
 //   - 包装其他执行器，提供审计功能
 //   - 记录命令执行的详细信息
 //   - 支持审计日志的持久化
 //
+// 4. 远程执行器 (remote.RemoteExecutor)：
+//   - 通过另一个 runshell 服务端的 API 执行命令
+//   - 支持 Bearer Token、Basic 认证和 TLS 客户端证书
+//   - 流式返回输出，传递取消和超时
+//
+// 5. SSH 执行器 (ssh.SSHExecutor)：
+//   - 通过 SSH 在已有主机上执行命令，无需安装 runshell
+//   - 支持私钥、ssh-agent 和密码认证，通过 known_hosts 校验主机密钥
+//   - 按主机复用连接，交互式命令分配伪终端，文件操作通过 SFTP 完成
+//
+// 6. 路由执行器 (RoutingExecutor)：
+//   - 按命令名称、参数和元数据规则将命令分发到不同的子执行器
+//   - 通过工作目录映射使各子执行器中的路径保持一致
+//   - 合并子执行器的命令列表，关闭时关闭所有子执行器
+//   - 通过 AllowExplicit 允许的子执行器可以用 "名称:命令" 显式指定，管道中的命令可以在不同的子执行器中执行
+//
+// 7. 中间件链 (Chain)：
+//   - 使用中间件包装任意执行器，在执行前后处理上下文和结果
+//   - 内置日志、统计、策略、超时、重试、输出限制和审计中间件
+//   - RetryRulesMiddleware 按命令规则重试，支持退出码、标准错误正则和指数退避
+//   - 审计执行器基于审计中间件实现
+//
+// 8. 缓存执行器 (CachingExecutor)：
+//   - 缓存只读命令的结果，支持有效期、LRU 数量上限和工作目录指纹
+//   - 执行不可缓存的命令后清除缓存
+//
+// 9. 管道执行器 (PipelineExecutor)：
+//   - 按 shell 语法 (shell.Parse) 解析命令字符串，在任意执行器上解释执行
+//   - 支持命令列表、&& / ||、子 shell、重定向、变量和路径名展开，不支持的语法返回 *shell.UnsupportedError
+//   - 脚本的语法树通过 context 传递给策略和审计中间件
+//
 // 使用示例：
 //
 //	// 创建本地执行器
@@ -28,5 +62,12 @@
 //	// 创建审计执行器
 //	auditedExec := executor.NewAuditedExecutor(localExec, auditor)
 //
+//	// 使用中间件包装执行器
+//	chained := executor.Chain(localExec,
+//		executor.LoggingMiddleware(),
+//		executor.TimeoutMiddleware(30*time.Second),
+//		executor.OutputLimitMiddleware(1<<20),
+//	)
+//
 // 本包实现了 types.Executor 接口，提供了统一的命令执行接口。
 package executor
@@ -0,0 +1,173 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/google/uuid"
+	"github.com/iamlongalong/runshell/pkg/archive"
+	"github.com/iamlongalong/runshell/pkg/log"
+)
+
+// newDockerClient 创建 Docker 客户端
+func newDockerClient() (*client.Client, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithVersion("1.43"))
+	if err != nil {
+		log.Error("Failed to create Docker client: %v", err)
+		return nil, fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	return cli, nil
+}
+
+// runHelper 在容器中执行辅助命令并等待其结束，返回标准输出
+func (e *DockerExecutor) runHelper(ctx context.Context, cli *client.Client, cmd []string) ([]byte, error) {
+	execResp, err := cli.ContainerExecCreate(ctx, e.containerID, container.ExecOptions{
+		User:         e.config.User,
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec instance: %v", err)
+	}
+
+	resp, err := cli.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec instance: %v", err)
+	}
+	defer resp.Close()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil {
+		return nil, fmt.Errorf("failed to read exec output: %v", err)
+	}
+
+	inspect, err := cli.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect exec instance: %v", err)
+	}
+	if inspect.ExitCode != 0 {
+		return stdout.Bytes(), fmt.Errorf("helper command %v exited with code %d: %s", cmd, inspect.ExitCode, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// workDir 返回实际使用的工作目录
+func (e *DockerExecutor) workDir(workDir string) (string, error) {
+	if workDir == "" {
+		workDir = e.config.WorkDir
+	}
+	if workDir == "" {
+		return "", fmt.Errorf("work directory not specified")
+	}
+	return workDir, nil
+}
+
+// ArchiveWorkDir 实现 types.WorkDirArchiver 接口，通过 Docker 归档 API 打包工作目录
+func (e *DockerExecutor) ArchiveWorkDir(ctx context.Context, workDir string) (io.ReadCloser, error) {
+	dir, err := e.workDir(workDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.ensureContainer(); err != nil {
+		return nil, fmt.Errorf("failed to ensure container: %v", err)
+	}
+
+	cli, err := newDockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Archiving container work directory: %s", dir)
+	rc, _, err := cli.CopyFromContainer(ctx, e.containerID, dir)
+	if err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("failed to copy from container: %v", err)
+	}
+
+	return &readCloser{
+		ReadCloser: archive.StripPrefix(rc),
+		close: func() error {
+			rc.Close()
+			return cli.Close()
+		},
+	}, nil
+}
+
+// RestoreWorkDir 实现 types.WorkDirArchiver 接口。
+// 先通过归档 API 解包到工作目录内的临时目录，成功后再替换原有内容，
+// 归档损坏或解包失败时工作目录保持不变。
+func (e *DockerExecutor) RestoreWorkDir(ctx context.Context, workDir string, r io.Reader) error {
+	dir, err := e.workDir(workDir)
+	if err != nil {
+		return err
+	}
+	if err := e.ensureContainer(); err != nil {
+		return fmt.Errorf("failed to ensure container: %v", err)
+	}
+
+	cli, err := newDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	log.Debug("Restoring container work directory: %s", dir)
+	id := uuid.New().String()
+	stage := path.Join(dir, archive.StagePrefix+id)
+	backup := path.Join(dir, archive.BackupPrefix+id)
+	if _, err := e.runHelper(ctx, cli, []string{"mkdir", stage}); err != nil {
+		return fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	if err := cli.CopyToContainer(ctx, e.containerID, stage, r, container.CopyToContainerOptions{}); err != nil {
+		e.runHelper(context.Background(), cli, []string{"rm", "-rf", stage})
+		return fmt.Errorf("failed to copy to container: %v", err)
+	}
+	if _, err := e.runHelper(ctx, cli, []string{"/bin/sh", "-c", swapScript, "sh", dir, stage, backup}); err != nil {
+		return fmt.Errorf("failed to replace work directory: %v", err)
+	}
+	return nil
+}
+
+// swapScript 用临时目录 $2 中的内容替换目录 $1 的内容，原有内容先移动到备份目录 $3，
+// 替换失败时恢复原有内容，恢复也失败时保留备份目录
+const swapScript = `dir=$1 stage=$2 backup=$3
+move() {
+	for f in "$1"/* "$1"/.[!.]* "$1"/..?*; do
+		if [ -e "$f" ] || [ -L "$f" ]; then
+			[ "$f" = "$stage" ] || [ "$f" = "$backup" ] || mv "$f" "$2"/ || return 1
+		fi
+	done
+}
+[ -d "$dir" ] && [ -d "$stage" ] || exit 1
+mkdir "$backup" || { rm -rf "$stage"; exit 1; }
+if move "$dir" "$backup" && move "$stage" "$dir"; then
+	rm -rf "$stage" "$backup"
+	exit 0
+fi
+for f in "$dir"/* "$dir"/.[!.]* "$dir"/..?*; do
+	if [ -e "$f" ] || [ -L "$f" ]; then
+		[ "$f" = "$stage" ] || [ "$f" = "$backup" ] || rm -rf "$f"
+	fi
+done
+move "$backup" "$dir" && rm -rf "$backup"
+rm -rf "$stage"
+echo "failed to replace $dir" >&2
+exit 1`
+
+// readCloser 在关闭时执行额外的清理函数
+type readCloser struct {
+	io.ReadCloser
+	close func() error
+}
+
+func (r *readCloser) Close() error {
+	r.ReadCloser.Close()
+	return r.close()
+}
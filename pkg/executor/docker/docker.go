@@ -5,19 +5,25 @@ package docker
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/creack/pty"
+	"al.essio.dev/pkg/shellescape"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-units"
+	"github.com/google/uuid"
 	"github.com/iamlongalong/runshell/pkg/commands"
+	"github.com/iamlongalong/runshell/pkg/executor"
 	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/tracker"
 	runshellTypes "github.com/iamlongalong/runshell/pkg/types"
 )
 
@@ -34,6 +40,10 @@ const (
 // 内部使用的常量，不需要导出
 const (
 	containerNamePrefix = "runshell-"
+	// pidFilePrefix 容器内记录命令进程 PID 的文件前缀
+	pidFilePrefix = "/tmp/.runshell-"
+	// killTimeout 终止进程和获取退出码的超时时间
+	killTimeout = 10 * time.Second
 )
 
 // DockerExecutor Docker 命令执行器
@@ -94,13 +104,9 @@ func (e *DockerExecutor) ensureContainer() error {
 	defer e.mu.Unlock()
 
 	// 创建 Docker 客户端
-	cli, err := client.NewClientWithOpts(
-		client.FromEnv,
-		client.WithVersion("1.43"), // 显式指定 API 版本
-	)
+	cli, err := newDockerClient()
 	if err != nil {
-		log.Error("Failed to create Docker client: %v", err)
-		return fmt.Errorf("failed to create Docker client: %v", err)
+		return err
 	}
 	defer cli.Close()
 
@@ -147,12 +153,11 @@ func (e *DockerExecutor) ensureContainer() error {
 		OpenStdin:   true,
 		AttachStdin: true,
 		Cmd:         []string{"tail", "-f", "/dev/null"},
+		Labels:      e.config.Labels,
 	}
 
 	// 准备主机配置
-	hostConfig := &container.HostConfig{
-		AutoRemove: true,
-	}
+	hostConfig := e.hostConfig()
 
 	// 添加目录绑定
 	if e.config.BindMount != "" {
@@ -247,6 +252,46 @@ func (e *DockerExecutor) ensureContainer() error {
 	return nil
 }
 
+// hostConfig 根据配置生成容器的资源限制、网络和隔离设置
+func (e *DockerExecutor) hostConfig() *container.HostConfig {
+	cfg := e.config
+	hostConfig := &container.HostConfig{
+		AutoRemove:     true,
+		NetworkMode:    container.NetworkMode(cfg.NetworkMode),
+		ReadonlyRootfs: cfg.ReadOnly,
+		CapDrop:        cfg.CapDrop,
+		SecurityOpt:    append([]string(nil), cfg.SecurityOpt...),
+		ExtraHosts:     cfg.ExtraHosts,
+		DNS:            cfg.DNS,
+		Resources: container.Resources{
+			Memory:   cfg.Memory,
+			NanoCPUs: int64(cfg.CPUs * 1e9),
+		},
+	}
+	if cfg.PidsLimit > 0 {
+		pids := cfg.PidsLimit
+		hostConfig.PidsLimit = &pids
+	}
+	if cfg.NoNewPrivileges {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges:true")
+	}
+	for _, u := range cfg.Ulimits {
+		hostConfig.Ulimits = append(hostConfig.Ulimits, &units.Ulimit{Name: u.Name, Soft: u.Soft, Hard: u.Hard})
+	}
+
+	// 只读根文件系统时 /tmp 默认挂载为 tmpfs，执行命令时需要在其中记录进程 PID
+	if len(cfg.Tmpfs) > 0 || cfg.ReadOnly {
+		hostConfig.Tmpfs = make(map[string]string, len(cfg.Tmpfs)+1)
+		for path, opts := range cfg.Tmpfs {
+			hostConfig.Tmpfs[path] = opts
+		}
+		if _, ok := hostConfig.Tmpfs["/tmp"]; !ok && cfg.ReadOnly {
+			hostConfig.Tmpfs["/tmp"] = ""
+		}
+	}
+	return hostConfig
+}
+
 // Execute 执行命令
 func (e *DockerExecutor) Execute(ctx *runshellTypes.ExecuteContext) (*runshellTypes.ExecuteResult, error) {
 	log.Debug("Executing command with context: %+v", ctx)
@@ -278,10 +323,32 @@ func (e *DockerExecutor) Execute(ctx *runshellTypes.ExecuteContext) (*runshellTy
 		return e.ExecuteInteractive(ctx)
 	}
 
+	// 开启文件变更跟踪时，通过容器内的辅助命令在执行前后扫描工作目录
+	if ctx.Options.Track != nil {
+		if root, err := e.workDir(ctx.Options.WorkDir); err == nil {
+			return tracker.Run(ctx.Context, trackerSource{executor: e}, root, ctx.Options.Track, func() (*runshellTypes.ExecuteResult, error) {
+				return e.dispatch(ctx)
+			})
+		}
+		log.Debug("Skipping change tracking: work directory not specified")
+	}
+
+	return e.dispatch(ctx)
+}
+
+// dispatch 将命令分发给内置命令或容器命令执行
+func (e *DockerExecutor) dispatch(ctx *runshellTypes.ExecuteContext) (*runshellTypes.ExecuteResult, error) {
+	if ctx.IsPiped {
+		return e.executePipeline(ctx)
+	}
+
 	// 检查是否内置命令
 	if cmd, ok := e.commands.Load(ctx.Command.Command); ok {
 		log.Debug("Executing built-in command: %s", ctx.Command)
 		command := cmd.(runshellTypes.ICommand)
+		if err := runshellTypes.ValidateArgs(command.Info(), ctx.Command.Args); err != nil {
+			return nil, err
+		}
 
 		ctx.Executor = e
 		return command.Execute(ctx)
@@ -291,8 +358,49 @@ func (e *DockerExecutor) Execute(ctx *runshellTypes.ExecuteContext) (*runshellTy
 	return e.ExecuteCommand(ctx)
 }
 
+// executePipeline 通过 executor.RunPipeline 执行管道，每个命令在容器中单独执行，
+// 命令之间的数据通过进程内的管道传递，结果中记录每个命令的退出码和耗时
+func (e *DockerExecutor) executePipeline(ctx *runshellTypes.ExecuteContext) (*runshellTypes.ExecuteResult, error) {
+	stages := make([]executor.PipelineStage, len(ctx.PipeContext.Commands))
+	for i, cmd := range ctx.PipeContext.Commands {
+		if cmd == nil || cmd.Command == "" {
+			return nil, fmt.Errorf("command not found")
+		}
+		stages[i] = executor.PipelineStage{Command: *cmd, Run: e.dispatch}
+	}
+
+	// 执行上下文或管道上下文任一结束时取消所有命令，各命令在容器内按 PID 终止
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	runCtx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+	if pipeParent := ctx.PipeContext.Context; pipeParent != nil {
+		stop := context.AfterFunc(pipeParent, func() { cancel(pipeParent.Err()) })
+		defer stop()
+	}
+	pipeCtx := ctx.Copy()
+	pipeCtx.Context = runCtx
+
+	// 管道的选项优先于命令的选项
+	options := ctx.Options.Merge(ctx.PipeContext.Options)
+	result, err := executor.RunPipeline(pipeCtx, stages, options, ctx.PipeContext.Pipefail)
+	if cause := context.Cause(runCtx); cause != nil && errors.Is(err, context.Canceled) {
+		err = cause
+	}
+	if err != nil && result != nil {
+		log.Error("Pipeline execution failed: %v", err)
+	}
+	return result, err
+}
+
 // ExecuteCommand 执行具体的命令
 func (e *DockerExecutor) ExecuteCommand(ctx *runshellTypes.ExecuteContext) (*runshellTypes.ExecuteResult, error) {
+	if ctx.IsPiped {
+		return e.executePipeline(ctx)
+	}
+
 	// 确保容器存在并运行
 	if err := e.ensureContainer(); err != nil {
 		log.Error("Failed to ensure container for command %v: %v", ctx.Command, err)
@@ -300,10 +408,9 @@ func (e *DockerExecutor) ExecuteCommand(ctx *runshellTypes.ExecuteContext) (*run
 	}
 
 	// 创建 Docker 客户端
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithVersion("1.43"))
+	cli, err := newDockerClient()
 	if err != nil {
-		log.Error("Failed to create Docker client: %v", err)
-		return nil, fmt.Errorf("failed to create Docker client: %v", err)
+		return nil, err
 	}
 	defer cli.Close()
 
@@ -316,41 +423,13 @@ func (e *DockerExecutor) ExecuteCommand(ctx *runshellTypes.ExecuteContext) (*run
 	}
 
 	// 构建完整的命令字符串
-	var execCtx context.Context
-	var cmds []string
-	if ctx.IsPiped && ctx.PipeContext != nil {
-		execCtx = ctx.PipeContext.Context
-		var cmdList []string
-		for _, cmd := range ctx.PipeContext.Commands {
-			cmdList = append(cmdList, fmt.Sprintf("%s %s", cmd.Command, strings.Join(cmd.Args, " ")))
-		}
-		// 使用 shell 来执行管道命令
-		cmds = []string{"/bin/sh", "-c", strings.Join(cmdList, " | ")}
-	} else {
-		execCtx = ctx.Context
-		cmds = []string{"/bin/sh", "-c", ctx.Command.Command + " " + strings.Join(ctx.Command.Args, " ")}
-	}
-
-	// 准备环境变量
-	var env []string
-	envMap := make(map[string]string)
-
-	// 首先添加执行器级别的环境变量
-	for k, v := range e.options.Env {
-		envMap[k] = v
-	}
-
-	// 然后添加上下文级别的环境变量（可能会覆盖执行器级别的）
-	if ctx.Options != nil {
-		for k, v := range ctx.Options.Env {
-			envMap[k] = v
-		}
-	}
-
-	// 将合并后的环境变量转换为切片格式
-	for k, v := range envMap {
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	execCtx := ctx.Context
+	script := shellCommand(&ctx.Command)
+	if execCtx == nil {
+		execCtx = context.Background()
 	}
+	pidFile := pidFilePrefix + uuid.New().String() + ".pid"
+	cmds := wrapCommand(script, pidFile)
 
 	// 创建执行配置
 	execConfig := container.ExecOptions{
@@ -360,19 +439,19 @@ func (e *DockerExecutor) ExecuteCommand(ctx *runshellTypes.ExecuteContext) (*run
 		AttachStdin:  ctx.Options.Stdin != nil,
 		AttachStdout: true,
 		AttachStderr: true,
-		Env:          env,
+		Env:          e.env(ctx.Options.Env),
 		Tty:          false,
 	}
 
 	// 创建执行实例
-	log.Debug("Creating exec instance for command: %v", cmds)
+	log.Debug("Creating exec instance for command: %s", script)
 	execResp, err := cli.ContainerExecCreate(execCtx, e.containerID, execConfig)
 	if err != nil {
 		log.Error("Failed to create exec instance: %v", err)
 		return nil, fmt.Errorf("failed to create exec instance: %v", err)
 	}
 
-	// 附加到执���实例
+	// 附加到执行实例
 	log.Debug("Attaching to exec instance: %s", execResp.ID)
 	resp, err := cli.ContainerExecAttach(execCtx, execResp.ID, container.ExecAttachOptions{})
 	if err != nil {
@@ -384,80 +463,194 @@ func (e *DockerExecutor) ExecuteCommand(ctx *runshellTypes.ExecuteContext) (*run
 	// 设置开始时间
 	startTime := runshellTypes.GetTimeNow()
 
-	// 创建输出缓冲区
+	// 创建输出缓冲区，标准输出和标准错误分别写入对应的输出流
 	var outputBuf bytes.Buffer
-	var writers []io.Writer
-	writers = append(writers, &outputBuf)
+	stdout := []io.Writer{&outputBuf}
 	if ctx.Options.Stdout != nil {
-		writers = append(writers, ctx.Options.Stdout)
+		stdout = append(stdout, ctx.Options.Stdout)
+	}
+	stderr := []io.Writer{&outputBuf}
+	if ctx.Options.Stderr != nil {
+		stderr = append(stderr, ctx.Options.Stderr)
 	}
-	outputWriter := io.MultiWriter(writers...)
 
 	// 创建完成通道
 	done := make(chan struct{})
 	var copyErr error
 
-	// 单个 goroutine 处理所有 I/O
+	// 如果有输入，将输入写入容器，输入结束后关闭写端使命令读到 EOF
+	if ctx.Options.Stdin != nil {
+		go func() {
+			io.Copy(resp.Conn, ctx.Options.Stdin)
+			resp.CloseWrite()
+		}()
+	}
+
+	// 处理输出，未分配终端时输出流中的标准输出和标准错误是多路复用的。
+	// 进程结束后 Docker 关闭输出流，因此输出流结束即表示命令执行完成
 	go func() {
 		defer close(done)
-
-		// 如果有输入，先处理输入
-		if ctx.Options.Stdin != nil {
-			go func() {
-				io.Copy(resp.Conn, ctx.Options.Stdin)
-				resp.CloseWrite()
-			}()
-		}
-
-		// 处理输出
-		_, err := io.Copy(outputWriter, resp.Reader)
+		_, err := stdcopy.StdCopy(io.MultiWriter(stdout...), io.MultiWriter(stderr...), resp.Reader)
 		if err != nil && err != io.EOF {
 			copyErr = err
 		}
 	}()
 
-	// 等待命令完成
-	var result *runshellTypes.ExecuteResult
-	for {
-		select {
-		case <-done:
-			if copyErr != nil {
-				return nil, fmt.Errorf("error copying data: %v", copyErr)
-			}
-		default:
-		}
+	// 等待命令完成，取消或超时时在容器内终止进程
+	cancelErr := e.wait(execCtx, cli, resp, done, pidFile)
+	endTime := runshellTypes.GetTimeNow()
 
-		inspectResp, err := cli.ContainerExecInspect(execCtx, execResp.ID)
-		if err != nil {
-			log.Error("Failed to inspect exec instance: %v", err)
-			break
+	result := &runshellTypes.ExecuteResult{
+		CommandName: ctx.Command.Command,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		Output:      outputBuf.String(),
+		ExitCode:    -1,
+	}
+
+	if cancelErr != nil {
+		if exitCode, err := execExitCode(cli, execResp.ID); err == nil {
+			result.ExitCode = exitCode
 		}
+		result.Error = cancelErr
+		return result, cancelErr
+	}
+	if copyErr != nil {
+		return nil, fmt.Errorf("error copying data: %v", copyErr)
+	}
 
-		if !inspectResp.Running {
-			endTime := runshellTypes.GetTimeNow()
+	exitCode, err := execExitCode(cli, execResp.ID)
+	if err != nil {
+		log.Error("Failed to inspect exec instance: %v", err)
+		return nil, fmt.Errorf("failed to inspect exec instance: %v", err)
+	}
+	result.ExitCode = exitCode
+	if exitCode != 0 {
+		err = fmt.Errorf("command exited with code %d", exitCode)
+		result.Error = err
+		log.Error("Command %s failed with exit code %d: %s", script, exitCode, result.Output)
+		return result, err
+	}
 
-			result = &runshellTypes.ExecuteResult{
-				CommandName: ctx.Command.Command,
-				StartTime:   startTime,
-				EndTime:     endTime,
-				Output:      outputBuf.String(),
-				ExitCode:    inspectResp.ExitCode,
-			}
+	log.Info("Command completed successfully: %s", script)
+	return result, nil
+}
 
-			if inspectResp.ExitCode != 0 {
-				err = fmt.Errorf("command exited with code %d", inspectResp.ExitCode)
-				result.Error = err
-				log.Error("Command %v failed with exit code %d: %s", cmds, inspectResp.ExitCode, result.Output)
-				return result, err
-			}
+// env 合并执行器级别和上下文级别的环境变量，后者优先
+func (e *DockerExecutor) env(extra map[string]string) []string {
+	envMap := make(map[string]string)
+	for k, v := range e.options.Env {
+		envMap[k] = v
+	}
+	for k, v := range extra {
+		envMap[k] = v
+	}
+	env := make([]string, 0, len(envMap))
+	for k, v := range envMap {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	return env
+}
 
-			log.Info("Command completed successfully: %s", cmds)
-			return result, nil
-		}
-		time.Sleep(100 * time.Millisecond)
+// wait 等待输出流结束。ctx 取消时在容器内终止进程并返回取消的原因，
+// 进程无法终止时关闭连接，不再等待
+func (e *DockerExecutor) wait(ctx context.Context, cli *client.Client, resp types.HijackedResponse, done <-chan struct{}, pidFile string) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
 	}
+	log.Info("Killing process in container %s: %v", e.containerID, ctx.Err())
+	if err := e.killProcess(cli, pidFile); err != nil {
+		log.Error("Failed to kill process in container %s: %v", e.containerID, err)
+	}
+	select {
+	case <-done:
+	case <-time.After(killTimeout):
+		resp.Close()
+		<-done
+	}
+	return ctx.Err()
+}
+
+// wrapCommand 返回在容器中执行命令行的参数。
+// 外层 shell 将自身的 PID 写入 pidFile，命令结束后删除该文件并返回命令的退出码，
+// 取消时根据 pidFile 在容器内终止外层 shell 及其所有子进程。
+func wrapCommand(script, pidFile string) []string {
+	const wrapper = `{ echo $$ > "$1"; } 2>/dev/null; /bin/sh -c "$2"; status=$?; rm -f "$1"; exit $status`
+	return []string{"/bin/sh", "-c", wrapper, "sh", pidFile, script}
+}
+
+// killScript 终止 pidFile 中记录的进程及其所有子进程。
+// 命令刚启动就被取消时包装脚本可能还没写入 pid，因此先等待 pid 文件出现，
+// 超时仍读不到 pid 时以非零状态退出。
+// 优先通过 /proc 查找子进程，内核不支持时使用 pgrep
+const killScript = `children() { cat /proc/$1/task/*/children 2>/dev/null || pgrep -P $1 2>/dev/null; }
+tree() { for c in $(children $1); do tree $c; done; echo $1; }
+i=0
+until pid=$(cat "$1" 2>/dev/null) && [ -n "$pid" ]; do
+  i=$((i+1))
+  if [ $i -gt 50 ]; then echo "no pid in $1" >&2; exit 1; fi
+  sleep 0.1 2>/dev/null || sleep 1
+done
+kill -KILL $(tree $pid) 2>/dev/null
+rm -f "$1"`
+
+// killProcess 在容器内终止 pidFile 中记录的进程。
+// 调用方的上下文已经取消，因此使用独立的超时上下文
+func (e *DockerExecutor) killProcess(cli *client.Client, pidFile string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), killTimeout)
+	defer cancel()
+
+	execResp, err := cli.ContainerExecCreate(ctx, e.containerID, container.ExecOptions{
+		User:         e.config.User,
+		Cmd:          []string{"/bin/sh", "-c", killScript, "sh", pidFile},
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create kill exec: %w", err)
+	}
+	resp, err := cli.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to attach to kill exec: %w", err)
+	}
+	defer resp.Close()
+
+	var stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(io.Discard, &stderr, resp.Reader); err != nil {
+		return fmt.Errorf("failed to read kill exec output: %w", err)
+	}
+	exitCode, err := execExitCode(cli, execResp.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect kill exec: %w", err)
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("kill exec exited with code %d: %s", exitCode, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// execExitCode 返回执行实例的退出码。
+// 输出流结束时 Docker 可能还没有记录退出码，此时短暂重试
+func execExitCode(cli *client.Client, execID string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), killTimeout)
+	defer cancel()
 
-	return nil, fmt.Errorf("failed to execute command")
+	for delay := 5 * time.Millisecond; ; delay = min(delay*2, 100*time.Millisecond) {
+		inspect, err := cli.ContainerExecInspect(ctx, execID)
+		if err != nil {
+			return -1, err
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return -1, fmt.Errorf("exec %s is still running", execID)
+		case <-time.After(delay):
+		}
+	}
 }
 
 // Close 关闭执行器，清理资源
@@ -467,8 +660,12 @@ func (e *DockerExecutor) Close() error {
 
 	if e.containerID != "" {
 		log.Debug("Removing container: %s", e.containerID)
-		cmd := exec.Command("docker", "rm", "-f", e.containerID)
-		if err := cmd.Run(); err != nil {
+		cli, err := newDockerClient()
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		if err := cli.ContainerRemove(context.Background(), e.containerID, container.RemoveOptions{Force: true}); err != nil {
 			log.Error("Failed to remove container %s: %v", e.containerID, err)
 			return fmt.Errorf("failed to remove container: %v", err)
 		}
@@ -550,143 +747,142 @@ func (b *DockerExecutorBuilder) WithOptions(options *runshellTypes.ExecuteOption
 	return b
 }
 
-// ExecuteInteractive 在 Docker 容器中执行交互式命令
+// shellCommand 将命令转换为 /bin/sh -c 执行的命令行。
+// 命令名称和参数都逐个加上引号，不会被 shell 解释；需要 shell 语法时显式执行 sh -c。
+func shellCommand(cmd *runshellTypes.Command) string {
+	return shellescape.QuoteCommand(append([]string{cmd.Command}, cmd.Args...))
+}
+
+// ExecuteInteractive 在 Docker 容器中执行交互式命令。
+// 通过 Docker API 创建分配终端的执行实例，命令运行期间应用收到的终端大小调整。
 func (e *DockerExecutor) ExecuteInteractive(ctx *runshellTypes.ExecuteContext) (*runshellTypes.ExecuteResult, error) {
 	if err := e.ensureContainer(); err != nil {
 		return nil, fmt.Errorf("failed to ensure container: %v", err)
 	}
 
+	cli, err := newDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
 	if ctx.Options == nil {
 		ctx.Options = &runshellTypes.ExecuteOptions{}
 	}
-
-	// 构建 docker exec 命令
-	args := []string{
-		"exec",
-		"-it", // 交互式和伪终端
+	opts := ctx.InteractiveOpts
+	if opts == nil {
+		opts = &runshellTypes.InteractiveOptions{}
 	}
-
-	// 设置工作目录
-	if e.config.WorkDir != "" {
-		args = append(args, "-w", e.config.WorkDir)
+	workDir := ctx.Options.WorkDir
+	if workDir == "" {
+		workDir = e.config.WorkDir
 	}
-
-	// 设置用户
-	if e.config.User != "" {
-		args = append(args, "--user", e.config.User)
+	env := e.env(ctx.Options.Env)
+	if opts.TerminalType != "" {
+		env = append(env, "TERM="+opts.TerminalType)
 	}
-
-	// 添加环境变量
-	if ctx.Options.Env != nil {
-		for k, v := range ctx.Options.Env {
-			args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
-		}
+	execCtx := ctx.Context
+	if execCtx == nil {
+		execCtx = context.Background()
 	}
 
-	// 添加容器ID
-	args = append(args, e.containerID)
-
-	// 添加要执行的命令
-	args = append(args, ctx.Command.Command)
-	args = append(args, ctx.Command.Args...)
-
-	// 创建命令
-	cmd := exec.CommandContext(ctx.Context, "docker", args...)
+	// 设置终端大小
+	var consoleSize *[2]uint
+	if opts.Rows > 0 && opts.Cols > 0 {
+		consoleSize = &[2]uint{uint(opts.Rows), uint(opts.Cols)}
+	}
 
-	// 创建伪终端
-	ptmx, err := pty.Start(cmd)
+	pidFile := pidFilePrefix + uuid.New().String() + ".pid"
+	execResp, err := cli.ContainerExecCreate(execCtx, e.containerID, container.ExecOptions{
+		User:         e.config.User,
+		WorkingDir:   workDir,
+		Cmd:          wrapCommand(shellCommand(&ctx.Command), pidFile),
+		Tty:          true,
+		ConsoleSize:  consoleSize,
+		AttachStdin:  ctx.Options.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          env,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to start pty: %w", err)
+		return nil, fmt.Errorf("failed to create exec instance: %v", err)
 	}
-	defer ptmx.Close()
-
-	// 设置端大小
-	if ctx.InteractiveOpts != nil && ctx.InteractiveOpts.Rows > 0 && ctx.InteractiveOpts.Cols > 0 {
-		if err := pty.Setsize(ptmx, &pty.Winsize{
-			Rows: ctx.InteractiveOpts.Rows,
-			Cols: ctx.InteractiveOpts.Cols,
-		}); err != nil {
-			log.Error("Failed to resize pty: %v", err)
-		}
+	resp, err := cli.ContainerExecAttach(execCtx, execResp.ID, container.ExecAttachOptions{Tty: true, ConsoleSize: consoleSize})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec instance: %v", err)
 	}
+	defer resp.Close()
+	startTime := runshellTypes.GetTimeNow()
 
-	// 创建等待组和错误通道
-	var wg sync.WaitGroup
-	errCh := make(chan error, 2)
-
-	// 处理输入
+	// 处理输入，输入结束后关闭写端使命令读到 EOF
 	if ctx.Options.Stdin != nil {
-		wg.Add(1)
 		go func() {
-			defer wg.Done()
-			_, err := io.Copy(ptmx, ctx.Options.Stdin)
-			if err != nil {
-				log.Error("Failed to copy stdin: %v", err)
-				errCh <- err
+			if _, err := io.Copy(resp.Conn, ctx.Options.Stdin); err != nil {
+				log.Debug("Failed to copy stdin: %v", err)
 			}
+			resp.CloseWrite()
 		}()
 	}
 
-	// 处理输出
-	if ctx.Options.Stdout != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			_, err := io.Copy(ctx.Options.Stdout, ptmx)
-			if err != nil {
-				log.Error("Failed to copy stdout: %v", err)
-				errCh <- err
-			}
-		}()
+	// 处理输出，分配终端时标准错误与标准输出合并且不经过多路复用
+	stdout := ctx.Options.Stdout
+	if stdout == nil {
+		stdout = io.Discard
 	}
-
-	// 创建完成通道
-	doneCh := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(doneCh)
-	}()
-
-	// 等待命令完成或出错
-	startTime := runshellTypes.GetTimeNow()
-	cmdDone := make(chan error, 1)
+	done := make(chan struct{})
 	go func() {
-		cmdDone <- cmd.Wait()
+		defer close(done)
+		if _, err := io.Copy(stdout, resp.Reader); err != nil {
+			log.Debug("Failed to copy stdout: %v", err)
+		}
 	}()
 
-	var cmdErr error
-	select {
-	case err := <-cmdDone:
-		cmdErr = err
-	case err := <-errCh:
-		cmdErr = err
-		cmd.Process.Kill()
-	case <-ctx.Context.Done():
-		cmdErr = ctx.Context.Err()
-		cmd.Process.Kill()
+	// 处理终端大小调整
+	resizeDone := make(chan struct{})
+	defer close(resizeDone)
+	if opts.Resize != nil {
+		go func() {
+			for {
+				select {
+				case size, ok := <-opts.Resize:
+					if !ok {
+						return
+					}
+					if err := cli.ContainerExecResize(execCtx, execResp.ID, container.ResizeOptions{
+						Height: uint(size.Rows),
+						Width:  uint(size.Cols),
+					}); err != nil {
+						log.Error("Failed to resize exec instance: %v", err)
+					}
+				case <-resizeDone:
+					return
+				}
+			}
+		}()
 	}
 
-	endTime := runshellTypes.GetTimeNow()
-
-	// 等待 IO 完成
-	<-doneCh
-
+	cancelErr := e.wait(execCtx, cli, resp, done, pidFile)
 	result := &runshellTypes.ExecuteResult{
 		CommandName: ctx.Command.Command,
 		StartTime:   startTime,
-		EndTime:     endTime,
+		EndTime:     runshellTypes.GetTimeNow(),
+		ExitCode:    -1,
 	}
-
-	if cmdErr != nil {
-		if exitErr, ok := cmdErr.(*exec.ExitError); ok {
-			result.ExitCode = exitErr.ExitCode()
-		} else {
-			result.ExitCode = 1
-		}
-		result.Error = cmdErr
-		return result, cmdErr
+	exitCode, err := execExitCode(cli, execResp.ID)
+	if err == nil {
+		result.ExitCode = exitCode
+	}
+	switch {
+	case cancelErr != nil:
+		err = cancelErr
+	case err != nil:
+		err = fmt.Errorf("failed to inspect exec instance: %v", err)
+	case exitCode != 0:
+		err = fmt.Errorf("command exited with code %d", exitCode)
+	}
+	if err != nil {
+		result.Error = err
+		return result, err
 	}
-
-	result.ExitCode = 0
 	return result, nil
 }
@@ -3,9 +3,13 @@ package docker
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/iamlongalong/runshell/pkg/types"
 	"github.com/stretchr/testify/assert"
@@ -79,7 +83,8 @@ func TestDockerExecutor_Execute(t *testing.T) {
 		{
 			name:        "Pipeline command",
 			dockerImage: "busybox:latest",
-			command:     "ls -la | grep total",
+			command:     "sh",
+			args:        []string{"-c", "ls -la | grep total"},
 			wantErr:     false,
 			wantOutput:  "total",
 			wantCode:    0,
@@ -87,7 +92,8 @@ func TestDockerExecutor_Execute(t *testing.T) {
 		{
 			name:        "Pipeline with grep no matches",
 			dockerImage: "busybox:latest",
-			command:     "ls -la | grep nonexistentfile",
+			command:     "sh",
+			args:        []string{"-c", "ls -la | grep nonexistentfile"},
 			wantErr:     true,
 			wantOutput:  "",
 			wantCode:    1,
@@ -95,7 +101,8 @@ func TestDockerExecutor_Execute(t *testing.T) {
 		{
 			name:        "Complex pipeline",
 			dockerImage: "busybox:latest",
-			command:     "ls -la /etc | grep conf | sort",
+			command:     "sh",
+			args:        []string{"-c", "ls -la /etc | grep conf | sort"},
 			wantErr:     false,
 			wantOutput:  "conf",
 			wantCode:    0,
@@ -182,28 +189,28 @@ func TestDockerExecutor_ExecutePipeline(t *testing.T) {
 
 	tests := []struct {
 		name          string
-		command       string
+		commands      [][]string
 		expectedErr   bool
 		expectedCode  int
 		expectedMatch string
 	}{
 		{
 			name:          "Simple pipeline",
-			command:       "echo hello | grep hello",
+			commands:      [][]string{{"echo", "hello"}, {"grep", "hello"}},
 			expectedErr:   false,
 			expectedCode:  0,
 			expectedMatch: "hello",
 		},
 		{
 			name:          "Pipeline with no matches",
-			command:       "echo hello | grep world",
+			commands:      [][]string{{"echo", "hello"}, {"grep", "world"}},
 			expectedErr:   true, // grep 命令没有找到匹配时返回错误
 			expectedCode:  1,    // grep 命令没有找到匹配时返回 1
 			expectedMatch: "",
 		},
 		{
 			name:          "Pipeline with multiple commands",
-			command:       "echo hello | grep hello | tr a-z A-Z",
+			commands:      [][]string{{"echo", "hello"}, {"grep", "hello"}, {"tr", "a-z", "A-Z"}},
 			expectedErr:   false,
 			expectedCode:  0,
 			expectedMatch: "HELLO",
@@ -222,16 +229,27 @@ func TestDockerExecutor_ExecutePipeline(t *testing.T) {
 			defer executor.Close()
 
 			var output bytes.Buffer
+			cmds := make([]*types.Command, len(tt.commands))
+			for i, c := range tt.commands {
+				cmds[i] = &types.Command{Command: c[0], Args: c[1:]}
+			}
 			result, err := executor.Execute(&types.ExecuteContext{
-				Context: context.Background(),
-				Command: types.Command{
-					Command: tt.command,
-				},
+				Context:     context.Background(),
+				IsPiped:     true,
+				PipeContext: &types.PipelineContext{Commands: cmds},
 				Options: &types.ExecuteOptions{
 					Stdout: &output,
 				},
 			})
 
+			// 每个命令的退出码和耗时都记录在结果中
+			if assert.NotNil(t, result) {
+				assert.Len(t, result.Stages, len(tt.commands))
+				for i, stage := range result.Stages {
+					assert.Equal(t, tt.commands[i][0], stage.Command)
+					assert.False(t, stage.EndTime.Before(stage.StartTime))
+				}
+			}
 			if tt.expectedErr {
 				assert.Error(t, err)
 				if result != nil {
@@ -493,3 +511,237 @@ func TestNewDockerExecutor(t *testing.T) {
 		})
 	}
 }
+
+func TestShellCommand(t *testing.T) {
+	assert.Equal(t, "ls", shellCommand(&types.Command{Command: "ls"}))
+	assert.Equal(t, "sh -c 'ls -la | grep total'", shellCommand(&types.Command{Command: "sh", Args: []string{"-c", "ls -la | grep total"}}))
+	assert.Equal(t, `echo 'a b' '$(id); rm -rf /' ''`, shellCommand(&types.Command{Command: "echo", Args: []string{"a b", "$(id); rm -rf /", ""}}))
+
+	// 命令名称中的 shell 语法不会被解释
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker")
+	cmd := exec.Command("/bin/sh", "-c", shellCommand(&types.Command{Command: "true; touch " + marker}))
+	assert.Error(t, cmd.Run())
+	assert.NoFileExists(t, marker)
+}
+
+func TestWrapCommand(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "cmd.pid")
+	args := wrapCommand("echo hello; exit 3", pidFile)
+	cmd := exec.Command(args[0], args[1:]...)
+	output, err := cmd.Output()
+	assert.Error(t, err)
+	assert.Equal(t, 3, cmd.ProcessState.ExitCode())
+	assert.Equal(t, "hello\n", string(output))
+	assert.NoFileExists(t, pidFile)
+}
+
+func TestKillScript(t *testing.T) {
+	t.Run("pid written late", func(t *testing.T) {
+		pidFile := filepath.Join(t.TempDir(), "cmd.pid")
+		sleep := exec.Command("sleep", "300")
+		assert.NoError(t, sleep.Start())
+		done := make(chan error, 1)
+		go func() { done <- sleep.Wait() }()
+
+		kill := exec.Command("/bin/sh", "-c", killScript, "sh", pidFile)
+		assert.NoError(t, kill.Start())
+		time.Sleep(300 * time.Millisecond)
+		assert.NoError(t, os.WriteFile(pidFile, []byte(fmt.Sprintf("%d\n", sleep.Process.Pid)), 0644))
+
+		assert.NoError(t, kill.Wait())
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			sleep.Process.Kill()
+			t.Fatal("process was not killed")
+		}
+		assert.NoFileExists(t, pidFile)
+	})
+
+	t.Run("no pid", func(t *testing.T) {
+		pidFile := filepath.Join(t.TempDir(), "cmd.pid")
+		kill := exec.Command("/bin/sh", "-c", killScript, "sh", pidFile)
+		output, err := kill.CombinedOutput()
+		assert.Error(t, err)
+		assert.Contains(t, string(output), "no pid")
+	})
+}
+
+func TestDockerExecutor_CancelRightAfterStart(t *testing.T) {
+	executor, err := NewDockerExecutor(types.DockerConfig{
+		Image:                     "busybox:latest",
+		AllowUnregisteredCommands: true,
+	}, &types.ExecuteOptions{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create Docker executor: %v", err)
+	}
+	defer executor.Close()
+
+	// 先启动容器，避免取消落在容器创建阶段
+	_, err = executor.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "true"},
+		Options: &types.ExecuteOptions{},
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = executor.Execute(&types.ExecuteContext{
+		Context: ctx,
+		Command: types.Command{Command: "sleep", Args: []string{"301"}},
+		Options: &types.ExecuteOptions{},
+	})
+	assert.Error(t, err)
+
+	// 即使取消早于 pid 写入，进程也会被终止
+	assert.Eventually(t, func() bool {
+		result, err := executor.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "ps"},
+			Options: &types.ExecuteOptions{},
+		})
+		return err == nil && !strings.Contains(result.Output, "sleep 301")
+	}, 15*time.Second, 500*time.Millisecond)
+}
+
+func TestDockerExecutor_Cancel(t *testing.T) {
+	executor, err := NewDockerExecutor(types.DockerConfig{
+		Image:                     "busybox:latest",
+		AllowUnregisteredCommands: true,
+	}, &types.ExecuteOptions{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create Docker executor: %v", err)
+	}
+	defer executor.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	result, err := executor.Execute(&types.ExecuteContext{
+		Context: ctx,
+		Command: types.Command{Command: "sh", Args: []string{"-c", "sleep 300 | cat"}},
+		Options: &types.ExecuteOptions{},
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotNil(t, result)
+	assert.Less(t, time.Since(start), 30*time.Second)
+
+	// 进程已在容器内被终止
+	result, err = executor.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "ps"},
+		Options: &types.ExecuteOptions{},
+	})
+	assert.NoError(t, err)
+	assert.NotContains(t, result.Output, "sleep 300")
+}
+
+func TestDockerExecutor_CancelPipeline(t *testing.T) {
+	executor, err := NewDockerExecutor(types.DockerConfig{
+		Image:                     "busybox:latest",
+		AllowUnregisteredCommands: true,
+	}, &types.ExecuteOptions{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create Docker executor: %v", err)
+	}
+	defer executor.Close()
+
+	// 只取消执行上下文，管道上下文保持有效
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = executor.Execute(&types.ExecuteContext{
+		Context: ctx,
+		IsPiped: true,
+		PipeContext: &types.PipelineContext{
+			Context:  context.Background(),
+			Commands: []*types.Command{{Command: "sleep", Args: []string{"302"}}, {Command: "cat"}},
+		},
+		Options: &types.ExecuteOptions{},
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 30*time.Second)
+
+	result, err := executor.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "ps"},
+		Options: &types.ExecuteOptions{},
+	})
+	assert.NoError(t, err)
+	assert.NotContains(t, result.Output, "sleep 302")
+}
+
+func TestDockerExecutor_ExecuteInteractive(t *testing.T) {
+	executor, err := NewDockerExecutor(types.DockerConfig{
+		Image:                     "busybox:latest",
+		AllowUnregisteredCommands: true,
+	}, &types.ExecuteOptions{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create Docker executor: %v", err)
+	}
+	defer executor.Close()
+
+	var output bytes.Buffer
+	resize := make(chan types.TerminalSize, 1)
+	resize <- types.TerminalSize{Rows: 50, Cols: 150}
+	result, err := executor.ExecuteInteractive(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "sh", Args: []string{"-c", "stty size; sleep 1; stty size; pwd; echo $TERM"}},
+		Options: &types.ExecuteOptions{WorkDir: "/tmp", Stdout: &output},
+		InteractiveOpts: &types.InteractiveOptions{
+			TerminalType: "xterm",
+			Rows:         40,
+			Cols:         120,
+			Resize:       resize,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Contains(t, output.String(), "50 150")
+	assert.Contains(t, output.String(), "/tmp")
+	assert.Contains(t, output.String(), "xterm")
+}
+
+func TestHostConfig(t *testing.T) {
+	executor := &DockerExecutor{config: types.DockerConfig{
+		Memory:          512 << 20,
+		CPUs:            1.5,
+		PidsLimit:       100,
+		NetworkMode:     "none",
+		ReadOnly:        true,
+		Tmpfs:           map[string]string{"/run": "size=16m"},
+		CapDrop:         []string{"ALL"},
+		NoNewPrivileges: true,
+		Ulimits:         []types.Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+	}}
+	hostConfig := executor.hostConfig()
+	assert.Equal(t, int64(512<<20), hostConfig.Memory)
+	assert.Equal(t, int64(1.5e9), hostConfig.NanoCPUs)
+	assert.Equal(t, int64(100), *hostConfig.PidsLimit)
+	assert.True(t, hostConfig.NetworkMode.IsNone())
+	assert.True(t, hostConfig.ReadonlyRootfs)
+	assert.Equal(t, map[string]string{"/run": "size=16m", "/tmp": ""}, hostConfig.Tmpfs)
+	assert.Equal(t, []string{"ALL"}, []string(hostConfig.CapDrop))
+	assert.Equal(t, []string{"no-new-privileges:true"}, hostConfig.SecurityOpt)
+	assert.Equal(t, "nofile", hostConfig.Ulimits[0].Name)
+}
+
+func TestParseStat(t *testing.T) {
+	out := []byte("81a4|3|1700000000.123456789|/workspace/a.txt\n" +
+		"41ed|4096|1700000000|/workspace/src\n" +
+		"81a4|1|1700000000.5|/workspace/debug.log\n")
+	tree, err := parseStat(out, "/workspace/", []string{"*.log"})
+	assert.NoError(t, err)
+	assert.Len(t, tree, 2)
+	assert.Equal(t, time.Unix(1700000000, 123456789), tree["a.txt"].ModTime)
+	assert.Equal(t, uint32(0644), tree["a.txt"].Mode)
+	assert.True(t, tree["src"].IsDir)
+	assert.Equal(t, int64(0), tree["src"].Size)
+	assert.Equal(t, time.Unix(1700000000, 0), tree["src"].ModTime)
+
+	// 不支持精度的 stat 输出无法解析时返回错误，由调用方退回到秒精度
+	_, err = parseStat([]byte("81a4|3|?|/workspace/a.txt\n"), "/workspace/", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,454 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	runshellTypes "github.com/iamlongalong/runshell/pkg/types"
+)
+
+// maxSymlinkDepth 读取文件时跟随符号链接的最大层数
+const maxSymlinkDepth = 8
+
+// containerFS 通过 Docker 归档 API 访问容器文件系统，实现 types.FileSystem 接口。
+// 不依赖容器内的系统命令，只有删除和重命名操作需要容器内提供 rm 和 mv。
+type containerFS struct {
+	executor *DockerExecutor
+	workDir  string
+}
+
+// FileSystem 实现 types.FileSystemProvider 接口
+func (e *DockerExecutor) FileSystem(workDir string) (runshellTypes.FileSystem, error) {
+	dir, err := e.workDir(workDir)
+	if err != nil {
+		dir = "/"
+	}
+	return &containerFS{executor: e, workDir: dir}, nil
+}
+
+// resolve 将路径解析为容器内的绝对路径
+func (f *containerFS) resolve(name string) string {
+	if path.IsAbs(name) {
+		return path.Clean(name)
+	}
+	return path.Join(f.workDir, name)
+}
+
+// client 确保容器运行并创建 Docker 客户端
+func (f *containerFS) client() (*client.Client, error) {
+	if err := f.executor.ensureContainer(); err != nil {
+		return nil, fmt.Errorf("failed to ensure container: %v", err)
+	}
+	return newDockerClient()
+}
+
+// pathError 将 Docker 的 NotFound 错误转换为 os.ErrNotExist
+func pathError(op, name string, err error) error {
+	if errdefs.IsNotFound(err) {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// Stat 实现 types.FileSystem 接口
+func (f *containerFS) Stat(ctx context.Context, name string) (*runshellTypes.FileInfo, error) {
+	cli, err := f.client()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	return f.stat(ctx, cli, name)
+}
+
+func (f *containerFS) stat(ctx context.Context, cli *client.Client, name string) (*runshellTypes.FileInfo, error) {
+	st, err := cli.ContainerStatPath(ctx, f.executor.containerID, f.resolve(name))
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+	return &runshellTypes.FileInfo{
+		Name:    st.Name,
+		Path:    name,
+		Size:    st.Size,
+		Mode:    st.Mode,
+		ModTime: st.Mtime,
+		IsDir:   st.Mode.IsDir(),
+		Link:    st.LinkTarget,
+	}, nil
+}
+
+// ReadDir 实现 types.FileSystem 接口
+func (f *containerFS) ReadDir(ctx context.Context, name string) ([]runshellTypes.FileInfo, error) {
+	cli, err := f.client()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	rc, st, err := cli.CopyFromContainer(ctx, f.executor.containerID, f.resolve(name))
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+	defer rc.Close()
+	if !st.Mode.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+
+	// 归档中第一层为目录本身，只保留其直接子项
+	var infos []runshellTypes.FileInfo
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %v", err)
+		}
+
+		_, rel, ok := strings.Cut(strings.TrimSuffix(hdr.Name, "/"), "/")
+		if !ok || rel == "" || strings.Contains(rel, "/") {
+			continue
+		}
+		infos = append(infos, headerFileInfo(path.Join(name, rel), hdr))
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+// ReadFile 实现 types.FileSystem 接口
+func (f *containerFS) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	rc, err := f.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %v", err)
+	}
+	return data, nil
+}
+
+// Open 实现 types.FileSystem 接口，边接收归档边读取文件内容
+func (f *containerFS) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	cli, err := f.client()
+	if err != nil {
+		return nil, err
+	}
+
+	p := f.resolve(name)
+	for depth := 0; depth < maxSymlinkDepth; depth++ {
+		rc, _, err := cli.CopyFromContainer(ctx, f.executor.containerID, p)
+		if err != nil {
+			cli.Close()
+			return nil, pathError("open", name, err)
+		}
+
+		tr := tar.NewReader(rc)
+		hdr, err := tr.Next()
+		if err != nil {
+			rc.Close()
+			cli.Close()
+			return nil, fmt.Errorf("failed to read archive: %v", err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			rc.Close()
+			cli.Close()
+			return nil, &os.PathError{Op: "read", Path: name, Err: fmt.Errorf("is a directory")}
+		case tar.TypeSymlink:
+			rc.Close()
+			if path.IsAbs(hdr.Linkname) {
+				p = hdr.Linkname
+			} else {
+				p = path.Join(path.Dir(p), hdr.Linkname)
+			}
+			continue
+		}
+
+		return &readCloser{
+			ReadCloser: io.NopCloser(tr),
+			close: func() error {
+				rc.Close()
+				return cli.Close()
+			},
+		}, nil
+	}
+	cli.Close()
+	return nil, &os.PathError{Op: "open", Path: name, Err: fmt.Errorf("too many levels of symbolic links")}
+}
+
+// WriteFile 实现 types.FileSystem 接口
+func (f *containerFS) WriteFile(ctx context.Context, name string, data []byte, perm os.FileMode) error {
+	cli, err := f.client()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	// 与 os.WriteFile 一致，已存在的文件保留原有权限
+	if info, err := f.stat(ctx, cli, name); err == nil {
+		if info.IsDir {
+			return &os.PathError{Op: "write", Path: name, Err: fmt.Errorf("is a directory")}
+		}
+		perm = info.Mode.Perm()
+	}
+
+	p := f.resolve(name)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     path.Base(p),
+		Typeflag: tar.TypeReg,
+		Mode:     int64(perm.Perm()),
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return f.copyTo(ctx, cli, "write", name, path.Dir(p), &buf)
+}
+
+// openFileScript 将标准输入写入文件 $1。文件不存在时 $3 为 1 则以权限 $2 创建，
+// $4 为 append 时追加，为 trunc 时清空后写入，否则从文件开头覆盖写入
+const openFileScript = `if [ ! -e "$1" ]; then
+  [ "$3" = 1 ] || { echo "$1: no such file or directory" >&2; exit 1; }
+  : > "$1" && chmod "$2" "$1" || exit 1
+fi
+case $4 in
+append) exec cat >> "$1" ;;
+trunc) exec cat > "$1" ;;
+*) exec cat 1<> "$1" ;;
+esac`
+
+// OpenFile 实现 types.FileSystem 接口。归档 API 只能写入完整的文件，
+// 因此通过容器内的 cat 将写入的内容从标准输入流式写入文件
+func (f *containerFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+	cli, err := f.client()
+	if err != nil {
+		return nil, err
+	}
+
+	create := "0"
+	if flag&os.O_CREATE != 0 {
+		create = "1"
+	}
+	mode := "write"
+	if flag&os.O_APPEND != 0 {
+		mode = "append"
+	} else if flag&os.O_TRUNC != 0 {
+		mode = "trunc"
+	}
+	cmd := []string{"/bin/sh", "-c", openFileScript, "sh", f.resolve(name), fmt.Sprintf("%o", perm.Perm()), create, mode}
+	execResp, err := cli.ContainerExecCreate(ctx, f.executor.containerID, container.ExecOptions{
+		User:         f.executor.config.User,
+		Cmd:          cmd,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		cli.Close()
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	resp, err := cli.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		cli.Close()
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	w := &execWriter{name: name, cli: cli, resp: resp, execID: execResp.ID, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		stdcopy.StdCopy(io.Discard, &w.stderr, resp.Reader)
+	}()
+	return w, nil
+}
+
+// execWriter 将写入的内容通过执行实例的标准输入传入容器，关闭时等待命令结束并检查退出码
+type execWriter struct {
+	name   string
+	cli    *client.Client
+	resp   types.HijackedResponse
+	execID string
+	done   chan struct{}
+	stderr bytes.Buffer
+}
+
+func (w *execWriter) Write(p []byte) (int, error) {
+	n, err := w.resp.Conn.Write(p)
+	if err != nil {
+		return n, &os.PathError{Op: "write", Path: w.name, Err: err}
+	}
+	return n, nil
+}
+
+func (w *execWriter) Close() error {
+	defer w.cli.Close()
+	defer w.resp.Close()
+	w.resp.CloseWrite()
+	<-w.done
+
+	exitCode, err := execExitCode(w.cli, w.execID)
+	if err != nil {
+		return &os.PathError{Op: "write", Path: w.name, Err: err}
+	}
+	if exitCode != 0 {
+		return &os.PathError{Op: "write", Path: w.name, Err: fmt.Errorf("%s", strings.TrimSpace(w.stderr.String()))}
+	}
+	return nil
+}
+
+// Mkdir 实现 types.FileSystem 接口
+func (f *containerFS) Mkdir(ctx context.Context, name string, perm os.FileMode, parents bool) error {
+	cli, err := f.client()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	p := f.resolve(name)
+	if info, err := f.stat(ctx, cli, p); err == nil {
+		if parents && info.IsDir {
+			return nil
+		}
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+
+	// 找到已存在的最深层上级目录，只为缺失的部分生成归档条目
+	base := path.Dir(p)
+	missing := []string{path.Base(p)}
+	for {
+		info, err := f.stat(ctx, cli, base)
+		if err == nil {
+			if !info.IsDir {
+				return &os.PathError{Op: "mkdir", Path: name, Err: fmt.Errorf("not a directory")}
+			}
+			break
+		}
+		if !parents || base == "/" {
+			return pathError("mkdir", name, err)
+		}
+		missing = append([]string{path.Base(base)}, missing...)
+		base = path.Dir(base)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := range missing {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     path.Join(missing[:i+1]...) + "/",
+			Typeflag: tar.TypeDir,
+			Mode:     int64(perm.Perm()),
+			ModTime:  time.Now(),
+		}); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return f.copyTo(ctx, cli, "mkdir", name, base, &buf)
+}
+
+// Remove 实现 types.FileSystem 接口。归档 API 不支持删除，因此通过容器内的 rm 完成。
+func (f *containerFS) Remove(ctx context.Context, name string, recursive bool) error {
+	cli, err := f.client()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	p := f.resolve(name)
+	if p == "/" || p == f.workDir {
+		return &os.PathError{Op: "remove", Path: name, Err: fmt.Errorf("refusing to remove work directory")}
+	}
+
+	info, err := f.stat(ctx, cli, name)
+	if err != nil {
+		return err
+	}
+
+	cmd := []string{"rm", "-f", p}
+	if info.IsDir {
+		cmd = []string{"rmdir", p}
+		if recursive {
+			cmd = []string{"rm", "-rf", p}
+		}
+	}
+	if _, err := f.executor.runHelper(ctx, cli, cmd); err != nil {
+		return &os.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Rename 实现 types.FileSystem 接口。归档 API 不支持重命名，因此通过容器内的 mv 完成。
+func (f *containerFS) Rename(ctx context.Context, oldName, newName string) error {
+	cli, err := f.client()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	if _, err := f.stat(ctx, cli, oldName); err != nil {
+		return err
+	}
+	if _, err := f.executor.runHelper(ctx, cli, []string{"mv", "-f", f.resolve(oldName), f.resolve(newName)}); err != nil {
+		return &os.PathError{Op: "rename", Path: oldName, Err: err}
+	}
+	return nil
+}
+
+// copyTo 将归档解压到容器中的目录
+func (f *containerFS) copyTo(ctx context.Context, cli *client.Client, op, name, dir string, r io.Reader) error {
+	err := cli.CopyToContainer(ctx, f.executor.containerID, dir, r, container.CopyToContainerOptions{
+		CopyUIDGID: true,
+	})
+	if err != nil {
+		return pathError(op, name, err)
+	}
+	return nil
+}
+
+// headerFileInfo 将归档条目转换为 types.FileInfo
+func headerFileInfo(name string, hdr *tar.Header) runshellTypes.FileInfo {
+	info := hdr.FileInfo()
+	fi := runshellTypes.FileInfo{
+		Name:    path.Base(name),
+		Path:    name,
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+	if hdr.Typeflag == tar.TypeSymlink {
+		fi.Link = hdr.Linkname
+	}
+	return fi
+}
@@ -0,0 +1,245 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/iamlongalong/runshell/pkg/tracker"
+	runshellTypes "github.com/iamlongalong/runshell/pkg/types"
+)
+
+// trackerSource 通过容器内的辅助命令扫描工作目录，实现 tracker.Source 和 tracker.ContentReader 接口。
+// 修改时间优先使用纳秒精度，容器中的 stat 不支持时退回到秒，此时需要更精确的比较应开启 Hash。
+type trackerSource struct {
+	executor *DockerExecutor
+}
+
+// Scan 实现 tracker.Source 接口
+func (s trackerSource) Scan(ctx context.Context, root string, opts *runshellTypes.TrackOptions) (tracker.Tree, error) {
+	e := s.executor
+	if err := e.ensureContainer(); err != nil {
+		return nil, fmt.Errorf("failed to ensure container: %v", err)
+	}
+
+	cli, err := newDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	patterns := tracker.IgnorePatterns(opts)
+	prefix := strings.TrimSuffix(root, "/") + "/"
+
+	// 列出所有文件的类型、权限、大小和修改时间。
+	// 优先使用纳秒精度的 %.9Y，stat 不支持时（例如旧版本的 busybox）退回到秒精度的 %Y
+	var tree tracker.Tree
+	for _, format := range []string{"%f|%s|%.9Y|%n", "%f|%s|%Y|%n"} {
+		cmd := append(findCmd(root, patterns), "-exec", "stat", "-c", format, "{}", "+")
+		var out []byte
+		if out, err = e.runHelper(ctx, cli, cmd); err != nil {
+			continue
+		}
+		if tree, err = parseStat(out, prefix, patterns); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts != nil && opts.Hash {
+		if err := s.hash(ctx, cli, root, prefix, patterns, tree); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// parseStat 解析 stat 输出的 "类型|大小|修改时间|路径" 行，修改时间可以带小数部分
+func parseStat(out []byte, prefix string, patterns []string) (tracker.Tree, error) {
+	tree := make(tracker.Tree)
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.SplitN(line, "|", 4)
+		if len(parts) != 4 || !strings.HasPrefix(parts[3], prefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(parts[3], prefix)
+		if tracker.Ignored(rel, patterns) {
+			continue
+		}
+
+		mode, err := strconv.ParseUint(parts[0], 16, 32)
+		if err != nil {
+			continue
+		}
+		size, _ := strconv.ParseInt(parts[1], 10, 64)
+		mtime, err := parseMtime(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid modification time %q for %s", parts[2], rel)
+		}
+
+		state := &tracker.FileState{
+			Path:    rel,
+			Size:    size,
+			Mode:    uint32(mode & 0777),
+			ModTime: mtime,
+			IsDir:   mode&0170000 == 0040000,
+		}
+		if state.IsDir {
+			state.Size = 0
+		}
+		tree[rel] = state
+	}
+	return tree, nil
+}
+
+// parseMtime 解析 "秒" 或 "秒.纳秒" 形式的修改时间
+func parseMtime(s string) (time.Time, error) {
+	sec, frac, _ := strings.Cut(s, ".")
+	secs, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var nsec int64
+	if frac != "" {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		if nsec, err = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(secs, nsec), nil
+}
+
+// hash 在容器内计算所有普通文件的 sha256
+func (s trackerSource) hash(ctx context.Context, cli *client.Client, root, prefix string, patterns []string, tree tracker.Tree) error {
+	cmd := append(findCmd(root, patterns), "-type", "f", "-exec", "sha256sum", "{}", "+")
+	out, err := s.executor.runHelper(ctx, cli, cmd)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			continue
+		}
+		if state, ok := tree[strings.TrimPrefix(name, prefix)]; ok {
+			state.Hash = sum
+		}
+	}
+	return nil
+}
+
+// ReadContents 实现 tracker.ContentReader 接口。
+// 在容器内用 tar 只打包指定的文件，并边接收边解析，被忽略或超过大小上限的文件不会被传输。
+func (s trackerSource) ReadContents(ctx context.Context, root string, paths []string, tree tracker.Tree) error {
+	e := s.executor
+	cli, err := newDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	cmd := []string{"tar", "-cf", "-", "-C", root, "-T", "-"}
+	execResp, err := cli.ContainerExecCreate(ctx, e.containerID, container.ExecOptions{
+		User:         e.config.User,
+		Cmd:          cmd,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create exec instance: %v", err)
+	}
+	resp, err := cli.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to attach to exec instance: %v", err)
+	}
+	defer resp.Close()
+
+	// 通过标准输入传入文件列表，路径以 ./ 开头，避免被 tar 当作选项
+	go func() {
+		w := bufio.NewWriter(resp.Conn)
+		for _, p := range paths {
+			if !strings.Contains(p, "\n") {
+				fmt.Fprintf(w, "./%s\n", p)
+			}
+		}
+		w.Flush()
+		resp.CloseWrite()
+	}()
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	var stderr bytes.Buffer
+	go func() {
+		_, err := stdcopy.StdCopy(pw, &stderr, resp.Reader)
+		pw.CloseWithError(err)
+	}()
+
+	tr := tar.NewReader(pr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+		state, ok := tree[tracker.Rel(hdr.Name)]
+		// 扫描之后大小发生变化的文件不读取，避免读取超过上限的内容
+		if !ok || hdr.Typeflag != tar.TypeReg || hdr.Size != state.Size {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+		state.Content = data
+	}
+
+	inspect, err := cli.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect exec instance: %v", err)
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("helper command %v exited with code %d: %s", cmd, inspect.ExitCode, stderr.String())
+	}
+	return nil
+}
+
+// findCmd 构造遍历工作目录的 find 命令，忽略规则中不含路径分隔符的部分交给 find 剪枝
+func findCmd(root string, patterns []string) []string {
+	cmd := []string{"find", root, "-mindepth", "1"}
+
+	var names []string
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			names = append(names, pattern)
+		}
+	}
+	if len(names) == 0 {
+		return cmd
+	}
+
+	cmd = append(cmd, "(")
+	for i, name := range names {
+		if i > 0 {
+			cmd = append(cmd, "-o")
+		}
+		cmd = append(cmd, "-name", name)
+	}
+	return append(cmd, ")", "-prune", "-o")
+}
@@ -2,16 +2,20 @@ package executor
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
-	"strings"
 	"sync"
+	"syscall"
 
-	"al.essio.dev/pkg/shellescape"
 	"github.com/creack/pty"
+	"github.com/iamlongalong/runshell/pkg/archive"
+	"github.com/iamlongalong/runshell/pkg/fs"
 	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/tracker"
 	"github.com/iamlongalong/runshell/pkg/types"
 )
 
@@ -88,11 +92,36 @@ func (e *LocalExecutor) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult
 		return e.ExecuteInteractive(ctx)
 	}
 
+	// 开启文件变更跟踪时，在执行前后扫描工作目录
+	if ctx.Options.Track != nil {
+		root := ctx.Options.WorkDir
+		if root == "" {
+			root = "."
+		}
+		return tracker.Run(ctx.Context, tracker.LocalSource{}, root, ctx.Options.Track, func() (*types.ExecuteResult, error) {
+			return e.dispatch(ctx)
+		})
+	}
+
+	return e.dispatch(ctx)
+}
+
+// dispatch 将命令分发给内置命令或系统命令执行
+func (e *LocalExecutor) dispatch(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	// 管道中的内置命令由管道运行器分发
+	if ctx.IsPiped {
+		return e.executePipeline(ctx)
+	}
+
 	log.Debug("Executing command: %s %v", ctx.Command.Command, ctx.Command.Args)
 	// 检查是否是内置命令
 	if cmd, ok := e.commands.Load(ctx.Command.Command); ok {
+		command := cmd.(types.ICommand)
+		if err := types.ValidateArgs(command.Info(), ctx.Command.Args); err != nil {
+			return nil, err
+		}
 		ctx.Executor = e
-		return cmd.(types.ICommand).Execute(ctx)
+		return command.Execute(ctx)
 	}
 
 	if !e.config.AllowUnregisteredCommands {
@@ -198,11 +227,7 @@ func (e *LocalExecutor) execute(ctx *types.ExecuteContext) (*types.ExecuteResult
 	}
 
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			result.ExitCode = exitErr.ExitCode()
-		} else {
-			result.ExitCode = 1
-		}
+		result.ExitCode = ExitCode(err)
 		result.Error = err
 		log.Error("Command execution failed: %v", err)
 		return result, err
@@ -262,6 +287,59 @@ func (e *LocalExecutor) Close() error {
 	return nil
 }
 
+// FileSystem 实现 types.FileSystemProvider 接口，返回限制在工作目录内的宿主机文件系统。
+// 未配置工作目录时不做路径限制。
+func (e *LocalExecutor) FileSystem(workDir string) (types.FileSystem, error) {
+	if workDir == "" {
+		workDir = e.config.WorkDir
+	}
+	if workDir == "" && e.options != nil {
+		workDir = e.options.WorkDir
+	}
+	return fs.NewLocalFS(workDir)
+}
+
+// workDir 返回实际使用的工作目录
+func (e *LocalExecutor) workDir(workDir string) (string, error) {
+	if workDir == "" {
+		workDir = e.config.WorkDir
+	}
+	if workDir == "" && e.options != nil {
+		workDir = e.options.WorkDir
+	}
+	if workDir == "" {
+		return "", fmt.Errorf("work directory not specified")
+	}
+	info, err := os.Stat(workDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat work directory: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("work directory is not a directory: %s", workDir)
+	}
+	return workDir, nil
+}
+
+// ArchiveWorkDir 实现 types.WorkDirArchiver 接口
+func (e *LocalExecutor) ArchiveWorkDir(ctx context.Context, workDir string) (io.ReadCloser, error) {
+	dir, err := e.workDir(workDir)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("Archiving local work directory: %s", dir)
+	return archive.TarDir(ctx, dir), nil
+}
+
+// RestoreWorkDir 实现 types.WorkDirArchiver 接口
+func (e *LocalExecutor) RestoreWorkDir(ctx context.Context, workDir string, r io.Reader) error {
+	dir, err := e.workDir(workDir)
+	if err != nil {
+		return err
+	}
+	log.Debug("Restoring local work directory: %s", dir)
+	return archive.RestoreDir(dir, r)
+}
+
 // RegisterCommand 注册命令
 func (e *LocalExecutor) RegisterCommand(cmd types.ICommand) error {
 	if cmd == nil {
@@ -283,7 +361,7 @@ func (e *LocalExecutor) UnregisterCommand(name string) error {
 	return nil
 }
 
-// executePipeline 执行管道命令
+// executePipeline 在进程内执行管道命令，内置命令与外部进程通过管道直接相连
 func (e *LocalExecutor) executePipeline(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
 	if ctx.PipeContext == nil || len(ctx.PipeContext.Commands) == 0 {
 		return nil, fmt.Errorf("no commands in pipeline")
@@ -305,86 +383,75 @@ func (e *LocalExecutor) executePipeline(ctx *types.ExecuteContext) (*types.Execu
 		}
 	}
 
-	startTime := types.GetTimeNow()
+	stages := make([]PipelineStage, len(ctx.PipeContext.Commands))
+	for i, cmd := range ctx.PipeContext.Commands {
+		stages[i] = e.pipelineStage(*cmd)
+	}
 
-	// 使用 bash -c 来实现
-	cmds := []string{}
-	for _, cmd := range ctx.PipeContext.Commands {
-		if len(cmd.Args) > 0 {
-			// 只对包含空格的参数进行简单的引号包裹
-			quotedArgs := make([]string, len(cmd.Args))
-			for i, arg := range cmd.Args {
-				quotedArgs[i] = shellescape.Quote(arg)
-			}
-			cmds = append(cmds, fmt.Sprintf("%s %s", cmd.Command, strings.Join(quotedArgs, " ")))
-		} else {
-			cmds = append(cmds, cmd.Command)
-		}
+	// 管道的选项优先于命令的选项
+	options := ctx.Options.Merge(ctx.PipeContext.Options)
+	if options.WorkDir == "" && e.config.WorkDir != "" {
+		options = options.Merge(&types.ExecuteOptions{WorkDir: e.config.WorkDir})
 	}
-	cmdStr := strings.Join(cmds, " | ")
 
-	// Create command with explicit shell
-	cmd := exec.CommandContext(ctx.Context, "bash", "-c", cmdStr)
-	var stdoutBuf, stderrBuf bytes.Buffer
+	result, err := RunPipeline(ctx, stages, options, ctx.PipeContext.Pipefail)
+	if err != nil && result != nil {
+		log.Error("Pipeline execution failed: %v", err)
+	}
+	return result, err
+}
 
-	// Set up output redirection
-	if ctx.PipeContext != nil && ctx.PipeContext.Options != nil {
-		if ctx.PipeContext.Options.Stdin != nil {
-			cmd.Stdin = ctx.PipeContext.Options.Stdin
-		}
-		if ctx.PipeContext.Options.Stdout != nil {
-			cmd.Stdout = ctx.PipeContext.Options.Stdout
-		} else {
-			cmd.Stdout = &stdoutBuf
-		}
-		if ctx.PipeContext.Options.Stderr != nil {
-			cmd.Stderr = ctx.PipeContext.Options.Stderr
-		} else {
-			cmd.Stderr = &stderrBuf
-		}
-	} else {
-		cmd.Stdout = &stdoutBuf
-		cmd.Stderr = &stderrBuf
+// pipelineStage 返回管道中命令的执行函数，已注册的命令作为内置命令执行，其他命令作为外部进程执行
+func (e *LocalExecutor) pipelineStage(cmd types.Command) PipelineStage {
+	registered, ok := e.commands.Load(cmd.Command)
+	if !ok {
+		return PipelineStage{Command: cmd, Run: e.runProcess}
 	}
 
-	// Execute command
-	err := cmd.Run()
-	endTime := types.GetTimeNow()
+	command := registered.(types.ICommand)
+	return PipelineStage{Command: cmd, Run: func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+		if err := types.ValidateArgs(command.Info(), ctx.Command.Args); err != nil {
+			return nil, err
+		}
+		ctx.Executor = e
+		return command.Execute(ctx)
+	}}
+}
 
-	// Check context cancellation
-	if ctx.Context != nil && ctx.Context.Err() != nil {
-		return nil, ctx.Context.Err()
+// runProcess 执行管道中的外部命令，标准输入输出直接连接到管道，不在内存中缓存
+func (e *LocalExecutor) runProcess(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	result := &types.ExecuteResult{
+		CommandName: ctx.Command.Command,
+		StartTime:   types.GetTimeNow(),
 	}
 
-	// Prepare result
-	result := &types.ExecuteResult{
-		CommandName: cmdStr,
-		StartTime:   startTime,
-		EndTime:     endTime,
+	cmdPath, err := exec.LookPath(ctx.Command.Command)
+	if err != nil {
+		result.EndTime = types.GetTimeNow()
+		result.ExitCode = 127
+		result.Error = fmt.Errorf("command not found: %s", ctx.Command.Command)
+		return result, result.Error
 	}
 
-	// Get output from buffer if not using direct output
-	if ctx.PipeContext == nil || ctx.PipeContext.Options == nil || ctx.PipeContext.Options.Stdout == nil {
-		result.Output = stdoutBuf.String()
-		if stderrBuf.Len() > 0 {
-			if result.Output != "" {
-				result.Output += "\n"
-			}
-			result.Output += stderrBuf.String()
+	cmd := exec.CommandContext(ctx.Context, cmdPath, ctx.Command.Args...)
+	cmd.Dir = ctx.Options.WorkDir
+	if len(ctx.Options.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range ctx.Options.Env {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
 		}
 	}
+	cmd.Stdin = ctx.Options.Stdin
+	cmd.Stdout = ctx.Options.Stdout
+	cmd.Stderr = ctx.Options.Stderr
 
+	err = cmd.Run()
+	result.EndTime = types.GetTimeNow()
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			result.ExitCode = exitErr.ExitCode()
-		} else {
-			result.ExitCode = 1
-		}
+		result.ExitCode = ExitCode(err)
 		result.Error = err
 		return result, err
 	}
-
-	result.ExitCode = 0
 	return result, nil
 }
 
@@ -461,26 +528,48 @@ func (e *LocalExecutor) ExecuteInteractive(ctx *types.ExecuteContext) (*types.Ex
 	var wg sync.WaitGroup
 	errCh := make(chan error, 2)
 
-	// 处理输入
+	// 处理输入，输入流可能在命令结束后仍未关闭，因此不等待其完成
 	if ctx.Options.Stdin != nil {
-		wg.Add(1)
 		go func() {
-			defer wg.Done()
 			_, err := io.Copy(ptmx, ctx.Options.Stdin)
-			if err != nil {
+			if err != nil && !errors.Is(err, os.ErrClosed) {
 				log.Error("Failed to copy stdin: %v", err)
 				errCh <- err
 			}
 		}()
 	}
 
+	// 处理终端大小调整，命令结束后停止，避免在伪终端关闭后调整大小
+	resizeStop := make(chan struct{})
+	resizeDone := make(chan struct{})
+	go func() {
+		defer close(resizeDone)
+		if ctx.InteractiveOpts == nil || ctx.InteractiveOpts.Resize == nil {
+			return
+		}
+		for {
+			select {
+			case size, ok := <-ctx.InteractiveOpts.Resize:
+				if !ok {
+					return
+				}
+				if err := pty.Setsize(ptmx, &pty.Winsize{Rows: size.Rows, Cols: size.Cols}); err != nil {
+					log.Error("Failed to resize pty: %v", err)
+				}
+			case <-resizeStop:
+				return
+			}
+		}
+	}()
+
 	// 处理输出
 	if ctx.Options.Stdout != nil {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			_, err := io.Copy(ctx.Options.Stdout, ptmx)
-			if err != nil {
+			// 命令退出后读取伪终端返回 EIO，视为输出结束
+			if err != nil && !errors.Is(err, syscall.EIO) {
 				log.Error("Failed to copy stdout: %v", err)
 				errCh <- err
 			}
@@ -517,6 +606,8 @@ func (e *LocalExecutor) ExecuteInteractive(ctx *types.ExecuteContext) (*types.Ex
 
 	// 等待 IO 完成
 	<-doneCh
+	close(resizeStop)
+	<-resizeDone
 
 	result := &types.ExecuteResult{
 		CommandName: ctx.Command.Command,
@@ -525,11 +616,7 @@ func (e *LocalExecutor) ExecuteInteractive(ctx *types.ExecuteContext) (*types.Ex
 	}
 
 	if cmdErr != nil {
-		if exitErr, ok := cmdErr.(*exec.ExitError); ok {
-			result.ExitCode = exitErr.ExitCode()
-		} else {
-			result.ExitCode = 1
-		}
+		result.ExitCode = ExitCode(cmdErr)
 		result.Error = cmdErr
 		return result, cmdErr
 	}
@@ -3,10 +3,13 @@ package executor
 import (
 	"bytes"
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/iamlongalong/runshell/pkg/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // testCommand 是一个用于测试的命令实现
@@ -16,6 +19,7 @@ type testCommand struct {
 	usage       string
 	output      string
 	exitCode    int
+	schema      *types.ArgSchema
 }
 
 func (c *testCommand) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
@@ -34,6 +38,7 @@ func (c *testCommand) Info() types.CommandInfo {
 		Name:        c.name,
 		Description: c.description,
 		Usage:       c.usage,
+		Schema:      c.schema,
 	}
 }
 
@@ -257,6 +262,39 @@ func TestLocalExecutor_RegisterCommand(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestLocalExecutorValidateArgs(t *testing.T) {
+	exec := NewLocalExecutor(types.LocalConfig{}, nil, nil)
+	assert.NoError(t, exec.RegisterCommand(&testCommand{
+		name:   "greet",
+		output: "hi",
+		schema: &types.ArgSchema{
+			Args: []types.ArgSpec{
+				{Name: "times", Flag: "-n", Type: types.ArgTypeInt},
+				{Name: "name", Type: types.ArgTypeString, Required: true},
+			},
+		},
+	}))
+
+	run := func(args ...string) (*types.ExecuteResult, error) {
+		return exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "greet", Args: args},
+		})
+	}
+
+	result, err := run("-n", "2", "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", result.Output)
+
+	_, err = run("-n", "two", "bob")
+	var argErr *types.ArgError
+	assert.ErrorAs(t, err, &argErr)
+	assert.EqualError(t, err, `greet: invalid argument -n: expected an integer, got "two"`)
+
+	_, err = run()
+	assert.EqualError(t, err, "greet: invalid argument name: is required (expected at least 1 positional argument(s), got 0)")
+}
+
 func TestLocalExecutor_UnregisterCommand(t *testing.T) {
 	exec := NewLocalExecutor(types.LocalConfig{
 		AllowUnregisteredCommands: true,
@@ -508,5 +546,41 @@ func TestLocalExecutorPipelineCancel(t *testing.T) {
 	result, err := exec.executePipeline(ectx)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "context canceled")
-	assert.Nil(t, result)
+	require.NotNil(t, result)
+	require.Len(t, result.Stages, 1)
+	assert.Equal(t, "sleep", result.Stages[0].Command)
+}
+
+func TestLocalExecutorTrackChanges(t *testing.T) {
+	exec := NewLocalExecutor(types.LocalConfig{
+		AllowUnregisteredCommands: true,
+	}, nil, nil)
+
+	tempDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "keep.txt"), []byte("keep\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "old.txt"), []byte("old\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "edit.txt"), []byte("a\nb\n"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(tempDir, ".git"), 0755))
+
+	ctx := &types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{
+			Command: "sh",
+			Args:    []string{"-c", "rm old.txt && echo new > new.txt && echo c >> edit.txt && touch .git/index"},
+		},
+		Options: &types.ExecuteOptions{
+			WorkDir: tempDir,
+			Track:   &types.TrackOptions{Hash: true, Diff: true},
+		},
+	}
+
+	result, err := exec.Execute(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, result.Changes, 3)
+	assert.Equal(t, types.FileChange{Path: "edit.txt", Type: types.FileModified,
+		Diff: "--- a/edit.txt\n+++ b/edit.txt\n@@ -1,2 +1,3 @@\n a\n b\n+c\n"}, result.Changes[0])
+	assert.Equal(t, "new.txt", result.Changes[1].Path)
+	assert.Equal(t, types.FileCreated, result.Changes[1].Type)
+	assert.Equal(t, "old.txt", result.Changes[2].Path)
+	assert.Equal(t, types.FileDeleted, result.Changes[2].Type)
 }
@@ -0,0 +1,382 @@
+// Package executor 实现了命令执行器的核心功能。
+// 本文件实现了执行器中间件链以及内置的中间件。
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/policy"
+	"github.com/iamlongalong/runshell/pkg/shell"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// ExecuteFunc 执行命令的函数
+type ExecuteFunc func(ctx *types.ExecuteContext) (*types.ExecuteResult, error)
+
+// Middleware 包装 ExecuteFunc，可以在命令执行前后处理上下文和结果
+type Middleware func(next ExecuteFunc) ExecuteFunc
+
+// ChainedExecutor 是经过中间件包装的执行器。
+// Execute 和 ExecuteCommand 都会经过中间件，其他方法直接转发给被包装的执行器。
+type ChainedExecutor struct {
+	executor       types.Executor
+	execute        ExecuteFunc
+	executeCommand ExecuteFunc
+}
+
+// Chain 使用中间件包装执行器，第一个中间件位于最外层，最先处理请求
+func Chain(executor types.Executor, middlewares ...Middleware) *ChainedExecutor {
+	execute := ExecuteFunc(executor.Execute)
+	executeCommand := ExecuteFunc(executor.ExecuteCommand)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		execute = middlewares[i](execute)
+		executeCommand = middlewares[i](executeCommand)
+	}
+	return &ChainedExecutor{
+		executor:       executor,
+		execute:        execute,
+		executeCommand: executeCommand,
+	}
+}
+
+// Name 返回被包装的执行器名称
+func (e *ChainedExecutor) Name() string {
+	return e.executor.Name()
+}
+
+// Execute 经过中间件执行命令
+func (e *ChainedExecutor) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	return e.execute(ctx)
+}
+
+// ExecuteCommand 经过中间件直接执行命令
+func (e *ChainedExecutor) ExecuteCommand(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	return e.executeCommand(ctx)
+}
+
+// ListCommands 列出被包装的执行器的命令
+func (e *ChainedExecutor) ListCommands() []types.CommandInfo {
+	return e.executor.ListCommands()
+}
+
+// Close 关闭被包装的执行器
+func (e *ChainedExecutor) Close() error {
+	return e.executor.Close()
+}
+
+// Unwrap 返回被包装的执行器
+func (e *ChainedExecutor) Unwrap() types.Executor {
+	return e.executor
+}
+
+// commandName 返回用于日志和统计的命令名称，管道命令使用第一个命令
+func commandName(ctx *types.ExecuteContext) string {
+	if ctx.IsPiped && ctx.PipeContext != nil && len(ctx.PipeContext.Commands) > 0 {
+		return ctx.PipeContext.Commands[0].Command
+	}
+	return ctx.Command.Command
+}
+
+// pipelineCommands 返回上下文中的所有命令，管道命令返回管道中的每个命令
+func pipelineCommands(ctx *types.ExecuteContext) []types.Command {
+	if !ctx.IsPiped || ctx.PipeContext == nil {
+		return []types.Command{ctx.Command}
+	}
+	cmds := make([]types.Command, 0, len(ctx.PipeContext.Commands))
+	for _, cmd := range ctx.PipeContext.Commands {
+		cmds = append(cmds, *cmd)
+	}
+	return cmds
+}
+
+// LoggingMiddleware 记录命令的开始、结束和耗时
+func LoggingMiddleware() Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+			name := commandName(ctx)
+			log.Info("Executing command: %s %v", name, ctx.Command.Args)
+			start := time.Now()
+			result, err := next(ctx)
+			if err != nil {
+				log.Error("Command %s failed after %v: %v", name, time.Since(start), err)
+			} else {
+				log.Info("Command %s completed in %v", name, time.Since(start))
+			}
+			return result, err
+		}
+	}
+}
+
+// CommandMetrics 单个命令的执行统计
+type CommandMetrics struct {
+	Count         int64         `json:"count"`          // 执行次数
+	Failures      int64         `json:"failures"`       // 失败次数
+	TotalDuration time.Duration `json:"total_duration"` // 累计耗时
+	MaxDuration   time.Duration `json:"max_duration"`   // 最长耗时
+	CacheHits     int64         `json:"cache_hits"`     // 结果缓存命中次数
+	CacheMisses   int64         `json:"cache_misses"`   // 结果缓存未命中次数
+}
+
+// Metrics 按命令名称汇总执行统计
+type Metrics struct {
+	mu       sync.Mutex
+	commands map[string]*CommandMetrics
+}
+
+// NewMetrics 创建执行统计
+func NewMetrics() *Metrics {
+	return &Metrics{commands: make(map[string]*CommandMetrics)}
+}
+
+// Record 记录一次命令执行
+func (m *Metrics) Record(command string, duration time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cm := m.command(command)
+	cm.Count++
+	if failed {
+		cm.Failures++
+	}
+	cm.TotalDuration += duration
+	if duration > cm.MaxDuration {
+		cm.MaxDuration = duration
+	}
+}
+
+// RecordCache 记录一次结果缓存的命中或未命中
+func (m *Metrics) RecordCache(command string, hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cm := m.command(command)
+	if hit {
+		cm.CacheHits++
+	} else {
+		cm.CacheMisses++
+	}
+}
+
+// command 返回命令的统计，调用方需持有锁
+func (m *Metrics) command(command string) *CommandMetrics {
+	cm, ok := m.commands[command]
+	if !ok {
+		cm = &CommandMetrics{}
+		m.commands[command] = cm
+	}
+	return cm
+}
+
+// Snapshot 返回当前统计的副本
+func (m *Metrics) Snapshot() map[string]CommandMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make(map[string]CommandMetrics, len(m.commands))
+	for name, cm := range m.commands {
+		result[name] = *cm
+	}
+	return result
+}
+
+// MetricsMiddleware 将命令的执行次数、失败次数、耗时和结果缓存的命中情况记录到 metrics
+func MetricsMiddleware(metrics *Metrics) Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+			start := time.Now()
+			result, err := next(ctx)
+			metrics.Record(commandName(ctx), time.Since(start), err != nil)
+			if result != nil && result.CacheStatus != "" {
+				metrics.RecordCache(commandName(ctx), result.CacheStatus == types.CacheHit)
+			}
+			return result, err
+		}
+	}
+}
+
+// PolicyMiddleware 执行前通过策略判定命令，被拒绝时返回 *policy.DeniedError
+func PolicyMiddleware(p policy.Policy) Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+			cmds := pipelineCommands(ctx)
+			// 由 shell 脚本执行的命令同时检查脚本中的所有命令，在脚本的第一个命令执行前拒绝整个脚本
+			if script := shell.ScriptFromContext(ctx.Context); script != nil {
+				for _, cmd := range script.Commands() {
+					if !isShellBuiltin(cmd.Command) {
+						cmds = append(cmds, cmd)
+					}
+				}
+			}
+			for _, cmd := range cmds {
+				if decision := p.Evaluate(cmd); !decision.Allowed {
+					return nil, &policy.DeniedError{Reason: decision.Reason}
+				}
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// TimeoutMiddleware 为命令设置超时，执行选项中指定了超时时使用执行选项中的值
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+			d := timeout
+			if ctx.Options != nil && ctx.Options.Timeout > 0 {
+				d = time.Duration(ctx.Options.Timeout)
+			}
+			if d <= 0 {
+				return next(ctx)
+			}
+
+			parent := ctx.Context
+			if parent == nil {
+				parent = context.Background()
+			}
+			timeoutCtx, cancel := context.WithTimeout(parent, d)
+			defer cancel()
+
+			c := *ctx
+			c.Context = timeoutCtx
+			result, err := next(&c)
+			if err != nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) && !errors.Is(err, context.DeadlineExceeded) {
+				err = fmt.Errorf("command %s timed out after %v: %w: %w", commandName(ctx), d, context.DeadlineExceeded, err)
+			}
+			return result, err
+		}
+	}
+}
+
+// RetryMiddleware 在命令失败时重试，最多执行 attempts 次，每次重试前等待 backoff。
+// retryable 判断失败的执行是否需要重试，为 nil 时只重试没有返回结果的执行错误（如连接失败），
+// 参数错误、策略拒绝和取消不会重试。重试时已写入 Stdout/Stderr 的输出不会撤回。
+// 需要按命令配置重试条件和指数退避时使用 RetryRulesMiddleware。
+func RetryMiddleware(attempts int, backoff time.Duration, retryable func(*types.ExecuteResult, error) bool) Middleware {
+	if retryable == nil {
+		retryable = func(result *types.ExecuteResult, err error) bool {
+			return result == nil
+		}
+	}
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+			var lastErr error
+			wrapped := func(c *types.ExecuteContext) (*types.ExecuteResult, error) {
+				result, err := next(c)
+				lastErr = err
+				return result, err
+			}
+			return retry(ctx, wrapped, attempts,
+				func(int) time.Duration { return backoff },
+				func(result *types.ExecuteResult, stderr string) bool { return retryable(result, lastErr) })
+		}
+	}
+}
+
+// isPermanent 判断错误是否不应重试
+func isPermanent(err error) bool {
+	var argErr *types.ArgError
+	var deniedErr *policy.DeniedError
+	return errors.As(err, &argErr) || errors.As(err, &deniedErr) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// OutputLimitMiddleware 限制命令输出的大小。
+// Stdout 和 Stderr 各自最多写入 limit 字节，结果中的 Output 超出 limit 的部分被截断。
+func OutputLimitMiddleware(limit int) Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+			c := *ctx
+			if ctx.Options != nil && (ctx.Options.Stdout != nil || ctx.Options.Stderr != nil) {
+				options := *ctx.Options
+				if options.Stdout != nil {
+					options.Stdout = &limitWriter{w: options.Stdout, remaining: limit}
+				}
+				if options.Stderr != nil {
+					options.Stderr = &limitWriter{w: options.Stderr, remaining: limit}
+				}
+				c.Options = &options
+			}
+
+			result, err := next(&c)
+			if result != nil && len(result.Output) > limit {
+				omitted := len(result.Output) - limit
+				result.Output = result.Output[:limit] + fmt.Sprintf("\n... output truncated (%d bytes omitted)", omitted)
+			}
+			return result, err
+		}
+	}
+}
+
+// limitWriter 最多写入 remaining 字节，超出部分丢弃但不返回错误，避免命令因写入失败而中止
+type limitWriter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	remaining int
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.remaining <= 0 {
+		return len(p), nil
+	}
+	n := len(p)
+	if n > w.remaining {
+		n = w.remaining
+	}
+	written, err := w.w.Write(p[:n])
+	w.remaining -= written
+	if err != nil {
+		return written, err
+	}
+	return len(p), nil
+}
+
+// AuditMiddleware 在命令开始和结束时记录审计日志
+func AuditMiddleware(auditor types.Auditor) Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+			// 创建审计记录
+			execution := &types.CommandExecution{
+				ID:        uuid.New().String(),
+				Command:   ctx.Command,
+				StartTime: time.Now(),
+				Status:    "STARTED",
+				Attempt:   AttemptFromContext(ctx.Context),
+			}
+			if script := shell.ScriptFromContext(ctx.Context); script != nil {
+				execution.Script = script.String()
+			}
+
+			log.Debug("Recording command start in audit log")
+			auditor.LogCommandExecution(execution)
+
+			result, err := next(ctx)
+
+			// 更新审计记录
+			execution.EndTime = time.Now()
+			execution.Error = err
+			if result != nil {
+				execution.ExitCode = result.ExitCode
+				// 位于重试中间件外层时记录最后一次尝试的次数
+				if n := len(result.Attempts); n > 0 && execution.Attempt == 0 {
+					execution.Attempt = result.Attempts[n-1].Attempt
+				}
+			}
+			if err != nil {
+				execution.Status = "FAILED"
+			} else {
+				execution.Status = "COMPLETED"
+			}
+
+			log.Debug("Recording command completion in audit log")
+			auditor.LogCommandExecution(execution)
+
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/policy"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next ExecuteFunc) ExecuteFunc {
+			return func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+				calls = append(calls, name+":before")
+				result, err := next(ctx)
+				calls = append(calls, name+":after")
+				return result, err
+			}
+		}
+	}
+
+	base := &recordingExecutor{name: "base", commands: []types.CommandInfo{{Name: "ls"}}}
+	exec := Chain(base, trace("outer"), trace("inner"))
+	assert.Equal(t, "base", exec.Name())
+	assert.Equal(t, base.commands, exec.ListCommands())
+
+	result, err := exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "ls"}})
+	require.NoError(t, err)
+	assert.Equal(t, "base", result.Output)
+	assert.Equal(t, []string{"outer:before", "inner:before", "inner:after", "outer:after"}, calls)
+
+	// ExecuteCommand 同样经过中间件
+	calls = nil
+	_, err = exec.ExecuteCommand(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "ls"}})
+	require.NoError(t, err)
+	assert.Len(t, calls, 4)
+	assert.Len(t, base.calls, 2)
+
+	// 可以通过 types.As 找到被包装的执行器
+	found, ok := types.As[*recordingExecutor](exec)
+	require.True(t, ok)
+	assert.Same(t, base, found)
+
+	require.NoError(t, exec.Close())
+	assert.True(t, base.closed)
+}
+
+// funcExecutor 使用函数实现 Execute 的测试执行器
+type funcExecutor struct {
+	recordingExecutor
+	fn ExecuteFunc
+}
+
+func (e *funcExecutor) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	return e.fn(ctx)
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	metrics := NewMetrics()
+	exec := Chain(&funcExecutor{fn: func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+		if ctx.Command.Command == "false" {
+			return &types.ExecuteResult{ExitCode: 1}, errors.New("exit 1")
+		}
+		return &types.ExecuteResult{}, nil
+	}}, LoggingMiddleware(), MetricsMiddleware(metrics))
+
+	for _, cmd := range []string{"true", "true", "false"} {
+		exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: cmd}})
+	}
+	snapshot := metrics.Snapshot()
+	assert.Equal(t, int64(2), snapshot["true"].Count)
+	assert.Equal(t, int64(0), snapshot["true"].Failures)
+	assert.Equal(t, int64(1), snapshot["false"].Count)
+	assert.Equal(t, int64(1), snapshot["false"].Failures)
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	base := &recordingExecutor{name: "base"}
+	exec := Chain(base, PolicyMiddleware(&policy.RulePolicy{Deny: []policy.Rule{{Command: "rm"}}}))
+
+	_, err := exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "rm", Args: []string{"-rf", "/"}}})
+	var deniedErr *policy.DeniedError
+	assert.ErrorAs(t, err, &deniedErr)
+
+	_, err = exec.Execute(&types.ExecuteContext{
+		Context:     context.Background(),
+		IsPiped:     true,
+		PipeContext: &types.PipelineContext{Commands: []*types.Command{{Command: "ls"}, {Command: "rm"}}},
+	})
+	assert.ErrorAs(t, err, &deniedErr)
+	assert.Empty(t, base.calls)
+
+	_, err = exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "ls"}})
+	assert.NoError(t, err)
+	assert.Len(t, base.calls, 1)
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	exec := Chain(NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil), TimeoutMiddleware(200*time.Millisecond))
+
+	start := time.Now()
+	_, err := exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "sleep", Args: []string{"10"}}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// 执行选项中的超时优先
+	_, err = exec.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "sleep", Args: []string{"0.5"}},
+		Options: &types.ExecuteOptions{Timeout: int64(5 * time.Second)},
+	})
+	assert.NoError(t, err)
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	attempts := 0
+	flaky := &funcExecutor{fn: func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("connection reset")
+		}
+		return &types.ExecuteResult{Output: "ok"}, nil
+	}}
+	result, err := Chain(flaky, RetryMiddleware(3, time.Millisecond, nil)).Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "git"}})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", result.Output)
+	assert.Equal(t, 3, attempts)
+
+	// 命令本身的失败默认不重试
+	attempts = 0
+	failing := &funcExecutor{fn: func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+		attempts++
+		return &types.ExecuteResult{ExitCode: 1}, errors.New("exit 1")
+	}}
+	_, err = Chain(failing, RetryMiddleware(3, time.Millisecond, nil)).Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "git"}})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+
+	// 参数错误不重试
+	attempts = 0
+	invalid := &funcExecutor{fn: func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+		attempts++
+		return nil, &types.ArgError{Command: "head", Reason: "bad"}
+	}}
+	_, err = Chain(invalid, RetryMiddleware(3, time.Millisecond, nil)).Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "head"}})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestOutputLimitMiddleware(t *testing.T) {
+	exec := Chain(NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil), OutputLimitMiddleware(10))
+
+	var stdout bytes.Buffer
+	result, err := exec.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "sh", Args: []string{"-c", "printf '%0100d' 0"}},
+		Options: &types.ExecuteOptions{Stdout: &stdout},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("0", 10), stdout.String())
+	assert.True(t, strings.HasPrefix(result.Output, strings.Repeat("0", 10)+"\n"))
+	assert.Contains(t, result.Output, "90 bytes omitted")
+}
@@ -0,0 +1,247 @@
+// Package executor 实现了命令执行器的核心功能。
+// 本文件实现了进程内的管道运行器。
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"al.essio.dev/pkg/shellescape"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// PipelineStage 表示管道中的一个命令。
+// Run 从 ctx.Options.Stdin 读取输入并将输出写入 ctx.Options.Stdout，
+// 没有写入 Stdout 的命令，其结果中的 Output 会被写入下一个命令。
+type PipelineStage struct {
+	Command types.Command
+	Run     ExecuteFunc
+}
+
+// RunPipeline 并发运行管道中的所有命令，相邻命令之间使用操作系统管道连接，
+// 内置命令和外部进程可以出现在同一个管道中。
+// options 中的 Stdin 作为第一个命令的输入，Stdout 接收最后一个命令的输出，所有命令共享 Stderr；
+// Stdout 或 Stderr 为空时输出记录在结果的 Output 中。
+// 管道的退出码为最后一个命令的退出码，pipefail 为 true 时为最后一个失败命令的退出码。
+// 每个命令的结果记录在 Stages 中。ctx.Context 取消时所有命令一起取消，返回的错误为 ctx.Context 的错误。
+func RunPipeline(ctx *types.ExecuteContext, stages []PipelineStage, options *types.ExecuteOptions, pipefail bool) (*types.ExecuteResult, error) {
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("no commands in pipeline")
+	}
+	if options == nil {
+		options = &types.ExecuteOptions{}
+	}
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	runCtx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	var stdout io.Writer = &stdoutBuf
+	if options.Stdout != nil {
+		stdout = options.Stdout
+	}
+	stderr := &lockedWriter{w: &stderrBuf}
+	if options.Stderr != nil {
+		stderr.w = options.Stderr
+	}
+
+	// readers[i] 是第 i 个命令的输入，writers[i] 是第 i 个命令的输出
+	n := len(stages)
+	readers := make([]*os.File, n)
+	writers := make([]*os.File, n)
+	for i := 1; i < n; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			for j := 1; j < i; j++ {
+				readers[j].Close()
+				writers[j-1].Close()
+			}
+			return nil, fmt.Errorf("failed to create pipe: %w", err)
+		}
+		readers[i], writers[i-1] = r, w
+	}
+	// 取消时设置已过期的读写期限，唤醒阻塞在管道读写上的内置命令；
+	// 外部进程由 exec.CommandContext 终止。这里不能关闭文件，外部进程可能正在启动并使用其文件描述符
+	stop := context.AfterFunc(runCtx, func() {
+		now := time.Now()
+		for i := 0; i < n; i++ {
+			if readers[i] != nil {
+				readers[i].SetDeadline(now)
+			}
+			if writers[i] != nil {
+				writers[i].SetDeadline(now)
+			}
+		}
+	})
+	defer stop()
+
+	stageResults := make([]types.StageResult, n)
+	errs := make([]error, n)
+	startTime := types.GetTimeNow()
+
+	var wg sync.WaitGroup
+	for i, stage := range stages {
+		stageOptions := *options
+		stageOptions.Stdin = options.Stdin
+		if i > 0 {
+			stageOptions.Stdin = readers[i]
+		}
+		var stageStdout io.Writer = stdout
+		if i < n-1 {
+			stageStdout = writers[i]
+		}
+		counter := &countingWriter{w: stageStdout}
+		stageOptions.Stdout = counter
+		stageOptions.Stderr = stderr
+
+		stageCtx := ctx.Copy()
+		stageCtx.Context = runCtx
+		stageCtx.Command = stage.Command
+		stageCtx.IsPiped = false
+		stageCtx.PipeContext = nil
+		stageCtx.Options = &stageOptions
+
+		wg.Add(1)
+		go func(i int, stage PipelineStage, counter *countingWriter) {
+			defer wg.Done()
+			// 命令结束后关闭其两端的管道：下游命令读到 EOF，上游命令写入时收到 EPIPE
+			defer func() {
+				if readers[i] != nil {
+					readers[i].Close()
+				}
+				if writers[i] != nil {
+					writers[i].Close()
+				}
+			}()
+
+			record := types.StageResult{
+				Command:   stage.Command.Command,
+				Args:      stage.Command.Args,
+				Executor:  stage.Command.Executor,
+				StartTime: types.GetTimeNow(),
+			}
+			result, err := stage.Run(stageCtx)
+			// 命令没有写入输出流时，将结果中的输出写入下一个命令
+			if result != nil && result.Output != "" && counter.n == 0 {
+				io.WriteString(counter.w, result.Output)
+			}
+			record.EndTime = types.GetTimeNow()
+			if result != nil {
+				record.ExitCode = result.ExitCode
+			}
+			if err != nil {
+				record.Error = err.Error()
+				if record.ExitCode == 0 {
+					record.ExitCode = 1
+				}
+			}
+			stageResults[i] = record
+			errs[i] = err
+		}(i, stage, counter)
+	}
+	wg.Wait()
+	endTime := types.GetTimeNow()
+
+	status := n - 1
+	if pipefail {
+		for i := n - 1; i >= 0; i-- {
+			if stageResults[i].ExitCode != 0 {
+				status = i
+				break
+			}
+		}
+	}
+
+	cmds := make([]string, n)
+	for i, stage := range stages {
+		cmds[i] = shellescape.QuoteCommand(append([]string{stage.Command.Command}, stage.Command.Args...))
+		if stage.Command.Executor != "" {
+			cmds[i] = stage.Command.Executor + ":" + cmds[i]
+		}
+	}
+	result := &types.ExecuteResult{
+		CommandName: strings.Join(cmds, " | "),
+		ExitCode:    stageResults[status].ExitCode,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		Stages:      stageResults,
+	}
+	if options.Stdout == nil {
+		result.Output = stdoutBuf.String()
+	}
+	if options.Stderr == nil && stderrBuf.Len() > 0 {
+		if result.Output != "" {
+			result.Output += "\n"
+		}
+		result.Output += stderrBuf.String()
+	}
+
+	// 管道被取消时仍返回各命令的结果，错误为取消原因
+	if err := parent.Err(); err != nil {
+		result.Error = err
+		return result, err
+	}
+	if result.ExitCode != 0 {
+		err := errs[status]
+		if err == nil {
+			err = fmt.Errorf("exit status %d", result.ExitCode)
+		}
+		result.Error = fmt.Errorf("pipeline command %s failed: %w", stages[status].Command.Command, err)
+		return result, result.Error
+	}
+	return result, nil
+}
+
+// ExitCode 返回命令错误对应的退出码，供各执行器共用。
+// 本地进程被信号终止时与 shell 一致返回 128+信号值；实现了 ExitStatus() int 的错误
+// （例如 SSH 会话的退出错误）返回其非零的退出状态；其他错误返回 1
+func ExitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	var statusErr interface{ ExitStatus() int }
+	if errors.As(err, &statusErr) && statusErr.ExitStatus() != 0 {
+		return statusErr.ExitStatus()
+	}
+	return 1
+}
+
+// lockedWriter 允许多个命令并发写入同一个 Writer
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// countingWriter 记录写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
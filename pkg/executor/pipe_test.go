@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	osexec "os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upperStage 将输入转换为大写，用于模拟读取标准输入的内置命令
+func upperStage(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	data, err := io.ReadAll(ctx.Options.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ctx.Options.Stdout.Write(bytes.ToUpper(data))
+	return &types.ExecuteResult{}, err
+}
+
+func TestLocalExecutorNativePipeline(t *testing.T) {
+	exec := NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil)
+	require.NoError(t, exec.RegisterCommand(&testCommand{name: "greet", output: "hello\nworld\n"}))
+	require.NoError(t, exec.RegisterCommand(&testCommand{name: "fail", exitCode: 3}))
+
+	run := func(pipefail bool, cmds ...*types.Command) (*types.ExecuteResult, error) {
+		return exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			IsPiped: true,
+			PipeContext: &types.PipelineContext{
+				Context:  context.Background(),
+				Commands: cmds,
+				Pipefail: pipefail,
+			},
+		})
+	}
+
+	t.Run("builtin and external", func(t *testing.T) {
+		result, err := run(false, &types.Command{Command: "greet"}, &types.Command{Command: "grep", Args: []string{"wor"}})
+		require.NoError(t, err)
+		assert.Equal(t, "world\n", result.Output)
+		require.Len(t, result.Stages, 2)
+		assert.Equal(t, "greet", result.Stages[0].Command)
+		assert.Equal(t, []string{"wor"}, result.Stages[1].Args)
+		for _, stage := range result.Stages {
+			assert.Equal(t, 0, stage.ExitCode)
+			assert.False(t, stage.EndTime.Before(stage.StartTime))
+		}
+	})
+
+	t.Run("args are not interpreted by a shell", func(t *testing.T) {
+		result, err := run(false, &types.Command{Command: "echo", Args: []string{"$HOME; `id`"}}, &types.Command{Command: "cat"})
+		require.NoError(t, err)
+		assert.Equal(t, "$HOME; `id`\n", result.Output)
+	})
+
+	t.Run("stage exit codes", func(t *testing.T) {
+		result, err := run(false, &types.Command{Command: "fail"}, &types.Command{Command: "cat"})
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Equal(t, 3, result.Stages[0].ExitCode)
+
+		result, err = run(true, &types.Command{Command: "fail"}, &types.Command{Command: "cat"})
+		assert.Error(t, err)
+		assert.Equal(t, 3, result.ExitCode)
+
+		result, err = run(false, &types.Command{Command: "echo"}, &types.Command{Command: "nonexistentcmd123"})
+		assert.Error(t, err)
+		assert.Equal(t, 127, result.ExitCode)
+	})
+
+	t.Run("early exit of downstream stage", func(t *testing.T) {
+		result, err := run(true, &types.Command{Command: "yes"}, &types.Command{Command: "head", Args: []string{"-n", "2"}})
+		assert.Error(t, err)
+		assert.Equal(t, "y\ny\n", result.Output)
+		// yes 被 SIGPIPE 终止
+		assert.Equal(t, 141, result.ExitCode)
+		assert.Equal(t, 0, result.Stages[1].ExitCode)
+	})
+}
+
+func TestRunPipeline(t *testing.T) {
+	exec := NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil)
+
+	t.Run("streams between stages", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		result, err := RunPipeline(&types.ExecuteContext{Context: context.Background()}, []PipelineStage{
+			{Command: types.Command{Command: "sh", Args: []string{"-c", "echo abc; echo oops >&2"}}, Run: exec.runProcess},
+			{Command: types.Command{Command: "upper"}, Run: upperStage},
+			{Command: types.Command{Command: "tr", Args: []string{"B", "x"}}, Run: exec.runProcess},
+		}, &types.ExecuteOptions{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr}, false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Output)
+		assert.Equal(t, "AxC\n", stdout.String())
+		assert.Equal(t, "oops\n", stderr.String())
+		assert.Equal(t, `sh -c 'echo abc; echo oops >&2' | upper | tr B x`, result.CommandName)
+	})
+
+	t.Run("cancel all stages", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		result, err := RunPipeline(&types.ExecuteContext{Context: ctx}, []PipelineStage{
+			{Command: types.Command{Command: "sleep", Args: []string{"10"}}, Run: exec.runProcess},
+			{Command: types.Command{Command: "upper"}, Run: upperStage},
+		}, nil, false)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NotNil(t, result)
+		assert.ErrorIs(t, result.Error, context.DeadlineExceeded)
+		require.Len(t, result.Stages, 2)
+		assert.Equal(t, "sleep", result.Stages[0].Command)
+		assert.NotZero(t, result.Stages[0].ExitCode)
+		assert.False(t, result.Stages[1].EndTime.IsZero())
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	_, err := RunPipeline(&types.ExecuteContext{Context: context.Background()}, nil, nil, false)
+	assert.Error(t, err)
+}
+
+// statusError 模拟 SSH 会话的退出错误
+type statusError int
+
+func (e statusError) Error() string   { return fmt.Sprintf("exit status %d", int(e)) }
+func (e statusError) ExitStatus() int { return int(e) }
+
+func TestExitCode(t *testing.T) {
+	err := osexec.Command("sh", "-c", "exit 3").Run()
+	assert.Equal(t, 3, ExitCode(err))
+	err = osexec.Command("sh", "-c", "kill -TERM $$").Run()
+	assert.Equal(t, 128+int(syscall.SIGTERM), ExitCode(err))
+	assert.Equal(t, 7, ExitCode(fmt.Errorf("wrapped: %w", statusError(7))))
+	assert.Equal(t, 1, ExitCode(statusError(0)))
+	assert.Equal(t, 1, ExitCode(errors.New("boom")))
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/shell"
 	"github.com/iamlongalong/runshell/pkg/types"
 )
 
@@ -29,81 +30,40 @@ func (e *PipelineExecutor) Name() string {
 	return PipelineExecutorName
 }
 
-// ParsePipeline 解析管道命令
+// ParsePipeline 解析管道命令。
+// 命令按 shell 语法解析，只接受由简单命令组成的单个管道，变量不展开。
 func (e *PipelineExecutor) ParsePipeline(cmdStr string) (*types.PipelineContext, error) {
 	log.Debug("Parsing pipeline command: %s", cmdStr)
 
-	// 检查命令字符串是否为空
-	cmdStr = strings.TrimSpace(cmdStr)
-	if cmdStr == "" {
-		log.Error("Empty pipeline command")
-		return nil, fmt.Errorf("empty pipeline")
-	}
-
-	// 检查管道符号位置
-	if strings.HasPrefix(cmdStr, "|") || strings.HasSuffix(cmdStr, "|") {
-		log.Error("Invalid pipeline: starts or ends with pipe symbol")
-		return nil, fmt.Errorf("invalid pipeline: starts or ends with pipe symbol")
+	script, err := shell.Parse(cmdStr)
+	if err != nil {
+		log.Error("Failed to parse pipeline: %v", err)
+		return nil, err
 	}
-
-	// 按管道符分割命令
-	cmds := strings.Split(cmdStr, "|")
-	if len(cmds) == 0 {
+	if len(script.Stmts) == 0 {
 		log.Error("Empty pipeline command")
 		return nil, fmt.Errorf("empty pipeline")
 	}
+	p := script.Pipeline()
+	if p == nil || p.Negated {
+		log.Error("Not a pipeline: %s", cmdStr)
+		return nil, fmt.Errorf("not a pipeline: %s", cmdStr)
+	}
 
 	// 创建管道上下文
 	pipeline := &types.PipelineContext{
-		Commands: make([]*types.Command, 0, len(cmds)),
+		Commands: make([]*types.Command, 0, len(p.Commands)),
 		Options:  &types.ExecuteOptions{},
 	}
-
-	// 解析每个命令
-	for i, cmd := range cmds {
-		cmd = strings.TrimSpace(cmd)
-		if cmd == "" {
-			log.Error("Empty command at position %d", i+1)
-			return nil, fmt.Errorf("empty command at position %d", i+1)
-		}
-
-		// 分割命令和参数，保留引号内的空格
-		var parts []string
-		var current string
-		var inQuote bool
-		var quoteChar rune
-
-		for _, r := range cmd {
-			switch {
-			case r == '"' || r == '\'':
-				if !inQuote {
-					inQuote = true
-					quoteChar = r
-				} else if quoteChar == r {
-					inQuote = false
-					if current != "" {
-						parts = append(parts, current)
-						current = ""
-					}
-				} else {
-					current += string(r)
-				}
-			case r == ' ' && !inQuote:
-				if current != "" {
-					parts = append(parts, current)
-					current = ""
-				}
-			default:
-				current += string(r)
-			}
-		}
-		if current != "" {
-			parts = append(parts, current)
-		}
-
-		if len(parts) == 0 {
+	for i, cmd := range p.Commands {
+		simple, ok := cmd.(*shell.SimpleCommand)
+		if !ok || len(simple.Assigns) > 0 || len(simple.Redirects) > 0 || len(simple.Words) == 0 {
 			log.Error("Invalid command at position %d", i+1)
-			return nil, fmt.Errorf("invalid command at position %d", i+1)
+			return nil, fmt.Errorf("invalid command at position %d: %s", i+1, cmd)
+		}
+		parts := make([]string, len(simple.Words))
+		for j, w := range simple.Words {
+			parts[j] = w.Value()
 		}
 
 		log.Debug("Adding command to pipeline: %v", parts)
@@ -113,11 +73,6 @@ func (e *PipelineExecutor) ParsePipeline(cmdStr string) (*types.PipelineContext,
 		})
 	}
 
-	if len(pipeline.Commands) == 0 {
-		log.Error("No valid commands found in pipeline")
-		return nil, fmt.Errorf("no valid commands in pipeline")
-	}
-
 	log.Debug("Successfully parsed pipeline with %d commands", len(pipeline.Commands))
 	return pipeline, nil
 }
@@ -165,27 +120,36 @@ func (e *PipelineExecutor) Execute(ctx *types.ExecuteContext) (*types.ExecuteRes
 		return nil, fmt.Errorf("no command specified")
 	}
 
-	// 如果是管道命令
-	if strings.Contains(ctx.Command.Command, "|") {
-		// 解析管道命令
-		pipeline, err := e.ParsePipeline(ctx.Command.Command)
-		if err != nil {
-			log.Error("Failed to parse pipeline: %v", err)
-			return nil, fmt.Errorf("failed to parse pipeline: %w", err)
-		}
+	// 带参数或不含 shell 语法的命令直接执行
+	if len(ctx.Command.Args) > 0 || !strings.ContainsAny(ctx.Command.Command, shellSyntaxChars) {
+		return e.executor.Execute(ctx)
+	}
 
-		// 设置管道上下文
-		pipeline.Context = ctx.Context
-		pipeline.Options = ctx.Options
-		ctx.PipeContext = pipeline
-		ctx.IsPiped = true
+	// 解析为 shell 脚本，由解释器执行命令列表、条件执行和重定向
+	script, err := shell.Parse(ctx.Command.Command)
+	if err != nil {
+		log.Error("Failed to parse command: %v", err)
+		return nil, fmt.Errorf("failed to parse command: %w", err)
+	}
+	if len(script.Stmts) == 0 {
+		log.Error("No command specified")
+		return nil, fmt.Errorf("no command specified")
+	}
 
-		// 执行管道命令
-		return e.executePipeline(ctx)
+	log.Debug("Executing script: %s", script)
+	workDir := ""
+	if ctx.Options != nil {
+		workDir = ctx.Options.WorkDir
 	}
+	return newInterpreter(e.executor, workDir).run(ctx, script)
+}
+
+// shellSyntaxChars 是需要按 shell 语法解析的字符
+const shellSyntaxChars = " \t\n|&;()<>$`'\"\\*?[~#"
 
-	// 如果不是管道命令，直接执行
-	return e.executor.Execute(ctx)
+// Unwrap 返回被包装的执行器
+func (e *PipelineExecutor) Unwrap() types.Executor {
+	return e.executor
 }
 
 // ListCommands 实现 Executor 接口
@@ -0,0 +1,231 @@
+// Package remote 实现了远程执行器。
+// 远程执行器通过另一个 runshell 服务端的 HTTP API 执行命令，
+// 用于由前端实例将命令分发到多台构建机。
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/client"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/policy"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// RemoteExecutor 远程执行器
+type RemoteExecutor struct {
+	client  *client.Client
+	options *types.ExecuteOptions // 默认执行选项
+}
+
+// NewRemoteExecutor 创建远程执行器
+func NewRemoteExecutor(config types.RemoteConfig, options *types.ExecuteOptions) (*RemoteExecutor, error) {
+	c, err := NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteExecutor{client: c, options: options}, nil
+}
+
+// NewClient 根据配置创建访问远程服务端的客户端
+func NewClient(config types.RemoteConfig) (*client.Client, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("remote url not specified")
+	}
+	c, err := client.NewClient(config.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		c.WithHTTPClient(&http.Client{Transport: transport})
+	}
+
+	for k, v := range config.Headers {
+		c.WithHeader(k, v)
+	}
+	switch {
+	case config.Token != "":
+		c.WithHeader("Authorization", "Bearer "+config.Token)
+	case config.Username != "":
+		auth := base64.StdEncoding.EncodeToString([]byte(config.Username + ":" + config.Password))
+		c.WithHeader("Authorization", "Basic "+auth)
+	}
+	return c, nil
+}
+
+// newTLSConfig 根据配置创建 TLS 配置，未配置 TLS 相关选项时返回 nil
+func newTLSConfig(config types.RemoteConfig) (*tls.Config, error) {
+	if config.CAFile == "" && config.CertFile == "" && !config.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		data, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in ca file: %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Name 返回执行器名称
+func (e *RemoteExecutor) Name() string {
+	return types.ExecutorTypeRemote
+}
+
+// Client 返回访问远程服务端的客户端
+func (e *RemoteExecutor) Client() *client.Client {
+	return e.client
+}
+
+// Execute 在远程服务端执行命令。
+// 输出实时写入 Stdout 和 Stderr，取消 Context 或超时会终止远程命令，
+// 远程返回的参数错误和策略拒绝分别转换为 *types.ArgError 和 *policy.DeniedError。
+func (e *RemoteExecutor) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("context is nil")
+	}
+	if ctx.IsPiped {
+		if ctx.PipeContext == nil || len(ctx.PipeContext.Commands) == 0 {
+			return nil, fmt.Errorf("no commands in pipeline")
+		}
+	} else if ctx.Command.Command == "" {
+		return nil, fmt.Errorf("no command specified")
+	}
+
+	// 合并默认选项和用户自定义选项
+	ctx.Options = ctx.Options.Merge(e.options)
+	if ctx.Context == nil {
+		ctx.Context = context.Background()
+	}
+
+	// 超时时间同时传给远程服务端，本地超时后关闭连接，远程服务端随之取消命令
+	remoteCtx := *ctx
+	if ctx.Options.Timeout > 0 {
+		var cancel context.CancelFunc
+		remoteCtx.Context, cancel = context.WithTimeout(ctx.Context, time.Duration(ctx.Options.Timeout))
+		defer cancel()
+	}
+
+	log.Debug("Executing command on remote %s: %s %v", e.client.BaseURL(), ctx.Command.Command, ctx.Command.Args)
+	result, err := e.client.Execute(&remoteCtx)
+	if err != nil {
+		if ctxErr := remoteCtx.Context.Err(); ctxErr != nil && result == nil {
+			return nil, fmt.Errorf("remote command %s aborted: %w", ctx.Command.Command, ctxErr)
+		}
+		return result, mapError(ctx.Command.Command, err)
+	}
+	return result, nil
+}
+
+// ExecuteCommand 执行单个命令
+func (e *RemoteExecutor) ExecuteCommand(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	return e.Execute(ctx)
+}
+
+// ListCommands 列出远程服务端的命令
+func (e *RemoteExecutor) ListCommands() []types.CommandInfo {
+	return e.client.ListCommands()
+}
+
+// Close 关闭执行器
+func (e *RemoteExecutor) Close() error {
+	return e.client.Close()
+}
+
+// remoteError 保留远程服务端返回的错误信息，同时可以通过 errors.As 匹配对应的本地错误类型
+type remoteError struct {
+	*client.APIError
+	cause error
+}
+
+func (e *remoteError) Unwrap() []error {
+	return []error{e.APIError, e.cause}
+}
+
+// mapError 将远程服务端返回的错误转换为本地错误类型
+func mapError(command string, err error) error {
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	switch {
+	case errors.Is(apiErr, client.ErrBadRequest):
+		return &remoteError{APIError: apiErr, cause: &types.ArgError{Command: command, Reason: apiErr.Message}}
+	case errors.Is(apiErr, client.ErrForbidden):
+		return &remoteError{APIError: apiErr, cause: &policy.DeniedError{Reason: apiErr.Message}}
+	}
+	return err
+}
+
+// RemoteExecutorBuilder 是远程执行器的构建器。
+// 构建的执行器共用同一个客户端，复用到远程服务端的连接。
+type RemoteExecutorBuilder struct {
+	config  types.RemoteConfig
+	options *types.ExecuteOptions
+
+	mu     sync.Mutex
+	client *client.Client
+}
+
+// NewRemoteExecutorBuilder 创建一个新的远程执行器构建器。
+func NewRemoteExecutorBuilder(config types.RemoteConfig) *RemoteExecutorBuilder {
+	return &RemoteExecutorBuilder{
+		config: config,
+	}
+}
+
+// WithOptions 设置执行选项。
+func (b *RemoteExecutorBuilder) WithOptions(options *types.ExecuteOptions) *RemoteExecutorBuilder {
+	b.options = options
+	return b
+}
+
+// Build 构建并返回一个新的远程执行器实例。
+func (b *RemoteExecutorBuilder) Build(options *types.ExecuteOptions) (types.Executor, error) {
+	if options == nil {
+		options = b.options
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.client == nil {
+		c, err := NewClient(b.config)
+		if err != nil {
+			return nil, err
+		}
+		b.client = c
+	}
+	return &RemoteExecutor{client: b.client, options: options}, nil
+}
@@ -0,0 +1,211 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/client"
+	"github.com/iamlongalong/runshell/pkg/executor"
+	"github.com/iamlongalong/runshell/pkg/policy"
+	"github.com/iamlongalong/runshell/pkg/server"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "secret"
+
+// newRemoteServer 启动作为远程服务端的 runshell，请求需要携带 testToken
+func newRemoteServer(t *testing.T, tls bool) (*httptest.Server, string) {
+	gin.SetMode(gin.TestMode)
+	workDir := t.TempDir()
+	s := server.NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		UseBuiltinCommands:        true,
+		AllowUnregisteredCommands: true,
+		WorkDir:                   workDir,
+	}), ":0")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"unauthorized"}`))
+			return
+		}
+		s.Handler().ServeHTTP(w, r)
+	})
+
+	var ts *httptest.Server
+	if tls {
+		ts = httptest.NewTLSServer(handler)
+	} else {
+		ts = httptest.NewServer(handler)
+	}
+	t.Cleanup(ts.Close)
+	return ts, workDir
+}
+
+func TestRemoteExecutor(t *testing.T) {
+	ts, workDir := newRemoteServer(t, false)
+
+	exec, err := NewRemoteExecutorBuilder(types.RemoteConfig{URL: ts.URL, Token: testToken}).
+		WithOptions(&types.ExecuteOptions{WorkDir: workDir}).
+		Build(nil)
+	require.NoError(t, err)
+	defer exec.Close()
+	assert.Equal(t, types.ExecutorTypeRemote, exec.Name())
+	assert.NotEmpty(t, exec.ListCommands())
+
+	t.Run("stream output", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		result, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "sh", Args: []string{"-c", "pwd; echo err >&2"}},
+			Options: &types.ExecuteOptions{Stdout: &stdout, Stderr: &stderr},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Equal(t, workDir+"\n", stdout.String())
+		assert.Equal(t, "err\n", stderr.String())
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		result, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "sh", Args: []string{"-c", "exit 3"}},
+		})
+		assert.Error(t, err)
+		require.NotNil(t, result)
+		assert.Equal(t, 3, result.ExitCode)
+	})
+
+	t.Run("argument error", func(t *testing.T) {
+		_, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "head", Args: []string{"-n", "x", "a.txt"}},
+		})
+		var argErr *types.ArgError
+		assert.ErrorAs(t, err, &argErr)
+		assert.True(t, errors.Is(err, client.ErrBadRequest))
+		assert.Contains(t, err.Error(), "expected an integer")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "sleep", Args: []string{"10"}},
+			Options: &types.ExecuteOptions{Timeout: int64(200 * time.Millisecond)},
+		})
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := exec.Execute(&types.ExecuteContext{
+			Context: ctx,
+			Command: types.Command{Command: "sleep", Args: []string{"10"}},
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		exec, err := NewRemoteExecutor(types.RemoteConfig{URL: ts.URL}, nil)
+		require.NoError(t, err)
+		_, err = exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "pwd"},
+		})
+		var apiErr *client.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	})
+}
+
+func TestRemoteExecutorTLS(t *testing.T) {
+	ts, _ := newRemoteServer(t, true)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, certPEM, 0600))
+
+	execute := func(config types.RemoteConfig) error {
+		exec, err := NewRemoteExecutor(config, nil)
+		if err != nil {
+			return err
+		}
+		_, err = exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "true"},
+		})
+		return err
+	}
+
+	assert.Error(t, execute(types.RemoteConfig{URL: ts.URL, Token: testToken}))
+	assert.NoError(t, execute(types.RemoteConfig{URL: ts.URL, Token: testToken, CAFile: caFile}))
+	assert.NoError(t, execute(types.RemoteConfig{URL: ts.URL, Token: testToken, InsecureSkipVerify: true}))
+
+	_, err := NewRemoteExecutor(types.RemoteConfig{URL: ts.URL, CAFile: filepath.Join(t.TempDir(), "missing.pem")}, nil)
+	assert.Error(t, err)
+}
+
+func TestMapError(t *testing.T) {
+	err := mapError("rm", &client.APIError{StatusCode: http.StatusForbidden, Message: "rm is denied"})
+	var deniedErr *policy.DeniedError
+	require.ErrorAs(t, err, &deniedErr)
+	assert.Equal(t, "rm is denied", deniedErr.Reason)
+
+	apiErr := &client.APIError{StatusCode: http.StatusInternalServerError, Message: "boom"}
+	assert.Equal(t, apiErr, mapError("ls", apiErr))
+}
+
+func TestSessionOnRemote(t *testing.T) {
+	ts, remoteDir := newRemoteServer(t, false)
+
+	// 前端服务端将会话分发到远程服务端
+	front := server.NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		AllowUnregisteredCommands: true,
+		WorkDir:                   t.TempDir(),
+	}), ":0").WithRemote("build-1", NewRemoteExecutorBuilder(types.RemoteConfig{URL: ts.URL, Token: testToken}))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		front.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/v1/sessions", `{"executor_type":"remote","remote":"build-2"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do("POST", "/api/v1/sessions", `{"executor_type":"remote","options":{"workdir":"`+remoteDir+`"}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var sessResp types.SessionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessResp))
+
+	w = do("POST", "/api/v1/sessions/"+sessResp.Session.ID+"/exec", `{"command":"pwd"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp server.ExecResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 0, resp.ExitCode)
+	assert.Equal(t, remoteDir+"\n", resp.Output)
+
+	// 远程参数错误映射为 400
+	w = do("POST", "/api/v1/sessions/"+sessResp.Session.ID+"/exec", `{"command":"head","args":["-n","x"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
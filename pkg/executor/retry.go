@@ -0,0 +1,257 @@
+// Package executor 实现了命令执行器的核心功能。
+// 本文件实现了按命令规则配置的重试中间件。
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"path"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+const (
+	// DefaultRetryAttempts 重试规则未指定次数时的最大执行次数
+	DefaultRetryAttempts = 3
+	// DefaultRetryBackoff 重试规则未指定时第一次重试前的等待时间
+	DefaultRetryBackoff = time.Second
+	// DefaultRetryMaxBackoff 重试规则未指定时重试等待时间的上限
+	DefaultRetryMaxBackoff = 30 * time.Second
+
+	// maxRetryStderr 用于匹配 StderrPatterns 时保留的标准错误字节数
+	maxRetryStderr = 64 * 1024
+)
+
+// RetryRule 表示一条重试规则。
+// Command 使用 glob 匹配命令名称（忽略路径），为空或 "*" 时匹配所有命令；Args 中的每个 glob 都必须匹配至少一个参数。
+// 命令失败后，退出码在 ExitCodes 中或标准错误匹配 StderrPatterns 中的任一正则时重试，
+// 两者都为空时任何非零退出码都会重试。没有返回结果的执行错误（如连接失败）总是会重试。
+type RetryRule struct {
+	Command        string        `json:"command,omitempty"`
+	Args           []string      `json:"args,omitempty"`
+	MaxAttempts    int           `json:"max_attempts,omitempty"`    // 最大执行次数，包括第一次，默认 DefaultRetryAttempts
+	InitialBackoff time.Duration `json:"initial_backoff,omitempty"` // 第一次重试前的等待时间，默认 DefaultRetryBackoff
+	MaxBackoff     time.Duration `json:"max_backoff,omitempty"`     // 等待时间上限，默认 DefaultRetryMaxBackoff
+	Multiplier     float64       `json:"multiplier,omitempty"`      // 每次重试等待时间的倍数，默认 2
+	Jitter         float64       `json:"jitter,omitempty"`          // 随机抖动比例，取值 0 到 1，等待时间在 [d*(1-Jitter), d*(1+Jitter)] 内随机
+	ExitCodes      []int         `json:"exit_codes,omitempty"`      // 需要重试的退出码
+	StderrPatterns []string      `json:"stderr_patterns,omitempty"` // 需要重试的标准错误正则
+}
+
+// Match 判断命令是否匹配规则
+func (r RetryRule) Match(cmd types.Command) bool {
+	return Route{Command: r.Command, Args: r.Args}.Match(cmd, nil)
+}
+
+// Backoff 返回第 attempt 次执行失败后、下一次执行前的等待时间
+func (r RetryRule) Backoff(attempt int) time.Duration {
+	initial := r.InitialBackoff
+	if initial <= 0 {
+		initial = DefaultRetryBackoff
+	}
+	maxBackoff := r.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultRetryMaxBackoff
+	}
+	multiplier := r.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	wait := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if wait > float64(maxBackoff) {
+		wait = float64(maxBackoff)
+	}
+	if r.Jitter > 0 {
+		jitter := math.Min(r.Jitter, 1)
+		wait *= 1 - jitter + 2*jitter*rand.Float64()
+	}
+	return time.Duration(wait)
+}
+
+// retryRule 是编译后的重试规则
+type retryRule struct {
+	RetryRule
+	stderr []*regexp.Regexp
+}
+
+// retryable 判断失败的执行是否需要按规则重试
+func (r *retryRule) retryable(result *types.ExecuteResult, stderr string) bool {
+	if result == nil {
+		return true
+	}
+	if len(r.ExitCodes) == 0 && len(r.stderr) == 0 {
+		return result.ExitCode != 0
+	}
+	for _, code := range r.ExitCodes {
+		if result.ExitCode == code {
+			return true
+		}
+	}
+	if stderr == "" {
+		// 执行器没有写入标准错误时使用合并后的输出
+		stderr = result.Output
+	}
+	for _, re := range r.stderr {
+		if re.MatchString(stderr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryRulesMiddleware 按规则重试失败的命令，规则按顺序匹配，管道命令中任一命令匹配即使用该规则，
+// 没有匹配的规则时不重试。每次尝试记录在结果的 Attempts 中，
+// 位于其内层的中间件（如 AuditMiddleware）可以通过 AttemptFromContext 获取当前的尝试次数。
+func RetryRulesMiddleware(rules ...RetryRule) (Middleware, error) {
+	compiled := make([]*retryRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Command != "" {
+			if _, err := path.Match(rule.Command, ""); err != nil {
+				return nil, fmt.Errorf("invalid command pattern %q: %w", rule.Command, err)
+			}
+		}
+		for _, pattern := range rule.Args {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid argument pattern %q: %w", pattern, err)
+			}
+		}
+		if rule.MaxAttempts <= 0 {
+			rule.MaxAttempts = DefaultRetryAttempts
+		}
+		r := &retryRule{RetryRule: rule}
+		for _, pattern := range rule.StderrPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid stderr pattern %q: %w", pattern, err)
+			}
+			r.stderr = append(r.stderr, re)
+		}
+		compiled = append(compiled, r)
+	}
+
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+			rule := matchRetryRule(compiled, ctx)
+			if rule == nil {
+				return next(ctx)
+			}
+			return retry(ctx, next, rule.MaxAttempts, rule.Backoff, rule.retryable)
+		}
+	}, nil
+}
+
+// matchRetryRule 返回第一条匹配命令的规则
+func matchRetryRule(rules []*retryRule, ctx *types.ExecuteContext) *retryRule {
+	cmds := pipelineCommands(ctx)
+	for _, rule := range rules {
+		for _, cmd := range cmds {
+			if rule.Match(cmd) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// attemptKey 是保存当前尝试次数的 context 键
+type attemptKey struct{}
+
+// AttemptFromContext 返回重试中间件设置的当前尝试次数，从 1 开始，未经过重试中间件时返回 0
+func AttemptFromContext(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return attempt
+}
+
+// retry 最多执行 attempts 次命令，失败且 retryable 返回 true 时等待 backoff 后重试。
+// 参数错误、策略拒绝和取消不会重试。每次尝试记录在最终结果的 Attempts 中。
+func retry(ctx *types.ExecuteContext, next ExecuteFunc, attempts int, backoff func(attempt int) time.Duration, retryable func(result *types.ExecuteResult, stderr string) bool) (*types.ExecuteResult, error) {
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	var history []types.ExecuteAttempt
+	for attempt := 1; ; attempt++ {
+		c := *ctx
+		c.Context = context.WithValue(parent, attemptKey{}, attempt)
+		stderr := &tailBuffer{limit: maxRetryStderr}
+		options := &types.ExecuteOptions{}
+		if ctx.Options != nil {
+			*options = *ctx.Options
+		}
+		if options.Stderr != nil {
+			options.Stderr = io.MultiWriter(options.Stderr, stderr)
+		} else {
+			options.Stderr = stderr
+		}
+		c.Options = options
+
+		record := types.ExecuteAttempt{Attempt: attempt, StartTime: time.Now()}
+		result, err := next(&c)
+		record.EndTime = time.Now()
+		if result != nil {
+			record.ExitCode = result.ExitCode
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+
+		if err == nil || attempt >= attempts || isPermanent(err) || !retryable(result, stderr.String()) {
+			history = append(history, record)
+			if result != nil && attempt > 1 {
+				result.Attempts = history
+			}
+			return result, err
+		}
+
+		record.Backoff = backoff(attempt)
+		history = append(history, record)
+		log.Info("Retrying command %s in %v after attempt %d/%d failed: %v", commandName(ctx), record.Backoff, attempt, attempts, err)
+
+		timer := time.NewTimer(record.Backoff)
+		select {
+		case <-parent.Done():
+			timer.Stop()
+			if result != nil {
+				result.Attempts = history
+			}
+			return result, err
+		case <-timer.C:
+		}
+	}
+}
+
+// tailBuffer 保留最后写入的 limit 字节
+type tailBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Write(p)
+	if over := b.buf.Len() - b.limit; over > 0 {
+		b.buf.Next(over)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
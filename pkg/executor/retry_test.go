@@ -0,0 +1,124 @@
+package executor
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAuditor 在内存中记录审计日志
+type memoryAuditor struct {
+	mu         sync.Mutex
+	executions []types.CommandExecution
+}
+
+func (a *memoryAuditor) LogCommandExecution(exec *types.CommandExecution) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.executions = append(a.executions, *exec)
+	return nil
+}
+
+func TestRetryRulesMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "count")
+	// 前两次以退出码 1 失败并输出 "Connection reset"，第三次成功
+	flaky := `n=$(cat ` + counter + ` 2>/dev/null || echo 0); n=$((n+1)); echo $n > ` + counter + `; ` +
+		`if [ $n -lt 3 ]; then echo "Connection reset by peer" >&2; exit 1; fi; echo done`
+
+	auditor := &memoryAuditor{}
+	local := NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil)
+	retryMiddleware, err := RetryRulesMiddleware(
+		RetryRule{Command: "sh", Args: []string{"flaky"}, MaxAttempts: 5, InitialBackoff: time.Millisecond, StderrPatterns: []string{"Connection reset"}},
+		RetryRule{Command: "sh", Args: []string{"exit-code"}, InitialBackoff: time.Millisecond, ExitCodes: []int{75}},
+	)
+	require.NoError(t, err)
+	exec := Chain(NewAuditedExecutor(local, auditor), retryMiddleware)
+
+	run := func(script string, args ...string) (*types.ExecuteResult, error) {
+		return exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "sh", Args: append([]string{"-c", script}, args...)},
+		})
+	}
+
+	t.Run("stderr pattern", func(t *testing.T) {
+		result, err := run(flaky, "flaky")
+		require.NoError(t, err)
+		assert.Equal(t, "done\n", result.Output)
+		require.Len(t, result.Attempts, 3)
+		for i, attempt := range result.Attempts {
+			assert.Equal(t, i+1, attempt.Attempt)
+		}
+		assert.Equal(t, 1, result.Attempts[0].ExitCode)
+		assert.NotEmpty(t, result.Attempts[0].Error)
+		assert.Equal(t, time.Millisecond, result.Attempts[0].Backoff)
+		assert.Equal(t, 2*time.Millisecond, result.Attempts[1].Backoff)
+		assert.Equal(t, 0, result.Attempts[2].ExitCode)
+
+		// 每次尝试都记录审计日志
+		var attempts []int
+		for _, execution := range auditor.executions {
+			if execution.Status != "STARTED" {
+				attempts = append(attempts, execution.Attempt)
+			}
+		}
+		assert.Equal(t, []int{1, 2, 3}, attempts)
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		result, err := run("exit 75", "exit-code")
+		assert.Error(t, err)
+		assert.Len(t, result.Attempts, DefaultRetryAttempts)
+
+		// 退出码不在列表中时不重试
+		result, err = run("exit 1", "exit-code")
+		assert.Error(t, err)
+		assert.Empty(t, result.Attempts)
+	})
+
+	t.Run("no matching rule", func(t *testing.T) {
+		result, err := run("exit 75")
+		assert.Error(t, err)
+		assert.Empty(t, result.Attempts)
+	})
+
+	t.Run("canceled during backoff", func(t *testing.T) {
+		slow, err := RetryRulesMiddleware(RetryRule{InitialBackoff: time.Hour})
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		result, err := Chain(local, slow).Execute(&types.ExecuteContext{
+			Context: ctx,
+			Command: types.Command{Command: "sh", Args: []string{"-c", "exit 1"}},
+		})
+		assert.Error(t, err)
+		assert.Len(t, result.Attempts, 1)
+	})
+
+	_, err = RetryRulesMiddleware(RetryRule{StderrPatterns: []string{"("}})
+	assert.Error(t, err)
+	_, err = RetryRulesMiddleware(RetryRule{Command: "["})
+	assert.Error(t, err)
+}
+
+func TestRetryRuleBackoff(t *testing.T) {
+	rule := RetryRule{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	assert.Equal(t, 100*time.Millisecond, rule.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, rule.Backoff(2))
+	assert.Equal(t, 800*time.Millisecond, rule.Backoff(4))
+	assert.Equal(t, time.Second, rule.Backoff(10))
+
+	rule.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		wait := rule.Backoff(2)
+		assert.GreaterOrEqual(t, wait, 100*time.Millisecond)
+		assert.LessOrEqual(t, wait, 300*time.Millisecond)
+	}
+}
@@ -0,0 +1,494 @@
+// Package executor 实现了命令执行器的核心功能。
+// 本文件实现了按规则将命令分发到不同子执行器的路由执行器。
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+const (
+	RoutingExecutorName = "routing"
+)
+
+// Route 表示一条路由规则，所有条件都满足时命令交给 Executor 指定的子执行器执行。
+// Command 使用 glob 匹配命令名称（忽略路径），为空或 "*" 时匹配所有命令；
+// Args 中的每个 glob 都必须匹配至少一个参数；Metadata 中的每一项都必须与执行选项的元数据相等。
+type Route struct {
+	Executor string            `json:"executor"`
+	Command  string            `json:"command,omitempty"`
+	Args     []string          `json:"args,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Match 判断命令是否匹配规则
+func (r Route) Match(cmd types.Command, metadata map[string]string) bool {
+	if r.Command != "" && r.Command != "*" {
+		if ok, _ := path.Match(r.Command, filepath.Base(cmd.Command)); !ok {
+			return false
+		}
+	}
+	for _, pattern := range r.Args {
+		found := false
+		for _, arg := range cmd.Args {
+			if ok, _ := path.Match(pattern, arg); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for k, v := range r.Metadata {
+		if metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// RoutingExecutor 按规则将命令分发到不同的子执行器，使多个执行环境可以组成一个逻辑会话。
+// 规则按添加顺序匹配，没有匹配的规则时使用默认子执行器。
+// 各子执行器可以将共享的工作目录挂载在不同的路径下，通过 MapWorkDir 配置后，
+// 工作目录以及位于工作目录下的绝对路径参数会被转换为子执行器中对应的路径。
+type RoutingExecutor struct {
+	mu         sync.RWMutex
+	children   map[string]types.Executor
+	order      []string          // 子执行器的添加顺序
+	routes     []Route           // 路由规则
+	fallback   string            // 默认子执行器
+	workDir    string            // 共享的工作目录
+	workDirMap map[string]string // 子执行器名称 -> 共享工作目录在子执行器中的路径
+	explicit   map[string]bool   // 允许显式指定的子执行器
+}
+
+// NewRoutingExecutor 创建路由执行器，fallback 为没有匹配规则时使用的子执行器名称
+func NewRoutingExecutor(fallback string) *RoutingExecutor {
+	return &RoutingExecutor{
+		children:   make(map[string]types.Executor),
+		fallback:   fallback,
+		workDirMap: make(map[string]string),
+		explicit:   make(map[string]bool),
+	}
+}
+
+// Name 返回执行器名称
+func (e *RoutingExecutor) Name() string {
+	return RoutingExecutorName
+}
+
+// AddExecutor 添加子执行器
+func (e *RoutingExecutor) AddExecutor(name string, executor types.Executor) error {
+	if name == "" {
+		return fmt.Errorf("executor name is empty")
+	}
+	if executor == nil {
+		return fmt.Errorf("executor %s is nil", name)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.children[name]; ok {
+		return fmt.Errorf("executor %s already exists", name)
+	}
+	e.children[name] = executor
+	e.order = append(e.order, name)
+	return nil
+}
+
+// AddRoute 追加一条路由规则
+func (e *RoutingExecutor) AddRoute(route Route) error {
+	if route.Executor == "" {
+		return fmt.Errorf("route executor is empty")
+	}
+	if route.Command != "" {
+		if _, err := path.Match(route.Command, ""); err != nil {
+			return fmt.Errorf("invalid command pattern %q: %w", route.Command, err)
+		}
+	}
+	for _, pattern := range route.Args {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid argument pattern %q: %w", pattern, err)
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.routes = append(e.routes, route)
+	return nil
+}
+
+// AllowExplicit 允许命令通过 Executor 字段或名称前缀显式指定 names 中的子执行器。
+// 显式指定会绕过路由规则，默认不允许显式指定任何子执行器，命令只按规则路由
+func (e *RoutingExecutor) AllowExplicit(names ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, name := range names {
+		e.explicit[name] = true
+	}
+}
+
+// MapWorkDir 设置共享工作目录 workDir 在子执行器 name 中的路径
+func (e *RoutingExecutor) MapWorkDir(workDir, name, childWorkDir string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.workDir = path.Clean(workDir)
+	e.workDirMap[name] = path.Clean(childWorkDir)
+}
+
+// Executor 返回指定名称的子执行器
+func (e *RoutingExecutor) Executor(name string) (types.Executor, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	executor, ok := e.children[name]
+	return executor, ok
+}
+
+// Route 返回命令对应的子执行器名称
+func (e *RoutingExecutor) Route(cmd types.Command, metadata map[string]string) (string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.route(cmd, metadata)
+}
+
+func (e *RoutingExecutor) route(cmd types.Command, metadata map[string]string) (string, error) {
+	name := e.fallback
+	for _, r := range e.routes {
+		if r.Match(cmd, metadata) {
+			name = r.Executor
+			break
+		}
+	}
+	if name == "" {
+		return "", fmt.Errorf("no route for command: %s", cmd.Command)
+	}
+	if _, ok := e.children[name]; !ok {
+		return "", fmt.Errorf("unknown executor %s for command: %s", name, cmd.Command)
+	}
+	return name, nil
+}
+
+// Execute 将命令交给匹配的子执行器执行。
+// 命令可以通过 Executor 字段或 "名称:命令" 形式的前缀（如 local:gzip）指定子执行器。
+// 管道中的命令都在同一个子执行器时交给该子执行器执行，否则每个命令在各自的子执行器中执行，
+// 命令之间的数据通过进程内的管道传递。
+func (e *RoutingExecutor) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("context is nil")
+	}
+	var metadata map[string]string
+	if ctx.Options != nil {
+		metadata = ctx.Options.Metadata
+	}
+
+	e.mu.RLock()
+	routed := *ctx
+	var name string
+	if ctx.IsPiped {
+		if ctx.PipeContext == nil || len(ctx.PipeContext.Commands) == 0 {
+			e.mu.RUnlock()
+			return nil, fmt.Errorf("no commands in pipeline")
+		}
+		names := make([]string, len(ctx.PipeContext.Commands))
+		cmds := make([]*types.Command, len(ctx.PipeContext.Commands))
+		for i, cmd := range ctx.PipeContext.Commands {
+			n, stripped, err := e.resolve(*cmd, metadata)
+			if err != nil {
+				e.mu.RUnlock()
+				return nil, err
+			}
+			names[i], cmds[i] = n, &stripped
+		}
+		pipe := *ctx.PipeContext
+		pipe.Commands = cmds
+		routed.PipeContext = &pipe
+		for _, n := range names[1:] {
+			if n != names[0] {
+				e.mu.RUnlock()
+				return e.executeAcross(&routed, names)
+			}
+		}
+		name = names[0]
+	} else {
+		n, stripped, err := e.resolve(ctx.Command, metadata)
+		if err != nil {
+			e.mu.RUnlock()
+			return nil, err
+		}
+		name, routed.Command = n, stripped
+	}
+	child := e.children[name]
+	childCtx := e.childContext(&routed, name)
+	e.mu.RUnlock()
+
+	log.Debug("Routing command %s to executor %s", ctx.Command.Command, name)
+	return child.Execute(childCtx)
+}
+
+// resolve 返回命令的子执行器名称和去掉执行器前缀后的命令。
+// 命令的 Executor 字段或 "名称:命令" 形式的前缀指定了子执行器时使用该子执行器，否则按规则路由。
+// 指定没有通过 AllowExplicit 允许的子执行器时返回错误。
+func (e *RoutingExecutor) resolve(cmd types.Command, metadata map[string]string) (string, types.Command, error) {
+	if cmd.Executor == "" {
+		if name, rest, ok := strings.Cut(cmd.Command, ":"); ok && rest != "" {
+			if _, known := e.children[name]; known {
+				cmd.Executor, cmd.Command = name, rest
+			}
+		}
+	}
+	if cmd.Executor == "" {
+		name, err := e.route(cmd, metadata)
+		return name, cmd, err
+	}
+	name := cmd.Executor
+	if _, ok := e.children[name]; !ok {
+		return "", cmd, fmt.Errorf("unknown executor %s for command: %s", name, cmd.Command)
+	}
+	if !e.explicit[name] {
+		return "", cmd, fmt.Errorf("executor %s cannot be selected explicitly for command: %s", name, cmd.Command)
+	}
+	cmd.Executor = ""
+	return name, cmd, nil
+}
+
+// executeAcross 在各自的子执行器中执行管道中的命令，names[i] 为第 i 个命令的子执行器
+func (e *RoutingExecutor) executeAcross(ctx *types.ExecuteContext, names []string) (*types.ExecuteResult, error) {
+	stages := make([]PipelineStage, len(names))
+	for i, cmd := range ctx.PipeContext.Commands {
+		name := names[i]
+		command := *cmd
+		command.Executor = name
+		stages[i] = PipelineStage{
+			Command: command,
+			Run: func(stageCtx *types.ExecuteContext) (*types.ExecuteResult, error) {
+				stageCtx.Command.Executor = ""
+				e.mu.RLock()
+				child, ok := e.children[name]
+				childCtx := e.childContext(stageCtx, name)
+				e.mu.RUnlock()
+				if !ok {
+					return nil, fmt.Errorf("unknown executor %s for command: %s", name, stageCtx.Command.Command)
+				}
+				return child.Execute(childCtx)
+			},
+		}
+	}
+
+	options := ctx.Options.Merge(ctx.PipeContext.Options)
+	log.Debug("Running pipeline across executors %v", names)
+	return RunPipeline(ctx, stages, options, ctx.PipeContext.Pipefail)
+}
+
+// ExecuteCommand 执行命令
+func (e *RoutingExecutor) ExecuteCommand(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	return e.Execute(ctx)
+}
+
+// childContext 复制执行上下文，并将路径转换为子执行器中的路径
+func (e *RoutingExecutor) childContext(ctx *types.ExecuteContext, name string) *types.ExecuteContext {
+	childCtx := *ctx
+	childWorkDir, ok := e.workDirMap[name]
+	if !ok || e.workDir == "" {
+		return &childCtx
+	}
+
+	options := &types.ExecuteOptions{}
+	if ctx.Options != nil {
+		*options = *ctx.Options
+	}
+	options.WorkDir = e.mapPath(options.WorkDir, childWorkDir)
+	if options.WorkDir == "" {
+		options.WorkDir = childWorkDir
+	}
+	childCtx.Options = options
+
+	childCtx.Command = e.mapCommand(ctx.Command, childWorkDir)
+	if ctx.IsPiped && ctx.PipeContext != nil {
+		pipe := *ctx.PipeContext
+		pipe.Commands = make([]*types.Command, len(ctx.PipeContext.Commands))
+		for i, cmd := range ctx.PipeContext.Commands {
+			mapped := e.mapCommand(*cmd, childWorkDir)
+			pipe.Commands[i] = &mapped
+		}
+		childCtx.PipeContext = &pipe
+	}
+	return &childCtx
+}
+
+// mapCommand 转换命令参数中位于共享工作目录下的绝对路径
+func (e *RoutingExecutor) mapCommand(cmd types.Command, childWorkDir string) types.Command {
+	if len(cmd.Args) == 0 {
+		return cmd
+	}
+	args := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		args[i] = e.mapPath(arg, childWorkDir)
+	}
+	cmd.Args = args
+	return cmd
+}
+
+// mapPath 将共享工作目录下的路径转换为子执行器中的路径，其他路径保持不变
+func (e *RoutingExecutor) mapPath(p, childWorkDir string) string {
+	if p == e.workDir {
+		return childWorkDir
+	}
+	prefix := e.workDir
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if strings.HasPrefix(p, prefix) {
+		return path.Join(childWorkDir, strings.TrimPrefix(p, prefix))
+	}
+	return p
+}
+
+// ListCommands 合并所有子执行器的命令，同名命令只保留先添加的子执行器中的
+func (e *RoutingExecutor) ListCommands() []types.CommandInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	seen := make(map[string]bool)
+	commands := make([]types.CommandInfo, 0)
+	for _, name := range e.order {
+		for _, info := range e.children[name].ListCommands() {
+			if seen[info.Name] {
+				continue
+			}
+			seen[info.Name] = true
+			commands = append(commands, info)
+		}
+	}
+	return commands
+}
+
+// FileSystem 实现 types.FileSystemProvider 接口，使用默认子执行器的文件系统
+func (e *RoutingExecutor) FileSystem(workDir string) (types.FileSystem, error) {
+	e.mu.RLock()
+	child, ok := e.children[e.fallback]
+	if ok && e.workDir != "" {
+		if childWorkDir, mapped := e.workDirMap[e.fallback]; mapped {
+			if workDir == "" {
+				workDir = childWorkDir
+			} else {
+				workDir = e.mapPath(workDir, childWorkDir)
+			}
+		}
+	}
+	e.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("default executor not found: %s", e.fallback)
+	}
+	provider, ok := types.As[types.FileSystemProvider](child)
+	if !ok {
+		return nil, fmt.Errorf("executor %s does not support file system access", e.fallback)
+	}
+	return provider.FileSystem(workDir)
+}
+
+// Close 关闭所有子执行器
+func (e *RoutingExecutor) Close() error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var errs []error
+	for _, name := range e.order {
+		if err := e.children[name].Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close executor %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RoutingExecutorBuilder 是路由执行器的构建器。
+// 每次构建都会通过各子执行器的构建器创建新的子执行器。
+type RoutingExecutorBuilder struct {
+	fallback   string
+	names      []string
+	builders   map[string]types.ExecutorBuilder
+	routes     []Route
+	workDir    string
+	workDirMap map[string]string
+	explicit   []string
+	options    *types.ExecuteOptions
+}
+
+// NewRoutingExecutorBuilder 创建路由执行器构建器
+func NewRoutingExecutorBuilder(fallback string) *RoutingExecutorBuilder {
+	return &RoutingExecutorBuilder{
+		fallback:   fallback,
+		builders:   make(map[string]types.ExecutorBuilder),
+		workDirMap: make(map[string]string),
+	}
+}
+
+// WithExecutor 添加子执行器的构建器
+func (b *RoutingExecutorBuilder) WithExecutor(name string, builder types.ExecutorBuilder) *RoutingExecutorBuilder {
+	if _, ok := b.builders[name]; !ok {
+		b.names = append(b.names, name)
+	}
+	b.builders[name] = builder
+	return b
+}
+
+// WithRoute 追加一条路由规则
+func (b *RoutingExecutorBuilder) WithRoute(route Route) *RoutingExecutorBuilder {
+	b.routes = append(b.routes, route)
+	return b
+}
+
+// WithExplicitExecutors 允许命令显式指定 names 中的子执行器
+func (b *RoutingExecutorBuilder) WithExplicitExecutors(names ...string) *RoutingExecutorBuilder {
+	b.explicit = append(b.explicit, names...)
+	return b
+}
+
+// WithWorkDirMapping 设置共享工作目录在子执行器中的路径
+func (b *RoutingExecutorBuilder) WithWorkDirMapping(workDir, name, childWorkDir string) *RoutingExecutorBuilder {
+	b.workDir = workDir
+	b.workDirMap[name] = childWorkDir
+	return b
+}
+
+// WithOptions 设置执行选项
+func (b *RoutingExecutorBuilder) WithOptions(options *types.ExecuteOptions) *RoutingExecutorBuilder {
+	b.options = options
+	return b
+}
+
+// Build 构建路由执行器，任一子执行器构建失败时关闭已构建的子执行器
+func (b *RoutingExecutorBuilder) Build(options *types.ExecuteOptions) (types.Executor, error) {
+	if options == nil {
+		options = b.options
+	}
+	executor := NewRoutingExecutor(b.fallback)
+	executor.AllowExplicit(b.explicit...)
+	for name, childWorkDir := range b.workDirMap {
+		executor.MapWorkDir(b.workDir, name, childWorkDir)
+	}
+	for _, route := range b.routes {
+		if err := executor.AddRoute(route); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range b.names {
+		childOptions := options
+		if childWorkDir, ok := b.workDirMap[name]; ok && options != nil && options.WorkDir != "" {
+			copied := *options
+			copied.WorkDir = executor.mapPath(options.WorkDir, path.Clean(childWorkDir))
+			childOptions = &copied
+		}
+		child, err := b.builders[name].Build(childOptions)
+		if err != nil {
+			executor.Close()
+			return nil, fmt.Errorf("failed to build executor %s: %w", name, err)
+		}
+		executor.AddExecutor(name, child)
+	}
+	return executor, nil
+}
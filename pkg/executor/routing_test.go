@@ -0,0 +1,297 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingExecutor 记录收到的执行上下文
+type recordingExecutor struct {
+	name     string
+	commands []types.CommandInfo
+	calls    []*types.ExecuteContext
+	closed   bool
+	closeErr error
+}
+
+func (e *recordingExecutor) Name() string { return e.name }
+
+func (e *recordingExecutor) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	e.calls = append(e.calls, ctx)
+	return &types.ExecuteResult{CommandName: ctx.Command.Command, Output: e.name}, nil
+}
+
+func (e *recordingExecutor) ExecuteCommand(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	return e.Execute(ctx)
+}
+
+func (e *recordingExecutor) ListCommands() []types.CommandInfo { return e.commands }
+
+func (e *recordingExecutor) Close() error {
+	e.closed = true
+	return e.closeErr
+}
+
+func TestRoutingExecutor(t *testing.T) {
+	local := &recordingExecutor{name: "local", commands: []types.CommandInfo{{Name: "ls"}, {Name: "cat"}}}
+	golang := &recordingExecutor{name: "golang", commands: []types.CommandInfo{{Name: "ls"}, {Name: "go"}}}
+	python := &recordingExecutor{name: "python"}
+
+	exec := NewRoutingExecutor("local")
+	require.NoError(t, exec.AddExecutor("local", local))
+	require.NoError(t, exec.AddExecutor("golang", golang))
+	require.NoError(t, exec.AddExecutor("python", python))
+	assert.Error(t, exec.AddExecutor("local", local))
+	require.NoError(t, exec.AddRoute(Route{Executor: "golang", Command: "go", Args: []string{"test"}}))
+	require.NoError(t, exec.AddRoute(Route{Executor: "python", Command: "python*"}))
+	require.NoError(t, exec.AddRoute(Route{Executor: "python", Metadata: map[string]string{"lang": "python"}}))
+	assert.Error(t, exec.AddRoute(Route{Executor: "python", Command: "["}))
+	exec.MapWorkDir("/home/dev/project", "golang", "/workspace")
+	exec.AllowExplicit("golang", "python")
+
+	run := func(cmd string, args []string, options *types.ExecuteOptions) string {
+		result, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: cmd, Args: args},
+			Options: options,
+		})
+		require.NoError(t, err)
+		return result.Output
+	}
+
+	assert.Equal(t, "golang", run("go", []string{"test", "./..."}, nil))
+	assert.Equal(t, "local", run("go", []string{"build"}, nil))
+	assert.Equal(t, "python", run("/usr/bin/python3", []string{"main.py"}, nil))
+	assert.Equal(t, "python", run("ls", nil, &types.ExecuteOptions{Metadata: map[string]string{"lang": "python"}}))
+	assert.Equal(t, "local", run("cat", []string{"a.txt"}, nil))
+
+	t.Run("workdir mapping", func(t *testing.T) {
+		options := &types.ExecuteOptions{WorkDir: "/home/dev/project/pkg"}
+		run("go", []string{"test", "/home/dev/project/pkg/a_test.go", "/home/dev/projectx", "-v"}, options)
+		ctx := golang.calls[len(golang.calls)-1]
+		assert.Equal(t, "/workspace/pkg", ctx.Options.WorkDir)
+		assert.Equal(t, []string{"test", "/workspace/pkg/a_test.go", "/home/dev/projectx", "-v"}, ctx.Command.Args)
+		// 调用方的选项不会被修改
+		assert.Equal(t, "/home/dev/project/pkg", options.WorkDir)
+
+		// 未配置映射的子执行器保持原路径
+		run("cat", []string{"/home/dev/project/a.txt"}, options)
+		ctx = local.calls[len(local.calls)-1]
+		assert.Equal(t, "/home/dev/project/pkg", ctx.Options.WorkDir)
+		assert.Equal(t, []string{"/home/dev/project/a.txt"}, ctx.Command.Args)
+
+		run("go", []string{"test"}, nil)
+		assert.Equal(t, "/workspace", golang.calls[len(golang.calls)-1].Options.WorkDir)
+	})
+
+	t.Run("pipeline", func(t *testing.T) {
+		pipe := func(cmds ...*types.Command) (*types.ExecuteResult, error) {
+			return exec.Execute(&types.ExecuteContext{
+				Context:     context.Background(),
+				IsPiped:     true,
+				PipeContext: &types.PipelineContext{Commands: cmds},
+			})
+		}
+		result, err := pipe(&types.Command{Command: "cat", Args: []string{"a.txt"}}, &types.Command{Command: "grep", Args: []string{"x"}})
+		require.NoError(t, err)
+		assert.Equal(t, "local", result.Output)
+
+		// 跨执行器的管道中每个命令在各自的子执行器中执行
+		result, err = pipe(&types.Command{Command: "cat"}, &types.Command{Command: "python3"}, &types.Command{Command: "golang:wc"})
+		require.NoError(t, err)
+		assert.Equal(t, "golang", result.Output)
+		require.Len(t, result.Stages, 3)
+		assert.Equal(t, "local", result.Stages[0].Executor)
+		assert.Equal(t, "python", result.Stages[1].Executor)
+		assert.Equal(t, "wc", result.Stages[2].Command)
+		assert.Equal(t, "golang", result.Stages[2].Executor)
+		assert.Equal(t, "local:cat | python:python3 | golang:wc", result.CommandName)
+		assert.Equal(t, types.Command{Command: "wc"}, golang.calls[len(golang.calls)-1].Command)
+
+		_, err = pipe(&types.Command{Command: "cat"}, &types.Command{Command: "wc", Executor: "missing"})
+		assert.ErrorContains(t, err, "unknown executor missing")
+	})
+
+	t.Run("executor prefix", func(t *testing.T) {
+		assert.Equal(t, "python", run("python:ls", nil, nil))
+		assert.Equal(t, "ls", python.calls[len(python.calls)-1].Command.Command)
+		// 不是子执行器名称的前缀保持原样
+		assert.Equal(t, "local", run("unknown:ls", nil, nil))
+		assert.Equal(t, "unknown:ls", local.calls[len(local.calls)-1].Command.Command)
+	})
+
+	t.Run("list commands", func(t *testing.T) {
+		var names []string
+		for _, info := range exec.ListCommands() {
+			names = append(names, info.Name)
+		}
+		assert.Equal(t, []string{"ls", "cat", "go"}, names)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		golang.closeErr = errors.New("boom")
+		err := exec.Close()
+		assert.ErrorContains(t, err, "golang")
+		assert.True(t, local.closed)
+		assert.True(t, golang.closed)
+		assert.True(t, python.closed)
+	})
+}
+
+func TestRoutingExecutorCrossExecutorPipeline(t *testing.T) {
+	dir := t.TempDir()
+	upper := NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil)
+	require.NoError(t, upper.RegisterCommand(&testCommand{name: "greet", output: "hello\nworld\n"}))
+	exec := NewRoutingExecutor("local")
+	require.NoError(t, exec.AddExecutor("local", NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil)))
+	require.NoError(t, exec.AddExecutor("upper", upper))
+	exec.AllowExplicit("local", "upper")
+
+	// 数据在两个执行器之间流式传递，最后一个命令的输出重定向到文件
+	result, err := NewPipelineExecutor(exec).Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "upper:greet | local:tr a-z A-Z | local:sort -r > out.txt"},
+		Options: &types.ExecuteOptions{WorkDir: dir},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	data, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "WORLD\nHELLO\n", string(data))
+
+	// 取消时所有执行器中的命令一起结束
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = exec.Execute(&types.ExecuteContext{
+		Context: ctx,
+		IsPiped: true,
+		PipeContext: &types.PipelineContext{Commands: []*types.Command{
+			{Command: "sleep", Args: []string{"10"}, Executor: "upper"},
+			{Command: "cat", Executor: "local"},
+		}},
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestRoutingExecutorNoRoute(t *testing.T) {
+	exec := NewRoutingExecutor("")
+	require.NoError(t, exec.AddExecutor("golang", &recordingExecutor{name: "golang"}))
+	require.NoError(t, exec.AddRoute(Route{Executor: "golang", Command: "go"}))
+	require.NoError(t, exec.AddRoute(Route{Executor: "missing", Command: "python"}))
+
+	_, err := exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "ls"}})
+	assert.ErrorContains(t, err, "no route")
+	_, err = exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "python"}})
+	assert.ErrorContains(t, err, "unknown executor")
+}
+
+func TestRoutingExecutorAllowExplicit(t *testing.T) {
+	exec := NewRoutingExecutor("sandbox")
+	require.NoError(t, exec.AddExecutor("sandbox", &recordingExecutor{name: "sandbox"}))
+	require.NoError(t, exec.AddExecutor("host", &recordingExecutor{name: "host"}))
+	require.NoError(t, exec.AddExecutor("golang", &recordingExecutor{name: "golang"}))
+
+	// 默认不能显式指定子执行器，规则路由不受影响
+	_, err := exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "golang:go"}})
+	assert.ErrorContains(t, err, "cannot be selected explicitly")
+	result, err := exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "ls"}})
+	require.NoError(t, err)
+	assert.Equal(t, "sandbox", result.Output)
+
+	exec.AllowExplicit("golang")
+	result, err = exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "golang:go"}})
+	require.NoError(t, err)
+	assert.Equal(t, "golang", result.Output)
+
+	// 不在允许列表中的子执行器不能通过前缀或 Executor 字段指定
+	_, err = exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "host:rm"}})
+	assert.ErrorContains(t, err, "cannot be selected explicitly")
+	_, err = exec.Execute(&types.ExecuteContext{Context: context.Background(), Command: types.Command{Command: "rm", Executor: "host"}})
+	assert.ErrorContains(t, err, "cannot be selected explicitly")
+	_, err = exec.Execute(&types.ExecuteContext{
+		Context:     context.Background(),
+		IsPiped:     true,
+		PipeContext: &types.PipelineContext{Commands: []*types.Command{{Command: "ls"}, {Command: "host:gzip"}}},
+	})
+	assert.ErrorContains(t, err, "cannot be selected explicitly")
+}
+
+func TestRoutingExecutorBuilder(t *testing.T) {
+	dir := t.TempDir()
+	builder := NewRoutingExecutorBuilder("local").
+		WithExecutor("local", NewLocalExecutorBuilder(types.LocalConfig{
+			AllowUnregisteredCommands: true,
+			UseBuiltinCommands:        true,
+			WorkDir:                   dir,
+		})).
+		WithExecutor("other", types.ExecutorBuilderFunc(func(options *types.ExecuteOptions) (types.Executor, error) {
+			return &recordingExecutor{name: "other"}, nil
+		})).
+		WithRoute(Route{Executor: "other", Command: "go"})
+
+	exec, err := builder.Build(nil)
+	require.NoError(t, err)
+	defer exec.Close()
+
+	result, err := exec.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "pwd"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, dir+"\n", result.Output)
+
+	result, err = exec.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "go", Args: []string{"version"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "other", result.Output)
+
+	// 只有 WithExplicitExecutors 列出的子执行器可以显式指定
+	_, err = exec.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "other:ls"},
+	})
+	assert.ErrorContains(t, err, "cannot be selected explicitly")
+	explicit, err := NewRoutingExecutorBuilder("local").
+		WithExecutor("other", types.ExecutorBuilderFunc(func(options *types.ExecuteOptions) (types.Executor, error) {
+			return &recordingExecutor{name: "other"}, nil
+		})).
+		WithExplicitExecutors("other").
+		Build(nil)
+	require.NoError(t, err)
+	defer explicit.Close()
+	result, err = explicit.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "other:ls"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "other", result.Output)
+
+	// 文件系统使用默认子执行器
+	provider, ok := types.As[types.FileSystemProvider](exec)
+	require.True(t, ok)
+	fsys, err := provider.FileSystem("")
+	require.NoError(t, err)
+	require.NoError(t, fsys.WriteFile(context.Background(), "a.txt", []byte("a"), 0644))
+	data, err := fsys.ReadFile(context.Background(), "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+
+	_, err = NewRoutingExecutorBuilder("local").
+		WithExecutor("local", types.ExecutorBuilderFunc(func(options *types.ExecuteOptions) (types.Executor, error) {
+			return nil, errors.New("boom")
+		})).
+		Build(nil)
+	assert.ErrorContains(t, err, "boom")
+}
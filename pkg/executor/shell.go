@@ -0,0 +1,678 @@
+// Package executor 实现了命令执行器的核心功能。
+// 本文件实现了 shell 脚本语法树的解释执行。
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/iamlongalong/runshell/pkg/fs"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/policy"
+	"github.com/iamlongalong/runshell/pkg/shell"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// interpreter 在执行器上解释执行 shell 脚本。
+// 命令列表、条件执行、重定向、变量和路径名展开在解释器中完成，
+// 每个命令（或不含重定向的管道）交给执行器执行。
+type interpreter struct {
+	executor types.Executor
+	files    shellFiles
+	hostEnv  bool // 变量未设置时是否读取宿主机的环境变量，仅本地执行器
+}
+
+// newInterpreter 创建在 executor 上执行脚本的解释器。
+// 重定向、路径名展开和 cd 只能通过执行器以 workDir 为根的文件系统访问文件，
+// 执行器不提供文件系统（或本地文件系统没有根目录）时这些操作都会失败。
+func newInterpreter(executor types.Executor, workDir string) *interpreter {
+	in := &interpreter{executor: executor}
+	if _, ok := types.As[*LocalExecutor](executor); ok {
+		in.hostEnv = true
+	}
+	provider, ok := types.As[types.FileSystemProvider](executor)
+	if !ok {
+		return in
+	}
+	fsys, err := provider.FileSystem(workDir)
+	if err != nil {
+		log.Debug("File system of executor %s is not available: %v", executor.Name(), err)
+		return in
+	}
+	if local, ok := fsys.(*fs.LocalFS); ok && local.Root() == "" {
+		return in
+	}
+	in.files = fsFiles{fs: fsys}
+	return in
+}
+
+// shellState 是脚本执行的状态，子 shell 和管道中的命令使用其副本
+type shellState struct {
+	vars     map[string]string
+	exported map[string]bool
+	dir      string
+	status   int
+	err      error // 最后一个命令的错误
+	exited   bool
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+func (st *shellState) clone() *shellState {
+	c := *st
+	c.vars = make(map[string]string, len(st.vars))
+	for k, v := range st.vars {
+		c.vars[k] = v
+	}
+	c.exported = make(map[string]bool, len(st.exported))
+	for k, v := range st.exported {
+		c.exported[k] = v
+	}
+	return &c
+}
+
+// setStatus 设置最后一个命令的退出码
+func (st *shellState) setStatus(status int, err error) {
+	st.status = status
+	st.err = err
+}
+
+// environ 返回传给命令的环境变量，assigns 为命令前的变量赋值
+func (st *shellState) environ(assigns map[string]string) map[string]string {
+	env := make(map[string]string, len(st.exported)+len(assigns))
+	for k := range st.exported {
+		env[k] = st.vars[k]
+	}
+	for k, v := range assigns {
+		env[k] = v
+	}
+	return env
+}
+
+// run 执行脚本。命令被策略拒绝或 context 被取消时终止脚本并返回该错误，
+// 否则返回最后一个命令的退出码，退出码非零时同时返回错误。
+func (in *interpreter) run(ctx *types.ExecuteContext, script *shell.Script) (*types.ExecuteResult, error) {
+	options := ctx.Options
+	if options == nil {
+		options = &types.ExecuteOptions{}
+	}
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	base := ctx.Copy()
+	base.Context = shell.WithScript(parent, script)
+	base.Options = options
+
+	st := &shellState{
+		vars:     make(map[string]string),
+		exported: make(map[string]bool),
+		dir:      options.WorkDir,
+		stdin:    options.Stdin,
+		stdout:   options.Stdout,
+		stderr:   options.Stderr,
+	}
+	for k, v := range options.Env {
+		st.vars[k] = v
+		st.exported[k] = true
+	}
+
+	// 只有一个不含重定向的管道时直接返回执行器的结果，保留缓存状态、文件变更等信息
+	if p := script.Pipeline(); p != nil && !p.Negated {
+		if cmds := in.plainPipeline(base, st, p); cmds != nil {
+			return in.executor.Execute(in.pipelineContext(base, st, cmds))
+		}
+	}
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	if st.stdout == nil {
+		st.stdout = &stdoutBuf
+	}
+	if st.stderr == nil {
+		st.stderr = &stderrBuf
+	}
+
+	startTime := types.GetTimeNow()
+	if err := in.script(base, st, script); err != nil {
+		return nil, err
+	}
+	result := &types.ExecuteResult{
+		CommandName: script.String(),
+		ExitCode:    st.status,
+		StartTime:   startTime,
+		EndTime:     types.GetTimeNow(),
+	}
+	result.Output = stdoutBuf.String()
+	if stderrBuf.Len() > 0 {
+		if result.Output != "" {
+			result.Output += "\n"
+		}
+		result.Output += stderrBuf.String()
+	}
+
+	if st.status != 0 {
+		err := st.err
+		if err == nil {
+			err = fmt.Errorf("exit status %d", st.status)
+		}
+		result.Error = err
+		return result, err
+	}
+	return result, nil
+}
+
+func (in *interpreter) script(ctx *types.ExecuteContext, st *shellState, script *shell.Script) error {
+	for _, stmt := range script.Stmts {
+		if err := in.andOr(ctx, st, stmt); err != nil {
+			return err
+		}
+		if st.exited {
+			return nil
+		}
+		if err := ctx.Context.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *interpreter) andOr(ctx *types.ExecuteContext, st *shellState, andOr *shell.AndOr) error {
+	for i, p := range andOr.Pipelines {
+		if i > 0 {
+			op := andOr.Ops[i-1]
+			if op == "&&" && st.status != 0 || op == "||" && st.status == 0 {
+				continue
+			}
+		}
+		if err := in.pipeline(ctx, st, p); err != nil {
+			return err
+		}
+		if st.exited {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (in *interpreter) pipeline(ctx *types.ExecuteContext, st *shellState, p *shell.Pipeline) error {
+	var err error
+	switch cmds := in.plainPipeline(ctx, st, p); {
+	case len(p.Commands) == 1:
+		err = in.command(ctx, st, p.Commands[0])
+	case cmds != nil:
+		// 不含重定向的管道交给执行器，由执行器以原生的方式连接
+		err = in.exec(in.pipelineContext(ctx, st, cmds), st)
+	default:
+		err = in.pipe(ctx, st, p)
+	}
+	if err != nil {
+		return err
+	}
+	if p.Negated {
+		if st.status == 0 {
+			st.setStatus(1, nil)
+		} else {
+			st.setStatus(0, nil)
+		}
+	}
+	return nil
+}
+
+// plainPipeline 在管道只包含不带赋值和重定向的外部命令时返回展开后的命令，否则返回 nil
+func (in *interpreter) plainPipeline(ctx *types.ExecuteContext, st *shellState, p *shell.Pipeline) []*types.Command {
+	for _, cmd := range p.Commands {
+		simple, ok := cmd.(*shell.SimpleCommand)
+		if !ok || len(simple.Assigns) > 0 || len(simple.Redirects) > 0 || len(simple.Words) == 0 {
+			return nil
+		}
+	}
+	x := in.expander(ctx, st)
+	cmds := make([]*types.Command, len(p.Commands))
+	for i, cmd := range p.Commands {
+		argv := x.Fields(cmd.(*shell.SimpleCommand).Words)
+		if len(argv) == 0 || isShellBuiltin(argv[0]) {
+			return nil
+		}
+		cmds[i] = &types.Command{Command: argv[0], Args: argv[1:]}
+	}
+	return cmds
+}
+
+// pipelineContext 返回执行命令或管道的上下文
+func (in *interpreter) pipelineContext(ctx *types.ExecuteContext, st *shellState, cmds []*types.Command) *types.ExecuteContext {
+	c := in.commandContext(ctx, st, st.stdin, st.stdout, st.stderr, nil)
+	if len(cmds) == 1 {
+		c.Command = *cmds[0]
+		return c
+	}
+	c.IsPiped = true
+	c.PipeContext = &types.PipelineContext{
+		Context:  c.Context,
+		Commands: cmds,
+		Options:  c.Options,
+	}
+	return c
+}
+
+// commandContext 返回在当前状态下执行命令的上下文
+func (in *interpreter) commandContext(ctx *types.ExecuteContext, st *shellState, stdin io.Reader, stdout, stderr io.Writer, assigns map[string]string) *types.ExecuteContext {
+	options := *ctx.Options
+	options.WorkDir = st.dir
+	options.Env = st.environ(assigns)
+	options.Stdin = stdin
+	options.Stdout = stdout
+	options.Stderr = stderr
+
+	c := ctx.Copy()
+	c.IsPiped = false
+	c.PipeContext = nil
+	c.Options = &options
+	return c
+}
+
+// exec 通过执行器执行命令并记录退出码，返回需要终止脚本的错误
+func (in *interpreter) exec(c *types.ExecuteContext, st *shellState) error {
+	stdout := &countingWriter{w: c.Options.Stdout}
+	stderr := &countingWriter{w: c.Options.Stderr}
+	c.Options.Stdout, c.Options.Stderr = stdout, stderr
+	if c.PipeContext != nil {
+		c.PipeContext.Options = c.Options
+	}
+
+	result, err := in.executor.Execute(c)
+	if result == nil {
+		if err == nil {
+			err = fmt.Errorf("executor returned no result")
+		}
+		// 被策略拒绝或已取消时终止脚本，其他错误（如命令不存在）和 shell 一样以退出码 127 继续执行
+		var deniedErr *policy.DeniedError
+		if errors.As(err, &deniedErr) || c.Context.Err() != nil {
+			return err
+		}
+		fmt.Fprintf(stderr.w, "runshell: %v\n", err)
+		st.setStatus(127, err)
+		return nil
+	}
+	// 执行器没有写入输出流时，将结果中的输出写入标准输出
+	if result.Output != "" && stdout.n == 0 && stderr.n == 0 {
+		io.WriteString(stdout.w, result.Output)
+	}
+	status := result.ExitCode
+	if err != nil && status == 0 {
+		status = 1
+	}
+	st.setStatus(status, err)
+	return nil
+}
+
+// pipe 在进程内运行包含重定向、子 shell 或内置命令的管道，每个命令在状态的副本中执行
+func (in *interpreter) pipe(ctx *types.ExecuteContext, st *shellState, p *shell.Pipeline) error {
+	var mu sync.Mutex
+	var fatal error
+	stages := make([]PipelineStage, len(p.Commands))
+	for i, cmd := range p.Commands {
+		cmd := cmd
+		stages[i] = PipelineStage{
+			Command: types.Command{Command: cmd.String()},
+			Run: func(stageCtx *types.ExecuteContext) (*types.ExecuteResult, error) {
+				sub := st.clone()
+				sub.stdin, sub.stdout, sub.stderr = stageCtx.Options.Stdin, stageCtx.Options.Stdout, stageCtx.Options.Stderr
+				c := ctx.Copy()
+				c.Context = stageCtx.Context
+				if err := in.command(c, sub, cmd); err != nil {
+					mu.Lock()
+					if fatal == nil {
+						fatal = err
+					}
+					mu.Unlock()
+					return nil, err
+				}
+				return &types.ExecuteResult{ExitCode: sub.status}, sub.err
+			},
+		}
+	}
+
+	result, err := RunPipeline(ctx, stages, &types.ExecuteOptions{Stdin: st.stdin, Stdout: st.stdout, Stderr: st.stderr}, false)
+	if fatal != nil {
+		return fatal
+	}
+	if result == nil || ctx.Context.Err() != nil {
+		return err
+	}
+	st.setStatus(result.ExitCode, err)
+	return nil
+}
+
+func (in *interpreter) command(ctx *types.ExecuteContext, st *shellState, cmd shell.Command) error {
+	x := in.expander(ctx, st)
+	switch c := cmd.(type) {
+	case *shell.SimpleCommand:
+		argv := x.Fields(c.Words)
+		stdin, stdout, stderr, closers, err := in.redirect(ctx, st, c.Redirects, x)
+		defer in.close(st, closers)
+		if err != nil {
+			fmt.Fprintf(st.stderr, "runshell: %v\n", err)
+			st.setStatus(1, err)
+			return nil
+		}
+
+		assigns := make(map[string]string, len(c.Assigns))
+		for _, a := range c.Assigns {
+			assigns[a.Name] = x.Literal(a.Value)
+		}
+		if len(argv) == 0 {
+			for k, v := range assigns {
+				st.vars[k] = v
+			}
+			st.setStatus(0, nil)
+			return nil
+		}
+		if isShellBuiltin(argv[0]) {
+			in.builtin(ctx, st, argv, stdout, stderr)
+			return nil
+		}
+
+		execCtx := in.commandContext(ctx, st, stdin, stdout, stderr, assigns)
+		execCtx.Command = types.Command{Command: argv[0], Args: argv[1:]}
+		return in.exec(execCtx, st)
+
+	case *shell.Subshell:
+		stdin, stdout, stderr, closers, err := in.redirect(ctx, st, c.Redirects, x)
+		defer in.close(st, closers)
+		if err != nil {
+			fmt.Fprintf(st.stderr, "runshell: %v\n", err)
+			st.setStatus(1, err)
+			return nil
+		}
+		sub := st.clone()
+		sub.stdin, sub.stdout, sub.stderr = stdin, stdout, stderr
+		err = in.script(ctx, sub, c.Body)
+		st.setStatus(sub.status, sub.err)
+		return err
+	}
+	return fmt.Errorf("unknown command type %T", cmd)
+}
+
+// redirect 按顺序执行重定向，返回命令的输入输出和需要在命令结束后关闭的文件
+func (in *interpreter) redirect(ctx *types.ExecuteContext, st *shellState, redirects []*shell.Redirect, x *shell.Expander) (io.Reader, io.Writer, io.Writer, []io.Closer, error) {
+	stdin := st.stdin
+	out := [3]io.Writer{nil, st.stdout, st.stderr}
+	var closers []io.Closer
+	for _, r := range redirects {
+		target := x.Literal(r.Target)
+		switch r.Op {
+		case shell.RedirectDupOut, shell.RedirectDupIn:
+			fd, _ := strconv.Atoi(target)
+			if r.Op == shell.RedirectDupIn || r.Fd == 0 || fd == 0 {
+				if r.Fd != fd {
+					return nil, nil, nil, closers, fmt.Errorf("%s: unsupported file descriptor duplication", r)
+				}
+				continue
+			}
+			out[r.Fd] = out[fd]
+			continue
+		}
+
+		if target == "" {
+			return nil, nil, nil, closers, fmt.Errorf("%s: ambiguous redirect", r.Target)
+		}
+		switch r.Op {
+		case shell.RedirectIn:
+			if r.Fd != 0 {
+				return nil, nil, nil, closers, fmt.Errorf("%s: unsupported input redirection", r)
+			}
+			f, err := in.openFile(ctx.Context, st, target)
+			if err != nil {
+				return nil, nil, nil, closers, fmt.Errorf("%s: %w", target, err)
+			}
+			closers = append(closers, f)
+			stdin = f
+		default:
+			if r.Fd == 0 {
+				return nil, nil, nil, closers, fmt.Errorf("%s: unsupported output redirection", r)
+			}
+			f, err := in.createFile(ctx.Context, st, target, r.Op == shell.RedirectAppend)
+			if err != nil {
+				return nil, nil, nil, closers, fmt.Errorf("%s: %w", target, err)
+			}
+			closers = append(closers, f)
+			if r.Op == shell.RedirectAll {
+				out[1], out[2] = f, f
+			} else {
+				out[r.Fd] = f
+			}
+		}
+	}
+	return stdin, out[1], out[2], closers, nil
+}
+
+// openFile 打开输入重定向的文件，/dev/null 不经过文件系统
+func (in *interpreter) openFile(ctx context.Context, st *shellState, target string) (io.ReadCloser, error) {
+	if target == os.DevNull {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	if in.files == nil {
+		return nil, fmt.Errorf("redirection is not supported by executor %s", in.executor.Name())
+	}
+	return in.files.open(ctx, in.path(st, target))
+}
+
+// createFile 打开输出重定向的文件，/dev/null 不经过文件系统
+func (in *interpreter) createFile(ctx context.Context, st *shellState, target string, append bool) (io.WriteCloser, error) {
+	if target == os.DevNull {
+		return nopWriteCloser{io.Discard}, nil
+	}
+	if in.files == nil {
+		return nil, fmt.Errorf("redirection is not supported by executor %s", in.executor.Name())
+	}
+	return in.files.create(ctx, in.path(st, target), append)
+}
+
+// nopWriteCloser 为 Writer 添加空的 Close 方法
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// close 关闭重定向打开的文件，写入失败时命令视为失败
+func (in *interpreter) close(st *shellState, closers []io.Closer) {
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			fmt.Fprintf(st.stderr, "runshell: %v\n", err)
+			if st.status == 0 {
+				st.setStatus(1, err)
+			}
+		}
+	}
+}
+
+// expander 返回当前状态下的单词展开器
+func (in *interpreter) expander(ctx *types.ExecuteContext, st *shellState) *shell.Expander {
+	x := &shell.Expander{
+		Lookup: func(name string) string {
+			if name == "?" {
+				return strconv.Itoa(st.status)
+			}
+			if v, ok := st.vars[name]; ok {
+				return v
+			}
+			if in.hostEnv {
+				return os.Getenv(name)
+			}
+			return ""
+		},
+	}
+	if in.files != nil {
+		x.ReadDir = func(dir string) ([]string, error) {
+			return in.files.readDir(ctx.Context, in.path(st, dir))
+		}
+	}
+	return x
+}
+
+// path 将相对路径解析到当前工作目录
+func (in *interpreter) path(st *shellState, name string) string {
+	if st.dir == "" || path.IsAbs(name) {
+		return name
+	}
+	return path.Join(st.dir, name)
+}
+
+// isShellBuiltin 判断是否为改变脚本状态、由解释器执行的内置命令
+func isShellBuiltin(name string) bool {
+	switch name {
+	case "cd", "export", "unset", "exit":
+		return true
+	}
+	return false
+}
+
+// builtin 执行解释器的内置命令
+func (in *interpreter) builtin(ctx *types.ExecuteContext, st *shellState, argv []string, stdout, stderr io.Writer) {
+	fail := func(status int, format string, args ...interface{}) {
+		err := fmt.Errorf(format, args...)
+		fmt.Fprintf(stderr, "%s: %v\n", argv[0], err)
+		st.setStatus(status, err)
+	}
+
+	args := argv[1:]
+	switch argv[0] {
+	case "cd":
+		dir := ""
+		if len(args) > 0 {
+			dir = args[0]
+		} else if dir = in.expander(ctx, st).Lookup("HOME"); dir == "" {
+			fail(1, "HOME not set")
+			return
+		}
+		if in.files == nil {
+			fail(1, "not supported by executor %s", in.executor.Name())
+			return
+		}
+		target := in.path(st, dir)
+		if err := in.files.checkDir(ctx.Context, target); err != nil {
+			fail(1, "%s: %v", dir, err)
+			return
+		}
+		st.dir = target
+	case "export":
+		for _, arg := range args {
+			name, value, ok := strings.Cut(arg, "=")
+			if !validVarName(name) {
+				fail(1, "%s: not a valid identifier", arg)
+				return
+			}
+			if ok {
+				st.vars[name] = value
+			}
+			st.exported[name] = true
+		}
+	case "unset":
+		for _, name := range args {
+			delete(st.vars, name)
+			delete(st.exported, name)
+		}
+	case "exit":
+		st.exited = true
+		if len(args) == 0 {
+			return
+		}
+		status, err := strconv.Atoi(args[0])
+		if err != nil {
+			fail(2, "%s: numeric argument required", args[0])
+			return
+		}
+		st.setStatus(status&0xff, nil)
+		if st.status != 0 {
+			st.err = fmt.Errorf("exit status %d", st.status)
+		}
+		return
+	}
+	st.setStatus(0, nil)
+}
+
+// validVarName 判断是否为合法的变量名
+func validVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// shellFiles 提供脚本中重定向、路径名展开和 cd 所需的文件访问
+type shellFiles interface {
+	open(ctx context.Context, name string) (io.ReadCloser, error)
+	create(ctx context.Context, name string, append bool) (io.WriteCloser, error)
+	readDir(ctx context.Context, name string) ([]string, error)
+	checkDir(ctx context.Context, name string) error
+}
+
+// fsFiles 通过执行器的文件系统访问文件，本地执行器的文件系统限制在工作目录内。
+// 读写都是流式的，不在内存中缓存文件内容。
+type fsFiles struct {
+	fs types.FileSystem
+}
+
+func (f fsFiles) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return f.fs.Open(ctx, name)
+}
+
+func (f fsFiles) create(ctx context.Context, name string, append bool) (io.WriteCloser, error) {
+	flag := os.O_CREATE | os.O_TRUNC
+	if append {
+		flag = os.O_CREATE | os.O_APPEND
+	}
+	w, err := f.fs.OpenFile(ctx, name, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fsWriter{lockedWriter: lockedWriter{w: w}, c: w}, nil
+}
+
+func (f fsFiles) readDir(ctx context.Context, name string) ([]string, error) {
+	entries, err := f.fs.ReadDir(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	return names, nil
+}
+
+func (f fsFiles) checkDir(ctx context.Context, name string) error {
+	info, err := f.fs.Stat(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir {
+		return fmt.Errorf("not a directory")
+	}
+	return nil
+}
+
+// fsWriter 允许标准输出和标准错误（&>）并发写入同一个文件
+type fsWriter struct {
+	lockedWriter
+	c io.Closer
+}
+
+func (w *fsWriter) Close() error {
+	return w.c.Close()
+}
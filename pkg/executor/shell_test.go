@@ -0,0 +1,212 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/policy"
+	"github.com/iamlongalong/runshell/pkg/shell"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineExecutorScripts(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("apple\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("banana\n"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+
+	local := NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil)
+	require.NoError(t, local.RegisterCommand(&testCommand{name: "greet", output: "hello\n"}))
+	exec := NewPipelineExecutor(local)
+
+	run := func(script string, env map[string]string) (*types.ExecuteResult, error) {
+		return exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: script},
+			Options: &types.ExecuteOptions{WorkDir: dir, Env: env},
+		})
+	}
+
+	tests := []struct {
+		name     string
+		script   string
+		env      map[string]string
+		output   string
+		exitCode int
+	}{
+		{name: "command list", script: "echo a; echo b", output: "a\nb\n"},
+		{name: "and or", script: "false && echo no || echo yes", output: "yes\n"},
+		{name: "exit status", script: "false; echo $?", output: "1\n"},
+		{name: "negation", script: "! false && echo negated", output: "negated\n"},
+		{name: "variables", script: `NAME=world; echo "hello $NAME"`, output: "hello world\n"},
+		{name: "prefix assignment", script: "FOO=bar sh -c 'echo $FOO'; echo -${FOO}-", output: "bar\n--\n"},
+		{name: "export", script: "export FOO=bar; sh -c 'echo $FOO'", output: "bar\n"},
+		{name: "options env", script: "echo $GREETING", env: map[string]string{"GREETING": "hi"}, output: "hi\n"},
+		{name: "glob", script: "echo *.txt", output: "a.txt b.txt\n"},
+		{name: "cd", script: "cd sub && pwd", output: filepath.Join(dir, "sub") + "\n"},
+		{name: "subshell isolation", script: "(cd sub; X=1) && pwd && echo -$X-", output: dir + "\n--\n"},
+		{name: "redirect in", script: "cat < a.txt", output: "apple\n"},
+		{name: "dup stderr", script: "sh -c 'echo err >&2' 2>&1 | tr a-z A-Z", output: "ERR\n"},
+		{name: "builtin output in pipeline", script: "greet | tr a-z A-Z", output: "HELLO\n"},
+		{name: "exit", script: "echo a; exit 3; echo b", output: "a\n", exitCode: 3},
+		{name: "failing last command", script: "echo a && false", output: "a\n", exitCode: 1},
+		{name: "command not found", script: "no-such-command-xyz 2>/dev/null; echo $?", output: "127\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := run(tt.script, tt.env)
+			require.NotNil(t, result, "%v", err)
+			assert.Equal(t, tt.exitCode, result.ExitCode)
+			if tt.exitCode == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+			assert.Equal(t, tt.output, result.Output)
+		})
+	}
+
+	t.Run("redirect out", func(t *testing.T) {
+		_, err := run("echo one > out.txt; echo two >> out.txt; cat *.txt | sort > sorted.txt", nil)
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "one\ntwo\n", string(data))
+		data, err = os.ReadFile(filepath.Join(dir, "sorted.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "apple\nbanana\none\ntwo\n", string(data))
+	})
+
+	t.Run("redirect streams to file", func(t *testing.T) {
+		// 重定向的输出在命令结束前就写入文件
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			exec.Execute(&types.ExecuteContext{
+				Context: ctx,
+				Command: types.Command{Command: "sh -c 'echo early; exec sleep 30' >> stream.txt"},
+				Options: &types.ExecuteOptions{WorkDir: dir},
+			})
+		}()
+		assert.Eventually(t, func() bool {
+			data, _ := os.ReadFile(filepath.Join(dir, "stream.txt"))
+			return string(data) == "early\n"
+		}, 10*time.Second, 20*time.Millisecond)
+		cancel()
+		<-done
+	})
+
+	t.Run("redirect failure", func(t *testing.T) {
+		result, err := run("cat < missing.txt || echo recovered", nil)
+		require.NoError(t, err)
+		assert.Contains(t, result.Output, "recovered\n")
+		assert.Contains(t, result.Output, "missing.txt")
+	})
+
+	t.Run("unsupported syntax", func(t *testing.T) {
+		_, err := run("echo $(id)", nil)
+		var unsupportedErr *shell.UnsupportedError
+		assert.ErrorAs(t, err, &unsupportedErr)
+	})
+}
+
+func TestPipelineExecutorScriptConfinedToWorkDir(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "work")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	secret := filepath.Join(parent, "secret.txt")
+	require.NoError(t, os.WriteFile(secret, []byte("top-secret-content\n"), 0644))
+
+	exec := NewPipelineExecutor(NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil))
+	run := func(script, workDir string) (*types.ExecuteResult, error) {
+		return exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: script},
+			Options: &types.ExecuteOptions{WorkDir: workDir},
+		})
+	}
+
+	for _, script := range []string{
+		"echo x > ../escaped.txt",
+		"echo x >> " + filepath.Join(parent, "escaped.txt"),
+		"cat < ../secret.txt",
+		"cat < " + secret,
+		"cd .. && echo x > escaped.txt",
+		"cd " + parent,
+		"cd /",
+	} {
+		t.Run(script, func(t *testing.T) {
+			result, err := run(script, dir)
+			assert.Error(t, err)
+			require.NotNil(t, result)
+			assert.NotEqual(t, 0, result.ExitCode)
+			assert.NotContains(t, result.Output, "top-secret-content")
+			assert.NoFileExists(t, filepath.Join(parent, "escaped.txt"))
+		})
+	}
+
+	// /dev/null 不经过文件系统
+	result, err := run("echo x > /dev/null; cat < /dev/null; echo ok", dir)
+	require.NoError(t, err)
+	assert.Equal(t, "ok\n", result.Output)
+
+	// 本地执行器没有工作目录时不访问文件
+	result, err = run("echo x > "+filepath.Join(parent, "escaped.txt"), "")
+	assert.Error(t, err)
+	assert.Contains(t, result.Output, "redirection is not supported")
+	assert.NoFileExists(t, filepath.Join(parent, "escaped.txt"))
+}
+
+func TestPipelineExecutorScriptWithoutFileSystem(t *testing.T) {
+	base := &recordingExecutor{name: "base"}
+	exec := NewPipelineExecutor(base)
+
+	result, err := exec.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "echo a > out.txt; ls *.go"},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, result.Output, "redirection is not supported")
+	// 无法访问文件系统时不进行路径名展开
+	require.Len(t, base.calls, 1)
+	assert.Equal(t, types.Command{Command: "ls", Args: []string{"*.go"}}, base.calls[0].Command)
+}
+
+func TestScriptPolicyAndAudit(t *testing.T) {
+	auditor := &memoryAuditor{}
+	base := &recordingExecutor{name: "base"}
+	exec := NewPipelineExecutor(Chain(base,
+		AuditMiddleware(auditor),
+		PolicyMiddleware(&policy.RulePolicy{Deny: []policy.Rule{{Command: "rm"}}}),
+	))
+
+	// 脚本中任何命令被拒绝时，第一个命令也不会执行
+	_, err := exec.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "echo start && (cd /tmp; rm -rf x)"},
+	})
+	var deniedErr *policy.DeniedError
+	assert.ErrorAs(t, err, &deniedErr)
+	assert.Empty(t, base.calls)
+
+	_, err = exec.Execute(&types.ExecuteContext{
+		Context: context.Background(),
+		Command: types.Command{Command: "echo a; echo b"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, base.calls, 2)
+
+	auditor.mu.Lock()
+	defer auditor.mu.Unlock()
+	require.NotEmpty(t, auditor.executions)
+	last := auditor.executions[len(auditor.executions)-1]
+	assert.Equal(t, "echo b", last.Command.Command+" "+last.Command.Args[0])
+	assert.Equal(t, "echo a; echo b", last.Script)
+}
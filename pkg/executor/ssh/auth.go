@@ -0,0 +1,127 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// DefaultPort SSH 默认端口
+	DefaultPort = "22"
+
+	// DefaultConnectTimeout 默认的连接超时时间
+	DefaultConnectTimeout = 10 * time.Second
+)
+
+// address 返回带端口的主机地址
+func address(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), DefaultPort)
+}
+
+// clientConfig 根据配置创建 SSH 客户端配置，返回的 closer 用于关闭 ssh-agent 连接
+func clientConfig(config types.SSHConfig) (*gossh.ClientConfig, func(), error) {
+	if config.Host == "" {
+		return nil, nil, fmt.Errorf("ssh host not specified")
+	}
+	if config.User == "" {
+		return nil, nil, fmt.Errorf("ssh user not specified")
+	}
+
+	closer := func() {}
+	var auths []gossh.AuthMethod
+	if config.KeyFile != "" {
+		signer, err := loadKey(config.KeyFile, config.KeyPassphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+		auths = append(auths, gossh.PublicKeys(signer))
+	}
+	if config.UseAgent {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, nil, fmt.Errorf("ssh agent requested but SSH_AUTH_SOCK is not set")
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to ssh agent: %w", err)
+		}
+		closer = func() { conn.Close() }
+		auths = append(auths, gossh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	if config.Password != "" {
+		auths = append(auths, gossh.Password(config.Password))
+	}
+	if len(auths) == 0 {
+		closer()
+		return nil, nil, fmt.Errorf("no ssh auth method configured")
+	}
+
+	hostKeyCallback, err := hostKeyCallback(config)
+	if err != nil {
+		closer()
+		return nil, nil, err
+	}
+
+	timeout := config.ConnectTimeout
+	if timeout <= 0 {
+		timeout = DefaultConnectTimeout
+	}
+	return &gossh.ClientConfig{
+		User:            config.User,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	}, closer, nil
+}
+
+// loadKey 读取私钥文件
+func loadKey(file, passphrase string) (gossh.Signer, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh key: %w", err)
+	}
+	var signer gossh.Signer
+	if passphrase != "" {
+		signer, err = gossh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	} else {
+		signer, err = gossh.ParsePrivateKey(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ssh key %s: %w", file, err)
+	}
+	return signer, nil
+}
+
+// hostKeyCallback 返回主机密钥校验函数
+func hostKeyCallback(config types.SSHConfig) (gossh.HostKeyCallback, error) {
+	if config.InsecureIgnoreHostKey {
+		log.Debug("Host key verification disabled for %s", config.Host)
+		return gossh.InsecureIgnoreHostKey(), nil
+	}
+	file := config.KnownHostsFile
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate known_hosts: %w", err)
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %w", err)
+	}
+	return callback, nil
+}
@@ -0,0 +1,282 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/pkg/sftp"
+)
+
+// sftpFS 通过 SFTP 访问远程主机的文件系统，实现 types.FileSystem 接口。
+// 相对路径相对于工作目录解析，未配置工作目录时相对于登录用户的主目录。
+type sftpFS struct {
+	executor *SSHExecutor
+	workDir  string
+}
+
+// FileSystem 实现 types.FileSystemProvider 接口
+func (e *SSHExecutor) FileSystem(workDir string) (types.FileSystem, error) {
+	if workDir == "" {
+		workDir = e.config.WorkDir
+	}
+	return &sftpFS{executor: e, workDir: workDir}, nil
+}
+
+// resolve 将路径解析为远程主机上的路径
+func (f *sftpFS) resolve(name string) string {
+	if path.IsAbs(name) || f.workDir == "" {
+		return path.Clean(name)
+	}
+	return path.Join(f.workDir, name)
+}
+
+// do 获取连接上的 SFTP 客户端执行操作
+func (f *sftpFS) do(fn func(client *sftp.Client) error) error {
+	pool := f.executor.pool
+	c, err := pool.Get(f.executor.config)
+	if err != nil {
+		return err
+	}
+	defer pool.Put(c)
+	client, err := c.SFTP()
+	if err != nil {
+		return err
+	}
+	return fn(client)
+}
+
+// Stat 实现 types.FileSystem 接口
+func (f *sftpFS) Stat(ctx context.Context, name string) (*types.FileInfo, error) {
+	var fi types.FileInfo
+	err := f.do(func(client *sftp.Client) error {
+		p := f.resolve(name)
+		info, err := client.Lstat(p)
+		if err != nil {
+			return &os.PathError{Op: "stat", Path: name, Err: err}
+		}
+		fi = sftpFileInfo(client, name, p, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &fi, nil
+}
+
+// ReadDir 实现 types.FileSystem 接口
+func (f *sftpFS) ReadDir(ctx context.Context, name string) ([]types.FileInfo, error) {
+	var infos []types.FileInfo
+	err := f.do(func(client *sftp.Client) error {
+		p := f.resolve(name)
+		entries, err := client.ReadDir(p)
+		if err != nil {
+			return &os.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		infos = make([]types.FileInfo, 0, len(entries))
+		for _, entry := range entries {
+			infos = append(infos, sftpFileInfo(client, path.Join(name, entry.Name()), path.Join(p, entry.Name()), entry))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+// ReadFile 实现 types.FileSystem 接口
+func (f *sftpFS) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	var data []byte
+	err := f.do(func(client *sftp.Client) error {
+		file, err := client.Open(f.resolve(name))
+		if err != nil {
+			return &os.PathError{Op: "open", Path: name, Err: err}
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+		if err != nil {
+			return &os.PathError{Op: "read", Path: name, Err: err}
+		}
+		return nil
+	})
+	return data, err
+}
+
+// Open 实现 types.FileSystem 接口，文件关闭前占用一个连接
+func (f *sftpFS) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	pool := f.executor.pool
+	c, err := pool.Get(f.executor.config)
+	if err != nil {
+		return nil, err
+	}
+	client, err := c.SFTP()
+	if err != nil {
+		pool.Put(c)
+		return nil, err
+	}
+	file, err := client.Open(f.resolve(name))
+	if err != nil {
+		pool.Put(c)
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &sftpFile{File: file, release: func() { pool.Put(c) }}, nil
+}
+
+// sftpFile 在关闭时归还连接，用于流式读取和写入
+type sftpFile struct {
+	*sftp.File
+	release func()
+}
+
+func (f *sftpFile) Close() error {
+	err := f.File.Close()
+	f.release()
+	return err
+}
+
+// WriteFile 实现 types.FileSystem 接口
+func (f *sftpFS) WriteFile(ctx context.Context, name string, data []byte, perm os.FileMode) error {
+	return f.do(func(client *sftp.Client) error {
+		p := f.resolve(name)
+
+		// 与 os.WriteFile 一致，已存在的文件保留原有权限
+		_, statErr := client.Stat(p)
+		file, err := client.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return &os.PathError{Op: "open", Path: name, Err: err}
+		}
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return &os.PathError{Op: "write", Path: name, Err: err}
+		}
+		if err := file.Close(); err != nil {
+			return &os.PathError{Op: "write", Path: name, Err: err}
+		}
+		if os.IsNotExist(statErr) {
+			if err := client.Chmod(p, perm.Perm()); err != nil {
+				return &os.PathError{Op: "chmod", Path: name, Err: err}
+			}
+		}
+		return nil
+	})
+}
+
+// OpenFile 实现 types.FileSystem 接口，文件关闭前占用一个连接
+func (f *sftpFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+	pool := f.executor.pool
+	c, err := pool.Get(f.executor.config)
+	if err != nil {
+		return nil, err
+	}
+	client, err := c.SFTP()
+	if err != nil {
+		pool.Put(c)
+		return nil, err
+	}
+
+	p := f.resolve(name)
+	_, statErr := client.Stat(p)
+	file, err := client.OpenFile(p, flag|os.O_WRONLY)
+	if err != nil {
+		pool.Put(c)
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	// 新建的文件设置为 perm 权限，已存在的文件保留原有权限
+	if os.IsNotExist(statErr) {
+		if err := client.Chmod(p, perm.Perm()); err != nil {
+			file.Close()
+			pool.Put(c)
+			return nil, &os.PathError{Op: "chmod", Path: name, Err: err}
+		}
+	}
+	return &sftpFile{File: file, release: func() { pool.Put(c) }}, nil
+}
+
+// Mkdir 实现 types.FileSystem 接口
+func (f *sftpFS) Mkdir(ctx context.Context, name string, perm os.FileMode, parents bool) error {
+	return f.do(func(client *sftp.Client) error {
+		p := f.resolve(name)
+		if parents {
+			if info, err := client.Stat(p); err == nil && info.IsDir() {
+				return nil
+			}
+			if err := client.MkdirAll(p); err != nil {
+				return &os.PathError{Op: "mkdir", Path: name, Err: err}
+			}
+		} else if err := client.Mkdir(p); err != nil {
+			if _, statErr := client.Lstat(p); statErr == nil {
+				err = os.ErrExist
+			}
+			return &os.PathError{Op: "mkdir", Path: name, Err: err}
+		}
+		if err := client.Chmod(p, perm.Perm()); err != nil {
+			return &os.PathError{Op: "chmod", Path: name, Err: err}
+		}
+		return nil
+	})
+}
+
+// Remove 实现 types.FileSystem 接口
+func (f *sftpFS) Remove(ctx context.Context, name string, recursive bool) error {
+	return f.do(func(client *sftp.Client) error {
+		p := f.resolve(name)
+		if p == "/" || (f.workDir != "" && p == path.Clean(f.workDir)) {
+			return fmt.Errorf("%s: refusing to remove work directory", name)
+		}
+		info, err := client.Lstat(p)
+		if err != nil {
+			return &os.PathError{Op: "remove", Path: name, Err: err}
+		}
+		if info.IsDir() && recursive {
+			err = client.RemoveAll(p)
+		} else {
+			err = client.Remove(p)
+		}
+		if err != nil {
+			return &os.PathError{Op: "remove", Path: name, Err: err}
+		}
+		return nil
+	})
+}
+
+// Rename 实现 types.FileSystem 接口。
+// 服务端支持 posix-rename 扩展时直接覆盖目标，否则使用标准的 rename 操作。
+func (f *sftpFS) Rename(ctx context.Context, oldName, newName string) error {
+	return f.do(func(client *sftp.Client) error {
+		oldPath, newPath := f.resolve(oldName), f.resolve(newName)
+		var err error
+		if _, ok := client.HasExtension("posix-rename@openssh.com"); ok {
+			err = client.PosixRename(oldPath, newPath)
+		} else {
+			err = client.Rename(oldPath, newPath)
+		}
+		if err != nil {
+			return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: err}
+		}
+		return nil
+	})
+}
+
+// sftpFileInfo 将 SFTP 返回的文件信息转换为 types.FileInfo
+func sftpFileInfo(client *sftp.Client, name, p string, info os.FileInfo) types.FileInfo {
+	fi := types.FileInfo{
+		Name:    info.Name(),
+		Path:    name,
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		fi.Link, _ = client.ReadLink(p)
+	}
+	return fi
+}
@@ -0,0 +1,218 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/pkg/sftp"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// DefaultIdleTimeout 连接空闲多久后关闭
+const DefaultIdleTimeout = time.Minute
+
+// Pool 按主机和认证信息复用 SSH 连接。
+// 同一主机上的命令通过同一个连接的多个会话（channel）执行，
+// 连接在没有使用者并空闲一段时间后关闭，断开的连接会在下次使用时重新建立。
+type Pool struct {
+	idleTimeout time.Duration
+
+	mu    sync.Mutex
+	conns map[string]*conn
+}
+
+// NewPool 创建连接池，idleTimeout 小于等于 0 时使用 DefaultIdleTimeout
+func NewPool(idleTimeout time.Duration) *Pool {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	return &Pool{
+		idleTimeout: idleTimeout,
+		conns:       make(map[string]*conn),
+	}
+}
+
+// defaultPool 未指定连接池的执行器共用的连接池
+var defaultPool = NewPool(DefaultIdleTimeout)
+
+// conn 是连接池中的一个连接
+type conn struct {
+	key    string
+	client *gossh.Client
+	closer func()
+	refs   int
+	idle   *time.Timer
+	closed bool
+
+	ready chan struct{} // 连接建立完成后关闭
+	err   error         // 建立连接的错误
+
+	sftpOnce sync.Once
+	sftp     *sftp.Client
+	sftpErr  error
+}
+
+// SFTP 返回该连接上共用的 SFTP 客户端
+func (c *conn) SFTP() (*sftp.Client, error) {
+	c.sftpOnce.Do(func() {
+		c.sftp, c.sftpErr = sftp.NewClient(c.client)
+		if c.sftpErr != nil {
+			c.sftpErr = fmt.Errorf("failed to start sftp: %w", c.sftpErr)
+		}
+	})
+	return c.sftp, c.sftpErr
+}
+
+// poolKey 返回连接在池中的键，认证信息不同的配置不共用连接
+func poolKey(config types.SSHConfig) string {
+	secret := sha256.Sum256([]byte(config.Password + "\x00" + config.KeyPassphrase))
+	return fmt.Sprintf("%s@%s|%s|%t|%x", config.User, address(config.Host), config.KeyFile, config.UseAgent, secret[:8])
+}
+
+// Get 获取到主机的连接，使用完毕后需要调用 Put 归还。
+// 并发获取同一主机的连接时只建立一次连接。
+func (p *Pool) Get(config types.SSHConfig) (*conn, error) {
+	key := poolKey(config)
+
+	p.mu.Lock()
+	if c, ok := p.conns[key]; ok && !c.closed {
+		c.refs++
+		if c.idle != nil {
+			c.idle.Stop()
+			c.idle = nil
+		}
+		p.mu.Unlock()
+
+		// 等待其他调用方建立连接
+		<-c.ready
+		if c.err != nil {
+			p.mu.Lock()
+			c.refs--
+			p.mu.Unlock()
+			return nil, c.err
+		}
+		return c, nil
+	}
+	c := &conn{key: key, refs: 1, ready: make(chan struct{})}
+	p.conns[key] = c
+	p.mu.Unlock()
+
+	// 在锁外建立连接，避免阻塞其他主机
+	c.client, c.closer, c.err = dial(config)
+	if c.err != nil {
+		p.mu.Lock()
+		c.closed = true
+		if p.conns[key] == c {
+			delete(p.conns, key)
+		}
+		p.mu.Unlock()
+		close(c.ready)
+		return nil, c.err
+	}
+	close(c.ready)
+
+	// 连接断开时从池中移除
+	go func() {
+		c.client.Wait()
+		p.mu.Lock()
+		c.closed = true
+		if p.conns[key] == c {
+			delete(p.conns, key)
+		}
+		p.mu.Unlock()
+	}()
+	return c, nil
+}
+
+// dial 建立到主机的连接
+func dial(config types.SSHConfig) (*gossh.Client, func(), error) {
+	clientConfig, closer, err := clientConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := gossh.Dial("tcp", address(config.Host), clientConfig)
+	if err != nil {
+		closer()
+		return nil, nil, fmt.Errorf("failed to connect to %s: %w", config.Host, err)
+	}
+	log.Debug("Connected to ssh host %s as %s", config.Host, config.User)
+	return client, closer, nil
+}
+
+// Put 归还连接，没有使用者的连接在空闲超时后关闭
+func (p *Pool) Put(c *conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c.refs--
+	if c.refs > 0 {
+		return
+	}
+	if c.closed {
+		c.close()
+		return
+	}
+	c.idle = time.AfterFunc(p.idleTimeout, func() {
+		p.mu.Lock()
+		if c.refs > 0 {
+			p.mu.Unlock()
+			return
+		}
+		c.closed = true
+		if p.conns[c.key] == c {
+			delete(p.conns, c.key)
+		}
+		p.mu.Unlock()
+		c.close()
+	})
+}
+
+// Discard 归还并关闭出错的连接，之后的 Get 会重新建立连接
+func (p *Pool) Discard(c *conn) {
+	p.mu.Lock()
+	c.refs--
+	c.closed = true
+	if c.idle != nil {
+		c.idle.Stop()
+		c.idle = nil
+	}
+	if p.conns[c.key] == c {
+		delete(p.conns, c.key)
+	}
+	p.mu.Unlock()
+	c.close()
+}
+
+// Close 关闭池中的所有连接
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = make(map[string]*conn)
+	for _, c := range conns {
+		c.closed = true
+		if c.idle != nil {
+			c.idle.Stop()
+		}
+	}
+	p.mu.Unlock()
+
+	for _, c := range conns {
+		<-c.ready
+		if c.err == nil {
+			c.close()
+		}
+	}
+	return nil
+}
+
+// close 关闭连接
+func (c *conn) close() {
+	if c.sftp != nil {
+		c.sftp.Close()
+	}
+	c.client.Close()
+	c.closer()
+}
@@ -0,0 +1,449 @@
+// Package ssh 实现了 SSH 执行器。
+// SSH 执行器通过 SSH 协议在远程主机上执行命令，目标主机上无需安装 runshell，
+// 到同一主机的连接在执行器之间复用，文件操作通过 SFTP 完成。
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"al.essio.dev/pkg/shellescape"
+	"github.com/iamlongalong/runshell/pkg/commands"
+	"github.com/iamlongalong/runshell/pkg/executor"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// DefaultTerminalType 交互式命令默认的终端类型
+const DefaultTerminalType = "xterm"
+
+// envNamePattern 合法的环境变量名
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SSHExecutor SSH 执行器
+type SSHExecutor struct {
+	commands sync.Map              // 注册的命令
+	config   types.SSHConfig       // SSH 配置
+	options  *types.ExecuteOptions // 默认执行选项
+	pool     *Pool                 // 连接池
+}
+
+// NewSSHExecutor 创建新的 SSH 执行器，连接在第一次执行命令时建立
+func NewSSHExecutor(config types.SSHConfig, options *types.ExecuteOptions, provider types.BuiltinCommandProvider) (*SSHExecutor, error) {
+	if config.Host == "" {
+		return nil, fmt.Errorf("ssh host not specified")
+	}
+	if config.User == "" {
+		return nil, fmt.Errorf("ssh user not specified")
+	}
+	if options == nil {
+		options = &types.ExecuteOptions{}
+	}
+	if options.WorkDir != "" {
+		config.WorkDir = options.WorkDir
+	}
+
+	executor := &SSHExecutor{
+		config:  config,
+		options: options,
+		pool:    defaultPool,
+	}
+	if provider != nil {
+		for _, cmd := range provider.GetCommands() {
+			executor.RegisterCommand(cmd)
+		}
+	}
+	return executor, nil
+}
+
+// WithPool 设置使用的连接池
+func (e *SSHExecutor) WithPool(pool *Pool) *SSHExecutor {
+	e.pool = pool
+	return e
+}
+
+// Name 返回执行器名称
+func (e *SSHExecutor) Name() string {
+	return types.ExecutorTypeSSH
+}
+
+// Execute 执行命令
+func (e *SSHExecutor) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("context is nil")
+	}
+	log.Debug("Executing command on ssh host %s: %s %v", e.config.Host, ctx.Command.Command, ctx.Command.Args)
+
+	// 合并默认选项和用户自定义选项
+	if ctx.Options == nil {
+		ctx.Options = &types.ExecuteOptions{}
+	}
+	if ctx.Options.WorkDir == "" && e.config.WorkDir != "" {
+		ctx.Options.WorkDir = e.config.WorkDir
+	}
+	ctx.Options = ctx.Options.Merge(e.options)
+	if ctx.Context == nil {
+		ctx.Context = context.Background()
+	}
+
+	if ctx.IsPiped {
+		if ctx.PipeContext == nil || len(ctx.PipeContext.Commands) == 0 {
+			return nil, fmt.Errorf("no commands in pipeline")
+		}
+	} else if ctx.Command.Command == "" {
+		return nil, fmt.Errorf("no command specified")
+	}
+
+	// 如果是交互式命令
+	if ctx.Interactive {
+		return e.ExecuteInteractive(ctx)
+	}
+
+	// 检查是否内置命令
+	if !ctx.IsPiped {
+		if cmd, ok := e.commands.Load(ctx.Command.Command); ok {
+			log.Debug("Executing built-in command: %s", ctx.Command.Command)
+			command := cmd.(types.ICommand)
+			if err := types.ValidateArgs(command.Info(), ctx.Command.Args); err != nil {
+				return nil, err
+			}
+
+			ctx.Executor = e
+			return command.Execute(ctx)
+		}
+	}
+
+	return e.ExecuteCommand(ctx)
+}
+
+// ExecuteCommand 在远程主机上执行命令
+func (e *SSHExecutor) ExecuteCommand(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if ctx.Options == nil {
+		ctx.Options = &types.ExecuteOptions{}
+	}
+	if ctx.Context == nil {
+		ctx.Context = context.Background()
+	}
+	line, err := e.commandLine(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	execCtx := ctx.Context
+	if ctx.Options.Timeout > 0 {
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithTimeout(execCtx, time.Duration(ctx.Options.Timeout))
+		defer cancel()
+	}
+
+	c, session, err := e.newSession()
+	if err != nil {
+		return nil, err
+	}
+	defer e.pool.Put(c)
+	defer session.Close()
+
+	// 设置输入输出
+	var stdoutBuf, stderrBuf bytes.Buffer
+	session.Stdin = ctx.Options.Stdin
+	session.Stdout = &stdoutBuf
+	if ctx.Options.Stdout != nil {
+		session.Stdout = io.MultiWriter(&stdoutBuf, ctx.Options.Stdout)
+	}
+	session.Stderr = &stderrBuf
+	if ctx.Options.Stderr != nil {
+		session.Stderr = io.MultiWriter(&stderrBuf, ctx.Options.Stderr)
+	}
+
+	startTime := types.GetTimeNow()
+	if err := session.Start(line); err != nil {
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+	err = e.wait(execCtx, session)
+	endTime := types.GetTimeNow()
+
+	result := &types.ExecuteResult{
+		CommandName: ctx.Command.Command,
+		StartTime:   startTime,
+		EndTime:     endTime,
+	}
+
+	// 合并输出
+	result.Output = stdoutBuf.String()
+	if stderrBuf.Len() > 0 {
+		if result.Output != "" {
+			result.Output += "\n"
+		}
+		result.Output += stderrBuf.String()
+	}
+
+	if err != nil {
+		if ctxErr := execCtx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("ssh command %s aborted: %w", ctx.Command.Command, ctxErr)
+		}
+		result.ExitCode = executor.ExitCode(err)
+		result.Error = err
+		log.Error("Command %s failed on %s with exit code %d", line, e.config.Host, result.ExitCode)
+		return result, err
+	}
+	return result, nil
+}
+
+// ExecuteInteractive 在伪终端中执行交互式命令，终端大小调整通过 window-change 请求同步到远程主机
+func (e *SSHExecutor) ExecuteInteractive(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	if ctx.Options == nil {
+		ctx.Options = &types.ExecuteOptions{}
+	}
+	if ctx.Context == nil {
+		ctx.Context = context.Background()
+	}
+	line, err := e.commandLine(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, session, err := e.newSession()
+	if err != nil {
+		return nil, err
+	}
+	defer e.pool.Put(c)
+	defer session.Close()
+
+	opts := ctx.InteractiveOpts
+	if opts == nil {
+		opts = &types.InteractiveOptions{}
+	}
+	term := opts.TerminalType
+	if term == "" {
+		term = DefaultTerminalType
+	}
+	rows, cols := opts.Rows, opts.Cols
+	if rows == 0 || cols == 0 {
+		rows, cols = 24, 80
+	}
+	modes := gossh.TerminalModes{
+		gossh.ECHO:          1,
+		gossh.TTY_OP_ISPEED: 14400,
+		gossh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty(term, int(rows), int(cols), modes); err != nil {
+		return nil, fmt.Errorf("failed to request pty: %w", err)
+	}
+
+	// 伪终端中标准错误与标准输出合并
+	session.Stdin = ctx.Options.Stdin
+	if ctx.Options.Stdout != nil {
+		session.Stdout = ctx.Options.Stdout
+		session.Stderr = ctx.Options.Stdout
+	}
+
+	startTime := types.GetTimeNow()
+	if err := session.Start(line); err != nil {
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	// 处理终端大小调整
+	done := make(chan struct{})
+	defer close(done)
+	if opts.Resize != nil {
+		go func() {
+			for {
+				select {
+				case size, ok := <-opts.Resize:
+					if !ok {
+						return
+					}
+					if err := session.WindowChange(int(size.Rows), int(size.Cols)); err != nil {
+						log.Error("Failed to resize pty: %v", err)
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	err = e.wait(ctx.Context, session)
+	result := &types.ExecuteResult{
+		CommandName: ctx.Command.Command,
+		StartTime:   startTime,
+		EndTime:     types.GetTimeNow(),
+	}
+	if err != nil {
+		if ctxErr := ctx.Context.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		result.ExitCode = executor.ExitCode(err)
+		result.Error = err
+		return result, err
+	}
+	return result, nil
+}
+
+// newSession 从连接池获取连接并打开会话，连接已断开时重新建立一次
+func (e *SSHExecutor) newSession() (*conn, *gossh.Session, error) {
+	for attempt := 0; ; attempt++ {
+		c, err := e.pool.Get(e.config)
+		if err != nil {
+			return nil, nil, err
+		}
+		session, err := c.client.NewSession()
+		if err == nil {
+			return c, session, nil
+		}
+		e.pool.Discard(c)
+		if attempt > 0 {
+			return nil, nil, fmt.Errorf("failed to open ssh session: %w", err)
+		}
+		log.Debug("Reconnecting to %s after session error: %v", e.config.Host, err)
+	}
+}
+
+// wait 等待命令结束，Context 取消时终止远程命令
+func (e *SSHExecutor) wait(ctx context.Context, session *gossh.Session) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// 不是所有服务端都支持信号，关闭会话作为兜底
+		session.Signal(gossh.SIGKILL)
+		session.Close()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// commandLine 生成在远程 shell 中执行的命令行，包含工作目录和环境变量
+func (e *SSHExecutor) commandLine(ctx *types.ExecuteContext) (string, error) {
+	var parts []string
+	if ctx.Options.WorkDir != "" {
+		parts = append(parts, "cd "+shellescape.Quote(ctx.Options.WorkDir))
+	}
+
+	if len(ctx.Options.Env) > 0 {
+		keys := make([]string, 0, len(ctx.Options.Env))
+		for k := range ctx.Options.Env {
+			if !envNamePattern.MatchString(k) {
+				return "", fmt.Errorf("invalid environment variable name: %s", k)
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		exports := make([]string, len(keys))
+		for i, k := range keys {
+			exports[i] = k + "=" + shellescape.Quote(ctx.Options.Env[k])
+		}
+		parts = append(parts, "export "+strings.Join(exports, " "))
+	}
+
+	if ctx.IsPiped && ctx.PipeContext != nil {
+		cmds := make([]string, 0, len(ctx.PipeContext.Commands))
+		for _, cmd := range ctx.PipeContext.Commands {
+			cmds = append(cmds, quoteCommand(cmd))
+		}
+		parts = append(parts, strings.Join(cmds, " | "))
+	} else {
+		parts = append(parts, quoteCommand(&ctx.Command))
+	}
+	return strings.Join(parts, " && "), nil
+}
+
+// quoteCommand 将命令转换为 shell 命令行中的一段
+func quoteCommand(cmd *types.Command) string {
+	parts := []string{shellescape.Quote(cmd.Command)}
+	for _, arg := range cmd.Args {
+		parts = append(parts, shellescape.Quote(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ListCommands 列出所有内置命令
+func (e *SSHExecutor) ListCommands() []types.CommandInfo {
+	commands := make([]types.CommandInfo, 0)
+	e.commands.Range(func(key, value interface{}) bool {
+		commands = append(commands, value.(types.ICommand).Info())
+		return true
+	})
+	return commands
+}
+
+// RegisterCommand 注册命令
+func (e *SSHExecutor) RegisterCommand(cmd types.ICommand) error {
+	if cmd == nil {
+		return fmt.Errorf("command is nil")
+	}
+	if cmd.Info().Name == "" {
+		return fmt.Errorf("command name is empty")
+	}
+	e.commands.Store(cmd.Info().Name, cmd)
+	return nil
+}
+
+// UnregisterCommand 注销命令
+func (e *SSHExecutor) UnregisterCommand(cmdName string) error {
+	if cmdName == "" {
+		return fmt.Errorf("command name is empty")
+	}
+	e.commands.Delete(cmdName)
+	return nil
+}
+
+// Close 关闭执行器。连接由连接池管理，空闲后自动关闭
+func (e *SSHExecutor) Close() error {
+	return nil
+}
+
+// SSHExecutorBuilder 是 SSH 执行器的构建器。
+type SSHExecutorBuilder struct {
+	config  types.SSHConfig
+	options *types.ExecuteOptions
+	pool    *Pool
+}
+
+// NewSSHExecutorBuilder 创建一个新的 SSH 执行器构建器。
+func NewSSHExecutorBuilder(config types.SSHConfig) *SSHExecutorBuilder {
+	return &SSHExecutorBuilder{
+		config: config,
+		pool:   defaultPool,
+	}
+}
+
+// WithOptions 设置执行选项。
+func (b *SSHExecutorBuilder) WithOptions(options *types.ExecuteOptions) *SSHExecutorBuilder {
+	b.options = options
+	return b
+}
+
+// WithPool 设置构建的执行器使用的连接池。
+func (b *SSHExecutorBuilder) WithPool(pool *Pool) *SSHExecutorBuilder {
+	b.pool = pool
+	return b
+}
+
+// Build 构建并返回一个新的 SSH 执行器实例。
+func (b *SSHExecutorBuilder) Build(options *types.ExecuteOptions) (types.Executor, error) {
+	if options == nil {
+		options = b.options
+	}
+	var provider types.BuiltinCommandProvider
+	if b.config.UseBuiltinCommands {
+		provider = commands.NewDefaultCommandProvider()
+	}
+	executor, err := NewSSHExecutor(b.config, options, provider)
+	if err != nil {
+		return nil, err
+	}
+	return executor.WithPool(b.pool), nil
+}
@@ -0,0 +1,604 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/executor"
+	"github.com/iamlongalong/runshell/pkg/server"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const testPassword = "secret"
+
+// testServer 是测试使用的进程内 SSH 服务端，命令在本机通过 sh -c 执行
+type testServer struct {
+	addr       string
+	hostKey    gossh.PublicKey
+	keyFile    string // 客户端私钥
+	knownHosts string // 包含服务端主机密钥的 known_hosts
+	conns      atomic.Int32
+}
+
+func newTestServer(t *testing.T) *testServer {
+	dir := t.TempDir()
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := gossh.NewSignerFromKey(hostPriv)
+	require.NoError(t, err)
+
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := gossh.MarshalPrivateKey(clientPriv, "")
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+	authorized, err := gossh.NewPublicKey(clientPub)
+	require.NoError(t, err)
+
+	config := &gossh.ServerConfig{
+		PublicKeyCallback: func(conn gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+		PasswordCallback: func(conn gossh.ConnMetadata, password []byte) (*gossh.Permissions, error) {
+			if string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	s := &testServer{
+		addr:    ln.Addr().String(),
+		hostKey: hostSigner.PublicKey(),
+		keyFile: keyFile,
+	}
+	s.knownHosts = s.writeKnownHosts(t, s.hostKey)
+
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveConn(nc, config)
+		}
+	}()
+	return s
+}
+
+// writeKnownHosts 生成记录服务端地址和指定主机密钥的 known_hosts 文件
+func (s *testServer) writeKnownHosts(t *testing.T, key gossh.PublicKey) string {
+	file := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.addr)}, key)
+	require.NoError(t, os.WriteFile(file, []byte(line+"\n"), 0600))
+	return file
+}
+
+// config 返回使用私钥认证的客户端配置
+func (s *testServer) config() types.SSHConfig {
+	return types.SSHConfig{
+		Host:           s.addr,
+		User:           "tester",
+		KeyFile:        s.keyFile,
+		KnownHostsFile: s.knownHosts,
+	}
+}
+
+func (s *testServer) serveConn(nc net.Conn, config *gossh.ServerConfig) {
+	sc, chans, reqs, err := gossh.NewServerConn(nc, config)
+	if err != nil {
+		nc.Close()
+		return
+	}
+	defer sc.Close()
+	s.conns.Add(1)
+	go gossh.DiscardRequests(reqs)
+
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(gossh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go serveSession(ch, chReqs)
+	}
+}
+
+// serveSession 处理一个会话上的 pty-req、window-change、exec、signal 和 sftp 子系统请求
+func serveSession(ch gossh.Channel, reqs <-chan *gossh.Request) {
+	defer ch.Close()
+
+	var (
+		mu   sync.Mutex
+		cmd  *exec.Cmd
+		ptmx *os.File
+		size *pty.Winsize
+	)
+	kill := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if cmd != nil && cmd.Process != nil {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+	defer kill()
+
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			var msg struct {
+				Term             string
+				Cols, Rows, W, H uint32
+				Modes            string
+			}
+			if err := gossh.Unmarshal(req.Payload, &msg); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			size = &pty.Winsize{Rows: uint16(msg.Rows), Cols: uint16(msg.Cols)}
+			req.Reply(true, nil)
+		case "window-change":
+			var msg struct{ Cols, Rows, W, H uint32 }
+			if err := gossh.Unmarshal(req.Payload, &msg); err == nil {
+				mu.Lock()
+				if ptmx != nil {
+					pty.Setsize(ptmx, &pty.Winsize{Rows: uint16(msg.Rows), Cols: uint16(msg.Cols)})
+				}
+				mu.Unlock()
+			}
+		case "signal":
+			kill()
+		case "exec":
+			var msg struct{ Command string }
+			if err := gossh.Unmarshal(req.Payload, &msg); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			c := exec.Command("sh", "-c", msg.Command)
+			c.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+			if size != nil {
+				f, err := pty.StartWithSize(c, size)
+				if err != nil {
+					req.Reply(false, nil)
+					continue
+				}
+				mu.Lock()
+				cmd, ptmx = c, f
+				mu.Unlock()
+				req.Reply(true, nil)
+				go func() {
+					go io.Copy(f, ch)
+					io.Copy(ch, f)
+					finish(ch, c.Wait())
+				}()
+				continue
+			}
+
+			c.Stdout = ch
+			c.Stderr = ch.Stderr()
+			stdin, _ := c.StdinPipe()
+			if err := c.Start(); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			mu.Lock()
+			cmd = c
+			mu.Unlock()
+			req.Reply(true, nil)
+			go func() {
+				io.Copy(stdin, ch)
+				stdin.Close()
+			}()
+			go func() {
+				finish(ch, c.Wait())
+			}()
+		case "subsystem":
+			var msg struct{ Name string }
+			if err := gossh.Unmarshal(req.Payload, &msg); err != nil || msg.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			server, err := sftp.NewServer(ch)
+			if err != nil {
+				return
+			}
+			go func() {
+				server.Serve()
+				server.Close()
+				ch.Close()
+			}()
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// finish 发送退出码并关闭会话
+func finish(ch gossh.Channel, err error) {
+	status := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		status = exitErr.ExitCode()
+		if status < 0 {
+			status = 255
+		}
+	}
+	ch.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{uint32(status)}))
+	ch.Close()
+}
+
+func newTestExecutor(t *testing.T, s *testServer, options *types.ExecuteOptions) *SSHExecutor {
+	pool := NewPool(time.Minute)
+	t.Cleanup(func() { pool.Close() })
+	exec, err := NewSSHExecutor(s.config(), options, nil)
+	require.NoError(t, err)
+	return exec.WithPool(pool)
+}
+
+func TestSSHExecutor(t *testing.T) {
+	s := newTestServer(t)
+	workDir := t.TempDir()
+	exec := newTestExecutor(t, s, &types.ExecuteOptions{WorkDir: workDir})
+	assert.Equal(t, types.ExecutorTypeSSH, exec.Name())
+
+	t.Run("output", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		result, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "sh", Args: []string{"-c", "pwd; echo err >&2"}},
+			Options: &types.ExecuteOptions{Stdout: &stdout, Stderr: &stderr},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Equal(t, workDir+"\n", stdout.String())
+		assert.Equal(t, "err\n", stderr.String())
+		assert.Equal(t, workDir+"\n\nerr\n", result.Output)
+	})
+
+	t.Run("env and quoting", func(t *testing.T) {
+		result, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "sh", Args: []string{"-c", `printf '%s|%s' "$FOO" "$1"`, "sh", "a b; c"}},
+			Options: &types.ExecuteOptions{Env: map[string]string{"FOO": "it's $HOME"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "it's $HOME|a b; c", result.Output)
+
+		_, err = exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "true"},
+			Options: &types.ExecuteOptions{Env: map[string]string{"A;B": "x"}},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("stdin and pipeline", func(t *testing.T) {
+		result, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			IsPiped: true,
+			PipeContext: &types.PipelineContext{Commands: []*types.Command{
+				{Command: "cat"},
+				{Command: "tr", Args: []string{"a-z", "A-Z"}},
+			}},
+			Options: &types.ExecuteOptions{Stdin: strings.NewReader("hello")},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "HELLO", result.Output)
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		result, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "sh", Args: []string{"-c", "exit 3"}},
+		})
+		assert.Error(t, err)
+		require.NotNil(t, result)
+		assert.Equal(t, 3, result.ExitCode)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "sleep", Args: []string{"10"}},
+			Options: &types.ExecuteOptions{Timeout: int64(200 * time.Millisecond)},
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := exec.Execute(&types.ExecuteContext{
+			Context: ctx,
+			Command: types.Command{Command: "sleep", Args: []string{"10"}},
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
+
+// lineWriter 在输出中出现指定内容时通知
+type lineWriter struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	want  string
+	found chan struct{}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	if w.want != "" && strings.Contains(w.buf.String(), w.want) {
+		w.want = ""
+		close(w.found)
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestSSHInteractive(t *testing.T) {
+	s := newTestServer(t)
+	exec := newTestExecutor(t, s, nil)
+
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close()
+	stdout := &lineWriter{want: "24 80", found: make(chan struct{})}
+	resize := make(chan types.TerminalSize, 1)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := exec.Execute(&types.ExecuteContext{
+			Context:     context.Background(),
+			Command:     types.Command{Command: "sh", Args: []string{"-c", "stty size; read x; stty size"}},
+			Options:     &types.ExecuteOptions{Stdin: stdinR, Stdout: stdout},
+			Interactive: true,
+			InteractiveOpts: &types.InteractiveOptions{
+				Rows:   24,
+				Cols:   80,
+				Resize: resize,
+			},
+		})
+		done <- err
+	}()
+
+	select {
+	case <-stdout.found:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("initial size not reported, output: %q", stdout.String())
+	}
+	resize <- types.TerminalSize{Rows: 40, Cols: 120}
+	time.Sleep(200 * time.Millisecond)
+	_, err := stdinW.Write([]byte("x\n"))
+	require.NoError(t, err)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("interactive command did not finish")
+	}
+	assert.Contains(t, stdout.String(), "40 120")
+}
+
+func TestSSHFileSystem(t *testing.T) {
+	s := newTestServer(t)
+	workDir := t.TempDir()
+	exec := newTestExecutor(t, s, &types.ExecuteOptions{WorkDir: workDir})
+
+	provider, ok := types.As[types.FileSystemProvider](exec)
+	require.True(t, ok)
+	fsys, err := provider.FileSystem("")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, fsys.Mkdir(ctx, "a/b", 0755, true))
+	require.NoError(t, fsys.Mkdir(ctx, "a/b", 0755, true))
+	assert.ErrorIs(t, fsys.Mkdir(ctx, "a", 0755, false), os.ErrExist)
+
+	require.NoError(t, fsys.WriteFile(ctx, "a/b/c.txt", []byte("hello"), 0600))
+	data, err := os.ReadFile(filepath.Join(workDir, "a/b/c.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	data, err = fsys.ReadFile(ctx, "a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	info, err := fsys.Stat(ctx, "a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "c.txt", info.Name)
+	assert.Equal(t, int64(5), info.Size)
+	assert.Equal(t, os.FileMode(0600), info.Mode.Perm())
+
+	require.NoError(t, fsys.WriteFile(ctx, "a/z.txt", []byte("z"), 0644))
+	entries, err := fsys.ReadDir(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "b", entries[0].Name)
+	assert.True(t, entries[0].IsDir)
+	assert.Equal(t, "a/z.txt", entries[1].Path)
+
+	require.NoError(t, fsys.Rename(ctx, "a/z.txt", "a/b/c.txt"))
+	data, err = fsys.ReadFile(ctx, "a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "z", string(data))
+
+	_, err = fsys.Stat(ctx, "missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = fsys.ReadFile(ctx, "missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.Error(t, fsys.Remove(ctx, "a", false))
+	require.NoError(t, fsys.Remove(ctx, "a", true))
+	_, err = os.Stat(filepath.Join(workDir, "a"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Error(t, fsys.Remove(ctx, ".", true))
+}
+
+func TestSSHAuth(t *testing.T) {
+	s := newTestServer(t)
+
+	run := func(config types.SSHConfig) error {
+		pool := NewPool(time.Minute)
+		defer pool.Close()
+		exec, err := NewSSHExecutor(config, nil, nil)
+		if err != nil {
+			return err
+		}
+		_, err = exec.WithPool(pool).Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "true"},
+		})
+		return err
+	}
+
+	assert.NoError(t, run(s.config()))
+
+	// 密码认证
+	config := s.config()
+	config.KeyFile = ""
+	config.Password = testPassword
+	assert.NoError(t, run(config))
+	config.Password = "wrong"
+	assert.Error(t, run(config))
+
+	// 主机密钥与 known_hosts 不一致
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := gossh.NewPublicKey(otherPub)
+	require.NoError(t, err)
+	config = s.config()
+	config.KnownHostsFile = s.writeKnownHosts(t, otherKey)
+	err = run(config)
+	var keyErr *knownhosts.KeyError
+	assert.ErrorAs(t, err, &keyErr)
+
+	// 显式跳过主机密钥校验
+	config.InsecureIgnoreHostKey = true
+	assert.NoError(t, run(config))
+
+	// 缺少认证方式
+	config = s.config()
+	config.KeyFile = ""
+	assert.Error(t, run(config))
+}
+
+func TestSSHPool(t *testing.T) {
+	s := newTestServer(t)
+	pool := NewPool(100 * time.Millisecond)
+	defer pool.Close()
+
+	builder := NewSSHExecutorBuilder(s.config()).WithPool(pool)
+	run := func() {
+		exec, err := builder.Build(nil)
+		require.NoError(t, err)
+		defer exec.Close()
+		_, err = exec.Execute(&types.ExecuteContext{
+			Context: context.Background(),
+			Command: types.Command{Command: "true"},
+		})
+		require.NoError(t, err)
+	}
+
+	// 并发和连续执行的命令共用一个连接
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run()
+		}()
+	}
+	wg.Wait()
+	run()
+	assert.Equal(t, int32(1), s.conns.Load())
+
+	// 空闲超时后连接关闭，再次执行时重新建立
+	time.Sleep(300 * time.Millisecond)
+	run()
+	assert.Equal(t, int32(2), s.conns.Load())
+}
+
+func TestSessionOnSSH(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer(t)
+	hostDir := t.TempDir()
+	pool := NewPool(time.Minute)
+	defer pool.Close()
+
+	front := server.NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		AllowUnregisteredCommands: true,
+		WorkDir:                   t.TempDir(),
+	}), ":0").WithSSHHost("vm-1", NewSSHExecutorBuilder(s.config()).WithPool(pool))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		front.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/v1/sessions", `{"executor_type":"ssh","remote":"vm-2"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do("POST", "/api/v1/sessions", `{"executor_type":"ssh","options":{"workdir":"`+hostDir+`"}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var sessResp types.SessionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessResp))
+
+	w = do("POST", "/api/v1/sessions/"+sessResp.Session.ID+"/exec", `{"command":"pwd"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp server.ExecResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, hostDir+"\n", resp.Output)
+}
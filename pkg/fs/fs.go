@@ -0,0 +1,180 @@
+// Package fs 提供了 types.FileSystem 的宿主机实现以及通用的文件操作辅助函数。
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// Join 拼接文件系统路径，使用 "/" 分隔
+func Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// IsNotExist 判断错误是否表示文件不存在
+func IsNotExist(err error) bool {
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// SkipDir 由 WalkFunc 返回时跳过当前目录
+var SkipDir = errors.New("skip this directory")
+
+// WalkFunc 是 Walk 访问每个条目时调用的函数，depth 为相对于根路径的层级（根路径为 0）。
+// err 不为空时表示读取该条目失败，返回 nil 可以忽略错误继续遍历。
+type WalkFunc func(name string, info *types.FileInfo, depth int, err error) error
+
+// Walk 深度优先遍历 root 下的所有条目，同一目录下按名称排序。
+// 符号链接不会被跟随。子路径使用 root 作为前缀拼接，与 find 的输出一致。
+func Walk(ctx context.Context, fsys types.FileSystem, root string, fn WalkFunc) error {
+	info, err := fsys.Stat(ctx, root)
+	if err != nil {
+		err = fn(root, nil, 0, err)
+	} else {
+		err = walk(ctx, fsys, root, info, 0, fn)
+	}
+	if err == SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walk(ctx context.Context, fsys types.FileSystem, name string, info *types.FileInfo, depth int, fn WalkFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := fn(name, info, depth, nil); err != nil || !info.IsDir {
+		return err
+	}
+
+	entries, err := fsys.ReadDir(ctx, name)
+	if err != nil {
+		return fn(name, info, depth, err)
+	}
+	for i := range entries {
+		child := strings.TrimSuffix(name, "/") + "/" + entries[i].Name
+		entries[i].Path = child
+		if err := walk(ctx, fsys, child, &entries[i], depth+1, fn); err != nil {
+			if err == SkipDir {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// maxLinks 解析符号链接时允许的最大跳转次数
+const maxLinks = 40
+
+// WriteFileAtomic 原子地写入文件：先写入同目录下的临时文件，再重命名为目标文件。
+// name 为符号链接时写入链接最终指向的文件，链接本身保持不变。目标文件已存在时保留其权限。
+func WriteFileAtomic(ctx context.Context, fsys types.FileSystem, name string, data []byte, perm os.FileMode) error {
+	name, info, err := ResolveLink(ctx, fsys, name)
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		if info.IsDir {
+			return &os.PathError{Op: "write", Path: name, Err: fmt.Errorf("is a directory")}
+		}
+		perm = info.Mode.Perm()
+	}
+
+	tmp := path.Join(path.Dir(name), fmt.Sprintf(".%s.runshell-%d.tmp", path.Base(name), time.Now().UnixNano()))
+	if err := fsys.WriteFile(ctx, tmp, data, perm); err != nil {
+		return err
+	}
+	if err := fsys.Rename(ctx, tmp, name); err != nil {
+		_ = fsys.Remove(ctx, tmp, false)
+		return err
+	}
+	return nil
+}
+
+// ResolveLink 跟随符号链接，返回最终指向的路径及其信息，路径不存在时信息为 nil
+func ResolveLink(ctx context.Context, fsys types.FileSystem, name string) (string, *types.FileInfo, error) {
+	for i := 0; i < maxLinks; i++ {
+		info, err := fsys.Stat(ctx, name)
+		if IsNotExist(err) {
+			return name, nil, nil
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if info.Mode&os.ModeSymlink == 0 {
+			return name, info, nil
+		}
+		if info.Link == "" {
+			return "", nil, &os.PathError{Op: "readlink", Path: name, Err: fmt.Errorf("unknown symbolic link target")}
+		}
+		target := info.Link
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(name), target)
+		}
+		name = target
+	}
+	return "", nil, &os.PathError{Op: "write", Path: name, Err: fmt.Errorf("too many levels of symbolic links")}
+}
+
+// Copy 复制文件或目录，recursive 为 false 时源路径不能是目录。
+// 目标为已存在的目录时，复制到该目录下的同名文件。返回复制的文件数量。
+func Copy(ctx context.Context, fsys types.FileSystem, src, dst string, recursive bool) (int, error) {
+	info, err := fsys.Stat(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir && !recursive {
+		return 0, fmt.Errorf("%s: is a directory (use -r to copy directories)", src)
+	}
+
+	if dstInfo, err := fsys.Stat(ctx, dst); err == nil && dstInfo.IsDir {
+		dst = Join(dst, info.Name)
+	} else if err != nil && !IsNotExist(err) {
+		return 0, err
+	}
+
+	return copyEntry(ctx, fsys, src, dst, info)
+}
+
+// copyEntry 复制单个条目，目录会递归复制
+func copyEntry(ctx context.Context, fsys types.FileSystem, src, dst string, info *types.FileInfo) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if !info.IsDir {
+		data, err := fsys.ReadFile(ctx, src)
+		if err != nil {
+			return 0, err
+		}
+		if err := fsys.WriteFile(ctx, dst, data, info.Mode.Perm()); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	if err := fsys.Mkdir(ctx, dst, info.Mode.Perm(), true); err != nil {
+		return 0, err
+	}
+	entries, err := fsys.ReadDir(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range entries {
+		n, err := copyEntry(ctx, fsys, Join(src, entries[i].Name), Join(dst, entries[i].Name), &entries[i])
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,221 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// LocalFS 宿主机文件系统。
+// 设置了根目录时，所有路径（包括绝对路径和符号链接的目标）都被限制在根目录内。
+type LocalFS struct {
+	root string // 根目录的绝对路径，为空表示不限制
+}
+
+// NewLocalFS 创建以 root 为工作目录的宿主机文件系统，root 为空时不做路径限制
+func NewLocalFS(root string) (*LocalFS, error) {
+	if root == "" {
+		return &LocalFS{}, nil
+	}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve work directory: %w", err)
+	}
+	// 根目录本身可能是符号链接（如 macOS 的 /tmp）
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+	return &LocalFS{root: abs}, nil
+}
+
+// Root 返回根目录
+func (f *LocalFS) Root() string {
+	return f.root
+}
+
+// resolve 将路径解析为宿主机路径，并检查是否超出根目录。
+// follow 为 true 时同时检查路径本身（而不仅是上级目录）的符号链接目标。
+func (f *LocalFS) resolve(name string, follow bool) (string, error) {
+	if f.root == "" {
+		return filepath.Clean(name), nil
+	}
+
+	var p string
+	if filepath.IsAbs(name) {
+		p = filepath.Clean(name)
+	} else {
+		p = filepath.Join(f.root, name)
+	}
+	if !f.within(p) {
+		return "", fmt.Errorf("%s: path is outside of work directory", name)
+	}
+
+	// 检查符号链接，找到已存在的最深层路径并解析其真实位置
+	check := p
+	if !follow {
+		check = filepath.Dir(p)
+	}
+	for f.within(check) {
+		real, err := filepath.EvalSymlinks(check)
+		if err == nil {
+			if !f.within(real) {
+				return "", fmt.Errorf("%s: path is outside of work directory", name)
+			}
+			break
+		}
+		check = filepath.Dir(check)
+	}
+	return p, nil
+}
+
+// within 判断路径是否位于根目录内
+func (f *LocalFS) within(p string) bool {
+	rel, err := filepath.Rel(f.root, p)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Stat 实现 types.FileSystem 接口
+func (f *LocalFS) Stat(ctx context.Context, name string) (*types.FileInfo, error) {
+	p, err := f.resolve(name, false)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	fi := localFileInfo(name, p, info)
+	return &fi, nil
+}
+
+// ReadDir 实现 types.FileSystem 接口
+func (f *LocalFS) ReadDir(ctx context.Context, name string) ([]types.FileInfo, error) {
+	p, err := f.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]types.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			// 读取过程中被删除的条目直接跳过
+			continue
+		}
+		infos = append(infos, localFileInfo(Join(name, entry.Name()), filepath.Join(p, entry.Name()), info))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, nil
+}
+
+// ReadFile 实现 types.FileSystem 接口
+func (f *LocalFS) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	p, err := f.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+// Open 实现 types.FileSystem 接口
+func (f *LocalFS) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	p, err := f.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// WriteFile 实现 types.FileSystem 接口
+func (f *LocalFS) WriteFile(ctx context.Context, name string, data []byte, perm os.FileMode) error {
+	p, err := f.resolve(name, true)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, perm)
+}
+
+// OpenFile 实现 types.FileSystem 接口
+func (f *LocalFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+	p, err := f.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, flag|os.O_WRONLY, perm)
+}
+
+// Mkdir 实现 types.FileSystem 接口
+func (f *LocalFS) Mkdir(ctx context.Context, name string, perm os.FileMode, parents bool) error {
+	p, err := f.resolve(name, false)
+	if err != nil {
+		return err
+	}
+	if parents {
+		return os.MkdirAll(p, perm)
+	}
+	return os.Mkdir(p, perm)
+}
+
+// Remove 实现 types.FileSystem 接口
+func (f *LocalFS) Remove(ctx context.Context, name string, recursive bool) error {
+	p, err := f.resolve(name, false)
+	if err != nil {
+		return err
+	}
+	if f.root != "" && p == f.root {
+		return fmt.Errorf("%s: refusing to remove work directory", name)
+	}
+	if !recursive {
+		return os.Remove(p)
+	}
+	// os.RemoveAll 对不存在的路径不报错，这里保持与 rm -r 一致
+	if _, err := os.Lstat(p); err != nil {
+		return err
+	}
+	return os.RemoveAll(p)
+}
+
+// Rename 实现 types.FileSystem 接口
+func (f *LocalFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldPath, err := f.resolve(oldName, false)
+	if err != nil {
+		return err
+	}
+	newPath, err := f.resolve(newName, false)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// localFileInfo 将 os.FileInfo 转换为 types.FileInfo
+func localFileInfo(name, p string, info os.FileInfo) types.FileInfo {
+	fi := types.FileInfo{
+		Name:    info.Name(),
+		Path:    name,
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		fi.Link, _ = os.Readlink(p)
+	}
+	return fi
+}
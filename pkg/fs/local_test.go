@@ -0,0 +1,149 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalFS(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	fsys, err := NewLocalFS(root)
+	require.NoError(t, err)
+
+	require.NoError(t, fsys.Mkdir(ctx, "a/b", 0755, true))
+	require.NoError(t, fsys.WriteFile(ctx, "a/b/c.txt", []byte("hello"), 0600))
+
+	data, err := fsys.ReadFile(ctx, "a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	info, err := fsys.Stat(ctx, "a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "c.txt", info.Name)
+	assert.Equal(t, int64(5), info.Size)
+	assert.Equal(t, os.FileMode(0600), info.Mode.Perm())
+
+	entries, err := fsys.ReadDir(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "a/b", entries[0].Path)
+	assert.True(t, entries[0].IsDir)
+
+	// 绝对路径只要位于根目录内即可访问
+	_, err = fsys.Stat(ctx, filepath.Join(fsys.Root(), "a"))
+	assert.NoError(t, err)
+
+	// 流式写入，追加和清空
+	w, err := fsys.OpenFile(ctx, "a/b/c.txt", os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(" world"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	data, err = fsys.ReadFile(ctx, "a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+	w, err = fsys.OpenFile(ctx, "a/b/c.txt", os.O_TRUNC, 0644)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	info, err = fsys.Stat(ctx, "a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size)
+	_, err = fsys.OpenFile(ctx, "a/b/missing.txt", os.O_APPEND, 0644)
+	assert.True(t, IsNotExist(err))
+
+	assert.Error(t, fsys.Mkdir(ctx, "x/y", 0755, false))
+	assert.Error(t, fsys.Remove(ctx, "a", false))
+	require.NoError(t, fsys.Remove(ctx, "a", true))
+	_, err = fsys.Stat(ctx, "a")
+	assert.True(t, IsNotExist(err))
+}
+
+func TestLocalFSConfinement(t *testing.T) {
+	ctx := context.Background()
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("x"), 0644))
+
+	root := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "escape")))
+	fsys, err := NewLocalFS(root)
+	require.NoError(t, err)
+
+	for _, name := range []string{"../secret", filepath.Join(outside, "secret"), "escape/secret"} {
+		_, err := fsys.ReadFile(ctx, name)
+		assert.Error(t, err, name)
+		assert.Contains(t, err.Error(), "outside of work directory", name)
+	}
+	assert.Error(t, fsys.WriteFile(ctx, "escape/new", []byte("x"), 0644))
+	_, err = fsys.OpenFile(ctx, "escape/new", os.O_CREATE|os.O_APPEND, 0644)
+	assert.Error(t, err)
+	assert.Error(t, fsys.Remove(ctx, ".", true))
+
+	// 符号链接本身位于根目录内，可以查看和删除
+	info, err := fsys.Stat(ctx, "escape")
+	require.NoError(t, err)
+	assert.Equal(t, outside, info.Link)
+	assert.NoError(t, fsys.Remove(ctx, "escape", false))
+}
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	fsys, err := NewLocalFS(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, fsys.Mkdir(ctx, "src/sub", 0755, true))
+	require.NoError(t, fsys.WriteFile(ctx, "src/a.txt", []byte("a"), 0644))
+	require.NoError(t, fsys.WriteFile(ctx, "src/sub/b.txt", []byte("b"), 0644))
+	require.NoError(t, fsys.Mkdir(ctx, "dst", 0755, false))
+
+	_, err = Copy(ctx, fsys, "src", "dst", false)
+	assert.Error(t, err)
+
+	n, err := Copy(ctx, fsys, "src", "dst", true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	data, err := fsys.ReadFile(ctx, "dst/src/sub/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(data))
+
+	n, err = Copy(ctx, fsys, "src/a.txt", "copy.txt", false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestWalk(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	fsys, err := NewLocalFS(root)
+	require.NoError(t, err)
+
+	require.NoError(t, fsys.Mkdir(ctx, "a/b", 0755, true))
+	require.NoError(t, fsys.Mkdir(ctx, "skip", 0755, false))
+	require.NoError(t, fsys.WriteFile(ctx, "a/b/c.txt", []byte("c"), 0644))
+	require.NoError(t, fsys.WriteFile(ctx, "skip/d.txt", []byte("d"), 0644))
+
+	var visited []string
+	err = Walk(ctx, fsys, ".", func(name string, info *types.FileInfo, depth int, err error) error {
+		require.NoError(t, err)
+		visited = append(visited, name)
+		if info.Name == "skip" {
+			return SkipDir
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{".", "./a", "./a/b", "./a/b/c.txt", "./skip"}, visited)
+
+	err = Walk(ctx, fsys, "missing", func(name string, info *types.FileInfo, depth int, err error) error {
+		return err
+	})
+	assert.True(t, IsNotExist(err))
+}
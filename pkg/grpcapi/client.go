@@ -0,0 +1,149 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"google.golang.org/grpc"
+)
+
+// RunShellClient 是 RunShell gRPC 服务的客户端接口
+type RunShellClient interface {
+	Exec(ctx context.Context, opts ...grpc.CallOption) (ExecClient, error)
+	Terminal(ctx context.Context, opts ...grpc.CallOption) (TerminalClient, error)
+	ListCommands(ctx context.Context, req *Empty, opts ...grpc.CallOption) (*CommandList, error)
+	CreateSession(ctx context.Context, req *types.SessionRequest, opts ...grpc.CallOption) (*types.SessionResponse, error)
+	ListSessions(ctx context.Context, req *Empty, opts ...grpc.CallOption) (*SessionList, error)
+	DeleteSession(ctx context.Context, req *SessionID, opts ...grpc.CallOption) (*Empty, error)
+	ListScripts(ctx context.Context, req *Empty, opts ...grpc.CallOption) (*CommandList, error)
+	RunScript(ctx context.Context, req *ScriptRequest, opts ...grpc.CallOption) (*ExecResponse, error)
+}
+
+// ExecClient 是 Exec 的客户端流
+type ExecClient interface {
+	Send(*ExecStreamRequest) error
+	Recv() (*ExecStreamResponse, error)
+	grpc.ClientStream
+}
+
+// TerminalClient 是 Terminal 的客户端流
+type TerminalClient interface {
+	Send(*TerminalRequest) error
+	Recv() (*TerminalResponse, error)
+	grpc.ClientStream
+}
+
+type runShellClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewRunShellClient 创建客户端，所有调用默认使用 JSON 编解码
+func NewRunShellClient(cc grpc.ClientConnInterface) RunShellClient {
+	return &runShellClient{cc: cc}
+}
+
+// callOptions 在调用选项前加上 JSON 编解码
+func callOptions(opts []grpc.CallOption) []grpc.CallOption {
+	return append([]grpc.CallOption{grpc.ForceCodec(Codec{})}, opts...)
+}
+
+// invoke 调用一元方法
+func (c *runShellClient) invoke(ctx context.Context, method string, req, resp interface{}, opts []grpc.CallOption) error {
+	return c.cc.Invoke(ctx, "/"+ServiceName+"/"+method, req, resp, callOptions(opts)...)
+}
+
+func (c *runShellClient) Exec(ctx context.Context, opts ...grpc.CallOption) (ExecClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ServiceDesc.Streams[0], "/"+ServiceName+"/Exec", callOptions(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	return &execClientStream{stream}, nil
+}
+
+func (c *runShellClient) Terminal(ctx context.Context, opts ...grpc.CallOption) (TerminalClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ServiceDesc.Streams[1], "/"+ServiceName+"/Terminal", callOptions(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	return &terminalClientStream{stream}, nil
+}
+
+func (c *runShellClient) ListCommands(ctx context.Context, req *Empty, opts ...grpc.CallOption) (*CommandList, error) {
+	resp := new(CommandList)
+	if err := c.invoke(ctx, "ListCommands", req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *runShellClient) CreateSession(ctx context.Context, req *types.SessionRequest, opts ...grpc.CallOption) (*types.SessionResponse, error) {
+	resp := new(types.SessionResponse)
+	if err := c.invoke(ctx, "CreateSession", req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *runShellClient) ListSessions(ctx context.Context, req *Empty, opts ...grpc.CallOption) (*SessionList, error) {
+	resp := new(SessionList)
+	if err := c.invoke(ctx, "ListSessions", req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *runShellClient) DeleteSession(ctx context.Context, req *SessionID, opts ...grpc.CallOption) (*Empty, error) {
+	resp := new(Empty)
+	if err := c.invoke(ctx, "DeleteSession", req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *runShellClient) ListScripts(ctx context.Context, req *Empty, opts ...grpc.CallOption) (*CommandList, error) {
+	resp := new(CommandList)
+	if err := c.invoke(ctx, "ListScripts", req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *runShellClient) RunScript(ctx context.Context, req *ScriptRequest, opts ...grpc.CallOption) (*ExecResponse, error) {
+	resp := new(ExecResponse)
+	if err := c.invoke(ctx, "RunScript", req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type execClientStream struct {
+	grpc.ClientStream
+}
+
+func (s *execClientStream) Send(m *ExecStreamRequest) error {
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *execClientStream) Recv() (*ExecStreamResponse, error) {
+	m := new(ExecStreamResponse)
+	if err := s.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type terminalClientStream struct {
+	grpc.ClientStream
+}
+
+func (s *terminalClientStream) Send(m *TerminalRequest) error {
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *terminalClientStream) Recv() (*TerminalResponse, error) {
+	m := new(TerminalResponse)
+	if err := s.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,32 @@
+// Package grpcapi 定义了 RunShell 的 gRPC 服务。
+//
+// 服务没有使用 protobuf 生成代码，消息为普通的 Go 结构体，以 JSON 编码传输。
+// Codec 不会注册为全局编解码器，以免影响同一进程中的其他 gRPC 服务：
+// 服务端使用 grpc.ForceServerCodec(Codec{})，客户端使用 grpc.ForceCodec(Codec{})，
+// NewRunShellClient 创建的客户端会自动设置。
+package grpcapi
+
+import (
+	"encoding/json"
+)
+
+// CodecName 编解码器名称，对应 content-type "application/grpc+json"
+const CodecName = "json"
+
+// Codec 使用 JSON 编解码 gRPC 消息
+type Codec struct{}
+
+// Marshal 实现 encoding.Codec 接口
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal 实现 encoding.Codec 接口
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Name 实现 encoding.Codec 接口
+func (Codec) Name() string {
+	return CodecName
+}
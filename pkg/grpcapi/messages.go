@@ -0,0 +1,101 @@
+package grpcapi
+
+import (
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// Empty 表示空消息
+type Empty struct{}
+
+// ExecRequest 表示执行命令的请求
+type ExecRequest struct {
+	Command   string              `json:"command"`              // 要执行的命令
+	Args      []string            `json:"args,omitempty"`       // 命令参数
+	WorkDir   string              `json:"workdir,omitempty"`    // 工作目录
+	Env       map[string]string   `json:"env,omitempty"`        // 环境变量，在会话中执行时使用会话的环境变量
+	SessionID string              `json:"session_id,omitempty"` // 在指定会话中执行
+	Track     *types.TrackOptions `json:"track,omitempty"`      // 文件变更跟踪选项
+	Timeout   int64               `json:"timeout,omitempty"`    // 超时时间（纳秒）
+
+	// Stdin 为 true 时客户端会通过后续消息发送标准输入，
+	// 为 false 时命令没有标准输入
+	Stdin bool `json:"stdin,omitempty"`
+}
+
+// ExecResponse 表示命令的执行结果
+type ExecResponse struct {
+	ExitCode int                `json:"exit_code"`
+	Output   string             `json:"output,omitempty"` // 命令输出，流式执行时输出通过数据块返回，此处为空
+	Error    string             `json:"error,omitempty"`
+	Changes  []types.FileChange `json:"changes,omitempty"`
+	Data     interface{}        `json:"data,omitempty"`
+}
+
+// ExecStreamRequest 是 Exec 流的客户端消息。
+// 第一条消息必须包含 Start，之后的消息携带标准输入数据块；
+// CloseStdin 为 true 或客户端关闭发送方向时关闭标准输入。
+type ExecStreamRequest struct {
+	Start      *ExecRequest `json:"start,omitempty"`
+	Stdin      []byte       `json:"stdin,omitempty"`
+	CloseStdin bool         `json:"close_stdin,omitempty"`
+}
+
+// ExecStreamResponse 是 Exec 流的服务端消息。
+// 执行过程中返回标准输出和标准错误的数据块，最后一条消息包含 Result。
+type ExecStreamResponse struct {
+	Stdout []byte        `json:"stdout,omitempty"`
+	Stderr []byte        `json:"stderr,omitempty"`
+	Result *ExecResponse `json:"result,omitempty"`
+}
+
+// TerminalSize 表示终端大小
+type TerminalSize struct {
+	Rows uint16 `json:"rows"`
+	Cols uint16 `json:"cols"`
+}
+
+// TerminalStart 表示启动交互式终端的参数
+type TerminalStart struct {
+	Command string            `json:"command,omitempty"` // 要执行的命令，默认为 bash
+	Args    []string          `json:"args,omitempty"`
+	WorkDir string            `json:"workdir,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Term    string            `json:"term,omitempty"` // 终端类型，默认为 xterm-256color
+	Size    *TerminalSize     `json:"size,omitempty"` // 初始终端大小
+}
+
+// TerminalRequest 是 Terminal 流的客户端消息。
+// 第一条消息必须包含 Start，之后的消息携带输入数据或终端大小调整。
+type TerminalRequest struct {
+	Start  *TerminalStart `json:"start,omitempty"`
+	Input  []byte         `json:"input,omitempty"`
+	Resize *TerminalSize  `json:"resize,omitempty"`
+}
+
+// TerminalResponse 是 Terminal 流的服务端消息，最后一条消息包含 Exit
+type TerminalResponse struct {
+	Output []byte        `json:"output,omitempty"`
+	Exit   *ExecResponse `json:"exit,omitempty"`
+}
+
+// CommandList 表示命令列表
+type CommandList struct {
+	Commands []types.CommandInfo `json:"commands"`
+}
+
+// SessionList 表示会话列表
+type SessionList struct {
+	Sessions []*types.Session `json:"sessions"`
+}
+
+// SessionID 表示会话 ID
+type SessionID struct {
+	ID string `json:"id"`
+}
+
+// ScriptRequest 表示执行脚本的请求
+type ScriptRequest struct {
+	Name    string   `json:"name"`
+	Args    []string `json:"args,omitempty"`
+	WorkDir string   `json:"workdir,omitempty"`
+}
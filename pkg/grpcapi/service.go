@@ -0,0 +1,150 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"google.golang.org/grpc"
+)
+
+// ServiceName gRPC 服务名
+const ServiceName = "runshell.v1.RunShell"
+
+// RunShellServer 是 RunShell gRPC 服务的服务端接口
+type RunShellServer interface {
+	// Exec 双向流式执行命令：接收 ExecStreamRequest，返回输出数据块和最终结果
+	Exec(stream ExecStream) error
+
+	// Terminal 双向流式交互终端：接收输入和终端大小调整，返回终端输出和退出结果
+	Terminal(stream TerminalStream) error
+
+	// ListCommands 列出可用命令
+	ListCommands(ctx context.Context, req *Empty) (*CommandList, error)
+
+	// CreateSession 创建会话
+	CreateSession(ctx context.Context, req *types.SessionRequest) (*types.SessionResponse, error)
+
+	// ListSessions 列出会话
+	ListSessions(ctx context.Context, req *Empty) (*SessionList, error)
+
+	// DeleteSession 删除会话
+	DeleteSession(ctx context.Context, req *SessionID) (*Empty, error)
+
+	// ListScripts 列出脚本
+	ListScripts(ctx context.Context, req *Empty) (*CommandList, error)
+
+	// RunScript 执行脚本
+	RunScript(ctx context.Context, req *ScriptRequest) (*ExecResponse, error)
+}
+
+// ExecStream 是 Exec 的服务端流
+type ExecStream interface {
+	Send(*ExecStreamResponse) error
+	Recv() (*ExecStreamRequest, error)
+	grpc.ServerStream
+}
+
+// TerminalStream 是 Terminal 的服务端流
+type TerminalStream interface {
+	Send(*TerminalResponse) error
+	Recv() (*TerminalRequest, error)
+	grpc.ServerStream
+}
+
+// RegisterRunShellServer 将服务实现注册到 gRPC 服务器
+func RegisterRunShellServer(s grpc.ServiceRegistrar, srv RunShellServer) {
+	s.RegisterService(&ServiceDesc, srv)
+}
+
+// ServiceDesc 是 RunShell 服务的描述
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*RunShellServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "ListCommands", Handler: unaryHandler("ListCommands", func(srv RunShellServer, ctx context.Context, req *Empty) (interface{}, error) {
+			return srv.ListCommands(ctx, req)
+		})},
+		{MethodName: "CreateSession", Handler: unaryHandler("CreateSession", func(srv RunShellServer, ctx context.Context, req *types.SessionRequest) (interface{}, error) {
+			return srv.CreateSession(ctx, req)
+		})},
+		{MethodName: "ListSessions", Handler: unaryHandler("ListSessions", func(srv RunShellServer, ctx context.Context, req *Empty) (interface{}, error) {
+			return srv.ListSessions(ctx, req)
+		})},
+		{MethodName: "DeleteSession", Handler: unaryHandler("DeleteSession", func(srv RunShellServer, ctx context.Context, req *SessionID) (interface{}, error) {
+			return srv.DeleteSession(ctx, req)
+		})},
+		{MethodName: "ListScripts", Handler: unaryHandler("ListScripts", func(srv RunShellServer, ctx context.Context, req *Empty) (interface{}, error) {
+			return srv.ListScripts(ctx, req)
+		})},
+		{MethodName: "RunScript", Handler: unaryHandler("RunScript", func(srv RunShellServer, ctx context.Context, req *ScriptRequest) (interface{}, error) {
+			return srv.RunScript(ctx, req)
+		})},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Exec",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(RunShellServer).Exec(&execServerStream{stream})
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName: "Terminal",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(RunShellServer).Terminal(&terminalServerStream{stream})
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+// unaryHandler 创建一元方法的处理函数，负责解码请求并接入拦截器
+func unaryHandler[Req any](method string, call func(RunShellServer, context.Context, *Req) (interface{}, error)) grpc.MethodHandler {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := new(Req)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(RunShellServer), ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/" + method}
+		return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(srv.(RunShellServer), ctx, req.(*Req))
+		})
+	}
+}
+
+type execServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *execServerStream) Send(m *ExecStreamResponse) error {
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *execServerStream) Recv() (*ExecStreamRequest, error) {
+	m := new(ExecStreamRequest)
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type terminalServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *terminalServerStream) Send(m *TerminalResponse) error {
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *terminalServerStream) Recv() (*TerminalRequest, error) {
+	m := new(TerminalRequest)
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -61,8 +61,8 @@ var (
 	errorLogger *log.Logger
 
 	// default writers
-	defaultStdout = os.Stdout
-	defaultStderr = os.Stderr
+	defaultStdout io.Writer = os.Stdout
+	defaultStderr           = os.Stderr
 
 	// custom writer for additional output (e.g., file)
 	customWriter io.Writer
@@ -133,6 +133,13 @@ func SetWriter(w io.Writer) {
 	setupLoggers()
 }
 
+// SetConsoleWriter redirects debug and info output from stdout to w.
+// This is needed when stdout carries a protocol stream, e.g. MCP over stdio.
+func SetConsoleWriter(w io.Writer) {
+	defaultStdout = w
+	setupLoggers()
+}
+
 // Debug prints a debug message if RUNSHELL_DEBUG environment variable is set.
 // The message format follows fmt.Printf conventions.
 func Debug(format string, args ...interface{}) {
@@ -0,0 +1,363 @@
+// Package mcp 实现了 Model Context Protocol（MCP）服务端。
+// 协议基于 JSON-RPC 2.0，支持 stdio 和 streamable HTTP 两种传输方式，
+// 具体的工具和资源由调用方注册。
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/iamlongalong/runshell/pkg/log"
+)
+
+// ProtocolVersion 服务端支持的最新 MCP 协议版本
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions 兼容的协议版本，客户端请求其中之一时按客户端版本响应
+var supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// JSON-RPC 错误码
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+
+	// CodeResourceNotFound MCP 约定的资源不存在错误码
+	CodeResourceNotFound = -32002
+)
+
+// Request 表示 JSON-RPC 请求或通知，通知没有 ID
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// IsNotification 判断请求是否为通知
+func (r *Request) IsNotification() bool {
+	return len(r.ID) == 0
+}
+
+// Response 表示 JSON-RPC 响应
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error 表示 JSON-RPC 错误
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// Content 表示工具结果中的一段内容
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// TextContent 创建文本内容
+func TextContent(text string) Content {
+	return Content{Type: "text", Text: text}
+}
+
+// ToolResult 表示工具调用的结果。
+// 工具执行失败时 IsError 为 true，错误信息放在 Content 中返回给模型。
+type ToolResult struct {
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
+}
+
+// ToolHandler 处理工具调用，返回的错误会转换为 IsError 的工具结果
+type ToolHandler func(ctx context.Context, args map[string]interface{}) (*ToolResult, error)
+
+// Tool 表示一个 MCP 工具
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	Handler     ToolHandler            `json:"-"`
+}
+
+// Resource 表示一个可读取的资源
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate 表示一类资源的 URI 模板
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents 表示资源内容，文本放在 Text 中，二进制内容以 base64 放在 Blob 中
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// ResourceProvider 提供资源的列举和读取
+type ResourceProvider interface {
+	// ListResources 列出当前可读取的资源
+	ListResources(ctx context.Context) ([]Resource, error)
+
+	// ResourceTemplates 返回资源的 URI 模板
+	ResourceTemplates() []ResourceTemplate
+
+	// ReadResource 读取资源内容
+	ReadResource(ctx context.Context, uri string) ([]ResourceContents, error)
+}
+
+// Server 表示 MCP 服务端
+type Server struct {
+	name      string
+	version   string
+	tools     map[string]*Tool
+	resources ResourceProvider
+
+	sessions sync.Map // HTTP 传输的会话 ID
+}
+
+// NewServer 创建 MCP 服务端
+func NewServer(name, version string) *Server {
+	return &Server{
+		name:    name,
+		version: version,
+		tools:   make(map[string]*Tool),
+	}
+}
+
+// AddTool 注册工具，同名工具会被覆盖
+func (s *Server) AddTool(tool *Tool) *Server {
+	s.tools[tool.Name] = tool
+	return s
+}
+
+// WithResources 设置资源提供者
+func (s *Server) WithResources(provider ResourceProvider) *Server {
+	s.resources = provider
+	return s
+}
+
+// Tools 返回按名称排序的工具列表
+func (s *Server) Tools() []*Tool {
+	tools := make([]*Tool, 0, len(s.tools))
+	for _, tool := range s.tools {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].Name < tools[j].Name
+	})
+	return tools
+}
+
+// Handle 处理一条 JSON-RPC 消息，返回响应消息；通知没有响应，返回 nil
+func (s *Server) Handle(ctx context.Context, data []byte) []byte {
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return s.encode(&Response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: CodeParseError, Message: err.Error()}})
+	}
+	resp := s.handleRequest(ctx, &req)
+	if resp == nil {
+		return nil
+	}
+	return s.encode(resp)
+}
+
+// encode 序列化响应
+func (s *Server) encode(resp *Response) []byte {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		log.Error("Failed to encode MCP response: %v", err)
+		data, _ = json.Marshal(&Response{JSONRPC: "2.0", ID: resp.ID, Error: &Error{Code: CodeInternalError, Message: err.Error()}})
+	}
+	return data
+}
+
+// handleRequest 分发请求，通知返回 nil
+func (s *Server) handleRequest(ctx context.Context, req *Request) *Response {
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if req.IsNotification() {
+			return nil
+		}
+		return &Response{JSONRPC: "2.0", ID: req.ID, Error: &Error{Code: CodeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}}
+	}
+
+	log.Debug("MCP request: %s", req.Method)
+	result, err := s.dispatch(ctx, req)
+	if req.IsNotification() {
+		if err != nil {
+			log.Error("MCP notification %s failed: %v", req.Method, err)
+		}
+		return nil
+	}
+
+	resp := &Response{JSONRPC: "2.0", ID: req.ID}
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+// dispatch 根据方法名调用对应的处理函数
+func (s *Server) dispatch(ctx context.Context, req *Request) (interface{}, error) {
+	switch req.Method {
+	case "initialize":
+		return s.initialize(req.Params)
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": s.Tools()}, nil
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	case "resources/list":
+		return s.listResources(ctx)
+	case "resources/templates/list":
+		return s.listResourceTemplates()
+	case "resources/read":
+		return s.readResource(ctx, req.Params)
+	default:
+		if strings.HasPrefix(req.Method, "notifications/") {
+			return nil, nil
+		}
+		return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+// initialize 处理握手请求，协商协议版本并声明服务端能力
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+		}
+	}
+
+	capabilities := map[string]interface{}{
+		"tools": map[string]interface{}{"listChanged": false},
+	}
+	if s.resources != nil {
+		capabilities["resources"] = map[string]interface{}{"listChanged": false, "subscribe": false}
+	}
+
+	version := ProtocolVersion
+	for _, v := range supportedVersions {
+		if v == p.ProtocolVersion {
+			version = v
+		}
+	}
+
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities":    capabilities,
+		"serverInfo": map[string]interface{}{
+			"name":    s.name,
+			"version": s.version,
+		},
+	}, nil
+}
+
+// callTool 调用工具
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	tool, ok := s.tools[p.Name]
+	if !ok {
+		return nil, &Error{Code: CodeInvalidParams, Message: "unknown tool: " + p.Name}
+	}
+
+	args := make(map[string]interface{})
+	if len(p.Arguments) > 0 && string(p.Arguments) != "null" {
+		dec := json.NewDecoder(strings.NewReader(string(p.Arguments)))
+		dec.UseNumber()
+		if err := dec.Decode(&args); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: "invalid tool arguments: " + err.Error()}
+		}
+	}
+
+	result, err := tool.Handler(ctx, args)
+	if err != nil {
+		return &ToolResult{Content: []Content{TextContent(err.Error())}, IsError: true}, nil
+	}
+	if result == nil {
+		result = &ToolResult{}
+	}
+	if result.Content == nil {
+		result.Content = []Content{}
+	}
+	return result, nil
+}
+
+// listResources 列出资源
+func (s *Server) listResources(ctx context.Context) (interface{}, error) {
+	resources := []Resource{}
+	if s.resources != nil {
+		list, err := s.resources.ListResources(ctx)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, list...)
+	}
+	return map[string]interface{}{"resources": resources}, nil
+}
+
+// listResourceTemplates 列出资源模板
+func (s *Server) listResourceTemplates() (interface{}, error) {
+	templates := []ResourceTemplate{}
+	if s.resources != nil {
+		templates = append(templates, s.resources.ResourceTemplates()...)
+	}
+	return map[string]interface{}{"resourceTemplates": templates}, nil
+}
+
+// readResource 读取资源
+func (s *Server) readResource(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.URI == "" {
+		return nil, &Error{Code: CodeInvalidParams, Message: "resource uri is required"}
+	}
+	if s.resources == nil {
+		return nil, &Error{Code: CodeResourceNotFound, Message: "resource not found: " + p.URI}
+	}
+	contents, err := s.resources.ReadResource(ctx, p.URI)
+	if err != nil {
+		return nil, &Error{Code: CodeResourceNotFound, Message: err.Error()}
+	}
+	return map[string]interface{}{"contents": contents}, nil
+}
@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testResources struct{}
+
+func (testResources) ListResources(ctx context.Context) ([]Resource, error) {
+	return []Resource{{URI: "test://a", Name: "a"}}, nil
+}
+
+func (testResources) ResourceTemplates() []ResourceTemplate {
+	return []ResourceTemplate{{URITemplate: "test://{name}", Name: "test"}}
+}
+
+func (testResources) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	if uri != "test://a" {
+		return nil, fmt.Errorf("resource not found: %s", uri)
+	}
+	return []ResourceContents{{URI: uri, Text: "content"}}, nil
+}
+
+func newTestServer() *Server {
+	return NewServer("test", "0.1").
+		WithResources(testResources{}).
+		AddTool(&Tool{
+			Name:        "echo",
+			InputSchema: map[string]interface{}{"type": "object"},
+			Handler: func(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+				if args["fail"] == true {
+					return nil, fmt.Errorf("failed")
+				}
+				return &ToolResult{Content: []Content{TextContent(fmt.Sprint(args["text"]))}}, nil
+			},
+		})
+}
+
+// call 发送请求并解析响应
+func call(t *testing.T, s *Server, method string, params interface{}) Response {
+	data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	require.NoError(t, err)
+	var resp Response
+	require.NoError(t, json.Unmarshal(s.Handle(context.Background(), data), &resp))
+	return resp
+}
+
+func TestHandle(t *testing.T) {
+	s := newTestServer()
+
+	resp := call(t, s, "initialize", map[string]interface{}{"protocolVersion": "2024-11-05"})
+	require.Nil(t, resp.Error)
+	result := resp.Result.(map[string]interface{})
+	assert.Equal(t, "2024-11-05", result["protocolVersion"])
+	assert.Contains(t, result["capabilities"], "resources")
+
+	resp = call(t, s, "initialize", map[string]interface{}{"protocolVersion": "1999-01-01"})
+	assert.Equal(t, ProtocolVersion, resp.Result.(map[string]interface{})["protocolVersion"])
+
+	resp = call(t, s, "tools/list", nil)
+	tools := resp.Result.(map[string]interface{})["tools"].([]interface{})
+	require.Len(t, tools, 1)
+	assert.Equal(t, "echo", tools[0].(map[string]interface{})["name"])
+
+	resp = call(t, s, "tools/call", map[string]interface{}{"name": "echo", "arguments": map[string]interface{}{"text": "hi"}})
+	require.Nil(t, resp.Error)
+	assert.Equal(t, "hi", resp.Result.(map[string]interface{})["content"].([]interface{})[0].(map[string]interface{})["text"])
+
+	resp = call(t, s, "tools/call", map[string]interface{}{"name": "echo", "arguments": map[string]interface{}{"fail": true}})
+	require.Nil(t, resp.Error)
+	assert.Equal(t, true, resp.Result.(map[string]interface{})["isError"])
+
+	resp = call(t, s, "tools/call", map[string]interface{}{"name": "missing"})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, CodeInvalidParams, resp.Error.Code)
+
+	resp = call(t, s, "resources/read", map[string]interface{}{"uri": "test://a"})
+	require.Nil(t, resp.Error)
+	assert.Equal(t, "content", resp.Result.(map[string]interface{})["contents"].([]interface{})[0].(map[string]interface{})["text"])
+
+	resp = call(t, s, "resources/read", map[string]interface{}{"uri": "test://b"})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, CodeResourceNotFound, resp.Error.Code)
+
+	resp = call(t, s, "unknown/method", nil)
+	assert.Equal(t, CodeMethodNotFound, resp.Error.Code)
+
+	assert.Nil(t, s.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)))
+
+	var parseErr Response
+	require.NoError(t, json.Unmarshal(s.Handle(context.Background(), []byte(`{bad`)), &parseErr))
+	assert.Equal(t, CodeParseError, parseErr.Error.Code)
+}
+
+func TestServeStdio(t *testing.T) {
+	s := newTestServer()
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"ping"}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		``,
+		`{"jsonrpc":"2.0","id":"b","method":"tools/call","params":{"name":"echo","arguments":{"text":"x"}}}`,
+	}, "\n")
+
+	var out bytes.Buffer
+	require.NoError(t, s.ServeStdio(context.Background(), strings.NewReader(input), &out))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{}}`, lines[0])
+	assert.Contains(t, lines[1], `"id":"b"`)
+}
+
+func TestServeHTTP(t *testing.T) {
+	s := newTestServer()
+	post := func(body, session string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if session != "" {
+			req.Header.Set(SessionHeader, session)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	session := w.Header().Get(SessionHeader)
+	require.NotEmpty(t, session)
+
+	w = post(`{"jsonrpc":"2.0","method":"notifications/initialized"}`, session)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w = post(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`, session)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"echo"`)
+
+	w = post(`{"jsonrpc":"2.0","id":3,"method":"ping"}`, "unknown")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = post(`not json`, session)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/mcp", nil)
+	req.Header.Set(SessionHeader, session)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = post(`{"jsonrpc":"2.0","id":4,"method":"ping"}`, session)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,114 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// SessionHeader streamable HTTP 传输中携带会话 ID 的请求头
+const SessionHeader = "Mcp-Session-Id"
+
+// maxMessageSize 单条消息的最大长度
+const maxMessageSize = 16 << 20
+
+// ServeStdio 通过标准输入输出提供服务，每行一条 JSON-RPC 消息，
+// 读到 EOF 或 ctx 取消时返回
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		resp := s.Handle(ctx, line)
+		if resp == nil {
+			continue
+		}
+		if _, err := w.Write(append(resp, '\n')); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+	return nil
+}
+
+// ServeHTTP 实现 streamable HTTP 传输。
+// POST 提交一条 JSON-RPC 消息，请求以 JSON 响应返回，通知返回 202；
+// initialize 的响应头中返回会话 ID，之后的请求携带该 ID，DELETE 结束会话。
+// 服务端不主动推送消息，因此 GET 返回 405。
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.Header.Get(SessionHeader)
+
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		if _, ok := s.sessions.LoadAndDelete(sessionID); !ok {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, s.Handle(r.Context(), body))
+		return
+	}
+
+	if req.Method == "initialize" {
+		sessionID = newSessionID()
+		s.sessions.Store(sessionID, struct{}{})
+		w.Header().Set(SessionHeader, sessionID)
+	} else if sessionID != "" {
+		if _, ok := s.sessions.Load(sessionID); !ok {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	resp := s.Handle(r.Context(), body)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeJSON 写入 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// newSessionID 生成随机的会话 ID
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
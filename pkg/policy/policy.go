@@ -0,0 +1,106 @@
+// Package policy 实现了命令执行策略。
+// 策略用于判断命令是否允许执行，以及命令是否具有破坏性。
+package policy
+
+import (
+	"path/filepath"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// Decision 表示策略对一条命令的判定结果
+type Decision struct {
+	Allowed     bool   `json:"allowed"`          // 是否允许执行
+	Destructive bool   `json:"destructive"`      // 是否为破坏性命令
+	Reason      string `json:"reason,omitempty"` // 判定原因
+}
+
+// DeniedError 表示命令被执行策略拒绝
+type DeniedError struct {
+	Reason string // 拒绝原因
+}
+
+func (e *DeniedError) Error() string {
+	return e.Reason
+}
+
+// Policy 定义了命令执行策略的接口
+type Policy interface {
+	// Evaluate 对命令进行判定
+	Evaluate(cmd types.Command) Decision
+}
+
+// Rule 表示一条命令匹配规则。
+// Command 匹配命令名称（忽略路径），Args 中的每一项都必须出现在命令参数中。
+type Rule struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Match 判断命令是否匹配规则
+func (r Rule) Match(cmd types.Command) bool {
+	if r.Command != "*" && filepath.Base(cmd.Command) != r.Command {
+		return false
+	}
+	for _, want := range r.Args {
+		found := false
+		for _, arg := range cmd.Args {
+			if arg == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// DefaultDestructiveRules 默认的破坏性命令规则
+var DefaultDestructiveRules = []Rule{
+	{Command: "rm"},
+	{Command: "rmdir"},
+	{Command: "mv"},
+	{Command: "dd"},
+	{Command: "truncate"},
+	{Command: "shred"},
+	{Command: "sed", Args: []string{"-i"}},
+	{Command: "find", Args: []string{"-delete"}},
+	{Command: "git", Args: []string{"reset"}},
+	{Command: "git", Args: []string{"clean"}},
+	{Command: "git", Args: []string{"checkout"}},
+	{Command: "git", Args: []string{"restore"}},
+}
+
+// RulePolicy 是基于规则列表的策略实现
+type RulePolicy struct {
+	Deny        []Rule `json:"deny,omitempty"`        // 禁止执行的命令
+	Destructive []Rule `json:"destructive,omitempty"` // 破坏性命令
+}
+
+// NewDefaultPolicy 创建默认策略：允许所有命令，并标记常见的破坏性命令
+func NewDefaultPolicy() *RulePolicy {
+	return &RulePolicy{
+		Destructive: DefaultDestructiveRules,
+	}
+}
+
+// Evaluate 实现 Policy 接口
+func (p *RulePolicy) Evaluate(cmd types.Command) Decision {
+	for _, rule := range p.Deny {
+		if rule.Match(cmd) {
+			return Decision{Allowed: false, Reason: "command denied by policy: " + cmd.Command}
+		}
+	}
+
+	decision := Decision{Allowed: true}
+	for _, rule := range p.Destructive {
+		if rule.Match(cmd) {
+			decision.Destructive = true
+			decision.Reason = "destructive command: " + cmd.Command
+			break
+		}
+	}
+	return decision
+}
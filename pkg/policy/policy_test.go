@@ -0,0 +1,37 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRulePolicy(t *testing.T) {
+	p := NewDefaultPolicy()
+	p.Deny = []Rule{{Command: "shutdown"}}
+
+	tests := []struct {
+		name        string
+		cmd         types.Command
+		allowed     bool
+		destructive bool
+	}{
+		{"plain command", types.Command{Command: "ls", Args: []string{"-l"}}, true, false},
+		{"rm", types.Command{Command: "rm", Args: []string{"-rf", "build"}}, true, true},
+		{"rm with path", types.Command{Command: "/bin/rm", Args: []string{"a"}}, true, true},
+		{"git status", types.Command{Command: "git", Args: []string{"status"}}, true, false},
+		{"git reset", types.Command{Command: "git", Args: []string{"reset", "--hard"}}, true, true},
+		{"sed in place", types.Command{Command: "sed", Args: []string{"-i", "s/a/b/", "f"}}, true, true},
+		{"sed to stdout", types.Command{Command: "sed", Args: []string{"s/a/b/", "f"}}, true, false},
+		{"denied", types.Command{Command: "shutdown", Args: []string{"-h", "now"}}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := p.Evaluate(tt.cmd)
+			assert.Equal(t, tt.allowed, d.Allowed)
+			assert.Equal(t, tt.destructive, d.Destructive)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	base := time.Date(2024, 1, 31, 10, 17, 30, 0, time.UTC) // 星期三
+
+	tests := []struct {
+		expr string
+		next []string
+	}{
+		{expr: "* * * * *", next: []string{"2024-01-31 10:18", "2024-01-31 10:19"}},
+		{expr: "*/15 * * * *", next: []string{"2024-01-31 10:30", "2024-01-31 10:45", "2024-01-31 11:00"}},
+		{expr: "0 3 * * *", next: []string{"2024-02-01 03:00", "2024-02-02 03:00"}},
+		{expr: "5,10-12 9 * * *", next: []string{"2024-02-01 09:05", "2024-02-01 09:10", "2024-02-01 09:11", "2024-02-01 09:12"}},
+		{expr: "0 0 29 feb *", next: []string{"2024-02-29 00:00", "2028-02-29 00:00"}},
+		{expr: "30 8 * * mon-fri", next: []string{"2024-02-01 08:30", "2024-02-02 08:30", "2024-02-05 08:30"}},
+		{expr: "0 12 * * 7", next: []string{"2024-02-04 12:00"}},
+		// 日和星期都指定时满足其一即可
+		{expr: "0 0 1 * 5", next: []string{"2024-02-01 00:00", "2024-02-02 00:00", "2024-02-09 00:00"}},
+		{expr: "0 20/2 * * *", next: []string{"2024-01-31 20:00", "2024-01-31 22:00", "2024-02-01 20:00"}},
+		{expr: "@hourly", next: []string{"2024-01-31 11:00"}},
+		{expr: "@monthly", next: []string{"2024-02-01 00:00", "2024-03-01 00:00"}},
+		{expr: "@weekly", next: []string{"2024-02-04 00:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			spec, err := Parse(tt.expr, time.UTC)
+			require.NoError(t, err)
+			next := base
+			for _, want := range tt.next {
+				next = spec.Next(next)
+				assert.Equal(t, want, next.Format("2006-01-02 15:04"))
+			}
+		})
+	}
+
+	t.Run("every", func(t *testing.T) {
+		spec, err := Parse("@every 90s", nil)
+		require.NoError(t, err)
+		assert.Equal(t, base.Add(90*time.Second), spec.Next(base))
+	})
+
+	t.Run("timezone", func(t *testing.T) {
+		loc := time.FixedZone("UTC+8", 8*3600)
+		spec, err := Parse("0 9 * * *", loc)
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 2, 1, 1, 0, 0, 0, time.UTC), spec.Next(base).UTC())
+	})
+
+	t.Run("never", func(t *testing.T) {
+		spec, err := Parse("0 0 30 2 *", time.UTC)
+		require.NoError(t, err)
+		assert.True(t, spec.Next(base).IsZero())
+	})
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@often",
+		"@every soon",
+		"@every 10ms",
+	} {
+		_, err := Parse(expr, time.UTC)
+		assert.Error(t, err, expr)
+	}
+}
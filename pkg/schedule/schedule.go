@@ -0,0 +1,188 @@
+// Package schedule 实现了按 cron 表达式定时执行命令、脚本或工作流的计划任务。
+//
+// 每个计划任务（Schedule）包含触发时间、执行目标和重叠策略：上一次运行尚未结束时，
+// 新的运行可以被跳过（skip）、排队等待（queue）或终止上一次运行后立即开始（kill）。
+// 计划任务的定义和每次运行的状态、输出通过 Store 保存，使用 FileStore 时重启后自动恢复。
+//
+// 示例：
+//
+//	scheduler := schedule.NewScheduler(store, dispatcher)
+//	scheduler.Start()
+//	defer scheduler.Stop()
+//
+//	scheduler.Create(&schedule.Schedule{
+//		Name:   "maintenance",
+//		Cron:   "0 3 * * *",
+//		Jitter: workflow.Duration(5 * time.Minute),
+//		Target: schedule.Target{Script: "system_maintenance", Args: []string{"--check-disk"}},
+//	})
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/workflow"
+)
+
+// 重叠策略
+const (
+	OverlapSkip  = "skip"  // 上一次运行尚未结束时跳过本次运行
+	OverlapQueue = "queue" // 上一次运行结束后再执行
+	OverlapKill  = "kill"  // 终止上一次运行后立即执行
+)
+
+// 运行状态
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = workflow.StatusRunning
+	StatusSucceeded = workflow.StatusSucceeded
+	StatusFailed    = workflow.StatusFailed
+	StatusSkipped   = workflow.StatusSkipped
+	StatusCanceled  = workflow.StatusCanceled
+)
+
+// 触发方式
+const (
+	TriggerSchedule = "schedule" // 按计划触发
+	TriggerManual   = "manual"   // 手动触发
+)
+
+var (
+	// ErrScheduleNotFound 表示计划任务不存在
+	ErrScheduleNotFound = errors.New("schedule not found")
+	// ErrScheduleExists 表示计划任务 ID 已存在
+	ErrScheduleExists = errors.New("schedule already exists")
+	// ErrRunNotFound 表示运行记录不存在
+	ErrRunNotFound = errors.New("schedule run not found")
+)
+
+// Schedule 表示一个计划任务
+// swagger:model
+type Schedule struct {
+	ID       string            `json:"id"`                 // 计划任务 ID，创建时为空则自动生成
+	Name     string            `json:"name,omitempty"`     // 名称
+	Cron     string            `json:"cron"`               // cron 表达式，如 "0 3 * * *"、"@hourly"、"@every 10m"
+	Timezone string            `json:"timezone,omitempty"` // cron 表达式使用的时区，如 "Asia/Shanghai"，默认为服务器时区
+	Target   Target            `json:"target"`             // 执行目标
+	Overlap  string            `json:"overlap,omitempty"`  // 重叠策略：skip（默认）、queue 或 kill
+	Jitter   workflow.Duration `json:"jitter,omitempty"`   // 每次触发前随机等待的最长时间，用于错开同时触发的任务
+	Timeout  workflow.Duration `json:"timeout,omitempty"`  // 每次运行的超时时间
+	Paused   bool              `json:"paused,omitempty"`   // 暂停后不再按计划触发，仍可手动触发
+
+	CreatedAt   time.Time  `json:"created_at"`              // 创建时间
+	UpdatedAt   time.Time  `json:"updated_at"`              // 更新时间
+	LastRunTime *time.Time `json:"last_run_time,omitempty"` // 最近一次运行的开始时间
+	LastStatus  string     `json:"last_status,omitempty"`   // 最近一次运行的状态
+	NextRunTime *time.Time `json:"next_run_time,omitempty"` // 下一次按计划触发的时间（不含随机等待），暂停时为空
+}
+
+// Target 表示计划任务的执行目标，Command、Script 和 Workflow 必须且只能指定一个
+// swagger:model
+type Target struct {
+	Command  string             `json:"command,omitempty"`  // 按 shell 语法执行的命令
+	Script   string             `json:"script,omitempty"`   // 脚本目录中的脚本名称
+	Args     []string           `json:"args,omitempty"`     // 命令或脚本的参数
+	Workflow *workflow.Workflow `json:"workflow,omitempty"` // 工作流定义
+
+	ExecutorType string            `json:"executor_type,omitempty"` // 执行器类型，与创建会话时相同，默认使用服务器的执行器
+	Remote       string            `json:"remote,omitempty"`        // 远程服务端或 SSH 主机的名称
+	WorkDir      string            `json:"workdir,omitempty"`       // 工作目录
+	Env          map[string]string `json:"env,omitempty"`           // 环境变量
+}
+
+// Run 表示计划任务的一次运行
+// swagger:model
+type Run struct {
+	ID            string    `json:"id"`               // 运行 ID
+	ScheduleID    string    `json:"schedule_id"`      // 计划任务 ID
+	Trigger       string    `json:"trigger"`          // 触发方式：schedule 或 manual
+	Status        string    `json:"status"`           // 运行状态
+	ScheduledTime time.Time `json:"scheduled_time"`   // 计划触发时间
+	StartTime     time.Time `json:"start_time"`       // 开始时间，排队中为零值
+	EndTime       time.Time `json:"end_time"`         // 结束时间，运行中为零值
+	ExitCode      int       `json:"exit_code"`        // 退出码
+	Output        string    `json:"output,omitempty"` // 合并的标准输出和标准错误，超过 64KB 时只保留末尾部分
+	Error         string    `json:"error,omitempty"`  // 错误信息
+}
+
+// Copy 返回计划任务的深拷贝
+func (s *Schedule) Copy() *Schedule {
+	c := *s
+	c.Target.Args = append([]string(nil), s.Target.Args...)
+	if s.Target.Env != nil {
+		c.Target.Env = make(map[string]string, len(s.Target.Env))
+		for k, v := range s.Target.Env {
+			c.Target.Env[k] = v
+		}
+	}
+	if s.LastRunTime != nil {
+		t := *s.LastRunTime
+		c.LastRunTime = &t
+	}
+	if s.NextRunTime != nil {
+		t := *s.NextRunTime
+		c.NextRunTime = &t
+	}
+	return &c
+}
+
+// Copy 返回运行记录的拷贝
+func (r *Run) Copy() *Run {
+	c := *r
+	return &c
+}
+
+// Validate 校验计划任务的定义
+func (s *Schedule) Validate() error {
+	if s.ID != "" && validateID(s.ID) != nil {
+		return fmt.Errorf("invalid schedule id %q", s.ID)
+	}
+	if _, err := s.spec(); err != nil {
+		return err
+	}
+	switch s.Overlap {
+	case "", OverlapSkip, OverlapQueue, OverlapKill:
+	default:
+		return fmt.Errorf("invalid overlap policy %q, must be one of skip, queue and kill", s.Overlap)
+	}
+	if s.Jitter < 0 {
+		return fmt.Errorf("jitter must not be negative")
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+
+	t := s.Target
+	targets := 0
+	for _, set := range []bool{t.Command != "", t.Script != "", t.Workflow != nil} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("exactly one of command, script and workflow is required")
+	}
+	if t.Workflow != nil {
+		if len(t.Args) > 0 {
+			return fmt.Errorf("args can not be used with a workflow")
+		}
+		if err := t.Workflow.Validate(); err != nil {
+			return fmt.Errorf("invalid workflow: %w", err)
+		}
+	}
+	return nil
+}
+
+// spec 解析计划任务的 cron 表达式
+func (s *Schedule) spec() (Spec, error) {
+	loc := time.Local
+	if s.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+		}
+	}
+	return Parse(s.Cron, loc)
+}
@@ -0,0 +1,579 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamlongalong/runshell/pkg/log"
+)
+
+const (
+	// DefaultHistory 每个计划任务默认保留的运行记录数量
+	DefaultHistory = 50
+	// maxQueuedRuns 排队策略下最多等待的运行数量，超出时跳过
+	maxQueuedRuns = 10
+	// maxRunOutput 运行记录中保存的输出的最大字节数，超出时保留末尾部分
+	maxRunOutput = 64 * 1024
+)
+
+// Dispatcher 执行计划任务的目标
+type Dispatcher interface {
+	// Dispatch 执行目标，输出写入 output，返回退出码。
+	// 执行失败或以非零状态退出时返回错误，ctx 取消时应尽快终止执行。
+	Dispatch(ctx context.Context, target *Target, output io.Writer) (int, error)
+}
+
+// DispatcherFunc 是函数形式的 Dispatcher
+type DispatcherFunc func(ctx context.Context, target *Target, output io.Writer) (int, error)
+
+// Dispatch 实现 Dispatcher 接口
+func (f DispatcherFunc) Dispatch(ctx context.Context, target *Target, output io.Writer) (int, error) {
+	return f(ctx, target, output)
+}
+
+// Scheduler 按计划触发计划任务
+type Scheduler struct {
+	store      Store
+	dispatcher Dispatcher
+	history    int
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	started bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// entry 是计划任务的调度状态，所有字段由 Scheduler.mu 保护
+type entry struct {
+	schedule *Schedule
+	spec     Spec
+	timer    *time.Timer
+	next     time.Time // 下一次计划触发时间，不含随机等待
+	active   *activeRun
+	queue    []*Run
+}
+
+// activeRun 是正在执行的运行
+type activeRun struct {
+	run      *Run
+	cancel   context.CancelFunc
+	done     chan struct{}
+	replaced string // 被 kill 策略终止时，替代它的运行 ID
+}
+
+// NewScheduler 创建调度器，调用 Start 后开始按计划触发
+func NewScheduler(store Store, dispatcher Dispatcher) *Scheduler {
+	return &Scheduler{
+		store:      store,
+		dispatcher: dispatcher,
+		history:    DefaultHistory,
+		entries:    make(map[string]*entry),
+	}
+}
+
+// WithHistory 设置每个计划任务保留的运行记录数量，小于等于 0 时不限制
+func (s *Scheduler) WithHistory(n int) *Scheduler {
+	s.history = n
+	return s
+}
+
+// Start 从存储中加载计划任务并开始调度。
+// 上次退出时未结束的运行被标记为已取消。
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return nil
+	}
+
+	schedules, err := s.store.ListSchedules()
+	if err != nil {
+		return fmt.Errorf("failed to load schedules: %w", err)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.started = true
+	for _, sc := range schedules {
+		spec, err := sc.spec()
+		if err != nil {
+			log.Error("Ignoring schedule %s: %v", sc.ID, err)
+			continue
+		}
+		s.recoverRuns(sc.ID)
+		e := &entry{schedule: sc, spec: spec}
+		s.entries[sc.ID] = e
+		s.arm(e)
+		s.saveSchedule(e.schedule)
+	}
+	log.Info("Scheduler started with %d schedules", len(s.entries))
+	return nil
+}
+
+// Stop 停止调度，终止正在执行的运行并等待其结束
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = false
+	for _, e := range s.entries {
+		if e.timer != nil {
+			e.timer.Stop()
+			e.timer = nil
+		}
+		s.cancelQueue(e, "scheduler stopped")
+	}
+	s.cancel()
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.mu.Lock()
+	s.entries = make(map[string]*entry)
+	s.mu.Unlock()
+	log.Info("Scheduler stopped")
+}
+
+// Create 创建计划任务
+func (s *Scheduler) Create(sc *Schedule) (*Schedule, error) {
+	sc = sc.Copy()
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	spec, _ := sc.spec()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sc.ID == "" {
+		sc.ID = uuid.New().String()
+	} else if exists, err := s.exists(sc.ID); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, ErrScheduleExists
+	}
+	sc.CreatedAt = time.Now()
+	sc.UpdatedAt = sc.CreatedAt
+	sc.LastRunTime, sc.LastStatus, sc.NextRunTime = nil, "", nil
+
+	e := &entry{schedule: sc, spec: spec}
+	s.arm(e)
+	if err := s.store.SaveSchedule(sc); err != nil {
+		s.disarm(e)
+		return nil, fmt.Errorf("failed to save schedule: %w", err)
+	}
+	if s.started {
+		s.entries[sc.ID] = e
+	}
+	log.Info("Created schedule %s (%s): %s", sc.ID, sc.Name, sc.Cron)
+	return sc.Copy(), nil
+}
+
+// Update 更新计划任务的定义，正在执行的运行不受影响
+func (s *Scheduler) Update(id string, sc *Schedule) (*Schedule, error) {
+	sc = sc.Copy()
+	sc.ID = id
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	spec, _ := sc.spec()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	sc.CreatedAt = old.CreatedAt
+	sc.UpdatedAt = time.Now()
+	sc.LastRunTime, sc.LastStatus, sc.NextRunTime = old.LastRunTime, old.LastStatus, nil
+
+	e := s.entries[id]
+	if e == nil {
+		e = &entry{}
+	}
+	s.disarm(e)
+	e.schedule, e.spec = sc, spec
+	s.arm(e)
+	if err := s.store.SaveSchedule(sc); err != nil {
+		return nil, fmt.Errorf("failed to save schedule: %w", err)
+	}
+	if s.started {
+		s.entries[id] = e
+	}
+	return sc.Copy(), nil
+}
+
+// Delete 删除计划任务及其运行记录，正在执行的运行被终止
+func (s *Scheduler) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.get(id); err != nil {
+		return err
+	}
+	if e := s.entries[id]; e != nil {
+		delete(s.entries, id)
+		s.disarm(e)
+		e.queue = nil
+		if e.active != nil {
+			e.active.cancel()
+		}
+	}
+	if err := s.store.DeleteSchedule(id); err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	log.Info("Deleted schedule %s", id)
+	return nil
+}
+
+// Get 返回计划任务
+func (s *Scheduler) Get(id string) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return sc.Copy(), nil
+}
+
+// List 返回所有计划任务，按创建时间排列
+func (s *Scheduler) List() ([]*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		return s.store.ListSchedules()
+	}
+	schedules := make([]*Schedule, 0, len(s.entries))
+	for _, e := range s.entries {
+		schedules = append(schedules, e.schedule.Copy())
+	}
+	sortSchedules(schedules)
+	return schedules, nil
+}
+
+// Trigger 立即触发一次运行，同样遵循重叠策略，返回运行记录
+func (s *Scheduler) Trigger(id string) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[id]
+	if e == nil {
+		if _, err := s.get(id); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("scheduler is not running")
+	}
+	run := s.dispatch(e, time.Now(), TriggerManual)
+	return run.Copy(), nil
+}
+
+// Runs 返回计划任务的运行记录，按计划触发时间从新到旧排列
+func (s *Scheduler) Runs(id string) ([]*Run, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	return s.store.ListRuns(id)
+}
+
+// GetRun 返回计划任务的一次运行
+func (s *Scheduler) GetRun(id, runID string) (*Run, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	return s.store.GetRun(id, runID)
+}
+
+// get 返回计划任务的定义，调用时需持有 mu
+func (s *Scheduler) get(id string) (*Schedule, error) {
+	if e := s.entries[id]; e != nil {
+		return e.schedule, nil
+	}
+	if s.started {
+		return nil, ErrScheduleNotFound
+	}
+	// 调度器未启动时直接从存储中读取
+	schedules, err := s.store.ListSchedules()
+	if err != nil {
+		return nil, err
+	}
+	for _, sc := range schedules {
+		if sc.ID == id {
+			return sc, nil
+		}
+	}
+	return nil, ErrScheduleNotFound
+}
+
+// exists 判断计划任务是否存在，调用时需持有 mu
+func (s *Scheduler) exists(id string) (bool, error) {
+	_, err := s.get(id)
+	if err == ErrScheduleNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// arm 计算下一次触发时间并设置定时器，调用时需持有 mu
+func (s *Scheduler) arm(e *entry) {
+	e.schedule.NextRunTime = nil
+	if !s.started || e.schedule.Paused {
+		return
+	}
+	now := time.Now()
+	next := e.spec.Next(now)
+	if next.IsZero() {
+		log.Error("Schedule %s will never run: %s", e.schedule.ID, e.schedule.Cron)
+		return
+	}
+	e.next = next
+	e.schedule.NextRunTime = &next
+
+	delay := next.Sub(now)
+	if jitter := time.Duration(e.schedule.Jitter); jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(jitter)))
+	}
+	e.timer = time.AfterFunc(delay, func() { s.fire(e, next) })
+}
+
+// disarm 停止定时器，调用时需持有 mu
+func (s *Scheduler) disarm(e *entry) {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+}
+
+// fire 在定时器到期时执行计划任务，并设置下一次触发
+func (s *Scheduler) fire(e *entry, scheduled time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 计划任务已更新、删除或调度器已停止
+	if !s.started || s.entries[e.schedule.ID] != e || !e.next.Equal(scheduled) {
+		return
+	}
+	e.timer = nil
+	s.dispatch(e, scheduled, TriggerSchedule)
+	s.arm(e)
+	s.saveSchedule(e.schedule)
+}
+
+// dispatch 按重叠策略开始一次运行，调用时需持有 mu
+func (s *Scheduler) dispatch(e *entry, scheduled time.Time, trigger string) *Run {
+	run := &Run{
+		ID:            uuid.New().String(),
+		ScheduleID:    e.schedule.ID,
+		Trigger:       trigger,
+		ScheduledTime: scheduled,
+	}
+	if e.active == nil {
+		s.start(e, run, nil)
+		return run
+	}
+
+	switch e.schedule.Overlap {
+	case OverlapQueue:
+		if len(e.queue) < maxQueuedRuns {
+			run.Status = StatusQueued
+			e.queue = append(e.queue, run)
+			s.saveRun(run)
+			log.Debug("Queued run %s of schedule %s", run.ID, e.schedule.ID)
+			return run
+		}
+		s.skip(run, "too many queued runs")
+	case OverlapKill:
+		prev := e.active
+		prev.replaced = run.ID
+		prev.cancel()
+		log.Info("Killing run %s of schedule %s", prev.run.ID, e.schedule.ID)
+		s.start(e, run, prev.done)
+	default:
+		s.skip(run, "previous run is still running")
+	}
+	return run
+}
+
+// skip 记录一次被跳过的运行，调用时需持有 mu
+func (s *Scheduler) skip(run *Run, reason string) {
+	run.Status = StatusSkipped
+	run.Error = reason
+	run.StartTime = time.Now()
+	run.EndTime = run.StartTime
+	s.saveRun(run)
+	s.prune(run.ScheduleID)
+	log.Info("Skipped run of schedule %s: %s", run.ScheduleID, reason)
+}
+
+// start 在新的 goroutine 中执行运行，wait 不为 nil 时先等待其关闭。调用时需持有 mu
+func (s *Scheduler) start(e *entry, run *Run, wait <-chan struct{}) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	if timeout := time.Duration(e.schedule.Timeout); timeout > 0 {
+		ctx, cancel = context.WithTimeout(s.ctx, timeout)
+	}
+	active := &activeRun{run: run, cancel: cancel, done: make(chan struct{})}
+	e.active = active
+
+	run.Status = StatusRunning
+	run.StartTime = time.Now()
+	startTime := run.StartTime
+	e.schedule.LastRunTime = &startTime
+	e.schedule.LastStatus = StatusRunning
+	s.saveRun(run)
+
+	target := e.schedule.Target
+	timeout := time.Duration(e.schedule.Timeout)
+	log.Info("Starting run %s of schedule %s (%s)", run.ID, e.schedule.ID, run.Trigger)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(active.done)
+		defer cancel()
+		if wait != nil {
+			<-wait
+		}
+
+		output := &tailBuffer{max: maxRunOutput}
+		exitCode, err := s.dispatcher.Dispatch(ctx, &target, output)
+		if err == nil && exitCode != 0 {
+			err = fmt.Errorf("exit status %d", exitCode)
+		}
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("run timed out after %v: %w", timeout, err)
+		}
+		s.finish(e, active, exitCode, output.String(), err, ctx.Err())
+	}()
+}
+
+// finish 记录运行结果，并开始排队中的下一次运行
+func (s *Scheduler) finish(e *entry, active *activeRun, exitCode int, output string, err, ctxErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run := active.run
+	run.EndTime = time.Now()
+	run.ExitCode = exitCode
+	run.Output = output
+	switch {
+	case err == nil:
+		run.Status = StatusSucceeded
+	case active.replaced != "":
+		run.Status = StatusCanceled
+		run.Error = "killed by run " + active.replaced
+	case ctxErr == context.Canceled:
+		run.Status = StatusCanceled
+		run.Error = err.Error()
+	default:
+		run.Status = StatusFailed
+		run.Error = err.Error()
+	}
+	log.Info("Run %s of schedule %s finished: %s", run.ID, run.ScheduleID, run.Status)
+
+	// 计划任务已删除时不再保存
+	if s.entries[e.schedule.ID] != e && s.started {
+		return
+	}
+	s.saveRun(run)
+	if e.active == active {
+		e.active = nil
+		e.schedule.LastStatus = run.Status
+		if s.started && len(e.queue) > 0 {
+			next := e.queue[0]
+			e.queue = e.queue[1:]
+			s.start(e, next, nil)
+		}
+	}
+	s.saveSchedule(e.schedule)
+	s.prune(run.ScheduleID)
+}
+
+// cancelQueue 取消排队中的运行，调用时需持有 mu
+func (s *Scheduler) cancelQueue(e *entry, reason string) {
+	for _, run := range e.queue {
+		run.Status = StatusCanceled
+		run.Error = reason
+		s.saveRun(run)
+	}
+	e.queue = nil
+}
+
+// recoverRuns 将上次退出时未结束的运行标记为已取消，调用时需持有 mu
+func (s *Scheduler) recoverRuns(id string) {
+	runs, err := s.store.ListRuns(id)
+	if err != nil {
+		log.Error("Failed to load runs of schedule %s: %v", id, err)
+		return
+	}
+	for _, run := range runs {
+		if run.Status != StatusRunning && run.Status != StatusQueued {
+			continue
+		}
+		run.Status = StatusCanceled
+		run.Error = "scheduler restarted"
+		if run.EndTime.IsZero() {
+			run.EndTime = time.Now()
+		}
+		s.saveRun(run)
+	}
+}
+
+// prune 删除超出保留数量的运行记录，调用时需持有 mu
+func (s *Scheduler) prune(id string) {
+	if s.history <= 0 {
+		return
+	}
+	runs, err := s.store.ListRuns(id)
+	if err != nil {
+		log.Error("Failed to list runs of schedule %s: %v", id, err)
+		return
+	}
+	for i := s.history; i < len(runs); i++ {
+		if runs[i].Status == StatusRunning || runs[i].Status == StatusQueued {
+			continue
+		}
+		if err := s.store.DeleteRun(id, runs[i].ID); err != nil {
+			log.Error("Failed to delete run %s of schedule %s: %v", runs[i].ID, id, err)
+		}
+	}
+}
+
+// saveSchedule 保存计划任务，调用时需持有 mu
+func (s *Scheduler) saveSchedule(sc *Schedule) {
+	if err := s.store.SaveSchedule(sc); err != nil {
+		log.Error("Failed to save schedule %s: %v", sc.ID, err)
+	}
+}
+
+// saveRun 保存运行记录，调用时需持有 mu
+func (s *Scheduler) saveRun(run *Run) {
+	if err := s.store.SaveRun(run); err != nil {
+		log.Error("Failed to save run %s of schedule %s: %v", run.ID, run.ScheduleID, err)
+	}
+}
+
+// tailBuffer 只保留最后 max 个字节，可以并发写入
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,274 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/workflow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDispatcher 将命令写入输出；命令为 block 时一直执行到 release 关闭或 ctx 取消，为 fail 时以状态 2 退出
+type testDispatcher struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func newTestDispatcher() *testDispatcher {
+	return &testDispatcher{release: make(chan struct{})}
+}
+
+func (d *testDispatcher) Dispatch(ctx context.Context, target *Target, output io.Writer) (int, error) {
+	d.calls.Add(1)
+	fmt.Fprintln(output, target.Command)
+	switch target.Command {
+	case "block":
+		select {
+		case <-d.release:
+			return 0, nil
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		}
+	case "fail":
+		return 2, nil
+	}
+	return 0, nil
+}
+
+// waitRun 等待运行结束并返回其记录
+func waitRun(t *testing.T, s *Scheduler, scheduleID, runID string) *Run {
+	t.Helper()
+	var run *Run
+	require.Eventually(t, func() bool {
+		var err error
+		run, err = s.GetRun(scheduleID, runID)
+		require.NoError(t, err)
+		return run.Status != StatusRunning && run.Status != StatusQueued
+	}, 5*time.Second, 10*time.Millisecond)
+	return run
+}
+
+func TestSchedulerCRUD(t *testing.T) {
+	s := NewScheduler(NewMemoryStore(), newTestDispatcher())
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	_, err := s.Create(&Schedule{Cron: "bad", Target: Target{Command: "echo"}})
+	assert.Error(t, err)
+	_, err = s.Create(&Schedule{Cron: "@daily"})
+	assert.Error(t, err)
+	_, err = s.Create(&Schedule{Cron: "@daily", Target: Target{Command: "echo", Workflow: &workflow.Workflow{}}})
+	assert.Error(t, err)
+	_, err = s.Create(&Schedule{Cron: "@daily", Overlap: "replace", Target: Target{Command: "echo"}})
+	assert.Error(t, err)
+	_, err = s.Create(&Schedule{Cron: "@daily", Timezone: "Nowhere/City", Target: Target{Command: "echo"}})
+	assert.Error(t, err)
+
+	created, err := s.Create(&Schedule{ID: "nightly", Cron: "0 3 * * *", Target: Target{Command: "echo"}})
+	require.NoError(t, err)
+	assert.False(t, created.CreatedAt.IsZero())
+	require.NotNil(t, created.NextRunTime)
+	assert.Equal(t, 3, created.NextRunTime.Hour())
+	_, err = s.Create(&Schedule{ID: "nightly", Cron: "@daily", Target: Target{Command: "echo"}})
+	assert.ErrorIs(t, err, ErrScheduleExists)
+
+	updated, err := s.Update("nightly", &Schedule{Cron: "@hourly", Paused: true, Target: Target{Script: "maintenance"}})
+	require.NoError(t, err)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+	assert.Nil(t, updated.NextRunTime)
+	_, err = s.Update("missing", &Schedule{Cron: "@hourly", Target: Target{Command: "echo"}})
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+
+	schedules, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, "maintenance", schedules[0].Target.Script)
+
+	require.NoError(t, s.Delete("nightly"))
+	_, err = s.Get("nightly")
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+	assert.ErrorIs(t, s.Delete("nightly"), ErrScheduleNotFound)
+}
+
+func TestSchedulerFires(t *testing.T) {
+	dispatcher := newTestDispatcher()
+	s := NewScheduler(NewMemoryStore(), dispatcher)
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	sc, err := s.Create(&Schedule{Cron: "@every 1s", Target: Target{Command: "tick"}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return dispatcher.calls.Load() >= 2 }, 5*time.Second, 10*time.Millisecond)
+
+	runs, err := s.Runs(sc.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, runs)
+	run := waitRun(t, s, sc.ID, runs[len(runs)-1].ID)
+	assert.Equal(t, TriggerSchedule, run.Trigger)
+	assert.Equal(t, StatusSucceeded, run.Status)
+	assert.Equal(t, "tick\n", run.Output)
+
+	got, err := s.Get(sc.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, got.LastRunTime)
+	assert.True(t, got.NextRunTime.After(*got.LastRunTime))
+}
+
+func TestSchedulerOverlap(t *testing.T) {
+	t.Run("skip", func(t *testing.T) {
+		dispatcher := newTestDispatcher()
+		s := NewScheduler(NewMemoryStore(), dispatcher)
+		require.NoError(t, s.Start())
+		defer s.Stop()
+
+		sc, err := s.Create(&Schedule{Cron: "@yearly", Target: Target{Command: "block"}})
+		require.NoError(t, err)
+		first, err := s.Trigger(sc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusRunning, first.Status)
+		second, err := s.Trigger(sc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusSkipped, second.Status)
+
+		close(dispatcher.release)
+		assert.Equal(t, StatusSucceeded, waitRun(t, s, sc.ID, first.ID).Status)
+		assert.EqualValues(t, 1, dispatcher.calls.Load())
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		dispatcher := newTestDispatcher()
+		s := NewScheduler(NewMemoryStore(), dispatcher)
+		require.NoError(t, s.Start())
+		defer s.Stop()
+
+		sc, err := s.Create(&Schedule{Cron: "@yearly", Overlap: OverlapQueue, Target: Target{Command: "block"}})
+		require.NoError(t, err)
+		first, err := s.Trigger(sc.ID)
+		require.NoError(t, err)
+		second, err := s.Trigger(sc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusQueued, second.Status)
+
+		close(dispatcher.release)
+		assert.Equal(t, StatusSucceeded, waitRun(t, s, sc.ID, first.ID).Status)
+		assert.Equal(t, StatusSucceeded, waitRun(t, s, sc.ID, second.ID).Status)
+		assert.EqualValues(t, 2, dispatcher.calls.Load())
+	})
+
+	t.Run("kill", func(t *testing.T) {
+		dispatcher := newTestDispatcher()
+		s := NewScheduler(NewMemoryStore(), dispatcher)
+		require.NoError(t, s.Start())
+		defer s.Stop()
+
+		sc, err := s.Create(&Schedule{Cron: "@yearly", Overlap: OverlapKill, Target: Target{Command: "block"}})
+		require.NoError(t, err)
+		first, err := s.Trigger(sc.ID)
+		require.NoError(t, err)
+		second, err := s.Trigger(sc.ID)
+		require.NoError(t, err)
+
+		killed := waitRun(t, s, sc.ID, first.ID)
+		assert.Equal(t, StatusCanceled, killed.Status)
+		assert.Equal(t, "killed by run "+second.ID, killed.Error)
+
+		close(dispatcher.release)
+		assert.Equal(t, StatusSucceeded, waitRun(t, s, sc.ID, second.ID).Status)
+	})
+}
+
+func TestSchedulerFailuresAndTimeout(t *testing.T) {
+	s := NewScheduler(NewMemoryStore(), newTestDispatcher())
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	sc, err := s.Create(&Schedule{Cron: "@yearly", Target: Target{Command: "fail"}})
+	require.NoError(t, err)
+	run, err := s.Trigger(sc.ID)
+	require.NoError(t, err)
+	run = waitRun(t, s, sc.ID, run.ID)
+	assert.Equal(t, StatusFailed, run.Status)
+	assert.Equal(t, 2, run.ExitCode)
+	assert.Equal(t, "exit status 2", run.Error)
+
+	sc, err = s.Create(&Schedule{Cron: "@yearly", Timeout: workflow.Duration(50 * time.Millisecond), Target: Target{Command: "block"}})
+	require.NoError(t, err)
+	run, err = s.Trigger(sc.ID)
+	require.NoError(t, err)
+	run = waitRun(t, s, sc.ID, run.ID)
+	assert.Equal(t, StatusFailed, run.Status)
+	assert.Contains(t, run.Error, "timed out")
+
+	got, err := s.Get(sc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, got.LastStatus)
+}
+
+func TestSchedulerHistory(t *testing.T) {
+	s := NewScheduler(NewMemoryStore(), newTestDispatcher()).WithHistory(2)
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	sc, err := s.Create(&Schedule{Cron: "@yearly", Target: Target{Command: "echo"}})
+	require.NoError(t, err)
+	var last *Run
+	for i := 0; i < 4; i++ {
+		run, err := s.Trigger(sc.ID)
+		require.NoError(t, err)
+		last = waitRun(t, s, sc.ID, run.ID)
+	}
+	runs, err := s.Runs(sc.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, last.ID, runs[0].ID)
+}
+
+func TestSchedulerPersistence(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+	dispatcher := newTestDispatcher()
+	s := NewScheduler(store, dispatcher)
+	require.NoError(t, s.Start())
+
+	sc, err := s.Create(&Schedule{Name: "maintenance", Cron: "0 3 * * *", Target: Target{Command: "block", Env: map[string]string{"A": "1"}}})
+	require.NoError(t, err)
+	run, err := s.Trigger(sc.ID)
+	require.NoError(t, err)
+	// 停止时终止正在执行的运行
+	s.Stop()
+	assert.Equal(t, StatusCanceled, waitRun(t, s, sc.ID, run.ID).Status)
+
+	// 模拟进程退出时没有结束的运行
+	stale := &Run{ID: "stale", ScheduleID: sc.ID, Status: StatusRunning, ScheduledTime: time.Now()}
+	require.NoError(t, store.SaveRun(stale))
+
+	store, err = NewFileStore(dir)
+	require.NoError(t, err)
+	s = NewScheduler(store, dispatcher)
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	got, err := s.Get(sc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "maintenance", got.Name)
+	assert.Equal(t, map[string]string{"A": "1"}, got.Target.Env)
+	assert.NotNil(t, got.NextRunTime)
+	stale, err = s.GetRun(sc.ID, "stale")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCanceled, stale.Status)
+	runs, err := s.Runs(sc.ID)
+	require.NoError(t, err)
+	assert.Len(t, runs, 2)
+
+	require.NoError(t, s.Delete(sc.ID))
+	_, err = store.ListRuns(sc.ID)
+	require.NoError(t, err)
+	_, err = s.GetRun(sc.ID, "stale")
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+}
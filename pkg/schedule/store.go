@@ -0,0 +1,309 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store 保存计划任务的定义和运行记录
+type Store interface {
+	// SaveSchedule 保存计划任务，已存在时覆盖
+	SaveSchedule(s *Schedule) error
+	// DeleteSchedule 删除计划任务及其运行记录
+	DeleteSchedule(id string) error
+	// ListSchedules 返回所有计划任务，按创建时间排列
+	ListSchedules() ([]*Schedule, error)
+	// SaveRun 保存运行记录，已存在时覆盖
+	SaveRun(run *Run) error
+	// GetRun 返回运行记录，不存在时返回 ErrRunNotFound
+	GetRun(scheduleID, runID string) (*Run, error)
+	// ListRuns 返回计划任务的运行记录，按计划触发时间从新到旧排列
+	ListRuns(scheduleID string) ([]*Run, error)
+	// DeleteRun 删除运行记录
+	DeleteRun(scheduleID, runID string) error
+}
+
+// MemoryStore 在内存中保存计划任务
+type MemoryStore struct {
+	mu        sync.RWMutex
+	schedules map[string]*Schedule
+	runs      map[string]map[string]*Run
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		schedules: make(map[string]*Schedule),
+		runs:      make(map[string]map[string]*Run),
+	}
+}
+
+// SaveSchedule 实现 Store 接口
+func (m *MemoryStore) SaveSchedule(s *Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedules[s.ID] = s.Copy()
+	return nil
+}
+
+// DeleteSchedule 实现 Store 接口
+func (m *MemoryStore) DeleteSchedule(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.schedules, id)
+	delete(m.runs, id)
+	return nil
+}
+
+// ListSchedules 实现 Store 接口
+func (m *MemoryStore) ListSchedules() ([]*Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	schedules := make([]*Schedule, 0, len(m.schedules))
+	for _, s := range m.schedules {
+		schedules = append(schedules, s.Copy())
+	}
+	sortSchedules(schedules)
+	return schedules, nil
+}
+
+// SaveRun 实现 Store 接口
+func (m *MemoryStore) SaveRun(run *Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.runs[run.ScheduleID] == nil {
+		m.runs[run.ScheduleID] = make(map[string]*Run)
+	}
+	m.runs[run.ScheduleID][run.ID] = run.Copy()
+	return nil
+}
+
+// GetRun 实现 Store 接口
+func (m *MemoryStore) GetRun(scheduleID, runID string) (*Run, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	run, ok := m.runs[scheduleID][runID]
+	if !ok {
+		return nil, ErrRunNotFound
+	}
+	return run.Copy(), nil
+}
+
+// ListRuns 实现 Store 接口
+func (m *MemoryStore) ListRuns(scheduleID string) ([]*Run, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	runs := make([]*Run, 0, len(m.runs[scheduleID]))
+	for _, run := range m.runs[scheduleID] {
+		runs = append(runs, run.Copy())
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+// DeleteRun 实现 Store 接口
+func (m *MemoryStore) DeleteRun(scheduleID, runID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.runs[scheduleID], runID)
+	return nil
+}
+
+// FileStore 将计划任务保存为目录中的 JSON 文件：
+// 定义保存在 <dir>/schedules/<id>.json，运行记录保存在 <dir>/runs/<id>/<run_id>.json
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStore 创建文件存储，目录不存在时自动创建
+func NewFileStore(dir string) (*FileStore, error) {
+	for _, sub := range []string{"schedules", "runs"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create schedule directory: %w", err)
+		}
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// SaveSchedule 实现 Store 接口
+func (f *FileStore) SaveSchedule(s *Schedule) error {
+	if err := validateID(s.ID); err != nil {
+		return err
+	}
+	return f.write(filepath.Join(f.dir, "schedules"), s.ID, s)
+}
+
+// DeleteSchedule 实现 Store 接口
+func (f *FileStore) DeleteSchedule(id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := os.Remove(filepath.Join(f.dir, "schedules", id+".json")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	if err := os.RemoveAll(filepath.Join(f.dir, "runs", id)); err != nil {
+		return fmt.Errorf("failed to delete schedule runs: %w", err)
+	}
+	return nil
+}
+
+// ListSchedules 实现 Store 接口
+func (f *FileStore) ListSchedules() ([]*Schedule, error) {
+	var schedules []*Schedule
+	err := f.readDir(filepath.Join(f.dir, "schedules"), func(data []byte, path string) error {
+		var s Schedule
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("failed to decode schedule %s: %w", path, err)
+		}
+		schedules = append(schedules, &s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortSchedules(schedules)
+	return schedules, nil
+}
+
+// SaveRun 实现 Store 接口
+func (f *FileStore) SaveRun(run *Run) error {
+	if err := validateID(run.ScheduleID); err != nil {
+		return err
+	}
+	if err := validateID(run.ID); err != nil {
+		return err
+	}
+	dir := filepath.Join(f.dir, "runs", run.ScheduleID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to save schedule run: %w", err)
+	}
+	return f.write(dir, run.ID, run)
+}
+
+// GetRun 实现 Store 接口
+func (f *FileStore) GetRun(scheduleID, runID string) (*Run, error) {
+	if validateID(scheduleID) != nil || validateID(runID) != nil {
+		return nil, ErrRunNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(f.dir, "runs", scheduleID, runID+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrRunNotFound
+		}
+		return nil, fmt.Errorf("failed to read schedule run: %w", err)
+	}
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to decode schedule run: %w", err)
+	}
+	return &run, nil
+}
+
+// ListRuns 实现 Store 接口
+func (f *FileStore) ListRuns(scheduleID string) ([]*Run, error) {
+	if err := validateID(scheduleID); err != nil {
+		return nil, err
+	}
+	var runs []*Run
+	err := f.readDir(filepath.Join(f.dir, "runs", scheduleID), func(data []byte, path string) error {
+		var run Run
+		if err := json.Unmarshal(data, &run); err != nil {
+			return fmt.Errorf("failed to decode schedule run %s: %w", path, err)
+		}
+		runs = append(runs, &run)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+// DeleteRun 实现 Store 接口
+func (f *FileStore) DeleteRun(scheduleID, runID string) error {
+	if validateID(scheduleID) != nil || validateID(runID) != nil {
+		return ErrRunNotFound
+	}
+	if err := os.Remove(filepath.Join(f.dir, "runs", scheduleID, runID+".json")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete schedule run: %w", err)
+	}
+	return nil
+}
+
+// write 将 v 编码为 JSON 写入 <dir>/<name>.json，先写入临时文件再重命名
+func (f *FileStore) write(dir, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tmp := filepath.Join(dir, "."+name+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save %s: %w", name, err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, name+".json")); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save %s: %w", name, err)
+	}
+	return nil
+}
+
+// readDir 读取目录中的所有 JSON 文件，目录不存在时视为空
+func (f *FileStore) readDir(dir string, fn func(data []byte, path string) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if err := fn(data, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateID 校验 ID，防止路径穿越
+func validateID(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("invalid id %q", id)
+	}
+	return nil
+}
+
+// sortSchedules 按创建时间排列
+func sortSchedules(schedules []*Schedule) {
+	sort.SliceStable(schedules, func(i, j int) bool {
+		if schedules[i].CreatedAt.Equal(schedules[j].CreatedAt) {
+			return schedules[i].ID < schedules[j].ID
+		}
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+}
+
+// sortRuns 按计划触发时间从新到旧排列
+func sortRuns(runs []*Run) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].ScheduledTime.After(runs[j].ScheduledTime)
+	})
+}
@@ -0,0 +1,209 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了会话请求中 Docker 配置的校验和限制。
+package server
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// DockerLimits 是会话请求中 Docker 配置的上限，请求不能超出管理员设置的限制
+type DockerLimits struct {
+	MaxMemory    int64    // 内存上限（字节），0 表示不限制，请求未指定时使用上限
+	MaxCPUs      float64  // CPU 配额上限（核数），0 表示不限制，请求未指定时使用上限
+	MaxPids      int64    // 进程数上限，0 表示不限制，请求未指定时使用上限
+	Images       []string // 除默认镜像外允许使用的镜像
+	NetworkModes []string // 除默认网络模式和 none 外允许使用的网络模式
+}
+
+// DockerBuilderFunc 根据 Docker 配置创建执行器构建器
+type DockerBuilderFunc func(config types.DockerConfig) types.ExecutorBuilder
+
+// dockerSessions 根据会话请求创建 Docker 执行器
+type dockerSessions struct {
+	base   types.DockerConfig
+	limits DockerLimits
+	build  DockerBuilderFunc
+}
+
+// WithDocker 允许会话请求通过 docker_config 定制容器。
+// base 是默认配置，请求只能收紧其中的隔离设置，资源限制不能超出 limits，
+// tmpfs、extra hosts、DNS 和 security opt 只能从 base 中选择。
+func (s *Server) WithDocker(base types.DockerConfig, limits DockerLimits, build DockerBuilderFunc) *Server {
+	s.docker = &dockerSessions{base: base, limits: limits, build: build}
+	return s
+}
+
+// dockerSessionBuilder 返回按会话请求中的 Docker 配置创建执行器的构建器
+func (s *Server) dockerSessionBuilder(req *types.SessionRequest) (types.ExecutorBuilder, error) {
+	if req.ExecutorType != "" && req.ExecutorType != types.ExecutorTypeDocker {
+		return nil, fmt.Errorf("docker_config can not be used with the %s executor", req.ExecutorType)
+	}
+	if s.docker == nil {
+		return nil, fmt.Errorf("docker_config is not enabled on this server")
+	}
+	config, err := s.docker.config(req.DockerConfig)
+	if err != nil {
+		return nil, err
+	}
+	return s.docker.build(config), nil
+}
+
+// config 将请求中的配置与默认配置合并，请求放宽隔离设置或超出限制时返回错误
+func (d *dockerSessions) config(req *types.DockerConfig) (types.DockerConfig, error) {
+	base, limits := d.base, d.limits
+	cfg := base
+	cfg.CapDrop = append([]string(nil), base.CapDrop...)
+	cfg.ExtraHosts = append([]string(nil), base.ExtraHosts...)
+	cfg.DNS = append([]string(nil), base.DNS...)
+	cfg.Tmpfs = mergeMap(base.Tmpfs, nil)
+	// 默认配置中的标签优先，管理员可以用标签识别会话容器
+	cfg.Labels = mergeMap(req.Labels, base.Labels)
+
+	if req.Image != "" && req.Image != base.Image {
+		if !slices.Contains(limits.Images, req.Image) {
+			return cfg, fmt.Errorf("image %q is not allowed", req.Image)
+		}
+		cfg.Image = req.Image
+	}
+	if req.WorkDir != "" {
+		cfg.WorkDir = req.WorkDir
+	}
+	if req.User != "" && req.User != base.User {
+		return cfg, fmt.Errorf("user can not be changed")
+	}
+	if req.BindMount != "" && req.BindMount != base.BindMount {
+		return cfg, fmt.Errorf("bind mount can not be changed")
+	}
+
+	// 资源限制
+	var err error
+	if cfg.Memory, err = limitInt(req.Memory, base.Memory, limits.MaxMemory, "memory"); err != nil {
+		return cfg, err
+	}
+	if cfg.PidsLimit, err = limitInt(req.PidsLimit, base.PidsLimit, limits.MaxPids, "pids limit"); err != nil {
+		return cfg, err
+	}
+	cfg.CPUs = base.CPUs
+	if req.CPUs != 0 {
+		cfg.CPUs = req.CPUs
+	}
+	if cfg.CPUs < 0 {
+		return cfg, fmt.Errorf("cpus must not be negative")
+	}
+	if limits.MaxCPUs > 0 {
+		if cfg.CPUs == 0 {
+			cfg.CPUs = limits.MaxCPUs
+		} else if cfg.CPUs > limits.MaxCPUs {
+			return cfg, fmt.Errorf("cpus %g exceeds the limit of %g", cfg.CPUs, limits.MaxCPUs)
+		}
+	}
+
+	// 网络和隔离，只能收紧
+	if mode := req.NetworkMode; mode != "" && mode != base.NetworkMode {
+		if mode != "none" && !slices.Contains(limits.NetworkModes, mode) {
+			return cfg, fmt.Errorf("network mode %q is not allowed", mode)
+		}
+		cfg.NetworkMode = mode
+	}
+	cfg.ReadOnly = base.ReadOnly || req.ReadOnly
+	cfg.NoNewPrivileges = base.NoNewPrivileges || req.NoNewPrivileges
+	for _, c := range req.CapDrop {
+		if !slices.Contains(cfg.CapDrop, c) {
+			cfg.CapDrop = append(cfg.CapDrop, c)
+		}
+	}
+	for _, opt := range req.SecurityOpt {
+		if !slices.Contains(base.SecurityOpt, opt) {
+			return cfg, fmt.Errorf("security option %q is not allowed", opt)
+		}
+	}
+	if len(req.Ulimits) > 0 {
+		if cfg.Ulimits, err = limitUlimits(req.Ulimits, base.Ulimits); err != nil {
+			return cfg, err
+		}
+	}
+
+	// tmpfs、hosts 和 DNS 只能使用默认配置中已有的设置
+	for path, opts := range req.Tmpfs {
+		baseOpts, ok := base.Tmpfs[path]
+		if !ok {
+			return cfg, fmt.Errorf("tmpfs %q is not allowed", path)
+		}
+		if opts != "" && opts != baseOpts {
+			return cfg, fmt.Errorf("tmpfs options %q of %s are not allowed", opts, path)
+		}
+	}
+	for _, host := range req.ExtraHosts {
+		if !slices.Contains(base.ExtraHosts, host) {
+			return cfg, fmt.Errorf("extra host %q is not allowed", host)
+		}
+	}
+	for _, dns := range req.DNS {
+		if !slices.Contains(base.DNS, dns) {
+			return cfg, fmt.Errorf("dns server %q is not allowed", dns)
+		}
+	}
+	if len(req.DNS) > 0 {
+		cfg.DNS = append([]string(nil), req.DNS...)
+	}
+	return cfg, nil
+}
+
+// limitInt 返回请求的资源限制，未指定时使用默认值，默认值也未指定时使用上限
+func limitInt(req, base, limit int64, name string) (int64, error) {
+	if req < 0 {
+		return 0, fmt.Errorf("%s must not be negative", name)
+	}
+	v := base
+	if req != 0 {
+		v = req
+	}
+	if limit > 0 {
+		if v == 0 {
+			v = limit
+		} else if v > limit {
+			return 0, fmt.Errorf("%s %d exceeds the limit of %d", name, v, limit)
+		}
+	}
+	return v, nil
+}
+
+// limitUlimits 校验请求的 ulimit，只能降低默认配置中已有的限制
+func limitUlimits(req, base []types.Ulimit) ([]types.Ulimit, error) {
+	ulimits := append([]types.Ulimit(nil), base...)
+	for _, u := range req {
+		i := -1
+		for j, b := range ulimits {
+			if b.Name == u.Name {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("ulimit %q is not allowed", u.Name)
+		}
+		if u.Soft > u.Hard || u.Hard > ulimits[i].Hard || u.Soft > ulimits[i].Soft {
+			return nil, fmt.Errorf("ulimit %s=%d:%d exceeds the limit of %d:%d", u.Name, u.Soft, u.Hard, ulimits[i].Soft, ulimits[i].Hard)
+		}
+		ulimits[i] = u
+	}
+	return ulimits, nil
+}
+
+// mergeMap 合并两个映射，b 中的值优先
+func mergeMap(a, b map[string]string) map[string]string {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		m[k] = v
+	}
+	for k, v := range b {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,169 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了会话工作目录中文件的上传和下载。
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// maxUploadSize 上传文件的大小上限
+const maxUploadSize = 64 << 20
+
+// sessionFileSystem 获取会话工作目录的文件系统
+func (s *Server) sessionFileSystem(c *gin.Context) (types.FileSystem, bool) {
+	session, err := s.sessionManager.GetSession(c.Param("id"))
+	if err != nil {
+		s.handleError(c, http.StatusNotFound, err, "")
+		return nil, false
+	}
+	provider, ok := types.As[types.FileSystemProvider](session.Executor)
+	if !ok {
+		s.handleError(c, http.StatusNotImplemented, fmt.Errorf("executor %s does not support file access", session.Executor.Name()), "")
+		return nil, false
+	}
+	fsys, err := provider.FileSystem(sessionWorkDir(session))
+	if err != nil {
+		s.handleError(c, http.StatusInternalServerError, err, "")
+		return nil, false
+	}
+	return fsys, true
+}
+
+// invalidateCache 清除会话执行器缓存的命令结果，通过文件接口修改工作目录后调用
+func (s *Server) invalidateCache(sessionID string) {
+	session, err := s.sessionManager.GetSession(sessionID)
+	if err != nil {
+		return
+	}
+	if invalidator, ok := types.As[types.CacheInvalidator](session.Executor); ok {
+		invalidator.InvalidateCache()
+	}
+}
+
+// fileStatus 返回文件操作失败时的状态码
+func fileStatus(err error) int {
+	if errors.Is(err, os.ErrNotExist) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// @Summary     Download File
+// @Description Download a file from the session workdir. Directories return their entries as JSON
+// @Tags        sessions
+// @Produce     octet-stream,json
+// @Param       id path string true "Session ID"
+// @Param       path query string true "File path relative to the session workdir"
+// @Success     200 {array} types.FileInfo
+// @Failure     400 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /sessions/{id}/files [get]
+func (s *Server) handleDownloadFile(c *gin.Context) {
+	name := c.Query("path")
+	if name == "" {
+		s.handleError(c, http.StatusBadRequest, fmt.Errorf("path is required"), "")
+		return
+	}
+	fsys, ok := s.sessionFileSystem(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	info, err := fsys.Stat(ctx, name)
+	if err != nil {
+		s.handleError(c, fileStatus(err), err, "")
+		return
+	}
+	if info.IsDir {
+		entries, err := fsys.ReadDir(ctx, name)
+		if err != nil {
+			s.handleError(c, fileStatus(err), err, "")
+			return
+		}
+		if entries == nil {
+			entries = []types.FileInfo{}
+		}
+		c.JSON(http.StatusOK, entries)
+		return
+	}
+
+	data, err := fsys.ReadFile(ctx, name)
+	if err != nil {
+		s.handleError(c, fileStatus(err), err, "")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(name)))
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+// @Summary     Upload File
+// @Description Upload the request body as a file in the session workdir, replacing any existing file
+// @Tags        sessions
+// @Accept      octet-stream
+// @Param       id path string true "Session ID"
+// @Param       path query string true "File path relative to the session workdir"
+// @Param       mode query string false "File mode in octal for new files" default(0644)
+// @Param       parents query bool false "Create missing parent directories"
+// @Success     204 "No Content"
+// @Failure     400 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Failure     413 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /sessions/{id}/files [put]
+func (s *Server) handleUploadFile(c *gin.Context) {
+	name := c.Query("path")
+	if name == "" {
+		s.handleError(c, http.StatusBadRequest, fmt.Errorf("path is required"), "")
+		return
+	}
+	perm := os.FileMode(0644)
+	if mode := c.Query("mode"); mode != "" {
+		m, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			s.handleError(c, http.StatusBadRequest, fmt.Errorf("invalid mode: %s", mode), "")
+			return
+		}
+		perm = os.FileMode(m).Perm()
+	}
+	fsys, ok := s.sessionFileSystem(c)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxUploadSize+1))
+	if err != nil {
+		s.handleError(c, http.StatusBadRequest, err, "")
+		return
+	}
+	if len(data) > maxUploadSize {
+		s.handleError(c, http.StatusRequestEntityTooLarge, fmt.Errorf("file exceeds %d bytes", maxUploadSize), "")
+		return
+	}
+
+	ctx := c.Request.Context()
+	if c.Query("parents") == "true" {
+		if dir := path.Dir(name); dir != "." && dir != "/" {
+			if err := fsys.Mkdir(ctx, dir, 0755, true); err != nil {
+				s.handleError(c, fileStatus(err), err, "")
+				return
+			}
+		}
+	}
+	if err := fsys.WriteFile(ctx, name, data, perm); err != nil {
+		s.handleError(c, fileStatus(err), err, "")
+		return
+	}
+	s.invalidateCache(c.Param("id"))
+	c.Status(http.StatusNoContent)
+}
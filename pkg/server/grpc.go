@@ -0,0 +1,378 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了 gRPC 服务，与 HTTP API 共用执行器和会话管理。
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/iamlongalong/runshell/pkg/grpcapi"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/policy"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcService 实现 grpcapi.RunShellServer
+type grpcService struct {
+	s *Server
+}
+
+// newGRPCServer 创建注册了 RunShell 服务的 gRPC 服务器
+func (s *Server) newGRPCServer() *grpc.Server {
+	srv := grpc.NewServer(grpc.ForceServerCodec(grpcapi.Codec{}))
+	grpcapi.RegisterRunShellServer(srv, &grpcService{s: s})
+	return srv
+}
+
+// grpcError 将执行错误转换为 gRPC 状态
+func grpcError(err error) error {
+	var argErr *types.ArgError
+	if errors.As(err, &argErr) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	var deniedErr *policy.DeniedError
+	if errors.As(err, &deniedErr) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// execResponse 将执行结果转换为响应
+func execResponse(result *types.ExecuteResult) *grpcapi.ExecResponse {
+	resp := &grpcapi.ExecResponse{
+		ExitCode: result.ExitCode,
+		Output:   result.Output,
+		Changes:  result.Changes,
+		Data:     result.Data,
+	}
+	if result.Error != nil {
+		resp.Error = result.Error.Error()
+	}
+	return resp
+}
+
+// streamWriter 将写入的数据作为流消息发送，多个 writer 共享同一把锁以串行化发送
+type streamWriter struct {
+	mu      *sync.Mutex
+	send    func(p []byte) error
+	written bool
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// 发送前复制数据，调用方可能复用缓冲区
+	if err := w.send(append([]byte(nil), p...)); err != nil {
+		return 0, err
+	}
+	w.written = true
+	return len(p), nil
+}
+
+// Exec 流式执行命令
+func (g *grpcService) Exec(stream grpcapi.ExecStream) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	req := first.Start
+	if req == nil || req.Command == "" {
+		return status.Error(codes.InvalidArgument, "first message must start a command")
+	}
+	ctx := stream.Context()
+
+	var mu sync.Mutex
+	stdout := &streamWriter{mu: &mu, send: func(p []byte) error {
+		return stream.Send(&grpcapi.ExecStreamResponse{Stdout: p})
+	}}
+	stderr := &streamWriter{mu: &mu, send: func(p []byte) error {
+		return stream.Send(&grpcapi.ExecStreamResponse{Stderr: p})
+	}}
+	opts := &types.ExecuteOptions{
+		WorkDir: req.WorkDir,
+		Env:     req.Env,
+		Stdout:  stdout,
+		Stderr:  stderr,
+		Track:   req.Track,
+		Timeout: req.Timeout,
+	}
+
+	// 仅在客户端声明发送标准输入时连接输入流，否则命令会等待输入结束
+	if req.Stdin {
+		stdinR, stdinW := io.Pipe()
+		defer stdinR.Close()
+		opts.Stdin = stdinR
+		go func() {
+			for {
+				msg, err := stream.Recv()
+				if err == io.EOF {
+					stdinW.Close()
+					return
+				}
+				if err != nil {
+					stdinW.CloseWithError(err)
+					return
+				}
+				if len(msg.Stdin) > 0 {
+					if _, err := stdinW.Write(msg.Stdin); err != nil {
+						return
+					}
+				}
+				if msg.CloseStdin {
+					stdinW.Close()
+					return
+				}
+			}
+		}()
+	}
+
+	cmd := types.Command{Command: req.Command, Args: req.Args}
+	var result *types.ExecuteResult
+	if req.SessionID != "" {
+		session, getErr := g.s.sessionManager.GetSession(req.SessionID)
+		if getErr != nil {
+			return status.Error(codes.NotFound, getErr.Error())
+		}
+		if session.Options != nil && session.Options.Env != nil {
+			opts.Env = session.Options.Env
+		}
+		var snapshotID string
+		result, snapshotID, err = g.s.executeInSession(ctx, session, cmd, opts)
+		if snapshotID != "" {
+			stream.SetHeader(metadata.Pairs(SnapshotHeader, snapshotID))
+		}
+	} else {
+		result, err = g.s.execute(ctx, cmd, opts)
+	}
+	if result == nil {
+		if err == nil {
+			err = errors.New("executor returned no result")
+		}
+		log.Error("gRPC exec failed: %v", err)
+		return grpcError(err)
+	}
+
+	resp := execResponse(result)
+	if err != nil && resp.Error == "" {
+		resp.Error = err.Error()
+	}
+	// 输出已通过数据块返回，仅在命令没有写入输出流时在结果中返回输出
+	mu.Lock()
+	if stdout.written || stderr.written {
+		resp.Output = ""
+	}
+	mu.Unlock()
+	return stream.Send(&grpcapi.ExecStreamResponse{Result: resp})
+}
+
+// Terminal 运行交互式终端
+func (g *grpcService) Terminal(stream grpcapi.TerminalStream) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	req := first.Start
+	if req == nil {
+		return status.Error(codes.InvalidArgument, "first message must start a terminal")
+	}
+	if req.Command == "" {
+		req.Command = "bash"
+	}
+	if req.Term == "" {
+		req.Term = defaultTerminal.Type
+	}
+	size := req.Size
+	if size == nil {
+		size = &grpcapi.TerminalSize{Rows: defaultTerminal.Rows, Cols: defaultTerminal.Cols}
+	}
+
+	executor, err := g.s.buildExecutor(g.s.executorBuilder, &types.ExecuteOptions{
+		WorkDir: req.WorkDir,
+		Env:     req.Env,
+		TTY:     true,
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to create executor: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	stdinR, stdinW := io.Pipe()
+	defer stdinR.Close()
+	resize := make(chan types.TerminalSize, 1)
+
+	// 处理客户端输入和终端大小调整
+	go func() {
+		defer stdinW.Close()
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			if msg.Resize != nil {
+				select {
+				case resize <- types.TerminalSize{Rows: msg.Resize.Rows, Cols: msg.Resize.Cols}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if len(msg.Input) > 0 {
+				if _, err := stdinW.Write(msg.Input); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	output := &streamWriter{mu: &mu, send: func(p []byte) error {
+		return stream.Send(&grpcapi.TerminalResponse{Output: p})
+	}}
+
+	result, err := executor.Execute(&types.ExecuteContext{
+		Context:     ctx,
+		Interactive: true,
+		Command:     types.Command{Command: req.Command, Args: req.Args},
+		InteractiveOpts: &types.InteractiveOptions{
+			TerminalType: req.Term,
+			Rows:         size.Rows,
+			Cols:         size.Cols,
+			Resize:       resize,
+		},
+		Options: &types.ExecuteOptions{
+			WorkDir: req.WorkDir,
+			Env:     req.Env,
+			TTY:     true,
+			Stdin:   stdinR,
+			Stdout:  output,
+			Stderr:  output,
+		},
+		Executor: executor,
+	})
+	if result == nil {
+		if err == nil {
+			err = errors.New("executor returned no result")
+		}
+		return grpcError(err)
+	}
+
+	exit := execResponse(result)
+	exit.Output = ""
+	mu.Lock()
+	defer mu.Unlock()
+	return stream.Send(&grpcapi.TerminalResponse{Exit: exit})
+}
+
+// ListCommands 列出可用命令
+func (g *grpcService) ListCommands(ctx context.Context, req *grpcapi.Empty) (*grpcapi.CommandList, error) {
+	executor, err := g.s.executorBuilder.Build(nil)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create executor: %v", err)
+	}
+	return &grpcapi.CommandList{Commands: executor.ListCommands()}, nil
+}
+
+// CreateSession 创建会话
+func (g *grpcService) CreateSession(ctx context.Context, req *types.SessionRequest) (*types.SessionResponse, error) {
+	if req.Options == nil {
+		req.Options = &types.ExecuteOptions{}
+	}
+	builder, err := g.s.sessionExecutorBuilder(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	executor, err := g.s.buildExecutor(builder, &types.ExecuteOptions{
+		WorkDir: req.Options.WorkDir,
+		Env:     req.Options.Env,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create executor: %v", err)
+	}
+	session, err := g.s.sessionManager.CreateSession(executor, req.Options)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &types.SessionResponse{Session: session}, nil
+}
+
+// ListSessions 列出会话
+func (g *grpcService) ListSessions(ctx context.Context, req *grpcapi.Empty) (*grpcapi.SessionList, error) {
+	sessions, err := g.s.sessionManager.ListSessions()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &grpcapi.SessionList{Sessions: sessions}, nil
+}
+
+// DeleteSession 删除会话
+func (g *grpcService) DeleteSession(ctx context.Context, req *grpcapi.SessionID) (*grpcapi.Empty, error) {
+	if req.ID == "" {
+		return nil, status.Error(codes.InvalidArgument, "session id is required")
+	}
+	if err := g.s.sessionManager.DeleteSession(req.ID); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	g.s.deleteSessionSnapshots(req.ID)
+	return &grpcapi.Empty{}, nil
+}
+
+// ListScripts 列出脚本
+func (g *grpcService) ListScripts(ctx context.Context, req *grpcapi.Empty) (*grpcapi.CommandList, error) {
+	if g.s.scripts == nil {
+		return &grpcapi.CommandList{Commands: []types.CommandInfo{}}, nil
+	}
+	return &grpcapi.CommandList{Commands: g.s.scripts.ListCommands()}, nil
+}
+
+// RunScript 执行脚本
+func (g *grpcService) RunScript(ctx context.Context, req *grpcapi.ScriptRequest) (*grpcapi.ExecResponse, error) {
+	if g.s.scripts == nil {
+		return nil, status.Error(codes.Unimplemented, "scripts are not enabled")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "script name is required")
+	}
+
+	var outputBuf bytes.Buffer
+	result, err := g.s.scripts.Execute(&types.ExecuteContext{
+		Context: ctx,
+		Command: types.Command{Command: req.Name, Args: req.Args},
+		Options: &types.ExecuteOptions{
+			WorkDir: req.WorkDir,
+			Stdout:  &outputBuf,
+			Stderr:  &outputBuf,
+		},
+	})
+	if result == nil {
+		if err == nil {
+			err = errors.New("script returned no result")
+		}
+		return nil, grpcError(err)
+	}
+
+	resp := execResponse(result)
+	if resp.Output == "" {
+		resp.Output = outputBuf.String()
+	}
+	if err != nil && resp.Error == "" {
+		resp.Error = err.Error()
+	}
+	return resp, nil
+}
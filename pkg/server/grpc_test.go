@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/executor"
+	"github.com/iamlongalong/runshell/pkg/grpcapi"
+	"github.com/iamlongalong/runshell/pkg/policy"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// execStream 通过 Exec 流执行命令，返回标准输出和最终结果
+func execStream(t *testing.T, client grpcapi.RunShellClient, req *grpcapi.ExecRequest, stdin ...string) (string, *grpcapi.ExecResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.Exec(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&grpcapi.ExecStreamRequest{Start: req}))
+	for _, chunk := range stdin {
+		require.NoError(t, stream.Send(&grpcapi.ExecStreamRequest{Stdin: []byte(chunk)}))
+	}
+	require.NoError(t, stream.CloseSend())
+
+	var stdout strings.Builder
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return stdout.String(), nil, err
+		}
+		stdout.Write(msg.Stdout)
+		if msg.Result != nil {
+			return stdout.String(), msg.Result, nil
+		}
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	workDir := t.TempDir()
+	s := NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		UseBuiltinCommands:        true,
+		AllowUnregisteredCommands: true,
+		WorkDir:                   workDir,
+	}), "127.0.0.1:0").
+		WithScripts(&mockScripts{}).
+		WithPolicy(&policy.RulePolicy{Deny: []policy.Rule{{Command: "rm"}}})
+	require.NoError(t, s.Start())
+	defer s.Stop()
+	addr := s.listener.Addr().String()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := grpcapi.NewRunShellClient(conn)
+	ctx := context.Background()
+
+	t.Run("http on same port", func(t *testing.T) {
+		resp, err := http.Get("http://" + addr + "/api/v1/health")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		stdout, result, err := execStream(t, client, &grpcapi.ExecRequest{Command: "sh", Args: []string{"-c", "echo hello; exit 2"}})
+		require.NoError(t, err)
+		assert.Equal(t, "hello\n", stdout)
+		assert.Equal(t, 2, result.ExitCode)
+		assert.NotEmpty(t, result.Error)
+		assert.Empty(t, result.Output)
+	})
+
+	t.Run("exec with stdin", func(t *testing.T) {
+		stdout, result, err := execStream(t, client, &grpcapi.ExecRequest{Command: "sh", Args: []string{"-c", "cat"}, Stdin: true}, "one\n", "two\n")
+		require.NoError(t, err)
+		assert.Equal(t, "one\ntwo\n", stdout)
+		assert.Equal(t, 0, result.ExitCode)
+	})
+
+	t.Run("exec builtin argument error", func(t *testing.T) {
+		_, _, err := execStream(t, client, &grpcapi.ExecRequest{Command: "head", Args: []string{"-n", "x", "a.txt"}})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("sessions", func(t *testing.T) {
+		created, err := client.CreateSession(ctx, &types.SessionRequest{Options: &types.ExecuteOptions{WorkDir: workDir}})
+		require.NoError(t, err)
+		require.NotNil(t, created.Session)
+
+		list, err := client.ListSessions(ctx, &grpcapi.Empty{})
+		require.NoError(t, err)
+		assert.Len(t, list.Sessions, 1)
+
+		stdout, result, err := execStream(t, client, &grpcapi.ExecRequest{Command: "pwd", SessionID: created.Session.ID})
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Contains(t, stdout+result.Output, workDir)
+
+		_, _, err = execStream(t, client, &grpcapi.ExecRequest{Command: "rm", Args: []string{"-rf", "/"}, SessionID: created.Session.ID})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = client.DeleteSession(ctx, &grpcapi.SessionID{ID: created.Session.ID})
+		require.NoError(t, err)
+		_, err = client.DeleteSession(ctx, &grpcapi.SessionID{ID: created.Session.ID})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+
+		_, _, err = execStream(t, client, &grpcapi.ExecRequest{Command: "ls", SessionID: "missing"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("commands and scripts", func(t *testing.T) {
+		commands, err := client.ListCommands(ctx, &grpcapi.Empty{})
+		require.NoError(t, err)
+		assert.NotEmpty(t, commands.Commands)
+
+		scripts, err := client.ListScripts(ctx, &grpcapi.Empty{})
+		require.NoError(t, err)
+		require.Len(t, scripts.Commands, 1)
+
+		result, err := client.RunScript(ctx, &grpcapi.ScriptRequest{Name: scripts.Commands[0].Name, Args: []string{"--env", "prod"}})
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Equal(t, "deployed\n", result.Output)
+	})
+
+	t.Run("terminal", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		stream, err := client.Terminal(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&grpcapi.TerminalRequest{Start: &grpcapi.TerminalStart{
+			Command: "sh",
+			WorkDir: workDir,
+			Size:    &grpcapi.TerminalSize{Rows: 24, Cols: 80},
+		}}))
+		require.NoError(t, stream.Send(&grpcapi.TerminalRequest{Resize: &grpcapi.TerminalSize{Rows: 40, Cols: 100}}))
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, stream.Send(&grpcapi.TerminalRequest{Input: []byte("stty size; exit 3\n")}))
+
+		var output strings.Builder
+		var exit *grpcapi.ExecResponse
+		for exit == nil {
+			msg, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			output.Write(msg.Output)
+			exit = msg.Exit
+		}
+		require.NotNil(t, exit)
+		assert.Equal(t, 3, exit.ExitCode)
+		assert.Contains(t, output.String(), "40 100")
+	})
+}
@@ -0,0 +1,200 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了命令执行请求的幂等键。
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+const (
+	// IdempotencyHeader 幂等键请求头，相同的键在有效期内重复请求时返回第一次请求的响应，不会再次执行命令
+	IdempotencyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader 响应是否为重放的响应头
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// DefaultIdempotencyTTL 默认的幂等键有效期
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	// maxIdempotencyKeyLength 幂等键的最大长度
+	maxIdempotencyKeyLength = 255
+	// commandExecutedKey 标记命令已经执行的 gin context 键，执行过的请求即使失败也会记录响应
+	commandExecutedKey = "runshell.command_executed"
+)
+
+// idempotentResponse 表示幂等键对应的请求及其响应
+type idempotentResponse struct {
+	fingerprint string        // 请求的摘要，相同的键只能用于相同的请求
+	done        chan struct{} // 请求处理结束后关闭
+	recorded    bool          // 是否记录了响应
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// idempotencyStore 在内存中保存幂等键对应的响应
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idempotentResponse
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:     ttl,
+		entries: make(map[string]*idempotentResponse),
+	}
+}
+
+// begin 返回键对应的记录。owner 为 true 时键是新的，调用方负责处理请求并调用 finish；
+// 否则返回已有的记录，调用方需要等待其 done 关闭后读取响应。
+func (st *idempotencyStore) begin(key, fingerprint string) (entry *idempotentResponse, owner bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range st.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(st.entries, k)
+		}
+	}
+
+	if entry, ok := st.entries[key]; ok {
+		return entry, false
+	}
+	entry = &idempotentResponse{fingerprint: fingerprint, done: make(chan struct{})}
+	st.entries[key] = entry
+	return entry, true
+}
+
+// finish 结束请求的处理，record 为 false 时删除记录，之后使用相同键的请求会重新执行
+func (st *idempotencyStore) finish(key string, entry *idempotentResponse, record bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if record {
+		entry.recorded = true
+		entry.expires = time.Now().Add(st.ttl)
+	} else {
+		delete(st.entries, key)
+	}
+	close(entry.done)
+}
+
+// WithIdempotencyTTL 设置幂等键的有效期，小于等于 0 时忽略幂等键
+func (s *Server) WithIdempotencyTTL(ttl time.Duration) *Server {
+	if ttl <= 0 {
+		s.idempotency = nil
+	} else {
+		s.idempotency = newIdempotencyStore(ttl)
+	}
+	return s
+}
+
+// idempotent 处理命令执行请求的幂等键。
+// 带有幂等键的请求第一次执行时记录响应，之后相同键的请求直接返回记录的响应；
+// 相同键的请求正在执行时等待其结束。命令没有执行且服务端出错，或者客户端断开连接时不记录响应。
+func (s *Server) idempotent(c *gin.Context) {
+	key := c.GetHeader(IdempotencyHeader)
+	if key == "" || s.idempotency == nil {
+		c.Next()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		s.handleError(c, http.StatusBadRequest, fmt.Errorf("idempotency key is longer than %d characters", maxIdempotencyKeyLength), "")
+		c.Abort()
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		s.handleError(c, http.StatusBadRequest, err, "Failed to read request body")
+		c.Abort()
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	// 键的作用域为请求路径，摘要包含查询参数和请求体
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n", c.Request.URL.RawQuery)
+	hash.Write(body)
+	fingerprint := hex.EncodeToString(hash.Sum(nil))
+	scopedKey := c.Request.URL.Path + "\n" + key
+
+	var entry *idempotentResponse
+	for {
+		var owner bool
+		entry, owner = s.idempotency.begin(scopedKey, fingerprint)
+		if entry.fingerprint != fingerprint {
+			s.handleError(c, http.StatusUnprocessableEntity, fmt.Errorf("idempotency key %s was used for a different request", key), "")
+			c.Abort()
+			return
+		}
+		if owner {
+			break
+		}
+
+		select {
+		case <-entry.done:
+		case <-c.Request.Context().Done():
+			c.Abort()
+			return
+		}
+		if entry.recorded {
+			log.Debug("Replaying response for idempotency key %s", key)
+			for k, v := range entry.header {
+				c.Writer.Header()[k] = v
+			}
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(entry.status, entry.header.Get("Content-Type"), entry.body)
+			c.Abort()
+			return
+		}
+		// 之前的请求没有记录响应，重新执行
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+
+	status := recorder.Status()
+	record := c.Request.Context().Err() == nil && (status < http.StatusInternalServerError || c.GetBool(commandExecutedKey))
+	if record {
+		entry.status = status
+		entry.header = recorder.Header().Clone()
+		entry.body = recorder.body.Bytes()
+	}
+	s.idempotency.finish(scopedKey, entry, record)
+}
+
+// markExecuted 在命令已经执行时标记请求，幂等键会记录其响应
+func markExecuted(c *gin.Context, result *types.ExecuteResult) {
+	if result != nil {
+		c.Set(commandExecutedKey, true)
+	}
+}
+
+// responseRecorder 在写入响应的同时记录响应体
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -56,7 +57,9 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// handleInteractiveExec 处理交互命令执行
+// handleInteractiveExec 处理交互命令执行。
+// 查询参数 command、args、workdir、rows、cols 指定要运行的命令和终端大小；
+// 二进制或文本消息作为输入，resize 类型的 JSON 消息调整终端大小，命令结束后以 JSON 返回执行结果。
 func (s *Server) handleInteractiveExec(c *gin.Context) {
 	// 升级到 WebSocket 连接
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -66,12 +69,22 @@ func (s *Server) handleInteractiveExec(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	// 读取初始请求
-	var req InteractiveRequest // 直接运行 bash
-	// if err := conn.ReadJSON(&req); err != nil {
-	// 	s.handleWSError(conn, fmt.Errorf("failed to read request: %w", err))
-	// 	return
-	// }
+	// 读取初始请求，参数通过查询字符串传递，未指定时直接运行 bash
+	req := InteractiveRequest{
+		Command: c.Query("command"),
+		Args:    c.QueryArray("args"),
+		WorkDir: c.Query("workdir"),
+	}
+	if rows, cols := c.Query("rows"), c.Query("cols"); rows != "" || cols != "" {
+		terminal := *defaultTerminal
+		if n, err := strconv.ParseUint(rows, 10, 16); err == nil && n > 0 {
+			terminal.Rows = uint16(n)
+		}
+		if n, err := strconv.ParseUint(cols, 10, 16); err == nil && n > 0 {
+			terminal.Cols = uint16(n)
+		}
+		req.Terminal = &terminal
+	}
 
 	log.Info("Received interactive request: %+v", req)
 
@@ -86,7 +99,7 @@ func (s *Server) handleInteractiveExec(c *gin.Context) {
 	}
 
 	// 创建执行器
-	executor, err := s.executorBuilder.Build(&types.ExecuteOptions{
+	executor, err := s.buildExecutor(s.executorBuilder, &types.ExecuteOptions{
 		WorkDir: req.WorkDir,
 		Env:     req.Env,
 		TTY:     true,
@@ -103,6 +116,9 @@ func (s *Server) handleInteractiveExec(c *gin.Context) {
 	// 创建错误通道
 	errCh := make(chan error, 2)
 
+	// 终端大小调整
+	resize := make(chan types.TerminalSize, 1)
+
 	// 创建交互式上下文
 	ctx := &types.ExecuteContext{
 		Context:     c.Request.Context(),
@@ -116,6 +132,7 @@ func (s *Server) handleInteractiveExec(c *gin.Context) {
 			Rows:         req.Terminal.Rows,
 			Cols:         req.Terminal.Cols,
 			Raw:          req.Terminal.Raw,
+			Resize:       resize,
 		},
 		Options: &types.ExecuteOptions{
 			WorkDir: req.WorkDir,
@@ -144,6 +161,16 @@ func (s *Server) handleInteractiveExec(c *gin.Context) {
 
 			fmt.Printf("Input received: messageType=%d, data=%q\n", messageType, message)
 
+			// 终端大小调整消息
+			if size, ok := parseResize(messageType, message); ok {
+				select {
+				case resize <- size:
+				case <-c.Request.Context().Done():
+					return
+				}
+				continue
+			}
+
 			// 确保命令以换行符结束
 			if len(message) > 0 && !bytes.HasSuffix(message, []byte("\n")) {
 				message = append(message, '\n')
@@ -161,7 +188,9 @@ func (s *Server) handleInteractiveExec(c *gin.Context) {
 	}()
 
 	// 处理命令输出
+	outputDone := make(chan struct{})
 	go func() {
+		defer close(outputDone)
 		defer stdoutR.Close()
 
 		buffer := make([]byte, 32*1024)
@@ -192,21 +221,44 @@ func (s *Server) handleInteractiveExec(c *gin.Context) {
 
 	// 执行命令
 	result, err := executor.Execute(ctx)
-	if err != nil {
+	// 等待剩余输出发送完毕，保证结果在所有输出之后发送，且不与输出并发写入连接
+	stdoutW.Close()
+	<-outputDone
+	if err != nil && result == nil {
 		s.handleWSError(conn, fmt.Errorf("command execution failed: %w", err))
 		return
 	}
 
-	// 发送执行结果
-	if err := conn.WriteJSON(ExecResponse{
+	// 发送执行结果，命令以非零状态退出时同时返回错误信息
+	response := ExecResponse{
 		ExitCode: result.ExitCode,
 		Output:   result.Output,
-	}); err != nil {
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	if err := conn.WriteJSON(response); err != nil {
 		s.handleWSError(conn, fmt.Errorf("failed to send result: %w", err))
 		return
 	}
 }
 
+// parseResize 解析终端大小调整消息，格式为 {"type":"resize","payload":{"rows":40,"cols":120}}
+func parseResize(messageType int, message []byte) (types.TerminalSize, bool) {
+	if messageType != websocket.TextMessage || !bytes.HasPrefix(bytes.TrimSpace(message), []byte("{")) {
+		return types.TerminalSize{}, false
+	}
+	var msg WSMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Type != "resize" {
+		return types.TerminalSize{}, false
+	}
+	var size ResizeMessage
+	if err := json.Unmarshal(msg.Payload, &size); err != nil || size.Rows == 0 || size.Cols == 0 {
+		return types.TerminalSize{}, false
+	}
+	return types.TerminalSize{Rows: size.Rows, Cols: size.Cols}, true
+}
+
 // handleWSError 处理 WebSocket 错误
 func (s *Server) handleWSError(conn *websocket.Conn, err error) {
 	log.Error("WebSocket error: %v", err)
@@ -0,0 +1,664 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了 MCP（Model Context Protocol）服务的工具和资源。
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/iamlongalong/runshell/pkg/fs"
+	"github.com/iamlongalong/runshell/pkg/mcp"
+	"github.com/iamlongalong/runshell/pkg/tools"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// MCP 资源 URI
+const (
+	sessionResourcePrefix = "runshell://sessions/"
+	auditResourceURI      = "runshell://audit"
+)
+
+// 资源列表的限制
+const (
+	maxListedFiles    = 100 // 每个会话列出的最大文件数
+	maxListedDepth    = 3   // 列出文件的最大目录深度
+	maxAuditEntries   = 200 // 审计资源返回的最大记录数
+	binarySniffLength = 8000
+)
+
+// errListLimit 列出的文件数量达到上限
+var errListLimit = errors.New("list limit reached")
+
+// AuditLog 读取审计记录，通常为 audit.FileAuditor
+type AuditLog interface {
+	Entries(limit int) ([]string, error)
+}
+
+// WithAuditLog 设置审计记录来源，审计记录会作为 MCP 资源导出
+func (s *Server) WithAuditLog(auditLog AuditLog) *Server {
+	s.auditLog = auditLog
+	return s
+}
+
+// MCP 返回 MCP 服务端，可用于 stdio 传输
+func (s *Server) MCP() *mcp.Server {
+	return s.mcp
+}
+
+// newMCPServer 创建 MCP 服务端，工具与 HTTP 接口共用执行器构建器、会话管理器和执行策略
+func (s *Server) newMCPServer() *mcp.Server {
+	server := mcp.NewServer("runshell", "1.0")
+	server.WithResources(&mcpResources{s: s})
+
+	execProps := map[string]interface{}{
+		"command":    map[string]interface{}{"type": "string", "description": "Command to execute"},
+		"args":       stringArray("Command arguments"),
+		"workdir":    map[string]interface{}{"type": "string", "description": "Working directory"},
+		"env":        map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}, "description": "Environment variables, ignored in a session"},
+		"session_id": sessionProperty(),
+	}
+	server.AddTool(&mcp.Tool{
+		Name:        "exec",
+		Description: "Execute a command and return its output. Commands are checked against the execution policy",
+		InputSchema: objectSchema(execProps, "command"),
+		Handler:     s.mcpExec,
+	})
+
+	server.AddTool(&mcp.Tool{
+		Name:        "read_file",
+		Description: "Read a file, optionally limited to a line range",
+		InputSchema: objectSchema(map[string]interface{}{
+			"path":         map[string]interface{}{"type": "string", "description": "File path relative to the working directory"},
+			"start_line":   map[string]interface{}{"type": "integer", "description": "First line to return (1-based)"},
+			"end_line":     map[string]interface{}{"type": "integer", "description": "Last line to return (inclusive)"},
+			"line_numbers": map[string]interface{}{"type": "boolean", "description": "Prefix lines with line numbers"},
+			"workdir":      execProps["workdir"],
+			"session_id":   sessionProperty(),
+		}, "path"),
+		Handler: s.mcpReadFile,
+	})
+
+	server.AddTool(&mcp.Tool{
+		Name:        "write_file",
+		Description: "Atomically create or overwrite a file",
+		InputSchema: objectSchema(map[string]interface{}{
+			"path":       map[string]interface{}{"type": "string", "description": "File path relative to the working directory"},
+			"content":    map[string]interface{}{"type": "string", "description": "File content"},
+			"parents":    map[string]interface{}{"type": "boolean", "description": "Create parent directories as needed"},
+			"workdir":    execProps["workdir"],
+			"session_id": sessionProperty(),
+		}, "path", "content"),
+		Handler: s.mcpWriteFile,
+	})
+
+	server.AddTool(&mcp.Tool{
+		Name:        "create_session",
+		Description: "Create a session that keeps its working directory and environment across commands",
+		InputSchema: objectSchema(map[string]interface{}{
+			"workdir": execProps["workdir"],
+			"env":     map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}, "description": "Environment variables"},
+		}),
+		Handler: s.mcpCreateSession,
+	})
+
+	server.AddTool(&mcp.Tool{
+		Name:        "list_sessions",
+		Description: "List active sessions",
+		InputSchema: objectSchema(map[string]interface{}{}),
+		Handler:     s.mcpListSessions,
+	})
+
+	server.AddTool(&mcp.Tool{
+		Name:        "delete_session",
+		Description: "Delete a session",
+		InputSchema: objectSchema(map[string]interface{}{"session_id": sessionProperty()}, "session_id"),
+		Handler:     s.mcpDeleteSession,
+	})
+
+	server.AddTool(&mcp.Tool{
+		Name:        "run_script",
+		Description: "Run a script from the script directory. Use list_scripts to see available scripts and their arguments",
+		InputSchema: objectSchema(map[string]interface{}{
+			"name": map[string]interface{}{"type": "string", "description": "Script name"},
+			"args": map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}, "description": "Script arguments keyed by argument name"},
+		}, "name"),
+		Handler: s.mcpRunScript,
+	})
+
+	server.AddTool(&mcp.Tool{
+		Name:        "list_scripts",
+		Description: "List available scripts with their argument schemas",
+		InputSchema: objectSchema(map[string]interface{}{}),
+		Handler:     s.mcpListScripts,
+	})
+
+	return server
+}
+
+// objectSchema 创建对象类型的 JSON Schema
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	if required == nil {
+		required = []string{}
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// stringArray 创建字符串数组类型的 JSON Schema
+func stringArray(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": description}
+}
+
+// sessionProperty 返回会话 ID 参数的 JSON Schema
+func sessionProperty() map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": "Run in this session instead of a new executor"}
+}
+
+// mcpRun 执行命令并转换为工具结果，指定会话时在会话中执行
+func (s *Server) mcpRun(ctx context.Context, cmd types.Command, workDir, sessionID string, env map[string]string) (*mcp.ToolResult, error) {
+	var outputBuf bytes.Buffer
+	opts := &types.ExecuteOptions{
+		WorkDir: workDir,
+		Env:     env,
+		Stdout:  &outputBuf,
+		Stderr:  &outputBuf,
+	}
+
+	var result *types.ExecuteResult
+	var err error
+	if sessionID != "" {
+		session, getErr := s.sessionManager.GetSession(sessionID)
+		if getErr != nil {
+			return nil, getErr
+		}
+		opts.Env = nil
+		if session.Options != nil && session.Options.Env != nil {
+			opts.Env = session.Options.Env
+		}
+		result, _, err = s.executeInSession(ctx, session, cmd, opts)
+	} else {
+		result, err = s.execute(ctx, cmd, opts)
+	}
+	if result == nil {
+		return nil, err
+	}
+	if result.Output == "" {
+		result.Output = outputBuf.String()
+	}
+	return execToolResult(result, err), nil
+}
+
+// execToolResult 将执行结果转换为工具结果
+func execToolResult(result *types.ExecuteResult, err error) *mcp.ToolResult {
+	response := ExecResponse{
+		ExitCode: result.ExitCode,
+		Output:   result.Output,
+		Changes:  result.Changes,
+		Data:     result.Data,
+	}
+	if err == nil {
+		err = result.Error
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+
+	out := tools.Output{ExitCode: response.ExitCode, Output: response.Output, Error: response.Error}
+	return &mcp.ToolResult{
+		Content:           []mcp.Content{mcp.TextContent(out.Content())},
+		StructuredContent: response,
+		IsError:           response.ExitCode != 0 || response.Error != "",
+	}
+}
+
+// jsonToolResult 以 JSON 文本和结构化内容返回数据
+func jsonToolResult(v interface{}) (*mcp.ToolResult, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.ToolResult{
+		Content:           []mcp.Content{mcp.TextContent(string(data))},
+		StructuredContent: map[string]interface{}{"result": v},
+	}, nil
+}
+
+// mcpExec 实现 exec 工具
+func (s *Server) mcpExec(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+	command, err := stringArg(args, "command", true)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs, err := stringsArg(args, "args")
+	if err != nil {
+		return nil, err
+	}
+	env, err := stringMapArg(args, "env")
+	if err != nil {
+		return nil, err
+	}
+	workDir, _ := stringArg(args, "workdir", false)
+	sessionID, _ := stringArg(args, "session_id", false)
+
+	return s.mcpRun(ctx, types.Command{Command: command, Args: cmdArgs}, workDir, sessionID, env)
+}
+
+// mcpReadFile 实现 read_file 工具，通过内置 readfile 命令读取
+func (s *Server) mcpReadFile(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+	path, err := stringArg(args, "path", true)
+	if err != nil {
+		return nil, err
+	}
+	var cmdArgs []string
+	if numbered, _ := args["line_numbers"].(bool); numbered {
+		cmdArgs = append(cmdArgs, "-n")
+	}
+	cmdArgs = append(cmdArgs, path)
+
+	start, err := stringArg(args, "start_line", false)
+	if err != nil {
+		return nil, err
+	}
+	end, err := stringArg(args, "end_line", false)
+	if err != nil {
+		return nil, err
+	}
+	if end != "" && start == "" {
+		start = "1"
+	}
+	if start != "" {
+		cmdArgs = append(cmdArgs, start)
+	}
+	if end != "" {
+		cmdArgs = append(cmdArgs, end)
+	}
+
+	workDir, _ := stringArg(args, "workdir", false)
+	sessionID, _ := stringArg(args, "session_id", false)
+	return s.mcpRun(ctx, types.Command{Command: "readfile", Args: cmdArgs}, workDir, sessionID, nil)
+}
+
+// mcpWriteFile 实现 write_file 工具，通过内置 write 命令写入
+func (s *Server) mcpWriteFile(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+	path, err := stringArg(args, "path", true)
+	if err != nil {
+		return nil, err
+	}
+	content, ok := args["content"].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument content must be a string")
+	}
+	var cmdArgs []string
+	if parents, _ := args["parents"].(bool); parents {
+		cmdArgs = append(cmdArgs, "-p")
+	}
+	cmdArgs = append(cmdArgs, path, content)
+
+	workDir, _ := stringArg(args, "workdir", false)
+	sessionID, _ := stringArg(args, "session_id", false)
+	return s.mcpRun(ctx, types.Command{Command: "write", Args: cmdArgs}, workDir, sessionID, nil)
+}
+
+// mcpCreateSession 实现 create_session 工具
+func (s *Server) mcpCreateSession(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+	workDir, err := stringArg(args, "workdir", false)
+	if err != nil {
+		return nil, err
+	}
+	env, err := stringMapArg(args, "env")
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &types.ExecuteOptions{WorkDir: workDir, Env: env}
+	executor, err := s.buildExecutor(s.executorBuilder, &types.ExecuteOptions{WorkDir: workDir, Env: env})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create executor: %w", err)
+	}
+	session, err := s.sessionManager.CreateSession(executor, opts)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToolResult(session)
+}
+
+// mcpListSessions 实现 list_sessions 工具
+func (s *Server) mcpListSessions(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+	sessions, err := s.sessionManager.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []*types.Session{}
+	}
+	return jsonToolResult(sessions)
+}
+
+// mcpDeleteSession 实现 delete_session 工具
+func (s *Server) mcpDeleteSession(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+	sessionID, err := stringArg(args, "session_id", true)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessionManager.DeleteSession(sessionID); err != nil {
+		return nil, err
+	}
+	s.deleteSessionSnapshots(sessionID)
+	return &mcp.ToolResult{Content: []mcp.Content{mcp.TextContent("session " + sessionID + " deleted")}}, nil
+}
+
+// mcpRunScript 实现 run_script 工具，参数按脚本声明的选项转换
+func (s *Server) mcpRunScript(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+	if s.scripts == nil {
+		return nil, fmt.Errorf("scripts are not enabled")
+	}
+	name, err := stringArg(args, "name", true)
+	if err != nil {
+		return nil, err
+	}
+	scriptArgs, _ := args["args"].(map[string]interface{})
+	if scriptArgs == nil && args["args"] != nil {
+		return nil, fmt.Errorf("argument args must be an object")
+	}
+
+	var tool *tools.Tool
+	for _, info := range s.scripts.ListCommands() {
+		if info.Name == name {
+			tool = tools.NewRegistry(nil, []types.CommandInfo{info}).Tools()[0]
+			break
+		}
+	}
+	if tool == nil {
+		return nil, fmt.Errorf("script not found: %s", name)
+	}
+	cmd, err := tool.Command(scriptArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var outputBuf bytes.Buffer
+	result, err := s.scripts.Execute(&types.ExecuteContext{
+		Context: ctx,
+		Command: cmd,
+		Options: &types.ExecuteOptions{Stdout: &outputBuf, Stderr: &outputBuf},
+	})
+	if result == nil {
+		return nil, err
+	}
+	if result.Output == "" {
+		result.Output = outputBuf.String()
+	}
+	return execToolResult(result, err), nil
+}
+
+// mcpListScripts 实现 list_scripts 工具
+func (s *Server) mcpListScripts(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+	scripts := []types.CommandInfo{}
+	if s.scripts != nil {
+		scripts = append(scripts, s.scripts.ListCommands()...)
+	}
+	return jsonToolResult(scripts)
+}
+
+// stringArg 读取字符串参数，数字会转换为字符串
+func stringArg(args map[string]interface{}, name string, required bool) (string, error) {
+	switch v := args[name].(type) {
+	case nil:
+		if required {
+			return "", fmt.Errorf("argument %s is required", name)
+		}
+		return "", nil
+	case string:
+		if required && v == "" {
+			return "", fmt.Errorf("argument %s is required", name)
+		}
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("argument %s must be a string", name)
+	}
+}
+
+// stringsArg 读取字符串数组参数
+func stringsArg(args map[string]interface{}, name string) ([]string, error) {
+	if args[name] == nil {
+		return nil, nil
+	}
+	items, ok := args[name].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("argument %s must be an array", name)
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			values = append(values, v)
+		case json.Number:
+			values = append(values, v.String())
+		case bool:
+			values = append(values, strconv.FormatBool(v))
+		default:
+			return nil, fmt.Errorf("argument %s must contain only strings", name)
+		}
+	}
+	return values, nil
+}
+
+// stringMapArg 读取字符串映射参数
+func stringMapArg(args map[string]interface{}, name string) (map[string]string, error) {
+	if args[name] == nil {
+		return nil, nil
+	}
+	items, ok := args[name].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("argument %s must be an object", name)
+	}
+	values := make(map[string]string, len(items))
+	for k, item := range items {
+		v, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("argument %s.%s must be a string", name, k)
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
+// mcpResources 将会话工作目录中的文件和审计记录导出为 MCP 资源
+type mcpResources struct {
+	s *Server
+}
+
+// ResourceTemplates 实现 mcp.ResourceProvider 接口
+func (r *mcpResources) ResourceTemplates() []mcp.ResourceTemplate {
+	return []mcp.ResourceTemplate{{
+		URITemplate: sessionResourcePrefix + "{session_id}/files/{path}",
+		Name:        "Session file",
+		Description: "A file or directory in a session's working directory",
+	}}
+}
+
+// ListResources 实现 mcp.ResourceProvider 接口，每个会话最多列出 maxListedFiles 个文件
+func (r *mcpResources) ListResources(ctx context.Context) ([]mcp.Resource, error) {
+	var resources []mcp.Resource
+	if r.s.auditLog != nil {
+		resources = append(resources, mcp.Resource{
+			URI:         auditResourceURI,
+			Name:        "Audit log",
+			Description: fmt.Sprintf("The latest %d command audit entries", maxAuditEntries),
+			MimeType:    "text/plain",
+		})
+	}
+
+	sessions, err := r.s.sessionManager.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		provider, ok := types.As[types.FileSystemProvider](session.Executor)
+		if !ok {
+			continue
+		}
+		fsys, err := provider.FileSystem(sessionWorkDir(session))
+		if err != nil {
+			continue
+		}
+
+		count := 0
+		err = fs.Walk(ctx, fsys, ".", func(name string, info *types.FileInfo, depth int, err error) error {
+			if err != nil || depth == 0 {
+				return nil
+			}
+			if strings.HasPrefix(info.Name, ".") {
+				if info.IsDir {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir {
+				if depth >= maxListedDepth {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if count >= maxListedFiles {
+				return errListLimit
+			}
+			count++
+			name = strings.TrimPrefix(name, "./")
+			resources = append(resources, mcp.Resource{
+				URI:      sessionFileURI(session.ID, name),
+				Name:     name,
+				MimeType: mime.TypeByExtension(path.Ext(name)),
+			})
+			return nil
+		})
+		if err != nil && err != errListLimit {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+// ReadResource 实现 mcp.ResourceProvider 接口
+func (r *mcpResources) ReadResource(ctx context.Context, uri string) ([]mcp.ResourceContents, error) {
+	if uri == auditResourceURI {
+		if r.s.auditLog == nil {
+			return nil, fmt.Errorf("audit log is not enabled")
+		}
+		entries, err := r.s.auditLog.Entries(maxAuditEntries)
+		if err != nil {
+			return nil, err
+		}
+		text := strings.Join(entries, "\n")
+		if text != "" {
+			text += "\n"
+		}
+		return []mcp.ResourceContents{{URI: uri, MimeType: "text/plain", Text: text}}, nil
+	}
+
+	sessionID, name, ok := parseSessionFileURI(uri)
+	if !ok {
+		return nil, fmt.Errorf("resource not found: %s", uri)
+	}
+	session, err := r.s.sessionManager.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := types.As[types.FileSystemProvider](session.Executor)
+	if !ok {
+		return nil, fmt.Errorf("executor %s does not support file access", session.Executor.Name())
+	}
+	fsys, err := provider.FileSystem(sessionWorkDir(session))
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := fsys.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir {
+		entries, err := fsys.ReadDir(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		for _, entry := range entries {
+			sb.WriteString(entry.Name)
+			if entry.IsDir {
+				sb.WriteString("/")
+			}
+			sb.WriteString("\n")
+		}
+		return []mcp.ResourceContents{{URI: uri, MimeType: "text/plain", Text: sb.String()}}, nil
+	}
+
+	data, err := fsys.ReadFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	mimeType := mime.TypeByExtension(path.Ext(name))
+	sniff := data
+	if len(sniff) > binarySniffLength {
+		sniff = sniff[:binarySniffLength]
+	}
+	if bytes.IndexByte(sniff, 0) >= 0 || !utf8.Valid(data) {
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		return []mcp.ResourceContents{{URI: uri, MimeType: mimeType, Blob: base64.StdEncoding.EncodeToString(data)}}, nil
+	}
+	if mimeType == "" {
+		mimeType = "text/plain"
+	}
+	return []mcp.ResourceContents{{URI: uri, MimeType: mimeType, Text: string(data)}}, nil
+}
+
+// sessionFileURI 返回会话文件的资源 URI，路径中的每一段都会转义
+func sessionFileURI(sessionID, name string) string {
+	segments := strings.Split(name, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return sessionResourcePrefix + url.PathEscape(sessionID) + "/files/" + strings.Join(segments, "/")
+}
+
+// parseSessionFileURI 解析会话文件的资源 URI，路径为空时表示工作目录本身
+func parseSessionFileURI(uri string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(uri, sessionResourcePrefix)
+	if !ok {
+		return "", "", false
+	}
+	sessionID, name, ok := strings.Cut(rest, "/files")
+	if !ok || (name != "" && name[0] != '/') {
+		return "", "", false
+	}
+	sessionID, err := url.PathUnescape(sessionID)
+	if err != nil {
+		return "", "", false
+	}
+	name, err = url.PathUnescape(strings.TrimPrefix(name, "/"))
+	if err != nil {
+		return "", "", false
+	}
+	if name == "" {
+		name = "."
+	}
+	return sessionID, name, true
+}
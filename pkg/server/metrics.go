@@ -0,0 +1,38 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了命令执行统计的查询接口。
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/executor"
+)
+
+// MetricsResponse 表示命令执行统计的响应
+// swagger:model
+type MetricsResponse struct {
+	Commands map[string]executor.CommandMetrics `json:"commands"` // 按命令名称汇总的执行统计
+}
+
+// WithMetrics 通过 /metrics 返回执行统计，metrics 由执行器链中的 executor.MetricsMiddleware 记录
+func (s *Server) WithMetrics(metrics *executor.Metrics) *Server {
+	s.metrics = metrics
+	return s
+}
+
+// @Summary     Get Metrics
+// @Description Get per-command execution counts, failures, durations and result cache hits
+// @Tags        metrics
+// @Produce     json
+// @Success     200 {object} MetricsResponse
+// @Failure     501 {object} ErrorResponse
+// @Router      /metrics [get]
+func (s *Server) handleMetrics(c *gin.Context) {
+	if s.metrics == nil {
+		s.handleError(c, http.StatusNotImplemented, fmt.Errorf("metrics are not enabled"), "")
+		return
+	}
+	c.JSON(http.StatusOK, MetricsResponse{Commands: s.metrics.Snapshot()})
+}
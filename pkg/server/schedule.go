@@ -0,0 +1,274 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了计划任务相关的处理函数。
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/executor"
+	"github.com/iamlongalong/runshell/pkg/schedule"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/iamlongalong/runshell/pkg/workflow"
+)
+
+// WithScheduleStore 设置保存计划任务的存储，默认保存在内存中。需在 Start 之前调用
+func (s *Server) WithScheduleStore(store schedule.Store) *Server {
+	s.scheduler = schedule.NewScheduler(store, &scheduleDispatcher{server: s})
+	return s
+}
+
+// scheduleDispatcher 在服务器的执行器上执行计划任务
+type scheduleDispatcher struct {
+	server *Server
+}
+
+// Dispatch 实现 schedule.Dispatcher 接口。
+// 命令和工作流的步骤需通过执行策略检查，脚本由脚本管理器执行。
+func (d *scheduleDispatcher) Dispatch(ctx context.Context, target *schedule.Target, output io.Writer) (int, error) {
+	s := d.server
+	options := &types.ExecuteOptions{WorkDir: target.WorkDir, Env: target.Env}
+
+	if target.Script != "" {
+		if s.scripts == nil {
+			return -1, fmt.Errorf("scripts are not enabled")
+		}
+		options.Stdout, options.Stderr = output, output
+		result, err := s.scripts.Execute(&types.ExecuteContext{
+			Context: ctx,
+			Command: types.Command{Command: target.Script, Args: target.Args},
+			Options: options,
+		})
+		if result == nil {
+			return -1, err
+		}
+		return result.ExitCode, err
+	}
+
+	builder, err := s.sessionExecutorBuilder(&types.SessionRequest{ExecutorType: target.ExecutorType, Remote: target.Remote})
+	if err != nil {
+		return -1, err
+	}
+	exec, err := s.buildExecutor(builder, &types.ExecuteOptions{WorkDir: target.WorkDir, Env: target.Env})
+	if err != nil {
+		return -1, fmt.Errorf("failed to create executor: %w", err)
+	}
+	defer exec.Close()
+
+	if target.Workflow != nil {
+		run, err := workflow.NewRunner(exec).WithStore(s.workflows).WithOptions(options).Run(ctx, target.Workflow, func(event *workflow.Event) {
+			io.WriteString(output, event.Stdout+event.Stderr)
+		})
+		if err != nil {
+			return -1, err
+		}
+		fmt.Fprintf(output, "workflow run %s %s\n", run.ID, run.Status)
+		if run.Status != workflow.StatusSucceeded {
+			return 1, fmt.Errorf("workflow run %s %s", run.ID, run.Status)
+		}
+		return 0, nil
+	}
+
+	options.Stdout, options.Stderr = output, output
+	result, err := executor.NewPipelineExecutor(exec).Execute(&types.ExecuteContext{
+		Context:  ctx,
+		Command:  types.Command{Command: target.Command, Args: target.Args},
+		Options:  options,
+		Executor: exec,
+	})
+	if result == nil {
+		return -1, err
+	}
+	return result.ExitCode, err
+}
+
+// scheduleStatus 返回计划任务操作失败时的 HTTP 状态码
+func scheduleStatus(err error) int {
+	switch {
+	case errors.Is(err, schedule.ErrScheduleNotFound), errors.Is(err, schedule.ErrRunNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, schedule.ErrScheduleExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// bindSchedule 解析并校验计划任务的定义，执行目标需在服务器上可用
+func (s *Server) bindSchedule(c *gin.Context) (*schedule.Schedule, bool) {
+	var sc schedule.Schedule
+	if err := c.ShouldBindJSON(&sc); err != nil {
+		s.handleError(c, http.StatusBadRequest, err, "Invalid request format")
+		return nil, false
+	}
+	if err := sc.Validate(); err != nil {
+		s.handleError(c, http.StatusBadRequest, err, "")
+		return nil, false
+	}
+	if sc.Target.Script != "" && s.scripts == nil {
+		s.handleError(c, http.StatusBadRequest, fmt.Errorf("scripts are not enabled"), "")
+		return nil, false
+	}
+	if _, err := s.sessionExecutorBuilder(&types.SessionRequest{ExecutorType: sc.Target.ExecutorType, Remote: sc.Target.Remote}); err != nil {
+		s.handleError(c, http.StatusBadRequest, err, "")
+		return nil, false
+	}
+	return &sc, true
+}
+
+// @Summary     List Schedules
+// @Description List all schedules with their last and next run times
+// @Tags        schedules
+// @Produce     json
+// @Success     200 {array} schedule.Schedule
+// @Failure     500 {object} ErrorResponse
+// @Router      /schedules [get]
+func (s *Server) handleListSchedules(c *gin.Context) {
+	schedules, err := s.scheduler.List()
+	if err != nil {
+		s.handleError(c, http.StatusInternalServerError, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+// @Summary     Create Schedule
+// @Description Create a schedule that runs a command, script or workflow on a cron expression
+// @Tags        schedules
+// @Accept      json
+// @Produce     json
+// @Param       request body schedule.Schedule true "Schedule definition"
+// @Success     201 {object} schedule.Schedule
+// @Failure     400 {object} ErrorResponse
+// @Failure     409 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /schedules [post]
+func (s *Server) handleCreateSchedule(c *gin.Context) {
+	sc, ok := s.bindSchedule(c)
+	if !ok {
+		return
+	}
+	created, err := s.scheduler.Create(sc)
+	if err != nil {
+		s.handleError(c, scheduleStatus(err), err, "")
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// @Summary     Get Schedule
+// @Description Get a schedule with its last and next run times
+// @Tags        schedules
+// @Produce     json
+// @Param       id path string true "Schedule ID"
+// @Success     200 {object} schedule.Schedule
+// @Failure     404 {object} ErrorResponse
+// @Router      /schedules/{id} [get]
+func (s *Server) handleGetSchedule(c *gin.Context) {
+	sc, err := s.scheduler.Get(c.Param("id"))
+	if err != nil {
+		s.handleError(c, scheduleStatus(err), err, "")
+		return
+	}
+	c.JSON(http.StatusOK, sc)
+}
+
+// @Summary     Update Schedule
+// @Description Replace the definition of a schedule, a run in progress is not affected
+// @Tags        schedules
+// @Accept      json
+// @Produce     json
+// @Param       id path string true "Schedule ID"
+// @Param       request body schedule.Schedule true "Schedule definition"
+// @Success     200 {object} schedule.Schedule
+// @Failure     400 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /schedules/{id} [put]
+func (s *Server) handleUpdateSchedule(c *gin.Context) {
+	sc, ok := s.bindSchedule(c)
+	if !ok {
+		return
+	}
+	updated, err := s.scheduler.Update(c.Param("id"), sc)
+	if err != nil {
+		s.handleError(c, scheduleStatus(err), err, "")
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// @Summary     Delete Schedule
+// @Description Delete a schedule and its run history, a run in progress is killed
+// @Tags        schedules
+// @Param       id path string true "Schedule ID"
+// @Success     204
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /schedules/{id} [delete]
+func (s *Server) handleDeleteSchedule(c *gin.Context) {
+	if err := s.scheduler.Delete(c.Param("id")); err != nil {
+		s.handleError(c, scheduleStatus(err), err, "")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary     Trigger Schedule
+// @Description Start a run of the schedule now, subject to its overlap policy
+// @Tags        schedules
+// @Produce     json
+// @Param       id path string true "Schedule ID"
+// @Success     202 {object} schedule.Run
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /schedules/{id}/trigger [post]
+func (s *Server) handleTriggerSchedule(c *gin.Context) {
+	run, err := s.scheduler.Trigger(c.Param("id"))
+	if err != nil {
+		s.handleError(c, scheduleStatus(err), err, "")
+		return
+	}
+	c.JSON(http.StatusAccepted, run)
+}
+
+// @Summary     List Schedule Runs
+// @Description List the run history of a schedule, newest first
+// @Tags        schedules
+// @Produce     json
+// @Param       id path string true "Schedule ID"
+// @Success     200 {array} schedule.Run
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /schedules/{id}/runs [get]
+func (s *Server) handleListScheduleRuns(c *gin.Context) {
+	runs, err := s.scheduler.Runs(c.Param("id"))
+	if err != nil {
+		s.handleError(c, scheduleStatus(err), err, "")
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// @Summary     Get Schedule Run
+// @Description Get a run of a schedule, including its output
+// @Tags        schedules
+// @Produce     json
+// @Param       id path string true "Schedule ID"
+// @Param       rid path string true "Run ID"
+// @Success     200 {object} schedule.Run
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /schedules/{id}/runs/{rid} [get]
+func (s *Server) handleGetScheduleRun(c *gin.Context) {
+	run, err := s.scheduler.GetRun(c.Param("id"), c.Param("rid"))
+	if err != nil {
+		s.handleError(c, scheduleStatus(err), err, "")
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
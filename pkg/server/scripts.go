@@ -0,0 +1,77 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了脚本相关的处理函数。
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// @Summary     List Scripts
+// @Description List all scripts from the script directory
+// @Tags        scripts
+// @Produce     json
+// @Success     200 {array} types.CommandInfo
+// @Router      /scripts [get]
+func (s *Server) handleListScripts(c *gin.Context) {
+	if s.scripts == nil {
+		c.JSON(http.StatusOK, []types.CommandInfo{})
+		return
+	}
+	c.JSON(http.StatusOK, s.scripts.ListCommands())
+}
+
+// @Summary     Execute Script
+// @Description Execute a script by name, the command field of the request is the script name
+// @Tags        scripts
+// @Accept      json
+// @Produce     json
+// @Param       request body ExecRequest true "Script execution request"
+// @Success     200 {object} ExecResponse
+// @Failure     400 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Failure     501 {object} ErrorResponse
+// @Router      /scripts/exec [post]
+func (s *Server) handleExecScript(c *gin.Context) {
+	if s.scripts == nil {
+		s.handleError(c, http.StatusNotImplemented, fmt.Errorf("scripts are not enabled"), "")
+		return
+	}
+	var req ExecRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.handleError(c, http.StatusBadRequest, err, "Invalid request format")
+		return
+	}
+
+	var outputBuf bytes.Buffer
+	result, err := s.scripts.Execute(&types.ExecuteContext{
+		Context: c.Request.Context(),
+		Command: types.Command{Command: req.Command, Args: req.Args},
+		Options: &types.ExecuteOptions{
+			WorkDir: req.WorkDir,
+			Env:     req.Env,
+			Stdout:  &outputBuf,
+			Stderr:  &outputBuf,
+		},
+	})
+	if result == nil {
+		if err == nil {
+			err = fmt.Errorf("script returned no result")
+		}
+		s.handleError(c, execStatus(err), err, "")
+		return
+	}
+
+	response := newExecResponse(result)
+	if response.Output == "" {
+		response.Output = outputBuf.String()
+	}
+	if err != nil && response.Error == "" {
+		response.Error = err.Error()
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -5,6 +5,7 @@ package server
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -15,10 +16,18 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/iamlongalong/runshell/cmd/runshell/docs"
+	"github.com/iamlongalong/runshell/pkg/executor"
 	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/mcp"
+	"github.com/iamlongalong/runshell/pkg/policy"
+	"github.com/iamlongalong/runshell/pkg/schedule"
+	"github.com/iamlongalong/runshell/pkg/snapshot"
 	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/iamlongalong/runshell/pkg/workflow"
+	"github.com/soheilhy/cmux"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"google.golang.org/grpc"
 )
 
 func init() {
@@ -61,8 +70,10 @@ type ExecRequest struct {
 	Command string   `json:"command" binding:"required" example:"ls"`  // 要执行的命令
 	Args    []string `json:"args,omitempty" example:"[\"-l\",\"-a\"]"` // 命令参数
 
-	WorkDir string            `json:"workdir,omitempty"` // 工作目录
-	Env     map[string]string `json:"env,omitempty"`     // 环境变量
+	WorkDir string              `json:"workdir,omitempty"`                       // 工作目录
+	Env     map[string]string   `json:"env,omitempty"`                           // 环境变量
+	Track   *types.TrackOptions `json:"track,omitempty"`                         // 文件变更跟踪选项
+	Timeout int64               `json:"timeout,omitempty" example:"30000000000"` // 超时时间（纳秒），超时后终止命令
 }
 
 // ExecResponse 表示执行命令的响应
@@ -71,15 +82,34 @@ type ExecResponse struct {
 	ExitCode int    `json:"exit_code" example:"0"`      // 命令退出码
 	Output   string `json:"output" example:"file1.txt"` // 命令输出
 	Error    string `json:"error,omitempty"`            // 错误信息，如果有的话
+
+	Changes  []types.FileChange     `json:"changes,omitempty"`  // 文件变更，仅在开启 track 时返回
+	Data     interface{}            `json:"data,omitempty"`     // 内置命令返回的结构化结果
+	Attempts []types.ExecuteAttempt `json:"attempts,omitempty"` // 命令被重试时每次尝试的结果
+	Cache    string                 `json:"cache,omitempty"`    // 结果缓存状态，hit 或 miss
 }
 
 // Server 表示 HTTP 服务器。
 type Server struct {
 	executorBuilder types.ExecutorBuilder
 	sessionManager  types.SessionManager
+	policy          policy.Policy
+	snapshots       *snapshot.Manager
+	autoSnapshot    bool
+	scripts         ScriptRunner
+	targets         map[string]map[string]types.ExecutorBuilder
+	docker          *dockerSessions
+	auditLog        AuditLog
+	metrics         *executor.Metrics
+	idempotency     *idempotencyStore
+	workflows       workflow.Store
+	scheduler       *schedule.Scheduler
+	mcp             *mcp.Server
 	addr            string
 	engine          *gin.Engine
 	server          *http.Server
+	grpcServer      *grpc.Server
+	mux             cmux.CMux
 	listener        net.Listener
 	mu              sync.Mutex
 }
@@ -167,14 +197,88 @@ func NewServer(executorBuilder types.ExecutorBuilder, addr string) *Server {
 	s := &Server{
 		executorBuilder: executorBuilder,
 		sessionManager:  NewMemorySessionManager(),
+		policy:          policy.NewDefaultPolicy(),
+		idempotency:     newIdempotencyStore(DefaultIdempotencyTTL),
+		workflows:       workflow.NewMemoryStore(),
 		addr:            addr,
 		engine:          engine,
 	}
+	s.scheduler = schedule.NewScheduler(schedule.NewMemoryStore(), &scheduleDispatcher{server: s})
+	s.mcp = s.newMCPServer()
 
 	s.setupRoutes()
 	return s
 }
 
+// WithPolicy 设置命令执行策略
+func (s *Server) WithPolicy(p policy.Policy) *Server {
+	s.policy = p
+	return s
+}
+
+// WithSnapshots 启用会话快照。
+// auto 为 true 时，会在策略判定为破坏性的会话命令执行前自动创建快照。
+func (s *Server) WithSnapshots(manager *snapshot.Manager, auto bool) *Server {
+	s.snapshots = manager
+	s.autoSnapshot = auto
+	return s
+}
+
+// WithScripts 设置脚本管理器，其中的脚本会作为工具通过 /tools 导出
+func (s *Server) WithScripts(scripts ScriptRunner) *Server {
+	s.scripts = scripts
+	return s
+}
+
+// WithRemote 注册远程执行器，创建会话时可以通过 executor_type=remote 和 remote 名称选择
+func (s *Server) WithRemote(name string, builder types.ExecutorBuilder) *Server {
+	return s.WithTarget(types.ExecutorTypeRemote, name, builder)
+}
+
+// WithSSHHost 注册 SSH 主机，创建会话时可以通过 executor_type=ssh 和 remote 名称选择
+func (s *Server) WithSSHHost(name string, builder types.ExecutorBuilder) *Server {
+	return s.WithTarget(types.ExecutorTypeSSH, name, builder)
+}
+
+// WithTarget 注册指定执行器类型下的命名执行目标
+func (s *Server) WithTarget(executorType, name string, builder types.ExecutorBuilder) *Server {
+	if s.targets == nil {
+		s.targets = make(map[string]map[string]types.ExecutorBuilder)
+	}
+	if s.targets[executorType] == nil {
+		s.targets[executorType] = make(map[string]types.ExecutorBuilder)
+	}
+	s.targets[executorType][name] = builder
+	return s
+}
+
+// sessionExecutorBuilder 返回创建会话使用的执行器构建器。
+// 选择远程执行器或 SSH 主机时按名称查找，该类型只注册了一个目标时可以省略名称；
+// 指定了 docker_config 时按请求定制 Docker 容器；其他类型使用默认构建器。
+func (s *Server) sessionExecutorBuilder(req *types.SessionRequest) (types.ExecutorBuilder, error) {
+	if req.DockerConfig != nil {
+		return s.dockerSessionBuilder(req)
+	}
+	if req.ExecutorType != types.ExecutorTypeRemote && req.ExecutorType != types.ExecutorTypeSSH {
+		return s.executorBuilder, nil
+	}
+	targets := s.targets[req.ExecutorType]
+	name := req.Remote
+	if name == "" && len(targets) == 1 {
+		for n := range targets {
+			name = n
+		}
+	}
+	builder, ok := targets[name]
+	if !ok {
+		if name == "" {
+			return nil, fmt.Errorf("%s name is required", req.ExecutorType)
+		}
+		return nil, fmt.Errorf("unknown %s: %s", req.ExecutorType, name)
+	}
+	return builder, nil
+}
+
 // bodyLogWriter 是一个自定义的 ResponseWriter，用于捕获响应体和状态码
 type bodyLogWriter struct {
 	gin.ResponseWriter
@@ -223,20 +327,64 @@ func (s *Server) setupRoutes() {
 		// 健康检查
 		v1.GET("/health", s.handleHealth)
 
+		// 执行统计
+		v1.GET("/metrics", s.handleMetrics)
+
 		// 命令执行
-		v1.POST("/exec", s.handleExec)
+		v1.POST("/exec", s.idempotent, s.handleExec)
 		v1.GET("/exec/interactive", s.handleInteractiveExec)
 		v1.GET("/commands", s.handleListCommands)
 		v1.GET("/help", s.handleCommandHelp)
 
+		// LLM 工具相关
+		v1.GET("/tools", s.handleListTools)
+		v1.POST("/tools/call", s.handleCallTool)
+
+		// 脚本
+		v1.GET("/scripts", s.handleListScripts)
+		v1.POST("/scripts/exec", s.handleExecScript)
+
+		// 工作流
+		v1.GET("/workflows/runs", s.handleListWorkflowRuns)
+		v1.POST("/workflows/runs", s.handleRunWorkflow)
+		v1.GET("/workflows/runs/:id", s.handleGetWorkflowRun)
+
+		// 计划任务
+		v1.GET("/schedules", s.handleListSchedules)
+		v1.POST("/schedules", s.handleCreateSchedule)
+		v1.GET("/schedules/:id", s.handleGetSchedule)
+		v1.PUT("/schedules/:id", s.handleUpdateSchedule)
+		v1.DELETE("/schedules/:id", s.handleDeleteSchedule)
+		v1.POST("/schedules/:id/trigger", s.handleTriggerSchedule)
+		v1.GET("/schedules/:id/runs", s.handleListScheduleRuns)
+		v1.GET("/schedules/:id/runs/:rid", s.handleGetScheduleRun)
+
+		// MCP streamable HTTP 传输
+		v1.Any("/mcp", gin.WrapH(s.mcp))
+
 		// 会话管理
 		v1.GET("/sessions", s.handleListSessions)
 		v1.POST("/sessions", s.handleCreateSession)
 		v1.DELETE("/sessions/:id", s.handleDeleteSession)
-		v1.POST("/sessions/:id/exec", s.handleSessionExec)
+		v1.POST("/sessions/:id/exec", s.idempotent, s.handleSessionExec)
+		v1.GET("/sessions/:id/files", s.handleDownloadFile)
+		v1.PUT("/sessions/:id/files", s.handleUploadFile)
+
+		// 会话快照
+		v1.GET("/sessions/:id/snapshots", s.handleListSnapshots)
+		v1.POST("/sessions/:id/snapshots", s.handleCreateSnapshot)
+		v1.GET("/sessions/:id/snapshots/:sid", s.handleGetSnapshot)
+		v1.DELETE("/sessions/:id/snapshots/:sid", s.handleDeleteSnapshot)
+		v1.POST("/sessions/:id/snapshots/:sid/restore", s.handleRestoreSnapshot)
+		v1.GET("/sessions/:id/snapshots/:sid/diff", s.handleDiffSnapshot)
 	}
 }
 
+// Handler 返回 HTTP API 的处理器，用于嵌入其他 HTTP 服务或测试
+func (s *Server) Handler() http.Handler {
+	return s.engine
+}
+
 // Start 启动服务器
 func (s *Server) Start() error {
 	s.mu.Lock()
@@ -254,18 +402,40 @@ func (s *Server) Start() error {
 	}
 	log.Info("Listener created successfully for %s", s.addr)
 
+	// 启动计划任务调度
+	if err := s.scheduler.Start(); err != nil {
+		listener.Close()
+		return err
+	}
+
 	s.listener = listener
 	s.server = &http.Server{
 		Handler: s.engine,
 	}
+	s.grpcServer = s.newGRPCServer()
+
+	// HTTP 和 gRPC 共用同一个端口，按 content-type 分流 gRPC 请求
+	s.mux = cmux.New(listener)
+	grpcListener := s.mux.MatchWithWriters(cmux.HTTP2MatchHeaderFieldPrefixSendSettings("content-type", "application/grpc"))
+	httpListener := s.mux.Match(cmux.Any())
 
 	// 启动服务器
 	log.Info("Starting server on %s", s.addr)
 	go func() {
-		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		if err := s.grpcServer.Serve(grpcListener); err != nil && !errors.Is(err, grpc.ErrServerStopped) && !errors.Is(err, cmux.ErrListenerClosed) {
+			log.Error("gRPC server error: %v", err)
+		}
+	}()
+	go func() {
+		if err := s.server.Serve(httpListener); err != nil && err != http.ErrServerClosed && !errors.Is(err, cmux.ErrListenerClosed) {
 			log.Error("Server error: %v", err)
 		}
 	}()
+	go func() {
+		if err := s.mux.Serve(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Error("Listener error: %v", err)
+		}
+	}()
 
 	return nil
 }
@@ -283,14 +453,21 @@ func (s *Server) Stop() error {
 	sessions, _ := s.sessionManager.ListSessions()
 	for _, session := range sessions {
 		s.sessionManager.DeleteSession(session.ID)
+		s.deleteSessionSnapshots(session.ID)
 	}
 
-	// 关闭服务器
+	// 停止计划任务调度，终止正在执行的运行
+	s.scheduler.Stop()
+
+	// 关闭服务器，gRPC 流可能长时间运行，因此直接停止
+	s.grpcServer.Stop()
 	if err := s.server.Shutdown(context.Background()); err != nil {
 		return fmt.Errorf("failed to shutdown server: %w", err)
 	}
+	s.mux.Close()
 
 	s.server = nil
+	s.grpcServer = nil
 	return nil
 }
 
@@ -311,8 +488,11 @@ func (s *Server) handleHealth(c *gin.Context) {
 // @Accept      json
 // @Produce     json
 // @Param       request body ExecRequest true "Command execution request"
+// @Param       stream query bool false "Stream output as newline-delimited JSON events"
+// @Param       Idempotency-Key header string false "Return the recorded response of an earlier request with the same key instead of executing again"
 // @Success     200 {object} ExecResponse
 // @Failure     400 {object} ErrorResponse
+// @Failure     422 {object} ErrorResponse
 // @Failure     500 {object} ErrorResponse
 // @Router      /exec [post]
 func (s *Server) handleExec(c *gin.Context) {
@@ -324,60 +504,52 @@ func (s *Server) handleExec(c *gin.Context) {
 
 	log.Debug("Received exec request: %+v", req)
 
-	executor, err := s.executorBuilder.Build(&types.ExecuteOptions{
-		WorkDir: req.WorkDir,
-		Env:     req.Env,
-	})
-	if err != nil {
-		s.handleError(c, http.StatusInternalServerError, err, fmt.Sprintf("Failed to create executor: %v", err))
-		return
-	}
-
-	log.Debug("Created executor: %s", executor.Name())
-
 	// 准备执行选项
-	var outputBuf bytes.Buffer
 	opts := &types.ExecuteOptions{
 		WorkDir: req.WorkDir,
 		Env:     req.Env,
-		Stdout:  &outputBuf,
-		Stderr:  &outputBuf,
+		Track:   req.Track,
+		Timeout: req.Timeout,
 	}
 
-	log.Debug("Prepared execution options: %+v", opts)
-
-	// 执行命令
-	execCtx := &types.ExecuteContext{
-		Context: c.Request.Context(),
-		Command: types.Command{
-			Command: req.Command,
-			Args:    req.Args,
-		},
-		Options:  opts,
-		Executor: executor,
+	cmd := types.Command{Command: req.Command, Args: req.Args}
+	if c.Query("stream") == "true" {
+		s.streamExec(c, opts, func() (*types.ExecuteResult, string, error) {
+			result, err := s.execute(c.Request.Context(), cmd, opts)
+			return result, "", err
+		})
+		return
 	}
 
-	log.Debug("Executing command: %s %v", req.Command, req.Args)
-
-	result, err := executor.Execute(execCtx)
+	var outputBuf bytes.Buffer
+	opts.Stdout = &outputBuf
+	opts.Stderr = &outputBuf
+	result, err := s.execute(c.Request.Context(), cmd, opts)
+	markExecuted(c, result)
 	if err != nil {
-		log.Error("Command execution failed: %v", err)
-		s.handleError(c, http.StatusInternalServerError, err, fmt.Sprintf("Command execution failed: %v", err))
+		s.handleError(c, execStatus(err), err, fmt.Sprintf("Command execution failed: %v", err))
 		return
 	}
 
 	log.Info("Command execution succeeded: %+v", result)
 
-	// 构造响应
-	response := ExecResponse{
+	c.JSON(http.StatusOK, newExecResponse(result))
+}
+
+// newExecResponse 将执行结果转换为响应
+func newExecResponse(result *types.ExecuteResult) *ExecResponse {
+	response := &ExecResponse{
 		ExitCode: result.ExitCode,
 		Output:   result.Output,
+		Changes:  result.Changes,
+		Data:     result.Data,
+		Attempts: result.Attempts,
+		Cache:    result.CacheStatus,
 	}
 	if result.Error != nil {
 		response.Error = result.Error.Error()
 	}
-
-	c.JSON(http.StatusOK, response)
+	return response
 }
 
 // @Summary     List Commands
@@ -400,12 +572,13 @@ func (s *Server) handleListCommands(c *gin.Context) {
 }
 
 // @Summary     Get Command Help
-// @Description Get help information for a specific command
+// @Description Get help information for a specific command. Returns plain text by default, or the command info including its argument schema with format=json
 // @Tags        commands
 // @Accept      json
-// @Produce     json
+// @Produce     json,plain
 // @Param       command query string true "Command name"
-// @Success     200 {string} string
+// @Param       format query string false "Response format" Enums(text, json)
+// @Success     200 {object} types.CommandInfo
 // @Failure     400 {object} ErrorResponse
 // @Failure     404 {object} ErrorResponse
 // @Router      /help [get]
@@ -422,13 +595,17 @@ func (s *Server) handleCommandHelp(c *gin.Context) {
 		return
 	}
 
-	help, err := s.getCommandHelp(executor, cmdName)
+	info, err := s.getCommandHelp(executor, cmdName)
 	if err != nil {
 		s.handleError(c, http.StatusNotFound, err, fmt.Sprintf("Failed to get command help: %v", err))
 		return
 	}
 
-	c.String(http.StatusOK, help)
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, info)
+		return
+	}
+	c.String(http.StatusOK, formatHelp(info))
 }
 
 // @Summary     List Sessions
@@ -466,7 +643,15 @@ func (s *Server) handleCreateSession(c *gin.Context) {
 		return
 	}
 
-	executor, err := s.executorBuilder.Build(&types.ExecuteOptions{
+	if req.Options == nil {
+		req.Options = &types.ExecuteOptions{}
+	}
+	builder, err := s.sessionExecutorBuilder(&req)
+	if err != nil {
+		s.handleError(c, http.StatusBadRequest, err, "")
+		return
+	}
+	executor, err := s.buildExecutor(builder, &types.ExecuteOptions{
 		WorkDir: req.Options.WorkDir,
 		Env:     req.Options.Env,
 	})
@@ -504,6 +689,7 @@ func (s *Server) handleDeleteSession(c *gin.Context) {
 		s.handleError(c, http.StatusNotFound, err, "")
 		return
 	}
+	s.deleteSessionSnapshots(sessionID)
 	c.Status(http.StatusNoContent)
 }
 
@@ -514,9 +700,13 @@ func (s *Server) handleDeleteSession(c *gin.Context) {
 // @Produce     json
 // @Param       id path string true "Session ID"
 // @Param       request body ExecRequest true "Command execution request"
-// @Success     200 {object} ExecResponse
+// @Param       stream query bool false "Stream output as newline-delimited JSON events"
+// @Param       Idempotency-Key header string false "Return the recorded response of an earlier request with the same key instead of executing again"
+// @Success     200 {object} types.ExecuteResult
 // @Failure     400 {object} ErrorResponse
+// @Failure     403 {object} ErrorResponse
 // @Failure     404 {object} ErrorResponse
+// @Failure     422 {object} ErrorResponse
 // @Failure     500 {object} ErrorResponse
 // @Router      /sessions/{id}/exec [post]
 func (s *Server) handleSessionExec(c *gin.Context) {
@@ -540,40 +730,162 @@ func (s *Server) handleSessionExec(c *gin.Context) {
 
 	opts := &types.ExecuteOptions{
 		WorkDir: req.WorkDir,
+		Track:   req.Track,
+		Timeout: req.Timeout,
 	}
 
 	if session.Options != nil && session.Options.Env != nil {
 		opts.Env = session.Options.Env
 	}
 
-	execCtx := &types.ExecuteContext{
-		Context: c.Request.Context(),
-		Command: types.Command{
-			Command: req.Command,
-			Args:    req.Args,
-		},
-		Options:  opts,
-		Executor: session.Executor,
+	cmd := types.Command{Command: req.Command, Args: req.Args}
+	if c.Query("stream") == "true" {
+		s.streamExec(c, opts, func() (*types.ExecuteResult, string, error) {
+			return s.executeInSession(c.Request.Context(), session, cmd, opts)
+		})
+		return
 	}
 
-	result, err := session.Executor.Execute(execCtx)
+	result, snapshotID, err := s.executeInSession(c.Request.Context(), session, cmd, opts)
+	markExecuted(c, result)
+	if snapshotID != "" {
+		c.Header(SnapshotHeader, snapshotID)
+	}
 	if err != nil {
-		s.handleError(c, http.StatusInternalServerError, err, "")
+		s.handleError(c, execStatus(err), err, "")
 		return
 	}
 
+	// 会话执行接口保持返回 types.ExecuteResult 的字段（CommandName、ExitCode、Output 等），
+	// 与 /exec 返回的 ExecResponse 不同
 	c.JSON(http.StatusOK, result)
 }
 
+// execute 使用新建的执行器执行命令，命令以非零状态退出时同时返回结果和错误
+func (s *Server) execute(ctx context.Context, cmd types.Command, opts *types.ExecuteOptions) (*types.ExecuteResult, error) {
+	executor, err := s.buildExecutor(s.executorBuilder, &types.ExecuteOptions{
+		WorkDir: opts.WorkDir,
+		Env:     opts.Env,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	log.Debug("Created executor: %s", executor.Name())
+	log.Debug("Executing command: %s %v", cmd.Command, cmd.Args)
+
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	execCtx := &types.ExecuteContext{
+		Context:  ctx,
+		Command:  cmd,
+		Options:  opts,
+		Executor: executor,
+	}
+	result, err := executor.Execute(execCtx)
+	if err != nil {
+		log.Error("Command execution failed: %v", err)
+	}
+	return result, err
+}
+
+// buildExecutor 通过构建器创建执行命令的执行器，并在最外层检查执行策略，
+// 使 HTTP、工具、MCP、gRPC、工作流和定时任务对同一命令得到相同的判定
+func (s *Server) buildExecutor(builder types.ExecutorBuilder, options *types.ExecuteOptions) (types.Executor, error) {
+	exec, err := builder.Build(options)
+	if err != nil {
+		return nil, err
+	}
+	return executor.Chain(exec, executor.PolicyMiddleware(s.policy)), nil
+}
+
+// withTimeout 按执行选项中的超时时间限制命令的执行
+func withTimeout(ctx context.Context, opts *types.ExecuteOptions) (context.Context, context.CancelFunc) {
+	if opts != nil && opts.Timeout > 0 {
+		return context.WithTimeout(ctx, time.Duration(opts.Timeout))
+	}
+	return context.WithCancel(ctx)
+}
+
+// executeInSession 在会话中执行命令，执行策略由会话执行器检查。
+// 破坏性命令在开启自动快照时会先创建快照，并返回快照 ID。
+func (s *Server) executeInSession(ctx context.Context, session *types.Session, cmd types.Command, opts *types.ExecuteOptions) (*types.ExecuteResult, string, error) {
+	var snapshotID string
+	if decision := s.policy.Evaluate(cmd); decision.Allowed && decision.Destructive && s.autoSnapshot && s.snapshots != nil {
+		if snap, err := s.createSnapshot(ctx, session, "auto: "+decision.Reason); err != nil {
+			log.Error("Failed to create automatic snapshot for session %s: %v", session.ID, err)
+		} else {
+			snapshotID = snap.ID
+		}
+	}
+
+	ctx, cancel := withTimeout(ctx, opts)
+	defer cancel()
+	execCtx := &types.ExecuteContext{
+		Context:  ctx,
+		Command:  cmd,
+		Options:  opts,
+		Executor: session.Executor,
+	}
+	result, err := session.Executor.Execute(execCtx)
+	return result, snapshotID, err
+}
+
 // getCommandHelp 获取命令帮助信息
-func (s *Server) getCommandHelp(executor types.Executor, cmdName string) (string, error) {
+func (s *Server) getCommandHelp(executor types.Executor, cmdName string) (types.CommandInfo, error) {
 	commands := executor.ListCommands()
 	for _, cmd := range commands {
 		if cmd.Name == cmdName {
-			return cmd.Usage, nil
+			return cmd, nil
+		}
+	}
+	return types.CommandInfo{}, fmt.Errorf("command not found: %s", cmdName)
+}
+
+// formatHelp 将命令信息格式化为文本帮助，声明了参数结构时附加参数说明
+func formatHelp(info types.CommandInfo) string {
+	if info.Schema == nil || len(info.Schema.Args) == 0 {
+		return info.Usage
+	}
+
+	var sb strings.Builder
+	sb.WriteString(info.Usage + "\n\nArguments:\n")
+	for _, arg := range info.Schema.Args {
+		name := arg.Name
+		if arg.Flag != "" {
+			name = strings.Join(append([]string{arg.Flag}, arg.Aliases...), ", ")
+		}
+		if arg.Variadic {
+			name += "..."
 		}
+
+		var attrs []string
+		attrs = append(attrs, string(arg.Type))
+		if arg.Required {
+			attrs = append(attrs, "required")
+		}
+		if arg.Default != "" {
+			attrs = append(attrs, "default: "+arg.Default)
+		}
+		if len(arg.Enum) > 0 {
+			attrs = append(attrs, "one of: "+strings.Join(arg.Enum, ", "))
+		}
+		fmt.Fprintf(&sb, "  %-24s %s (%s)\n", name, arg.Description, strings.Join(attrs, "; "))
+	}
+	return sb.String()
+}
+
+// execStatus 返回命令执行失败时的 HTTP 状态码，参数校验失败视为请求错误，被策略拒绝视为禁止访问
+func execStatus(err error) int {
+	var argErr *types.ArgError
+	if errors.As(err, &argErr) {
+		return http.StatusBadRequest
+	}
+	var deniedErr *policy.DeniedError
+	if errors.As(err, &deniedErr) {
+		return http.StatusForbidden
 	}
-	return "", fmt.Errorf("command not found: %s", cmdName)
+	return http.StatusInternalServerError
 }
 
 // handleError 统一的错误处理函数
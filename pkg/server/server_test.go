@@ -3,15 +3,24 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/executor"
+	"github.com/iamlongalong/runshell/pkg/policy"
+	"github.com/iamlongalong/runshell/pkg/schedule"
+	"github.com/iamlongalong/runshell/pkg/snapshot"
 	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/iamlongalong/runshell/pkg/workflow"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -171,6 +180,16 @@ func TestHandleCommandHelp(t *testing.T) {
 					Name:  "test",
 					Usage: "test command usage",
 				},
+				{
+					Name:  "typed",
+					Usage: "typed [-n NUM] file",
+					Schema: &types.ArgSchema{
+						Args: []types.ArgSpec{
+							{Name: "lines", Flag: "-n", Type: types.ArgTypeInt, Default: "10", Description: "Line count"},
+							{Name: "file", Type: types.ArgTypePath, Required: true, Description: "Input file"},
+						},
+					},
+				},
 			}
 		},
 	}
@@ -180,6 +199,32 @@ func TestHandleCommandHelp(t *testing.T) {
 		return mockExecutor, nil
 	}), ":8080")
 
+	t.Run("schema", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/help?command=typed&format=json", nil)
+
+		s.handleCommandHelp(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var info types.CommandInfo
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&info))
+		assert.Equal(t, "typed", info.Name)
+		assert.Len(t, info.Schema.Args, 2)
+		assert.Equal(t, types.ArgTypeInt, info.Schema.Args[0].Type)
+
+		w = httptest.NewRecorder()
+		c, _ = gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/help?command=typed", nil)
+
+		s.handleCommandHelp(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Arguments:")
+		assert.Contains(t, w.Body.String(), "Line count (int; default: 10)")
+		assert.Contains(t, w.Body.String(), "Input file (path; required)")
+	})
+
 	t.Run("valid command", func(t *testing.T) {
 		// 创建测试上下文
 		w := httptest.NewRecorder()
@@ -223,6 +268,39 @@ func TestHandleCommandHelp(t *testing.T) {
 	})
 }
 
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockExecutor := &MockExecutor{
+		ExecuteFunc: func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+			return &types.ExecuteResult{ExitCode: 0, Output: "ok"}, nil
+		},
+	}
+	metrics := executor.NewMetrics()
+	s := NewServer(types.ExecutorBuilderFunc(func(options *types.ExecuteOptions) (types.Executor, error) {
+		return executor.Chain(mockExecutor, executor.MetricsMiddleware(metrics)), nil
+	}), ":0")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		s.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// 未启用时返回 501
+	assert.Equal(t, http.StatusNotImplemented, do("GET", "/api/v1/metrics", "").Code)
+
+	s.WithMetrics(metrics)
+	assert.Equal(t, http.StatusOK, do("POST", "/api/v1/exec", `{"command":"echo","args":["hi"]}`).Code)
+	w := do("GET", "/api/v1/metrics", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp MetricsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(1), resp.Commands["echo"].Count)
+}
+
 func TestServerStartStop(t *testing.T) {
 	// 创建服务器
 	server := NewServer(types.NewMockExecutorBuilder(&types.MockExecutor{}), ":0")
@@ -250,3 +328,836 @@ func TestServerStartStop(t *testing.T) {
 		t.Fatal("Server is still running")
 	}
 }
+
+func TestSessionSnapshots(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	workDir, snapshotDir := t.TempDir(), t.TempDir()
+	manager, err := snapshot.NewManager(snapshotDir, 0)
+	assert.NoError(t, err)
+
+	s := NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		AllowUnregisteredCommands: true,
+		WorkDir:                   workDir,
+	}), ":0").WithSnapshots(manager, true)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		s.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// 创建会话
+	w := do("POST", "/api/v1/sessions", `{"options":{"workdir":"`+workDir+`"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var sessResp types.SessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessResp))
+	sessionID := sessResp.Session.ID
+
+	assert.NoError(t, os.WriteFile(filepath.Join(workDir, "keep.txt"), []byte("data"), 0644))
+
+	// 手动创建快照
+	w = do("POST", "/api/v1/sessions/"+sessionID+"/snapshots", `{"reason":"manual"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var snap snapshot.Snapshot
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &snap))
+	assert.Equal(t, 1, snap.FileCount)
+
+	// 破坏性命令触发自动快照
+	w = do("POST", "/api/v1/sessions/"+sessionID+"/exec", `{"command":"rm","args":["keep.txt"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get(SnapshotHeader))
+	_, err = os.Stat(filepath.Join(workDir, "keep.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	w = do("GET", "/api/v1/sessions/"+sessionID+"/snapshots", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var snaps []snapshot.Snapshot
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &snaps))
+	assert.Len(t, snaps, 2)
+
+	// 与当前工作目录比较
+	w = do("GET", "/api/v1/sessions/"+sessionID+"/snapshots/"+snap.ID+"/diff", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var diff SnapshotDiffResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &diff))
+	assert.Equal(t, []types.FileChange{{Path: "keep.txt", Type: types.FileDeleted}}, diff.Changes)
+
+	// 回滚
+	w = do("POST", "/api/v1/sessions/"+sessionID+"/snapshots/"+snap.ID+"/restore", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	data, err := os.ReadFile(filepath.Join(workDir, "keep.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	w = do("GET", "/api/v1/sessions/"+sessionID+"/snapshots/unknown", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = do("GET", "/api/v1/sessions/"+sessionID+"/snapshots/unknown/diff", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 快照存在但无法读取时返回 500 而不是 404
+	assert.NoError(t, os.WriteFile(filepath.Join(snapshotDir, "sessions", sessionID, snap.ID+".json"), []byte("{"), 0600))
+	w = do("GET", "/api/v1/sessions/"+sessionID+"/snapshots/"+snap.ID+"/diff", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestSessionExecResponseKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	s := NewServer(types.NewMockExecutorBuilder(types.NewMockExecutor()), ":0")
+	do := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		s.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/api/v1/sessions", `{}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var sessResp types.SessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessResp))
+
+	// 会话执行接口返回 types.ExecuteResult 的字段
+	w = do("/api/v1/sessions/"+sessResp.Session.ID+"/exec", `{"command":"pwd"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "pwd", body["CommandName"])
+	assert.Equal(t, float64(0), body["ExitCode"])
+	assert.Contains(t, body, "Output")
+	assert.NotContains(t, body, "exit_code")
+	assert.NotContains(t, body, "output")
+}
+
+func TestSessionExecPolicyDenied(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	s := NewServer(types.NewMockExecutorBuilder(types.NewMockExecutor()), ":0").
+		WithPolicy(&policy.RulePolicy{Deny: []policy.Rule{{Command: "rm"}}})
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		s.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/api/v1/sessions", `{}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var sessResp types.SessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessResp))
+
+	w = do("/api/v1/sessions/"+sessResp.Session.ID+"/exec", `{"command":"rm","args":["-rf","/"]}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 不使用会话的命令同样需要通过执行策略检查
+	w = do("/api/v1/exec", `{"command":"rm","args":["-rf","/"]}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do("/api/v1/exec", `{"command":"ls"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// mockScripts 是用于测试的脚本管理器
+type mockScripts struct {
+	executed types.Command
+}
+
+func (m *mockScripts) ListCommands() []types.CommandInfo {
+	return []types.CommandInfo{{
+		Name:   "deploy",
+		Schema: &types.ArgSchema{Args: []types.ArgSpec{{Name: "env", Flag: "--env", Type: types.ArgTypeString, Required: true}}},
+	}}
+}
+
+func (m *mockScripts) Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+	m.executed = ctx.Command
+	return &types.ExecuteResult{Output: "deployed\n"}, nil
+}
+
+func TestTools(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	workDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("one\ntwo\nthree\n"), 0644))
+
+	scripts := &mockScripts{}
+	s := NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		UseBuiltinCommands: true,
+		WorkDir:            workDir,
+	}), ":0").WithScripts(scripts)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		s.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("list", func(t *testing.T) {
+		w := do("GET", "/api/v1/tools?format=anthropic", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var defs []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &defs))
+		names := make(map[string]bool)
+		for _, def := range defs {
+			names[def["name"].(string)] = true
+			assert.Contains(t, def, "input_schema")
+		}
+		assert.True(t, names["head"])
+		assert.True(t, names["script_deploy"])
+
+		w = do("GET", "/api/v1/tools?format=xml", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("call openai", func(t *testing.T) {
+		w := do("POST", "/api/v1/tools/call", `{"id":"call_1","type":"function","function":{"name":"head","arguments":"{\"lines\":2,\"file\":[\"a.txt\"]}"}}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "tool", resp["role"])
+		assert.Equal(t, "call_1", resp["tool_call_id"])
+		assert.Equal(t, "one\ntwo\n", resp["content"])
+	})
+
+	t.Run("call anthropic error", func(t *testing.T) {
+		w := do("POST", "/api/v1/tools/call", `{"type":"tool_use","id":"toolu_1","name":"head","input":{"file":["missing.txt"]}}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "tool_result", resp["type"])
+		assert.Equal(t, true, resp["is_error"])
+	})
+
+	t.Run("call script", func(t *testing.T) {
+		w := do("POST", "/api/v1/tools/call?format=jsonschema", `{"name":"script_deploy","arguments":{"env":"prod"}}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, types.Command{Command: "deploy", Args: []string{"--env", "prod"}}, scripts.executed)
+		assert.Contains(t, w.Body.String(), `"output":"deployed\n"`)
+	})
+
+	t.Run("unknown tool", func(t *testing.T) {
+		w := do("POST", "/api/v1/tools/call", `{"name":"nope"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestMCP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	workDir := t.TempDir()
+	s := NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		UseBuiltinCommands: true,
+		WorkDir:            workDir,
+	}), ":0").WithPolicy(&policy.RulePolicy{Deny: []policy.Rule{{Command: "rm"}}})
+
+	id := 0
+	call := func(method string, params interface{}) map[string]interface{} {
+		id++
+		body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/v1/mcp", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		s.engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Nil(t, resp["error"])
+		result, _ := resp["result"].(map[string]interface{})
+		return result
+	}
+	text := func(result map[string]interface{}) string {
+		return result["content"].([]interface{})[0].(map[string]interface{})["text"].(string)
+	}
+
+	// 创建会话
+	result := call("tools/call", map[string]interface{}{"name": "create_session", "arguments": map[string]interface{}{"workdir": workDir}})
+	var session types.Session
+	assert.NoError(t, json.Unmarshal([]byte(text(result)), &session))
+	assert.NotEmpty(t, session.ID)
+
+	// 写入并读取文件
+	result = call("tools/call", map[string]interface{}{"name": "write_file", "arguments": map[string]interface{}{
+		"path": "notes/a.txt", "content": "one\ntwo\n", "parents": true, "session_id": session.ID,
+	}})
+	assert.Nil(t, result["isError"])
+	result = call("tools/call", map[string]interface{}{"name": "read_file", "arguments": map[string]interface{}{
+		"path": "notes/a.txt", "start_line": 2, "session_id": session.ID,
+	}})
+	assert.Equal(t, "two\n", text(result))
+
+	// 会话中的命令经过执行策略检查
+	result = call("tools/call", map[string]interface{}{"name": "exec", "arguments": map[string]interface{}{
+		"command": "rm", "args": []string{"notes/a.txt"}, "session_id": session.ID,
+	}})
+	assert.Equal(t, true, result["isError"])
+	_, err := os.Stat(filepath.Join(workDir, "notes/a.txt"))
+	assert.NoError(t, err)
+
+	// 会话文件资源
+	result = call("resources/list", nil)
+	uri := "runshell://sessions/" + session.ID + "/files/notes/a.txt"
+	assert.Contains(t, jsonString(result["resources"]), uri)
+	result = call("resources/read", map[string]interface{}{"uri": uri})
+	contents := result["contents"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "one\ntwo\n", contents["text"])
+	assert.Equal(t, "text/plain; charset=utf-8", contents["mimeType"])
+
+	result = call("tools/call", map[string]interface{}{"name": "delete_session", "arguments": map[string]interface{}{"session_id": session.ID}})
+	assert.Nil(t, result["isError"])
+	_, err = s.sessionManager.GetSession(session.ID)
+	assert.Error(t, err)
+}
+
+// jsonString 将 JSON 值编码为字符串，便于断言
+func jsonString(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestExecStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	s := NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		UseBuiltinCommands:        true,
+		AllowUnregisteredCommands: true,
+		WorkDir:                   t.TempDir(),
+	}), ":0")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/exec?stream=true", strings.NewReader(`{"command":"sh","args":["-c","echo out; echo err >&2; exit 2"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	s.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, StreamContentType, w.Header().Get("Content-Type"))
+
+	// 每行一个事件，最后一个事件为执行结果
+	var stdout, stderr string
+	var result *ExecResponse
+	dec := json.NewDecoder(w.Body)
+	for dec.More() {
+		var event ExecStreamEvent
+		assert.NoError(t, dec.Decode(&event))
+		stdout += event.Stdout
+		stderr += event.Stderr
+		if event.Result != nil {
+			result = event.Result
+		}
+	}
+	assert.Equal(t, "out\n", stdout)
+	assert.Equal(t, "err\n", stderr)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, 2, result.ExitCode)
+		assert.Empty(t, result.Output)
+		assert.NotEmpty(t, result.Error)
+	}
+
+	// 参数错误在输出前返回，仍使用对应的状态码
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/v1/exec?stream=true", strings.NewReader(`{"command":"head","args":["-n","x"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	s.engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSessionFiles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	workDir := t.TempDir()
+	s := NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		AllowUnregisteredCommands: true,
+		WorkDir:                   workDir,
+	}), ":0")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.engine.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := do("POST", "/api/v1/sessions", `{"options":{"workdir":"`+workDir+`"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var sessResp types.SessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessResp))
+	files := "/api/v1/sessions/" + sessResp.Session.ID + "/files"
+
+	// 上传
+	w = do("PUT", files+"?path=sub/a.sh&mode=755", "echo hi\n")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = do("PUT", files+"?path=sub/a.sh&mode=755&parents=true", "echo hi\n")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	info, err := os.Stat(filepath.Join(workDir, "sub", "a.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	w = do("PUT", files+"?path=b.txt&mode=abc", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 下载文件
+	w = do("GET", files+"?path=sub/a.sh", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "echo hi\n", w.Body.String())
+
+	// 下载目录时返回目录列表
+	w = do("GET", files+"?path=sub", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []types.FileInfo
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "a.sh", entries[0].Name)
+	}
+
+	assert.Equal(t, http.StatusNotFound, do("GET", files+"?path=missing", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do("GET", files, "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/v1/sessions/unknown/files?path=a", "").Code)
+}
+
+func TestSessionResultCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	workDir := t.TempDir()
+	local := executor.NewLocalExecutorBuilder(types.LocalConfig{
+		AllowUnregisteredCommands: true,
+		WorkDir:                   workDir,
+	})
+	s := NewServer(types.ExecutorBuilderFunc(func(options *types.ExecuteOptions) (types.Executor, error) {
+		exec, err := local.Build(options)
+		if err != nil {
+			return nil, err
+		}
+		return executor.NewCachingExecutor(exec, executor.CacheConfig{}), nil
+	}), ":0")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.engine.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	type sessionExecResult struct {
+		Output      string
+		CacheStatus string
+	}
+	exec := func(sessionID string) sessionExecResult {
+		w := do("POST", "/api/v1/sessions/"+sessionID+"/exec", `{"command":"cat","args":["a.txt"]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp sessionExecResult
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	w := do("POST", "/api/v1/sessions", `{"options":{"workdir":"`+workDir+`"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var sessResp types.SessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessResp))
+	sessionID := sessResp.Session.ID
+
+	assert.NoError(t, os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("one"), 0644))
+	resp := exec(sessionID)
+	assert.Equal(t, "one", resp.Output)
+	assert.Equal(t, types.CacheMiss, resp.CacheStatus)
+	assert.Equal(t, types.CacheHit, exec(sessionID).CacheStatus)
+
+	// 通过文件接口修改后缓存失效
+	assert.Equal(t, http.StatusNoContent, do("PUT", "/api/v1/sessions/"+sessionID+"/files?path=a.txt", "two").Code)
+	resp = exec(sessionID)
+	assert.Equal(t, "two", resp.Output)
+	assert.Equal(t, types.CacheMiss, resp.CacheStatus)
+}
+
+func TestScriptsAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	do := func(s *Server, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		s.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// 未启用脚本
+	s := NewServer(types.NewMockExecutorBuilder(types.NewMockExecutor()), ":0")
+	w := do(s, "GET", "/api/v1/scripts", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+	assert.Equal(t, http.StatusNotImplemented, do(s, "POST", "/api/v1/scripts/exec", `{"command":"deploy"}`).Code)
+
+	scripts := &mockScripts{}
+	s = NewServer(types.NewMockExecutorBuilder(types.NewMockExecutor()), ":0").WithScripts(scripts)
+	w = do(s, "GET", "/api/v1/scripts", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list []types.CommandInfo
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list, 1)
+
+	w = do(s, "POST", "/api/v1/scripts/exec", `{"command":"deploy","args":["--env","prod"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp ExecResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "deployed\n", resp.Output)
+	assert.Equal(t, []string{"--env", "prod"}, scripts.executed.Args)
+}
+
+func TestExecTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	s := NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		AllowUnregisteredCommands: true,
+		WorkDir:                   t.TempDir(),
+	}), ":0")
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/exec?stream=true", strings.NewReader(`{"command":"sleep","args":["10"],"timeout":200000000}`))
+	req.Header.Set("Content-Type", "application/json")
+	s.engine.ServeHTTP(w, req)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var mu sync.Mutex
+	calls := 0
+	s := NewServer(types.ExecutorBuilderFunc(func(options *types.ExecuteOptions) (types.Executor, error) {
+		return &MockExecutor{ExecuteFunc: func(ctx *types.ExecuteContext) (*types.ExecuteResult, error) {
+			mu.Lock()
+			calls++
+			n := calls
+			mu.Unlock()
+			time.Sleep(50 * time.Millisecond)
+			switch ctx.Command.Command {
+			case "false":
+				return &types.ExecuteResult{ExitCode: 1, Output: fmt.Sprintf("run %d", n)}, fmt.Errorf("exit status 1")
+			case "broken":
+				return nil, fmt.Errorf("executor unavailable")
+			}
+			return &types.ExecuteResult{Output: fmt.Sprintf("run %d", n)}, nil
+		}}, nil
+	}), ":0")
+
+	do := func(path, key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(IdempotencyHeader, key)
+		}
+		s.engine.ServeHTTP(w, req)
+		return w
+	}
+	callCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+
+	// 相同的键返回第一次执行的结果
+	w := do("/api/v1/exec", "k1", `{"command":"echo"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "run 1")
+	w = do("/api/v1/exec", "k1", `{"command":"echo"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "run 1")
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 1, callCount())
+
+	// 相同的键用于不同的请求
+	w = do("/api/v1/exec", "k1", `{"command":"ls"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 1, callCount())
+
+	// 没有幂等键的请求每次都执行
+	do("/api/v1/exec", "", `{"command":"echo"}`)
+	do("/api/v1/exec", "", `{"command":"echo"}`)
+	assert.Equal(t, 3, callCount())
+
+	// 并发的相同请求只执行一次
+	var wg sync.WaitGroup
+	bodies := make([]string, 3)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = do("/api/v1/exec?stream=true", "k2", `{"command":"echo"}`).Body.String()
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 4, callCount())
+	assert.Equal(t, bodies[0], bodies[1])
+	assert.Equal(t, bodies[0], bodies[2])
+
+	// 命令执行失败的结果同样会被记录
+	w = do("/api/v1/exec", "k3", `{"command":"false"}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	w = do("/api/v1/exec", "k3", `{"command":"false"}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, 5, callCount())
+
+	// 命令没有执行时不记录，重试会重新执行
+	do("/api/v1/exec", "k4", `{"command":"broken"}`)
+	do("/api/v1/exec", "k4", `{"command":"broken"}`)
+	assert.Equal(t, 7, callCount())
+
+	// 键的作用域为请求路径
+	w = do("/api/v1/sessions", "", `{}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var sessResp types.SessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessResp))
+	w = do("/api/v1/sessions/"+sessResp.Session.ID+"/exec", "k1", `{"command":"echo"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 8, callCount())
+
+	// 关闭幂等键
+	s.WithIdempotencyTTL(0)
+	do("/api/v1/exec", "k1", `{"command":"echo"}`)
+	assert.Equal(t, 9, callCount())
+}
+
+func TestWorkflowRuns(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	workDir := t.TempDir()
+	s := NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		AllowUnregisteredCommands: true,
+		WorkDir:                   workDir,
+	}), ":0").WithPolicy(&policy.RulePolicy{Deny: []policy.Rule{{Command: "rm"}}})
+
+	do := func(path, contentType, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		s.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// JSON 请求
+	w := do("/api/v1/workflows/runs", "application/json", `{"workdir":"`+workDir+`","workflow":{"steps":[
+		{"id":"a","run":"echo hello","outputs":{"greeting":""}},
+		{"id":"b","needs":["a"],"run":"echo ${{ steps.a.outputs.greeting }} world > out.txt"}]}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var run workflow.Run
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+	assert.Equal(t, workflow.StatusSucceeded, run.Status)
+	data, err := os.ReadFile(filepath.Join(workDir, "out.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world\n", string(data))
+
+	// YAML 请求体，流式返回事件
+	w = do("/api/v1/workflows/runs?stream=true", "application/yaml", "steps:\n  - id: fail\n    run: echo oops; exit 2\n")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, StreamContentType, w.Header().Get("Content-Type"))
+	var events []workflow.Event
+	dec := json.NewDecoder(w.Body)
+	for dec.More() {
+		var event workflow.Event
+		assert.NoError(t, dec.Decode(&event))
+		events = append(events, event)
+	}
+	if assert.NotEmpty(t, events) {
+		last := events[len(events)-1]
+		assert.Equal(t, workflow.EventRunFinished, last.Type)
+		assert.Equal(t, workflow.StatusFailed, last.Run.Status)
+		assert.Equal(t, 2, last.Run.Steps[0].ExitCode)
+	}
+
+	// 运行状态可以查询
+	w = httptest.NewRecorder()
+	s.engine.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/workflows/runs/"+run.ID, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	s.engine.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/workflows/runs", nil))
+	var runs []workflow.Run
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &runs))
+	assert.Len(t, runs, 2)
+	w = httptest.NewRecorder()
+	s.engine.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/workflows/runs/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 无效的工作流
+	w = do("/api/v1/workflows/runs", "application/json", `{"workflow":{"steps":[{"id":"a","run":"x","needs":["b"]}]}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 会话中的步骤需通过执行策略检查
+	w = do("/api/v1/sessions", "application/json", `{"options":{"workdir":"`+workDir+`"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var sessResp types.SessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessResp))
+	w = do("/api/v1/workflows/runs", "application/json", `{"session_id":"`+sessResp.Session.ID+`","workflow":{"steps":[{"id":"clean","run":"rm out.txt"}]}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+	assert.Equal(t, workflow.StatusFailed, run.Status)
+	assert.Contains(t, run.Steps[0].Error, "denied")
+	assert.FileExists(t, filepath.Join(workDir, "out.txt"))
+}
+
+func TestSchedules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	workDir := t.TempDir()
+	s := NewServer(executor.NewLocalExecutorBuilder(types.LocalConfig{
+		AllowUnregisteredCommands: true,
+		WorkDir:                   workDir,
+	}), ":0").WithPolicy(&policy.RulePolicy{Deny: []policy.Rule{{Command: "rm"}}})
+	assert.NoError(t, s.scheduler.Start())
+	defer s.scheduler.Stop()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		s.engine.ServeHTTP(w, req)
+		return w
+	}
+	waitRun := func(id, runID string) schedule.Run {
+		var run schedule.Run
+		assert.Eventually(t, func() bool {
+			w := do("GET", "/api/v1/schedules/"+id+"/runs/"+runID, "")
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+			return run.Status != schedule.StatusRunning
+		}, 5*time.Second, 10*time.Millisecond)
+		return run
+	}
+
+	// 无效的定义
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/api/v1/schedules", `{"cron":"* * *","target":{"command":"echo"}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/api/v1/schedules", `{"cron":"@daily","target":{"script":"maintenance"}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/api/v1/schedules", `{"cron":"@daily","target":{"command":"echo","executor_type":"ssh"}}`).Code)
+
+	w := do("POST", "/api/v1/schedules", `{"id":"report","cron":"0 3 * * *","jitter":"1m","target":{"command":"echo $GREETING > report.txt; cat report.txt","workdir":"`+workDir+`","env":{"GREETING":"hi"}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var sc schedule.Schedule
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sc))
+	assert.Equal(t, "report", sc.ID)
+	assert.NotNil(t, sc.NextRunTime)
+	assert.Equal(t, http.StatusConflict, do("POST", "/api/v1/schedules", `{"id":"report","cron":"@daily","target":{"command":"echo"}}`).Code)
+
+	// 手动触发
+	w = do("POST", "/api/v1/schedules/report/trigger", "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var run schedule.Run
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+	run = waitRun("report", run.ID)
+	assert.Equal(t, schedule.StatusSucceeded, run.Status)
+	assert.Equal(t, "hi\n", run.Output)
+
+	// 命令需通过执行策略检查
+	w = do("PUT", "/api/v1/schedules/report", `{"cron":"@hourly","target":{"command":"rm report.txt","workdir":"`+workDir+`"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do("POST", "/api/v1/schedules/report/trigger", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+	run = waitRun("report", run.ID)
+	assert.Equal(t, schedule.StatusFailed, run.Status)
+	assert.Contains(t, run.Error, "denied")
+	assert.FileExists(t, filepath.Join(workDir, "report.txt"))
+
+	// 工作流
+	w = do("POST", "/api/v1/schedules", `{"id":"flow","cron":"@daily","target":{"workflow":{"steps":[{"id":"a","run":"echo step"}]}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = do("POST", "/api/v1/schedules/flow/trigger", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+	run = waitRun("flow", run.ID)
+	assert.Equal(t, schedule.StatusSucceeded, run.Status)
+	assert.Contains(t, run.Output, "step\n")
+
+	w = do("GET", "/api/v1/schedules", "")
+	var schedules []schedule.Schedule
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &schedules))
+	assert.Len(t, schedules, 2)
+	w = do("GET", "/api/v1/schedules/report/runs", "")
+	var runs []schedule.Run
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &runs))
+	assert.Len(t, runs, 2)
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/api/v1/schedules/report", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/v1/schedules/report", "").Code)
+	assert.Equal(t, http.StatusNotFound, do("POST", "/api/v1/schedules/report/trigger", "").Code)
+}
+
+func TestDockerSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	local := executor.NewLocalExecutorBuilder(types.LocalConfig{AllowUnregisteredCommands: true})
+	var built types.DockerConfig
+	s := NewServer(local, ":0").WithDocker(types.DockerConfig{
+		Image:      "ubuntu:latest",
+		Memory:     256 << 20,
+		CapDrop:    []string{"NET_RAW"},
+		Ulimits:    []types.Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+		Labels:     map[string]string{"owner": "runshell"},
+		Tmpfs:      map[string]string{"/tmp": "size=64m,noexec"},
+		DNS:        []string{"10.0.0.1", "10.0.0.2"},
+		ExtraHosts: []string{"registry:10.0.0.3"},
+	}, DockerLimits{
+		MaxMemory: 1 << 30,
+		MaxCPUs:   2,
+		Images:    []string{"python:3"},
+	}, func(config types.DockerConfig) types.ExecutorBuilder {
+		built = config
+		return local
+	})
+
+	create := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.engine.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/sessions", strings.NewReader(body)))
+		return w
+	}
+
+	// 在上限内定制容器，隔离设置只能收紧
+	w := create(`{"executor_type":"docker","docker_config":{"image":"python:3","memory":536870912,"network_mode":"none","cap_drop":["ALL"],"read_only":true,"ulimits":[{"name":"nofile","soft":512,"hard":512}],"labels":{"owner":"me","team":"a"}}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "python:3", built.Image)
+	assert.Equal(t, int64(512<<20), built.Memory)
+	assert.Equal(t, 2.0, built.CPUs)
+	assert.Equal(t, "none", built.NetworkMode)
+	assert.True(t, built.ReadOnly)
+	assert.Equal(t, []string{"NET_RAW", "ALL"}, built.CapDrop)
+	assert.Equal(t, []types.Ulimit{{Name: "nofile", Soft: 512, Hard: 512}}, built.Ulimits)
+	assert.Equal(t, map[string]string{"owner": "runshell", "team": "a"}, built.Labels)
+
+	// tmpfs、hosts 和 DNS 只能从默认配置中选择
+	w = create(`{"docker_config":{"tmpfs":{"/tmp":""},"extra_hosts":["registry:10.0.0.3"],"dns":["10.0.0.2"]}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, map[string]string{"/tmp": "size=64m,noexec"}, built.Tmpfs)
+	assert.Equal(t, []string{"registry:10.0.0.3"}, built.ExtraHosts)
+	assert.Equal(t, []string{"10.0.0.2"}, built.DNS)
+
+	// 未指定时使用默认配置
+	w = create(`{"docker_config":{}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "ubuntu:latest", built.Image)
+	assert.Equal(t, int64(256<<20), built.Memory)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, built.DNS)
+
+	// 超出上限或放宽隔离设置
+	for _, body := range []string{
+		`{"docker_config":{"memory":2147483648}}`,
+		`{"docker_config":{"cpus":4}}`,
+		`{"docker_config":{"image":"evil:latest"}}`,
+		`{"docker_config":{"network_mode":"host"}}`,
+		`{"docker_config":{"security_opt":["seccomp=unconfined"]}}`,
+		`{"docker_config":{"ulimits":[{"name":"nofile","soft":4096,"hard":4096}]}}`,
+		`{"docker_config":{"ulimits":[{"name":"nproc","soft":10,"hard":10}]}}`,
+		`{"docker_config":{"user":"root"}}`,
+		`{"docker_config":{"bind_mount":"/:/host"}}`,
+		`{"docker_config":{"tmpfs":{"/tmp":"size=1g,exec"}}}`,
+		`{"docker_config":{"tmpfs":{"/run":""}}}`,
+		`{"docker_config":{"extra_hosts":["registry:6.6.6.6"]}}`,
+		`{"docker_config":{"dns":["8.8.8.8"]}}`,
+		`{"executor_type":"ssh","docker_config":{}}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, create(body).Code, body)
+	}
+
+	// 未启用时拒绝定制容器
+	s = NewServer(local, ":0")
+	assert.Equal(t, http.StatusBadRequest, create(`{"docker_config":{"image":"python:3"}}`).Code)
+}
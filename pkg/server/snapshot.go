@@ -0,0 +1,269 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了会话工作目录快照相关的处理函数。
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/snapshot"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// SnapshotHeader 自动快照创建后，在响应头中返回快照 ID
+const SnapshotHeader = "X-Runshell-Snapshot"
+
+// SnapshotRequest 表示创建快照的请求
+// swagger:model
+type SnapshotRequest struct {
+	Reason string `json:"reason,omitempty" example:"before refactor"` // 创建原因
+}
+
+// SnapshotDiffResponse 表示快照差异的响应
+// swagger:model
+type SnapshotDiffResponse struct {
+	From    string             `json:"from"`         // 起始快照 ID
+	To      string             `json:"to,omitempty"` // 目标快照 ID，为空表示当前工作目录
+	Changes []types.FileChange `json:"changes"`      // 变更列表
+}
+
+// snapshotSession 获取会话以及快照所需的归档能力
+func (s *Server) snapshotSession(c *gin.Context) (*types.Session, types.WorkDirArchiver, bool) {
+	if s.snapshots == nil {
+		s.handleError(c, http.StatusNotImplemented, fmt.Errorf("snapshots are not enabled"), "")
+		return nil, nil, false
+	}
+
+	session, err := s.sessionManager.GetSession(c.Param("id"))
+	if err != nil {
+		s.handleError(c, http.StatusNotFound, err, "")
+		return nil, nil, false
+	}
+
+	archiver, ok := types.As[types.WorkDirArchiver](session.Executor)
+	if !ok {
+		s.handleError(c, http.StatusNotImplemented, fmt.Errorf("executor %s does not support snapshots", session.Executor.Name()), "")
+		return nil, nil, false
+	}
+	return session, archiver, true
+}
+
+// sessionWorkDir 返回会话的工作目录
+func sessionWorkDir(session *types.Session) string {
+	if session.Options != nil {
+		return session.Options.WorkDir
+	}
+	return ""
+}
+
+// createSnapshot 为会话创建快照
+func (s *Server) createSnapshot(ctx context.Context, session *types.Session, reason string) (*snapshot.Snapshot, error) {
+	archiver, ok := types.As[types.WorkDirArchiver](session.Executor)
+	if !ok {
+		return nil, fmt.Errorf("executor %s does not support snapshots", session.Executor.Name())
+	}
+	return s.snapshots.Create(ctx, session.ID, archiver, sessionWorkDir(session), reason)
+}
+
+// deleteSessionSnapshots 删除会话的所有快照
+func (s *Server) deleteSessionSnapshots(sessionID string) {
+	if s.snapshots == nil {
+		return
+	}
+	if err := s.snapshots.DeleteSession(sessionID); err != nil {
+		log.Error("Failed to delete snapshots of session %s: %v", sessionID, err)
+	}
+}
+
+// @Summary     List Snapshots
+// @Description List the workdir snapshots of a session
+// @Tags        snapshots
+// @Produce     json
+// @Param       id path string true "Session ID"
+// @Success     200 {array} snapshot.Snapshot
+// @Failure     404 {object} ErrorResponse
+// @Failure     501 {object} ErrorResponse
+// @Router      /sessions/{id}/snapshots [get]
+func (s *Server) handleListSnapshots(c *gin.Context) {
+	session, _, ok := s.snapshotSession(c)
+	if !ok {
+		return
+	}
+
+	snaps, err := s.snapshots.List(session.ID)
+	if err != nil {
+		s.handleError(c, http.StatusInternalServerError, err, "")
+		return
+	}
+	if snaps == nil {
+		snaps = []*snapshot.Snapshot{}
+	}
+	c.JSON(http.StatusOK, snaps)
+}
+
+// @Summary     Create Snapshot
+// @Description Capture the session workdir
+// @Tags        snapshots
+// @Accept      json
+// @Produce     json
+// @Param       id path string true "Session ID"
+// @Param       request body SnapshotRequest false "Snapshot creation request"
+// @Success     201 {object} snapshot.Snapshot
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Failure     501 {object} ErrorResponse
+// @Router      /sessions/{id}/snapshots [post]
+func (s *Server) handleCreateSnapshot(c *gin.Context) {
+	session, _, ok := s.snapshotSession(c)
+	if !ok {
+		return
+	}
+
+	var req SnapshotRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			s.handleError(c, http.StatusBadRequest, err, "Invalid request format")
+			return
+		}
+	}
+
+	snap, err := s.createSnapshot(c.Request.Context(), session, req.Reason)
+	if err != nil {
+		s.handleError(c, http.StatusInternalServerError, err, "")
+		return
+	}
+	c.JSON(http.StatusCreated, snap)
+}
+
+// @Summary     Get Snapshot
+// @Description Get a snapshot including its file manifest
+// @Tags        snapshots
+// @Produce     json
+// @Param       id path string true "Session ID"
+// @Param       sid path string true "Snapshot ID"
+// @Success     200 {object} snapshot.Snapshot
+// @Failure     404 {object} ErrorResponse
+// @Failure     501 {object} ErrorResponse
+// @Router      /sessions/{id}/snapshots/{sid} [get]
+func (s *Server) handleGetSnapshot(c *gin.Context) {
+	session, _, ok := s.snapshotSession(c)
+	if !ok {
+		return
+	}
+
+	snap, err := s.snapshots.Get(session.ID, c.Param("sid"))
+	if err != nil {
+		s.handleError(c, snapshotErrorStatus(err), err, "")
+		return
+	}
+	c.JSON(http.StatusOK, snap)
+}
+
+// @Summary     Delete Snapshot
+// @Description Delete a snapshot
+// @Tags        snapshots
+// @Param       id path string true "Session ID"
+// @Param       sid path string true "Snapshot ID"
+// @Success     204 "No Content"
+// @Failure     404 {object} ErrorResponse
+// @Failure     501 {object} ErrorResponse
+// @Router      /sessions/{id}/snapshots/{sid} [delete]
+func (s *Server) handleDeleteSnapshot(c *gin.Context) {
+	session, _, ok := s.snapshotSession(c)
+	if !ok {
+		return
+	}
+
+	if err := s.snapshots.Delete(session.ID, c.Param("sid")); err != nil {
+		s.handleError(c, snapshotErrorStatus(err), err, "")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary     Restore Snapshot
+// @Description Roll the session workdir back to a snapshot
+// @Tags        snapshots
+// @Produce     json
+// @Param       id path string true "Session ID"
+// @Param       sid path string true "Snapshot ID"
+// @Success     200 {object} snapshot.Snapshot
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Failure     501 {object} ErrorResponse
+// @Router      /sessions/{id}/snapshots/{sid}/restore [post]
+func (s *Server) handleRestoreSnapshot(c *gin.Context) {
+	session, archiver, ok := s.snapshotSession(c)
+	if !ok {
+		return
+	}
+
+	snap, err := s.snapshots.Get(session.ID, c.Param("sid"))
+	if err != nil {
+		s.handleError(c, snapshotErrorStatus(err), err, "")
+		return
+	}
+
+	if err := s.snapshots.Restore(c.Request.Context(), session.ID, snap.ID, archiver, sessionWorkDir(session)); err != nil {
+		s.handleError(c, http.StatusInternalServerError, err, "")
+		return
+	}
+	s.invalidateCache(session.ID)
+	snap.Entries = nil
+	c.JSON(http.StatusOK, snap)
+}
+
+// @Summary     Diff Snapshot
+// @Description Compare a snapshot with another snapshot or with the current workdir
+// @Tags        snapshots
+// @Produce     json
+// @Param       id path string true "Session ID"
+// @Param       sid path string true "Snapshot ID"
+// @Param       to query string false "Target snapshot ID, defaults to the current workdir"
+// @Success     200 {object} SnapshotDiffResponse
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Failure     501 {object} ErrorResponse
+// @Router      /sessions/{id}/snapshots/{sid}/diff [get]
+func (s *Server) handleDiffSnapshot(c *gin.Context) {
+	session, archiver, ok := s.snapshotSession(c)
+	if !ok {
+		return
+	}
+
+	from, to := c.Param("sid"), c.Query("to")
+
+	var changes []types.FileChange
+	var err error
+	if to == "" {
+		changes, err = s.snapshots.DiffCurrent(c.Request.Context(), session.ID, from, archiver, sessionWorkDir(session))
+	} else {
+		changes, err = s.snapshots.Diff(session.ID, from, to)
+	}
+	if err != nil {
+		s.handleError(c, snapshotErrorStatus(err), err, "")
+		return
+	}
+	if changes == nil {
+		changes = []types.FileChange{}
+	}
+
+	c.JSON(http.StatusOK, SnapshotDiffResponse{
+		From:    from,
+		To:      to,
+		Changes: changes,
+	})
+}
+
+// snapshotErrorStatus 返回快照操作错误对应的状态码，只有快照不存在时返回 404
+func snapshotErrorStatus(err error) int {
+	if errors.Is(err, snapshot.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,115 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了命令输出的流式返回。
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// StreamContentType 流式执行响应的内容类型，每行一个 ExecStreamEvent
+const StreamContentType = "application/x-ndjson"
+
+// ExecStreamEvent 表示流式执行中的一个事件。
+// 执行过程中返回标准输出和标准错误的数据块，最后一个事件包含 Result。
+// swagger:model
+type ExecStreamEvent struct {
+	Stdout     string        `json:"stdout,omitempty"`      // 标准输出数据块
+	Stderr     string        `json:"stderr,omitempty"`      // 标准错误数据块
+	Result     *ExecResponse `json:"result,omitempty"`      // 执行结果，输出已通过数据块返回时 Output 为空
+	SnapshotID string        `json:"snapshot_id,omitempty"` // 自动快照 ID
+	Error      string        `json:"error,omitempty"`       // 输出开始后执行失败且没有结果时的错误信息
+}
+
+// eventWriter 将写入的数据作为事件发送
+type eventWriter struct {
+	stream *eventStream
+	stderr bool
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	event := &ExecStreamEvent{Stdout: string(p)}
+	if w.stderr {
+		event = &ExecStreamEvent{Stderr: string(p)}
+	}
+	if err := w.stream.send(event); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// eventStream 串行化事件的写入，在第一个事件发送时才写入响应头，
+// 因此输出开始前的错误仍可以使用对应的状态码返回
+type eventStream struct {
+	c       *gin.Context
+	mu      sync.Mutex
+	started bool
+}
+
+func (s *eventStream) send(event *ExecStreamEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		s.c.Header("Content-Type", StreamContentType)
+		s.c.Status(http.StatusOK)
+		s.started = true
+	}
+	if err := json.NewEncoder(s.c.Writer).Encode(event); err != nil {
+		return err
+	}
+	s.c.Writer.Flush()
+	return nil
+}
+
+// streamExec 执行命令并以换行分隔的 JSON 事件流式返回输出
+func (s *Server) streamExec(c *gin.Context, opts *types.ExecuteOptions, run func() (*types.ExecuteResult, string, error)) {
+	stream := &eventStream{c: c}
+	opts.Stdout = &eventWriter{stream: stream}
+	opts.Stderr = &eventWriter{stream: stream, stderr: true}
+
+	result, snapshotID, err := run()
+
+	stream.mu.Lock()
+	started := stream.started
+	stream.mu.Unlock()
+
+	if result == nil {
+		if err == nil {
+			err = fmt.Errorf("executor returned no result")
+		}
+		if !started {
+			if snapshotID != "" {
+				c.Header(SnapshotHeader, snapshotID)
+			}
+			s.handleError(c, execStatus(err), err, "")
+			return
+		}
+		log.Error("Streaming command execution failed: %v", err)
+		_ = stream.send(&ExecStreamEvent{Error: err.Error(), SnapshotID: snapshotID})
+		return
+	}
+
+	response := newExecResponse(result)
+	if err != nil && response.Error == "" {
+		response.Error = err.Error()
+	}
+	// 输出已通过数据块返回，仅在命令没有写入输出流时在结果中返回输出
+	if started {
+		response.Output = ""
+	}
+	if snapshotID != "" && !started {
+		c.Header(SnapshotHeader, snapshotID)
+	}
+	if err := stream.send(&ExecStreamEvent{Result: response, SnapshotID: snapshotID}); err != nil {
+		log.Error("Failed to send execution result: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了将命令导出为 LLM 工具的处理函数。
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/tools"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// ScriptRunner 列出并执行脚本，通常为 script.ScriptManager
+type ScriptRunner interface {
+	ListCommands() []types.CommandInfo
+	Execute(ctx *types.ExecuteContext) (*types.ExecuteResult, error)
+}
+
+// toolRegistry 根据当前的内置命令和脚本构建工具注册表
+func (s *Server) toolRegistry() (*tools.Registry, error) {
+	executor, err := s.executorBuilder.Build(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	var scripts []types.CommandInfo
+	if s.scripts != nil {
+		scripts = s.scripts.ListCommands()
+	}
+	return tools.NewRegistry(executor.ListCommands(), scripts), nil
+}
+
+// @Summary     List Tools
+// @Description List all builtin commands and scripts as LLM function-calling tool definitions
+// @Tags        tools
+// @Accept      json
+// @Produce     json
+// @Param       format query string false "Tool definition format" Enums(openai, anthropic, jsonschema)
+// @Success     200 {array} object
+// @Failure     400 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /tools [get]
+func (s *Server) handleListTools(c *gin.Context) {
+	registry, err := s.toolRegistry()
+	if err != nil {
+		s.handleError(c, http.StatusInternalServerError, err, "")
+		return
+	}
+
+	definitions, err := tools.Render(registry.Tools(), c.DefaultQuery("format", tools.FormatJSONSchema))
+	if err != nil {
+		s.handleError(c, http.StatusBadRequest, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, definitions)
+}
+
+// @Summary     Call Tool
+// @Description Execute a tool call produced by an LLM and return a tool result message. Accepts OpenAI and Anthropic tool call payloads; the result format follows the payload unless format is given
+// @Tags        tools
+// @Accept      json
+// @Produce     json
+// @Param       format query string false "Tool result format" Enums(openai, anthropic, jsonschema)
+// @Param       request body tools.Call true "Tool call"
+// @Success     200 {object} tools.Output
+// @Failure     400 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /tools/call [post]
+func (s *Server) handleCallTool(c *gin.Context) {
+	var call tools.Call
+	if err := c.ShouldBindJSON(&call); err != nil {
+		s.handleError(c, http.StatusBadRequest, err, "Invalid request format")
+		return
+	}
+	if call.ToolName() == "" {
+		s.handleError(c, http.StatusBadRequest, fmt.Errorf("tool name is required"), "")
+		return
+	}
+
+	format := c.DefaultQuery("format", call.Format())
+	switch format {
+	case tools.FormatOpenAI, tools.FormatAnthropic, tools.FormatJSONSchema:
+	default:
+		s.handleError(c, http.StatusBadRequest, fmt.Errorf("unsupported tool format: %s", format), "")
+		return
+	}
+
+	registry, err := s.toolRegistry()
+	if err != nil {
+		s.handleError(c, http.StatusInternalServerError, err, "")
+		return
+	}
+	tool, ok := registry.Lookup(call.ToolName())
+	if !ok {
+		s.handleError(c, http.StatusNotFound, fmt.Errorf("tool not found: %s", call.ToolName()), "")
+		return
+	}
+
+	// 参数错误和执行失败都以工具结果返回，便于模型据此修正调用
+	output := &tools.Output{ID: call.ID, Name: tool.Name}
+	result, err := s.callTool(c, tool, &call)
+	if err != nil {
+		log.Debug("Tool call %s failed: %v", tool.Name, err)
+		output.Error = err.Error()
+		output.IsError = true
+		if result == nil {
+			output.ExitCode = -1
+		}
+	}
+	if result != nil {
+		output.ExitCode = result.ExitCode
+		output.Output = result.Output
+		output.Data = result.Data
+		if result.Error != nil && output.Error == "" {
+			output.Error = result.Error.Error()
+		}
+		if result.ExitCode != 0 || result.Error != nil {
+			output.IsError = true
+		}
+	}
+
+	c.JSON(http.StatusOK, output.Envelope(format))
+}
+
+// callTool 将工具调用映射为命令并执行，指定会话时在会话中执行
+func (s *Server) callTool(c *gin.Context, tool *tools.Tool, call *tools.Call) (*types.ExecuteResult, error) {
+	args, err := call.Args()
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := tool.Command(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var outputBuf bytes.Buffer
+	opts := &types.ExecuteOptions{
+		WorkDir: call.WorkDir,
+		Stdout:  &outputBuf,
+		Stderr:  &outputBuf,
+	}
+
+	var result *types.ExecuteResult
+	switch {
+	case tool.Script:
+		if call.SessionID != "" {
+			return nil, fmt.Errorf("scripts cannot be called in a session")
+		}
+		result, err = s.scripts.Execute(&types.ExecuteContext{
+			Context: c.Request.Context(),
+			Command: cmd,
+			Options: opts,
+		})
+	case call.SessionID != "":
+		session, getErr := s.sessionManager.GetSession(call.SessionID)
+		if getErr != nil {
+			return nil, getErr
+		}
+		if session.Options != nil && session.Options.Env != nil {
+			opts.Env = session.Options.Env
+		}
+		var snapshotID string
+		result, snapshotID, err = s.executeInSession(c.Request.Context(), session, cmd, opts)
+		if snapshotID != "" {
+			c.Header(SnapshotHeader, snapshotID)
+		}
+	default:
+		result, err = s.execute(c.Request.Context(), cmd, opts)
+	}
+	if result != nil && result.Output == "" {
+		result.Output = outputBuf.String()
+	}
+	return result, err
+}
@@ -0,0 +1,171 @@
+// Package server 实现了 RunShell 的 HTTP API 服务。
+// 本文件实现了工作流运行相关的处理函数。
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/iamlongalong/runshell/pkg/workflow"
+)
+
+// WorkflowRunRequest 表示运行工作流的请求。
+// 请求体的内容类型为 application/yaml 时，整个请求体作为工作流定义，其他参数通过查询参数传递。
+// swagger:model
+type WorkflowRunRequest struct {
+	Workflow  *workflow.Workflow `json:"workflow" binding:"required"` // 工作流定义
+	SessionID string             `json:"session_id,omitempty"`        // 在会话中运行
+	WorkDir   string             `json:"workdir,omitempty"`           // 工作目录，工作流中的相对路径相对于该目录
+	Env       map[string]string  `json:"env,omitempty"`               // 环境变量，工作流和步骤中的环境变量覆盖这里的设置
+}
+
+// WithWorkflowStore 设置保存工作流运行状态的存储，默认保存在内存中
+func (s *Server) WithWorkflowStore(store workflow.Store) *Server {
+	s.workflows = store
+	return s
+}
+
+// bindWorkflowRunRequest 解析 JSON 或 YAML 格式的运行请求
+func bindWorkflowRunRequest(c *gin.Context) (*WorkflowRunRequest, error) {
+	contentType := c.ContentType()
+	if !strings.Contains(contentType, "yaml") {
+		var req WorkflowRunRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, err
+		}
+		return &req, nil
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	wf, err := workflow.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return &WorkflowRunRequest{
+		Workflow:  wf,
+		SessionID: c.Query("session_id"),
+		WorkDir:   c.Query("workdir"),
+	}, nil
+}
+
+// @Summary     Run Workflow
+// @Description Run a workflow of dependent steps and return the final run state. The body is either a JSON request or, with content type application/yaml, the workflow definition itself
+// @Tags        workflows
+// @Accept      json
+// @Accept      application/yaml
+// @Produce     json
+// @Param       request body WorkflowRunRequest true "Workflow run request"
+// @Param       session_id query string false "Session to run in, for YAML bodies"
+// @Param       workdir query string false "Working directory, for YAML bodies"
+// @Param       stream query bool false "Stream progress as newline-delimited JSON events"
+// @Success     200 {object} workflow.Run
+// @Failure     400 {object} ErrorResponse
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /workflows/runs [post]
+func (s *Server) handleRunWorkflow(c *gin.Context) {
+	req, err := bindWorkflowRunRequest(c)
+	if err != nil {
+		s.handleError(c, http.StatusBadRequest, err, "Invalid request format")
+		return
+	}
+	if err := req.Workflow.Validate(); err != nil {
+		s.handleError(c, http.StatusBadRequest, err, "")
+		return
+	}
+
+	options := &types.ExecuteOptions{WorkDir: req.WorkDir, Env: req.Env}
+	var exec types.Executor
+	if req.SessionID != "" {
+		session, err := s.sessionManager.GetSession(req.SessionID)
+		if err != nil {
+			s.handleError(c, http.StatusNotFound, err, "")
+			return
+		}
+		if options.WorkDir == "" {
+			options.WorkDir = sessionWorkDir(session)
+		}
+		exec = session.Executor
+	} else {
+		built, err := s.buildExecutor(s.executorBuilder, &types.ExecuteOptions{WorkDir: req.WorkDir, Env: req.Env})
+		if err != nil {
+			s.handleError(c, http.StatusInternalServerError, err, "Failed to create executor")
+			return
+		}
+		defer built.Close()
+		exec = built
+	}
+
+	runner := workflow.NewRunner(exec).WithStore(s.workflows).WithOptions(options)
+	if c.Query("stream") != "true" {
+		run, err := runner.Run(c.Request.Context(), req.Workflow, nil)
+		if err != nil {
+			s.handleError(c, http.StatusBadRequest, err, "")
+			return
+		}
+		c.JSON(http.StatusOK, run)
+		return
+	}
+
+	// 事件由运行器串行传递，依次写入响应
+	c.Header("Content-Type", StreamContentType)
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	_, err = runner.Run(c.Request.Context(), req.Workflow, func(event *workflow.Event) {
+		if err := enc.Encode(event); err != nil {
+			log.Debug("Failed to send workflow event: %v", err)
+			return
+		}
+		c.Writer.Flush()
+	})
+	if err != nil {
+		log.Error("Failed to run workflow: %v", err)
+	}
+}
+
+// @Summary     List Workflow Runs
+// @Description List workflow runs, newest first
+// @Tags        workflows
+// @Produce     json
+// @Success     200 {array} workflow.Run
+// @Failure     500 {object} ErrorResponse
+// @Router      /workflows/runs [get]
+func (s *Server) handleListWorkflowRuns(c *gin.Context) {
+	runs, err := s.workflows.List()
+	if err != nil {
+		s.handleError(c, http.StatusInternalServerError, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// @Summary     Get Workflow Run
+// @Description Get the state of a workflow run, including runs still in progress
+// @Tags        workflows
+// @Produce     json
+// @Param       id path string true "Run ID"
+// @Success     200 {object} workflow.Run
+// @Failure     404 {object} ErrorResponse
+// @Failure     500 {object} ErrorResponse
+// @Router      /workflows/runs/{id} [get]
+func (s *Server) handleGetWorkflowRun(c *gin.Context) {
+	run, err := s.workflows.Get(c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, workflow.ErrRunNotFound) {
+			status = http.StatusNotFound
+		}
+		s.handleError(c, status, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
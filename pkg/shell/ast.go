@@ -0,0 +1,263 @@
+// Package shell 实现了 POSIX shell 子集的词法分析和语法分析。
+// 解析结果为抽象语法树，由执行器解释执行，也可供策略和审计使用。
+//
+// 支持的语法：
+//   - 命令列表：以 ; 或换行分隔，&& 和 || 连接的条件执行
+//   - 管道：| 连接的命令，! 取反退出码
+//   - 子 shell：( list )
+//   - 重定向：<、>、>>、N>&M、N<&M、&>，N 为 0、1、2
+//   - 命令前的环境变量赋值：FOO=1 cmd，单独的赋值设置 shell 变量
+//   - 单引号、双引号、反斜杠转义和 # 注释
+//   - 变量展开：$NAME、${NAME}、$?，行首的 ~ 展开为 $HOME
+//   - 未加引号的变量结果按空白拆分字段，未加引号的 *、?、[...] 进行路径名展开
+//
+// 命令替换、算术展开、here-doc、后台执行、函数以及 if/for/while/case 等复合命令
+// 返回 *UnsupportedError。
+package shell
+
+import (
+	"strconv"
+	"strings"
+
+	"al.essio.dev/pkg/shellescape"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// Script 表示解析后的脚本，由按顺序执行的语句组成
+type Script struct {
+	Stmts []*AndOr
+}
+
+// AndOr 表示由 && 和 || 连接的管道，按从左到右的顺序求值
+type AndOr struct {
+	Pipelines []*Pipeline
+	Ops       []string // Ops[i] 连接 Pipelines[i] 和 Pipelines[i+1]，取值为 "&&" 或 "||"
+}
+
+// Pipeline 表示由 | 连接的命令
+type Pipeline struct {
+	Negated  bool // 以 ! 开头，退出码取反
+	Commands []Command
+}
+
+// Command 表示管道中的一个命令，*SimpleCommand 或 *Subshell
+type Command interface {
+	String() string
+	command()
+}
+
+// SimpleCommand 表示简单命令
+type SimpleCommand struct {
+	Assigns   []*Assign   // 命令前的变量赋值
+	Words     []*Word     // 命令名称和参数，只有赋值或重定向时为空
+	Redirects []*Redirect // 按出现顺序排列的重定向
+}
+
+// Subshell 表示在子 shell 中执行的命令列表，其中的变量和工作目录变化不影响外层
+type Subshell struct {
+	Body      *Script
+	Redirects []*Redirect
+}
+
+// Assign 表示变量赋值 NAME=value
+type Assign struct {
+	Name  string
+	Value *Word
+}
+
+// 重定向操作符
+const (
+	RedirectIn     = "<"  // 从文件读取
+	RedirectOut    = ">"  // 写入文件
+	RedirectAppend = ">>" // 追加到文件
+	RedirectDupOut = ">&" // 复制输出文件描述符，如 2>&1
+	RedirectDupIn  = "<&" // 复制输入文件描述符
+	RedirectAll    = "&>" // 标准输出和标准错误都写入文件
+)
+
+// Redirect 表示重定向
+type Redirect struct {
+	Fd     int    // 被重定向的文件描述符
+	Op     string // 重定向操作符
+	Target *Word  // 文件名，复制文件描述符时为目标文件描述符
+}
+
+// Word 表示一个单词，由相邻的字面量和变量引用组成
+type Word struct {
+	Parts []WordPart
+}
+
+// WordPart 表示单词的一部分，*Literal 或 *Param
+type WordPart interface {
+	wordPart()
+}
+
+// Literal 表示字面量，Quoted 为 true 时来自引号或转义，不进行路径名展开
+type Literal struct {
+	Value  string
+	Quoted bool
+}
+
+// Param 表示变量引用，Quoted 为 true 时位于双引号内，不进行字段拆分和路径名展开
+type Param struct {
+	Name   string
+	Quoted bool
+}
+
+func (*SimpleCommand) command() {}
+func (*Subshell) command()      {}
+func (*Literal) wordPart()      {}
+func (*Param) wordPart()        {}
+
+// Pipeline 在脚本只包含一个管道时返回该管道，否则返回 nil
+func (s *Script) Pipeline() *Pipeline {
+	if len(s.Stmts) != 1 || len(s.Stmts[0].Pipelines) != 1 {
+		return nil
+	}
+	return s.Stmts[0].Pipelines[0]
+}
+
+// Commands 返回脚本中的所有简单命令（包括子 shell 中的命令），供策略等在执行前检查。
+// 单词不进行展开，包含变量的单词使用其在脚本中的写法。
+func (s *Script) Commands() []types.Command {
+	var cmds []types.Command
+	for _, stmt := range s.Stmts {
+		for _, p := range stmt.Pipelines {
+			for _, cmd := range p.Commands {
+				switch c := cmd.(type) {
+				case *SimpleCommand:
+					if len(c.Words) == 0 {
+						continue
+					}
+					args := make([]string, len(c.Words)-1)
+					for i, w := range c.Words[1:] {
+						args[i] = w.Value()
+					}
+					cmds = append(cmds, types.Command{Command: c.Words[0].Value(), Args: args})
+				case *Subshell:
+					cmds = append(cmds, c.Body.Commands()...)
+				}
+			}
+		}
+	}
+	return cmds
+}
+
+// String 返回脚本的规范化文本
+func (s *Script) String() string {
+	stmts := make([]string, len(s.Stmts))
+	for i, stmt := range s.Stmts {
+		stmts[i] = stmt.String()
+	}
+	return strings.Join(stmts, "; ")
+}
+
+func (a *AndOr) String() string {
+	var b strings.Builder
+	for i, p := range a.Pipelines {
+		if i > 0 {
+			b.WriteString(" " + a.Ops[i-1] + " ")
+		}
+		b.WriteString(p.String())
+	}
+	return b.String()
+}
+
+func (p *Pipeline) String() string {
+	cmds := make([]string, len(p.Commands))
+	for i, cmd := range p.Commands {
+		cmds[i] = cmd.String()
+	}
+	s := strings.Join(cmds, " | ")
+	if p.Negated {
+		s = "! " + s
+	}
+	return s
+}
+
+func (c *SimpleCommand) String() string {
+	var parts []string
+	for _, a := range c.Assigns {
+		parts = append(parts, a.String())
+	}
+	for _, w := range c.Words {
+		parts = append(parts, w.String())
+	}
+	for _, r := range c.Redirects {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c *Subshell) String() string {
+	parts := []string{"(" + c.Body.String() + ")"}
+	for _, r := range c.Redirects {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (a *Assign) String() string {
+	return a.Name + "=" + a.Value.String()
+}
+
+func (r *Redirect) String() string {
+	if r.Op == RedirectAll {
+		return r.Op + r.Target.String()
+	}
+	fd := ""
+	if (r.Op == RedirectIn || r.Op == RedirectDupIn) && r.Fd != 0 ||
+		(r.Op != RedirectIn && r.Op != RedirectDupIn) && r.Fd != 1 {
+		fd = strconv.Itoa(r.Fd)
+	}
+	return fd + r.Op + r.Target.String()
+}
+
+// String 返回单词在 shell 中的写法，加引号的部分使用单引号
+func (w *Word) String() string {
+	var b strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *Literal:
+			if p.Quoted {
+				b.WriteString(shellescape.Quote(p.Value))
+			} else {
+				b.WriteString(p.Value)
+			}
+		case *Param:
+			if p.Quoted {
+				b.WriteString(`"${` + p.Name + `}"`)
+			} else {
+				b.WriteString("${" + p.Name + "}")
+			}
+		}
+	}
+	return b.String()
+}
+
+// Value 返回去掉引号后的单词，变量引用保持 ${NAME} 的形式
+func (w *Word) Value() string {
+	var b strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *Literal:
+			b.WriteString(p.Value)
+		case *Param:
+			b.WriteString("${" + p.Name + "}")
+		}
+	}
+	return b.String()
+}
+
+// Literal 在单词不包含变量引用时返回去掉引号后的值
+func (w *Word) Literal() (string, bool) {
+	var b strings.Builder
+	for _, part := range w.Parts {
+		lit, ok := part.(*Literal)
+		if !ok {
+			return "", false
+		}
+		b.WriteString(lit.Value)
+	}
+	return b.String(), true
+}
@@ -0,0 +1,24 @@
+package shell
+
+import "context"
+
+// scriptKey 是保存脚本语法树的 context 键
+type scriptKey struct{}
+
+// WithScript 返回携带脚本语法树的 context，执行器在执行脚本中的每个命令时传递该 context，
+// 策略和审计中间件可以通过 ScriptFromContext 获取完整的脚本
+func WithScript(ctx context.Context, script *Script) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, scriptKey{}, script)
+}
+
+// ScriptFromContext 返回 context 中的脚本语法树，没有时返回 nil
+func ScriptFromContext(ctx context.Context) *Script {
+	if ctx == nil {
+		return nil
+	}
+	script, _ := ctx.Value(scriptKey{}).(*Script)
+	return script
+}
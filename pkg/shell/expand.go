@@ -0,0 +1,226 @@
+package shell
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Expander 展开单词
+type Expander struct {
+	// Lookup 返回变量的值，未设置的变量返回空字符串
+	Lookup func(name string) string
+	// ReadDir 返回目录中的文件名，用于路径名展开，dir 为 "." 时表示当前目录；为 nil 时不进行路径名展开
+	ReadDir func(dir string) ([]string, error)
+}
+
+// Fields 展开单词，依次进行变量展开、字段拆分和路径名展开。
+// 没有匹配文件的模式保持原样。
+func (x *Expander) Fields(words []*Word) []string {
+	var fields []string
+	for _, w := range words {
+		fields = append(fields, x.fields(w)...)
+	}
+	return fields
+}
+
+// Literal 展开单词为一个字符串，只进行变量展开，用于赋值和重定向目标
+func (x *Expander) Literal(w *Word) string {
+	var b strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *Literal:
+			b.WriteString(p.Value)
+		case *Param:
+			b.WriteString(x.lookup(p.Name))
+		}
+	}
+	return b.String()
+}
+
+func (x *Expander) lookup(name string) string {
+	if x.Lookup == nil {
+		return ""
+	}
+	return x.Lookup(name)
+}
+
+// field 是展开过程中的一个字段
+type field struct {
+	value   strings.Builder
+	pattern strings.Builder // 通配符模式，加引号的部分已转义
+	glob    bool            // 是否包含未加引号的通配符
+	started bool            // 是否已有内容，空的引号也会产生字段
+}
+
+func (x *Expander) fields(w *Word) []string {
+	var fields []string
+	cur := &field{}
+	emit := func() {
+		if !cur.started {
+			return
+		}
+		if cur.glob && x.ReadDir != nil {
+			if matches := Glob(cur.pattern.String(), x.ReadDir); len(matches) > 0 {
+				fields = append(fields, matches...)
+				cur = &field{}
+				return
+			}
+		}
+		fields = append(fields, cur.value.String())
+		cur = &field{}
+	}
+	appendRaw := func(s string) {
+		cur.value.WriteString(s)
+		cur.pattern.WriteString(s)
+		if hasMeta(s) {
+			cur.glob = true
+		}
+		cur.started = true
+	}
+	appendQuoted := func(s string) {
+		cur.value.WriteString(s)
+		cur.pattern.WriteString(escapeMeta(s))
+		cur.started = true
+	}
+
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *Literal:
+			if p.Quoted {
+				appendQuoted(p.Value)
+			} else {
+				appendRaw(p.Value)
+			}
+		case *Param:
+			value := x.lookup(p.Name)
+			if p.Quoted {
+				appendQuoted(value)
+				continue
+			}
+			// 未加引号的变量按空白拆分为多个字段
+			if value != "" && isIFS(value[0]) {
+				emit()
+			}
+			for i, s := range strings.Fields(value) {
+				if i > 0 {
+					emit()
+				}
+				appendRaw(s)
+			}
+			if value != "" && isIFS(value[len(value)-1]) {
+				emit()
+			}
+		}
+	}
+	emit()
+	return fields
+}
+
+func isIFS(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// hasMeta 判断模式中是否包含未转义的通配符
+func hasMeta(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// escapeMeta 转义字符串中的通配符
+func escapeMeta(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`*?[\`, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// unescapeMeta 去掉模式中的转义
+func unescapeMeta(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Glob 返回匹配 pattern 的路径，按字典序排列。
+// pattern 使用 path.Match 的语法，以 / 分隔；* 和 ? 不匹配以 . 开头的文件名，除非模式也以 . 开头。
+// readDir 返回目录中的文件名，读取失败的目录视为没有匹配。
+func Glob(pattern string, readDir func(dir string) ([]string, error)) []string {
+	prefix := ""
+	if strings.HasPrefix(pattern, "/") {
+		prefix = "/"
+	}
+	matches := []string{prefix}
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment == "" {
+			continue
+		}
+		var next []string
+		for _, dir := range matches {
+			names, err := readDir(dirOf(dir))
+			if err != nil {
+				continue
+			}
+			if !hasMeta(segment) {
+				name := unescapeMeta(segment)
+				for _, n := range names {
+					if n == name {
+						next = append(next, joinPath(dir, n))
+						break
+					}
+				}
+				continue
+			}
+			for _, name := range names {
+				if strings.HasPrefix(name, ".") && !strings.HasPrefix(segment, ".") {
+					continue
+				}
+				if ok, err := path.Match(segment, name); err == nil && ok {
+					next = append(next, joinPath(dir, name))
+				}
+			}
+		}
+		matches = next
+		if len(matches) == 0 {
+			return nil
+		}
+	}
+	if len(matches) == 1 && (matches[0] == "" || matches[0] == "/") {
+		return nil
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func dirOf(p string) string {
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+func joinPath(dir, name string) string {
+	switch dir {
+	case "":
+		return name
+	case "/":
+		return "/" + name
+	}
+	return dir + "/" + name
+}
@@ -0,0 +1,92 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpanderFields(t *testing.T) {
+	vars := map[string]string{
+		"A":     "x",
+		"SPACE": " a  b ",
+		"EMPTY": "",
+		"STAR":  "*.txt",
+		"?":     "3",
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", ".hidden.txt", "c.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "d.txt"), nil, 0644))
+
+	x := &Expander{
+		Lookup: func(name string) string { return vars[name] },
+		ReadDir: func(name string) ([]string, error) {
+			entries, err := os.ReadDir(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, len(entries))
+			for i, e := range entries {
+				names[i] = e.Name()
+			}
+			return names, nil
+		},
+	}
+
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{`echo $A "$A" '$A' \$A`, []string{"echo", "x", "x", "$A", "$A"}},
+		{`echo pre${A}post`, []string{"echo", "prexpost"}},
+		{`echo $SPACE`, []string{"echo", "a", "b"}},
+		{`echo "$SPACE"`, []string{"echo", " a  b "}},
+		{`echo x${SPACE}y`, []string{"echo", "x", "a", "b", "y"}},
+		{`echo $EMPTY "$EMPTY" ''`, []string{"echo", "", ""}},
+		{`echo $?`, []string{"echo", "3"}},
+		{`ls *.txt`, []string{"ls", "a.txt", "b.txt"}},
+		{`ls .*.txt`, []string{"ls", ".hidden.txt"}},
+		{`ls "*.txt" \*.txt`, []string{"ls", "*.txt", "*.txt"}},
+		{`ls */*.txt`, []string{"ls", "sub/d.txt"}},
+		{`ls ?.go [ab].txt`, []string{"ls", "c.go", "a.txt", "b.txt"}},
+		{`ls *.none`, []string{"ls", "*.none"}},
+		{`ls $STAR`, []string{"ls", "a.txt", "b.txt"}},
+		{`ls "$STAR"`, []string{"ls", "*.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			script, err := Parse(tt.src)
+			require.NoError(t, err)
+			cmd := script.Pipeline().Commands[0].(*SimpleCommand)
+			assert.Equal(t, tt.want, x.Fields(cmd.Words))
+		})
+	}
+
+	script, err := Parse(`cat > "$A y".txt`)
+	require.NoError(t, err)
+	assert.Equal(t, "x y.txt", x.Literal(script.Pipeline().Commands[0].(*SimpleCommand).Redirects[0].Target))
+}
+
+func TestGlobAbsolute(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"), nil, 0644))
+	readDir := func(name string) ([]string, error) {
+		entries, err := os.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name()
+		}
+		return names, nil
+	}
+	assert.Equal(t, []string{filepath.ToSlash(dir) + "/a.log"}, Glob(filepath.ToSlash(dir)+"/*.log", readDir))
+	assert.Nil(t, Glob(filepath.ToSlash(dir)+"/*.txt", readDir))
+}
@@ -0,0 +1,618 @@
+package shell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyntaxError 表示脚本的语法错误，Pos 为出错位置的字节偏移
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at offset %d: %s", e.Pos, e.Msg)
+}
+
+// UnsupportedError 表示脚本使用了不支持的 shell 语法
+type UnsupportedError struct {
+	Pos       int
+	Construct string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("unsupported shell syntax at offset %d: %s", e.Pos, e.Construct)
+}
+
+// reservedWords 是不支持的复合命令和保留字
+var reservedWords = map[string]string{
+	"if":       "if statement",
+	"then":     "if statement",
+	"elif":     "if statement",
+	"else":     "if statement",
+	"fi":       "if statement",
+	"for":      "for loop",
+	"while":    "while loop",
+	"until":    "until loop",
+	"do":       "loop",
+	"done":     "loop",
+	"case":     "case statement",
+	"esac":     "case statement",
+	"select":   "select statement",
+	"function": "function definition",
+	"{":        "command group ({ ...; })",
+	"}":        "command group ({ ...; })",
+	"[[":       "conditional expression ([[ ... ]])",
+}
+
+// Parse 解析脚本，返回语法树。空脚本返回不包含语句的 Script。
+func Parse(src string) (*Script, error) {
+	p := &parser{lex: &lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	script, err := p.list(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return script, nil
+}
+
+// parser 是递归下降的语法分析器
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.op == op
+}
+
+func (p *parser) unexpected() error {
+	switch p.tok.kind {
+	case tokEOF:
+		return &SyntaxError{Pos: p.tok.pos, Msg: "unexpected end of input"}
+	case tokNewline:
+		return &SyntaxError{Pos: p.tok.pos, Msg: "unexpected newline"}
+	case tokOp:
+		return &SyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf("unexpected %q", p.tok.op)}
+	default:
+		return &SyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf("unexpected %q", p.tok.word.String())}
+	}
+}
+
+func (p *parser) skipNewlines() error {
+	for p.tok.kind == tokNewline {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// list 解析以 ; 或换行分隔的语句，sub 为 true 时在 ) 处结束
+func (p *parser) list(sub bool) (*Script, error) {
+	script := &Script{}
+	for {
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokEOF || (sub && p.isOp(")")) {
+			return script, nil
+		}
+		stmt, err := p.andOr()
+		if err != nil {
+			return nil, err
+		}
+		script.Stmts = append(script.Stmts, stmt)
+		if !p.isOp(";") && p.tok.kind != tokNewline {
+			return script, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) andOr() (*AndOr, error) {
+	pipeline, err := p.pipeline()
+	if err != nil {
+		return nil, err
+	}
+	andOr := &AndOr{Pipelines: []*Pipeline{pipeline}}
+	for p.isOp("&&") || p.isOp("||") {
+		andOr.Ops = append(andOr.Ops, p.tok.op)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+		if pipeline, err = p.pipeline(); err != nil {
+			return nil, err
+		}
+		andOr.Pipelines = append(andOr.Pipelines, pipeline)
+	}
+	return andOr, nil
+}
+
+func (p *parser) pipeline() (*Pipeline, error) {
+	pipeline := &Pipeline{}
+	if p.tok.kind == tokWord && p.tok.reserved() == "!" {
+		pipeline.Negated = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		cmd, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Commands = append(pipeline.Commands, cmd)
+		if !p.isOp("|") {
+			return pipeline, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) command() (Command, error) {
+	if p.isOp("(") {
+		pos := p.tok.pos
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		body, err := p.list(true)
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.unexpected()
+		}
+		if len(body.Stmts) == 0 {
+			return nil, &SyntaxError{Pos: pos, Msg: "empty subshell"}
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		subshell := &Subshell{Body: body}
+		for p.isRedirect() {
+			r, err := p.redirect()
+			if err != nil {
+				return nil, err
+			}
+			subshell.Redirects = append(subshell.Redirects, r)
+		}
+		return subshell, nil
+	}
+
+	if p.tok.kind == tokWord {
+		if construct, ok := reservedWords[p.tok.reserved()]; ok {
+			return nil, &UnsupportedError{Pos: p.tok.pos, Construct: construct}
+		}
+	}
+
+	cmd := &SimpleCommand{}
+	for {
+		switch {
+		case p.isRedirect():
+			r, err := p.redirect()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Redirects = append(cmd.Redirects, r)
+		case p.tok.kind == tokWord:
+			if assign := assignment(p.tok.word); assign != nil && len(cmd.Words) == 0 {
+				cmd.Assigns = append(cmd.Assigns, assign)
+			} else {
+				cmd.Words = append(cmd.Words, p.tok.word)
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		default:
+			if len(cmd.Assigns) == 0 && len(cmd.Words) == 0 && len(cmd.Redirects) == 0 {
+				return nil, p.unexpected()
+			}
+			if p.isOp("(") && len(cmd.Words) == 1 {
+				return nil, &UnsupportedError{Pos: p.tok.pos, Construct: "function definition"}
+			}
+			return cmd, nil
+		}
+	}
+}
+
+func (p *parser) isRedirect() bool {
+	if p.tok.kind == tokIONumber {
+		return true
+	}
+	if p.tok.kind != tokOp {
+		return false
+	}
+	switch p.tok.op {
+	case RedirectIn, RedirectOut, RedirectAppend, RedirectDupOut, RedirectDupIn, RedirectAll, ">|":
+		return true
+	}
+	return false
+}
+
+func (p *parser) redirect() (*Redirect, error) {
+	pos := p.tok.pos
+	fd := -1
+	if p.tok.kind == tokIONumber {
+		fd = p.tok.fd
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	op := p.tok.op
+	if op == ">|" {
+		op = RedirectOut
+	}
+	if fd < 0 {
+		fd = 1
+		if op == RedirectIn || op == RedirectDupIn {
+			fd = 0
+		}
+	}
+	if fd > 2 {
+		return nil, &UnsupportedError{Pos: pos, Construct: fmt.Sprintf("redirection of file descriptor %d", fd)}
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokWord {
+		if p.tok.kind == tokEOF {
+			return nil, &SyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf("missing file name after %s", op)}
+		}
+		return nil, p.unexpected()
+	}
+	target := p.tok.word
+	if op == RedirectDupOut || op == RedirectDupIn {
+		lit, _ := target.Literal()
+		if n, err := strconv.Atoi(lit); err != nil || n < 0 || n > 2 {
+			return nil, &UnsupportedError{Pos: p.tok.pos, Construct: fmt.Sprintf("%s%s", op, target.String())}
+		}
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	return &Redirect{Fd: fd, Op: op, Target: target}, nil
+}
+
+// assignment 在单词为 NAME=value 形式时返回赋值
+func assignment(w *Word) *Assign {
+	if len(w.Parts) == 0 {
+		return nil
+	}
+	lit, ok := w.Parts[0].(*Literal)
+	if !ok || lit.Quoted {
+		return nil
+	}
+	i := strings.IndexByte(lit.Value, '=')
+	if i <= 0 || !validName(lit.Value[:i]) {
+		return nil
+	}
+	value := &Word{}
+	if rest := lit.Value[i+1:]; rest != "" {
+		value.Parts = append(value.Parts, &Literal{Value: rest})
+	}
+	value.Parts = append(value.Parts, w.Parts[1:]...)
+	return &Assign{Name: lit.Value[:i], Value: value}
+}
+
+// validName 判断是否为合法的变量名
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokOp
+	tokNewline
+	tokIONumber
+)
+
+type token struct {
+	kind tokenKind
+	pos  int
+	op   string // tokOp 的操作符
+	word *Word  // tokWord 的单词
+	fd   int    // tokIONumber 的文件描述符
+}
+
+// reserved 返回未加引号的单词文本，用于识别保留字
+func (t token) reserved() string {
+	if t.kind != tokWord || len(t.word.Parts) != 1 {
+		return ""
+	}
+	if lit, ok := t.word.Parts[0].(*Literal); ok && !lit.Quoted {
+		return lit.Value
+	}
+	return ""
+}
+
+// operators 按长度从长到短排列，保证最长匹配
+var operators = []string{
+	"<<<", "&>>",
+	"&&", "||", ";;", "|&", ">>", ">&", "<&", "&>", "<<", "<>", ">|",
+	"|", "&", ";", "(", ")", "<", ">",
+}
+
+// unsupportedOperators 是不支持的操作符
+var unsupportedOperators = map[string]string{
+	"<<<": "here-string (<<<)",
+	"&>>": "append redirection of both outputs (&>>)",
+	";;":  "case statement (;;)",
+	"|&":  "pipe of both outputs (|&)",
+	"<<":  "here-document (<<)",
+	"<>":  "read-write redirection (<>)",
+	"&":   "background execution (&)",
+}
+
+// lexer 将脚本切分为单词和操作符
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	// 跳过空白、续行和注释
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == ' ' || c == '\t' || c == '\r' {
+			l.pos++
+		} else if c == '\\' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '\n' {
+			l.pos += 2
+		} else if c == '#' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		} else {
+			break
+		}
+	}
+
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	if l.src[l.pos] == '\n' {
+		l.pos++
+		return token{kind: tokNewline, pos: start}, nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			if construct, ok := unsupportedOperators[op]; ok {
+				return token{}, &UnsupportedError{Pos: start, Construct: construct}
+			}
+			l.pos += len(op)
+			return token{kind: tokOp, pos: start, op: op}, nil
+		}
+	}
+
+	// 紧跟在重定向操作符前的数字是文件描述符
+	end := l.pos
+	for end < len(l.src) && l.src[end] >= '0' && l.src[end] <= '9' {
+		end++
+	}
+	if end > l.pos && end < len(l.src) && (l.src[end] == '<' || l.src[end] == '>') {
+		fd, err := strconv.Atoi(l.src[l.pos:end])
+		if err != nil {
+			return token{}, &SyntaxError{Pos: start, Msg: "invalid file descriptor"}
+		}
+		l.pos = end
+		return token{kind: tokIONumber, pos: start, fd: fd}, nil
+	}
+
+	word, err := l.word()
+	if err != nil {
+		return token{}, err
+	}
+	return token{kind: tokWord, pos: start, word: word}, nil
+}
+
+// word 读取一个单词
+func (l *lexer) word() (*Word, error) {
+	w := &Word{}
+	start := l.pos
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			w.Parts = append(w.Parts, &Literal{Value: lit.String()})
+			lit.Reset()
+		}
+	}
+
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case isWordEnd(c):
+			flush()
+			return w, nil
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				lit.WriteByte(c)
+				l.pos++
+				continue
+			}
+			if l.src[l.pos+1] == '\n' {
+				l.pos += 2
+				continue
+			}
+			flush()
+			_, size := utf8.DecodeRuneInString(l.src[l.pos+1:])
+			w.Parts = append(w.Parts, &Literal{Value: l.src[l.pos+1 : l.pos+1+size], Quoted: true})
+			l.pos += 1 + size
+		case c == '\'':
+			end := strings.IndexByte(l.src[l.pos+1:], '\'')
+			if end < 0 {
+				return nil, &SyntaxError{Pos: l.pos, Msg: "unterminated single quote"}
+			}
+			flush()
+			w.Parts = append(w.Parts, &Literal{Value: l.src[l.pos+1 : l.pos+1+end], Quoted: true})
+			l.pos += end + 2
+		case c == '"':
+			flush()
+			if err := l.doubleQuoted(w); err != nil {
+				return nil, err
+			}
+		case c == '$':
+			param, err := l.param(false)
+			if err != nil {
+				return nil, err
+			}
+			if param == nil {
+				lit.WriteByte('$')
+				continue
+			}
+			flush()
+			w.Parts = append(w.Parts, param)
+		case c == '`':
+			return nil, &UnsupportedError{Pos: l.pos, Construct: "command substitution (`...`)"}
+		case c == '~' && l.pos == start && (l.pos+1 >= len(l.src) || l.src[l.pos+1] == '/' || isWordEnd(l.src[l.pos+1])):
+			w.Parts = append(w.Parts, &Param{Name: "HOME", Quoted: true})
+			l.pos++
+		default:
+			lit.WriteByte(c)
+			l.pos++
+		}
+	}
+	flush()
+	return w, nil
+}
+
+// doubleQuoted 读取双引号中的内容
+func (l *lexer) doubleQuoted(w *Word) error {
+	start := l.pos
+	parts := len(w.Parts)
+	l.pos++
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			w.Parts = append(w.Parts, &Literal{Value: b.String(), Quoted: true})
+			b.Reset()
+		}
+	}
+
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			flush()
+			// 空的双引号也是一个参数
+			if len(w.Parts) == parts {
+				w.Parts = append(w.Parts, &Literal{Quoted: true})
+			}
+			return nil
+		case '\\':
+			if l.pos+1 < len(l.src) && strings.IndexByte("$`\"\\\n", l.src[l.pos+1]) >= 0 {
+				if l.src[l.pos+1] != '\n' {
+					b.WriteByte(l.src[l.pos+1])
+				}
+				l.pos += 2
+				continue
+			}
+			b.WriteByte(c)
+			l.pos++
+		case '$':
+			param, err := l.param(true)
+			if err != nil {
+				return err
+			}
+			if param == nil {
+				b.WriteByte('$')
+				continue
+			}
+			flush()
+			w.Parts = append(w.Parts, param)
+		case '`':
+			return &UnsupportedError{Pos: l.pos, Construct: "command substitution (`...`)"}
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return &SyntaxError{Pos: start, Msg: "unterminated double quote"}
+}
+
+// param 读取 $ 开头的变量引用，$ 后不是变量时作为字面量返回 nil
+func (l *lexer) param(quoted bool) (*Param, error) {
+	start := l.pos
+	l.pos++
+	if l.pos >= len(l.src) {
+		return nil, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case c == '(':
+		if strings.HasPrefix(l.src[l.pos:], "((") {
+			return nil, &UnsupportedError{Pos: start, Construct: "arithmetic expansion ($((...)))"}
+		}
+		return nil, &UnsupportedError{Pos: start, Construct: "command substitution ($(...))"}
+	case c == '{':
+		end := strings.IndexByte(l.src[l.pos:], '}')
+		if end < 0 {
+			return nil, &SyntaxError{Pos: start, Msg: "unterminated ${"}
+		}
+		name := l.src[l.pos+1 : l.pos+end]
+		if name != "?" && !validName(name) {
+			return nil, &UnsupportedError{Pos: start, Construct: "parameter expansion ${" + name + "}"}
+		}
+		l.pos += end + 1
+		return &Param{Name: name, Quoted: quoted}, nil
+	case c == '?':
+		l.pos++
+		return &Param{Name: "?", Quoted: quoted}, nil
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		end := l.pos
+		for end < len(l.src) && validName(l.src[l.pos:end+1]) {
+			end++
+		}
+		name := l.src[l.pos:end]
+		l.pos = end
+		return &Param{Name: name, Quoted: quoted}, nil
+	case strings.IndexByte("@*#$!-0123456789", c) >= 0:
+		return nil, &UnsupportedError{Pos: start, Construct: "special parameter $" + string(c)}
+	}
+	return nil, nil
+}
+
+// isWordEnd 判断字符是否结束单词
+func isWordEnd(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || strings.IndexByte("|&;()<>", c) >= 0
+}
@@ -0,0 +1,123 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"echo hello | grep hello", "echo hello | grep hello"},
+		{"  echo   hello   |   grep   hello  ", "echo hello | grep hello"},
+		{`echo "a | b" 'c|d' e\|f`, `echo 'a | b' 'c|d' e'|'f`},
+		{`echo "" ''`, `echo '' ''`},
+		{"make && make test || echo failed; echo done", "make && make test || echo failed; echo done"},
+		{"a\nb\n\nc", "a; b; c"},
+		{"cat < in.txt > out.txt 2>&1", "cat <in.txt >out.txt 2>&1"},
+		{"cmd >> log 2> err &> all", "cmd >>log 2>err &>all"},
+		{"FOO=1 BAR=\"x y\" env", "FOO=1 BAR='x y' env"},
+		{"FOO=1", "FOO=1"},
+		{"echo $HOME ${USER}x \"$A-$?\"", `echo ${HOME} ${USER}x "${A}"-"${?}"`},
+		{"(cd /tmp && ls) > out", "(cd /tmp && ls) >out"},
+		{"! grep -q x file", "! grep -q x file"},
+		{"echo a # comment\necho b", "echo a; echo b"},
+		{"ls ~ ~/src a~", `ls "${HOME}" "${HOME}"/src a~`},
+		{"echo cost: $", "echo cost: $"},
+		{"echo a \\\n b", "echo a b"},
+		{"ls |\n grep x &&\n echo ok", "ls | grep x && echo ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			script, err := Parse(tt.src)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, script.String())
+		})
+	}
+}
+
+func TestParseAST(t *testing.T) {
+	script, err := Parse(`FOO=bar cmd "a b" '' 2>&1 | (cd x; ls *.go)`)
+	require.NoError(t, err)
+	pipeline := script.Pipeline()
+	require.NotNil(t, pipeline)
+	require.Len(t, pipeline.Commands, 2)
+
+	simple := pipeline.Commands[0].(*SimpleCommand)
+	require.Len(t, simple.Assigns, 1)
+	assert.Equal(t, "FOO", simple.Assigns[0].Name)
+	assert.Equal(t, "bar", simple.Assigns[0].Value.Value())
+	require.Len(t, simple.Words, 3)
+	assert.Equal(t, "", simple.Words[2].Value())
+	assert.Equal(t, []*Redirect{{Fd: 2, Op: RedirectDupOut, Target: &Word{Parts: []WordPart{&Literal{Value: "1"}}}}}, simple.Redirects)
+
+	subshell := pipeline.Commands[1].(*Subshell)
+	assert.Len(t, subshell.Body.Stmts, 2)
+
+	assert.Equal(t, []types.Command{
+		{Command: "cmd", Args: []string{"a b", ""}},
+		{Command: "cd", Args: []string{"x"}},
+		{Command: "ls", Args: []string{"*.go"}},
+	}, script.Commands())
+
+	script, err = Parse("a; b")
+	require.NoError(t, err)
+	assert.Nil(t, script.Pipeline())
+
+	script, err = Parse("  # only a comment\n")
+	require.NoError(t, err)
+	assert.Empty(t, script.Stmts)
+}
+
+func TestParseErrors(t *testing.T) {
+	syntaxErrors := []string{
+		"|",
+		"| echo hello",
+		"echo hello |",
+		"echo hello | | grep hello",
+		"a &&",
+		"; a",
+		"echo 'unterminated",
+		`echo "unterminated`,
+		"echo ${HOME",
+		"cat <",
+		"(echo a",
+		"()",
+		"echo a)",
+	}
+	for _, src := range syntaxErrors {
+		_, err := Parse(src)
+		var syntaxErr *SyntaxError
+		assert.ErrorAs(t, err, &syntaxErr, src)
+	}
+
+	unsupported := map[string]string{
+		"sleep 1 &":            "background execution (&)",
+		"echo $(date)":         "command substitution ($(...))",
+		"echo \"`date`\"":      "command substitution (`...`)",
+		"echo $((1+2))":        "arithmetic expansion ($((...)))",
+		"cat <<EOF":            "here-document (<<)",
+		"if true; then a; fi":  "if statement",
+		"for i in 1 2; do a":   "for loop",
+		"{ a; }":               "command group ({ ...; })",
+		"f() { a; }":           "function definition",
+		"echo ${HOME:-/root}":  "parameter expansion ${HOME:-/root}",
+		"echo $1":              "special parameter $1",
+		"cmd 3> file":          "redirection of file descriptor 3",
+		"cmd 2>&-":             ">&-",
+		"a |& b":               "pipe of both outputs (|&)",
+		"[[ -f x ]] && echo y": "conditional expression ([[ ... ]])",
+	}
+	for src, construct := range unsupported {
+		_, err := Parse(src)
+		var unsupportedErr *UnsupportedError
+		if assert.ErrorAs(t, err, &unsupportedErr, src) {
+			assert.Equal(t, construct, unsupportedErr.Construct, src)
+		}
+	}
+}
@@ -0,0 +1,571 @@
+// Package snapshot 实现了会话工作目录的快照与回滚。
+//
+// 快照通过执行器的 types.WorkDirArchiver 能力获取工作目录的 tar 流，
+// 文件内容按 SHA-256 以内容寻址的方式存储，相同内容在多个快照之间只保存一份。
+// 每个快照只记录一份清单（manifest），恢复时根据清单重新构造 tar 流交给执行器解包。
+//
+// 存储目录结构：
+//
+//	<root>/objects/<hash[:2]>/<hash>        文件内容
+//	<root>/sessions/<session>/<id>.json     快照清单
+package snapshot
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// 清单条目类型
+const (
+	EntryFile    = "file"
+	EntryDir     = "dir"
+	EntrySymlink = "symlink"
+)
+
+// DefaultRetention 每个会话默认保留的快照数量
+const DefaultRetention = 10
+
+// ErrNotFound 表示快照不存在
+var ErrNotFound = errors.New("snapshot not found")
+
+// Entry 表示快照中的一个文件或目录
+type Entry struct {
+	Path     string      `json:"path"`               // 相对于工作目录的路径
+	Type     string      `json:"type"`               // 条目类型（file/dir/symlink）
+	Mode     os.FileMode `json:"mode"`               // 权限位
+	Size     int64       `json:"size"`               // 文件大小
+	Hash     string      `json:"hash,omitempty"`     // 文件内容的 SHA-256
+	Linkname string      `json:"linkname,omitempty"` // 符号链接目标
+	ModTime  time.Time   `json:"mtime"`              // 修改时间
+}
+
+// Snapshot 表示一个工作目录快照
+// swagger:model
+type Snapshot struct {
+	ID        string    `json:"id"`               // 快照 ID
+	SessionID string    `json:"session_id"`       // 所属会话 ID
+	Reason    string    `json:"reason,omitempty"` // 创建原因
+	CreatedAt time.Time `json:"created_at"`       // 创建时间
+	FileCount int       `json:"file_count"`       // 文件数量
+	Size      int64     `json:"size"`             // 文件总大小（字节）
+	Entries   []Entry   `json:"entries,omitempty"`
+}
+
+// summary 返回不含清单条目的快照副本
+func (s *Snapshot) summary() *Snapshot {
+	c := *s
+	c.Entries = nil
+	return &c
+}
+
+// Manager 管理快照的创建、恢复和保留
+type Manager struct {
+	root      string
+	retention int
+	mu        sync.Mutex
+	// pinned 记录正在恢复的快照引用的对象，gc 不会删除这些对象
+	pinned map[string]int
+}
+
+// NewManager 创建快照管理器。
+// retention 为每个会话最多保留的快照数量，小于等于 0 时使用 DefaultRetention。
+func NewManager(root string, retention int) (*Manager, error) {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	// 快照包含工作目录中的文件内容，只允许当前用户访问
+	for _, dir := range []string{root, filepath.Join(root, "objects"), filepath.Join(root, "sessions")} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+		}
+	}
+	return &Manager{
+		root:      root,
+		retention: retention,
+		pinned:    make(map[string]int),
+	}, nil
+}
+
+// Create 为会话工作目录创建快照
+func (m *Manager) Create(ctx context.Context, sessionID string, archiver types.WorkDirArchiver, workDir, reason string) (*Snapshot, error) {
+	if err := validateID(sessionID); err != nil {
+		return nil, err
+	}
+
+	rc, err := archiver.ArchiveWorkDir(ctx, workDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive work directory: %w", err)
+	}
+	defer rc.Close()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries, err := m.scan(rc, true)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+		Entries:   entries,
+	}
+	for _, e := range entries {
+		if e.Type == EntryFile {
+			snap.FileCount++
+			snap.Size += e.Size
+		}
+	}
+
+	if err := m.save(snap); err != nil {
+		return nil, err
+	}
+	log.Info("Created snapshot %s for session %s (%d files)", snap.ID, sessionID, snap.FileCount)
+
+	if err := m.prune(sessionID); err != nil {
+		log.Error("Failed to prune snapshots for session %s: %v", sessionID, err)
+	}
+
+	return snap.summary(), nil
+}
+
+// List 列出会话的所有快照，按创建时间升序排列
+func (m *Manager) List(sessionID string) ([]*Snapshot, error) {
+	if err := validateID(sessionID); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snaps, err := m.load(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Snapshot, 0, len(snaps))
+	for _, s := range snaps {
+		result = append(result, s.summary())
+	}
+	return result, nil
+}
+
+// Get 获取快照详情（包含清单条目）
+func (m *Manager) Get(sessionID, id string) (*Snapshot, error) {
+	if err := validateID(sessionID); err != nil {
+		return nil, err
+	}
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.read(sessionID, id)
+}
+
+// Restore 将会话工作目录回滚到指定快照
+func (m *Manager) Restore(ctx context.Context, sessionID, id string, archiver types.WorkDirArchiver, workDir string) error {
+	if err := validateID(sessionID); err != nil {
+		return err
+	}
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	// 读取清单的同时固定其引用的对象，避免恢复期间被并发的删除或清理移除
+	m.mu.Lock()
+	snap, err := m.read(sessionID, id)
+	if err == nil {
+		m.pin(snap, 1)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(m.writeTar(snap, pw))
+	}()
+	defer func() {
+		pr.Close()
+		<-done
+		m.mu.Lock()
+		m.pin(snap, -1)
+		m.mu.Unlock()
+	}()
+
+	if err := archiver.RestoreWorkDir(ctx, workDir, pr); err != nil {
+		return fmt.Errorf("failed to restore work directory: %w", err)
+	}
+	log.Info("Restored session %s to snapshot %s", sessionID, id)
+	return nil
+}
+
+// Diff 比较两个快照之间的差异
+func (m *Manager) Diff(sessionID, fromID, toID string) ([]types.FileChange, error) {
+	from, err := m.Get(sessionID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := m.Get(sessionID, toID)
+	if err != nil {
+		return nil, err
+	}
+	return diffEntries(from.Entries, to.Entries), nil
+}
+
+// DiffCurrent 比较快照与当前工作目录之间的差异
+func (m *Manager) DiffCurrent(ctx context.Context, sessionID, fromID string, archiver types.WorkDirArchiver, workDir string) ([]types.FileChange, error) {
+	from, err := m.Get(sessionID, fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := archiver.ArchiveWorkDir(ctx, workDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive work directory: %w", err)
+	}
+	defer rc.Close()
+
+	current, err := m.scan(rc, false)
+	if err != nil {
+		return nil, err
+	}
+	return diffEntries(from.Entries, current), nil
+}
+
+// Delete 删除指定快照
+func (m *Manager) Delete(sessionID, id string) error {
+	if err := validateID(sessionID); err != nil {
+		return err
+	}
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.Remove(m.manifestPath(sessionID, id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return err
+	}
+	return m.gc()
+}
+
+// DeleteSession 删除会话的所有快照
+func (m *Manager) DeleteSession(sessionID string) error {
+	if err := validateID(sessionID); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.RemoveAll(filepath.Join(m.root, "sessions", sessionID)); err != nil {
+		return err
+	}
+	return m.gc()
+}
+
+// scan 读取 tar 流并生成清单，store 为 true 时同时保存文件内容
+func (m *Manager) scan(r io.Reader, store bool) ([]Entry, error) {
+	var entries []Entry
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		entry := Entry{
+			Path:    strings.TrimSuffix(hdr.Name, "/"),
+			Mode:    os.FileMode(hdr.Mode).Perm(),
+			ModTime: hdr.ModTime,
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			entry.Type = EntryDir
+		case tar.TypeSymlink:
+			entry.Type = EntrySymlink
+			entry.Linkname = hdr.Linkname
+		case tar.TypeReg:
+			entry.Type = EntryFile
+			entry.Size = hdr.Size
+			if entry.Hash, err = m.storeObject(tr, store); err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// storeObject 计算内容哈希，store 为 true 时将内容写入对象存储
+func (m *Manager) storeObject(r io.Reader, store bool) (string, error) {
+	h := sha256.New()
+	if !store {
+		if _, err := io.Copy(h, r); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(m.root, "objects"), "tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(io.MultiWriter(tmp, h), r)
+	tmp.Close()
+	if err != nil {
+		return "", err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	target := m.objectPath(hash)
+	if _, err := os.Stat(target); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// writeTar 根据快照清单构造 tar 流
+func (m *Manager) writeTar(snap *Snapshot, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, e := range snap.Entries {
+		hdr := &tar.Header{
+			Name:    e.Path,
+			Mode:    int64(e.Mode),
+			ModTime: e.ModTime,
+		}
+		switch e.Type {
+		case EntryDir:
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+		case EntrySymlink:
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = e.Linkname
+		case EntryFile:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = e.Size
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if e.Type == EntryFile {
+			f, err := os.Open(m.objectPath(e.Hash))
+			if err != nil {
+				return fmt.Errorf("snapshot object missing for %s: %w", e.Path, err)
+			}
+			_, err = io.Copy(tw, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+// save 保存快照清单
+func (m *Manager) save(snap *Snapshot) error {
+	dir := filepath.Join(m.root, "sessions", snap.SessionID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.manifestPath(snap.SessionID, snap.ID), data, 0600)
+}
+
+// read 读取快照清单
+func (m *Manager) read(sessionID, id string) (*Snapshot, error) {
+	data, err := os.ReadFile(m.manifestPath(sessionID, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("invalid snapshot manifest %s: %w", id, err)
+	}
+	return &snap, nil
+}
+
+// load 读取会话的所有快照清单，按创建时间升序排列
+func (m *Manager) load(sessionID string) ([]*Snapshot, error) {
+	files, err := os.ReadDir(filepath.Join(m.root, "sessions", sessionID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var snaps []*Snapshot
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		snap, err := m.read(sessionID, strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil {
+			log.Error("Failed to read snapshot manifest %s: %v", f.Name(), err)
+			continue
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreatedAt.Before(snaps[j].CreatedAt) })
+	return snaps, nil
+}
+
+// prune 删除超出保留数量的旧快照
+func (m *Manager) prune(sessionID string) error {
+	snaps, err := m.load(sessionID)
+	if err != nil {
+		return err
+	}
+	if len(snaps) <= m.retention {
+		return nil
+	}
+	for _, s := range snaps[:len(snaps)-m.retention] {
+		log.Debug("Pruning snapshot %s of session %s", s.ID, sessionID)
+		if err := os.Remove(m.manifestPath(sessionID, s.ID)); err != nil {
+			return err
+		}
+	}
+	return m.gc()
+}
+
+// gc 删除不再被任何快照引用的对象
+func (m *Manager) gc() error {
+	referenced := make(map[string]bool)
+	sessions, err := os.ReadDir(filepath.Join(m.root, "sessions"))
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		snaps, err := m.load(s.Name())
+		if err != nil {
+			return err
+		}
+		for _, snap := range snaps {
+			for _, e := range snap.Entries {
+				if e.Hash != "" {
+					referenced[e.Hash] = true
+				}
+			}
+		}
+	}
+
+	for hash := range m.pinned {
+		referenced[hash] = true
+	}
+
+	return filepath.Walk(filepath.Join(m.root, "objects"), func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if !referenced[info.Name()] && !strings.HasPrefix(info.Name(), "tmp-") {
+			return os.Remove(p)
+		}
+		return nil
+	})
+}
+
+// pin 调整快照引用对象的固定计数，调用方需持有 m.mu
+func (m *Manager) pin(snap *Snapshot, delta int) {
+	for _, e := range snap.Entries {
+		if e.Hash == "" {
+			continue
+		}
+		m.pinned[e.Hash] += delta
+		if m.pinned[e.Hash] <= 0 {
+			delete(m.pinned, e.Hash)
+		}
+	}
+}
+
+func (m *Manager) objectPath(hash string) string {
+	return filepath.Join(m.root, "objects", hash[:2], hash)
+}
+
+func (m *Manager) manifestPath(sessionID, id string) string {
+	return filepath.Join(m.root, "sessions", sessionID, id+".json")
+}
+
+// diffEntries 比较两份清单，返回文件级别的变更
+func diffEntries(from, to []Entry) []types.FileChange {
+	old := make(map[string]Entry, len(from))
+	for _, e := range from {
+		old[e.Path] = e
+	}
+
+	var changes []types.FileChange
+	for _, e := range to {
+		prev, ok := old[e.Path]
+		delete(old, e.Path)
+		switch {
+		case !ok:
+			changes = append(changes, types.FileChange{Path: e.Path, Type: types.FileCreated})
+		case prev.Type != e.Type || prev.Hash != e.Hash || prev.Mode != e.Mode || prev.Linkname != e.Linkname:
+			changes = append(changes, types.FileChange{Path: e.Path, Type: types.FileModified})
+		}
+	}
+	for p := range old {
+		changes = append(changes, types.FileChange{Path: p, Type: types.FileDeleted})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// validateID 校验 ID，防止路径穿越。非法 ID 不可能对应已有的快照，按不存在处理
+func validateID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("%w: invalid id %q", ErrNotFound, id)
+	}
+	return nil
+}
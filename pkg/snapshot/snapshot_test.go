@@ -0,0 +1,196 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iamlongalong/runshell/pkg/archive"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dirArchiver 是基于本地目录的 WorkDirArchiver 测试实现
+type dirArchiver struct {
+	dir string
+}
+
+func (a *dirArchiver) ArchiveWorkDir(ctx context.Context, workDir string) (io.ReadCloser, error) {
+	return archive.TarDir(ctx, a.dir), nil
+}
+
+func (a *dirArchiver) RestoreWorkDir(ctx context.Context, workDir string, r io.Reader) error {
+	return archive.RestoreDir(a.dir, r)
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+	require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+}
+
+func TestSnapshotCreateRestore(t *testing.T) {
+	workDir := t.TempDir()
+	arch := &dirArchiver{dir: workDir}
+	m, err := NewManager(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	writeFile(t, workDir, "a.txt", "hello")
+	writeFile(t, workDir, "src/b.txt", "world")
+
+	snap, err := m.Create(context.Background(), "sess", arch, "", "manual")
+	require.NoError(t, err)
+	assert.Equal(t, 2, snap.FileCount)
+	assert.Equal(t, int64(10), snap.Size)
+	assert.Empty(t, snap.Entries)
+
+	// 修改工作目录
+	writeFile(t, workDir, "a.txt", "changed")
+	writeFile(t, workDir, "new.txt", "new")
+	require.NoError(t, os.RemoveAll(filepath.Join(workDir, "src")))
+
+	changes, err := m.DiffCurrent(context.Background(), "sess", snap.ID, arch, "")
+	require.NoError(t, err)
+	assert.Equal(t, []types.FileChange{
+		{Path: "a.txt", Type: types.FileModified},
+		{Path: "new.txt", Type: types.FileCreated},
+		{Path: "src", Type: types.FileDeleted},
+		{Path: "src/b.txt", Type: types.FileDeleted},
+	}, changes)
+
+	// 回滚
+	require.NoError(t, m.Restore(context.Background(), "sess", snap.ID, arch, ""))
+
+	data, err := os.ReadFile(filepath.Join(workDir, "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	data, err = os.ReadFile(filepath.Join(workDir, "src/b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(data))
+	_, err = os.Stat(filepath.Join(workDir, "new.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSnapshotDiffAndRetention(t *testing.T) {
+	workDir := t.TempDir()
+	arch := &dirArchiver{dir: workDir}
+	root := t.TempDir()
+	m, err := NewManager(root, 2)
+	require.NoError(t, err)
+
+	writeFile(t, workDir, "a.txt", "v1")
+	s1, err := m.Create(context.Background(), "sess", arch, "", "")
+	require.NoError(t, err)
+
+	writeFile(t, workDir, "a.txt", "v2")
+	s2, err := m.Create(context.Background(), "sess", arch, "", "")
+	require.NoError(t, err)
+
+	changes, err := m.Diff("sess", s1.ID, s2.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []types.FileChange{{Path: "a.txt", Type: types.FileModified}}, changes)
+
+	writeFile(t, workDir, "a.txt", "v3")
+	_, err = m.Create(context.Background(), "sess", arch, "", "")
+	require.NoError(t, err)
+
+	snaps, err := m.List("sess")
+	require.NoError(t, err)
+	require.Len(t, snaps, 2)
+	assert.Equal(t, s2.ID, snaps[0].ID)
+
+	_, err = m.Get("sess", s1.ID)
+	assert.Error(t, err)
+
+	// 删除会话后对象应被回收
+	require.NoError(t, m.DeleteSession("sess"))
+	var objects int
+	filepath.Walk(filepath.Join(root, "objects"), func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			objects++
+		}
+		return nil
+	})
+	assert.Equal(t, 0, objects)
+}
+
+// pausingArchiver 在恢复读取第一个条目后暂停，等待测试放行后读完剩余内容
+type pausingArchiver struct {
+	dirArchiver
+	paused chan struct{}
+	resume chan struct{}
+}
+
+func (a *pausingArchiver) RestoreWorkDir(ctx context.Context, workDir string, r io.Reader) error {
+	tr := tar.NewReader(r)
+	if _, err := tr.Next(); err != nil {
+		return err
+	}
+	close(a.paused)
+	<-a.resume
+	for {
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return err
+		}
+		if _, err := tr.Next(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func TestSnapshotRestoreConcurrentDelete(t *testing.T) {
+	workDir := t.TempDir()
+	m, err := NewManager(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	writeFile(t, workDir, "a.txt", "hello")
+	writeFile(t, workDir, "b.txt", "world")
+	snap, err := m.Create(context.Background(), "sess", &dirArchiver{dir: workDir}, "", "manual")
+	require.NoError(t, err)
+
+	arch := &pausingArchiver{
+		dirArchiver: dirArchiver{dir: workDir},
+		paused:      make(chan struct{}),
+		resume:      make(chan struct{}),
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- m.Restore(context.Background(), "sess", snap.ID, arch, "")
+	}()
+
+	// 恢复进行中删除快照，对象必须保留到恢复结束
+	<-arch.paused
+	require.NoError(t, m.Delete("sess", snap.ID))
+	close(arch.resume)
+
+	require.NoError(t, <-errCh)
+
+	// 恢复结束后对象被清理
+	require.NoError(t, m.gc())
+	objects := 0
+	require.NoError(t, filepath.Walk(filepath.Join(m.root, "objects"), func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			objects++
+		}
+		return err
+	}))
+	assert.Equal(t, 0, objects)
+}
+
+func TestSnapshotInvalidID(t *testing.T) {
+	m, err := NewManager(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	_, err = m.List("../etc")
+	assert.Error(t, err)
+	_, err = m.Get("sess", "../../x")
+	assert.Error(t, err)
+}
@@ -0,0 +1,305 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// Call 表示一次工具调用，兼容 OpenAI 和 Anthropic 的调用格式：
+//
+//	OpenAI:    {"id": "call_1", "type": "function", "function": {"name": "ls", "arguments": "{\"path\": \".\"}"}}
+//	Anthropic: {"type": "tool_use", "id": "toolu_1", "name": "ls", "input": {"path": "."}}
+//	通用格式:   {"id": "1", "name": "ls", "arguments": {"path": "."}}
+//
+// swagger:model
+type Call struct {
+	ID        string          `json:"id,omitempty" example:"call_1"`            // 调用 ID，原样返回
+	Type      string          `json:"type,omitempty" example:"function"`        // 调用类型，"function" 或 "tool_use"
+	Name      string          `json:"name,omitempty" example:"ls"`              // 工具名称
+	Arguments json.RawMessage `json:"arguments,omitempty" swaggertype:"object"` // 工具参数，JSON 对象或其字符串形式
+	Input     json.RawMessage `json:"input,omitempty" swaggertype:"object"`     // Anthropic 格式的工具参数
+	Function  *struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments,omitempty" swaggertype:"object"`
+	} `json:"function,omitempty"` // OpenAI 格式的函数调用
+
+	SessionID string `json:"session_id,omitempty"` // 在指定会话中执行
+	WorkDir   string `json:"workdir,omitempty"`    // 工作目录
+}
+
+// Format 根据调用格式推断工具结果的输出格式
+func (c *Call) Format() string {
+	switch {
+	case c.Type == "tool_use" || c.Input != nil:
+		return FormatAnthropic
+	case c.Function != nil || c.Type == "function":
+		return FormatOpenAI
+	default:
+		return FormatJSONSchema
+	}
+}
+
+// ToolName 返回调用的工具名称
+func (c *Call) ToolName() string {
+	if c.Function != nil && c.Function.Name != "" {
+		return c.Function.Name
+	}
+	return c.Name
+}
+
+// Args 解析调用参数，参数可以是 JSON 对象或 JSON 对象的字符串形式
+func (c *Call) Args() (map[string]interface{}, error) {
+	raw := c.Arguments
+	if c.Function != nil && len(c.Function.Arguments) > 0 {
+		raw = c.Function.Arguments
+	}
+	if len(raw) == 0 {
+		raw = c.Input
+	}
+
+	args := make(map[string]interface{})
+	if len(raw) == 0 || string(raw) == "null" {
+		return args, nil
+	}
+
+	// OpenAI 以字符串形式传递参数
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		if strings.TrimSpace(encoded) == "" {
+			return args, nil
+		}
+		raw = json.RawMessage(encoded)
+	}
+
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	if err := dec.Decode(&args); err != nil {
+		return nil, fmt.Errorf("invalid tool arguments: %w", err)
+	}
+	return args, nil
+}
+
+// Command 将工具参数映射为命令。
+// 选项按声明顺序在前，随后是 extra_args 和位置参数；
+// 不允许透传的命令在位置参数以 "-" 开头时插入 "--"。
+func (t *Tool) Command(args map[string]interface{}) (types.Command, error) {
+	cmd := types.Command{Command: t.Info.Name}
+	schema := t.Info.Schema
+
+	if schema == nil {
+		for name := range args {
+			if name != RawArgsProperty {
+				return cmd, fmt.Errorf("%s: unknown argument %q", t.Name, name)
+			}
+		}
+		values, err := stringList(args[RawArgsProperty], types.ArgTypeString)
+		if err != nil {
+			return cmd, fmt.Errorf("%s: argument %s: %w", t.Name, RawArgsProperty, err)
+		}
+		cmd.Args = values
+		return cmd, nil
+	}
+
+	known := make(map[string]bool, len(schema.Args))
+	for _, spec := range schema.Args {
+		known[propertyName(spec)] = true
+	}
+	if schema.Passthrough {
+		known[ExtraArgsProperty] = true
+	}
+	var unknown []string
+	for name := range args {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return cmd, fmt.Errorf("%s: unknown argument %q", t.Name, unknown[0])
+	}
+
+	var options, positional []string
+	for _, spec := range schema.Args {
+		name := propertyName(spec)
+		value, ok := args[name]
+		if !ok || value == nil {
+			if spec.Required {
+				return cmd, fmt.Errorf("%s: argument %s is required", t.Name, name)
+			}
+			continue
+		}
+
+		if spec.Flag != "" && spec.Type == types.ArgTypeBool {
+			enabled, err := boolValue(value)
+			if err != nil {
+				return cmd, fmt.Errorf("%s: argument %s: %w", t.Name, name, err)
+			}
+			if enabled {
+				options = append(options, spec.Flag)
+			}
+			continue
+		}
+
+		var values []string
+		var err error
+		if spec.Variadic {
+			values, err = stringList(value, spec.Type)
+		} else {
+			var s string
+			s, err = stringValue(value, spec.Type)
+			values = []string{s}
+		}
+		if err != nil {
+			return cmd, fmt.Errorf("%s: argument %s: %w", t.Name, name, err)
+		}
+
+		if spec.Flag == "" {
+			positional = append(positional, values...)
+			continue
+		}
+		for _, v := range values {
+			options = append(options, spec.Flag, v)
+		}
+	}
+
+	if schema.Passthrough {
+		extra, err := stringList(args[ExtraArgsProperty], types.ArgTypeString)
+		if err != nil {
+			return cmd, fmt.Errorf("%s: argument %s: %w", t.Name, ExtraArgsProperty, err)
+		}
+		options = append(options, extra...)
+	}
+
+	cmd.Args = options
+	if !schema.Passthrough {
+		for _, p := range positional {
+			if strings.HasPrefix(p, "-") && p != "-" {
+				cmd.Args = append(cmd.Args, "--")
+				break
+			}
+		}
+	}
+	cmd.Args = append(cmd.Args, positional...)
+	return cmd, nil
+}
+
+// stringList 将数组或单个值转换为字符串列表
+func stringList(value interface{}, t types.ArgType) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		s, err := stringValue(item, t)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+// stringValue 将 JSON 值转换为命令行参数
+func stringValue(value interface{}, t types.ArgType) (string, error) {
+	switch v := value.(type) {
+	case string:
+		if t == types.ArgTypeInt {
+			if _, err := strconv.Atoi(v); err != nil {
+				return "", fmt.Errorf("expected an integer, got %q", v)
+			}
+		}
+		return v, nil
+	case json.Number:
+		if t == types.ArgTypeInt {
+			if _, err := v.Int64(); err != nil {
+				return "", fmt.Errorf("expected an integer, got %s", v)
+			}
+		}
+		return v.String(), nil
+	case float64:
+		if t == types.ArgTypeInt && v != float64(int64(v)) {
+			return "", fmt.Errorf("expected an integer, got %v", v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("expected a scalar value, got %T", value)
+	}
+}
+
+// boolValue 将 JSON 值转换为布尔值
+func boolValue(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("expected a boolean, got %q", v)
+		}
+		return b, nil
+	default:
+		return false, fmt.Errorf("expected a boolean, got %T", value)
+	}
+}
+
+// Output 表示工具执行的结果
+// swagger:model
+type Output struct {
+	ID       string      `json:"id,omitempty"`    // 调用 ID
+	Name     string      `json:"name"`            // 工具名称
+	ExitCode int         `json:"exit_code"`       // 命令退出码
+	Output   string      `json:"output"`          // 命令输出
+	Error    string      `json:"error,omitempty"` // 错误信息
+	Data     interface{} `json:"data,omitempty"`  // 内置命令返回的结构化结果
+	IsError  bool        `json:"is_error"`        // 执行是否失败
+}
+
+// Content 返回发送给模型的文本内容
+func (o *Output) Content() string {
+	content := o.Output
+	if o.Error != "" {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += "error: " + o.Error
+	}
+	if o.ExitCode != 0 {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += fmt.Sprintf("exit code: %d", o.ExitCode)
+	}
+	return content
+}
+
+// Envelope 按指定格式将执行结果包装为工具结果消息
+func (o *Output) Envelope(format string) interface{} {
+	switch format {
+	case FormatAnthropic:
+		return map[string]interface{}{
+			"type":        "tool_result",
+			"tool_use_id": o.ID,
+			"content":     o.Content(),
+			"is_error":    o.IsError,
+		}
+	case FormatOpenAI:
+		return map[string]interface{}{
+			"role":         "tool",
+			"tool_call_id": o.ID,
+			"content":      o.Content(),
+		}
+	default:
+		return o
+	}
+}
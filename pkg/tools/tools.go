@@ -0,0 +1,259 @@
+// Package tools 将 RunShell 的命令导出为 LLM 函数调用（function calling）的工具定义，
+// 并将工具调用映射回 types.Command。
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// 工具定义的输出格式
+const (
+	FormatOpenAI     = "openai"
+	FormatAnthropic  = "anthropic"
+	FormatJSONSchema = "jsonschema"
+)
+
+// ScriptPrefix 脚本工具名称的前缀，避免与内置命令重名
+const ScriptPrefix = "script_"
+
+// ExtraArgsProperty 允许透传未声明选项的命令额外接受的参数名称
+const ExtraArgsProperty = "extra_args"
+
+// RawArgsProperty 没有声明参数结构的命令使用的参数名称
+const RawArgsProperty = "args"
+
+// invalidNameChars 匹配工具名称中不允许的字符
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// Tool 表示一个可供 LLM 调用的工具
+type Tool struct {
+	Name        string                 // 工具名称
+	Description string                 // 工具描述
+	Parameters  map[string]interface{} // 参数的 JSON Schema
+	Info        types.CommandInfo      // 对应的命令
+	Script      bool                   // 是否为脚本
+}
+
+// Registry 保存工具名称到命令的映射
+type Registry struct {
+	tools []*Tool
+	index map[string]*Tool
+}
+
+// NewRegistry 根据内置命令和脚本创建工具注册表，按名称排序
+func NewRegistry(commands, scripts []types.CommandInfo) *Registry {
+	r := &Registry{index: make(map[string]*Tool)}
+	for _, info := range commands {
+		r.add(info, false)
+	}
+	for _, info := range scripts {
+		r.add(info, true)
+	}
+	sort.Slice(r.tools, func(i, j int) bool {
+		return r.tools[i].Name < r.tools[j].Name
+	})
+	return r
+}
+
+// add 添加一个命令，同名工具只保留第一个
+func (r *Registry) add(info types.CommandInfo, script bool) {
+	name := ToolName(info.Name, script)
+	if _, exists := r.index[name]; exists {
+		return
+	}
+	tool := &Tool{
+		Name:        name,
+		Description: describe(info),
+		Parameters:  Parameters(info),
+		Info:        info,
+		Script:      script,
+	}
+	r.tools = append(r.tools, tool)
+	r.index[name] = tool
+}
+
+// Tools 返回所有工具
+func (r *Registry) Tools() []*Tool {
+	return r.tools
+}
+
+// Lookup 按名称查找工具
+func (r *Registry) Lookup(name string) (*Tool, bool) {
+	tool, ok := r.index[name]
+	return tool, ok
+}
+
+// ToolName 将命令名称转换为合法的工具名称（仅包含字母、数字、下划线和连字符，最长 64 个字符）
+func ToolName(name string, script bool) string {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if script {
+		name = ScriptPrefix + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// describe 生成工具描述
+func describe(info types.CommandInfo) string {
+	desc := info.Description
+	if desc == "" {
+		desc = "Run " + info.Name
+	}
+	if info.Usage != "" {
+		desc += "\nUsage: " + info.Usage
+	}
+	return desc
+}
+
+// propertyName 返回参数在 JSON Schema 中的属性名称
+func propertyName(spec types.ArgSpec) string {
+	if spec.Name != "" {
+		return spec.Name
+	}
+	return strings.TrimLeft(spec.Flag, "-")
+}
+
+// Parameters 将命令的参数结构转换为 JSON Schema。
+// 没有声明参数结构的命令接受一个字符串数组参数 args。
+func Parameters(info types.CommandInfo) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+
+	if info.Schema == nil {
+		properties[RawArgsProperty] = map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "Command line arguments",
+		}
+	} else {
+		for _, spec := range info.Schema.Args {
+			name := propertyName(spec)
+			properties[name] = property(spec)
+			if spec.Required {
+				required = append(required, name)
+			}
+		}
+		if info.Schema.Passthrough {
+			properties[ExtraArgsProperty] = map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Additional raw options passed through to the command",
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// property 将单个参数转换为 JSON Schema 属性
+func property(spec types.ArgSpec) map[string]interface{} {
+	prop := map[string]interface{}{"type": jsonType(spec.Type)}
+
+	desc := spec.Description
+	if spec.Flag != "" {
+		desc = strings.TrimSpace(fmt.Sprintf("%s (%s)", desc, spec.Flag))
+	}
+	if spec.Type == types.ArgTypePath {
+		desc = strings.TrimSpace(desc + " Path relative to the working directory.")
+	}
+	if desc != "" {
+		prop["description"] = desc
+	}
+	if len(spec.Enum) > 0 {
+		prop["enum"] = spec.Enum
+	}
+	if spec.Default != "" {
+		prop["default"] = typedValue(spec.Type, spec.Default)
+	}
+
+	if spec.Variadic {
+		items := prop
+		prop = map[string]interface{}{"type": "array", "items": items}
+		if d, ok := items["description"]; ok {
+			prop["description"] = d
+			delete(items, "description")
+		}
+		if d, ok := items["default"]; ok {
+			prop["default"] = []interface{}{d}
+			delete(items, "default")
+		}
+	}
+	return prop
+}
+
+// jsonType 返回参数类型对应的 JSON Schema 类型
+func jsonType(t types.ArgType) string {
+	switch t {
+	case types.ArgTypeInt:
+		return "integer"
+	case types.ArgTypeBool:
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// typedValue 将字符串默认值转换为参数类型对应的值
+func typedValue(t types.ArgType, value string) interface{} {
+	switch t {
+	case types.ArgTypeInt:
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	case types.ArgTypeBool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// Render 按指定格式输出工具定义
+func Render(tools []*Tool, format string) ([]interface{}, error) {
+	out := make([]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		switch format {
+		case FormatOpenAI:
+			out = append(out, map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"parameters":  tool.Parameters,
+				},
+			})
+		case FormatAnthropic:
+			out = append(out, map[string]interface{}{
+				"name":         tool.Name,
+				"description":  tool.Description,
+				"input_schema": tool.Parameters,
+			})
+		case FormatJSONSchema, "":
+			schema := map[string]interface{}{
+				"$schema":     "https://json-schema.org/draft/2020-12/schema",
+				"title":       tool.Name,
+				"description": tool.Description,
+			}
+			for k, v := range tool.Parameters {
+				schema[k] = v
+			}
+			out = append(out, schema)
+		default:
+			return nil, fmt.Errorf("unsupported tool format: %s", format)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var headInfo = types.CommandInfo{
+	Name:        "head",
+	Description: "Output the first part of files",
+	Usage:       "head [-n NUM] [file...]",
+	Schema: &types.ArgSchema{
+		Args: []types.ArgSpec{
+			{Name: "lines", Flag: "-n", Type: types.ArgTypeInt, Default: "10", Description: "Line count"},
+			{Name: "quiet", Flag: "-q", Type: types.ArgTypeBool, Description: "Never print headers"},
+			{Name: "file", Type: types.ArgTypePath, Variadic: true, Required: true, Description: "Input files"},
+		},
+	},
+}
+
+func TestParameters(t *testing.T) {
+	params := Parameters(headInfo)
+	assert.Equal(t, "object", params["type"])
+	assert.Equal(t, false, params["additionalProperties"])
+	assert.Equal(t, []string{"file"}, params["required"])
+
+	props := params["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "integer", "default": 10, "description": "Line count (-n)"}, props["lines"])
+	assert.Equal(t, "boolean", props["quiet"].(map[string]interface{})["type"])
+	file := props["file"].(map[string]interface{})
+	assert.Equal(t, "array", file["type"])
+	assert.Equal(t, map[string]interface{}{"type": "string"}, file["items"])
+
+	params = Parameters(types.CommandInfo{Name: "raw"})
+	assert.Contains(t, params["properties"], RawArgsProperty)
+
+	params = Parameters(types.CommandInfo{Name: "ps", Schema: &types.ArgSchema{Passthrough: true}})
+	assert.Contains(t, params["properties"], ExtraArgsProperty)
+}
+
+func TestRegistryRender(t *testing.T) {
+	registry := NewRegistry(
+		[]types.CommandInfo{headInfo, {Name: "head"}},
+		[]types.CommandInfo{{Name: "deploy.app", Description: "Deploy"}},
+	)
+	require.Len(t, registry.Tools(), 2)
+	tool, ok := registry.Lookup("script_deploy_app")
+	require.True(t, ok)
+	assert.True(t, tool.Script)
+	assert.Equal(t, "deploy.app", tool.Info.Name)
+
+	openai, err := Render(registry.Tools(), FormatOpenAI)
+	require.NoError(t, err)
+	fn := openai[0].(map[string]interface{})["function"].(map[string]interface{})
+	assert.Equal(t, "head", fn["name"])
+	assert.Contains(t, fn["description"], "Usage: head [-n NUM] [file...]")
+
+	anthropic, err := Render(registry.Tools(), FormatAnthropic)
+	require.NoError(t, err)
+	assert.Contains(t, anthropic[1], "input_schema")
+
+	schemas, err := Render(registry.Tools(), FormatJSONSchema)
+	require.NoError(t, err)
+	assert.Equal(t, "head", schemas[0].(map[string]interface{})["title"])
+
+	_, err = Render(registry.Tools(), "xml")
+	assert.Error(t, err)
+}
+
+func TestToolCommand(t *testing.T) {
+	tool := NewRegistry([]types.CommandInfo{headInfo}, nil).Tools()[0]
+
+	cmd, err := tool.Command(map[string]interface{}{"lines": json.Number("5"), "quiet": true, "file": []interface{}{"a.txt", "-b.txt"}})
+	require.NoError(t, err)
+	assert.Equal(t, types.Command{Command: "head", Args: []string{"-n", "5", "-q", "--", "a.txt", "-b.txt"}}, cmd)
+	assert.NoError(t, types.ValidateArgs(headInfo, cmd.Args))
+
+	cmd, err = tool.Command(map[string]interface{}{"quiet": false, "file": "a.txt"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt"}, cmd.Args)
+
+	_, err = tool.Command(map[string]interface{}{"file": "a", "bogus": 1})
+	assert.ErrorContains(t, err, `unknown argument "bogus"`)
+	_, err = tool.Command(map[string]interface{}{"lines": "ten", "file": "a"})
+	assert.ErrorContains(t, err, "expected an integer")
+	_, err = tool.Command(map[string]interface{}{})
+	assert.ErrorContains(t, err, "file is required")
+
+	raw := NewRegistry([]types.CommandInfo{{Name: "echo"}}, nil).Tools()[0]
+	cmd, err = raw.Command(map[string]interface{}{"args": []interface{}{"hello", json.Number("1")}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello", "1"}, cmd.Args)
+}
+
+func TestCallArgs(t *testing.T) {
+	var openai Call
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"call_1","type":"function","function":{"name":"head","arguments":"{\"lines\":3}"}}`), &openai))
+	assert.Equal(t, FormatOpenAI, openai.Format())
+	assert.Equal(t, "head", openai.ToolName())
+	args, err := openai.Args()
+	require.NoError(t, err)
+	assert.Equal(t, json.Number("3"), args["lines"])
+
+	var anthropic Call
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"tool_use","id":"toolu_1","name":"head","input":{"file":["a"]}}`), &anthropic))
+	assert.Equal(t, FormatAnthropic, anthropic.Format())
+	args, err = anthropic.Args()
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a"}, args["file"])
+
+	bad := Call{Name: "head", Arguments: json.RawMessage(`"{not json"`)}
+	_, err = bad.Args()
+	assert.Error(t, err)
+}
+
+func TestOutputEnvelope(t *testing.T) {
+	out := &Output{ID: "toolu_1", Name: "ls", ExitCode: 2, Output: "partial", Error: "no such file", IsError: true}
+	assert.Equal(t, "partial\nerror: no such file\nexit code: 2", out.Content())
+
+	anthropic := out.Envelope(FormatAnthropic).(map[string]interface{})
+	assert.Equal(t, "tool_result", anthropic["type"])
+	assert.Equal(t, "toolu_1", anthropic["tool_use_id"])
+	assert.Equal(t, true, anthropic["is_error"])
+
+	openai := out.Envelope(FormatOpenAI).(map[string]interface{})
+	assert.Equal(t, "tool", openai["role"])
+	assert.Equal(t, "toolu_1", openai["tool_call_id"])
+
+	assert.Same(t, out, out.Envelope(FormatJSONSchema))
+}
@@ -0,0 +1,106 @@
+package tracker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// LocalSource 扫描宿主机文件系统中的工作目录
+type LocalSource struct{}
+
+// Scan 实现 Source 接口
+func (LocalSource) Scan(ctx context.Context, root string, opts *types.TrackOptions) (Tree, error) {
+	patterns := IgnorePatterns(opts)
+	maxDiff := MaxDiffSize(opts)
+	tree := make(Tree)
+
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			// 扫描过程中文件被删除属于正常情况
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if Ignored(rel, patterns) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		state := &FileState{
+			Path:    rel,
+			Size:    info.Size(),
+			Mode:    uint32(info.Mode().Perm()),
+			ModTime: info.ModTime(),
+			IsDir:   info.IsDir(),
+		}
+		if info.IsDir() {
+			state.Size = 0
+		} else if info.Mode().IsRegular() && opts != nil {
+			if err := readState(p, state, opts, maxDiff); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		tree[rel] = state
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// readState 按需计算文件哈希并读取用于 diff 的内容
+func readState(p string, state *FileState, opts *types.TrackOptions, maxDiff int64) error {
+	needContent := opts.Diff && state.Size <= maxDiff
+	if !opts.Hash && !needContent {
+		return nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if needContent {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		state.Content = data
+		if opts.Hash {
+			sum := sha256.Sum256(data)
+			state.Hash = hex.EncodeToString(sum[:])
+		}
+		return nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	state.Hash = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
@@ -0,0 +1,259 @@
+// Package tracker 实现了命令执行前后工作目录的文件变更跟踪。
+// 通过对比执行前后的文件指纹（修改时间、大小以及可选的内容哈希）得出
+// 新增、修改、删除的文件列表，并可为小体积文本文件生成 unified diff。
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/iamlongalong/runshell/pkg/diff"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// FileState 表示一个文件的指纹
+type FileState struct {
+	Path    string    // 相对于工作目录的路径，使用 "/" 分隔
+	Size    int64     // 文件大小
+	Mode    uint32    // 权限位
+	ModTime time.Time // 修改时间
+	IsDir   bool      // 是否为目录
+	Hash    string    // 内容哈希，仅在开启 Hash 时计算
+	Content []byte    // 文件内容，仅在开启 Diff 且文件不超过大小上限时读取
+}
+
+// Tree 工作目录的指纹集合，以相对路径为键
+type Tree map[string]*FileState
+
+// Source 用于扫描某个执行环境中的工作目录
+type Source interface {
+	// Scan 扫描工作目录，返回所有未被忽略的文件指纹
+	Scan(ctx context.Context, root string, opts *types.TrackOptions) (Tree, error)
+}
+
+// ContentReader 可以由 Source 实现，用于按路径读取文件内容。
+// 实现了该接口的 Source 在 Scan 时不需要读取内容：开启 Diff 时，Tracker 在执行前读取
+// 不超过大小上限的文件，执行后只读取指纹发生变化的文件。
+type ContentReader interface {
+	// ReadContents 读取 paths 中文件的内容并写入 tree 中对应的 FileState
+	ReadContents(ctx context.Context, root string, paths []string, tree Tree) error
+}
+
+// IgnorePatterns 返回生效的忽略规则
+func IgnorePatterns(opts *types.TrackOptions) []string {
+	if opts == nil || len(opts.Ignore) == 0 {
+		return types.DefaultTrackIgnore
+	}
+	return opts.Ignore
+}
+
+// MaxDiffSize 返回生效的 diff 文件大小上限
+func MaxDiffSize(opts *types.TrackOptions) int64 {
+	if opts == nil || opts.MaxDiffSize <= 0 {
+		return types.DefaultMaxDiffSize
+	}
+	return opts.MaxDiffSize
+}
+
+// Ignored 判断相对路径是否被忽略。
+// 规则会同时与文件名和完整相对路径进行 glob 匹配。
+func Ignored(rel string, patterns []string) bool {
+	base := path.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Tracker 记录一次命令执行前的工作目录状态
+type Tracker struct {
+	source Source
+	root   string
+	opts   *types.TrackOptions
+	before Tree
+}
+
+// Start 扫描执行前的工作目录状态
+func Start(ctx context.Context, source Source, root string, opts *types.TrackOptions) (*Tracker, error) {
+	before, err := source.Scan(ctx, root, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan workdir: %w", err)
+	}
+	readContents(ctx, source, root, opts, before, nil)
+	return &Tracker{
+		source: source,
+		root:   root,
+		opts:   opts,
+		before: before,
+	}, nil
+}
+
+// Finish 扫描执行后的工作目录状态并返回变更列表
+func (t *Tracker) Finish(ctx context.Context) ([]types.FileChange, error) {
+	after, err := t.source.Scan(ctx, t.root, t.opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan workdir: %w", err)
+	}
+	readContents(ctx, t.source, t.root, t.opts, after, func(p string, state *FileState) bool {
+		b, ok := t.before[p]
+		return !ok || modified(b, state)
+	})
+	return Compare(t.before, after, t.opts), nil
+}
+
+// readContents 通过 ContentReader 读取 tree 中需要 diff 的文件内容，
+// 只读取不超过大小上限且满足 want（为 nil 时不限制）的文件。读取失败时只记录日志，变更中不附带 diff。
+func readContents(ctx context.Context, source Source, root string, opts *types.TrackOptions, tree Tree, want func(p string, state *FileState) bool) {
+	reader, ok := source.(ContentReader)
+	if !ok || opts == nil || !opts.Diff {
+		return
+	}
+
+	maxDiff := MaxDiffSize(opts)
+	var paths []string
+	for p, state := range tree {
+		if !state.IsDir && state.Content == nil && state.Size <= maxDiff && (want == nil || want(p, state)) {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	sort.Strings(paths)
+
+	if err := reader.ReadContents(ctx, root, paths, tree); err != nil {
+		log.Error("Failed to read file contents for diff: %v", err)
+	}
+}
+
+// Run 在 fn 执行前后跟踪工作目录，并将变更写入执行结果。
+// 跟踪失败只记录日志，不影响命令本身的执行结果。
+func Run(ctx context.Context, source Source, root string, opts *types.TrackOptions, fn func() (*types.ExecuteResult, error)) (*types.ExecuteResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	t, err := Start(ctx, source, root, opts)
+	if err != nil {
+		log.Error("Failed to start change tracking: %v", err)
+		return fn()
+	}
+
+	result, execErr := fn()
+	if result == nil {
+		return result, execErr
+	}
+
+	changes, err := t.Finish(ctx)
+	if err != nil {
+		log.Error("Failed to finish change tracking: %v", err)
+		return result, execErr
+	}
+	result.Changes = changes
+	return result, execErr
+}
+
+// Compare 对比两次扫描结果，返回按路径排序的变更列表
+func Compare(before, after Tree, opts *types.TrackOptions) []types.FileChange {
+	var changes []types.FileChange
+
+	for p, a := range after {
+		b, ok := before[p]
+		switch {
+		case !ok:
+			changes = append(changes, newChange(p, types.FileCreated, nil, a, opts))
+		case modified(b, a):
+			changes = append(changes, newChange(p, types.FileModified, b, a, opts))
+		}
+	}
+	for p, b := range before {
+		if _, ok := after[p]; !ok {
+			changes = append(changes, newChange(p, types.FileDeleted, b, nil, opts))
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// modified 判断文件是否发生变化。目录的修改时间随子项变化，因此只比较类型。
+func modified(b, a *FileState) bool {
+	if b.IsDir != a.IsDir {
+		return true
+	}
+	if a.IsDir {
+		return false
+	}
+	if b.Mode != a.Mode || b.Size != a.Size {
+		return true
+	}
+	if b.Hash != "" && a.Hash != "" {
+		return b.Hash != a.Hash
+	}
+	return !b.ModTime.Equal(a.ModTime)
+}
+
+// newChange 创建变更记录，按需附带 diff
+func newChange(p, changeType string, b, a *FileState, opts *types.TrackOptions) types.FileChange {
+	change := types.FileChange{Path: p, Type: changeType}
+	if opts == nil || !opts.Diff {
+		return change
+	}
+
+	oldText, ok := diffContent(b)
+	if !ok {
+		return change
+	}
+	newText, ok := diffContent(a)
+	if !ok {
+		return change
+	}
+
+	oldName, newName := "a/"+p, "b/"+p
+	if b == nil {
+		oldName = "/dev/null"
+	}
+	if a == nil {
+		newName = "/dev/null"
+	}
+	change.Diff = diff.Unified(oldName, newName, oldText, newText, diff.DefaultContext)
+	return change
+}
+
+// diffContent 返回可用于 diff 的文本内容，文件不存在时视为空文本
+func diffContent(state *FileState) (string, bool) {
+	if state == nil {
+		return "", true
+	}
+	if state.IsDir || state.Content == nil && state.Size > 0 {
+		return "", false
+	}
+	if !IsText(state.Content) {
+		return "", false
+	}
+	return string(state.Content), true
+}
+
+// IsText 判断内容是否为文本
+func IsText(data []byte) bool {
+	return !bytes.Contains(data, []byte{0}) && utf8.Valid(data)
+}
+
+// Rel 将扫描到的路径转换为相对路径，去掉开头的 "./"
+func Rel(p string) string {
+	return strings.TrimPrefix(path.Clean(p), "./")
+}
@@ -0,0 +1,145 @@
+package tracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnored(t *testing.T) {
+	patterns := []string{".git", "node_modules", "build/*.o"}
+
+	assert.True(t, Ignored(".git", patterns))
+	assert.True(t, Ignored("web/node_modules", patterns))
+	assert.True(t, Ignored("build/main.o", patterns))
+	assert.False(t, Ignored("src/main.go", patterns))
+	assert.False(t, Ignored("main.o", patterns))
+}
+
+func TestCompare(t *testing.T) {
+	now := time.Now()
+	before := Tree{
+		"same.txt":    {Path: "same.txt", Size: 1, ModTime: now},
+		"touched.txt": {Path: "touched.txt", Size: 1, ModTime: now, Hash: "h1"},
+		"changed.txt": {Path: "changed.txt", Size: 1, ModTime: now},
+		"gone.txt":    {Path: "gone.txt", Size: 1, ModTime: now},
+		"dir":         {Path: "dir", IsDir: true, ModTime: now},
+	}
+	after := Tree{
+		"same.txt":    {Path: "same.txt", Size: 1, ModTime: now},
+		"touched.txt": {Path: "touched.txt", Size: 1, ModTime: now.Add(time.Second), Hash: "h1"},
+		"changed.txt": {Path: "changed.txt", Size: 2, ModTime: now},
+		"dir":         {Path: "dir", IsDir: true, ModTime: now.Add(time.Second)},
+		"dir/new.txt": {Path: "dir/new.txt", Size: 1, ModTime: now},
+	}
+
+	changes := Compare(before, after, nil)
+	assert.Equal(t, []types.FileChange{
+		{Path: "changed.txt", Type: types.FileModified},
+		{Path: "dir/new.txt", Type: types.FileCreated},
+		{Path: "gone.txt", Type: types.FileDeleted},
+	}, changes)
+}
+
+func TestCompareDiff(t *testing.T) {
+	opts := &types.TrackOptions{Diff: true}
+	before := Tree{
+		"a.txt":   {Path: "a.txt", Size: 4, Content: []byte("old\n")},
+		"bin.dat": {Path: "bin.dat", Size: 2, Content: []byte{0, 1}},
+	}
+	after := Tree{
+		"a.txt":   {Path: "a.txt", Size: 4, Content: []byte("new\n"), ModTime: time.Now()},
+		"bin.dat": {Path: "bin.dat", Size: 3, Content: []byte{0, 1, 2}},
+		"big.txt": {Path: "big.txt", Size: 1 << 20},
+	}
+
+	changes := Compare(before, after, opts)
+	require.Len(t, changes, 3)
+	assert.Equal(t, "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-old\n+new\n", changes[0].Diff)
+	assert.Empty(t, changes[1].Diff)
+	assert.Empty(t, changes[2].Diff)
+}
+
+func TestLocalSourceScan(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "src"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "node_modules", "pkg"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "src", "main.go"), []byte("package main\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "node_modules", "pkg", "index.js"), []byte("x"), 0644))
+
+	tree, err := LocalSource{}.Scan(context.Background(), root, &types.TrackOptions{Hash: true, Diff: true, MaxDiffSize: 4})
+	require.NoError(t, err)
+
+	assert.Len(t, tree, 2)
+	assert.True(t, tree["src"].IsDir)
+	main := tree["src/main.go"]
+	require.NotNil(t, main)
+	assert.Equal(t, int64(13), main.Size)
+	assert.NotEmpty(t, main.Hash)
+	assert.Nil(t, main.Content)
+}
+
+// contentSource 返回预设的扫描结果，并记录每次读取内容的路径
+type contentSource struct {
+	trees    []Tree
+	contents map[string]string
+	reads    [][]string
+}
+
+func (s *contentSource) Scan(ctx context.Context, root string, opts *types.TrackOptions) (Tree, error) {
+	tree := s.trees[0]
+	s.trees = s.trees[1:]
+	return tree, nil
+}
+
+func (s *contentSource) ReadContents(ctx context.Context, root string, paths []string, tree Tree) error {
+	s.reads = append(s.reads, paths)
+	for _, p := range paths {
+		tree[p].Content = []byte(s.contents[p])
+	}
+	return nil
+}
+
+func TestTrackerContentReader(t *testing.T) {
+	now := time.Now()
+	source := &contentSource{
+		trees: []Tree{
+			{
+				"same.txt":  {Path: "same.txt", Size: 2, ModTime: now},
+				"edit.txt":  {Path: "edit.txt", Size: 2, ModTime: now},
+				"large.bin": {Path: "large.bin", Size: 100, ModTime: now},
+				"dir":       {Path: "dir", IsDir: true, ModTime: now},
+			},
+			{
+				"same.txt":  {Path: "same.txt", Size: 2, ModTime: now},
+				"edit.txt":  {Path: "edit.txt", Size: 3, ModTime: now},
+				"large.bin": {Path: "large.bin", Size: 100, ModTime: now},
+				"dir":       {Path: "dir", IsDir: true, ModTime: now},
+				"dir/new":   {Path: "dir/new", Size: 2, ModTime: now},
+			},
+		},
+		contents: map[string]string{"same.txt": "a\n", "edit.txt": "b\n", "dir/new": "c\n"},
+	}
+	opts := &types.TrackOptions{Diff: true, MaxDiffSize: 10}
+
+	tr, err := Start(context.Background(), source, "/work", opts)
+	require.NoError(t, err)
+	source.contents["edit.txt"] = "bb\n"
+	changes, err := tr.Finish(context.Background())
+	require.NoError(t, err)
+
+	// 执行前读取不超过上限的文件，执行后只读取发生变化的文件
+	assert.Equal(t, [][]string{{"edit.txt", "same.txt"}, {"dir/new", "edit.txt"}}, source.reads)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "dir/new", changes[0].Path)
+	assert.Contains(t, changes[0].Diff, "+c")
+	assert.Equal(t, "edit.txt", changes[1].Path)
+	assert.Contains(t, changes[1].Diff, "-b")
+	assert.Contains(t, changes[1].Diff, "+bb")
+}
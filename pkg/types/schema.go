@@ -0,0 +1,240 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ArgType 表示命令参数的类型
+type ArgType string
+
+const (
+	ArgTypeString ArgType = "string" // 任意字符串
+	ArgTypeInt    ArgType = "int"    // 整数
+	ArgTypeBool   ArgType = "bool"   // 开关选项，不带参数值
+	ArgTypePath   ArgType = "path"   // 文件或目录路径
+)
+
+// ArgSpec 描述命令的一个参数，Flag 为空时表示位置参数
+// swagger:model
+type ArgSpec struct {
+	Name        string   `json:"name" example:"lines"`                       // 参数名称
+	Flag        string   `json:"flag,omitempty" example:"-n"`                // 选项写法，如 "-n"、"--max-bytes"、"-name"
+	Aliases     []string `json:"aliases,omitempty"`                          // 选项的其他写法
+	Type        ArgType  `json:"type" example:"int"`                         // 参数类型
+	Required    bool     `json:"required,omitempty"`                         // 是否必填
+	Default     string   `json:"default,omitempty" example:"10"`             // 默认值
+	Enum        []string `json:"enum,omitempty"`                             // 允许的取值
+	Variadic    bool     `json:"variadic,omitempty"`                         // 位置参数是否可以出现多次，只能用于最后一个位置参数
+	Description string   `json:"description,omitempty" example:"Line count"` // 参数描述
+}
+
+// ArgSchema 描述命令参数的结构，用于在执行前统一校验参数
+// swagger:model
+type ArgSchema struct {
+	Args []ArgSpec `json:"args"` // 选项和位置参数，位置参数按顺序排列
+
+	// Passthrough 为 true 时允许未声明的选项，由系统命令处理。
+	// 出现未声明的选项后无法确定位置参数的对应关系，只检查必填的位置参数数量。
+	Passthrough bool `json:"passthrough,omitempty"`
+
+	// OptionsFirst 为 true 时选项只能出现在第一个位置参数之前，之后的参数都视为位置参数
+	OptionsFirst bool `json:"options_first,omitempty"`
+}
+
+// ArgError 表示命令参数校验失败
+type ArgError struct {
+	Command string // 命令名称
+	Arg     string // 出错的参数名称或选项
+	Reason  string // 失败原因
+}
+
+func (e *ArgError) Error() string {
+	if e.Arg == "" {
+		return fmt.Sprintf("%s: invalid arguments: %s", e.Command, e.Reason)
+	}
+	return fmt.Sprintf("%s: invalid argument %s: %s", e.Command, e.Arg, e.Reason)
+}
+
+// ValidateArgs 根据命令的参数结构校验参数，没有声明参数结构的命令不做校验
+func ValidateArgs(info CommandInfo, args []string) error {
+	if info.Schema == nil {
+		return nil
+	}
+	if err := info.Schema.Validate(args); err != nil {
+		if argErr, ok := err.(*ArgError); ok {
+			argErr.Command = info.Name
+		}
+		return err
+	}
+	return nil
+}
+
+// flag 查找选项对应的参数声明
+func (s *ArgSchema) flag(name string) *ArgSpec {
+	for i := range s.Args {
+		spec := &s.Args[i]
+		if spec.Flag == "" {
+			continue
+		}
+		if spec.Flag == name {
+			return spec
+		}
+		for _, alias := range spec.Aliases {
+			if alias == name {
+				return spec
+			}
+		}
+	}
+	return nil
+}
+
+// positionals 返回按顺序排列的位置参数声明
+func (s *ArgSchema) positionals() []*ArgSpec {
+	var specs []*ArgSpec
+	for i := range s.Args {
+		if s.Args[i].Flag == "" {
+			specs = append(specs, &s.Args[i])
+		}
+	}
+	return specs
+}
+
+// Validate 校验参数，失败时返回 *ArgError
+func (s *ArgSchema) Validate(args []string) error {
+	var positional []string
+	unknown := false
+	seen := make(map[*ArgSpec]bool)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// 单独的 "-"、多行文本以及 OptionsFirst 模式下第一个位置参数之后的参数都是位置参数
+		if len(arg) < 2 || arg[0] != '-' || strings.Contains(arg, "\n") || (s.OptionsFirst && len(positional) > 0) {
+			positional = append(positional, arg)
+			continue
+		}
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+
+		// 完整匹配的选项，包括 "-name" 这类单横线长选项
+		name, value, hasValue := arg, "", false
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue = strings.Cut(arg, "=")
+		}
+		if spec := s.flag(name); spec != nil {
+			seen[spec] = true
+			if spec.Type == ArgTypeBool {
+				continue
+			}
+			if !hasValue {
+				if i+1 >= len(args) {
+					return &ArgError{Arg: name, Reason: "requires a value"}
+				}
+				i++
+				value = args[i]
+			}
+			if err := spec.check(name, value); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(arg, "--") {
+			if !s.Passthrough {
+				return &ArgError{Arg: name, Reason: "unknown option"}
+			}
+			unknown = true
+			continue
+		}
+
+		// 合并书写的短选项，如 -rf、-n5
+		for j := 1; j < len(arg); j++ {
+			short := "-" + arg[j:j+1]
+			spec := s.flag(short)
+			if spec == nil {
+				if !s.Passthrough {
+					return &ArgError{Arg: short, Reason: "unknown option"}
+				}
+				unknown = true
+				break
+			}
+			seen[spec] = true
+			if spec.Type == ArgTypeBool {
+				continue
+			}
+			value := arg[j+1:]
+			if value == "" {
+				if i+1 >= len(args) {
+					return &ArgError{Arg: short, Reason: "requires a value"}
+				}
+				i++
+				value = args[i]
+			}
+			if err := spec.check(short, value); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	for i := range s.Args {
+		spec := &s.Args[i]
+		if spec.Flag != "" && spec.Required && !seen[spec] {
+			return &ArgError{Arg: spec.Flag, Reason: "is required"}
+		}
+	}
+
+	specs := s.positionals()
+	required := 0
+	for _, spec := range specs {
+		if spec.Required {
+			required++
+		}
+	}
+	for i, spec := range specs {
+		if spec.Required && i >= len(positional) {
+			return &ArgError{Arg: spec.Name, Reason: fmt.Sprintf("is required (expected at least %d positional argument(s), got %d)", required, len(positional))}
+		}
+	}
+
+	// 未声明的选项可能带有参数值，此时位置参数无法可靠对应
+	if unknown {
+		return nil
+	}
+	for i, value := range positional {
+		if i >= len(specs) {
+			if len(specs) > 0 && specs[len(specs)-1].Variadic {
+				if err := specs[len(specs)-1].check(specs[len(specs)-1].Name, value); err != nil {
+					return err
+				}
+				continue
+			}
+			return &ArgError{Reason: fmt.Sprintf("too many arguments (expected at most %d, got %d)", len(specs), len(positional))}
+		}
+		if err := specs[i].check(specs[i].Name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check 校验参数值的类型和取值范围
+func (spec *ArgSpec) check(name, value string) error {
+	if spec.Type == ArgTypeInt {
+		if _, err := strconv.Atoi(value); err != nil {
+			return &ArgError{Arg: name, Reason: fmt.Sprintf("expected an integer, got %q", value)}
+		}
+	}
+	if len(spec.Enum) > 0 {
+		for _, v := range spec.Enum {
+			if v == value {
+				return nil
+			}
+		}
+		return &ArgError{Arg: name, Reason: fmt.Sprintf("must be one of %s, got %q", strings.Join(spec.Enum, ", "), value)}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgSchemaValidate(t *testing.T) {
+	schema := &ArgSchema{
+		Args: []ArgSpec{
+			{Name: "recursive", Flag: "-r", Aliases: []string{"-R"}, Type: ArgTypeBool},
+			{Name: "lines", Flag: "-n", Aliases: []string{"--lines"}, Type: ArgTypeInt},
+			{Name: "type", Flag: "-type", Type: ArgTypeString, Enum: []string{"f", "d"}},
+			{Name: "file", Type: ArgTypePath, Required: true},
+			{Name: "rest", Type: ArgTypeInt, Variadic: true},
+		},
+	}
+
+	valid := [][]string{
+		{"a.txt"},
+		{"-rn5", "a.txt"},
+		{"a.txt", "-R", "-n", "3", "1", "2"},
+		{"--lines=3", "a.txt"},
+		{"-type", "d", "a.txt"},
+		{"--", "-a.txt"},
+		{"-"},
+	}
+	for _, args := range valid {
+		assert.NoError(t, schema.Validate(args), "%v", args)
+	}
+
+	invalid := map[string][]string{
+		"invalid argument -x: unknown option":                      {"-x", "a.txt"},
+		"invalid argument --all: unknown option":                   {"--all", "a.txt"},
+		"invalid argument -n: requires a value":                    {"a.txt", "-n"},
+		`invalid argument --lines: expected an integer, got "abc"`: {"--lines", "abc", "a.txt"},
+		`invalid argument -type: must be one of f, d, got "l"`:     {"-type", "l", "a.txt"},
+		"invalid argument file: is required":                       {"-r"},
+		`invalid argument rest: expected an integer, got "x"`:      {"a.txt", "1", "x"},
+	}
+	for msg, args := range invalid {
+		err := schema.Validate(args)
+		if assert.Error(t, err, "%v", args) {
+			assert.Contains(t, err.Error(), msg)
+		}
+	}
+
+	// 非可变参数不能超过声明的数量
+	fixed := &ArgSchema{Args: []ArgSpec{{Name: "file", Type: ArgTypePath}}}
+	assert.EqualError(t, ValidateArgs(CommandInfo{Name: "cat", Schema: fixed}, []string{"a", "b"}),
+		"cat: invalid arguments: too many arguments (expected at most 1, got 2)")
+	assert.NoError(t, ValidateArgs(CommandInfo{Name: "any"}, []string{"--anything"}))
+}
+
+func TestArgSchemaModes(t *testing.T) {
+	passthrough := &ArgSchema{
+		Args: []ArgSpec{
+			{Name: "count", Flag: "-c", Type: ArgTypeInt},
+			{Name: "url", Type: ArgTypeString, Required: true},
+		},
+		Passthrough: true,
+	}
+	assert.NoError(t, passthrough.Validate([]string{"-X", "POST", "-H", "a: b", "http://example.com"}))
+	assert.NoError(t, passthrough.Validate([]string{"--silent", "http://example.com"}))
+	assert.Error(t, passthrough.Validate([]string{"-c", "x", "http://example.com"}))
+	assert.Error(t, passthrough.Validate([]string{"--silent"}))
+
+	optionsFirst := &ArgSchema{
+		Args: []ArgSpec{
+			{Name: "all", Flag: "--all", Type: ArgTypeBool},
+			{Name: "file", Type: ArgTypePath, Required: true},
+			{Name: "old", Type: ArgTypeString, Required: true},
+			{Name: "new", Type: ArgTypeString, Required: true},
+		},
+		OptionsFirst: true,
+	}
+	assert.NoError(t, optionsFirst.Validate([]string{"--all", "a.go", "-x", "--y"}))
+	assert.Error(t, optionsFirst.Validate([]string{"--bogus", "a.go", "x", "y"}))
+
+	// 多行参数始终视为位置参数
+	assert.NoError(t, optionsFirst.Validate([]string{"a.go", "--- a\n+++ b\n", "z"}))
+}
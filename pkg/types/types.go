@@ -3,6 +3,7 @@ package types
 import (
 	"context"
 	"io"
+	"os"
 	"time"
 )
 
@@ -40,6 +41,31 @@ type ExecuteOptions struct {
 
 	// Shell 指定执行命令的 shell, 默认使用 /bin/bash
 	Shell string `json:"shell,omitempty"`
+
+	// Track 开启后记录命令执行前后工作目录中的文件变更
+	Track *TrackOptions `json:"track,omitempty"`
+}
+
+// DefaultTrackIgnore 文件变更跟踪默认忽略的路径
+var DefaultTrackIgnore = []string{".git", "node_modules"}
+
+// DefaultMaxDiffSize 生成 diff 的文件大小上限（字节）
+const DefaultMaxDiffSize = 64 * 1024
+
+// TrackOptions 文件变更跟踪选项
+// swagger:model
+type TrackOptions struct {
+	// Hash 是否比较文件内容哈希，默认仅比较修改时间和大小
+	Hash bool `json:"hash,omitempty"`
+
+	// Ignore 忽略的 glob 列表，匹配文件名或相对路径，为空时使用 DefaultTrackIgnore
+	Ignore []string `json:"ignore,omitempty" example:".git,node_modules"`
+
+	// Diff 是否为文本文件生成 unified diff
+	Diff bool `json:"diff,omitempty"`
+
+	// MaxDiffSize 生成 diff 的文件大小上限（字节），为 0 时使用 DefaultMaxDiffSize
+	MaxDiffSize int64 `json:"max_diff_size,omitempty" example:"65536"`
 }
 
 // Merge 合并两个执行选项, 用于处理默认选项和用自定义选项
@@ -63,6 +89,7 @@ func (opts *ExecuteOptions) Merge(other *ExecuteOptions) *ExecuteOptions {
 			Stdout:   other.Stdout,
 			Stderr:   other.Stderr,
 			User:     other.User,
+			Track:    other.Track,
 			Env:      make(map[string]string),
 			Metadata: make(map[string]string),
 		}
@@ -92,6 +119,7 @@ func (opts *ExecuteOptions) Merge(other *ExecuteOptions) *ExecuteOptions {
 		Stdout:   opts.Stdout,
 		Stderr:   opts.Stderr,
 		User:     opts.User,
+		Track:    opts.Track,
 		Env:      make(map[string]string),
 		Metadata: make(map[string]string),
 	}
@@ -144,6 +172,10 @@ func (opts *ExecuteOptions) Merge(other *ExecuteOptions) *ExecuteOptions {
 		result.User = other.User
 	}
 
+	if other.Track != nil {
+		result.Track = other.Track
+	}
+
 	// 合并元数据
 	if other.Metadata != nil {
 		for k, v := range other.Metadata {
@@ -226,6 +258,50 @@ type ExecuteResult struct {
 
 	// Output 是命令的输出
 	Output string
+
+	// Changes 是命令执行引起的文件变更，仅在开启 Track 时记录
+	Changes []FileChange
+
+	// Data 是命令返回的结构化结果，与 Output 中的文本表示相对应
+	Data interface{}
+
+	// Attempts 记录重试时每次尝试的结果，包括最后一次，未经过重试时为空
+	Attempts []ExecuteAttempt
+
+	// CacheStatus 是结果缓存的状态，CacheHit 或 CacheMiss，命令不可缓存时为空
+	CacheStatus string
+
+	// Stages 记录管道中每个命令的结果，按管道顺序排列，非管道命令时为空
+	Stages []StageResult
+}
+
+// 结果缓存状态
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// ExecuteAttempt 表示命令的一次执行尝试。
+// swagger:model
+type ExecuteAttempt struct {
+	Attempt   int           `json:"attempt" example:"1"`   // 尝试次数，从 1 开始
+	ExitCode  int           `json:"exit_code" example:"1"` // 退出码
+	StartTime time.Time     `json:"start_time"`            // 开始时间
+	EndTime   time.Time     `json:"end_time"`              // 结束时间
+	Error     string        `json:"error,omitempty"`       // 错误信息
+	Backoff   time.Duration `json:"backoff,omitempty"`     // 下一次尝试前的等待时间（纳秒）
+}
+
+// StageResult 表示管道中一个命令的执行结果。
+// swagger:model
+type StageResult struct {
+	Command   string    `json:"command" example:"grep"`             // 命令名称
+	Args      []string  `json:"args,omitempty"`                     // 命令参数
+	Executor  string    `json:"executor,omitempty" example:"local"` // 执行命令的执行器，跨执行器的管道中记录
+	ExitCode  int       `json:"exit_code" example:"0"`              // 退出码，被信号终止时为 128+信号值
+	StartTime time.Time `json:"start_time"`                         // 开始时间
+	EndTime   time.Time `json:"end_time"`                           // 结束时间
+	Error     string    `json:"error,omitempty"`                    // 错误信息
 }
 
 // ResourceUsage 记录命令执行过程中的资源使用情况。
@@ -272,6 +348,7 @@ type CommandInfo struct {
 	Usage       string            `json:"usage" example:"ls [options] [path]"`  // 命令用法
 	Category    string            `json:"category,omitempty"`                   // 命令类
 	Metadata    map[string]string `json:"metadata,omitempty"`                   // 命令元数据
+	Schema      *ArgSchema        `json:"schema,omitempty"`                     // 参数结构，为空时不校验参数
 }
 
 // ICommand 定义了命令处理器的接口
@@ -308,6 +385,114 @@ type Executor interface {
 	Close() error
 }
 
+// Unwrapper 由包装其他执行器的装饰器实现，用于获取被包装的执行器
+type Unwrapper interface {
+	// Unwrap 返回被包装的执行器
+	Unwrap() Executor
+}
+
+// As 沿着装饰器链查找实现了 T 的执行器。
+// 用于获取执行器的可选能力（如 WorkDirArchiver）。
+func As[T any](executor Executor) (T, bool) {
+	for executor != nil {
+		if v, ok := executor.(T); ok {
+			return v, true
+		}
+		u, ok := executor.(Unwrapper)
+		if !ok {
+			break
+		}
+		executor = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// WorkDirArchiver 定义了工作目录归档的接口。
+// 执行器实现该接口后即可支持工作目录快照与回滚。
+// 归档使用 tar 格式，条目路径均相对于工作目录。
+type WorkDirArchiver interface {
+	// ArchiveWorkDir 将工作目录打包为 tar 流，workDir 为空时使用执行器默认工作目录
+	ArchiveWorkDir(ctx context.Context, workDir string) (io.ReadCloser, error)
+
+	// RestoreWorkDir 用 tar 流替换工作目录的内容，tar 流无法完整解包时工作目录保持不变
+	RestoreWorkDir(ctx context.Context, workDir string, archive io.Reader) error
+}
+
+// FileInfo 描述文件系统中的一个条目
+// swagger:model
+type FileInfo struct {
+	Name    string      `json:"name" example:"main.go"`                   // 文件名
+	Path    string      `json:"path" example:"src/main.go"`               // 请求时使用的路径
+	Size    int64       `json:"size" example:"1024"`                      // 文件大小（字节）
+	Mode    os.FileMode `json:"mode" swaggertype:"integer" example:"420"` // 文件类型与权限位
+	ModTime time.Time   `json:"mtime"`                                    // 修改时间
+	IsDir   bool        `json:"is_dir"`                                   // 是否为目录
+	Link    string      `json:"link,omitempty"`                           // 符号链接指向的路径
+}
+
+// FileSystem 定义了执行环境中的文件系统访问接口。
+// 相对路径相对于工作目录解析，不存在的文件返回的错误满足 errors.Is(err, os.ErrNotExist)。
+type FileSystem interface {
+	// Stat 返回文件信息，不跟随符号链接
+	Stat(ctx context.Context, name string) (*FileInfo, error)
+
+	// ReadDir 返回目录中的条目，按名称排序
+	ReadDir(ctx context.Context, name string) ([]FileInfo, error)
+
+	// ReadFile 读取文件内容
+	ReadFile(ctx context.Context, name string) ([]byte, error)
+
+	// Open 打开文件用于流式读取，跟随符号链接。读取大文件的一部分时应使用 Open，
+	// 读取到所需内容后即可关闭，不需要加载整个文件
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// WriteFile 写入文件，文件不存在时以 perm 权限创建
+	WriteFile(ctx context.Context, name string, data []byte, perm os.FileMode) error
+
+	// OpenFile 打开文件用于流式写入，跟随符号链接。flag 与 os.OpenFile 相同，
+	// 支持 os.O_CREATE、os.O_TRUNC 和 os.O_APPEND，文件不存在时以 perm 权限创建。
+	// 写入的内容直接写入文件，不在内存中缓存
+	OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (io.WriteCloser, error)
+
+	// Mkdir 创建目录，parents 为 true 时同时创建不存在的上级目录
+	Mkdir(ctx context.Context, name string, perm os.FileMode, parents bool) error
+
+	// Remove 删除文件或目录，recursive 为 true 时递归删除目录
+	Remove(ctx context.Context, name string, recursive bool) error
+
+	// Rename 重命名文件或目录，目标已存在时覆盖
+	Rename(ctx context.Context, oldName, newName string) error
+}
+
+// FileSystemProvider 定义了提供文件系统访问能力的接口。
+// 内置文件命令优先通过该接口操作文件，不依赖执行环境中的系统命令。
+type FileSystemProvider interface {
+	// FileSystem 返回以 workDir 为工作目录的文件系统，workDir 为空时使用执行器默认工作目录
+	FileSystem(workDir string) (FileSystem, error)
+}
+
+// CacheInvalidator 定义了缓存命令结果的执行器的能力，
+// 通过执行器以外的途径（如文件接口、快照恢复）修改工作目录后调用 InvalidateCache 清除缓存的结果。
+type CacheInvalidator interface {
+	InvalidateCache()
+}
+
+// 文件变更类型
+const (
+	FileCreated  = "created"
+	FileModified = "modified"
+	FileDeleted  = "deleted"
+)
+
+// FileChange 表示工作目录中一个文件的变更
+// swagger:model
+type FileChange struct {
+	Path string `json:"path" example:"src/main.go"` // 相对于工作目录的路径
+	Type string `json:"type" example:"modified"`    // 变更类型（created/modified/deleted）
+	Diff string `json:"diff,omitempty"`             // 文本文件的 unified diff
+}
+
 // ErrCommandNotFound 表示命令未找到
 var ErrCommandNotFound = NewExecuteError("command not found", "COMMAND_NOT_FOUND")
 
@@ -344,8 +529,9 @@ func GetTimeNow() time.Time {
 
 // Command 表示命令
 type Command struct {
-	Command string   // 命令名称
-	Args    []string // 命令参数
+	Command  string   // 命令名称
+	Args     []string // 命令参数
+	Executor string   // 执行命令的执行器名称，用于跨执行器的管道，为空时使用当前执行器
 }
 
 // PipelineContext 表示管道上下文
@@ -354,6 +540,7 @@ type PipelineContext struct {
 
 	Commands []*Command      // 管道中的命令列表
 	Options  *ExecuteOptions // 执行选项
+	Pipefail bool            // 为 true 时管道的退出码为最后一个失败命令的退出码，否则为最后一个命令的退出码
 }
 
 // Session 表示一个执行会话
@@ -395,12 +582,17 @@ const (
 	ExecutorTypeLocal = "local"
 	// ExecutorTypeDocker 表示 Docker 执行器
 	ExecutorTypeDocker = "docker"
+	// ExecutorTypeRemote 表示调用其他 runshell 服务端的远程执行器
+	ExecutorTypeRemote = "remote"
+	// ExecutorTypeSSH 表示通过 SSH 在远程主机上执行命令的执行器
+	ExecutorTypeSSH = "ssh"
 )
 
 // SessionRequest 表示创建会话的请求
 // swagger:model
 type SessionRequest struct {
-	ExecutorType string            `json:"executor_type,omitempty"` // 执行器类型（local/docker）
+	ExecutorType string            `json:"executor_type,omitempty"` // 执行器类型（local/docker/remote/ssh）
+	Remote       string            `json:"remote,omitempty"`        // 远程服务端或 SSH 主机的名称，对应服务端的配置
 	DockerConfig *DockerConfig     `json:"docker_config,omitempty"` // Docker 执行器配置
 	LocalConfig  *LocalConfig      `json:"local_config,omitempty"`  // 本地执行器配置
 	Options      *ExecuteOptions   `json:"options,omitempty"`       // 执行选项
@@ -423,6 +615,8 @@ type CommandExecution struct {
 	ExitCode  int       // 退出码
 	Error     error     // 错误信息
 	Status    string    // 执行状态
+	Attempt   int       // 重试时的尝试次数，从 1 开始，未经过重试时为 0
+	Script    string    // 命令所属的 shell 脚本，命令不是由脚本执行时为空
 }
 
 // Auditor 定义审计器接口
@@ -433,12 +627,65 @@ type Auditor interface {
 
 // DockerConfig 表示 Docker 执行器的配置
 type DockerConfig struct {
-	Image                     string // Docker 镜像
-	WorkDir                   string // 工作目录
-	User                      string // 用户
-	BindMount                 string // 目录绑定
-	AllowUnregisteredCommands bool   // 是否允许执行未注册的命令
-	UseBuiltinCommands        bool   // 是否使用内置命令
+	Image                     string `json:"image,omitempty"`                       // Docker 镜像
+	WorkDir                   string `json:"workdir,omitempty"`                     // 工作目录
+	User                      string `json:"user,omitempty"`                        // 用户
+	BindMount                 string `json:"bind_mount,omitempty"`                  // 目录绑定
+	AllowUnregisteredCommands bool   `json:"allow_unregistered_commands,omitempty"` // 是否允许执行未注册的命令
+	UseBuiltinCommands        bool   `json:"use_builtin_commands,omitempty"`        // 是否使用内置命令
+
+	// 资源限制，0 表示不限制
+	Memory    int64   `json:"memory,omitempty"`     // 内存上限（字节）
+	CPUs      float64 `json:"cpus,omitempty"`       // CPU 配额（核数），如 1.5
+	PidsLimit int64   `json:"pids_limit,omitempty"` // 进程数上限
+
+	// 网络和隔离
+	NetworkMode     string            `json:"network_mode,omitempty"`      // 网络模式，如 bridge、host、none，默认为 Docker 的默认网络
+	ReadOnly        bool              `json:"read_only,omitempty"`         // 是否以只读方式挂载根文件系统
+	Tmpfs           map[string]string `json:"tmpfs,omitempty"`             // tmpfs 挂载，键为容器内路径，值为挂载选项（如 "size=64m"），只读根文件系统时用于提供可写目录
+	CapDrop         []string          `json:"cap_drop,omitempty"`          // 移除的 Linux capabilities，如 ALL、NET_RAW
+	NoNewPrivileges bool              `json:"no_new_privileges,omitempty"` // 是否禁止进程获取新的权限（如通过 setuid）
+	SecurityOpt     []string          `json:"security_opt,omitempty"`      // 安全选项，如 "seccomp=/path/profile.json"
+	Ulimits         []Ulimit          `json:"ulimits,omitempty"`           // 资源 ulimit
+	ExtraHosts      []string          `json:"extra_hosts,omitempty"`       // 额外的 hosts 记录，格式为 host:ip
+	Labels          map[string]string `json:"labels,omitempty"`            // 容器标签
+	DNS             []string          `json:"dns,omitempty"`               // DNS 服务器
+}
+
+// Ulimit 表示容器的 ulimit 设置
+type Ulimit struct {
+	Name string `json:"name"` // 名称，如 nofile、nproc
+	Soft int64  `json:"soft"` // 软限制
+	Hard int64  `json:"hard"` // 硬限制
+}
+
+// RemoteConfig 远程执行器的配置，认证信息和 TLS 设置用于访问远程服务端
+type RemoteConfig struct {
+	URL                string            // 远程服务端地址，例如 https://build-1:8080
+	Token              string            // Bearer Token
+	Username           string            // Basic 认证用户名
+	Password           string            // Basic 认证密码
+	Headers            map[string]string // 每个请求附带的请求头
+	CAFile             string            // 校验服务端证书的 CA 证书文件
+	CertFile           string            // 客户端证书文件
+	KeyFile            string            // 客户端私钥文件
+	InsecureSkipVerify bool              // 是否跳过服务端证书校验
+}
+
+// SSHConfig SSH 执行器的配置。
+// 认证方式按私钥、ssh-agent、密码的顺序尝试，默认通过 known_hosts 校验主机密钥。
+type SSHConfig struct {
+	Host                  string        // 主机地址，可以包含端口，默认端口为 22
+	User                  string        // 登录用户
+	Password              string        // 登录密码
+	KeyFile               string        // 私钥文件
+	KeyPassphrase         string        // 私钥密码
+	UseAgent              bool          // 是否使用 SSH_AUTH_SOCK 指定的 ssh-agent
+	KnownHostsFile        string        // known_hosts 文件，为空时使用 ~/.ssh/known_hosts
+	InsecureIgnoreHostKey bool          // 是否跳过主机密钥校验
+	ConnectTimeout        time.Duration // 建立连接的超时时间
+	WorkDir               string        // 工作目录
+	UseBuiltinCommands    bool          // 是否使用内置命令
 }
 
 // LocalConfig 本地执行器配置
@@ -484,4 +731,13 @@ type InteractiveOptions struct {
 
 	// Raw 是否使用原始模式
 	Raw bool `json:"raw,omitempty"`
+
+	// Resize 接收终端大小调整，执行器在命令运行期间应用收到的大小
+	Resize <-chan TerminalSize `json:"-"`
+}
+
+// TerminalSize 表示终端大小
+type TerminalSize struct {
+	Rows uint16 `json:"rows"`
+	Cols uint16 `json:"cols"`
 }
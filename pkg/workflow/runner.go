@@ -0,0 +1,545 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamlongalong/runshell/pkg/executor"
+	"github.com/iamlongalong/runshell/pkg/log"
+	"github.com/iamlongalong/runshell/pkg/types"
+)
+
+// 运行和步骤的状态
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+	StatusCanceled  = "canceled"
+)
+
+// 事件类型
+const (
+	EventRunStarted   = "run_started"
+	EventStepStarted  = "step_started"
+	EventStepOutput   = "step_output"
+	EventStepFinished = "step_finished"
+	EventRunFinished  = "run_finished"
+)
+
+// maxStepOutput 运行状态中保存的每个步骤输出的最大字节数，超出时保留末尾部分
+const maxStepOutput = 64 * 1024
+
+// Run 表示一次工作流运行的状态
+// swagger:model
+type Run struct {
+	ID        string       `json:"id"`                 // 运行 ID
+	Workflow  string       `json:"workflow,omitempty"` // 工作流名称
+	Status    string       `json:"status"`             // 运行状态
+	StartTime time.Time    `json:"start_time"`         // 开始时间
+	EndTime   time.Time    `json:"end_time"`           // 结束时间，运行中为零值
+	Steps     []*StepState `json:"steps"`              // 每个步骤的状态，与工作流中的步骤顺序一致
+	Error     string       `json:"error,omitempty"`    // 运行失败的原因
+}
+
+// StepState 表示步骤的运行状态
+// swagger:model
+type StepState struct {
+	ID        string            `json:"id"`                 // 步骤 ID
+	Status    string            `json:"status"`             // 步骤状态
+	ExitCode  int               `json:"exit_code"`          // 最后一次执行的退出码，执行器没有返回结果时为 -1
+	Attempts  int               `json:"attempts,omitempty"` // 执行次数
+	StartTime time.Time         `json:"start_time"`         // 开始时间
+	EndTime   time.Time         `json:"end_time"`           // 结束时间
+	Output    string            `json:"output,omitempty"`   // 最后一次执行的输出，超过 64KB 时只保留末尾部分
+	Outputs   map[string]string `json:"outputs,omitempty"`  // 捕获的输出变量
+	Error     string            `json:"error,omitempty"`    // 错误信息
+}
+
+// Copy 返回运行状态的深拷贝
+func (r *Run) Copy() *Run {
+	c := *r
+	c.Steps = make([]*StepState, len(r.Steps))
+	for i, s := range r.Steps {
+		c.Steps[i] = s.Copy()
+	}
+	return &c
+}
+
+// Copy 返回步骤状态的深拷贝
+func (s *StepState) Copy() *StepState {
+	c := *s
+	if s.Outputs != nil {
+		c.Outputs = make(map[string]string, len(s.Outputs))
+		for k, v := range s.Outputs {
+			c.Outputs[k] = v
+		}
+	}
+	return &c
+}
+
+// Event 表示工作流运行过程中的一个事件
+// swagger:model
+type Event struct {
+	Type    string     `json:"type"`              // 事件类型
+	RunID   string     `json:"run_id"`            // 运行 ID
+	StepID  string     `json:"step_id,omitempty"` // 步骤 ID
+	Attempt int        `json:"attempt,omitempty"` // 步骤的第几次执行
+	Stdout  string     `json:"stdout,omitempty"`  // 标准输出数据块，仅 step_output 事件
+	Stderr  string     `json:"stderr,omitempty"`  // 标准错误数据块，仅 step_output 事件
+	Step    *StepState `json:"step,omitempty"`    // 步骤状态，仅 step_started 和 step_finished 事件
+	Run     *Run       `json:"run,omitempty"`     // 运行状态，仅 run_started 和 run_finished 事件
+	Time    time.Time  `json:"time"`              // 事件时间
+}
+
+// Runner 在执行器上运行工作流。
+// 步骤按 shell 语法在 executor.PipelineExecutor 中执行，因此 run 中可以使用命令列表、条件执行和重定向。
+type Runner struct {
+	executor    types.Executor
+	pipeline    *executor.PipelineExecutor
+	store       Store
+	maxParallel int
+	options     *types.ExecuteOptions
+}
+
+// NewRunner 创建工作流运行器，默认在内存中保存运行状态，并发执行的步骤数量不受限制
+func NewRunner(exec types.Executor) *Runner {
+	return &Runner{
+		executor: exec,
+		pipeline: executor.NewPipelineExecutor(exec),
+		store:    NewMemoryStore(),
+	}
+}
+
+// WithStore 设置保存运行状态的存储
+func (r *Runner) WithStore(store Store) *Runner {
+	r.store = store
+	return r
+}
+
+// WithMaxParallel 设置同时执行的步骤数量上限，小于等于 0 时不限制
+func (r *Runner) WithMaxParallel(n int) *Runner {
+	r.maxParallel = n
+	return r
+}
+
+// WithOptions 设置所有步骤的基础执行选项（工作目录和环境变量），
+// 工作流和步骤中的设置覆盖这里的设置
+func (r *Runner) WithOptions(options *types.ExecuteOptions) *Runner {
+	r.options = options
+	return r
+}
+
+// Store 返回保存运行状态的存储
+func (r *Runner) Store() Store {
+	return r.store
+}
+
+// stepResult 是一个步骤执行结束的通知
+type stepResult struct {
+	index   int
+	outputs map[string]string
+}
+
+// run 是一次运行的状态，所有字段由 mu 保护
+type run struct {
+	runner  *Runner
+	wf      *Workflow
+	state   *Run
+	handler func(*Event)
+	mu      sync.Mutex
+	outputs map[string]map[string]string // 步骤 ID -> 输出变量
+}
+
+// Run 运行工作流，返回运行结束时的状态。
+// handler 不为 nil 时接收运行过程中的事件，事件按顺序串行传递。
+// 有步骤失败时运行状态为 failed，依赖失败步骤的步骤被跳过，其他步骤继续执行；
+// ctx 取消时正在执行的步骤被终止，未开始的步骤标记为 canceled。
+// 只有工作流校验失败时返回错误。
+func (r *Runner) Run(ctx context.Context, wf *Workflow, handler func(*Event)) (*Run, error) {
+	if err := wf.Validate(); err != nil {
+		return nil, err
+	}
+
+	state := &Run{
+		ID:        uuid.New().String(),
+		Workflow:  wf.Name,
+		Status:    StatusRunning,
+		StartTime: time.Now(),
+		Steps:     make([]*StepState, len(wf.Steps)),
+	}
+	for i, step := range wf.Steps {
+		state.Steps[i] = &StepState{ID: step.ID, Status: StatusPending}
+	}
+	rn := &run{
+		runner:  r,
+		wf:      wf,
+		state:   state,
+		handler: handler,
+		outputs: make(map[string]map[string]string),
+	}
+
+	log.Info("Starting workflow run %s (%s) with %d steps", state.ID, wf.Name, len(wf.Steps))
+	rn.mu.Lock()
+	rn.save()
+	rn.emit(&Event{Type: EventRunStarted, Run: state.Copy()})
+	rn.mu.Unlock()
+
+	rn.schedule(ctx)
+
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	state.EndTime = time.Now()
+	switch {
+	case ctx.Err() != nil:
+		state.Status = StatusCanceled
+		state.Error = ctx.Err().Error()
+	case rn.failed():
+		state.Status = StatusFailed
+	default:
+		state.Status = StatusSucceeded
+	}
+	rn.save()
+	rn.emit(&Event{Type: EventRunFinished, Run: state.Copy()})
+	log.Info("Workflow run %s finished: %s", state.ID, state.Status)
+	return state.Copy(), nil
+}
+
+// schedule 按依赖关系调度步骤，依赖都结束后启动步骤，直到所有步骤结束
+func (rn *run) schedule(ctx context.Context) {
+	index := make(map[string]int, len(rn.wf.Steps))
+	for i, step := range rn.wf.Steps {
+		index[step.ID] = i
+	}
+	done := make(chan stepResult)
+	running := 0
+
+	for {
+		rn.mu.Lock()
+		remaining := 0
+		for i, step := range rn.wf.Steps {
+			st := rn.state.Steps[i]
+			if st.Status != StatusPending {
+				continue
+			}
+			remaining++
+
+			ready, blocked := true, false
+			for _, need := range step.Needs {
+				switch dep := rn.state.Steps[index[need]]; dep.Status {
+				case StatusSucceeded:
+				case StatusFailed:
+					if !rn.wf.Steps[index[need]].ContinueOnError {
+						blocked = true
+					}
+				case StatusSkipped, StatusCanceled:
+					blocked = true
+				default:
+					ready = false
+				}
+			}
+			switch {
+			case !ready:
+				continue
+			case ctx.Err() != nil:
+				rn.finish(i, StatusCanceled, "workflow run canceled")
+			case blocked:
+				rn.finish(i, StatusSkipped, "a required step did not succeed")
+			case rn.runner.maxParallel > 0 && running >= rn.runner.maxParallel:
+				continue
+			default:
+				st.Status = StatusRunning
+				st.StartTime = time.Now()
+				rn.save()
+				rn.emit(&Event{Type: EventStepStarted, StepID: step.ID, Step: st.Copy()})
+				running++
+				outputs := rn.referencedOutputs(step)
+				go func(i int, step *Step) {
+					done <- rn.runStep(ctx, i, step, outputs)
+				}(i, step)
+			}
+			remaining--
+		}
+		rn.mu.Unlock()
+
+		if running == 0 {
+			if remaining == 0 {
+				return
+			}
+			// 没有正在执行的步骤时，剩余步骤的状态会在下一轮确定
+			continue
+		}
+		result := <-done
+		running--
+		rn.mu.Lock()
+		if result.outputs != nil {
+			rn.outputs[rn.wf.Steps[result.index].ID] = result.outputs
+		}
+		rn.mu.Unlock()
+	}
+}
+
+// referencedOutputs 返回步骤可以引用的输出，调用时需持有 mu
+func (rn *run) referencedOutputs(step *Step) map[string]map[string]string {
+	outputs := make(map[string]map[string]string)
+	for _, ref := range step.refs() {
+		outputs[ref[0]] = rn.outputs[ref[0]]
+	}
+	return outputs
+}
+
+// failed 判断是否有导致运行失败的步骤，调用时需持有 mu
+func (rn *run) failed() bool {
+	for i, st := range rn.state.Steps {
+		if st.Status == StatusFailed && !rn.wf.Steps[i].ContinueOnError || st.Status == StatusSkipped || st.Status == StatusCanceled {
+			return true
+		}
+	}
+	return false
+}
+
+// finish 结束没有执行的步骤，调用时需持有 mu
+func (rn *run) finish(i int, status, reason string) {
+	st := rn.state.Steps[i]
+	st.Status = status
+	st.Error = reason
+	st.StartTime = time.Now()
+	st.EndTime = st.StartTime
+	rn.save()
+	rn.emit(&Event{Type: EventStepFinished, StepID: st.ID, Step: st.Copy()})
+}
+
+// save 保存运行状态，调用时需持有 mu
+func (rn *run) save() {
+	if rn.runner.store == nil {
+		return
+	}
+	if err := rn.runner.store.Save(rn.state); err != nil {
+		log.Error("Failed to save workflow run %s: %v", rn.state.ID, err)
+	}
+}
+
+// emit 发送事件，调用时需持有 mu
+func (rn *run) emit(event *Event) {
+	if rn.handler == nil {
+		return
+	}
+	event.RunID = rn.state.ID
+	event.Time = time.Now()
+	rn.handler(event)
+}
+
+// runStep 执行步骤，失败时按重试策略重新执行
+func (rn *run) runStep(ctx context.Context, i int, step *Step, outputs map[string]map[string]string) stepResult {
+	cmd := types.Command{Command: expand(step.Run, outputs)}
+	if step.Command != "" {
+		cmd.Command = expand(step.Command, outputs)
+		for _, arg := range step.Args {
+			cmd.Args = append(cmd.Args, expand(arg, outputs))
+		}
+	}
+	options := rn.stepOptions(step, outputs)
+
+	maxAttempts := 1
+	retry := executor.RetryRule{}
+	if step.Retry != nil {
+		maxAttempts = step.Retry.MaxAttempts
+		retry.InitialBackoff = time.Duration(step.Retry.Backoff)
+	}
+
+	var stdout []byte
+	var exitCode int
+	var output string
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		log.Debug("Running workflow step %s (attempt %d): %s %v", step.ID, attempt, cmd.Command, cmd.Args)
+		stdout, output, exitCode, err = rn.execute(ctx, step, attempt, cmd, options)
+		if err == nil || attempt >= maxAttempts || ctx.Err() != nil {
+			break
+		}
+		log.Info("Workflow step %s failed (attempt %d/%d): %v", step.ID, attempt, maxAttempts, err)
+		if step.Retry != nil && step.Retry.Backoff == 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(retry.Backoff(attempt)):
+		}
+	}
+
+	result := stepResult{index: i}
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	st := rn.state.Steps[i]
+	st.EndTime = time.Now()
+	st.Attempts = attempt
+	st.ExitCode = exitCode
+	st.Output = output
+	switch {
+	case err == nil:
+		st.Status = StatusSucceeded
+		st.Outputs = captureOutputs(step, stdout)
+		result.outputs = st.Outputs
+	case ctx.Err() != nil:
+		st.Status = StatusCanceled
+		st.Error = err.Error()
+	default:
+		st.Status = StatusFailed
+		st.Error = err.Error()
+	}
+	rn.save()
+	rn.emit(&Event{Type: EventStepFinished, StepID: step.ID, Attempt: attempt, Step: st.Copy()})
+	return result
+}
+
+// stepOptions 合并运行器、工作流和步骤的工作目录与环境变量
+func (rn *run) stepOptions(step *Step, outputs map[string]map[string]string) *types.ExecuteOptions {
+	options := &types.ExecuteOptions{Env: make(map[string]string)}
+	if base := rn.runner.options; base != nil {
+		options.WorkDir = base.WorkDir
+		options.User = base.User
+		for k, v := range base.Env {
+			options.Env[k] = v
+		}
+	}
+	for k, v := range rn.wf.Env {
+		options.Env[k] = v
+	}
+	for k, v := range step.Env {
+		options.Env[k] = expand(v, outputs)
+	}
+	for _, dir := range []string{rn.wf.WorkDir, expand(step.WorkDir, outputs)} {
+		if dir == "" {
+			continue
+		}
+		if path.IsAbs(dir) || options.WorkDir == "" {
+			options.WorkDir = dir
+		} else {
+			options.WorkDir = path.Join(options.WorkDir, dir)
+		}
+	}
+	return options
+}
+
+// execute 执行一次步骤，返回标准输出、合并的输出、退出码和错误
+func (rn *run) execute(ctx context.Context, step *Step, attempt int, cmd types.Command, base *types.ExecuteOptions) ([]byte, string, int, error) {
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(step.Timeout))
+		defer cancel()
+	}
+
+	var stdout bytes.Buffer
+	output := &tailBuffer{max: maxStepOutput}
+	stdoutWriter := &eventWriter{run: rn, step: step.ID, attempt: attempt, buf: &stdout, output: output}
+	stderrWriter := &eventWriter{run: rn, step: step.ID, attempt: attempt, output: output, stderr: true}
+
+	options := *base
+	options.Stdout = stdoutWriter
+	options.Stderr = stderrWriter
+	result, err := rn.runner.pipeline.Execute(&types.ExecuteContext{
+		Context:  ctx,
+		Command:  cmd,
+		Options:  &options,
+		Executor: rn.runner.executor,
+	})
+	if result == nil {
+		if err == nil {
+			err = fmt.Errorf("executor returned no result")
+		}
+		return stdout.Bytes(), output.String(), -1, err
+	}
+	// 执行器没有写入输出流时使用结果中的输出
+	if result.Output != "" && stdout.Len() == 0 && output.n == 0 {
+		stdoutWriter.Write([]byte(result.Output))
+	}
+	if err == nil && result.ExitCode != 0 {
+		err = fmt.Errorf("exit status %d", result.ExitCode)
+	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("step timed out after %v: %w", time.Duration(step.Timeout), err)
+	}
+	return stdout.Bytes(), output.String(), result.ExitCode, err
+}
+
+// captureOutputs 从标准输出中提取步骤声明的输出变量
+func captureOutputs(step *Step, stdout []byte) map[string]string {
+	if len(step.Outputs) == 0 {
+		return nil
+	}
+	outputs := make(map[string]string, len(step.Outputs))
+	for name, pattern := range step.Outputs {
+		if pattern == "" {
+			outputs[name] = strings.TrimSpace(string(stdout))
+			continue
+		}
+		// 正则在校验时已编译过
+		m := regexp.MustCompile(pattern).FindSubmatch(stdout)
+		switch {
+		case m == nil:
+			outputs[name] = ""
+		case len(m) > 1:
+			outputs[name] = string(m[1])
+		default:
+			outputs[name] = string(m[0])
+		}
+	}
+	return outputs
+}
+
+// eventWriter 将步骤的输出作为事件发送，并记录在步骤的输出中
+type eventWriter struct {
+	run     *run
+	step    string
+	attempt int
+	stderr  bool
+	buf     *bytes.Buffer // 捕获标准输出用于提取输出变量
+	output  *tailBuffer
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	w.run.mu.Lock()
+	defer w.run.mu.Unlock()
+	if w.buf != nil {
+		w.buf.Write(p)
+	}
+	w.output.Write(p)
+	event := &Event{Type: EventStepOutput, StepID: w.step, Attempt: w.attempt, Stdout: string(p)}
+	if w.stderr {
+		event = &Event{Type: EventStepOutput, StepID: w.step, Attempt: w.attempt, Stderr: string(p)}
+	}
+	w.run.emit(event)
+	return len(p), nil
+}
+
+// tailBuffer 只保留最后 max 个字节
+type tailBuffer struct {
+	max int
+	n   int64 // 写入的总字节数
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.n += int64(len(p))
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
@@ -0,0 +1,241 @@
+package workflow
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iamlongalong/runshell/pkg/executor"
+	"github.com/iamlongalong/runshell/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRunner() *Runner {
+	return NewRunner(executor.NewLocalExecutor(types.LocalConfig{AllowUnregisteredCommands: true}, nil, nil))
+}
+
+// eventRecorder 记录运行过程中的事件
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (r *eventRecorder) handle(e *Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []string
+	for _, e := range r.events {
+		if e.Type != EventStepOutput {
+			types = append(types, e.Type+":"+e.StepID)
+		}
+	}
+	return types
+}
+
+func stepByID(run *Run, id string) *StepState {
+	for _, st := range run.Steps {
+		if st.ID == id {
+			return st
+		}
+	}
+	return nil
+}
+
+func TestRunnerOutputsAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	wf, err := Parse([]byte(`
+name: outputs
+env:
+  GREETING: hello
+steps:
+  - id: version
+    run: echo "version=1.2.3"; echo done
+    outputs:
+      version: 'version=(\S+)'
+      all: ""
+  - id: use
+    needs: [version]
+    command: sh
+    args: [-c, 'echo "$GREETING $NAME ${{ steps.version.outputs.version }}"; pwd']
+    workdir: sub
+    env:
+      NAME: v${{ steps.version.outputs.version }}
+`))
+	require.NoError(t, err)
+
+	recorder := &eventRecorder{}
+	run, err := newTestRunner().WithOptions(&types.ExecuteOptions{WorkDir: dir}).Run(context.Background(), wf, recorder.handle)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, run.Status)
+	assert.Equal(t, "outputs", run.Workflow)
+	assert.False(t, run.EndTime.IsZero())
+
+	version := stepByID(run, "version")
+	assert.Equal(t, map[string]string{"version": "1.2.3", "all": "version=1.2.3\ndone"}, version.Outputs)
+	use := stepByID(run, "use")
+	assert.Equal(t, StatusSucceeded, use.Status)
+	assert.Equal(t, 1, use.Attempts)
+	assert.Equal(t, "hello v1.2.3 1.2.3\n"+filepath.Join(dir, "sub")+"\n", use.Output)
+
+	assert.Equal(t, []string{
+		"run_started:",
+		"step_started:version", "step_finished:version",
+		"step_started:use", "step_finished:use",
+		"run_finished:",
+	}, recorder.types())
+	for _, e := range recorder.events {
+		assert.Equal(t, run.ID, e.RunID)
+	}
+}
+
+func TestRunnerFailures(t *testing.T) {
+	wf, err := Parse([]byte(`
+steps:
+  - id: lint
+    run: echo lint failed >&2; exit 3
+    continue_on_error: true
+  - id: build
+    run: "false"
+  - id: report
+    needs: [lint]
+    run: echo report
+  - id: deploy
+    needs: [build, report]
+    run: echo deploy
+  - id: notify
+    needs: [deploy]
+    run: echo notify
+`))
+	require.NoError(t, err)
+
+	run, err := newTestRunner().Run(context.Background(), wf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, run.Status)
+
+	lint := stepByID(run, "lint")
+	assert.Equal(t, StatusFailed, lint.Status)
+	assert.Equal(t, 3, lint.ExitCode)
+	assert.Equal(t, "lint failed\n", lint.Output)
+	assert.Equal(t, StatusSucceeded, stepByID(run, "report").Status)
+	assert.Equal(t, StatusFailed, stepByID(run, "build").Status)
+	assert.Equal(t, StatusSkipped, stepByID(run, "deploy").Status)
+	assert.Equal(t, StatusSkipped, stepByID(run, "notify").Status)
+
+	// 只有 continue_on_error 的步骤失败时运行成功
+	wf.Steps = wf.Steps[:1]
+	run, err = newTestRunner().Run(context.Background(), wf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, run.Status)
+}
+
+func TestRunnerRetryAndTimeout(t *testing.T) {
+	dir := t.TempDir()
+	wf, err := Parse([]byte(`
+steps:
+  - id: flaky
+    run: echo x >> attempts; sh -c 'test $(wc -l < attempts) -ge 3'
+    retry:
+      max_attempts: 5
+      backoff: 1ms
+  - id: slow
+    run: sleep 5
+    timeout: 100ms
+    retry:
+      max_attempts: 2
+`))
+	require.NoError(t, err)
+
+	start := time.Now()
+	run, err := newTestRunner().WithOptions(&types.ExecuteOptions{WorkDir: dir}).Run(context.Background(), wf, nil)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 3*time.Second)
+
+	flaky := stepByID(run, "flaky")
+	assert.Equal(t, StatusSucceeded, flaky.Status)
+	assert.Equal(t, 3, flaky.Attempts)
+
+	slow := stepByID(run, "slow")
+	assert.Equal(t, StatusFailed, slow.Status)
+	assert.Equal(t, 2, slow.Attempts)
+	assert.Contains(t, slow.Error, "timed out")
+}
+
+func TestRunnerConcurrency(t *testing.T) {
+	wf, err := Parse([]byte(`
+steps:
+  - {id: a, run: sleep 0.3}
+  - {id: b, run: sleep 0.3}
+  - {id: c, run: sleep 0.3}
+  - {id: d, run: echo done, needs: [a, b, c]}
+`))
+	require.NoError(t, err)
+
+	start := time.Now()
+	run, err := newTestRunner().Run(context.Background(), wf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, run.Status)
+	assert.Less(t, time.Since(start), 800*time.Millisecond)
+
+	start = time.Now()
+	run, err = newTestRunner().WithMaxParallel(1).Run(context.Background(), wf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, run.Status)
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+}
+
+func TestRunnerCancel(t *testing.T) {
+	wf, err := Parse([]byte(`
+steps:
+  - {id: wait, run: sleep 10}
+  - {id: after, run: echo after, needs: [wait]}
+`))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	run, err := newTestRunner().Run(ctx, wf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCanceled, run.Status)
+	assert.Equal(t, StatusCanceled, stepByID(run, "wait").Status)
+	assert.Equal(t, StatusCanceled, stepByID(run, "after").Status)
+}
+
+func TestFileStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "runs")
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	wf, err := Parse([]byte("steps:\n  - {id: a, run: echo hi}"))
+	require.NoError(t, err)
+	runner := newTestRunner().WithStore(store)
+	first, err := runner.Run(context.Background(), wf, nil)
+	require.NoError(t, err)
+	second, err := runner.Run(context.Background(), wf, nil)
+	require.NoError(t, err)
+
+	stored, err := store.Get(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, stored.Status)
+	assert.Equal(t, "hi\n", stored.Steps[0].Output)
+
+	runs, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, second.ID, runs[0].ID)
+
+	_, err = store.Get("../etc/passwd")
+	assert.ErrorIs(t, err, ErrRunNotFound)
+	_, err = store.Get("missing")
+	assert.ErrorIs(t, err, ErrRunNotFound)
+}
@@ -0,0 +1,163 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrRunNotFound 表示运行记录不存在
+var ErrRunNotFound = errors.New("workflow run not found")
+
+// Store 保存工作流的运行状态，运行过程中每个步骤的状态变化后都会保存
+type Store interface {
+	// Save 保存运行状态，已存在时覆盖
+	Save(run *Run) error
+	// Get 返回运行状态，不存在时返回 ErrRunNotFound
+	Get(id string) (*Run, error)
+	// List 返回所有运行状态，按开始时间从新到旧排列
+	List() ([]*Run, error)
+}
+
+// MemoryStore 在内存中保存运行状态
+type MemoryStore struct {
+	mu   sync.RWMutex
+	runs map[string]*Run
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{runs: make(map[string]*Run)}
+}
+
+// Save 实现 Store 接口
+func (s *MemoryStore) Save(run *Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[run.ID] = run.Copy()
+	return nil
+}
+
+// Get 实现 Store 接口
+func (s *MemoryStore) Get(id string) (*Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return nil, ErrRunNotFound
+	}
+	return run.Copy(), nil
+}
+
+// List 实现 Store 接口
+func (s *MemoryStore) List() ([]*Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	runs := make([]*Run, 0, len(s.runs))
+	for _, run := range s.runs {
+		runs = append(runs, run.Copy())
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+// FileStore 将每次运行的状态保存为目录中的一个 JSON 文件：<dir>/<id>.json
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStore 创建文件存储，目录不存在时自动创建
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create workflow run directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save 实现 Store 接口，先写入临时文件再重命名，读取时不会看到写了一半的文件
+func (s *FileStore) Save(run *Run) error {
+	if err := validateRunID(run.ID); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode workflow run: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp := filepath.Join(s.dir, "."+run.ID+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save workflow run: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, run.ID+".json")); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save workflow run: %w", err)
+	}
+	return nil
+}
+
+// Get 实现 Store 接口
+func (s *FileStore) Get(id string) (*Run, error) {
+	if err := validateRunID(id); err != nil {
+		return nil, ErrRunNotFound
+	}
+	return s.load(filepath.Join(s.dir, id+".json"))
+}
+
+// List 实现 Store 接口
+func (s *FileStore) List() ([]*Run, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow runs: %w", err)
+	}
+	runs := make([]*Run, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		run, err := s.load(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+func (s *FileStore) load(path string) (*Run, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrRunNotFound
+		}
+		return nil, fmt.Errorf("failed to read workflow run: %w", err)
+	}
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to decode workflow run %s: %w", path, err)
+	}
+	return &run, nil
+}
+
+// validateRunID 校验运行 ID，防止路径穿越
+func validateRunID(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("invalid workflow run id %q", id)
+	}
+	return nil
+}
+
+// sortRuns 按开始时间从新到旧排列
+func sortRuns(runs []*Run) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartTime.After(runs[j].StartTime)
+	})
+}
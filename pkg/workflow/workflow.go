@@ -0,0 +1,244 @@
+// Package workflow 实现了由多个命令组成的工作流的定义与执行。
+//
+// 工作流使用 YAML 或 JSON 定义，每个步骤执行一个 shell 脚本（run）或一个命令（command 和 args），
+// 通过 needs 声明依赖的步骤，构成有向无环图，没有依赖关系的步骤并发执行。
+// 步骤的标准输出可以通过 outputs 捕获为变量，依赖它的步骤在脚本、命令、参数、环境变量和工作目录中
+// 通过 ${{ steps.<id>.outputs.<name> }} 引用。
+//
+// 示例：
+//
+//	name: release
+//	env:
+//	  CGO_ENABLED: "0"
+//	steps:
+//	  - id: version
+//	    run: git describe --tags
+//	    outputs:
+//	      tag: ""
+//	  - id: build
+//	    needs: [version]
+//	    run: go build -ldflags "-X main.version=${{ steps.version.outputs.tag }}" -o bin/app .
+//	    timeout: 5m
+//	  - id: test
+//	    run: go test ./...
+//	    retry:
+//	      max_attempts: 3
+//	      backoff: 2s
+//	  - id: lint
+//	    run: golangci-lint run
+//	    continue_on_error: true
+package workflow
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Workflow 表示一个工作流
+type Workflow struct {
+	Name    string            `json:"name,omitempty" yaml:"name,omitempty"`       // 工作流名称
+	Env     map[string]string `json:"env,omitempty" yaml:"env,omitempty"`         // 所有步骤共用的环境变量
+	WorkDir string            `json:"workdir,omitempty" yaml:"workdir,omitempty"` // 所有步骤的工作目录
+	Steps   []*Step           `json:"steps" yaml:"steps"`                         // 步骤列表
+}
+
+// Step 表示工作流中的一个步骤，Run 和 Command 必须且只能指定一个
+type Step struct {
+	ID              string            `json:"id" yaml:"id"`                                                   // 步骤 ID，只能包含字母、数字、下划线和连字符
+	Name            string            `json:"name,omitempty" yaml:"name,omitempty"`                           // 步骤名称
+	Run             string            `json:"run,omitempty" yaml:"run,omitempty"`                             // 按 shell 语法执行的脚本
+	Command         string            `json:"command,omitempty" yaml:"command,omitempty"`                     // 直接执行的命令
+	Args            []string          `json:"args,omitempty" yaml:"args,omitempty"`                           // 命令参数
+	Needs           []string          `json:"needs,omitempty" yaml:"needs,omitempty"`                         // 依赖的步骤 ID
+	Env             map[string]string `json:"env,omitempty" yaml:"env,omitempty"`                             // 步骤的环境变量，覆盖工作流的环境变量
+	WorkDir         string            `json:"workdir,omitempty" yaml:"workdir,omitempty"`                     // 工作目录，相对路径相对于工作流的工作目录
+	Timeout         Duration          `json:"timeout,omitempty" yaml:"timeout,omitempty"`                     // 每次执行的超时时间
+	Retry           *Retry            `json:"retry,omitempty" yaml:"retry,omitempty"`                         // 失败后的重试策略
+	ContinueOnError bool              `json:"continue_on_error,omitempty" yaml:"continue_on_error,omitempty"` // 失败时不使工作流失败，依赖它的步骤继续执行
+	Outputs         map[string]string `json:"outputs,omitempty" yaml:"outputs,omitempty"`                     // 变量名 -> 从标准输出中提取值的正则，为空时取去掉首尾空白的全部标准输出
+}
+
+// Retry 表示步骤的重试策略
+type Retry struct {
+	MaxAttempts int      `json:"max_attempts" yaml:"max_attempts"`           // 最大执行次数，包括第一次
+	Backoff     Duration `json:"backoff,omitempty" yaml:"backoff,omitempty"` // 第一次重试前的等待时间，之后每次加倍，为 0 时立即重试
+}
+
+// Duration 是以 Go 时间格式（如 "30s"、"5m"）表示的时间间隔
+type Duration time.Duration
+
+// MarshalText 实现 encoding.TextMarshaler 接口
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText 实现 encoding.TextUnmarshaler 接口
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+var (
+	// idPattern 是步骤 ID 和输出变量名的格式
+	idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// refPattern 匹配对步骤输出的引用 ${{ steps.<id>.outputs.<name> }}
+	refPattern = regexp.MustCompile(`\$\{\{\s*steps\.([A-Za-z0-9_-]+)\.outputs\.([A-Za-z0-9_-]+)\s*\}\}`)
+)
+
+// Parse 解析 YAML 或 JSON 格式的工作流并校验
+func Parse(data []byte) (*Workflow, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var wf Workflow
+	if err := dec.Decode(&wf); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow: %w", err)
+	}
+	if err := wf.Validate(); err != nil {
+		return nil, err
+	}
+	return &wf, nil
+}
+
+// Load 从文件中读取工作流
+func Load(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow: %w", err)
+	}
+	return Parse(data)
+}
+
+// Validate 校验工作流：步骤 ID 唯一，依赖的步骤存在且没有循环，
+// 引用的输出由该步骤直接或间接依赖的步骤声明
+func (wf *Workflow) Validate() error {
+	if len(wf.Steps) == 0 {
+		return fmt.Errorf("workflow has no steps")
+	}
+	steps := make(map[string]*Step, len(wf.Steps))
+	for i, step := range wf.Steps {
+		if step == nil {
+			return fmt.Errorf("step %d is empty", i+1)
+		}
+		if !idPattern.MatchString(step.ID) {
+			return fmt.Errorf("step %d: invalid id %q", i+1, step.ID)
+		}
+		if _, ok := steps[step.ID]; ok {
+			return fmt.Errorf("duplicate step id %s", step.ID)
+		}
+		steps[step.ID] = step
+
+		if (step.Run == "") == (step.Command == "") {
+			return fmt.Errorf("step %s: exactly one of run and command is required", step.ID)
+		}
+		if step.Run != "" && len(step.Args) > 0 {
+			return fmt.Errorf("step %s: args can only be used with command", step.ID)
+		}
+		if step.Timeout < 0 {
+			return fmt.Errorf("step %s: timeout must not be negative", step.ID)
+		}
+		if step.Retry != nil && step.Retry.MaxAttempts < 1 {
+			return fmt.Errorf("step %s: retry max_attempts must be at least 1", step.ID)
+		}
+		if step.Retry != nil && step.Retry.Backoff < 0 {
+			return fmt.Errorf("step %s: retry backoff must not be negative", step.ID)
+		}
+		for name, pattern := range step.Outputs {
+			if !idPattern.MatchString(name) {
+				return fmt.Errorf("step %s: invalid output name %q", step.ID, name)
+			}
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("step %s: invalid pattern for output %s: %w", step.ID, name, err)
+			}
+		}
+	}
+	for _, step := range wf.Steps {
+		for _, need := range step.Needs {
+			if _, ok := steps[need]; !ok {
+				return fmt.Errorf("step %s needs unknown step %s", step.ID, need)
+			}
+		}
+	}
+
+	// 按深度优先检查循环依赖，并计算每个步骤的所有祖先
+	ancestors := make(map[string]map[string]bool, len(steps))
+	visiting := make(map[string]bool)
+	var visit func(id string) error
+	visit = func(id string) error {
+		if ancestors[id] != nil {
+			return nil
+		}
+		if visiting[id] {
+			return fmt.Errorf("dependency cycle at step %s", id)
+		}
+		visiting[id] = true
+		set := make(map[string]bool)
+		for _, need := range steps[id].Needs {
+			if err := visit(need); err != nil {
+				return err
+			}
+			set[need] = true
+			for a := range ancestors[need] {
+				set[a] = true
+			}
+		}
+		visiting[id] = false
+		ancestors[id] = set
+		return nil
+	}
+	for _, step := range wf.Steps {
+		if err := visit(step.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, step := range wf.Steps {
+		for _, ref := range step.refs() {
+			from, name := ref[0], ref[1]
+			if !ancestors[step.ID][from] {
+				return fmt.Errorf("step %s references outputs of step %s which it does not depend on", step.ID, from)
+			}
+			if _, ok := steps[from].Outputs[name]; !ok {
+				return fmt.Errorf("step %s references undeclared output %s of step %s", step.ID, name, from)
+			}
+		}
+	}
+	return nil
+}
+
+// fields 返回步骤中可以引用输出的所有字段
+func (s *Step) fields() []string {
+	fields := []string{s.Run, s.Command, s.WorkDir}
+	fields = append(fields, s.Args...)
+	for _, v := range s.Env {
+		fields = append(fields, v)
+	}
+	return fields
+}
+
+// refs 返回步骤中引用的输出，每一项为 [步骤 ID, 输出名称]
+func (s *Step) refs() [][2]string {
+	var refs [][2]string
+	for _, field := range s.fields() {
+		for _, m := range refPattern.FindAllStringSubmatch(field, -1) {
+			refs = append(refs, [2]string{m[1], m[2]})
+		}
+	}
+	return refs
+}
+
+// expand 将字符串中对步骤输出的引用替换为输出的值
+func expand(s string, outputs map[string]map[string]string) string {
+	return refPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		m := refPattern.FindStringSubmatch(ref)
+		return outputs[m[1]][m[2]]
+	})
+}
@@ -0,0 +1,104 @@
+package workflow
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	wf, err := Parse([]byte(`
+name: build
+env:
+  GOOS: linux
+steps:
+  - id: version
+    run: echo v1.2.3
+    outputs:
+      tag: ""
+  - id: build
+    needs: [version]
+    command: go
+    args: [build, "-ldflags=-X main.version=${{ steps.version.outputs.tag }}"]
+    timeout: 5m
+    retry:
+      max_attempts: 3
+      backoff: 2s
+    continue_on_error: true
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "build", wf.Name)
+	assert.Equal(t, map[string]string{"GOOS": "linux"}, wf.Env)
+	require.Len(t, wf.Steps, 2)
+	build := wf.Steps[1]
+	assert.Equal(t, []string{"version"}, build.Needs)
+	assert.Equal(t, Duration(5*time.Minute), build.Timeout)
+	assert.Equal(t, &Retry{MaxAttempts: 3, Backoff: Duration(2 * time.Second)}, build.Retry)
+	assert.True(t, build.ContinueOnError)
+	assert.Equal(t, [][2]string{{"version", "tag"}}, build.refs())
+
+	// JSON 是 YAML 的子集
+	data, err := json.Marshal(wf)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"timeout":"5m0s"`)
+	parsed, err := Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, wf, parsed)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{name: "no steps", yaml: "name: empty", err: "no steps"},
+		{name: "unknown field", yaml: "steps:\n  - id: a\n    run: x\n    foo: bar", err: "field foo not found"},
+		{name: "invalid id", yaml: "steps:\n  - id: a.b\n    run: x", err: "invalid id"},
+		{name: "duplicate id", yaml: "steps:\n  - {id: a, run: x}\n  - {id: a, run: y}", err: "duplicate step id a"},
+		{name: "no command", yaml: "steps:\n  - id: a", err: "exactly one of run and command"},
+		{name: "run and command", yaml: "steps:\n  - {id: a, run: x, command: y}", err: "exactly one of run and command"},
+		{name: "args with run", yaml: "steps:\n  - {id: a, run: x, args: [y]}", err: "args can only be used with command"},
+		{name: "invalid timeout", yaml: "steps:\n  - {id: a, run: x, timeout: soon}", err: "invalid duration"},
+		{name: "invalid retry", yaml: "steps:\n  - {id: a, run: x, retry: {max_attempts: 0}}", err: "max_attempts must be at least 1"},
+		{name: "invalid pattern", yaml: "steps:\n  - {id: a, run: x, outputs: {v: '('}}", err: "invalid pattern for output v"},
+		{name: "unknown need", yaml: "steps:\n  - {id: a, run: x, needs: [b]}", err: "needs unknown step b"},
+		{name: "cycle", yaml: "steps:\n  - {id: a, run: x, needs: [c]}\n  - {id: b, run: x, needs: [a]}\n  - {id: c, run: x, needs: [b]}", err: "dependency cycle"},
+		{
+			name: "reference without dependency",
+			yaml: "steps:\n  - {id: a, run: x, outputs: {v: ''}}\n  - {id: b, run: 'echo ${{ steps.a.outputs.v }}'}",
+			err:  "does not depend on",
+		},
+		{
+			name: "undeclared output",
+			yaml: "steps:\n  - {id: a, run: x}\n  - {id: b, run: x, needs: [a], env: {V: '${{ steps.a.outputs.v }}'}}",
+			err:  "undeclared output v of step a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+
+	t.Run("indirect dependency", func(t *testing.T) {
+		_, err := Parse([]byte(`
+steps:
+  - {id: a, run: x, outputs: {v: ''}}
+  - {id: b, run: x, needs: [a]}
+  - {id: c, run: 'echo ${{ steps.a.outputs.v }}', needs: [b]}
+`))
+		assert.NoError(t, err)
+	})
+}
+
+func TestExpand(t *testing.T) {
+	outputs := map[string]map[string]string{"a": {"v": "1"}}
+	assert.Equal(t, "x=1 y=", expand("x=${{ steps.a.outputs.v }} y=${{steps.b.outputs.w}}", outputs))
+	assert.Equal(t, "echo $HOME ${X}", expand("echo $HOME ${X}", outputs))
+}